@@ -97,3 +97,7 @@ func (chain *Chain) Listen() Block {
 func (chain *Chain) Order(txn Transaction) error {
 	return chain.node.consensus.SubmitRequest(txn.ToBytes())
 }
+
+func (chain *Chain) Stop() {
+	chain.node.Stop()
+}
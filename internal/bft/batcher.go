@@ -8,6 +8,9 @@ package bft
 import (
 	"sync"
 	"time"
+
+	"github.com/hyperledger-labs/SmartBFT/pkg/api"
+	"github.com/pkg/errors"
 )
 
 // BatchBuilder implements Batcher
@@ -19,6 +22,16 @@ type BatchBuilder struct {
 	batchTimeout  time.Duration
 	closeChan     chan struct{}
 	closeLock     sync.Mutex // Reset and Close may be called by different threads
+	closedAt      time.Time
+
+	// ResetGracePeriod is the interval after a Close within which the next NextBatch counts the batch timeout
+	// from the start of the batch accumulation that was interrupted by the Close, instead of from its own start.
+	// Zero means the batch timeout is always counted anew.
+	ResetGracePeriod time.Duration
+	// interruptedBatchStart is the time the accumulation of the batch interrupted by Close started
+	interruptedBatchStart time.Time
+	// PreOrder, if set, orders the requests of each batch, and rejects the requests it leaves out.
+	PreOrder api.PreOrderFilter
 }
 
 // NewBatchBuilder creates a new BatchBuilder
@@ -38,16 +51,25 @@ func NewBatchBuilder(pool RequestPool, submittedChan chan struct{}, maxMsgCount
 // The method returns as soon as the batch is full, in terms of request count or total size, or after a timeout.
 // The method may block.
 func (b *BatchBuilder) NextBatch() [][]byte {
+	return b.preOrder(b.nextBatch())
+}
+
+func (b *BatchBuilder) nextBatch() [][]byte {
+	start := b.batchStart()
+
 	currBatch, full := b.pool.NextRequests(b.maxMsgCount, b.maxSizeBytes, true)
 	if full {
 		return currBatch
 	}
 
-	timeout := time.After(b.batchTimeout) // TODO use task-scheduler based on logical time
+	timeout := time.After(b.batchTimeout - time.Since(start)) // TODO use task-scheduler based on logical time
 
 	for {
 		select {
 		case <-b.closeChan:
+			if b.ResetGracePeriod > 0 {
+				b.interruptedBatchStart = start
+			}
 			return nil
 		case <-timeout:
 			currBatch, _ = b.pool.NextRequests(b.maxMsgCount, b.maxSizeBytes, false)
@@ -62,6 +84,54 @@ func (b *BatchBuilder) NextBatch() [][]byte {
 	}
 }
 
+// preOrder returns the batch in the order of the pre-order filter, if there is one,
+// and removes the requests the filter rejected from the pool.
+func (b *BatchBuilder) preOrder(batch [][]byte) [][]byte {
+	if b.PreOrder == nil || len(batch) == 0 {
+		return batch
+	}
+	ordered := b.PreOrder.Order(batch)
+	if len(ordered) == len(batch) {
+		return ordered
+	}
+	accepted := make(map[string]struct{}, len(ordered))
+	for _, req := range ordered {
+		accepted[string(req)] = struct{}{}
+	}
+	rejected := make(map[string]struct{}, len(batch)-len(ordered))
+	for _, req := range batch {
+		if _, exists := accepted[string(req)]; !exists {
+			rejected[string(req)] = struct{}{}
+		}
+	}
+	b.pool.Prune(func(req []byte) error {
+		if _, exists := rejected[string(req)]; exists {
+			return errors.New("rejected by the pre-order filter")
+		}
+		return nil
+	})
+	return ordered
+}
+
+// batchStart returns the time from which the batch interval of the next batch is counted, which is the start of
+// the interrupted batch accumulation if the batcher was closed no longer than ResetGracePeriod ago.
+func (b *BatchBuilder) batchStart() time.Time {
+	interrupted := b.interruptedBatchStart
+	b.interruptedBatchStart = time.Time{}
+	if interrupted.IsZero() {
+		return time.Now()
+	}
+
+	b.closeLock.Lock()
+	closedAt := b.closedAt
+	b.closeLock.Unlock()
+
+	if time.Since(closedAt) > b.ResetGracePeriod {
+		return time.Now()
+	}
+	return interrupted
+}
+
 // Close closes the close channel to stop NextBatch
 func (b *BatchBuilder) Close() {
 	b.closeLock.Lock()
@@ -72,6 +142,7 @@ func (b *BatchBuilder) Close() {
 	default:
 	}
 	close(b.closeChan)
+	b.closedAt = time.Now()
 }
 
 // Closed returns true if the batcher is closed
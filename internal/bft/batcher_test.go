@@ -7,6 +7,7 @@ package bft_test
 
 import (
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -238,3 +239,182 @@ func TestBatcherReset(t *testing.T) {
 	assert.Len(t, res, 0)
 	pool.Close()
 }
+
+func TestBatcherResetGracePeriod(t *testing.T) {
+	for _, testCase := range []struct {
+		name        string
+		gracePeriod time.Duration
+		// the batch is returned within the batch timeout of the first start, or of the last reset
+		sinceFirstStart bool
+	}{
+		{name: "within the grace period", gracePeriod: 5 * time.Second, sinceFirstStart: true},
+		{name: "without a grace period", gracePeriod: 0, sinceFirstStart: false},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			basicLog, err := zap.NewDevelopment()
+			assert.NoError(t, err)
+			log := basicLog.Sugar()
+			insp := &testRequestInspector{}
+
+			submittedChan := make(chan struct{}, 1)
+			byteReq := makeTestRequest("1", "1", "foo")
+			pool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{QueueSize: 3}, submittedChan)
+			defer pool.Close()
+			err = pool.Submit(byteReq)
+			assert.NoError(t, err)
+
+			batchTimeout := time.Second
+			batcher := bft.NewBatchBuilder(pool, submittedChan, 100, 2048, batchTimeout)
+			batcher.ResetGracePeriod = testCase.gracePeriod
+
+			start := time.Now()
+
+			// Two view changes in quick succession interrupt the batch accumulation
+			for _, after := range []time.Duration{600 * time.Millisecond, 100 * time.Millisecond} {
+				go func(after time.Duration) {
+					time.Sleep(after)
+					batcher.Close()
+				}(after)
+				assert.Nil(t, batcher.NextBatch())
+				batcher.Reset()
+			}
+			lastReset := time.Now()
+
+			res := batcher.NextBatch()
+			assert.Equal(t, [][]byte{byteReq}, res)
+			if testCase.sinceFirstStart {
+				// The batch timeout is counted from when the interrupted batch started
+				assert.Less(t, time.Since(start), batchTimeout+400*time.Millisecond)
+			} else {
+				// The batch timeout is counted anew after the last reset
+				assert.GreaterOrEqual(t, time.Since(lastReset), batchTimeout)
+			}
+		})
+	}
+}
+
+type epochMarkerInspector struct{}
+
+func (epochMarkerInspector) IsBatchBoundary(req []byte) bool {
+	_, _, data := parseTestRequest(req)
+	return data == "epoch"
+}
+
+func TestBatcherCutsBatchOnBoundary(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	insp := &testRequestInspector{}
+
+	submittedChan := make(chan struct{}, 1)
+	pool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{
+		QueueSize:         10,
+		BoundaryInspector: epochMarkerInspector{},
+	}, submittedChan)
+	defer pool.Close()
+
+	byteReq1 := makeTestRequest("1", "1", "foo")
+	byteReq2 := makeTestRequest("2", "2", "foo")
+	marker := makeTestRequest("3", "3", "epoch")
+	byteReq4 := makeTestRequest("4", "4", "foo")
+	for _, req := range [][]byte{byteReq1, byteReq2, marker, byteReq4} {
+		assert.NoError(t, pool.Submit(req))
+	}
+
+	// The batch is neither full nor timed out, but it is cut right after the boundary request
+	batcher := bft.NewBatchBuilder(pool, submittedChan, 100, 2048, time.Hour)
+	assert.Equal(t, [][]byte{byteReq1, byteReq2, marker}, batcher.NextBatch())
+
+	for _, req := range [][]byte{byteReq1, byteReq2, marker} {
+		assert.NoError(t, pool.RemoveRequest(insp.RequestID(req)))
+	}
+
+	// A boundary request submitted while the batch accumulates cuts it as well
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		assert.NoError(t, pool.Submit(makeTestRequest("5", "5", "epoch")))
+	}()
+	assert.Equal(t, [][]byte{byteReq4, makeTestRequest("5", "5", "epoch")}, batcher.NextBatch())
+}
+
+func TestBatcherCutsBatchOnVerificationSequenceChange(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	insp := &testRequestInspector{}
+
+	var verificationSeq atomic.Uint64
+	submittedChan := make(chan struct{}, 1)
+	pool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{
+		QueueSize:            10,
+		VerificationSequence: verificationSeq.Load,
+	}, submittedChan)
+	defer pool.Close()
+
+	byteReq1 := makeTestRequest("1", "1", "foo")
+	byteReq2 := makeTestRequest("2", "2", "foo")
+	assert.NoError(t, pool.Submit(byteReq1))
+	assert.NoError(t, pool.Submit(byteReq2))
+
+	// The verification sequence changes while the batch accumulates, which cuts the batch
+	// right before the first request submitted after the change
+	byteReq3 := makeTestRequest("3", "3", "foo")
+	byteReq4 := makeTestRequest("4", "4", "foo")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		verificationSeq.Store(1)
+		assert.NoError(t, pool.Submit(byteReq3))
+		assert.NoError(t, pool.Submit(byteReq4))
+	}()
+	batcher := bft.NewBatchBuilder(pool, submittedChan, 100, 2048, time.Hour)
+	assert.Equal(t, [][]byte{byteReq1, byteReq2}, batcher.NextBatch())
+
+	for _, req := range [][]byte{byteReq1, byteReq2} {
+		assert.NoError(t, pool.RemoveRequest(insp.RequestID(req)))
+	}
+
+	// The requests submitted after the change are batched together, and apart from the requests submitted
+	// after the next change
+	byteReq5 := makeTestRequest("5", "5", "foo")
+	verificationSeq.Store(2)
+	assert.NoError(t, pool.Submit(byteReq5))
+	assert.Equal(t, [][]byte{byteReq3, byteReq4}, batcher.NextBatch())
+}
+
+type reversingFilter struct{}
+
+func (reversingFilter) Order(requests [][]byte) [][]byte {
+	ordered := make([][]byte, 0, len(requests))
+	for i := len(requests) - 1; i >= 0; i-- {
+		if clientID, _, _ := parseTestRequest(requests[i]); clientID != "mallory" {
+			ordered = append(ordered, requests[i])
+		}
+	}
+	return ordered
+}
+
+func TestBatcherPreOrder(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	submittedChan := make(chan struct{}, 1)
+	pool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{QueueSize: 10}, submittedChan)
+	defer pool.Close()
+
+	byteReq1 := makeTestRequest("alice", "1", "foo")
+	byteReq2 := makeTestRequest("alice", "2", "foo")
+	rejected := makeTestRequest("mallory", "1", "foo")
+	byteReq3 := makeTestRequest("alice", "3", "foo")
+	for _, req := range [][]byte{byteReq1, byteReq2, rejected, byteReq3} {
+		assert.NoError(t, pool.Submit(req))
+	}
+
+	// The batch is in the order of the filter, and the rejected request is removed from the pool
+	batcher := bft.NewBatchBuilder(pool, submittedChan, 4, 2048, time.Hour)
+	batcher.PreOrder = reversingFilter{}
+	assert.Equal(t, [][]byte{byteReq3, byteReq2, byteReq1}, batcher.NextBatch())
+	assert.Equal(t, 3, pool.Size())
+	assert.False(t, pool.Contains(insp.RequestID(rejected)))
+}
@@ -6,6 +6,9 @@
 package bft
 
 import (
+	"bytes"
+	"context"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,6 +17,16 @@ import (
 	"github.com/hyperledger-labs/SmartBFT/pkg/api"
 	"github.com/hyperledger-labs/SmartBFT/pkg/types"
 	protos "github.com/hyperledger-labs/SmartBFT/smartbftprotos"
+	"github.com/pkg/errors"
+)
+
+const (
+	// viewIdleChecks is the number of times per MaxViewIdle that the progress of the view is checked
+	viewIdleChecks = 10
+	// minViewIdleCheckInterval bounds how often the progress of the view is checked when MaxViewIdle is tiny
+	minViewIdleCheckInterval = time.Millisecond
+	// forwardedRequestMaxClockSkew is how far in the future the submission time of a forwarded request may be
+	forwardedRequestMaxClockSkew = time.Second
 )
 
 // Decider delivers the proposal with signatures to the application
@@ -46,8 +59,14 @@ type Batcher interface {
 type RequestPool interface {
 	Prune(predicate func([]byte) error)
 	Submit(request []byte) error
+	SubmitForwarded(request []byte, submitted time.Time) error
+	SubmitBatch(requests [][]byte) error
 	Size() int
+	Contains(request types.RequestInfo) bool
+	SubmissionTime(request types.RequestInfo) (time.Time, bool)
+	Request(request types.RequestInfo) ([]byte, bool)
 	NextRequests(maxCount int, maxSizeBytes uint64, check bool) (batch [][]byte, full bool)
+	Prioritize(request types.RequestInfo) bool
 	RemoveRequest(request types.RequestInfo) error
 	StopTimers()
 	RestartTimers()
@@ -61,6 +80,8 @@ type LeaderMonitor interface {
 	ChangeRole(role Role, view uint64, leaderID uint64)
 	ProcessMsg(sender uint64, msg *protos.Message)
 	InjectArtificialHeartbeat(sender uint64, msg *protos.Message)
+	PeerActive(sender uint64)
+	InactivePeers(nodes []uint64) []uint64
 	HeartbeatWasSent()
 	Close()
 	StopLeaderSendMsg()
@@ -74,6 +95,7 @@ type Proposer interface {
 	Stopped() bool
 	GetLeaderID() uint64
 	GetMetadata() []byte
+	CurrentSequence() uint64
 	HandleMessage(sender uint64, m *protos.Message)
 }
 
@@ -99,12 +121,39 @@ type Controller struct {
 	Verifier           api.Verifier
 	Logger             api.Logger
 	Assembler          api.Assembler
+	CandidateAssembler api.CandidateAssembler
+	CandidatesMaxCount uint64
+	FallibleAssembler  api.FallibleAssembler
+	AssembleAttempts   uint64
+	AssembleBackoff    time.Duration
+	AssembleMaxBackoff time.Duration
+	MaxProposalBytes   uint64
+	FirstProposalDelay time.Duration
+	ProposalInterval   time.Duration
+	CatchUpDelay       time.Duration
+	MaxLeaderIdle      time.Duration
+	MaxViewIdle        time.Duration
+	LogLeaderToken     bool
+	AsyncDelivery      bool
 	Application        api.Application
 	Deliver            api.Application
 	FailureDetector    FailureDetector
 	Synchronizer       api.Synchronizer
+	CancellableSync    api.CancellableSynchronizer
+	BroadcastWorkers   uint64
+	SendTimeout        time.Duration
+	ForwardQuota       uint64
+	ForwardMaxAge      time.Duration
+	ProposeDigests     bool
+	PayloadCompactor   api.PayloadCompactor
+	RequestFetcher     api.RequestFetcher
+	FetchTimeout       time.Duration
+	FutureMsgsLimit    int
 	Signer             api.Signer
+	KeyRotator         api.KeyRotator
 	RequestInspector   api.RequestInspector
+	ClientSigVerifier  api.ClientSignatureVerifier
+	RequestAbandoned   api.RequestAbandonedHandler
 	WAL                api.WriteAheadLog
 	ProposerBuilder    ProposerBuilder
 	Checkpoint         *types.Checkpoint
@@ -112,6 +161,18 @@ type Controller struct {
 	Collector          *StateCollector
 	State              State
 	InFlight           *InFlightData
+	LeaderHistory      *LeaderHistory
+	DetectQuorumLoss   bool
+	DetectDuplicateID  bool
+	VerifySynced       bool
+	PrioritizeTimedOut bool
+	ForwardToNext      bool
+	PreOrder           api.PreOrderFilter
+	QuorumObserver     api.QuorumObserver
+	SuspicionObserver  api.SuspicionObserver
+	LeadershipObserver api.LeadershipObserver
+	FaultInjector      api.FaultInjector
+	DryRun             *DryRun
 	MetricsView        *api.MetricsView
 	quorum             int
 
@@ -119,6 +180,8 @@ type Controller struct {
 
 	currViewLock   sync.RWMutex
 	currViewNumber uint64
+	viewAborted    chan struct{} // closed once the current view is aborted
+	viewStarts     uint64        // the number of views started so far
 
 	currDecisionsInViewLock sync.RWMutex
 	currDecisionsInView     uint64
@@ -130,17 +193,43 @@ type Controller struct {
 	stopChan chan struct{}
 
 	syncChan             chan struct{}
+	syncCancelLock       sync.Mutex
+	syncCancel           context.CancelFunc
 	decisionChan         chan decision
 	deliverChan          chan struct{}
+	deliveryQueue        chan decision // decisions for the delivery worker to deliver in order
+	deliveredChan        chan decision // decisions the delivery worker delivered
 	leaderToken          chan struct{}
 	verificationSequence atomic.Uint64
+	quorumUnreachable    atomic.Bool
+	sendFailuresLock     sync.Mutex
+	sendFailures         map[uint64]struct{} // the nodes that sending to timed out or dropped messages
+	peerSendersLock      sync.Mutex
+	peerSenders          map[uint64]*peerSender
+	sendSlots            chan struct{} // bounds the number of senders that send at the same time
+	forwardedLock        sync.Mutex
+	forwarded            map[uint64]map[types.RequestInfo]struct{}
+	proposingPaused      atomic.Bool
+	proposing            atomic.Bool // set while the run loop proposes with the leader token
+	leaderTokenHeld      atomic.Bool // whether the leader token was held at its last logged transition
+	futureMsgsLock       sync.Mutex
+	futureMsgs           []*incMsg
+	futureMsgsSynced     bool
+	nextProposalTime     time.Time
+	assembleFailures     uint64      // the consecutive failed attempts to assemble the next proposal
+	leaderIdleTimer      *time.Timer // fires if a proposal of this leader is not committed in time
+	appDeliverer         ViewRecordingDeliverer
+	appDelivererOnce     sync.Once
+	leading              bool
+	leadershipNotified   bool
 
 	controllerDone sync.WaitGroup
 
 	ViewSequences *atomic.Value
 
-	StartedWG *sync.WaitGroup
-	syncLock  sync.Mutex
+	StartedWG     *sync.WaitGroup
+	syncLock      sync.Mutex
+	outOfSequence map[uint64]outOfSequenceDecision // decisions ahead of the checkpoint, guarded by syncLock
 }
 
 func (c *Controller) blacklist() []uint64 {
@@ -179,6 +268,24 @@ func (c *Controller) currentViewLeader() uint64 {
 	return view.GetLeaderID()
 }
 
+// CurrentProposalSequence returns the sequence the current view is working on, which is one past the last
+// sequence the view decided on, or zero if there is no view
+func (c *Controller) CurrentProposalSequence() uint64 {
+	c.currViewLock.RLock()
+	view := c.currView
+	c.currViewLock.RUnlock()
+
+	if view == nil {
+		return 0
+	}
+	return view.CurrentSequence()
+}
+
+// CurrentView returns the number of the view the controller is in
+func (c *Controller) CurrentView() uint64 {
+	return c.getCurrentViewNumber()
+}
+
 func (c *Controller) getCurrentViewNumber() uint64 {
 	c.currViewLock.RLock()
 	defer c.currViewLock.RUnlock()
@@ -229,28 +336,202 @@ func (c *Controller) GetLeaderID() uint64 {
 	return c.leaderID()
 }
 
+// ExpectedLeader returns the leader the given view is started with, according to the current nodes and blacklist
+func (c *Controller) ExpectedLeader(view uint64) uint64 {
+	return getLeaderID(view, c.N, c.NodesList, c.LeaderRotation, 0, c.DecisionsPerLeader, c.blacklist())
+}
+
+// UnreachablePeers returns the sorted IDs of the nodes that no activity was observed from within the heartbeat
+// timeout, if DetectQuorumLoss is set, and of the nodes that sending to timed out or dropped messages,
+// until a send to them completes
+func (c *Controller) UnreachablePeers() []uint64 {
+	var peers []uint64
+	for _, node := range c.NodesList {
+		if node != c.ID {
+			peers = append(peers, node)
+		}
+	}
+
+	unreachable := make(map[uint64]struct{})
+	for _, node := range c.LeaderMonitor.InactivePeers(peers) {
+		unreachable[node] = struct{}{}
+	}
+	c.sendFailuresLock.Lock()
+	for node := range c.sendFailures {
+		unreachable[node] = struct{}{}
+	}
+	c.sendFailuresLock.Unlock()
+
+	var result []uint64
+	for _, node := range peers {
+		if _, exists := unreachable[node]; exists {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// OnQuorumReachabilityChange is called by the leader monitor when a quorum of nodes becomes unreachable,
+// in which case proposing is paused, or reachable again, in which case proposing is resumed
+func (c *Controller) OnQuorumReachabilityChange(reachable bool) {
+	c.quorumUnreachable.Store(!reachable)
+	if c.QuorumObserver != nil {
+		c.QuorumObserver.OnQuorumReachabilityChange(reachable)
+	}
+	// Resume proposing only if it was paused, as otherwise the leader token is acquired once the
+	// in-flight proposal is decided
+	if reachable && c.proposingPaused.Swap(false) {
+		if iAm, _ := c.iAmTheLeader(); iAm {
+			c.acquireLeaderToken()
+		}
+	}
+}
+
+// QuorumReachable returns false if this node is the leader and it does not observe activity from a quorum of nodes
+func (c *Controller) QuorumReachable() bool {
+	return !c.quorumUnreachable.Load()
+}
+
+// LeaderForView returns the leader the given view was started with, and false if it isn't known,
+// either because this node didn't take part in that view or because it is too old to be remembered.
+func (c *Controller) LeaderForView(view uint64) (uint64, bool) {
+	if c.LeaderHistory == nil {
+		return 0, false
+	}
+	return c.LeaderHistory.LeaderForView(view)
+}
+
 // HandleRequest handles a request from the client
 func (c *Controller) HandleRequest(sender uint64, req []byte) {
 	iAm, leaderID := c.iAmTheLeader()
-	if !iAm {
+	if !iAm && !c.ForwardToNext {
 		c.Logger.Warnf("Got request from %d but the leader is %d, dropping request", sender, leaderID)
 		return
 	}
+	var submitted time.Time
+	if c.ForwardMaxAge > 0 {
+		if request, submittedAt, err := unwrapForwardedRequest(req); err != nil {
+			// Nodes that do not set ForwardedRequestMaxAge, such as during a rolling upgrade, forward requests as is
+			c.Logger.Debugf("Got request from %d without its submission time: %v", sender, err)
+		} else {
+			now := time.Now()
+			if ahead := submittedAt.Sub(now); ahead > forwardedRequestMaxClockSkew {
+				c.Logger.Warnf("Got request from %d which was submitted %v in the future, exceeding the allowed clock skew of %v, dropping request", sender, ahead, forwardedRequestMaxClockSkew)
+				return
+			}
+			if age := now.Sub(submittedAt); age > c.ForwardMaxAge {
+				c.Logger.Warnf("Got request from %d which was submitted %v ago, exceeding the maximal age of %v, dropping request", sender, age, c.ForwardMaxAge)
+				return
+			}
+			if submittedAt.After(now) {
+				submittedAt = now
+			}
+			req, submitted = request, submittedAt
+		}
+	}
 	reqInfo, err := c.Verifier.VerifyRequest(req)
 	if err != nil {
 		c.Logger.Warnf("Got bad request from %d: %v", sender, err)
 		return
 	}
-	c.Logger.Debugf("Got request from %d", sender)
-	c.addRequest(reqInfo, req)
+	if err := c.verifyClientSignature(req); err != nil {
+		c.Logger.Warnf("Got request %s from %d with a bad client signature: %v", reqInfo, sender, err)
+		return
+	}
+	if !c.reserveForwardQuota(sender, reqInfo) {
+		c.Logger.Warnf("Got request %s from %d which exceeds its quota of %d forwarded requests, dropping request", reqInfo, sender, c.ForwardQuota)
+		return
+	}
+	if !iAm {
+		// The sender could not reach the leader, and this node forwards the request to the leader once it times out
+		c.Logger.Debugf("Got request %s from %d while the leader is %d, relaying it to the leader", reqInfo, sender, leaderID)
+	} else {
+		c.Logger.Debugf("Got request from %d", sender)
+	}
+	if submitted.IsZero() {
+		c.addRequest(reqInfo, req)
+		return
+	}
+	// The request keeps the time it was first submitted, so that relaying it to the leader does not reset its age
+	if err := c.RequestPool.SubmitForwarded(req, submitted); err != nil {
+		c.Logger.Infof("Request %s was not submitted, error: %s", reqInfo, err)
+		return
+	}
+	c.Logger.Debugf("Request %s was submitted", reqInfo)
+}
+
+// reserveForwardQuota returns false if the pool already holds ForwardQuota requests forwarded by the given node,
+// and otherwise counts the given request towards the quota of that node
+func (c *Controller) reserveForwardQuota(sender uint64, info types.RequestInfo) bool {
+	if c.ForwardQuota == 0 {
+		return true
+	}
+	c.forwardedLock.Lock()
+	defer c.forwardedLock.Unlock()
+
+	if c.forwarded == nil {
+		c.forwarded = make(map[uint64]map[types.RequestInfo]struct{})
+	}
+	pending, exists := c.forwarded[sender]
+	if !exists {
+		pending = make(map[types.RequestInfo]struct{})
+		c.forwarded[sender] = pending
+	}
+	if uint64(len(pending)) >= c.ForwardQuota {
+		// Requests that left the pool no longer count towards the quota
+		for reqInfo := range pending {
+			if !c.RequestPool.Contains(reqInfo) {
+				delete(pending, reqInfo)
+			}
+		}
+	}
+	if uint64(len(pending)) >= c.ForwardQuota {
+		return false
+	}
+	pending[info] = struct{}{}
+	return true
 }
 
 // SubmitRequest Submits a request to go through consensus.
 func (c *Controller) SubmitRequest(request []byte) error {
 	info := c.RequestInspector.RequestID(request)
+	if err := c.verifyClientSignature(request); err != nil {
+		c.Logger.Infof("Request %s was not submitted, error: %s", info, err)
+		return err
+	}
 	return c.addRequest(info, request)
 }
 
+// SubmitBatch submits the given requests as a group, which is proposed in a single batch.
+func (c *Controller) SubmitBatch(requests [][]byte) error {
+	for _, request := range requests {
+		if err := c.verifyClientSignature(request); err != nil {
+			c.Logger.Infof("Group of %d requests was not submitted, error: %s", len(requests), err)
+			return err
+		}
+	}
+	if err := c.RequestPool.SubmitBatch(requests); err != nil {
+		c.Logger.Infof("Group of %d requests was not submitted, error: %s", len(requests), err)
+		return err
+	}
+
+	c.Logger.Debugf("Group of %d requests was submitted", len(requests))
+
+	return nil
+}
+
+// verifyClientSignature returns an error if the client signature of the given request is invalid,
+// provided that client signatures are verified
+func (c *Controller) verifyClientSignature(request []byte) error {
+	if c.ClientSigVerifier == nil {
+		return nil
+	}
+	if err := c.ClientSigVerifier.VerifyClientSignature(request); err != nil {
+		return errors.Wrapf(err, "invalid client signature on request %s", c.RequestInspector.RequestID(request))
+	}
+	return nil
+}
+
 func (c *Controller) addRequest(info types.RequestInfo, request []byte) error {
 	err := c.RequestPool.Submit(request)
 	if err != nil {
@@ -264,16 +545,60 @@ func (c *Controller) addRequest(info types.RequestInfo, request []byte) error {
 }
 
 // OnRequestTimeout is called when request-timeout expires and forwards the request to leader.
+// If this node is the leader, it prioritizes the request into its next batch if PrioritizeTimedOut is set.
 // Called by the request-pool timeout goroutine. Upon return, the leader-forward timeout is started.
 func (c *Controller) OnRequestTimeout(request []byte, info types.RequestInfo) {
 	iAm, leaderID := c.iAmTheLeader()
 	if iAm {
-		c.Logger.Infof("Request %s timeout expired, this node is the leader, nothing to do", info)
+		if !c.PrioritizeTimedOut {
+			c.Logger.Infof("Request %s timeout expired, this node is the leader, nothing to do", info)
+			return
+		}
+		// The leader did not make progress on its own request, so it makes sure the request is in its next batch
+		if c.RequestPool.Prioritize(info) {
+			c.Logger.Warnf("Request %s timeout expired, this node is the leader, prioritizing it into the next batch", info)
+		}
+		return
+	}
+
+	if c.pruneIfPreOrderRejects(request, info) {
 		return
 	}
 
 	c.Logger.Infof("Request %s timeout expired, forwarding request to leader: %d", info, leaderID)
+	if c.ForwardMaxAge > 0 {
+		submitted, exists := c.RequestPool.SubmissionTime(info)
+		if !exists {
+			c.Logger.Debugf("Request %s is no longer in the pool, not forwarding it", info)
+			return
+		}
+		request = wrapForwardedRequest(request, submitted)
+	}
 	c.Comm.SendTransaction(leaderID, request)
+	if !c.ForwardToNext {
+		return
+	}
+	// The forwarding cannot tell whether the leader is reachable, so the request is forwarded to the
+	// next leader candidate as well, which relays it to the leader before this node complains about the leader
+	if next := c.nextLeaderCandidate(leaderID); next != 0 {
+		c.Logger.Infof("Request %s timeout expired, forwarding request to the next leader candidate: %d", info, next)
+		c.Comm.SendTransaction(next, request)
+	}
+}
+
+// nextLeaderCandidate returns the leader of the closest next view that is neither this node nor the given leader,
+// or zero if there is no such node
+func (c *Controller) nextLeaderCandidate(leaderID uint64) uint64 {
+	view := c.getCurrentViewNumber()
+	decisions := c.getCurrentDecisionsInView()
+	blacklist := c.blacklist()
+	for i := uint64(1); i <= c.N; i++ {
+		candidate := getLeaderID(view+i, c.N, c.NodesList, c.LeaderRotation, decisions, c.DecisionsPerLeader, blacklist)
+		if candidate != c.ID && candidate != leaderID {
+			return candidate
+		}
+	}
+	return 0
 }
 
 // OnLeaderFwdRequestTimeout is called when the leader-forward timeout expires, and complains about the leader.
@@ -286,14 +611,38 @@ func (c *Controller) OnLeaderFwdRequestTimeout(request []byte, info types.Reques
 		return
 	}
 
+	if c.pruneIfPreOrderRejects(request, info) {
+		return
+	}
+
 	c.Logger.Warnf("Request %s leader-forwarding timeout expired, complaining about leader: %d", info, leaderID)
 	c.FailureDetector.Complain(c.getCurrentViewNumber(), true)
 }
 
+// pruneIfPreOrderRejects removes the request from the pool if the pre-order filter rejects it, and returns whether it did.
+// The leader removes the requests the filter rejects when it batches them, hence a follower checks its timed out
+// requests with the filter as well, rather than forwarding them to the leader, which ignores them, and complaining.
+func (c *Controller) pruneIfPreOrderRejects(request []byte, info types.RequestInfo) bool {
+	if c.PreOrder == nil || len(c.PreOrder.Order([][]byte{request})) > 0 {
+		return false
+	}
+	c.Logger.Infof("Request %s is rejected by the pre-order filter, removing it from the pool", info)
+	c.RequestPool.Prune(func(req []byte) error {
+		if bytes.Equal(req, request) {
+			return errors.New("rejected by the pre-order filter")
+		}
+		return nil
+	})
+	return true
+}
+
 // OnAutoRemoveTimeout is called when the auto-remove timeout expires.
 // Called by the request-pool timeout goroutine.
 func (c *Controller) OnAutoRemoveTimeout(requestInfo types.RequestInfo) {
 	c.Logger.Debugf("Request %s auto-remove timeout expired, removed from the request pool", requestInfo)
+	if c.RequestAbandoned != nil {
+		c.RequestAbandoned.OnRequestAbandoned(requestInfo)
+	}
 }
 
 // OnHeartbeatTimeout is called when the heartbeat timeout expires.
@@ -317,11 +666,59 @@ func (c *Controller) OnHeartbeatTimeout(view uint64, leaderID uint64) {
 	c.FailureDetector.Complain(c.getCurrentViewNumber(), true)
 }
 
+// SuspectLeader complains about the current leader on behalf of an external monitor, for the given reason.
+func (c *Controller) SuspectLeader(reason string) {
+	view := c.getCurrentViewNumber()
+	_, leaderID := c.iAmTheLeader()
+	c.Logger.Warnf("Leader %d of view %d is suspected, complaining about it; reason: %s", leaderID, view, reason)
+	if c.SuspicionObserver != nil {
+		c.SuspicionObserver.OnLeaderSuspected(view, leaderID, reason)
+	}
+	c.FailureDetector.Complain(view, true)
+}
+
 // ProcessMessages dispatches the incoming message to the required component
 func (c *Controller) ProcessMessages(sender uint64, m *protos.Message) {
+	if sender == c.ID {
+		if c.DetectDuplicateID && m.GetHeartBeat() != nil {
+			// The leader monitor tells apart heartbeats of another node that uses our ID
+			c.LeaderMonitor.ProcessMsg(sender, m)
+			return
+		}
+		// A message of our own that was looped back must not be counted as if it came from another node
+		c.Logger.Debugf("%d got message from itself, ignoring: %s", c.ID, MsgToString(m))
+		return
+	}
+	if c.FaultInjector != nil {
+		fault := c.FaultInjector.IncomingFault(sender, m)
+		if c.injectFault(fault, m, func(m *protos.Message) { c.processMessage(sender, m) }) {
+			c.Logger.Debugf("Injected %+v into %s from %d", fault, MsgToString(m), sender)
+			return
+		}
+	}
+	c.processMessage(sender, m)
+}
+
+func (c *Controller) processMessage(sender uint64, m *protos.Message) {
 	c.Logger.Debugf("%d got message from %d: %s", c.ID, sender, MsgToString(m))
+	if c.DetectQuorumLoss {
+		c.LeaderMonitor.PeerActive(sender)
+	}
+	if c.ProposeDigests && m.GetPrePrepare() != nil {
+		c.assemblePrePrepare(sender, m)
+		return
+	}
+	c.dispatchMessage(sender, m)
+}
+
+// dispatchMessage passes the given message to the component that handles it
+func (c *Controller) dispatchMessage(sender uint64, m *protos.Message) {
 	switch m.GetContent().(type) {
 	case *protos.Message_PrePrepare, *protos.Message_Prepare, *protos.Message_Commit:
+		if c.FutureMsgsLimit > 0 && viewNumber(m) > c.getCurrentViewNumber() && c.bufferFutureMessage(sender, m) {
+			c.Logger.Infof("Node %d got messages of views ahead of view %d from enough nodes, syncing", c.ID, c.getCurrentViewNumber())
+			c.Sync()
+		}
 		c.currViewLock.RLock()
 		view := c.currView
 		c.currViewLock.RUnlock()
@@ -343,6 +740,52 @@ func (c *Controller) ProcessMessages(sender uint64, m *protos.Message) {
 	}
 }
 
+// bufferFutureMessage retains the given message of a view ahead of the current view, up to FutureMsgsLimit messages,
+// and returns true if f+1 nodes sent such messages, in which case this node is behind and should sync.
+// It returns true only once until the next view starts.
+func (c *Controller) bufferFutureMessage(sender uint64, m *protos.Message) bool {
+	c.futureMsgsLock.Lock()
+	defer c.futureMsgsLock.Unlock()
+
+	c.futureMsgs = append(c.futureMsgs, &incMsg{sender: sender, Message: m})
+	if len(c.futureMsgs) > c.FutureMsgsLimit {
+		c.futureMsgs = c.futureMsgs[len(c.futureMsgs)-c.FutureMsgsLimit:]
+	}
+
+	if c.futureMsgsSynced {
+		return false
+	}
+	currView := c.getCurrentViewNumber()
+	senders := make(map[uint64]struct{})
+	for _, msg := range c.futureMsgs {
+		if viewNumber(msg.Message) > currView {
+			senders[msg.sender] = struct{}{}
+		}
+	}
+	_, f := computeQuorum(c.N)
+	c.futureMsgsSynced = len(senders) >= f+1
+	return c.futureMsgsSynced
+}
+
+// replayFutureMessages passes the retained messages of the given view to it,
+// and discards the retained messages of the given view and of older views.
+func (c *Controller) replayFutureMessages(viewNum uint64, view Proposer) {
+	c.futureMsgsLock.Lock()
+	defer c.futureMsgsLock.Unlock()
+
+	c.futureMsgsSynced = false
+	var future []*incMsg
+	for _, msg := range c.futureMsgs {
+		switch msgView := viewNumber(msg.Message); {
+		case msgView == viewNum:
+			view.HandleMessage(msg.sender, msg.Message)
+		case msgView > viewNum:
+			future = append(future, msg)
+		}
+	}
+	c.futureMsgs = future
+}
+
 func (c *Controller) respondToStateTransferRequest(sender uint64) {
 	vs := c.ViewSequences.Load()
 	if vs == nil {
@@ -356,7 +799,7 @@ func (c *Controller) respondToStateTransferRequest(sender uint64) {
 			},
 		},
 	}
-	c.Comm.SendConsensus(sender, msg)
+	c.send(sender, msg)
 }
 
 func (c *Controller) convertViewMessageToHeartbeat(m *protos.Message) *protos.Message {
@@ -375,11 +818,19 @@ func (c *Controller) convertViewMessageToHeartbeat(m *protos.Message) *protos.Me
 func (c *Controller) startView(proposalSequence uint64) {
 	view, initPhase := c.ProposerBuilder.NewProposer(c.leaderID(), proposalSequence, c.currViewNumber, c.currDecisionsInView, c.quorum)
 
+	c.assembleFailures = 0
+
 	c.currViewLock.Lock()
 	c.currView = view
+	c.viewAborted = make(chan struct{})
+	c.viewStarts++
 	c.currView.Start()
 	c.currViewLock.Unlock()
 
+	if c.FutureMsgsLimit > 0 {
+		c.replayFutureMessages(c.currViewNumber, view)
+	}
+
 	role := Follower
 	leader, _ := c.iAmTheLeader()
 	if leader {
@@ -392,9 +843,25 @@ func (c *Controller) startView(proposalSequence uint64) {
 		role = Leader
 	}
 	c.LeaderMonitor.ChangeRole(role, c.currViewNumber, c.leaderID())
+	c.notifyLeadership(leader, c.currViewNumber)
+	if c.LeaderHistory != nil {
+		c.LeaderHistory.Record(c.currViewNumber, c.leaderID())
+	}
 	c.Logger.Infof("Starting view with number %d, sequence %d, and decisions %d", c.currViewNumber, proposalSequence, c.currDecisionsInView)
 }
 
+// notifyLeadership notifies the LeadershipObserver if this node became or stopped being the leader
+func (c *Controller) notifyLeadership(leader bool, view uint64) {
+	if c.leadershipNotified && c.leading == leader {
+		return
+	}
+	c.leadershipNotified = true
+	c.leading = leader
+	if c.LeadershipObserver != nil {
+		c.LeadershipObserver.OnLeadershipChange(leader, view)
+	}
+}
+
 func (c *Controller) changeView(newViewNumber uint64, newProposalSequence uint64, newDecisionsInView uint64) {
 	latestView := c.getCurrentViewNumber()
 	if latestView > newViewNumber {
@@ -414,9 +881,12 @@ func (c *Controller) changeView(newViewNumber uint64, newProposalSequence uint64
 	if !c.abortView(latestView) {
 		return
 	}
+	c.disarmLeaderIdleTimer()
 
 	c.setCurrentViewNumber(newViewNumber)
 	c.setCurrentDecisionsInView(newDecisionsInView)
+	// The new view must not start before the decisions of the previous views are delivered
+	c.waitForDeliveries()
 	c.Logger.Debugf("Starting view after setting decisions in view to %d", newDecisionsInView)
 	c.startView(newProposalSequence)
 
@@ -440,6 +910,15 @@ func (c *Controller) abortView(view uint64) bool {
 
 	// Kill current view
 	c.Logger.Debugf("Aborting current view with number %d", c.currViewNumber)
+	c.currViewLock.Lock()
+	if c.viewAborted != nil {
+		select {
+		case <-c.viewAborted:
+		default:
+			close(c.viewAborted)
+		}
+	}
+	c.currViewLock.Unlock()
 	c.currView.Abort()
 
 	return true
@@ -476,14 +955,205 @@ func (c *Controller) propose() {
 	if c.stopped() || c.Batcher.Closed() {
 		return
 	}
+	if c.quorumUnreachable.Load() {
+		c.Logger.Infof("Not proposing since a quorum of nodes is unreachable")
+		c.proposingPaused.Store(true)
+		return
+	}
+	if delay := time.Until(c.nextProposalTime); delay > 0 {
+		c.Logger.Debugf("Delaying the next proposal by %v", delay)
+		c.acquireLeaderTokenAfter(delay)
+		return
+	}
 	nextBatch := c.Batcher.NextBatch()
 	if len(nextBatch) == 0 { // no requests in this batch
 		c.acquireLeaderToken() // try again later
 		return
 	}
 	metadata := c.currView.GetMetadata()
-	proposal := c.Assembler.AssembleProposal(metadata, nextBatch)
+	proposal, err := c.assembleProposalWithinLimit(metadata, nextBatch)
+	if retry, isRetry := err.(*assembleRetry); isRetry {
+		// Wait for the retry outside the run loop, so that decisions and view changes are not held back
+		c.acquireLeaderTokenAfter(retry.backoff)
+		return
+	}
+	if err != nil {
+		if c.stopped() {
+			return
+		}
+		c.Logger.Errorf("Failed assembling a proposal of %d requests, relinquishing leadership: %v", len(nextBatch), err)
+		c.relinquishLeaderToken()
+		c.FailureDetector.Complain(c.getCurrentViewNumber(), true)
+		return
+	}
 	c.currView.Propose(proposal)
+	c.nextProposalTime = time.Now().Add(c.ProposalInterval)
+	c.armLeaderIdleTimer()
+}
+
+// armLeaderIdleTimer makes the leader give up leading if nothing is committed within MaxLeaderIdle,
+// unless it is already waiting for an earlier proposal to be committed.
+func (c *Controller) armLeaderIdleTimer() {
+	if c.MaxLeaderIdle == 0 || c.leaderIdleTimer != nil {
+		return
+	}
+	view, seq := c.getCurrentViewNumber(), c.latestSeq()
+	c.leaderIdleTimer = time.AfterFunc(c.MaxLeaderIdle, func() {
+		if c.stopped() || c.getCurrentViewNumber() != view || c.latestSeq() != seq {
+			return
+		}
+		c.Logger.Warnf("Nothing was committed in view %d for %v since sequence %d, relinquishing leadership",
+			view, c.MaxLeaderIdle, seq)
+		c.relinquishLeaderToken()
+		c.FailureDetector.Complain(view, true)
+	})
+}
+
+// watchViewProgress forces a view change once the view decided nothing for MaxViewIdle while requests are pending,
+// which happens when the leader keeps the view alive but never proposes. Without pending requests the view
+// is idle rather than stuck, so the time is counted only while there are some.
+func (c *Controller) watchViewProgress() {
+	interval := c.MaxViewIdle / viewIdleChecks
+	if interval < minViewIdleCheckInterval {
+		interval = minViewIdleCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	view, seq, since := c.getCurrentViewNumber(), c.latestSeq(), time.Now()
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case now := <-ticker.C:
+			currView, currSeq := c.getCurrentViewNumber(), c.latestSeq()
+			if currView != view || currSeq != seq || c.RequestPool.Size() == 0 {
+				view, seq, since = currView, currSeq, now
+				continue
+			}
+			if now.Sub(since) < c.MaxViewIdle {
+				continue
+			}
+			c.Logger.Warnf("View %d decided nothing for %v since sequence %d although requests are pending, forcing a view change",
+				view, c.MaxViewIdle, seq)
+			if iAm, _ := c.iAmTheLeader(); iAm {
+				c.relinquishLeaderToken()
+			}
+			c.FailureDetector.Complain(view, true)
+			since = now
+		}
+	}
+}
+
+// disarmLeaderIdleTimer stops waiting for the proposals of this leader to be committed
+func (c *Controller) disarmLeaderIdleTimer() {
+	if c.leaderIdleTimer == nil {
+		return
+	}
+	c.leaderIdleTimer.Stop()
+	c.leaderIdleTimer = nil
+}
+
+// assembleProposalWithinLimit assembles a proposal, and as long as it exceeds MaxProposalBytes,
+// assembles it again out of the first half of the requests. The rest remain in the pool for the next proposals.
+func (c *Controller) assembleProposalWithinLimit(metadata []byte, nextBatch [][]byte) (types.Proposal, error) {
+	for {
+		proposal, err := c.assembleProposal(metadata, nextBatch)
+		if err != nil {
+			return types.Proposal{}, err
+		}
+		size := proposalSize(proposal)
+		if c.MaxProposalBytes == 0 || size <= c.MaxProposalBytes {
+			return proposal, nil
+		}
+		// The candidates of a CandidateAssembler are not taken from the batch, so it cannot be split
+		if len(nextBatch) <= 1 || c.CandidateAssembler != nil {
+			return types.Proposal{}, errors.Errorf("proposal of %d requests is %d bytes, which exceeds the maximum of %d bytes",
+				len(nextBatch), size, c.MaxProposalBytes)
+		}
+		c.Logger.Warnf("Proposal of %d requests is %d bytes, which exceeds the maximum of %d bytes, splitting it",
+			len(nextBatch), size, c.MaxProposalBytes)
+		nextBatch = nextBatch[:len(nextBatch)/2]
+	}
+}
+
+// assembleProposal assembles a proposal out of the next batch, or when a CandidateAssembler is used,
+// out of the candidate requests taken from the request pool.
+func (c *Controller) assembleProposal(metadata []byte, nextBatch [][]byte) (types.Proposal, error) {
+	if c.CandidateAssembler == nil {
+		if c.FallibleAssembler != nil {
+			return c.tryAssembleProposal(metadata, nextBatch)
+		}
+		return c.Assembler.AssembleProposal(metadata, nextBatch), nil
+	}
+
+	candidates := nextBatch
+	if c.CandidatesMaxCount > uint64(len(nextBatch)) {
+		if requests, _ := c.RequestPool.NextRequests(int(c.CandidatesMaxCount), math.MaxUint64, false); len(requests) > len(nextBatch) {
+			candidates = requests
+		}
+	}
+
+	proposal, remainder := c.CandidateAssembler.AssembleProposalFromCandidates(metadata, candidates)
+	c.Logger.Debugf("Assembled a proposal out of %d candidate requests, %d of them remain in the pool", len(candidates), len(remainder))
+	c.reconcileCandidates(candidates, proposal, remainder)
+	return proposal, nil
+}
+
+// reconcileCandidates makes sure that candidate requests the CandidateAssembler neither included in the proposal
+// nor returned in the remainder are not lost, by returning them to the pool if they are no longer in it.
+func (c *Controller) reconcileCandidates(candidates [][]byte, proposal types.Proposal, remainder [][]byte) {
+	accounted := make(map[types.RequestInfo]struct{}, len(candidates))
+	for _, info := range c.Verifier.RequestsFromProposal(proposal) {
+		accounted[info] = struct{}{}
+	}
+	for _, req := range remainder {
+		accounted[c.RequestInspector.RequestID(req)] = struct{}{}
+	}
+	for _, req := range candidates {
+		info := c.RequestInspector.RequestID(req)
+		if _, exists := accounted[info]; exists {
+			continue
+		}
+		c.Logger.Warnf("Request %s was dropped by the assembler, it is neither in the proposal nor in the remainder", info)
+		if c.RequestPool.Contains(info) {
+			continue
+		}
+		if err := c.RequestPool.Submit(req); err != nil {
+			c.Logger.Warnf("Failed returning request %s to the pool: %v", info, err)
+		}
+	}
+}
+
+// assembleRetry is returned by tryAssembleProposal when an attempt to assemble a proposal failed,
+// and the proposal should be assembled again once the backoff elapses.
+type assembleRetry struct {
+	error
+	backoff time.Duration
+}
+
+// tryAssembleProposal makes an attempt to assemble a proposal using the FallibleAssembler. As long as the attempts fail,
+// it returns an assembleRetry with an exponential backoff capped by AssembleMaxBackoff, up to AssembleAttempts attempts.
+func (c *Controller) tryAssembleProposal(metadata []byte, requests [][]byte) (types.Proposal, error) {
+	proposal, err := c.FallibleAssembler.TryAssembleProposal(metadata, requests)
+	if err == nil {
+		c.assembleFailures = 0
+		return proposal, nil
+	}
+	c.assembleFailures++
+	attempt := c.assembleFailures
+	if attempt >= c.AssembleAttempts {
+		c.assembleFailures = 0
+		return types.Proposal{}, errors.Wrapf(err, "failed %d attempts", attempt)
+	}
+	backoff := c.AssembleBackoff
+	for i := uint64(1); i < attempt && backoff < c.AssembleMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.AssembleMaxBackoff && c.AssembleMaxBackoff >= c.AssembleBackoff {
+		backoff = c.AssembleMaxBackoff
+	}
+	c.Logger.Warnf("Attempt %d to assemble a proposal failed, retrying in %v: %v", attempt, backoff, err)
+	return types.Proposal{}, &assembleRetry{error: errors.Wrapf(err, "retrying in %v", backoff), backoff: backoff}
 }
 
 func (c *Controller) run() {
@@ -498,6 +1168,8 @@ func (c *Controller) run() {
 		select {
 		case d := <-c.decisionChan:
 			c.decide(d)
+		case d := <-c.deliveredChan:
+			c.delivered(d)
 		case newView := <-c.viewChange:
 			c.Logger.Debugf("get newView from viewChange")
 			c.changeView(newView.viewNumber, newView.proposalSeq, 0)
@@ -506,7 +1178,10 @@ func (c *Controller) run() {
 		case <-c.stopChan:
 			return
 		case <-c.leaderToken:
+			c.proposing.Store(true)
 			c.propose()
+			c.proposing.Store(false)
+			c.logLeaderTokenTransition()
 		case <-c.syncChan:
 			c.Logger.Debugf("get msg from syncChan")
 			view, seq, dec := c.sync()
@@ -527,17 +1202,108 @@ func (c *Controller) run() {
 
 func (c *Controller) decide(d decision) {
 	c.Logger.Debugf("Delivering to app from Controller decide the last decision proposal")
+	c.disarmLeaderIdleTimer()
+	delivered := c.deliverDecision(d)
+	select {
+	case c.deliverChan <- struct{}{}:
+	case <-c.stopChan:
+		return
+	}
+	if !delivered {
+		c.Sync()
+		return
+	}
+	c.afterDelivery(d)
+}
+
+// deliverDecision delivers the decision to the application and removes its requests from the pool.
+// It returns false if the decision was buffered rather than delivered, as it is ahead of the decisions delivered
+// so far, in which case it is not counted as a decision of the view, and the node should sync.
+func (c *Controller) deliverDecision(d decision) bool {
 	reconfig := c.Deliver.Deliver(d.proposal, d.signatures)
 	if reconfig.InLatestDecision {
 		c.close()
 	}
+	md := &protos.ViewMetadata{}
+	if err := proto.Unmarshal(d.proposal.Metadata, md); err != nil {
+		c.Logger.Panicf("Failed to unmarshal proposal metadata, error: %v", err)
+	}
+	if c.latestSeq() < md.LatestSequence {
+		c.Logger.Warnf("Node %d buffered the proposal of sequence %d, as the latest delivered sequence is %d",
+			c.ID, md.LatestSequence, c.latestSeq())
+		c.removeDeliveredFromPool(d)
+		return false
+	}
 	c.Logger.Debugf("Node %d delivered proposal", c.ID)
+	if c.DryRun != nil && c.DryRun.Verified() {
+		c.Logger.Infof("Node %d verified %d consecutive decisions and is now voting", c.ID, c.DryRun.Decisions)
+	}
 	c.removeDeliveredFromPool(d)
+	// The verification sequence might have changed by the delivery, and the view must not sign on
+	// the next proposal before the signing key is rotated, hence check it before the view is released.
+	c.MaybePruneRevokedRequests()
+	return true
+}
+
+// deliver is run by the delivery worker, which delivers the decisions in the order they were decided,
+// so that the run loop keeps handling view changes while the application is slow to deliver
+func (c *Controller) deliver() {
+	for {
+		select {
+		case d := <-c.deliveryQueue:
+			if d.barrier != nil {
+				close(d.barrier)
+				continue
+			}
+			delivered := c.deliverDecision(d)
+			close(d.delivered)
+			if !delivered {
+				c.Sync()
+				continue
+			}
+			select {
+			case c.deliveredChan <- d:
+			case <-c.stopChan:
+				return
+			}
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// delivered completes a decision delivered by the delivery worker,
+// unless the view that decided it was aborted in the meantime
+func (c *Controller) delivered(d decision) {
+	c.disarmLeaderIdleTimer()
+	c.currViewLock.RLock()
+	viewStarts := c.viewStarts
+	c.currViewLock.RUnlock()
+	if d.viewStart != viewStarts {
+		c.Logger.Debugf("Node %d delivered proposal of a view that is no longer running", c.ID)
+		return
+	}
+	c.afterDelivery(d)
+}
+
+// waitForDeliveries waits for the delivery worker to deliver the decisions handed to it so far
+func (c *Controller) waitForDeliveries() {
+	if !c.AsyncDelivery {
+		return
+	}
+	barrier := make(chan struct{})
 	select {
-	case c.deliverChan <- struct{}{}:
+	case c.deliveryQueue <- decision{barrier: barrier}:
 	case <-c.stopChan:
 		return
 	}
+	select {
+	case <-barrier:
+	case <-c.stopChan:
+	}
+}
+
+func (c *Controller) afterDelivery(d decision) {
 	c.incrementCurrentDecisionsInView()
 
 	md := &protos.ViewMetadata{}
@@ -551,7 +1317,6 @@ func (c *Controller) decide(d decision) {
 		c.Logger.Debugf("Restarting timers in request pool due to leader rotation")
 		c.RequestPool.RestartTimers()
 	}
-	c.MaybePruneRevokedRequests()
 	if iAm, _ := c.iAmTheLeader(); iAm {
 		c.acquireLeaderToken()
 	}
@@ -584,7 +1349,7 @@ func (c *Controller) sync() (viewNum uint64, seq uint64, decisions uint64) {
 	c.syncLock.Lock()
 	defer c.syncLock.Unlock()
 
-	syncResponse := c.Synchronizer.Sync()
+	syncResponse := c.synchronize()
 	if syncResponse.Reconfig.InReplicatedDecisions {
 		c.close()
 		c.ViewChanger.close()
@@ -601,6 +1366,12 @@ func (c *Controller) sync() (viewNum uint64, seq uint64, decisions uint64) {
 	var newViewNum, newProposalSequence, newDecisionsInView uint64
 
 	latestDecision := syncResponse.Latest
+	if c.VerifySynced {
+		if err := c.validateSyncedDecision(syncResponse); err != nil {
+			c.Logger.Warnf("Ignoring the decision returned by the synchronizer: %v", err)
+			latestDecision = types.Decision{}
+		}
+	}
 	var latestDecisionSeq, latestDecisionViewNum, latestDecisionDecisions uint64
 	var latestDecisionMetadata *protos.ViewMetadata
 	if len(latestDecision.Proposal.Metadata) == 0 {
@@ -628,8 +1399,16 @@ func (c *Controller) sync() (viewNum uint64, seq uint64, decisions uint64) {
 		c.Logger.Debugf("Node %d is setting the checkpoint after sync returned with view %d and seq %d", c.ID, latestDecisionViewNum, latestDecisionSeq)
 		c.Checkpoint.Set(latestDecision.Proposal, latestDecision.Signatures)
 		c.verificationSequence.Store(uint64(latestDecision.Proposal.VerificationSequence))
+		if c.DryRun != nil {
+			// The synced decisions were not verified by this node
+			c.DryRun.Reset()
+		}
 		newProposalSequence = latestDecisionSeq + 1
 		newDecisionsInView = latestDecisionDecisions + 1
+		// Let the node settle after it caught up before it resumes proposing
+		if settled := time.Now().Add(c.CatchUpDelay); settled.After(c.nextProposalTime) {
+			c.nextProposalTime = settled
+		}
 	}
 
 	if latestDecisionViewNum > controllerViewNum {
@@ -679,6 +1458,49 @@ func (c *Controller) sync() (viewNum uint64, seq uint64, decisions uint64) {
 	return newViewNum, newProposalSequence, newDecisionsInView
 }
 
+// validateSyncedDecision checks that the latest decision returned by the synchronizer is signed by a quorum of the nodes.
+// If the synchronizer replicated a reconfiguration, the decision may be signed by a quorum of the new nodes instead.
+func (c *Controller) validateSyncedDecision(syncResponse types.SyncResponse) error {
+	err := ValidateDecision(syncResponse.Latest.Proposal, syncResponse.Latest.Signatures, c.NodesList, c.Verifier)
+	if err != nil && syncResponse.Reconfig.InReplicatedDecisions {
+		err = ValidateDecision(syncResponse.Latest.Proposal, syncResponse.Latest.Signatures, syncResponse.Reconfig.CurrentNodes, c.Verifier)
+	}
+	return err
+}
+
+// synchronize calls the synchronizer, with a context that is cancelled by CancelSync if it is a CancellableSync
+func (c *Controller) synchronize() types.SyncResponse {
+	if c.CancellableSync == nil {
+		return c.Synchronizer.Sync()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.syncCancelLock.Lock()
+	c.syncCancel = cancel
+	c.syncCancelLock.Unlock()
+
+	defer func() {
+		c.syncCancelLock.Lock()
+		c.syncCancel = nil
+		c.syncCancelLock.Unlock()
+		cancel()
+	}()
+
+	return c.CancellableSync.SyncWithContext(ctx)
+}
+
+// CancelSync cancels the sync in progress, if there is one and the synchronizer supports it,
+// as this node learned that it is not behind after all.
+func (c *Controller) CancelSync() {
+	c.syncCancelLock.Lock()
+	defer c.syncCancelLock.Unlock()
+	if c.syncCancel == nil {
+		return
+	}
+	c.Logger.Infof("Node %d is cancelling the sync in progress since it is not behind", c.ID)
+	c.syncCancel()
+}
+
 func (c *Controller) maybePruneInFlight(syncResultViewMD *protos.ViewMetadata) {
 	inFlight := c.InFlight.InFlightProposal()
 	if inFlight == nil {
@@ -729,16 +1551,24 @@ func (c *Controller) relinquishSyncToken() {
 	}
 }
 
-// MaybePruneRevokedRequests prunes requests with different verification sequence
+// MaybePruneRevokedRequests prunes requests with different verification sequence,
+// and rotates the signing key if a KeyRotator is set
 func (c *Controller) MaybePruneRevokedRequests() {
 	oldVerSqn := c.verificationSequence.Load()
 	newVerSqn := c.Verifier.VerificationSequence()
 	if newVerSqn == oldVerSqn {
 		return
 	}
-	c.verificationSequence.Store(newVerSqn)
+	// It may be called concurrently by the delivery worker and by the run loop,
+	// so only the caller that records the change rotates the key and prunes the pool.
+	if !c.verificationSequence.CompareAndSwap(oldVerSqn, newVerSqn) {
+		return
+	}
 
 	c.Logger.Infof("Verification sequence changed: %d --> %d", oldVerSqn, newVerSqn)
+	if c.KeyRotator != nil {
+		c.KeyRotator.RotateKey(oldVerSqn, newVerSqn)
+	}
 	c.RequestPool.Prune(func(req []byte) error {
 		_, err := c.Verifier.VerifyRequest(req)
 		return err
@@ -751,6 +1581,16 @@ func (c *Controller) acquireLeaderToken() {
 	default:
 		// No room, seems we're already a leader.
 	}
+	c.logLeaderTokenTransition()
+}
+
+// acquireLeaderTokenAfter acquires the leader token once the given delay elapses, if this node is still the leader
+func (c *Controller) acquireLeaderTokenAfter(delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		if iAm, _ := c.iAmTheLeader(); iAm && !c.stopped() {
+			c.acquireLeaderToken()
+		}
+	})
 }
 
 func (c *Controller) relinquishLeaderToken() {
@@ -758,6 +1598,30 @@ func (c *Controller) relinquishLeaderToken() {
 	case <-c.leaderToken:
 	default:
 	}
+	c.logLeaderTokenTransition()
+}
+
+// HoldsLeaderToken returns whether this node holds the leader token, which entitles it to propose.
+// The token is held until the leader proposes, and is acquired again once the proposal is decided.
+func (c *Controller) HoldsLeaderToken() bool {
+	return len(c.leaderToken) > 0 || c.proposing.Load()
+}
+
+// logLeaderTokenTransition logs whether this node acquired or released the leader token since the last time
+// it was called, if LogLeaderToken is set
+func (c *Controller) logLeaderTokenTransition() {
+	if !c.LogLeaderToken {
+		return
+	}
+	holds := c.HoldsLeaderToken()
+	if c.leaderTokenHeld.Swap(holds) == holds {
+		return
+	}
+	if holds {
+		c.Logger.Infof("Node %d acquired the leader token in view %d", c.ID, c.getCurrentViewNumber())
+	} else {
+		c.Logger.Infof("Node %d released the leader token in view %d", c.ID, c.getCurrentViewNumber())
+	}
 }
 
 func (c *Controller) syncOnStart(startViewNumber uint64, startProposalSequence uint64, startDecisionsInView uint64) (viewNum uint64, seq uint64, decisions uint64) {
@@ -787,6 +1651,10 @@ func (c *Controller) Start(startViewNumber uint64, startProposalSequence uint64,
 	c.leaderToken = make(chan struct{}, 1)
 	c.decisionChan = make(chan decision)
 	c.deliverChan = make(chan struct{})
+	if c.AsyncDelivery {
+		c.deliveryQueue = make(chan decision, 1)
+		c.deliveredChan = make(chan decision, 2)
+	}
 	c.viewChange = make(chan viewInfo, 1)
 	c.abortViewChan = make(chan uint64, 1)
 
@@ -795,6 +1663,7 @@ func (c *Controller) Start(startViewNumber uint64, startProposalSequence uint64,
 	c.quorum = Q
 
 	c.verificationSequence.Store(c.Verifier.VerificationSequence())
+	c.nextProposalTime = time.Now().Add(c.FirstProposalDelay)
 
 	if syncOnStart {
 		startViewNumber, startProposalSequence, startDecisionsInView = c.syncOnStart(startViewNumber, startProposalSequence, startDecisionsInView)
@@ -810,6 +1679,22 @@ func (c *Controller) Start(startViewNumber uint64, startProposalSequence uint64,
 		c.run()
 	}()
 
+	if c.AsyncDelivery {
+		c.controllerDone.Add(1)
+		go func() {
+			defer c.controllerDone.Done()
+			c.deliver()
+		}()
+	}
+
+	if c.MaxViewIdle > 0 {
+		c.controllerDone.Add(1)
+		go func() {
+			defer c.controllerDone.Done()
+			c.watchViewProgress()
+		}()
+	}
+
 	c.StartedWG.Done()
 }
 
@@ -871,12 +1756,18 @@ func (c *Controller) stopped() bool {
 
 // Decide delivers the decision to the application
 func (c *Controller) Decide(proposal types.Proposal, signatures []types.Signature, requests []types.RequestInfo) {
-	select {
-	case c.decisionChan <- decision{
+	d := decision{
 		proposal:   proposal,
 		requests:   requests,
-		signatures: signatures,
-	}:
+		signatures: sortedSignatures(signatures),
+	}
+	if c.AsyncDelivery {
+		c.decideAsync(d)
+		return
+	}
+
+	select {
+	case c.decisionChan <- d:
 	case <-c.stopChan:
 		// In case we are in the middle of shutting down,
 		// abort deciding.
@@ -889,6 +1780,29 @@ func (c *Controller) Decide(proposal types.Proposal, signatures []types.Signatur
 	}
 }
 
+// decideAsync hands the decision to the delivery worker and waits for its delivery,
+// unless the view that decided it is aborted in the meantime
+func (c *Controller) decideAsync(d decision) {
+	c.currViewLock.RLock()
+	aborted := c.viewAborted
+	d.viewStart = c.viewStarts
+	c.currViewLock.RUnlock()
+	d.delivered = make(chan struct{})
+
+	select {
+	case c.deliveryQueue <- d:
+	case <-c.stopChan:
+		return
+	}
+
+	select {
+	case <-d.delivered:
+	case <-aborted:
+		c.Logger.Debugf("Node %d aborted the view while its decision is being delivered", c.ID)
+	case <-c.stopChan:
+	}
+}
+
 func (c *Controller) removeDeliveredFromPool(d decision) {
 	for _, reqInfo := range d.requests {
 		if err := c.RequestPool.RemoveRequest(reqInfo); err != nil {
@@ -897,6 +1811,13 @@ func (c *Controller) removeDeliveredFromPool(d decision) {
 	}
 }
 
+// outOfSequenceDecision is a decision buffered until the decisions of the sequences before it are delivered
+type outOfSequenceDecision struct {
+	proposal   types.Proposal
+	signatures []types.Signature
+	commitView uint64
+}
+
 type viewInfo struct {
 	viewNumber  uint64
 	proposalSeq uint64
@@ -906,22 +1827,370 @@ type decision struct {
 	proposal   types.Proposal
 	signatures []types.Signature
 	requests   []types.RequestInfo
+	viewStart  uint64        // the start of the view that decided it, when delivered asynchronously
+	delivered  chan struct{} // closed once it is delivered asynchronously
+	barrier    chan struct{} // if set, this is not a decision, and it is closed once the preceding decisions are delivered
+}
+
+// Voting returns whether this node votes, that is, whether it is not in a join dry run
+func (c *Controller) Voting() bool {
+	return c.DryRun == nil || c.DryRun.Voting()
+}
+
+// SendConsensus sends the message to the given node, unless it is a vote and this node does not vote yet
+func (c *Controller) SendConsensus(targetID uint64, m *protos.Message) {
+	if isVote(m) && !c.Voting() {
+		return
+	}
+	if c.BroadcastWorkers > 1 || c.SendTimeout > 0 {
+		// Queue the message behind the messages broadcast to the node, so that they are sent in order
+		c.peerSender(targetID).enqueue(m)
+		return
+	}
+	c.send(targetID, m)
 }
 
 // BroadcastConsensus broadcasts the message and informs the heartbeat monitor if necessary
 func (c *Controller) BroadcastConsensus(m *protos.Message) {
+	if isVote(m) && !c.Voting() {
+		c.Logger.Debugf("Node %d is in a join dry run and does not broadcast %s", c.ID, MsgToString(m))
+		return
+	}
+	if c.ProposeDigests && m.GetPrePrepare() != nil {
+		m = c.compactPrePrepare(m)
+	}
+	if c.BroadcastWorkers > 1 || c.SendTimeout > 0 {
+		c.broadcastConcurrently(m)
+	} else {
+		for _, node := range c.NodesList {
+			// Do not send to yourself
+			if c.ID == node {
+				continue
+			}
+			c.send(node, m)
+		}
+	}
+
+	if m.GetPrePrepare() != nil || m.GetPrepare() != nil || m.GetCommit() != nil {
+		if leader, _ := c.iAmTheLeader(); leader {
+			c.LeaderMonitor.HeartbeatWasSent()
+		}
+	}
+}
+
+// broadcastConcurrently queues the message to the sender of each node, without waiting for it to be sent
+func (c *Controller) broadcastConcurrently(m *protos.Message) {
 	for _, node := range c.NodesList {
 		// Do not send to yourself
 		if c.ID == node {
 			continue
 		}
-		c.Comm.SendConsensus(node, m)
+		c.peerSender(node).enqueue(m)
 	}
+}
 
-	if m.GetPrePrepare() != nil || m.GetPrepare() != nil || m.GetCommit() != nil {
-		if leader, _ := c.iAmTheLeader(); leader {
-			c.LeaderMonitor.HeartbeatWasSent()
+// peerSender returns the sender of the given node, which is created on first use, and anew after the controller
+// is restarted, as the sender of the previous run exits once the controller is stopped
+func (c *Controller) peerSender(node uint64) *peerSender {
+	c.peerSendersLock.Lock()
+	defer c.peerSendersLock.Unlock()
+	if sender, exists := c.peerSenders[node]; exists && sender.stopChan == c.stopChan {
+		return sender
+	}
+	if c.sendSlots == nil {
+		workers := c.BroadcastWorkers
+		if workers == 0 {
+			workers = 1
+		}
+		c.sendSlots = make(chan struct{}, workers)
+	}
+	if c.peerSenders == nil {
+		c.peerSenders = make(map[uint64]*peerSender)
+	}
+	sender := &peerSender{
+		c:        c,
+		node:     node,
+		queue:    make(chan *protos.Message, peerSendQueueSize),
+		stopChan: c.stopChan,
+	}
+	c.peerSenders[node] = sender
+	go sender.run()
+	return sender
+}
+
+// setSendFailed marks whether sending to the given node failed, and returns whether it was marked before
+func (c *Controller) setSendFailed(node uint64, failed bool) bool {
+	c.sendFailuresLock.Lock()
+	defer c.sendFailuresLock.Unlock()
+	_, wasFailed := c.sendFailures[node]
+	if failed {
+		if c.sendFailures == nil {
+			c.sendFailures = make(map[uint64]struct{})
+		}
+		c.sendFailures[node] = struct{}{}
+	} else {
+		delete(c.sendFailures, node)
+	}
+	return wasFailed
+}
+
+// peerSendQueueSize is the number of messages queued to be sent to a node, beyond which messages to it are dropped
+const peerSendQueueSize = 100
+
+// peerSender sends the messages broadcast to a node one after the other, in the order they were broadcast,
+// so that a slow node neither delays the broadcast nor the messages sent to the rest of the nodes.
+// At most BroadcastWorkers senders send at the same time, and a sender that does not complete a send within
+// SendTimeout gives up its turn and the node is considered unreachable until the send completes.
+// Messages that do not fit in the queue of the sender are dropped.
+type peerSender struct {
+	c        *Controller
+	node     uint64
+	queue    chan *protos.Message
+	stopChan chan struct{}
+}
+
+// enqueue queues the message to be sent, or drops it if the queue is full
+func (s *peerSender) enqueue(m *protos.Message) {
+	select {
+	case s.queue <- m:
+	default:
+		if !s.c.setSendFailed(s.node, true) {
+			s.c.Logger.Warnf("Dropped %s to %d as %d messages to it are pending", MsgToString(m), s.node, peerSendQueueSize)
+			return
+		}
+		s.c.Logger.Debugf("Dropped %s to %d as %d messages to it are pending", MsgToString(m), s.node, peerSendQueueSize)
+	}
+}
+
+func (s *peerSender) run() {
+	for {
+		var m *protos.Message
+		select {
+		case m = <-s.queue:
+		case <-s.stopChan:
+			return
+		}
+		select {
+		case s.c.sendSlots <- struct{}{}:
+		case <-s.stopChan:
+			return
+		}
+		s.send(m)
+	}
+}
+
+// send sends the message and then frees its turn, or once SendTimeout expires if the send takes longer
+func (s *peerSender) send(m *protos.Message) {
+	var release sync.Once
+	freeTurn := func() { <-s.c.sendSlots }
+	if s.c.SendTimeout > 0 {
+		var lock sync.Mutex
+		completed := false
+		timer := time.AfterFunc(s.c.SendTimeout, func() {
+			lock.Lock()
+			defer lock.Unlock()
+			if completed {
+				return
+			}
+			release.Do(freeTurn)
+			if !s.c.setSendFailed(s.node, true) {
+				s.c.Logger.Warnf("Sending %s to %d timed out after %v", MsgToString(m), s.node, s.c.SendTimeout)
+			}
+		})
+		defer func() {
+			lock.Lock()
+			completed = true
+			lock.Unlock()
+			timer.Stop()
+			release.Do(freeTurn)
+			s.c.setSendFailed(s.node, false)
+		}()
+	} else {
+		defer release.Do(freeTurn)
+	}
+	s.c.send(s.node, m)
+}
+
+// compactPrePrepare returns the given pre-prepare with the payload of its proposal replaced by the digests of its requests
+func (c *Controller) compactPrePrepare(m *protos.Message) *protos.Message {
+	pp := m.GetPrePrepare()
+	if pp.Proposal == nil {
+		return m
+	}
+	requests := c.PayloadCompactor.PayloadRequests(pp.Proposal.Payload)
+	infos := make([]types.RequestInfo, 0, len(requests))
+	for _, req := range requests {
+		infos = append(infos, c.RequestInspector.RequestID(req))
+	}
+	return &protos.Message{
+		Content: &protos.Message_PrePrepare{
+			PrePrepare: &protos.PrePrepare{
+				View: pp.View,
+				Seq:  pp.Seq,
+				Proposal: &protos.Proposal{
+					Header:               pp.Proposal.Header,
+					Payload:              marshalRequestDigests(infos),
+					Metadata:             pp.Proposal.Metadata,
+					VerificationSequence: pp.Proposal.VerificationSequence,
+				},
+				PrevCommitSignatures: pp.PrevCommitSignatures,
+			},
+		},
+	}
+}
+
+// assemblePrePrepare assembles the payload of the proposal of the given pre-prepare, which carries the digests
+// of its requests, back from the requests in the pool, and dispatches the assembled pre-prepare. The requests missing
+// from the pool are fetched from the sender in the background, so that a sender that withholds them does not hold up
+// the processing of other messages, and the pre-prepare is dropped if they are not fetched within FetchTimeout.
+func (c *Controller) assemblePrePrepare(sender uint64, m *protos.Message) {
+	pp := m.GetPrePrepare()
+	if pp.Proposal == nil {
+		c.dispatchMessage(sender, m)
+		return
+	}
+	infos, err := unmarshalRequestDigests(pp.Proposal.Payload)
+	if err != nil {
+		c.Logger.Warnf("%d could not assemble the proposal of %s from %d: %v", c.ID, MsgToString(m), sender, err)
+		return
+	}
+
+	requests := make([][]byte, len(infos))
+	var missing []int
+	for i, info := range infos {
+		if req, exists := c.RequestPool.Request(info); exists {
+			requests[i] = req
+			continue
+		}
+		missing = append(missing, i)
+	}
+
+	if len(missing) == 0 {
+		c.dispatchMessage(sender, c.assembledPrePrepare(pp, requests))
+		return
+	}
+	if c.RequestFetcher == nil {
+		c.Logger.Warnf("%d could not assemble the proposal of %s from %d: missing %d out of %d requests",
+			c.ID, MsgToString(m), sender, len(missing), len(infos))
+		return
+	}
+	go c.fetchMissingRequests(sender, pp, infos, requests, missing, c.stopChan)
+}
+
+// fetchMissingRequests fetches the requests at the given missing indices of the proposal of the given pre-prepare
+// from the sender, and dispatches the pre-prepare once its proposal is assembled. It gives up once FetchTimeout
+// expires or the controller is stopped, in which case the fetching goroutine is left to return on its own.
+func (c *Controller) fetchMissingRequests(sender uint64, pp *protos.PrePrepare, infos []types.RequestInfo, requests [][]byte, missing []int, stopChan chan struct{}) {
+	missingInfos := make([]types.RequestInfo, 0, len(missing))
+	for _, i := range missing {
+		missingInfos = append(missingInfos, infos[i])
+	}
+	c.Logger.Debugf("%d is fetching %d out of %d requests from %d", c.ID, len(missing), len(infos), sender)
+
+	fetchedChan := make(chan [][]byte, 1)
+	go func() {
+		fetchedChan <- c.RequestFetcher.FetchRequests(sender, missingInfos)
+	}()
+
+	timeout := time.NewTimer(c.FetchTimeout)
+	defer timeout.Stop()
+	var fetchedRequests [][]byte
+	select {
+	case fetchedRequests = <-fetchedChan:
+	case <-timeout.C:
+		c.Logger.Warnf("%d could not assemble the proposal of sequence %d from %d: fetching %d requests timed out after %v",
+			c.ID, pp.Seq, sender, len(missing), c.FetchTimeout)
+		return
+	case <-stopChan:
+		return
+	}
+
+	fetched := make(map[types.RequestInfo][]byte, len(missing))
+	for _, req := range fetchedRequests {
+		fetched[c.RequestInspector.RequestID(req)] = req
+	}
+	for _, i := range missing {
+		req, exists := fetched[infos[i]]
+		if !exists {
+			c.Logger.Warnf("%d could not assemble the proposal of sequence %d from %d: request %s could not be fetched",
+				c.ID, pp.Seq, sender, infos[i])
+			return
 		}
+		requests[i] = req
+	}
+	c.dispatchMessage(sender, c.assembledPrePrepare(pp, requests))
+}
+
+// assembledPrePrepare returns the given pre-prepare with the payload of its proposal assembled from the given requests
+func (c *Controller) assembledPrePrepare(pp *protos.PrePrepare, requests [][]byte) *protos.Message {
+	return &protos.Message{
+		Content: &protos.Message_PrePrepare{
+			PrePrepare: &protos.PrePrepare{
+				View: pp.View,
+				Seq:  pp.Seq,
+				Proposal: &protos.Proposal{
+					Header:               pp.Proposal.Header,
+					Payload:              c.PayloadCompactor.AssemblePayload(requests),
+					Metadata:             pp.Proposal.Metadata,
+					VerificationSequence: pp.Proposal.VerificationSequence,
+				},
+				PrevCommitSignatures: pp.PrevCommitSignatures,
+			},
+		},
+	}
+}
+
+// send sends the message to the node, unless the FaultInjector injects a fault into it
+func (c *Controller) send(node uint64, m *protos.Message) {
+	if c.FaultInjector != nil {
+		fault := c.FaultInjector.OutgoingFault(node, m)
+		if c.injectFault(fault, m, func(m *protos.Message) { c.Comm.SendConsensus(node, m) }) {
+			c.Logger.Debugf("Injected %+v into %s sent to %d", fault, MsgToString(m), node)
+			return
+		}
+	}
+	c.Comm.SendConsensus(node, m)
+}
+
+// injectFault applies the given fault to a message, which is handled by the given function unless it is dropped,
+// and returns whether a fault was injected.
+func (c *Controller) injectFault(fault types.Fault, m *protos.Message, handle func(m *protos.Message)) bool {
+	if fault == (types.Fault{}) {
+		return false
+	}
+	if fault.Drop {
+		return true
+	}
+	if fault.Replace != nil {
+		m = fault.Replace
+	}
+	times := 1
+	if fault.Duplicate {
+		times = 2
+	}
+	handleAll := func() {
+		for i := 0; i < times; i++ {
+			handle(m)
+		}
+	}
+	if fault.Delay == 0 {
+		handleAll()
+		return true
+	}
+	time.AfterFunc(fault.Delay, func() {
+		if !c.stopped() {
+			handleAll()
+		}
+	})
+	return true
+}
+
+// syncedReconfig returns the reconfiguration the given sync response replicated
+func syncedReconfig(syncResult types.SyncResponse) types.Reconfig {
+	return types.Reconfig{
+		CurrentNodes:     syncResult.Reconfig.CurrentNodes,
+		InLatestDecision: syncResult.Reconfig.InReplicatedDecisions,
+		CurrentConfig:    syncResult.Reconfig.CurrentConfig,
 	}
 }
 
@@ -929,7 +2198,43 @@ type MutuallyExclusiveDeliver struct {
 	C *Controller
 }
 
+// ViewRecordingDeliverer delivers decisions along with the view in which they were committed
+type ViewRecordingDeliverer interface {
+	DeliverInView(proposal types.Proposal, signature []types.Signature, commitView uint64) types.Reconfig
+}
+
+// inViewDeliverer returns a ViewRecordingDeliverer that delivers decisions to the given application,
+// and passes on the views in which they were committed only if the application records them.
+func inViewDeliverer(app api.Application) ViewRecordingDeliverer {
+	if deliverer, ok := app.(ViewRecordingDeliverer); ok {
+		return deliverer
+	}
+	return &viewIgnoringDeliverer{app: app}
+}
+
+// applicationDeliverer returns the ViewRecordingDeliverer of the Application, which is resolved on first use
+func (c *Controller) applicationDeliverer() ViewRecordingDeliverer {
+	c.appDelivererOnce.Do(func() {
+		c.appDeliverer = inViewDeliverer(c.Application)
+	})
+	return c.appDeliverer
+}
+
+type viewIgnoringDeliverer struct {
+	app api.Application
+}
+
+func (d *viewIgnoringDeliverer) DeliverInView(proposal types.Proposal, signature []types.Signature, _ uint64) types.Reconfig {
+	return d.app.Deliver(proposal, signature)
+}
+
+// Deliver delivers the given decision, as committed in the current view of the controller
 func (med *MutuallyExclusiveDeliver) Deliver(proposal types.Proposal, signature []types.Signature) types.Reconfig {
+	return med.DeliverInView(proposal, signature, med.C.getCurrentViewNumber())
+}
+
+// DeliverInView delivers the given decision, as committed in the given view
+func (med *MutuallyExclusiveDeliver) DeliverInView(proposal types.Proposal, signature []types.Signature, commitView uint64) types.Reconfig {
 	pendingProposalMetadata := &protos.ViewMetadata{}
 	if err := proto.Unmarshal(proposal.Metadata, pendingProposalMetadata); err != nil {
 		med.C.Logger.Panicf("Failed unmarshalling metadata of pending proposal: %v", err)
@@ -945,21 +2250,89 @@ func (med *MutuallyExclusiveDeliver) Deliver(proposal types.Proposal, signature
 	if latest != 0 && latest >= pendingProposalMetadata.LatestSequence {
 		med.C.Logger.Infof("Attempted to deliver block %d via view change but meanwhile view change already synced to seq %d, "+
 			"returning result from sync", pendingProposalMetadata.LatestSequence, latest)
-		syncResult := med.C.Synchronizer.Sync()
+		syncResult := med.C.synchronize()
 		med.C.Checkpoint.Set(syncResult.Latest.Proposal, syncResult.Latest.Signatures)
-		return types.Reconfig{
-			CurrentNodes:     syncResult.Reconfig.CurrentNodes,
-			InLatestDecision: syncResult.Reconfig.InReplicatedDecisions,
-			CurrentConfig:    syncResult.Reconfig.CurrentConfig,
+		return syncedReconfig(syncResult)
+	}
+
+	// If the pending proposal skips sequences, it is buffered until the decisions in between are delivered,
+	// so that the application receives the decisions in order. The decisions in between are synced,
+	// unless they are delivered later on, such as a late decision of the previous view.
+	seq := pendingProposalMetadata.LatestSequence
+	pending := outOfSequenceDecision{proposal: proposal, signatures: signature, commitView: commitView}
+	if seq > latest+1 {
+		med.C.Logger.Warnf("Attempted to deliver block %d while the latest delivered block is %d, buffering it and syncing the blocks in between",
+			seq, latest)
+		if med.C.outOfSequence == nil {
+			med.C.outOfSequence = make(map[uint64]outOfSequenceDecision)
+		}
+		med.C.outOfSequence[seq] = pending
+		syncResult := med.C.synchronize()
+		syncedMetadata := &protos.ViewMetadata{}
+		if err := proto.Unmarshal(syncResult.Latest.Proposal.Metadata, syncedMetadata); err != nil {
+			med.C.Logger.Panicf("Failed unmarshalling metadata of synced proposal: %v", err)
+		}
+		if syncedMetadata.LatestSequence > latest {
+			med.C.Checkpoint.Set(syncResult.Latest.Proposal, syncResult.Latest.Signatures)
 		}
+		if syncResult.Reconfig.InReplicatedDecisions {
+			med.C.outOfSequence = nil
+			return syncedReconfig(syncResult)
+		}
+		return med.deliverInSequence()
+	}
+
+	result := med.deliverToApplication(pending, seq)
+	if result.InLatestDecision {
+		med.C.outOfSequence = nil
+		return result
+	}
+	if buffered := med.deliverInSequence(); buffered.InLatestDecision {
+		return buffered
 	}
+	return result
+}
+
+// deliverInSequence delivers the buffered decisions that follow the checkpoint, in the order of their sequences,
+// and discards the buffered decisions the checkpoint already passed. It stops at a decision that reconfigures
+// the nodes, and returns the reconfiguration of the last decision it delivered.
+// Decisions that do not follow the checkpoint remain buffered, and are not delivered yet.
+func (med *MutuallyExclusiveDeliver) deliverInSequence() types.Reconfig {
+	var result types.Reconfig
+	for {
+		latest := med.C.latestSeq()
+		for seq := range med.C.outOfSequence {
+			if seq <= latest {
+				delete(med.C.outOfSequence, seq)
+			}
+		}
+		next, exists := med.C.outOfSequence[latest+1]
+		if !exists {
+			if len(med.C.outOfSequence) > 0 {
+				med.C.Logger.Infof("Not delivering %d buffered blocks, as the latest delivered block is %d", len(med.C.outOfSequence), latest)
+			}
+			return result
+		}
+		delete(med.C.outOfSequence, latest+1)
+		result = med.deliverToApplication(next, latest+1)
+		if result.InLatestDecision {
+			med.C.outOfSequence = nil
+			return result
+		}
+	}
+}
 
+// deliverToApplication delivers the given decision of the given sequence to the application, and sets it as the checkpoint
+func (med *MutuallyExclusiveDeliver) deliverToApplication(d outOfSequenceDecision, seq uint64) types.Reconfig {
 	begin := time.Now()
-	result := med.C.Application.Deliver(proposal, signature)
+	result := med.C.applicationDeliverer().DeliverInView(d.proposal, d.signatures, d.commitView)
 	med.C.MetricsView.LatencyBatchSave.Observe(time.Since(begin).Seconds())
 
 	// Only set the proposal in case it is later than the already known checkpoint.
-	med.C.Checkpoint.Set(proposal, signature)
+	med.C.Checkpoint.Set(d.proposal, d.signatures)
+	// The in-flight proposal is cleared only after the checkpoint is set,
+	// so the view changer always observes the proposal in at least one of them.
+	med.C.InFlight.ClearCommitted(seq)
 
 	return result
 }
@@ -6,7 +6,10 @@
 package bft_test
 
 import (
+	"context"
 	"os"
+	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -128,7 +131,7 @@ func TestControllerLeaderBasic(t *testing.T) {
 	batcher.AssertCalled(t, "NextBatch")
 }
 
-func TestLeaderPropose(t *testing.T) {
+func TestLeaderGivesUpFailedAssembly(t *testing.T) {
 	basicLog, err := zap.NewDevelopment()
 	assert.NoError(t, err)
 	log := basicLog.Sugar()
@@ -136,153 +139,129 @@ func TestLeaderPropose(t *testing.T) {
 	batcher := &mocks.Batcher{}
 	batcher.On("Close")
 	batcher.On("Closed").Return(false)
-	batcher.On("NextBatch").Return([][]byte{req}).Once()
-	batcher.On("NextBatch").Return([][]byte{req}).Once()
-	batcher.On("PopRemainder").Return([][]byte{})
-	batcher.On("BatchRemainder", mock.Anything)
-	verifier := &mocks.VerifierMock{}
-	verifier.On("VerifySignature", mock.Anything).Return(nil)
-	verifier.On("VerifyRequest", req).Return(types.RequestInfo{}, nil)
-	verifier.On("VerificationSequence").Return(uint64(1))
-	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
-	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
-	verifier.On("AuxiliaryData", mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
-		Ids: []uint64{11, 23},
-	}))
-	assembler := &mocks.AssemblerMock{}
-	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(proposal, [][]byte{}).Once()
-	secondProposal := proposal
-	secondProposal.Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
-		DecisionsInView: 1,
-		LatestSequence:  1,
-		ViewId:          1,
-	})
-	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(secondProposal, [][]byte{}).Once()
-	comm := &mocks.CommMock{}
-	commWG := sync.WaitGroup{}
-	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		commWG.Done()
-	})
-	comm.On("Nodes").Return([]uint64{11, 17, 23, 37})
-	signer := &mocks.SignerMock{}
-	signer.On("Sign", mock.Anything).Return(nil)
-	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
-		ID:    17,
-		Value: []byte{4},
+	batcher.On("NextBatch").Return([][]byte{req})
+	assembler := &mocks.FallibleAssemblerMock{}
+	assembler.On("TryAssembleProposal", mock.Anything, [][]byte{req}).Return(types.Proposal{}, errors.New("unavailable"))
+	failureDetector := &mocks.FailureDetector{}
+	complained := make(chan uint64, 1)
+	failureDetector.On("Complain", mock.Anything, true).Run(func(args mock.Arguments) {
+		complained <- args.Get(0).(uint64)
 	})
-	app := &mocks.ApplicationMock{}
-	appWG := sync.WaitGroup{}
-	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		appWG.Done()
-	}).Return(types.Reconfig{InLatestDecision: false})
-	reqPool := &mocks.RequestPool{}
-	reqPool.On("Prune", mock.Anything)
-	reqPool.On("Close")
+	pool := &mocks.RequestPool{}
+	pool.On("Close")
 	leaderMon := &mocks.LeaderMonitor{}
 	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
-	leaderMon.On("HeartbeatWasSent")
 	leaderMon.On("Close")
-
-	testDir, err := os.MkdirTemp("", "controller-unittest")
-	assert.NoErrorf(t, err, "generate temporary test dir")
-	defer os.RemoveAll(testDir)
-	wal, err := wal.Create(log, testDir, nil)
-	assert.NoError(t, err)
-
-	synchronizer := &mocks.SynchronizerMock{}
-	synchronizer.On("Sync").Run(func(args mock.Arguments) {}).Return(types.SyncResponse{Latest: types.Decision{
-		Proposal:   types.Proposal{VerificationSequence: 0},
-		Signatures: nil,
-	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}})
-
-	collector := bft.StateCollector{
-		SelfID:         11,
-		N:              4,
-		Logger:         log,
-		CollectTimeout: 100 * time.Millisecond,
-	}
-	collector.Start()
+	commMock := &mocks.CommMock{}
+	commMock.On("SendConsensus", mock.Anything, mock.Anything)
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
 
 	startedWG := sync.WaitGroup{}
 	startedWG.Add(1)
 
 	controller := &bft.Controller{
-		InFlight:      &bft.InFlightData{},
-		RequestPool:   reqPool,
-		LeaderMonitor: leaderMon,
-		WAL:           wal,
-		ID:            17, // the leader
-		N:             4,
-		NodesList:     []uint64{11, 17, 23, 37},
-		Logger:        log,
-		Batcher:       batcher,
-		Verifier:      verifier,
-		Assembler:     assembler,
-		Comm:          comm,
-		Signer:        signer,
-		Application:   app,
-		Checkpoint:    &types.Checkpoint{},
-		ViewChanger:   &bft.ViewChanger{},
-		Synchronizer:  synchronizer,
-		Collector:     &collector,
-		StartedWG:     &startedWG,
-		MetricsView:   api.NewMetricsView(&disabled.Provider{}),
+		InFlight:          &bft.InFlightData{},
+		Checkpoint:        &types.Checkpoint{},
+		RequestPool:       pool,
+		LeaderMonitor:     leaderMon,
+		FailureDetector:   failureDetector,
+		ID:                2, // the leader
+		N:                 4,
+		NodesList:         []uint64{1, 2, 3, 4},
+		Logger:            log,
+		Batcher:           batcher,
+		FallibleAssembler: assembler,
+		AssembleAttempts:  3,
+		AssembleBackoff:   time.Millisecond,
+		Comm:              commMock,
+		Verifier:          verifier,
+		StartedWG:         &startedWG,
 	}
 	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
 
 	configureProposerBuilder(controller)
 
-	commWG.Add(9)
-	controller.Start(1, 0, 0, true)
-	commWG.Wait() // propose + state request
-
-	commWG.Add(3)
-	controller.ProcessMessages(23, prepare)
-	controller.ProcessMessages(37, prepare)
-	commWG.Wait()
+	controller.Start(1, 0, 0, false)
+	assert.Equal(t, uint64(1), <-complained)
+	controller.Stop()
+	assembler.AssertNumberOfCalls(t, "TryAssembleProposal", 3)
+	// Every attempt takes the next batch anew, since the run loop is released while waiting to retry
+	batcher.AssertNumberOfCalls(t, "NextBatch", 3)
+}
 
-	commit23 := proto.Clone(commit2).(*protos.Message)
-	commit23Get := commit23.GetCommit()
-	commit23Get.Signature.Signer = 23
-	controller.ProcessMessages(23, commit23)
-	commit37 := proto.Clone(commit3).(*protos.Message)
-	commit37Get := commit37.GetCommit()
-	commit37Get.Signature.Signer = 37
-	appWG.Add(1)  // deliver
-	commWG.Add(6) // next proposal
-	controller.ProcessMessages(37, commit37)
-	appWG.Wait()
-	commWG.Wait()
+func TestAbortViewWhileRetryingAssembly(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	req := []byte{1}
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	batcher.On("NextBatch").Return([][]byte{req})
+	assembler := &mocks.FallibleAssemblerMock{}
+	attempted := make(chan struct{}, 1)
+	assembler.On("TryAssembleProposal", mock.Anything, [][]byte{req}).Return(types.Proposal{}, errors.New("unavailable")).Run(func(mock.Arguments) {
+		attempted <- struct{}{}
+	})
+	failureDetector := &mocks.FailureDetector{}
+	pool := &mocks.RequestPool{}
+	pool.On("Close")
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMon.On("Close")
+	commMock := &mocks.CommMock{}
+	commMock.On("SendConsensus", mock.Anything, mock.Anything)
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
 
-	controller.Stop()
-	app.AssertNumberOfCalls(t, "Deliver", 1)
-	leaderMon.AssertCalled(t, "HeartbeatWasSent")
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
 
-	// Ensure checkpoint was updated
-	expected := &protos.Proposal{
-		Header:               proposal.Header,
-		Payload:              proposal.Payload,
-		Metadata:             proposal.Metadata,
-		VerificationSequence: uint64(proposal.VerificationSequence),
-	}
-	proposal, signatures := controller.Checkpoint.Get()
-	assert.Equal(t, expected, proposal)
-	signaturesBySigners := make(map[uint64]*protos.Signature)
-	for _, sig := range signatures {
-		signaturesBySigners[sig.Signer] = sig
+	controller := &bft.Controller{
+		InFlight:           &bft.InFlightData{},
+		Checkpoint:         &types.Checkpoint{},
+		RequestPool:        pool,
+		LeaderMonitor:      leaderMon,
+		FailureDetector:    failureDetector,
+		ID:                 2, // the leader
+		N:                  4,
+		NodesList:          []uint64{1, 2, 3, 4},
+		Logger:             log,
+		Batcher:            batcher,
+		FallibleAssembler:  assembler,
+		AssembleAttempts:   3,
+		AssembleBackoff:    time.Hour,
+		AssembleMaxBackoff: time.Hour,
+		Comm:               commMock,
+		Verifier:           verifier,
+		StartedWG:          &startedWG,
 	}
-	assert.Equal(t, map[uint64]*protos.Signature{
-		17: {Signer: 17, Value: []byte{4}},
-		23: {Signer: 23, Value: []byte{4}},
-		37: {Signer: 37, Value: []byte{4}},
-	}, signaturesBySigners)
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+
+	configureProposerBuilder(controller)
 
+	controller.Start(1, 0, 0, false)
+	<-attempted
+
+	// The run loop handles aborts without waiting for the backoff of the failed attempt to elapse,
+	// and the second abort can only be sent once the run loop received the first one
+	aborted := make(chan struct{})
+	go func() {
+		controller.AbortView(1)
+		controller.AbortView(1)
+		close(aborted)
+	}()
+	select {
+	case <-aborted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("aborting the view was blocked by the assembly retry")
+	}
 	controller.Stop()
-	collector.Stop()
-	wal.Close()
+	assembler.AssertNumberOfCalls(t, "TryAssembleProposal", 1)
 }
 
-func TestViewChanged(t *testing.T) {
+func TestLeaderGivesUpWithoutCommit(t *testing.T) {
 	basicLog, err := zap.NewDevelopment()
 	assert.NoError(t, err)
 	log := basicLog.Sugar()
@@ -290,340 +269,199 @@ func TestViewChanged(t *testing.T) {
 	batcher := &mocks.Batcher{}
 	batcher.On("Close")
 	batcher.On("Closed").Return(false)
-	batcher.On("Reset")
 	batcher.On("NextBatch").Return([][]byte{req})
 	verifier := &mocks.VerifierMock{}
-	verifier.On("VerifySignature", mock.Anything).Return(nil)
-	verifier.On("VerificationSequence").Return(uint64(1))
-	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
-
-	secondProposal := proposal
-	secondProposal.Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
-		LatestSequence: 0,
-		ViewId:         2,
-	})
-
+	verifier.On("VerificationSequence").Return(uint64(0))
+	verifier.On("VerifyProposal", mock.Anything).Return(nil, nil)
 	assembler := &mocks.AssemblerMock{}
-	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(secondProposal, [][]byte{}).Once()
-	comm := &mocks.CommMock{}
-	commWG := sync.WaitGroup{}
-	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		commWG.Done()
+	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(func(metadata []byte, _ [][]byte) types.Proposal {
+		return types.Proposal{Payload: proposal.Payload, Metadata: metadata}
 	})
-	reqPool := &mocks.RequestPool{}
-	reqPool.On("Prune", mock.Anything)
-	reqPool.On("Close")
+	failureDetector := &mocks.FailureDetector{}
+	complained := make(chan time.Time, 1)
+	failureDetector.On("Complain", uint64(1), true).Run(func(args mock.Arguments) {
+		complained <- time.Now()
+	})
+	// The followers are down, so the proposal is never committed
+	comm := &mocks.CommMock{}
+	comm.On("SendConsensus", mock.Anything, mock.Anything)
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return(nil)
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{})
+	pool := &mocks.RequestPool{}
+	pool.On("Close")
 	leaderMon := &mocks.LeaderMonitor{}
-	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything)
 	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
 	leaderMon.On("HeartbeatWasSent")
 	leaderMon.On("Close")
 
-	signer := &mocks.SignerMock{}
-	signer.On("Sign", mock.Anything).Return(nil)
-
 	testDir, err := os.MkdirTemp("", "controller-unittest")
 	assert.NoErrorf(t, err, "generate temporary test dir")
 	defer os.RemoveAll(testDir)
 	wal, err := wal.Create(log, testDir, nil)
 	assert.NoError(t, err)
-
-	synchronizer := &mocks.SynchronizerMock{}
-	synchronizer.On("Sync").Run(func(args mock.Arguments) {}).Return(types.SyncResponse{Latest: types.Decision{
-		Proposal:   types.Proposal{VerificationSequence: 0},
-		Signatures: nil,
-	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}})
-
-	collector := bft.StateCollector{
-		SelfID:         1,
-		N:              4,
-		Logger:         log,
-		CollectTimeout: 100 * time.Millisecond,
-	}
-	collector.Start()
+	defer wal.Close()
 
 	startedWG := sync.WaitGroup{}
 	startedWG.Add(1)
 
+	maxIdle := 500 * time.Millisecond
 	controller := &bft.Controller{
-		InFlight:      &bft.InFlightData{},
-		Checkpoint:    &types.Checkpoint{},
-		Signer:        signer,
-		WAL:           wal,
-		ID:            3, // the next leader
-		N:             4,
-		NodesList:     []uint64{1, 2, 3, 4},
-		Logger:        log,
-		Batcher:       batcher,
-		Verifier:      verifier,
-		Assembler:     assembler,
-		Comm:          comm,
-		RequestPool:   reqPool,
-		LeaderMonitor: leaderMon,
-		Synchronizer:  synchronizer,
-		Collector:     &collector,
-		StartedWG:     &startedWG,
+		InFlight:        &bft.InFlightData{},
+		Checkpoint:      &types.Checkpoint{},
+		RequestPool:     pool,
+		LeaderMonitor:   leaderMon,
+		FailureDetector: failureDetector,
+		WAL:             wal,
+		ID:              2, // the leader
+		N:               4,
+		NodesList:       []uint64{1, 2, 3, 4},
+		Logger:          log,
+		Batcher:         batcher,
+		Assembler:       assembler,
+		Comm:            comm,
+		Signer:          signer,
+		Verifier:        verifier,
+		StartedWG:       &startedWG,
+		MetricsView:     api.NewMetricsView(&disabled.Provider{}),
+		MaxLeaderIdle:   maxIdle,
 	}
 	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
 
 	configureProposerBuilder(controller)
 
-	commWG.Add(3) // state request
-	controller.Start(1, 0, 0, true)
-	commWG.Wait()
+	start := time.Now()
+	controller.Start(1, 0, 0, false)
+	defer controller.Stop()
 
-	commWG.Add(6) // propose
-	controller.ViewChanged(2, 0)
-	commWG.Wait()
+	select {
+	case complainedAt := <-complained:
+		assert.GreaterOrEqual(t, complainedAt.Sub(start), maxIdle)
+	case <-time.After(10 * time.Second):
+		t.Fatal("leader did not give up leading")
+	}
 	batcher.AssertNumberOfCalls(t, "NextBatch", 1)
-	assembler.AssertNumberOfCalls(t, "AssembleProposal", 1)
-	comm.AssertNumberOfCalls(t, "SendConsensus", 9)
-	leaderMon.AssertCalled(t, "HeartbeatWasSent")
-	controller.Stop()
-	collector.Stop()
-	wal.Close()
 }
 
-func TestSyncPrevView(t *testing.T) {
-	basicLog, err := zap.NewDevelopment()
-	assert.NoError(t, err)
-	log := basicLog.Sugar()
-	app := &mocks.ApplicationMock{}
-	appWG := sync.WaitGroup{}
-	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		appWG.Done()
-	}).Return(types.Reconfig{InLatestDecision: false})
-	batcher := &mocks.Batcher{}
-	batcher.On("Close")
-	pool := &mocks.RequestPool{}
-	pool.On("Close")
-	pool.On("Prune", mock.Anything)
-	leaderMon := &mocks.LeaderMonitor{}
-	leaderMon.On("InjectArtificialHeartbeat", mock.Anything, mock.Anything)
-	leaderMonWG := sync.WaitGroup{}
-	leaderMon.On("ChangeRole", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		leaderMonWG.Done()
-	})
-	leaderMon.On("Close")
-	comm := &mocks.CommMock{}
-	comm.On("SendConsensus", mock.Anything, mock.Anything)
-	verifier := &mocks.VerifierMock{}
-	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
-	verifier.On("VerificationSequence").Return(uint64(1))
-	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
-	signer := &mocks.SignerMock{}
-	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
-		ID:    4,
-		Value: []byte{4},
-	})
-	fd := &mocks.FailureDetector{}
-	fd.On("Complain", mock.Anything, mock.Anything)
-	synchronizer := &mocks.SynchronizerMock{}
-	synchronizerWG := sync.WaitGroup{}
-	synchronizer.On("Sync").Run(func(args mock.Arguments) {
-		synchronizerWG.Done()
-	}).Return(types.SyncResponse{Latest: types.Decision{
-		Proposal: types.Proposal{
-			Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
-				LatestSequence: 0,
-				ViewId:         0, // previous view number
-			}),
-			VerificationSequence: 1,
-		},
-		Signatures: nil,
-	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}})
-
-	startedWG := sync.WaitGroup{}
-	startedWG.Add(1)
-
-	testDir, err := os.MkdirTemp("", "controller-unittest")
-	assert.NoErrorf(t, err, "generate temporary test dir")
-	defer os.RemoveAll(testDir)
-	wal, err := wal.Create(log, testDir, nil)
-	assert.NoError(t, err)
-
-	collector := bft.StateCollector{
-		SelfID:         0,
-		N:              4,
-		Logger:         log,
-		CollectTimeout: 100 * time.Millisecond,
-	}
-	collector.Start()
-	defer collector.Stop()
-
-	controller := &bft.Controller{
-		Collector:       &collector,
-		InFlight:        &bft.InFlightData{},
-		Batcher:         batcher,
-		RequestPool:     pool,
-		LeaderMonitor:   leaderMon,
-		ID:              4, // not the leader
-		N:               4,
-		NodesList:       []uint64{1, 2, 3, 4},
-		Logger:          log,
-		Application:     app,
-		Comm:            comm,
-		ViewChanger:     &bft.ViewChanger{},
-		Checkpoint:      &types.Checkpoint{},
-		FailureDetector: fd,
-		Synchronizer:    synchronizer,
-		Verifier:        verifier,
-		Signer:          signer,
-		WAL:             wal,
-		StartedWG:       &startedWG,
-		MetricsView:     api.NewMetricsView(&disabled.Provider{}),
-	}
-	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
-
-	vs := configureProposerBuilder(controller)
-	controller.ViewSequences = vs
-
-	leaderMonWG.Add(1)
-	controller.Start(1, 0, 0, false)
-	leaderMonWG.Wait()
+func TestViewChangeWhenViewIdle(t *testing.T) {
+	for _, testCase := range []struct {
+		description string
+		maxIdle     time.Duration
+		pending     int
+		complains   bool
+	}{
+		{description: "pending requests", maxIdle: 500 * time.Millisecond, pending: 1, complains: true},
+		{description: "no pending requests", maxIdle: 500 * time.Millisecond, pending: 0, complains: false},
+		{description: "tiny max idle", maxIdle: 5 * time.Nanosecond, pending: 1, complains: true},
+	} {
+		testCase := testCase
+		t.Run(testCase.description, func(t *testing.T) {
+			basicLog, err := zap.NewDevelopment()
+			assert.NoError(t, err)
+			log := basicLog.Sugar()
+			// The leader keeps the view alive but never proposes
+			batcher := &mocks.Batcher{}
+			batcher.On("Close")
+			batcher.On("Closed").Return(false)
+			batcher.On("NextBatch").Run(func(args mock.Arguments) {
+				time.Sleep(10 * time.Millisecond)
+			}).Return(nil)
+			verifier := &mocks.VerifierMock{}
+			verifier.On("VerificationSequence").Return(uint64(0))
+			failureDetector := &mocks.FailureDetector{}
+			complained := make(chan time.Time, 1)
+			failureDetector.On("Complain", uint64(1), true).Run(func(args mock.Arguments) {
+				select {
+				case complained <- time.Now():
+				default:
+				}
+			})
+			pool := &mocks.RequestPool{}
+			pool.On("Close")
+			pool.On("Size").Return(testCase.pending)
+			leaderMon := &mocks.LeaderMonitor{}
+			leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+			leaderMon.On("Close")
 
-	appWG.Add(1)
-	controller.ProcessMessages(2, prePrepare)
-	controller.ProcessMessages(2, prepare)
-	controller.ProcessMessages(3, prepare)
-	controller.ProcessMessages(2, commit2)
-	controller.ProcessMessages(3, commit3)
+			startedWG := sync.WaitGroup{}
+			startedWG.Add(1)
 
-	appWG.Wait()
-	app.AssertNumberOfCalls(t, "Deliver", 1)
+			maxIdle := testCase.maxIdle
+			controller := &bft.Controller{
+				InFlight:        &bft.InFlightData{},
+				Checkpoint:      &types.Checkpoint{},
+				RequestPool:     pool,
+				LeaderMonitor:   leaderMon,
+				FailureDetector: failureDetector,
+				ID:              2, // the leader
+				N:               4,
+				NodesList:       []uint64{1, 2, 3, 4},
+				Logger:          log,
+				Batcher:         batcher,
+				Verifier:        verifier,
+				StartedWG:       &startedWG,
+				MaxViewIdle:     maxIdle,
+			}
+			controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
 
-	synchronizerWG.Add(1)
-	leaderMonWG.Add(1)
-	wrongViewMsg := proto.Clone(prePrepare).(*protos.Message)
-	wrongViewMsgGet := wrongViewMsg.GetPrePrepare()
-	wrongViewMsgGet.View = 2
-	controller.ProcessMessages(2, wrongViewMsg)
-	synchronizerWG.Wait()
-	leaderMonWG.Wait() // wait for view to start before sending messages
+			configureProposerBuilder(controller)
 
-	prePrepareNext := proto.Clone(prePrepare).(*protos.Message)
-	prePrepareNextGet := prePrepareNext.GetPrePrepare()
-	prePrepareNextGet.Seq = 1
-	prePrepareNextGet.GetProposal().Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
-		DecisionsInView: 1,
-		LatestSequence:  1,
-		ViewId:          1,
-	})
-	controller.ProcessMessages(2, prePrepareNext)
+			start := time.Now()
+			controller.Start(1, 0, 0, false)
+			defer controller.Stop()
 
-	nextProp := types.Proposal{
-		Header:               prePrepareNextGet.Proposal.Header,
-		Payload:              prePrepareNextGet.Proposal.Payload,
-		Metadata:             prePrepareNextGet.Proposal.Metadata,
-		VerificationSequence: 1,
+			select {
+			case complainedAt := <-complained:
+				assert.True(t, testCase.complains, "complained although no requests are pending")
+				assert.GreaterOrEqual(t, complainedAt.Sub(start), maxIdle)
+			case <-time.After(maxIdle + time.Second):
+				assert.False(t, testCase.complains, "did not force a view change")
+			}
+		})
 	}
-	prepareNext := proto.Clone(prepare).(*protos.Message)
-	prepareNextGet := prepareNext.GetPrepare()
-	prepareNextGet.Seq = 1
-	prepareNextGet.Digest = nextProp.Digest()
-	controller.ProcessMessages(2, prepareNext)
-	controller.ProcessMessages(3, prepareNext)
-
-	commit2Next := proto.Clone(commit2).(*protos.Message)
-	commit2NextGet := commit2Next.GetCommit()
-	commit2NextGet.Seq = 1
-	commit2NextGet.Digest = nextProp.Digest()
-
-	commit3Next := proto.Clone(commit3).(*protos.Message)
-	commit3NextGet := commit3Next.GetCommit()
-	commit3NextGet.Seq = 1
-	commit3NextGet.Digest = nextProp.Digest()
-
-	appWG.Add(1)
-	controller.ProcessMessages(2, commit2Next)
-	controller.ProcessMessages(3, commit3Next)
-
-	appWG.Wait()
-	app.AssertNumberOfCalls(t, "Deliver", 2)
-
-	controller.Stop()
-	wal.Close()
 }
 
-func TestControllerLeaderRequestHandling(t *testing.T) {
+func TestAsyncDeliveryDoesNotBlockViewChange(t *testing.T) {
 	for _, testCase := range []struct {
-		description      string
-		startViewNum     uint64
-		verifyReqReturns error
-		shouldEnqueue    bool
-		shouldVerify     bool
-		waitForLoggedMsg string
+		description string
+		async       bool
 	}{
-		{
-			description:      "not the leader",
-			startViewNum:     2,
-			waitForLoggedMsg: "Got request from 3 but the leader is 2, dropping request",
-		},
-		{
-			description:      "bad request",
-			startViewNum:     1,
-			verifyReqReturns: errors.New("unauthorized user"),
-			waitForLoggedMsg: "unauthorized user",
-			shouldVerify:     true,
-		},
-		{
-			description:      "good request",
-			shouldEnqueue:    true,
-			startViewNum:     1,
-			waitForLoggedMsg: "Got request from 3",
-			shouldVerify:     true,
-		},
+		{description: "synchronous delivery", async: false},
+		{description: "asynchronous delivery", async: true},
 	} {
+		async := testCase.async
 		t.Run(testCase.description, func(t *testing.T) {
-			var submittedToPool sync.WaitGroup
-
 			basicLog, err := zap.NewDevelopment()
 			assert.NoError(t, err)
-
 			log := basicLog.Sugar()
-
 			batcher := &mocks.Batcher{}
 			batcher.On("Close")
 			batcher.On("Closed").Return(false)
-			batcher.On("Reset")
-			batcher.On("NextBatch").Run(func(arguments mock.Arguments) {
-				time.Sleep(time.Hour)
-			})
-
+			verifier := &mocks.VerifierMock{}
+			verifier.On("VerificationSequence").Return(uint64(0))
 			pool := &mocks.RequestPool{}
 			pool.On("Close")
 			leaderMon := &mocks.LeaderMonitor{}
 			leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything)
-			leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
 			leaderMon.On("Close")
-			if testCase.shouldEnqueue {
-				submittedToPool.Add(1)
-				pool.On("Submit", mock.Anything).Return(nil).Run(func(_ mock.Arguments) {
-					submittedToPool.Done()
-				})
-			}
-
-			commMock := &mocks.CommMock{}
-			commMock.On("SendConsensus", mock.Anything, mock.Anything)
-
-			verifier := &mocks.VerifierMock{}
-			verifier.On("VerifyRequest", mock.Anything).Return(types.RequestInfo{}, testCase.verifyReqReturns)
-			verifier.On("VerificationSequence").Return(uint64(0))
-
-			synchronizer := &mocks.SynchronizerMock{}
-			synchronizer.On("Sync").Run(func(args mock.Arguments) {}).Return(types.SyncResponse{Latest: types.Decision{
-				Proposal:   types.Proposal{VerificationSequence: 0},
-				Signatures: nil,
-			}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}})
 
-			collector := bft.StateCollector{
-				SelfID:         0,
-				N:              4,
-				Logger:         log,
-				CollectTimeout: 100 * time.Millisecond,
-			}
-			collector.Start()
-			defer collector.Stop()
+			// The application is slow to deliver
+			delivering := make(chan struct{})
+			release := make(chan struct{})
+			delivered := make(chan struct{})
+			app := &mocks.ApplicationMock{}
+			app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				close(delivering)
+				<-release
+				close(delivered)
+			}).Return(types.Reconfig{})
+
+			testDir, err := os.MkdirTemp("", "controller-unittest")
+			assert.NoErrorf(t, err, "generate temporary test dir")
+			defer os.RemoveAll(testDir)
+			wal, err := wal.Create(log, testDir, nil)
+			assert.NoError(t, err)
+			defer wal.Close()
 
 			startedWG := sync.WaitGroup{}
 			startedWG.Add(1)
@@ -633,74 +471,257 @@ func TestControllerLeaderRequestHandling(t *testing.T) {
 				Checkpoint:    &types.Checkpoint{},
 				RequestPool:   pool,
 				LeaderMonitor: leaderMon,
-				ID:            1,
+				WAL:           wal,
+				ID:            4, // a follower in views 1 and 2
 				N:             4,
-				NodesList:     []uint64{0, 1, 2, 3},
+				NodesList:     []uint64{1, 2, 3, 4},
 				Logger:        log,
 				Batcher:       batcher,
-				Comm:          commMock,
 				Verifier:      verifier,
-				Synchronizer:  synchronizer,
-				Collector:     &collector,
+				Application:   app,
 				StartedWG:     &startedWG,
+				MetricsView:   api.NewMetricsView(&disabled.Provider{}),
+				AsyncDelivery: async,
 			}
 			controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
-
 			configureProposerBuilder(controller)
-			controller.Start(testCase.startViewNum, 0, 0, true)
 
-			controller.HandleRequest(3, []byte{1, 2, 3})
+			controller.Start(1, 1, 0, false)
+			defer controller.Stop()
+
+			decided := make(chan struct{})
+			go func() {
+				defer close(decided)
+				controller.Decide(types.Proposal{
+					Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{ViewId: 1, LatestSequence: 1}),
+				}, nil, nil)
+			}()
+			<-delivering
+
+			controller.AbortView(1)
+			controller.ViewChanged(2, 2)
+
+			if !async {
+				// The view change is handled only once the decision is delivered
+				assert.Never(t, func() bool {
+					return controller.CurrentView() == 2
+				}, 500*time.Millisecond, 10*time.Millisecond)
+				close(release)
+				<-decided
+				assert.Eventually(t, func() bool {
+					return controller.CurrentView() == 2
+				}, 10*time.Second, 10*time.Millisecond)
+				return
+			}
 
-			submittedToPool.Wait()
+			// The view change is handled while the decision is being delivered,
+			// and the aborted view does not wait for the delivery
+			assert.Eventually(t, func() bool {
+				return controller.CurrentView() == 2
+			}, 10*time.Second, 10*time.Millisecond)
+			select {
+			case <-decided:
+			case <-time.After(10 * time.Second):
+				t.Fatal("the aborted view waited for the delivery")
+			}
+			select {
+			case <-delivered:
+				t.Fatal("the decision was delivered before the application was released")
+			default:
+			}
 
-			if !testCase.shouldVerify {
-				verifier.AssertNotCalled(t, "VerifyRequest", mock.Anything)
+			close(release)
+			select {
+			case <-delivered:
+			case <-time.After(10 * time.Second):
+				t.Fatal("the decision was not delivered")
 			}
+			assert.Eventually(t, func() bool {
+				proposal, _ := controller.Checkpoint.Get()
+				md := &protos.ViewMetadata{}
+				return proto.Unmarshal(proposal.Metadata, md) == nil && md.LatestSequence == 1
+			}, 10*time.Second, 10*time.Millisecond)
 		})
 	}
 }
 
-func createView(c *bft.Controller, leader, proposalSequence, viewNum, decisionsInView uint64, quorumSize int, vs *atomic.Value) *bft.View {
-	mn := &mocks.MembershipNotifierMock{}
-	mn.On("MembershipChange").Return(false)
-	return &bft.View{
-		RetrieveCheckpoint: c.Checkpoint.Get,
-		N:                  c.N,
-		NodesList:          c.NodesList,
-		LeaderID:           leader,
-		SelfID:             c.ID,
-		Quorum:             quorumSize,
-		Number:             viewNum,
-		Decider:            c,
-		FailureDetector:    c.FailureDetector,
-		Sync:               c,
-		Logger:             c.Logger,
-		Comm:               c,
-		Verifier:           c.Verifier,
-		Signer:             c.Signer,
-		MembershipNotifier: mn,
-		ProposalSequence:   proposalSequence,
-		DecisionsInView:    decisionsInView,
-		ViewSequences:      vs,
-		State:              &bft.PersistedState{WAL: c.WAL, InFlightProposal: &bft.InFlightData{}},
-		InMsgQSize:         int(c.N * 10),
-		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
-		MetricsBlacklist:   api.NewMetricsBlacklist(&disabled.Provider{}),
+func TestLeaderLimitsProposalSize(t *testing.T) {
+	req1, req2 := []byte{1}, []byte{2}
+	oversized := types.Proposal{Payload: make([]byte, 1024)}
+
+	for _, testCase := range []struct {
+		description string
+		batch       [][]byte
+		proposed    bool
+	}{
+		{
+			description: "oversized batch is split",
+			batch:       [][]byte{req1, req2},
+			proposed:    true,
+		},
+		{
+			description: "oversized single request is rejected",
+			batch:       [][]byte{req1},
+			proposed:    false,
+		},
+	} {
+		t.Run(testCase.description, func(t *testing.T) {
+			basicLog, err := zap.NewDevelopment()
+			assert.NoError(t, err)
+			log := basicLog.Sugar()
+			batcher := &mocks.Batcher{}
+			batcher.On("Close")
+			batcher.On("Closed").Return(false)
+			batcher.On("NextBatch").Return(testCase.batch)
+			assembler := &mocks.AssemblerMock{}
+			assembler.On("AssembleProposal", mock.Anything, testCase.batch).Return(oversized)
+			assembler.On("AssembleProposal", mock.Anything, [][]byte{req1}).Return(proposal)
+			failureDetector := &mocks.FailureDetector{}
+			complained := make(chan uint64, 1)
+			failureDetector.On("Complain", mock.Anything, true).Run(func(args mock.Arguments) {
+				complained <- args.Get(0).(uint64)
+			})
+			pool := &mocks.RequestPool{}
+			pool.On("Close")
+			leaderMon := &mocks.LeaderMonitor{}
+			leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+			leaderMon.On("HeartbeatWasSent")
+			leaderMon.On("Close")
+			prePrepares := make(chan *protos.PrePrepare, 10)
+			commMock := &mocks.CommMock{}
+			commMock.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				if pp := args.Get(1).(*protos.Message).GetPrePrepare(); pp != nil {
+					prePrepares <- pp
+				}
+			})
+			verifier := &mocks.VerifierMock{}
+			verifier.On("VerificationSequence").Return(uint64(1))
+			verifier.On("VerifyProposal", mock.Anything).Return(nil, nil)
+
+			testDir, err := os.MkdirTemp("", "controller-unittest")
+			assert.NoErrorf(t, err, "generate temporary test dir")
+			defer os.RemoveAll(testDir)
+			wal, err := wal.Create(log, testDir, nil)
+			assert.NoError(t, err)
+			defer wal.Close()
+
+			startedWG := sync.WaitGroup{}
+			startedWG.Add(1)
+
+			controller := &bft.Controller{
+				InFlight:         &bft.InFlightData{},
+				Checkpoint:       &types.Checkpoint{},
+				RequestPool:      pool,
+				LeaderMonitor:    leaderMon,
+				FailureDetector:  failureDetector,
+				WAL:              wal,
+				ID:               2, // the leader
+				N:                4,
+				NodesList:        []uint64{1, 2, 3, 4},
+				Logger:           log,
+				Batcher:          batcher,
+				Assembler:        assembler,
+				MaxProposalBytes: 100,
+				Comm:             commMock,
+				Verifier:         verifier,
+				StartedWG:        &startedWG,
+			}
+			controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+
+			configureProposerBuilder(controller)
+
+			controller.Start(1, 0, 0, false)
+			if testCase.proposed {
+				pp := <-prePrepares
+				assert.Equal(t, proposal.Payload, pp.Proposal.Payload)
+				assembler.AssertCalled(t, "AssembleProposal", mock.Anything, [][]byte{req1})
+			} else {
+				assert.Equal(t, uint64(1), <-complained)
+			}
+			controller.Stop()
+
+			assert.Empty(t, complained)
+			for len(prePrepares) > 0 {
+				assert.NotEqual(t, oversized.Payload, (<-prePrepares).Proposal.Payload)
+			}
+		})
 	}
 }
 
-func configureProposerBuilder(controller *bft.Controller) *atomic.Value {
-	pb := &mocks.ProposerBuilder{}
-	vs := &atomic.Value{}
-	pb.On("NewProposer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
-		Return(func(a uint64, b uint64, c uint64, d uint64, e int) bft.Proposer {
-			return createView(controller, a, b, c, d, e, vs)
-		}, bft.Phase(bft.COMMITTED))
-	controller.ProposerBuilder = pb
-	return vs
+func TestLeaderReturnsDroppedCandidates(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	insp := &testRequestInspector{}
+	req1, req2, req3 := makeTestRequest("1", "1", "foo"), makeTestRequest("1", "2", "foo"), makeTestRequest("1", "3", "foo")
+	batch := [][]byte{req1, req2, req3}
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	batcher.On("NextBatch").Return(batch).Once()
+	batcher.On("NextBatch").Return(nil)
+	// The assembler includes only the first request, and drops the rest instead of returning them in the remainder
+	assembler := &mocks.CandidateAssemblerMock{}
+	assembler.On("AssembleProposalFromCandidates", mock.Anything, batch).Return(proposal, nil)
+	// The second request is no longer in the pool, while the third still is
+	pool := &mocks.RequestPool{}
+	pool.On("Close")
+	pool.On("Contains", insp.RequestID(req2)).Return(false)
+	pool.On("Contains", insp.RequestID(req3)).Return(true)
+	resubmitted := make(chan []byte, 1)
+	pool.On("Submit", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		resubmitted <- args.Get(0).([]byte)
+	})
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMon.On("HeartbeatWasSent")
+	leaderMon.On("Close")
+	commMock := &mocks.CommMock{}
+	commMock.On("SendConsensus", mock.Anything, mock.Anything)
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifier.On("VerifyProposal", mock.Anything).Return(nil, nil)
+	verifier.On("RequestsFromProposal", proposal).Return([]types.RequestInfo{insp.RequestID(req1)})
+
+	testDir, err := os.MkdirTemp("", "controller-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	wal, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+	defer wal.Close()
+
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
+
+	controller := &bft.Controller{
+		InFlight:           &bft.InFlightData{},
+		Checkpoint:         &types.Checkpoint{},
+		RequestPool:        pool,
+		RequestInspector:   insp,
+		LeaderMonitor:      leaderMon,
+		WAL:                wal,
+		ID:                 2, // the leader
+		N:                  4,
+		NodesList:          []uint64{1, 2, 3, 4},
+		Logger:             log,
+		Batcher:            batcher,
+		CandidateAssembler: assembler,
+		Comm:               commMock,
+		Verifier:           verifier,
+		StartedWG:          &startedWG,
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+
+	configureProposerBuilder(controller)
+
+	controller.Start(1, 0, 0, false)
+	assert.Equal(t, req2, <-resubmitted)
+	controller.Stop()
+
+	pool.AssertNumberOfCalls(t, "Submit", 1)
 }
 
-func TestSyncInform(t *testing.T) {
+func TestLeaderPropose(t *testing.T) {
 	basicLog, err := zap.NewDevelopment()
 	assert.NoError(t, err)
 	log := basicLog.Sugar()
@@ -708,54 +729,53 @@ func TestSyncInform(t *testing.T) {
 	batcher := &mocks.Batcher{}
 	batcher.On("Close")
 	batcher.On("Closed").Return(false)
-	batcher.On("Reset")
-	batcher.On("NextBatch").Return([][]byte{req})
+	batcher.On("NextBatch").Return([][]byte{req}).Once()
+	batcher.On("NextBatch").Return([][]byte{req}).Once()
+	batcher.On("PopRemainder").Return([][]byte{})
+	batcher.On("BatchRemainder", mock.Anything)
 	verifier := &mocks.VerifierMock{}
 	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	verifier.On("VerifyRequest", req).Return(types.RequestInfo{}, nil)
 	verifier.On("VerificationSequence").Return(uint64(1))
 	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
 	verifier.On("AuxiliaryData", mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
-		Ids: []uint64{1},
+		Ids: []uint64{11, 23},
 	}))
-	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
-		Ids: []uint64{1},
-	}), nil)
-
+	assembler := &mocks.AssemblerMock{}
+	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(proposal, [][]byte{}).Once()
 	secondProposal := proposal
 	secondProposal.Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
 		DecisionsInView: 1,
-		LatestSequence:  2,
-		ViewId:          2,
+		LatestSequence:  1,
+		ViewId:          1,
 	})
-
-	assembler := &mocks.AssemblerMock{}
-	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(secondProposal).Once()
-
+	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(secondProposal, [][]byte{}).Once()
 	comm := &mocks.CommMock{}
 	commWG := sync.WaitGroup{}
 	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 		commWG.Done()
 	})
-	comm.On("Nodes").Return([]uint64{0, 1, 2, 3})
-
-	commWithChan := &mocks.CommMock{}
-	msgChan := make(chan *protos.Message)
-	commWithChan.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
-		msgChan <- args.Get(0).(*protos.Message)
+	comm.On("Nodes").Return([]uint64{11, 17, 23, 37})
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return(nil)
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
+		ID:    17,
+		Value: []byte{4},
 	})
-	commWithChan.On("Nodes").Return([]uint64{0, 1, 2, 3})
-
+	app := &mocks.ApplicationMock{}
+	appWG := sync.WaitGroup{}
+	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		appWG.Done()
+	}).Return(types.Reconfig{InLatestDecision: false})
 	reqPool := &mocks.RequestPool{}
+	reqPool.On("Prune", mock.Anything)
 	reqPool.On("Close")
 	leaderMon := &mocks.LeaderMonitor{}
-	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything)
 	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
 	leaderMon.On("HeartbeatWasSent")
 	leaderMon.On("Close")
 
-	signer := &mocks.SignerMock{}
-	signer.On("Sign", mock.Anything).Return(nil)
-
 	testDir, err := os.MkdirTemp("", "controller-unittest")
 	assert.NoErrorf(t, err, "generate temporary test dir")
 	defer os.RemoveAll(testDir)
@@ -763,813 +783,2593 @@ func TestSyncInform(t *testing.T) {
 	assert.NoError(t, err)
 
 	synchronizer := &mocks.SynchronizerMock{}
-	synchronizerWG := sync.WaitGroup{}
-	syncToView := uint64(2)
-	synchronizer.On("Sync").Run(func(args mock.Arguments) {
-		synchronizerWG.Done()
-	}).Return(types.SyncResponse{Latest: types.Decision{
-		Proposal: types.Proposal{
-			Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
-				LatestSequence: 1,
-				ViewId:         syncToView,
-			}),
-			VerificationSequence: 1,
-		},
-		Signatures: []types.Signature{
-			{ID: 1}, {ID: 2}, {ID: 3},
-		},
+	synchronizer.On("Sync").Run(func(args mock.Arguments) {}).Return(types.SyncResponse{Latest: types.Decision{
+		Proposal:   types.Proposal{VerificationSequence: 0},
+		Signatures: nil,
 	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}})
 
-	reqTimer := &mocks.RequestsTimer{}
-	reqTimer.On("StopTimers")
-	reqTimer.On("RestartTimers")
-	controllerMock := &mocks.ViewController{}
-	controllerMock.On("AbortView", mock.Anything)
-
 	collector := bft.StateCollector{
-		SelfID:         0,
+		SelfID:         11,
 		N:              4,
 		Logger:         log,
 		CollectTimeout: 100 * time.Millisecond,
 	}
 	collector.Start()
 
-	vc := &bft.ViewChanger{
-		SelfID:              2,
-		N:                   4,
-		NodesList:           []uint64{0, 1, 2, 3},
-		Logger:              log,
-		Comm:                commWithChan,
-		RequestsTimer:       reqTimer,
-		Ticker:              make(chan time.Time),
-		Controller:          controllerMock,
-		InMsqQSize:          100,
-		ControllerStartedWG: sync.WaitGroup{},
-		MetricsViewChange:   api.NewMetricsViewChange(&disabled.Provider{}),
-	}
-
-	vc.ControllerStartedWG.Add(1)
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
 
 	controller := &bft.Controller{
 		InFlight:      &bft.InFlightData{},
-		Signer:        signer,
+		RequestPool:   reqPool,
+		LeaderMonitor: leaderMon,
 		WAL:           wal,
-		ID:            2,
+		ID:            17, // the leader
 		N:             4,
-		NodesList:     []uint64{0, 1, 2, 3},
+		NodesList:     []uint64{11, 17, 23, 37},
 		Logger:        log,
 		Batcher:       batcher,
 		Verifier:      verifier,
 		Assembler:     assembler,
 		Comm:          comm,
-		RequestPool:   reqPool,
-		LeaderMonitor: leaderMon,
-		Synchronizer:  synchronizer,
-		ViewChanger:   vc,
+		Signer:        signer,
+		Application:   app,
 		Checkpoint:    &types.Checkpoint{},
+		ViewChanger:   &bft.ViewChanger{},
+		Synchronizer:  synchronizer,
 		Collector:     &collector,
-		StartedWG:     &vc.ControllerStartedWG,
+		StartedWG:     &startedWG,
+		MetricsView:   api.NewMetricsView(&disabled.Provider{}),
 	}
 	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
-	configureProposerBuilder(controller)
-
-	controller.Checkpoint.Set(proposal, []types.Signature{{ID: 1}, {ID: 2}, {ID: 3}})
 
-	vc.Start(1)
+	configureProposerBuilder(controller)
 
-	synchronizerWG.Add(1)
 	commWG.Add(9)
 	controller.Start(1, 0, 0, true)
-	synchronizerWG.Wait()
-	commWG.Wait()
+	commWG.Wait() // propose + state request
 
-	vc.StartViewChange(2, true)
-	msg := <-msgChan
-	assert.NotNil(t, msg.GetViewChange())
-	assert.Equal(t, syncToView+1, msg.GetViewChange().NextView) // view number did change according to info
+	commWG.Add(3)
+	controller.ProcessMessages(23, prepare)
+	controller.ProcessMessages(37, prepare)
+	commWG.Wait()
 
-	batcher.AssertNumberOfCalls(t, "NextBatch", 1)
-	assembler.AssertNumberOfCalls(t, "AssembleProposal", 1)
-	comm.AssertNumberOfCalls(t, "SendConsensus", 9)
+	commit23 := proto.Clone(commit2).(*protos.Message)
+	commit23Get := commit23.GetCommit()
+	commit23Get.Signature.Signer = 23
+	controller.ProcessMessages(23, commit23)
+	commit37 := proto.Clone(commit3).(*protos.Message)
+	commit37Get := commit37.GetCommit()
+	commit37Get.Signature.Signer = 37
+	appWG.Add(1)  // deliver
+	commWG.Add(6) // next proposal
+	controller.ProcessMessages(37, commit37)
+	appWG.Wait()
+	commWG.Wait()
+
+	controller.Stop()
+	app.AssertNumberOfCalls(t, "Deliver", 1)
 	leaderMon.AssertCalled(t, "HeartbeatWasSent")
 
+	// Ensure checkpoint was updated
+	expected := &protos.Proposal{
+		Header:               proposal.Header,
+		Payload:              proposal.Payload,
+		Metadata:             proposal.Metadata,
+		VerificationSequence: uint64(proposal.VerificationSequence),
+	}
+	proposal, signatures := controller.Checkpoint.Get()
+	assert.Equal(t, expected, proposal)
+	signaturesBySigners := make(map[uint64]*protos.Signature)
+	for _, sig := range signatures {
+		signaturesBySigners[sig.Signer] = sig
+	}
+	assert.Equal(t, map[uint64]*protos.Signature{
+		17: {Signer: 17, Value: []byte{4}},
+		23: {Signer: 23, Value: []byte{4}},
+		37: {Signer: 37, Value: []byte{4}},
+	}, signaturesBySigners)
+
 	controller.Stop()
-	vc.Stop()
 	collector.Stop()
 	wal.Close()
 }
 
-func TestRotateFromLeaderToFollower(t *testing.T) {
+func TestLeaderDelaysProposingAfterCatchingUp(t *testing.T) {
 	basicLog, err := zap.NewDevelopment()
 	assert.NoError(t, err)
 	log := basicLog.Sugar()
-
-	testDir, err := os.MkdirTemp("", "controller-unittest")
-	assert.NoErrorf(t, err, "generate temporary test dir")
-	defer os.RemoveAll(testDir)
-	wal, err := wal.Create(log, testDir, nil)
-	assert.NoError(t, err)
-	defer wal.Close()
-
-	var restartTimersWG sync.WaitGroup
-	restartTimersWG.Add(2)
-	reqPool := &mocks.RequestPool{}
-	reqPool.On("RestartTimers").Run(func(args mock.Arguments) {
-		restartTimersWG.Done()
-	})
-	reqPool.On("Prune", mock.Anything)
-	reqPool.On("Close")
-	leaderMon := &mocks.LeaderMonitor{}
-	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
-	leaderMonWG := sync.WaitGroup{}
-	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		leaderMonWG.Done()
-	})
-	leaderMon.On("HeartbeatWasSent")
-	leaderMon.On("InjectArtificialHeartbeat", uint64(3), mock.Anything)
-	leaderMon.On("Close")
 	req := []byte{1}
 	batcher := &mocks.Batcher{}
 	batcher.On("Close")
 	batcher.On("Closed").Return(false)
-	batcher.On("NextBatch").Return([][]byte{req}).Once()
+	batcher.On("NextBatch").Return([][]byte{req})
 	batcher.On("PopRemainder").Return([][]byte{})
 	batcher.On("BatchRemainder", mock.Anything)
 	verifier := &mocks.VerifierMock{}
-	verifier.On("VerifySignature", mock.Anything).Return(nil)
-	verifier.On("VerifyRequest", req).Return(types.RequestInfo{}, nil)
-	verifier.On("VerificationSequence").Return(uint64(1))
-	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
-	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
-	verifier.On("AuxiliaryData", mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
-		Ids: []uint64{1, 3},
-	}))
-
+	verifier.On("VerificationSequence").Return(uint64(0))
+	verifier.On("VerifyProposal", mock.Anything).Return(nil, nil)
 	assembler := &mocks.AssemblerMock{}
-	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(proposal, [][]byte{}).Once()
+	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(func(metadata []byte, _ [][]byte) types.Proposal {
+		return types.Proposal{Payload: proposal.Payload, Metadata: metadata}
+	})
 	comm := &mocks.CommMock{}
-	commWG := sync.WaitGroup{}
+	proposed := make(chan time.Time, 10)
 	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		commWG.Done()
+		if args.Get(1).(*protos.Message).GetPrePrepare() != nil {
+			proposed <- time.Now()
+		}
 	})
+	comm.On("Nodes").Return([]uint64{11, 17, 23, 37})
 	signer := &mocks.SignerMock{}
 	signer.On("Sign", mock.Anything).Return(nil)
-	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
-		ID:    2,
-		Value: []byte{4},
-	})
-	app := &mocks.ApplicationMock{}
-	appWG := sync.WaitGroup{}
-	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		appWG.Done()
-	}).Return(types.Reconfig{InLatestDecision: false})
+	reqPool := &mocks.RequestPool{}
+	reqPool.On("Close")
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMon.On("HeartbeatWasSent")
+	leaderMon.On("Close")
+
+	testDir, err := os.MkdirTemp("", "controller-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	wal, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+
+	// The node was behind the others, and catches up by syncing when it starts
+	synchronizer := &mocks.SynchronizerMock{}
+	synchronizer.On("Sync").Return(types.SyncResponse{Latest: types.Decision{Proposal: proposalOfSeq(5)}})
+
+	collector := bft.StateCollector{
+		SelfID:         17,
+		N:              4,
+		Logger:         log,
+		CollectTimeout: 100 * time.Millisecond,
+	}
+	collector.Start()
+	defer collector.Stop()
 
 	startedWG := sync.WaitGroup{}
 	startedWG.Add(1)
 
+	delay := time.Second
 	controller := &bft.Controller{
-		InFlight:           &bft.InFlightData{},
-		RequestPool:        reqPool,
-		LeaderMonitor:      leaderMon,
-		WAL:                wal,
-		ID:                 2, // the first leader
-		N:                  4,
-		NodesList:          []uint64{1, 2, 3, 4},
-		Logger:             log,
-		Batcher:            batcher,
-		Verifier:           verifier,
-		Assembler:          assembler,
-		Comm:               comm,
-		Signer:             signer,
-		Application:        app,
-		Checkpoint:         &types.Checkpoint{},
-		ViewChanger:        &bft.ViewChanger{},
-		StartedWG:          &startedWG,
-		LeaderRotation:     true,
-		DecisionsPerLeader: 1,
-		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
+		InFlight:      &bft.InFlightData{},
+		RequestPool:   reqPool,
+		LeaderMonitor: leaderMon,
+		WAL:           wal,
+		ID:            17, // the leader
+		N:             4,
+		NodesList:     []uint64{11, 17, 23, 37},
+		Logger:        log,
+		Batcher:       batcher,
+		Verifier:      verifier,
+		Assembler:     assembler,
+		Comm:          comm,
+		Signer:        signer,
+		Checkpoint:    &types.Checkpoint{},
+		ViewChanger:   &bft.ViewChanger{},
+		Synchronizer:  synchronizer,
+		Collector:     &collector,
+		StartedWG:     &startedWG,
+		MetricsView:   api.NewMetricsView(&disabled.Provider{}),
+		CatchUpDelay:  delay,
 	}
 	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
-	vs := configureProposerBuilder(controller)
-	controller.ViewSequences = vs
-
-	commWG.Add(6)
-	controller.Start(1, 0, 0, false)
-	commWG.Wait() // propose (pre-prepare + prepare)
 
-	commWG.Add(3)
-	controller.ProcessMessages(3, prepare)
-	controller.ProcessMessages(4, prepare)
-	commWG.Wait() // commit
+	configureProposerBuilder(controller)
 
-	controller.ProcessMessages(1, commit1)
-	appWG.Add(1)       // deliver
-	leaderMonWG.Add(1) // change role
-	controller.ProcessMessages(3, commit3)
-	appWG.Wait()
-	commWG.Wait()
-	leaderMonWG.Wait()
+	start := time.Now()
+	controller.Start(1, 0, 0, true)
+	assert.Equal(t, uint64(6), controller.CurrentProposalSequence())
+	assert.GreaterOrEqual(t, (<-proposed).Sub(start), delay)
 
-	// leader rotation (now 3 is the leader)
+	controller.Stop()
+	wal.Close()
+}
 
-	prePrepareNext := proto.Clone(prePrepare).(*protos.Message)
-	prePrepareNextGet := prePrepareNext.GetPrePrepare()
-	prePrepareNextGet.Seq = 1
-	prePrepareNextGet.GetProposal().Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
-		DecisionsInView: 1,
-		LatestSequence:  1,
-		ViewId:          1,
-	})
-	commWG.Add(3) // sending prepare
-	controller.ProcessMessages(3, prePrepareNext)
-	commWG.Wait()
+func TestDeliverClearsCommittedInFlight(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	app := &mocks.ApplicationMock{}
+	app.On("Deliver", mock.Anything, mock.Anything).Return(types.Reconfig{})
 
-	nextProp := types.Proposal{
-		Header:               prePrepareNextGet.Proposal.Header,
-		Payload:              prePrepareNextGet.Proposal.Payload,
-		Metadata:             prePrepareNextGet.Proposal.Metadata,
-		VerificationSequence: 1,
+	controller := &bft.Controller{
+		InFlight:    &bft.InFlightData{},
+		Checkpoint:  &types.Checkpoint{},
+		Logger:      log,
+		Application: app,
+		MetricsView: api.NewMetricsView(&disabled.Provider{}),
 	}
-	prepareNext := proto.Clone(prepare).(*protos.Message)
-	prepareNextGet := prepareNext.GetPrepare()
-	prepareNextGet.Seq = 1
-	prepareNextGet.Digest = nextProp.Digest()
-	commWG.Add(3) // sending commit
-	controller.ProcessMessages(3, prepareNext)
-	controller.ProcessMessages(4, prepareNext)
-	commWG.Wait()
-
-	commit1Next := proto.Clone(commit1).(*protos.Message)
-	commit1NextGet := commit1Next.GetCommit()
-	commit1NextGet.Seq = 1
-	commit1NextGet.Digest = nextProp.Digest()
-
-	commit3Next := proto.Clone(commit3).(*protos.Message)
-	commit3NextGet := commit3Next.GetCommit()
-	commit3NextGet.Seq = 1
-	commit3NextGet.Digest = nextProp.Digest()
+	controller.InFlight.StoreProposal(proposal)
+	controller.InFlight.StorePrepares(1, 0)
 
-	appWG.Add(1)
-	leaderMonWG.Add(1)
-	controller.ProcessMessages(1, commit1Next)
-	controller.ProcessMessages(3, commit3Next)
-	leaderMonWG.Wait()
-	restartTimersWG.Wait()
-	appWG.Wait()
-	app.AssertNumberOfCalls(t, "Deliver", 2)
+	deliver := &bft.MutuallyExclusiveDeliver{C: controller}
+	deliver.Deliver(proposal, []types.Signature{{ID: 1}})
 
-	controller.Stop()
+	// No prepares of the committed proposal linger
+	assert.Nil(t, controller.InFlight.InFlightProposal())
+	assert.False(t, controller.InFlight.IsInFlightPrepared())
+	app.AssertNumberOfCalls(t, "Deliver", 1)
 }
 
-func TestRotateFromFollowerToLeader(t *testing.T) {
+func TestViewChanged(t *testing.T) {
 	basicLog, err := zap.NewDevelopment()
 	assert.NoError(t, err)
 	log := basicLog.Sugar()
+	req := []byte{1}
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	batcher.On("Reset")
+	batcher.On("NextBatch").Return([][]byte{req})
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
 
-	testDir, err := os.MkdirTemp("", "controller-unittest")
-	assert.NoErrorf(t, err, "generate temporary test dir")
-	defer os.RemoveAll(testDir)
-	wal, err := wal.Create(log, testDir, nil)
-	assert.NoError(t, err)
-	defer wal.Close()
+	secondProposal := proposal
+	secondProposal.Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
+		LatestSequence: 0,
+		ViewId:         2,
+	})
 
-	var restartTimersWG sync.WaitGroup
-	restartTimersWG.Add(2)
-	reqPool := &mocks.RequestPool{}
-	reqPool.On("Prune", mock.Anything)
+	assembler := &mocks.AssemblerMock{}
+	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(secondProposal, [][]byte{}).Once()
+	comm := &mocks.CommMock{}
+	commWG := sync.WaitGroup{}
+	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		commWG.Done()
+	})
+	reqPool := &mocks.RequestPool{}
+	reqPool.On("Prune", mock.Anything)
 	reqPool.On("Close")
-	reqPool.On("RestartTimers").Run(func(args mock.Arguments) {
-		restartTimersWG.Done()
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything)
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMon.On("HeartbeatWasSent")
+	leaderMon.On("Close")
+
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return(nil)
+
+	testDir, err := os.MkdirTemp("", "controller-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	wal, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+
+	synchronizer := &mocks.SynchronizerMock{}
+	synchronizer.On("Sync").Run(func(args mock.Arguments) {}).Return(types.SyncResponse{Latest: types.Decision{
+		Proposal:   types.Proposal{VerificationSequence: 0},
+		Signatures: nil,
+	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}})
+
+	collector := bft.StateCollector{
+		SelfID:         1,
+		N:              4,
+		Logger:         log,
+		CollectTimeout: 100 * time.Millisecond,
+	}
+	collector.Start()
+
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
+
+	controller := &bft.Controller{
+		InFlight:      &bft.InFlightData{},
+		Checkpoint:    &types.Checkpoint{},
+		Signer:        signer,
+		WAL:           wal,
+		ID:            3, // the next leader
+		N:             4,
+		NodesList:     []uint64{1, 2, 3, 4},
+		Logger:        log,
+		Batcher:       batcher,
+		Verifier:      verifier,
+		Assembler:     assembler,
+		Comm:          comm,
+		RequestPool:   reqPool,
+		LeaderMonitor: leaderMon,
+		Synchronizer:  synchronizer,
+		Collector:     &collector,
+		StartedWG:     &startedWG,
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+
+	configureProposerBuilder(controller)
+
+	commWG.Add(3) // state request
+	controller.Start(1, 0, 0, true)
+	commWG.Wait()
+
+	commWG.Add(6) // propose
+	controller.ViewChanged(2, 0)
+	commWG.Wait()
+	batcher.AssertNumberOfCalls(t, "NextBatch", 1)
+	assembler.AssertNumberOfCalls(t, "AssembleProposal", 1)
+	comm.AssertNumberOfCalls(t, "SendConsensus", 9)
+	leaderMon.AssertCalled(t, "HeartbeatWasSent")
+	controller.Stop()
+	collector.Stop()
+	wal.Close()
+}
+
+func TestSyncPrevView(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	app := &mocks.ApplicationMock{}
+	appWG := sync.WaitGroup{}
+	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		appWG.Done()
+	}).Return(types.Reconfig{InLatestDecision: false})
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	pool := &mocks.RequestPool{}
+	pool.On("Close")
+	pool.On("Prune", mock.Anything)
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("InjectArtificialHeartbeat", mock.Anything, mock.Anything)
+	leaderMonWG := sync.WaitGroup{}
+	leaderMon.On("ChangeRole", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		leaderMonWG.Done()
+	})
+	leaderMon.On("Close")
+	comm := &mocks.CommMock{}
+	comm.On("SendConsensus", mock.Anything, mock.Anything)
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	signer := &mocks.SignerMock{}
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
+		ID:    4,
+		Value: []byte{4},
+	})
+	fd := &mocks.FailureDetector{}
+	fd.On("Complain", mock.Anything, mock.Anything)
+	synchronizer := &mocks.SynchronizerMock{}
+	synchronizerWG := sync.WaitGroup{}
+	synchronizer.On("Sync").Run(func(args mock.Arguments) {
+		synchronizerWG.Done()
+	}).Return(types.SyncResponse{Latest: types.Decision{
+		Proposal: types.Proposal{
+			Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
+				LatestSequence: 0,
+				ViewId:         0, // previous view number
+			}),
+			VerificationSequence: 1,
+		},
+		Signatures: nil,
+	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}})
+
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
+
+	testDir, err := os.MkdirTemp("", "controller-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	wal, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+
+	collector := bft.StateCollector{
+		SelfID:         0,
+		N:              4,
+		Logger:         log,
+		CollectTimeout: 100 * time.Millisecond,
+	}
+	collector.Start()
+	defer collector.Stop()
+
+	controller := &bft.Controller{
+		Collector:       &collector,
+		InFlight:        &bft.InFlightData{},
+		Batcher:         batcher,
+		RequestPool:     pool,
+		LeaderMonitor:   leaderMon,
+		ID:              4, // not the leader
+		N:               4,
+		NodesList:       []uint64{1, 2, 3, 4},
+		Logger:          log,
+		Application:     app,
+		Comm:            comm,
+		ViewChanger:     &bft.ViewChanger{},
+		Checkpoint:      &types.Checkpoint{},
+		FailureDetector: fd,
+		Synchronizer:    synchronizer,
+		Verifier:        verifier,
+		Signer:          signer,
+		WAL:             wal,
+		StartedWG:       &startedWG,
+		MetricsView:     api.NewMetricsView(&disabled.Provider{}),
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+
+	vs := configureProposerBuilder(controller)
+	controller.ViewSequences = vs
+
+	leaderMonWG.Add(1)
+	controller.Start(1, 0, 0, false)
+	leaderMonWG.Wait()
+
+	appWG.Add(1)
+	controller.ProcessMessages(2, prePrepare)
+	controller.ProcessMessages(2, prepare)
+	controller.ProcessMessages(3, prepare)
+	controller.ProcessMessages(2, commit2)
+	controller.ProcessMessages(3, commit3)
+
+	appWG.Wait()
+	app.AssertNumberOfCalls(t, "Deliver", 1)
+
+	synchronizerWG.Add(1)
+	leaderMonWG.Add(1)
+	wrongViewMsg := proto.Clone(prePrepare).(*protos.Message)
+	wrongViewMsgGet := wrongViewMsg.GetPrePrepare()
+	wrongViewMsgGet.View = 2
+	controller.ProcessMessages(2, wrongViewMsg)
+	synchronizerWG.Wait()
+	leaderMonWG.Wait() // wait for view to start before sending messages
+
+	prePrepareNext := proto.Clone(prePrepare).(*protos.Message)
+	prePrepareNextGet := prePrepareNext.GetPrePrepare()
+	prePrepareNextGet.Seq = 1
+	prePrepareNextGet.GetProposal().Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
+		DecisionsInView: 1,
+		LatestSequence:  1,
+		ViewId:          1,
+	})
+	controller.ProcessMessages(2, prePrepareNext)
+
+	nextProp := types.Proposal{
+		Header:               prePrepareNextGet.Proposal.Header,
+		Payload:              prePrepareNextGet.Proposal.Payload,
+		Metadata:             prePrepareNextGet.Proposal.Metadata,
+		VerificationSequence: 1,
+	}
+	prepareNext := proto.Clone(prepare).(*protos.Message)
+	prepareNextGet := prepareNext.GetPrepare()
+	prepareNextGet.Seq = 1
+	prepareNextGet.Digest = nextProp.Digest()
+	controller.ProcessMessages(2, prepareNext)
+	controller.ProcessMessages(3, prepareNext)
+
+	commit2Next := proto.Clone(commit2).(*protos.Message)
+	commit2NextGet := commit2Next.GetCommit()
+	commit2NextGet.Seq = 1
+	commit2NextGet.Digest = nextProp.Digest()
+
+	commit3Next := proto.Clone(commit3).(*protos.Message)
+	commit3NextGet := commit3Next.GetCommit()
+	commit3NextGet.Seq = 1
+	commit3NextGet.Digest = nextProp.Digest()
+
+	appWG.Add(1)
+	controller.ProcessMessages(2, commit2Next)
+	controller.ProcessMessages(3, commit3Next)
+
+	appWG.Wait()
+	app.AssertNumberOfCalls(t, "Deliver", 2)
+
+	controller.Stop()
+	wal.Close()
+}
+
+func TestControllerLeaderRequestHandling(t *testing.T) {
+	for _, testCase := range []struct {
+		description      string
+		startViewNum     uint64
+		verifyReqReturns error
+		shouldEnqueue    bool
+		shouldVerify     bool
+		waitForLoggedMsg string
+	}{
+		{
+			description:      "not the leader",
+			startViewNum:     2,
+			waitForLoggedMsg: "Got request from 3 but the leader is 2, dropping request",
+		},
+		{
+			description:      "bad request",
+			startViewNum:     1,
+			verifyReqReturns: errors.New("unauthorized user"),
+			waitForLoggedMsg: "unauthorized user",
+			shouldVerify:     true,
+		},
+		{
+			description:      "good request",
+			shouldEnqueue:    true,
+			startViewNum:     1,
+			waitForLoggedMsg: "Got request from 3",
+			shouldVerify:     true,
+		},
+	} {
+		t.Run(testCase.description, func(t *testing.T) {
+			var submittedToPool sync.WaitGroup
+
+			basicLog, err := zap.NewDevelopment()
+			assert.NoError(t, err)
+
+			log := basicLog.Sugar()
+
+			batcher := &mocks.Batcher{}
+			batcher.On("Close")
+			batcher.On("Closed").Return(false)
+			batcher.On("Reset")
+			batcher.On("NextBatch").Run(func(arguments mock.Arguments) {
+				time.Sleep(time.Hour)
+			})
+
+			pool := &mocks.RequestPool{}
+			pool.On("Close")
+			leaderMon := &mocks.LeaderMonitor{}
+			leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything)
+			leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+			leaderMon.On("Close")
+			if testCase.shouldEnqueue {
+				submittedToPool.Add(1)
+				pool.On("Submit", mock.Anything).Return(nil).Run(func(_ mock.Arguments) {
+					submittedToPool.Done()
+				})
+			}
+
+			commMock := &mocks.CommMock{}
+			commMock.On("SendConsensus", mock.Anything, mock.Anything)
+
+			verifier := &mocks.VerifierMock{}
+			verifier.On("VerifyRequest", mock.Anything).Return(types.RequestInfo{}, testCase.verifyReqReturns)
+			verifier.On("VerificationSequence").Return(uint64(0))
+
+			synchronizer := &mocks.SynchronizerMock{}
+			synchronizer.On("Sync").Run(func(args mock.Arguments) {}).Return(types.SyncResponse{Latest: types.Decision{
+				Proposal:   types.Proposal{VerificationSequence: 0},
+				Signatures: nil,
+			}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}})
+
+			collector := bft.StateCollector{
+				SelfID:         0,
+				N:              4,
+				Logger:         log,
+				CollectTimeout: 100 * time.Millisecond,
+			}
+			collector.Start()
+			defer collector.Stop()
+
+			startedWG := sync.WaitGroup{}
+			startedWG.Add(1)
+
+			controller := &bft.Controller{
+				InFlight:      &bft.InFlightData{},
+				Checkpoint:    &types.Checkpoint{},
+				RequestPool:   pool,
+				LeaderMonitor: leaderMon,
+				ID:            1,
+				N:             4,
+				NodesList:     []uint64{0, 1, 2, 3},
+				Logger:        log,
+				Batcher:       batcher,
+				Comm:          commMock,
+				Verifier:      verifier,
+				Synchronizer:  synchronizer,
+				Collector:     &collector,
+				StartedWG:     &startedWG,
+			}
+			controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+
+			configureProposerBuilder(controller)
+			controller.Start(testCase.startViewNum, 0, 0, true)
+
+			controller.HandleRequest(3, []byte{1, 2, 3})
+
+			submittedToPool.Wait()
+
+			if !testCase.shouldVerify {
+				verifier.AssertNotCalled(t, "VerifyRequest", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestControllerForwardedRequestsQuota(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{QueueSize: 10}, make(chan struct{}, 10))
+	defer pool.Close()
+
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifyRequest", mock.Anything).Return(func(req []byte) types.RequestInfo {
+		return insp.RequestID(req)
+	}, func(req []byte) error {
+		if _, _, data := parseTestRequest(req); data == "invalid" {
+			return errors.New("invalid request")
+		}
+		return nil
+	})
+
+	verifier.On("VerificationSequence").Return(uint64(0))
+
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	batcher.On("Reset")
+	batcher.On("NextBatch").Run(func(arguments mock.Arguments) {
+		time.Sleep(time.Hour)
+	})
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMon.On("Close")
+	commMock := &mocks.CommMock{}
+	commMock.On("SendConsensus", mock.Anything, mock.Anything)
+
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
+
+	controller := &bft.Controller{
+		InFlight:      &bft.InFlightData{},
+		Checkpoint:    &types.Checkpoint{},
+		RequestPool:   pool,
+		LeaderMonitor: leaderMon,
+		ID:            1,
+		N:             4,
+		NodesList:     []uint64{0, 1, 2, 3},
+		Logger:        log,
+		Batcher:       batcher,
+		Comm:          commMock,
+		Verifier:      verifier,
+		StartedWG:     &startedWG,
+		ForwardQuota:  2,
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+	configureProposerBuilder(controller)
+	controller.Start(1, 0, 0, false)
+	defer controller.Stop()
+
+	// Invalid requests don't enter the pool
+	controller.HandleRequest(3, makeTestRequest("alice", "1", "invalid"))
+	assert.Equal(t, 0, pool.Size())
+
+	// Node 3 can't have more than 2 forwarded requests in the pool
+	for i := 1; i <= 3; i++ {
+		controller.HandleRequest(3, makeTestRequest("alice", strconv.Itoa(i), "foo"))
+	}
+	assert.Equal(t, 2, pool.Size())
+	assert.False(t, pool.Contains(types.RequestInfo{ClientID: "alice", ID: "3"}))
+
+	// The quota of node 3 doesn't affect node 2
+	controller.HandleRequest(2, makeTestRequest("bob", "1", "foo"))
+	assert.Equal(t, 3, pool.Size())
+
+	// Once a request of node 3 leaves the pool, it can forward another one
+	assert.NoError(t, pool.RemoveRequest(types.RequestInfo{ClientID: "alice", ID: "1"}))
+	controller.HandleRequest(3, makeTestRequest("alice", "3", "foo"))
+	assert.True(t, pool.Contains(types.RequestInfo{ClientID: "alice", ID: "3"}))
+	assert.Equal(t, 3, pool.Size())
+}
+
+// clientSignatureVerifier accepts the requests whose data is signed by their client
+type clientSignatureVerifier struct{}
+
+func (clientSignatureVerifier) VerifyClientSignature(req []byte) error {
+	clientID, _, data := parseTestRequest(req)
+	if data != "signed-by-"+clientID {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+func TestControllerVerifiesClientSignatures(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{QueueSize: 10}, make(chan struct{}, 10))
+	defer pool.Close()
+
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifyRequest", mock.Anything).Return(func(req []byte) types.RequestInfo {
+		return insp.RequestID(req)
+	}, nil)
+	verifier.On("VerificationSequence").Return(uint64(0))
+
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	batcher.On("Reset")
+	batcher.On("NextBatch").Run(func(arguments mock.Arguments) {
+		time.Sleep(time.Hour)
+	})
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMon.On("Close")
+	commMock := &mocks.CommMock{}
+	commMock.On("SendConsensus", mock.Anything, mock.Anything)
+
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
+
+	controller := &bft.Controller{
+		InFlight:          &bft.InFlightData{},
+		Checkpoint:        &types.Checkpoint{},
+		RequestPool:       pool,
+		LeaderMonitor:     leaderMon,
+		ID:                1,
+		N:                 4,
+		NodesList:         []uint64{0, 1, 2, 3},
+		Logger:            log,
+		Batcher:           batcher,
+		Comm:              commMock,
+		Verifier:          verifier,
+		RequestInspector:  insp,
+		ClientSigVerifier: clientSignatureVerifier{},
+		StartedWG:         &startedWG,
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+	configureProposerBuilder(controller)
+	controller.Start(1, 0, 0, false)
+	defer controller.Stop()
+
+	// Requests that are unsigned or signed by someone other than their client are rejected
+	assert.ErrorContains(t, controller.SubmitRequest(makeTestRequest("alice", "1", "foo")), "invalid client signature")
+	assert.ErrorContains(t, controller.SubmitRequest(makeTestRequest("alice", "2", "signed-by-bob")), "invalid client signature")
+	assert.Error(t, controller.SubmitBatch([][]byte{
+		makeTestRequest("alice", "3", "signed-by-alice"),
+		makeTestRequest("alice", "4", "signed-by-bob"),
+	}))
+	controller.HandleRequest(3, makeTestRequest("alice", "5", "signed-by-bob"))
+	assert.Equal(t, 0, pool.Size())
+
+	// Requests signed by their client are accepted
+	assert.NoError(t, controller.SubmitRequest(makeTestRequest("alice", "1", "signed-by-alice")))
+	controller.HandleRequest(3, makeTestRequest("bob", "1", "signed-by-bob"))
+	assert.Equal(t, 2, pool.Size())
+}
+
+func TestControllerForwardedRequestMaxAge(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	// The follower forwards a request submitted an hour ago and a request submitted just now
+	followerPool := &mocks.RequestPool{}
+	followerPool.On("SubmissionTime", types.RequestInfo{ClientID: "alice", ID: "1"}).Return(time.Now().Add(-time.Hour), true)
+	followerPool.On("SubmissionTime", types.RequestInfo{ClientID: "alice", ID: "2"}).Return(time.Now(), true)
+	followerPool.On("SubmissionTime", types.RequestInfo{ClientID: "alice", ID: "3"}).Return(time.Now().Add(time.Hour), true)
+	var forwarded [][]byte
+	followerComm := &mocks.CommMock{}
+	followerComm.On("SendTransaction", uint64(1), mock.Anything).Run(func(args mock.Arguments) {
+		forwarded = append(forwarded, args.Get(1).([]byte))
+	})
+	follower := &bft.Controller{
+		Checkpoint:    &types.Checkpoint{},
+		RequestPool:   followerPool,
+		ID:            2,
+		N:             4,
+		NodesList:     []uint64{1, 2, 3, 4},
+		Logger:        log,
+		Comm:          followerComm,
+		ForwardMaxAge: time.Minute,
+	}
+	stale := makeTestRequest("alice", "1", "foo")
+	fresh := makeTestRequest("alice", "2", "foo")
+	follower.OnRequestTimeout(stale, types.RequestInfo{ClientID: "alice", ID: "1"})
+	follower.OnRequestTimeout(fresh, types.RequestInfo{ClientID: "alice", ID: "2"})
+	// The clock of the follower runs an hour ahead
+	future := makeTestRequest("alice", "3", "foo")
+	follower.OnRequestTimeout(future, types.RequestInfo{ClientID: "alice", ID: "3"})
+	assert.Len(t, forwarded, 3)
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{QueueSize: 10}, make(chan struct{}, 10))
+	defer pool.Close()
+
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifyRequest", mock.Anything).Return(func(req []byte) types.RequestInfo {
+		return insp.RequestID(req)
+	}, nil)
+	verifier.On("VerificationSequence").Return(uint64(0))
+
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	batcher.On("Reset")
+	batcher.On("NextBatch").Run(func(arguments mock.Arguments) {
+		time.Sleep(time.Hour)
+	})
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMon.On("Close")
+	commMock := &mocks.CommMock{}
+	commMock.On("SendConsensus", mock.Anything, mock.Anything)
+
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
+
+	leader := &bft.Controller{
+		InFlight:      &bft.InFlightData{},
+		Checkpoint:    &types.Checkpoint{},
+		RequestPool:   pool,
+		LeaderMonitor: leaderMon,
+		ID:            1,
+		N:             4,
+		NodesList:     []uint64{1, 2, 3, 4},
+		Logger:        log,
+		Batcher:       batcher,
+		Comm:          commMock,
+		Verifier:      verifier,
+		StartedWG:     &startedWG,
+		ForwardMaxAge: time.Minute,
+	}
+	leader.Deliver = &bft.MutuallyExclusiveDeliver{C: leader}
+	configureProposerBuilder(leader)
+	leader.Start(0, 0, 0, false)
+	defer leader.Stop()
+
+	// The stale request is dropped, and the fresh one enters the pool
+	leader.HandleRequest(2, forwarded[0])
+	assert.Equal(t, 0, pool.Size())
+	leader.HandleRequest(2, forwarded[1])
+	assert.Equal(t, 1, pool.Size())
+	assert.True(t, pool.Contains(types.RequestInfo{ClientID: "alice", ID: "2"}))
+
+	// A request submitted too far in the future is dropped
+	leader.HandleRequest(2, forwarded[2])
+	assert.Equal(t, 1, pool.Size())
+
+	// A request forwarded without its submission time, as during a rolling upgrade, enters the pool
+	leader.HandleRequest(2, makeTestRequest("alice", "4", "foo"))
+	assert.Equal(t, 2, pool.Size())
+	assert.True(t, pool.Contains(types.RequestInfo{ClientID: "alice", ID: "4"}))
+}
+
+func TestControllerRelayKeepsSubmissionTime(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	info := types.RequestInfo{ClientID: "alice", ID: "1"}
+	req := makeTestRequest("alice", "1", "foo")
+	submitted := time.Now().Add(-30 * time.Second)
+
+	// The follower forwards a request submitted half a minute ago to the leader, and to the next leader candidate
+	followerPool := &mocks.RequestPool{}
+	followerPool.On("SubmissionTime", info).Return(submitted, true)
+	forwarded := make(map[uint64][]byte)
+	followerComm := &mocks.CommMock{}
+	followerComm.On("SendTransaction", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		forwarded[args.Get(0).(uint64)] = args.Get(1).([]byte)
+	})
+	follower := &bft.Controller{
+		Checkpoint:    &types.Checkpoint{},
+		RequestPool:   followerPool,
+		ID:            2,
+		N:             4,
+		NodesList:     []uint64{1, 2, 3, 4},
+		Logger:        log,
+		Comm:          followerComm,
+		ForwardMaxAge: time.Minute,
+		ForwardToNext: true,
+	}
+	follower.OnRequestTimeout(req, info)
+	assert.Contains(t, forwarded, uint64(3))
+
+	insp := &testRequestInspector{}
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifyRequest", mock.Anything).Return(func(req []byte) types.RequestInfo {
+		return insp.RequestID(req)
+	}, nil)
+
+	// The next leader candidate relays the request to the leader once it times out, with the time it was first submitted
+	pool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{QueueSize: 10, ForwardTimeout: time.Hour}, make(chan struct{}, 10))
+	defer pool.Close()
+	relayed := make(map[uint64][]byte)
+	relayComm := &mocks.CommMock{}
+	relayComm.On("SendTransaction", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		relayed[args.Get(0).(uint64)] = args.Get(1).([]byte)
+	})
+	relay := &bft.Controller{
+		Checkpoint:    &types.Checkpoint{},
+		RequestPool:   pool,
+		ID:            3,
+		N:             4,
+		NodesList:     []uint64{1, 2, 3, 4},
+		Logger:        log,
+		Comm:          relayComm,
+		Verifier:      verifier,
+		ForwardMaxAge: time.Minute,
+		ForwardToNext: true,
+	}
+	relay.HandleRequest(2, forwarded[3])
+	relaySubmitted, exists := pool.SubmissionTime(info)
+	assert.True(t, exists)
+	assert.WithinDuration(t, submitted, relaySubmitted, time.Millisecond)
+	relay.OnRequestTimeout(req, info)
+	assert.Contains(t, relayed, uint64(1))
+
+	// A node with a maximal age shorter than the age of the request drops it, although it was relayed just now
+	checkerPool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{QueueSize: 10, ForwardTimeout: time.Hour}, make(chan struct{}, 10))
+	defer checkerPool.Close()
+	checker := &bft.Controller{
+		Checkpoint:    &types.Checkpoint{},
+		RequestPool:   checkerPool,
+		ID:            4,
+		N:             4,
+		NodesList:     []uint64{1, 2, 3, 4},
+		Logger:        log,
+		Verifier:      verifier,
+		ForwardMaxAge: 20 * time.Second,
+		ForwardToNext: true,
+	}
+	checker.HandleRequest(3, relayed[1])
+	assert.Equal(t, 0, checkerPool.Size())
+}
+
+func createView(c *bft.Controller, leader, proposalSequence, viewNum, decisionsInView uint64, quorumSize int, vs *atomic.Value) *bft.View {
+	mn := &mocks.MembershipNotifierMock{}
+	mn.On("MembershipChange").Return(false)
+	return &bft.View{
+		RetrieveCheckpoint: c.Checkpoint.Get,
+		N:                  c.N,
+		NodesList:          c.NodesList,
+		LeaderID:           leader,
+		SelfID:             c.ID,
+		Quorum:             quorumSize,
+		Number:             viewNum,
+		Decider:            c,
+		FailureDetector:    c.FailureDetector,
+		Sync:               c,
+		Logger:             c.Logger,
+		Comm:               c,
+		Verifier:           c.Verifier,
+		Signer:             c.Signer,
+		MembershipNotifier: mn,
+		ProposalSequence:   proposalSequence,
+		DecisionsInView:    decisionsInView,
+		ViewSequences:      vs,
+		State:              &bft.PersistedState{WAL: c.WAL, InFlightProposal: &bft.InFlightData{}},
+		InMsgQSize:         int(c.N * 10),
+		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
+		MetricsBlacklist:   api.NewMetricsBlacklist(&disabled.Provider{}),
+	}
+}
+
+func configureProposerBuilder(controller *bft.Controller) *atomic.Value {
+	pb := &mocks.ProposerBuilder{}
+	vs := &atomic.Value{}
+	pb.On("NewProposer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(a uint64, b uint64, c uint64, d uint64, e int) bft.Proposer {
+			return createView(controller, a, b, c, d, e, vs)
+		}, bft.Phase(bft.COMMITTED))
+	controller.ProposerBuilder = pb
+	return vs
+}
+
+func TestSyncInform(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	req := []byte{1}
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	batcher.On("Reset")
+	batcher.On("NextBatch").Return([][]byte{req})
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("AuxiliaryData", mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
+		Ids: []uint64{1},
+	}))
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
+		Ids: []uint64{1},
+	}), nil)
+
+	secondProposal := proposal
+	secondProposal.Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
+		DecisionsInView: 1,
+		LatestSequence:  2,
+		ViewId:          2,
+	})
+
+	assembler := &mocks.AssemblerMock{}
+	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(secondProposal).Once()
+
+	comm := &mocks.CommMock{}
+	commWG := sync.WaitGroup{}
+	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		commWG.Done()
+	})
+	comm.On("Nodes").Return([]uint64{0, 1, 2, 3})
+
+	commWithChan := &mocks.CommMock{}
+	msgChan := make(chan *protos.Message)
+	commWithChan.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		msgChan <- args.Get(0).(*protos.Message)
+	})
+	commWithChan.On("Nodes").Return([]uint64{0, 1, 2, 3})
+
+	reqPool := &mocks.RequestPool{}
+	reqPool.On("Close")
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything)
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMon.On("HeartbeatWasSent")
+	leaderMon.On("Close")
+
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return(nil)
+
+	testDir, err := os.MkdirTemp("", "controller-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	wal, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+
+	synchronizer := &mocks.SynchronizerMock{}
+	synchronizerWG := sync.WaitGroup{}
+	syncToView := uint64(2)
+	synchronizer.On("Sync").Run(func(args mock.Arguments) {
+		synchronizerWG.Done()
+	}).Return(types.SyncResponse{Latest: types.Decision{
+		Proposal: types.Proposal{
+			Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
+				LatestSequence: 1,
+				ViewId:         syncToView,
+			}),
+			VerificationSequence: 1,
+		},
+		Signatures: []types.Signature{
+			{ID: 1}, {ID: 2}, {ID: 3},
+		},
+	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}})
+
+	reqTimer := &mocks.RequestsTimer{}
+	reqTimer.On("StopTimers")
+	reqTimer.On("RestartTimers")
+	controllerMock := &mocks.ViewController{}
+	controllerMock.On("AbortView", mock.Anything)
+
+	collector := bft.StateCollector{
+		SelfID:         0,
+		N:              4,
+		Logger:         log,
+		CollectTimeout: 100 * time.Millisecond,
+	}
+	collector.Start()
+
+	vc := &bft.ViewChanger{
+		SelfID:              2,
+		N:                   4,
+		NodesList:           []uint64{0, 1, 2, 3},
+		Logger:              log,
+		Comm:                commWithChan,
+		RequestsTimer:       reqTimer,
+		Ticker:              make(chan time.Time),
+		Controller:          controllerMock,
+		InMsqQSize:          100,
+		ControllerStartedWG: sync.WaitGroup{},
+		MetricsViewChange:   api.NewMetricsViewChange(&disabled.Provider{}),
+	}
+
+	vc.ControllerStartedWG.Add(1)
+
+	controller := &bft.Controller{
+		InFlight:      &bft.InFlightData{},
+		Signer:        signer,
+		WAL:           wal,
+		ID:            2,
+		N:             4,
+		NodesList:     []uint64{0, 1, 2, 3},
+		Logger:        log,
+		Batcher:       batcher,
+		Verifier:      verifier,
+		Assembler:     assembler,
+		Comm:          comm,
+		RequestPool:   reqPool,
+		LeaderMonitor: leaderMon,
+		Synchronizer:  synchronizer,
+		ViewChanger:   vc,
+		Checkpoint:    &types.Checkpoint{},
+		Collector:     &collector,
+		StartedWG:     &vc.ControllerStartedWG,
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+	configureProposerBuilder(controller)
+
+	controller.Checkpoint.Set(proposal, []types.Signature{{ID: 1}, {ID: 2}, {ID: 3}})
+
+	vc.Start(1)
+
+	synchronizerWG.Add(1)
+	commWG.Add(9)
+	controller.Start(1, 0, 0, true)
+	synchronizerWG.Wait()
+	commWG.Wait()
+
+	vc.StartViewChange(2, true)
+	msg := <-msgChan
+	assert.NotNil(t, msg.GetViewChange())
+	assert.Equal(t, syncToView+1, msg.GetViewChange().NextView) // view number did change according to info
+
+	batcher.AssertNumberOfCalls(t, "NextBatch", 1)
+	assembler.AssertNumberOfCalls(t, "AssembleProposal", 1)
+	comm.AssertNumberOfCalls(t, "SendConsensus", 9)
+	leaderMon.AssertCalled(t, "HeartbeatWasSent")
+
+	controller.Stop()
+	vc.Stop()
+	collector.Stop()
+	wal.Close()
+}
+
+func TestRotateFromLeaderToFollower(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	testDir, err := os.MkdirTemp("", "controller-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	wal, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+	defer wal.Close()
+
+	var restartTimersWG sync.WaitGroup
+	restartTimersWG.Add(2)
+	reqPool := &mocks.RequestPool{}
+	reqPool.On("RestartTimers").Run(func(args mock.Arguments) {
+		restartTimersWG.Done()
+	})
+	reqPool.On("Prune", mock.Anything)
+	reqPool.On("Close")
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMonWG := sync.WaitGroup{}
+	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		leaderMonWG.Done()
+	})
+	leaderMon.On("HeartbeatWasSent")
+	leaderMon.On("InjectArtificialHeartbeat", uint64(3), mock.Anything)
+	leaderMon.On("Close")
+	req := []byte{1}
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	batcher.On("NextBatch").Return([][]byte{req}).Once()
+	batcher.On("PopRemainder").Return([][]byte{})
+	batcher.On("BatchRemainder", mock.Anything)
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	verifier.On("VerifyRequest", req).Return(types.RequestInfo{}, nil)
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("AuxiliaryData", mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
+		Ids: []uint64{1, 3},
+	}))
+
+	assembler := &mocks.AssemblerMock{}
+	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(proposal, [][]byte{}).Once()
+	comm := &mocks.CommMock{}
+	commWG := sync.WaitGroup{}
+	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		commWG.Done()
+	})
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return(nil)
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
+		ID:    2,
+		Value: []byte{4},
+	})
+	app := &mocks.ApplicationMock{}
+	appWG := sync.WaitGroup{}
+	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		appWG.Done()
+	}).Return(types.Reconfig{InLatestDecision: false})
+
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
+
+	controller := &bft.Controller{
+		InFlight:           &bft.InFlightData{},
+		RequestPool:        reqPool,
+		LeaderMonitor:      leaderMon,
+		WAL:                wal,
+		ID:                 2, // the first leader
+		N:                  4,
+		NodesList:          []uint64{1, 2, 3, 4},
+		Logger:             log,
+		Batcher:            batcher,
+		Verifier:           verifier,
+		Assembler:          assembler,
+		Comm:               comm,
+		Signer:             signer,
+		Application:        app,
+		Checkpoint:         &types.Checkpoint{},
+		ViewChanger:        &bft.ViewChanger{},
+		StartedWG:          &startedWG,
+		LeaderRotation:     true,
+		DecisionsPerLeader: 1,
+		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+	vs := configureProposerBuilder(controller)
+	controller.ViewSequences = vs
+
+	commWG.Add(6)
+	controller.Start(1, 0, 0, false)
+	commWG.Wait() // propose (pre-prepare + prepare)
+
+	commWG.Add(3)
+	controller.ProcessMessages(3, prepare)
+	controller.ProcessMessages(4, prepare)
+	commWG.Wait() // commit
+
+	controller.ProcessMessages(1, commit1)
+	appWG.Add(1)       // deliver
+	leaderMonWG.Add(1) // change role
+	controller.ProcessMessages(3, commit3)
+	appWG.Wait()
+	commWG.Wait()
+	leaderMonWG.Wait()
+
+	// leader rotation (now 3 is the leader)
+
+	prePrepareNext := proto.Clone(prePrepare).(*protos.Message)
+	prePrepareNextGet := prePrepareNext.GetPrePrepare()
+	prePrepareNextGet.Seq = 1
+	prePrepareNextGet.GetProposal().Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
+		DecisionsInView: 1,
+		LatestSequence:  1,
+		ViewId:          1,
+	})
+	commWG.Add(3) // sending prepare
+	controller.ProcessMessages(3, prePrepareNext)
+	commWG.Wait()
+
+	nextProp := types.Proposal{
+		Header:               prePrepareNextGet.Proposal.Header,
+		Payload:              prePrepareNextGet.Proposal.Payload,
+		Metadata:             prePrepareNextGet.Proposal.Metadata,
+		VerificationSequence: 1,
+	}
+	prepareNext := proto.Clone(prepare).(*protos.Message)
+	prepareNextGet := prepareNext.GetPrepare()
+	prepareNextGet.Seq = 1
+	prepareNextGet.Digest = nextProp.Digest()
+	commWG.Add(3) // sending commit
+	controller.ProcessMessages(3, prepareNext)
+	controller.ProcessMessages(4, prepareNext)
+	commWG.Wait()
+
+	commit1Next := proto.Clone(commit1).(*protos.Message)
+	commit1NextGet := commit1Next.GetCommit()
+	commit1NextGet.Seq = 1
+	commit1NextGet.Digest = nextProp.Digest()
+
+	commit3Next := proto.Clone(commit3).(*protos.Message)
+	commit3NextGet := commit3Next.GetCommit()
+	commit3NextGet.Seq = 1
+	commit3NextGet.Digest = nextProp.Digest()
+
+	appWG.Add(1)
+	leaderMonWG.Add(1)
+	controller.ProcessMessages(1, commit1Next)
+	controller.ProcessMessages(3, commit3Next)
+	leaderMonWG.Wait()
+	restartTimersWG.Wait()
+	appWG.Wait()
+	app.AssertNumberOfCalls(t, "Deliver", 2)
+
+	controller.Stop()
+}
+
+func TestRotateFromFollowerToLeader(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	testDir, err := os.MkdirTemp("", "controller-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	wal, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+	defer wal.Close()
+
+	var restartTimersWG sync.WaitGroup
+	restartTimersWG.Add(2)
+	reqPool := &mocks.RequestPool{}
+	reqPool.On("Prune", mock.Anything)
+	reqPool.On("Close")
+	reqPool.On("RestartTimers").Run(func(args mock.Arguments) {
+		restartTimersWG.Done()
+	})
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMonWG := sync.WaitGroup{}
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		leaderMonWG.Done()
+	})
+	followerMonWG := sync.WaitGroup{}
+	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		followerMonWG.Done()
+	})
+	leaderMon.On("HeartbeatWasSent")
+	leaderMon.On("InjectArtificialHeartbeat", uint64(2), mock.Anything)
+	leaderMon.On("Close")
+	req := []byte{1}
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Reset")
+	batcher.On("Closed").Return(false)
+	batcher.On("NextBatch").Return([][]byte{req}).Once()
+	batcher.On("PopRemainder").Return([][]byte{})
+	batcher.On("BatchRemainder", mock.Anything)
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	verifier.On("VerifyRequest", req).Return(types.RequestInfo{}, nil)
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("AuxiliaryData", mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
+		Ids: []uint64{1, 3},
+	}))
+
+	nextMD := bft.MarshalOrPanic(&protos.ViewMetadata{
+		DecisionsInView: 1,
+		LatestSequence:  1,
+		ViewId:          1,
+		PrevCommitSignatureDigest: bft.CommitSignaturesDigest([]*protos.Signature{
+			commit1.GetCommit().Signature,
+			commit2.GetCommit().Signature,
+			commit3.GetCommit().Signature,
+		}),
+	})
+	nextProp := types.Proposal{
+		Header:               proposal.Header,
+		Payload:              proposal.Payload,
+		Metadata:             nextMD,
+		VerificationSequence: 1,
+	}
+
+	assembler := &mocks.AssemblerMock{}
+	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(nextProp, [][]byte{}).Once()
+	comm := &mocks.CommMock{}
+	commWG := sync.WaitGroup{}
+	comm.On("Nodes").Return([]uint64{1, 2, 3, 4})
+	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		commWG.Done()
+	})
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return(nil)
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
+		ID:    3,
+		Value: []byte{4},
+	})
+	app := &mocks.ApplicationMock{}
+	appWG := sync.WaitGroup{}
+	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		appWG.Done()
+	}).Return(types.Reconfig{InLatestDecision: false})
+
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
+
+	controller := &bft.Controller{
+		InFlight:           &bft.InFlightData{},
+		RequestPool:        reqPool,
+		LeaderMonitor:      leaderMon,
+		WAL:                wal,
+		ID:                 3, // the second leader
+		N:                  4,
+		NodesList:          []uint64{1, 2, 3, 4},
+		Logger:             log,
+		Batcher:            batcher,
+		Verifier:           verifier,
+		Assembler:          assembler,
+		Comm:               comm,
+		Signer:             signer,
+		Application:        app,
+		Checkpoint:         &types.Checkpoint{},
+		ViewChanger:        &bft.ViewChanger{},
+		StartedWG:          &startedWG,
+		LeaderRotation:     true,
+		DecisionsPerLeader: 1,
+		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+	vs := configureProposerBuilder(controller)
+	controller.ViewSequences = vs
+
+	followerMonWG.Add(1) // change role
+	controller.Start(1, 0, 0, false)
+	followerMonWG.Wait()
+
+	commWG.Add(3)
+	controller.ProcessMessages(2, prePrepare)
+	commWG.Wait() // prepare
+
+	commWG.Add(3)
+	controller.ProcessMessages(1, prepare)
+	controller.ProcessMessages(4, prepare)
+	commWG.Wait() // commit
+
+	controller.ProcessMessages(1, commit1)
+	time.Sleep(time.Second)
+	appWG.Add(1)       // deliver
+	leaderMonWG.Add(1) // change role
+	commWG.Add(6)      // propose + prepare
+	controller.ProcessMessages(2, commit2)
+	appWG.Wait()
+	leaderMonWG.Wait()
+	commWG.Wait()
+
+	// leader rotation (now 3 is the leader)
+
+	prepareNext := proto.Clone(prepare).(*protos.Message)
+	prepareNextGet := prepareNext.GetPrepare()
+	prepareNextGet.Seq = 1
+	prepareNextGet.Digest = nextProp.Digest()
+	commWG.Add(3) // sending commit
+	controller.ProcessMessages(1, prepareNext)
+	controller.ProcessMessages(4, prepareNext)
+	commWG.Wait()
+
+	commit1Next := proto.Clone(commit1).(*protos.Message)
+	commit1NextGet := commit1Next.GetCommit()
+	commit1NextGet.Seq = 1
+	commit1NextGet.Digest = nextProp.Digest()
+
+	commit2Next := proto.Clone(commit2).(*protos.Message)
+	commit2NextGet := commit2Next.GetCommit()
+	commit2NextGet.Seq = 1
+	commit2NextGet.Digest = nextProp.Digest()
+
+	appWG.Add(1)
+	followerMonWG.Add(1)
+	controller.ProcessMessages(1, commit1Next)
+	controller.ProcessMessages(2, commit2Next)
+	followerMonWG.Wait()
+	restartTimersWG.Wait()
+	appWG.Wait()
+	app.AssertNumberOfCalls(t, "Deliver", 2)
+
+	controller.Stop()
+}
+
+func TestDeliverTwiceOnceFromSyncAndOnceFromViewData(t *testing.T) {
+
+	// In this scenario a decision is delivered twice.
+	// Once by the synchronizer and a second time by the view data received during a view change.
+	// In the beginning there is a view change timeout and there is a view still running concurrently.
+	// Afterwards the view change is successful, but it does not include a decision made during the concurrent view.
+	// Later there is a second view change timeout and the synchronizer is called and delivers the decision.
+	// The checkpoint should be updated accordingly.
+	// Then the node receives a view data message which include the decision.
+	// If the checkpoint was updated correctly then this decision should not be delivered twice.
+
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	testDir, err := os.MkdirTemp("", "controller-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	wal, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+
+	comm := &mocks.CommMock{}
+	comm.On("BroadcastConsensus", mock.Anything)
+	commSendWG := sync.WaitGroup{}
+	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		commSendWG.Done()
+	})
+
+	reqTimer := &mocks.RequestsTimer{}
+	reqTimer.On("StopTimers")
+	reqTimer.On("RestartTimers")
+
+	ticker := make(chan time.Time)
+
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return(nil)
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
+		ID:    4,
+		Value: []byte{4},
+	})
+
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+
+	state := &mocks.State{}
+	state.On("Save", mock.Anything).Return(nil)
+
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifierSigWG := sync.WaitGroup{}
+	verifier.On("VerifySignature", mock.Anything).Run(func(args mock.Arguments) {
+		verifierSigWG.Done()
+	}).Return(nil)
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
+		Ids: []uint64{1},
+	}), nil)
+	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+
+	assembler := &mocks.AssemblerMock{}
+
+	reqPool := &mocks.RequestPool{}
+	reqPool.On("Close")
+
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMonWG := sync.WaitGroup{}
+	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		leaderMonWG.Done()
+	})
+	leaderMon.On("InjectArtificialHeartbeat", uint64(1), mock.Anything)
+	leaderMon.On("Close")
+
+	collector := bft.StateCollector{
+		SelfID:         4,
+		N:              7,
+		Logger:         log,
+		CollectTimeout: 100 * time.Millisecond,
+	}
+	collector.Start()
+
+	// The synchronizer returns on the first call a proposal with sequence 0 and on the second call a proposal with sequence 1.
+	// In both times the synchronizer is called after a view change timeout.
+	synchronizer := &mocks.SynchronizerMock{}
+	synchronizerWG := sync.WaitGroup{}
+	synchronizer.On("Sync").Run(func(args mock.Arguments) {
+		synchronizerWG.Done()
+	}).Return(types.SyncResponse{Latest: types.Decision{
+		Proposal: types.Proposal{
+			Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
+				LatestSequence: 0,
+				ViewId:         0,
+			}),
+			VerificationSequence: 1,
+		},
+		Signatures: []types.Signature{
+			{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5},
+		},
+	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}}).Once()
+	synchronizer.On("Sync").Run(func(args mock.Arguments) {
+		synchronizerWG.Done()
+	}).Return(types.SyncResponse{Latest: types.Decision{
+		Proposal: types.Proposal{
+			Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
+				LatestSequence: 1,
+				ViewId:         0,
+			}),
+			VerificationSequence: 1,
+		},
+		Signatures: []types.Signature{
+			{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5},
+		},
+	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}}).Once()
+
+	// If deliver is called with sequence 1 then the test fails.
+	// This is after the synchronizer returns with sequence 1.
+	app := &mocks.ApplicationMock{}
+	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		proposal := args.Get(0).(types.Proposal)
+		md := &protos.ViewMetadata{}
+		if err := proto.Unmarshal(proposal.Metadata, md); err != nil {
+			panic("Error unmarshaling metadata")
+		}
+		if md.LatestSequence == 1 {
+			panic("Delivering sequence 1 twice")
+		}
+	}).Return(types.Reconfig{InLatestDecision: false})
+
+	vc := &bft.ViewChanger{
+		SelfID:            4,
+		N:                 7,
+		NodesList:         []uint64{1, 2, 3, 4, 5, 6, 7},
+		Logger:            log,
+		Comm:              comm,
+		Application:       app,
+		Verifier:          verifier,
+		Signer:            signer,
+		RequestsTimer:     reqTimer,
+		State:             state,
+		InFlight:          &bft.InFlightData{},
+		Ticker:            ticker,
+		InMsqQSize:        100,
+		MetricsViewChange: api.NewMetricsViewChange(&disabled.Provider{}),
+		ViewChangeTimeout: 10 * time.Second,
+		ResendTimeout:     20 * time.Second,
+	}
+
+	vc.ControllerStartedWG.Add(1)
+
+	controller := &bft.Controller{
+		InFlight:      &bft.InFlightData{},
+		Signer:        signer,
+		State:         state,
+		WAL:           wal,
+		ID:            4,
+		N:             7,
+		NodesList:     []uint64{1, 2, 3, 4, 5, 6, 7},
+		Logger:        log,
+		Batcher:       batcher,
+		Verifier:      verifier,
+		Assembler:     assembler,
+		Comm:          comm,
+		RequestPool:   reqPool,
+		LeaderMonitor: leaderMon,
+		Synchronizer:  synchronizer,
+		ViewChanger:   vc,
+		Checkpoint:    &types.Checkpoint{},
+		Collector:     &collector,
+		StartedWG:     &vc.ControllerStartedWG,
+	}
+	configureProposerBuilder(controller)
+
+	controller.Checkpoint.Set(proposal, []types.Signature{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}})
+
+	vc.Controller = controller
+	vc.Synchronizer = controller
+	vc.Checkpoint = controller.Checkpoint
+	vc.Start(0)
+
+	vs := configureProposerBuilder(controller)
+	controller.ViewSequences = vs
+
+	leaderMonWG.Add(1)
+	controller.Start(0, 1, 0, false)
+	leaderMonWG.Wait()
+
+	// Starting view change
+
+	startTime := time.Now()
+	vc.StartViewChange(1, true)
+
+	vc.HandleMessage(1, viewChangeMsg)
+	vc.HandleMessage(2, viewChangeMsg)
+	vc.HandleMessage(3, viewChangeMsg)
+	commSendWG.Add(1) // sending view data msg and joining the view change
+	vc.HandleMessage(5, viewChangeMsg)
+	commSendWG.Wait()
+
+	// View change timeout, synchronizer is called
+
+	synchronizerWG.Add(1)
+	commSendWG.Add(6) // fetch and send state after sync
+	leaderMonWG.Add(1)
+	ticker <- startTime.Add(5 * time.Second)
+	ticker <- startTime.Add(10 * time.Second)
+	ticker <- startTime.Add(9 * time.Second)
+	ticker <- startTime.Add(12 * time.Second)
+	ticker <- startTime.Add(15 * time.Second) // view change timeout
+	synchronizerWG.Wait()
+	commSendWG.Wait()
+	synchronizer.AssertNumberOfCalls(t, "Sync", 1)
+	leaderMonWG.Wait()
+
+	// Concurrent view is running and a decision is delivered (by the other nodes)
+
+	commSendWG.Add(6) // send prepares
+	controller.ProcessMessages(1, prePrepareSeq1View0)
+	commSendWG.Wait()
+
+	commSendWG.Add(6) // send commits
+	controller.ProcessMessages(1, prepareSeq1View0)
+	controller.ProcessMessages(2, prepareSeq1View0)
+	controller.ProcessMessages(3, prepareSeq1View0)
+	controller.ProcessMessages(7, prepareSeq1View0)
+	commSendWG.Wait()
+
+	// A view change occurs (the new view message does not include the decision made by the concurrent view)
+
+	verifierSigWG.Add(5)
+	leaderMonWG.Add(1)
+	vc.HandleMessage(2, createNewViewMsgForDeliverTwiceTest(1))
+	verifierSigWG.Wait() // got the new view msg
+	leaderMonWG.Wait()   // changed view and created the view
+
+	// Starting another view change
+
+	startTime = time.Now()
+	vc.StartViewChange(2, true)
+
+	viewChangeMsg2 := proto.Clone(viewChangeMsg).(*protos.Message)
+	viewChangeMsg2.GetViewChange().NextView = 2
+	vc.HandleMessage(1, viewChangeMsg2)
+	vc.HandleMessage(2, viewChangeMsg2)
+	vc.HandleMessage(3, viewChangeMsg2)
+	commSendWG.Add(1) // sending view data msg and joining the view change
+	vc.HandleMessage(5, viewChangeMsg2)
+	commSendWG.Wait()
+
+	// Again there is a view change timeout
+	// The synchronizer returns the decision
+
+	synchronizerWG.Add(1)
+	commSendWG.Add(6) // fetch and send state after sync
+	leaderMonWG.Add(1)
+	ticker <- startTime.Add(5 * time.Second)
+	ticker <- startTime.Add(10 * time.Second)
+	ticker <- startTime.Add(9 * time.Second)
+	ticker <- startTime.Add(12 * time.Second)
+	ticker <- startTime.Add(15 * time.Second)
+	ticker <- startTime.Add(20 * time.Second)
+	ticker <- startTime.Add(25 * time.Second) // view change timeout (with backoff)
+	synchronizerWG.Wait()
+	commSendWG.Wait()
+	leaderMonWG.Wait() // change view after sync returns
+	synchronizer.AssertNumberOfCalls(t, "Sync", 2)
+
+	// The view change continues
+
+	viewChangeMsg3 := proto.Clone(viewChangeMsg).(*protos.Message)
+	viewChangeMsg3.GetViewChange().NextView = 3
+	vc.HandleMessage(1, viewChangeMsg3)
+	vc.HandleMessage(2, viewChangeMsg3)
+	vc.HandleMessage(3, viewChangeMsg3)
+	vc.HandleMessage(5, viewChangeMsg3)
+
+	// The node receives a view data message which includes the decision
+
+	verifierSigWG.Add(1)
+	vc.HandleMessage(1, viewDataMsgSeq1View3)
+	verifierSigWG.Wait()
+
+	controller.Stop()
+	vc.Stop()
+	collector.Stop()
+	wal.Close()
+}
+
+var (
+	prePrepareSeq1View0 = &protos.Message{
+		Content: &protos.Message_PrePrepare{
+			PrePrepare: &protos.PrePrepare{
+				View: 0,
+				Seq:  1,
+				Proposal: &protos.Proposal{
+					Header:  []byte{0},
+					Payload: []byte{1},
+					Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
+						LatestSequence: 1,
+						ViewId:         0,
+					}),
+					VerificationSequence: 1,
+				},
+			},
+		},
+	}
+	proposalSeq1View0 = &types.Proposal{
+		Header:  []byte{0},
+		Payload: []byte{1},
+		Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
+			LatestSequence: 1,
+			ViewId:         0,
+		}),
+		VerificationSequence: 1,
+	}
+	prepareSeq1View0 = &protos.Message{
+		Content: &protos.Message_Prepare{
+			Prepare: &protos.Prepare{
+				View:   0,
+				Seq:    1,
+				Digest: proposalSeq1View0.Digest(),
+			},
+		},
+	}
+	viewDataSeq1View3    = createViewDataForDeliverTwiceTest(3, 1)
+	viewDataMsgSeq1View3 = &protos.Message{
+		Content: &protos.Message_ViewData{
+			ViewData: &protos.SignedViewData{
+				RawViewData: bft.MarshalOrPanic(viewDataSeq1View3),
+				Signer:      1,
+				Signature:   nil,
+			},
+		},
+	}
+)
+
+func createViewDataForDeliverTwiceTest(nextView, lastDecisionSequence uint64) *protos.ViewData {
+	lastDecision := types.Proposal{
+		Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
+			LatestSequence: lastDecisionSequence,
+			ViewId:         0,
+		}),
+		VerificationSequence: 1,
+	}
+	lastDecisionSignaturesProtos := []*protos.Signature{{Signer: 1, Value: []byte{4}, Msg: []byte{5}}, {Signer: 2, Value: []byte{4}, Msg: []byte{5}}, {Signer: 3, Value: []byte{4}, Msg: []byte{5}}, {Signer: 4, Value: []byte{4}, Msg: []byte{5}}, {Signer: 5, Value: []byte{4}, Msg: []byte{5}}}
+	return &protos.ViewData{
+		NextView: nextView,
+		LastDecision: &protos.Proposal{
+			Header:               lastDecision.Header,
+			Payload:              lastDecision.Payload,
+			Metadata:             lastDecision.Metadata,
+			VerificationSequence: uint64(lastDecision.VerificationSequence),
+		},
+		LastDecisionSignatures: lastDecisionSignaturesProtos,
+	}
+}
+
+func createNewViewMsgForDeliverTwiceTest(nextView uint64) *protos.Message {
+	q := 5
+	vd := createViewDataForDeliverTwiceTest(nextView, 0)
+	vdBytes := bft.MarshalOrPanic(vd)
+
+	signed := make([]*protos.SignedViewData, 0)
+	for len(signed) < q { // quorum
+		msg := &protos.Message{
+			Content: &protos.Message_ViewData{
+				ViewData: &protos.SignedViewData{
+					RawViewData: vdBytes,
+					Signer:      uint64(len(signed) + 1),
+					Signature:   nil,
+				},
+			},
+		}
+		signed = append(signed, msg.GetViewData())
+	}
+	return &protos.Message{
+		Content: &protos.Message_NewView{
+			NewView: &protos.NewView{
+				SignedViewData: signed,
+			},
+		},
+	}
+}
+
+func TestControllerRequestAbandoned(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	req := makeTestRequest("1", "1", "foo")
+	insp := &testRequestInspector{}
+
+	comm := &mocks.CommMock{}
+	forwarded := make(chan struct{}, 1)
+	comm.On("SendTransaction", uint64(1), req).Run(func(args mock.Arguments) {
+		forwarded <- struct{}{}
 	})
-	leaderMon := &mocks.LeaderMonitor{}
-	leaderMonWG := sync.WaitGroup{}
-	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		leaderMonWG.Done()
+	failureDetector := &mocks.FailureDetector{}
+	failureDetector.On("Complain", uint64(0), true)
+	abandonedHandler := &mocks.RequestAbandonedHandlerMock{}
+	abandoned := make(chan types.RequestInfo, 1)
+	abandonedHandler.On("OnRequestAbandoned", mock.Anything).Run(func(args mock.Arguments) {
+		abandoned <- args.Get(0).(types.RequestInfo)
 	})
-	followerMonWG := sync.WaitGroup{}
-	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		followerMonWG.Done()
+
+	controller := &bft.Controller{
+		Checkpoint:       &types.Checkpoint{},
+		ID:               2, // not the leader
+		N:                4,
+		NodesList:        []uint64{1, 2, 3, 4},
+		Logger:           log,
+		Comm:             comm,
+		FailureDetector:  failureDetector,
+		RequestAbandoned: abandonedHandler,
+	}
+
+	pool := bft.NewPool(log, insp, controller, bft.PoolOptions{
+		QueueSize:         3,
+		ForwardTimeout:    10 * time.Millisecond,
+		ComplainTimeout:   10 * time.Millisecond,
+		AutoRemoveTimeout: 10 * time.Millisecond,
+		RequestMaxBytes:   1024,
+	}, nil)
+	defer pool.Close()
+
+	assert.NoError(t, pool.Submit(req))
+
+	// The request is forwarded to the leader, but is never committed
+	<-forwarded
+	select {
+	case info := <-abandoned:
+		assert.Equal(t, insp.RequestID(req), info)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "request was not abandoned")
+	}
+	assert.Equal(t, 0, pool.Size())
+	failureDetector.AssertCalled(t, "Complain", uint64(0), true)
+}
+
+func TestControllerBroadcastWithSlowNode(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	msg := &protos.Message{
+		Content: &protos.Message_ViewChange{
+			ViewChange: &protos.ViewChange{NextView: 1},
+		},
+	}
+
+	releaseSlowNode := make(chan struct{})
+	defer close(releaseSlowNode)
+
+	received := make(chan uint64, 3)
+	comm := &mocks.CommMock{}
+	comm.On("SendConsensus", uint64(2), msg).Run(func(args mock.Arguments) {
+		<-releaseSlowNode
 	})
-	leaderMon.On("HeartbeatWasSent")
-	leaderMon.On("InjectArtificialHeartbeat", uint64(2), mock.Anything)
-	leaderMon.On("Close")
+	comm.On("SendConsensus", mock.Anything, msg).Run(func(args mock.Arguments) {
+		received <- args.Get(0).(uint64)
+	})
+
+	controller := &bft.Controller{
+		ID:               1,
+		N:                4,
+		NodesList:        []uint64{1, 2, 3, 4},
+		Logger:           log,
+		Comm:             comm,
+		BroadcastWorkers: 3,
+		SendTimeout:      100 * time.Millisecond,
+	}
+
+	broadcastDone := make(chan struct{})
+	go func() {
+		controller.BroadcastConsensus(msg)
+		close(broadcastDone)
+	}()
+
+	// The rest of the nodes receive the message although node 2 is stuck
+	var nodes []uint64
+	for i := 0; i < 2; i++ {
+		select {
+		case node := <-received:
+			nodes = append(nodes, node)
+		case <-time.After(time.Second):
+			assert.Fail(t, "message was not sent to the rest of the nodes")
+			return
+		}
+	}
+	assert.ElementsMatch(t, []uint64{3, 4}, nodes)
+
+	// The broadcast stops waiting for node 2 once the send timeout expires
+	select {
+	case <-broadcastDone:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "broadcast did not time out sending to the slow node")
+	}
+}
+
+func TestControllerBroadcastToStuckNode(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	releaseStuckNode := make(chan struct{})
+	defer close(releaseStuckNode)
+
+	received := make(chan *protos.Message, 1000)
+	comm := &mocks.CommMock{}
+	comm.On("SendConsensus", uint64(2), mock.Anything).Run(func(args mock.Arguments) {
+		<-releaseStuckNode
+	})
+	comm.On("SendConsensus", uint64(3), mock.Anything).Run(func(args mock.Arguments) {
+		received <- args.Get(1).(*protos.Message)
+	})
+	comm.On("SendConsensus", uint64(4), mock.Anything)
+
+	controller := &bft.Controller{
+		ID:               1,
+		N:                4,
+		NodesList:        []uint64{1, 2, 3, 4},
+		Logger:           log,
+		Comm:             comm,
+		BroadcastWorkers: 2,
+		SendTimeout:      10 * time.Millisecond,
+	}
+
+	viewChange := func(view uint64) *protos.Message {
+		return &protos.Message{
+			Content: &protos.Message_ViewChange{
+				ViewChange: &protos.ViewChange{NextView: view},
+			},
+		}
+	}
+
+	controller.BroadcastConsensus(viewChange(1))
+	select {
+	case m := <-received:
+		assert.Equal(t, uint64(1), m.GetViewChange().NextView)
+	case <-time.After(time.Second):
+		assert.Fail(t, "message was not sent to node 3")
+		return
+	}
+	// Wait for the send to node 2 to time out, so that only the long-lived senders remain
+	time.Sleep(100 * time.Millisecond)
+	goroutines := runtime.NumGoroutine()
+
+	// Broadcasts neither wait for the stuck node nor leave goroutines behind,
+	// and the rest of the nodes receive the messages in order
+	for view := uint64(2); view <= 500; view += 50 {
+		for i := view; i < view+50; i++ {
+			controller.BroadcastConsensus(viewChange(i))
+		}
+		for i := view; i < view+50; i++ {
+			select {
+			case m := <-received:
+				assert.Equal(t, i, m.GetViewChange().NextView)
+			case <-time.After(time.Second):
+				assert.Fail(t, "message was not sent to node 3")
+				return
+			}
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+}
+
+func TestControllerIgnoresOwnMessages(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
 	req := []byte{1}
 	batcher := &mocks.Batcher{}
 	batcher.On("Close")
-	batcher.On("Reset")
 	batcher.On("Closed").Return(false)
 	batcher.On("NextBatch").Return([][]byte{req}).Once()
-	batcher.On("PopRemainder").Return([][]byte{})
-	batcher.On("BatchRemainder", mock.Anything)
+	batcher.On("NextBatch").Return(nil)
 	verifier := &mocks.VerifierMock{}
-	verifier.On("VerifySignature", mock.Anything).Return(nil)
-	verifier.On("VerifyRequest", req).Return(types.RequestInfo{}, nil)
 	verifier.On("VerificationSequence").Return(uint64(1))
 	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
-	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(nil, nil)
-	verifier.On("AuxiliaryData", mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
-		Ids: []uint64{1, 3},
-	}))
-
-	nextMD := bft.MarshalOrPanic(&protos.ViewMetadata{
-		DecisionsInView: 1,
-		LatestSequence:  1,
-		ViewId:          1,
-		PrevCommitSignatureDigest: bft.CommitSignaturesDigest([]*protos.Signature{
-			commit1.GetCommit().Signature,
-			commit2.GetCommit().Signature,
-			commit3.GetCommit().Signature,
-		}),
-	})
-	nextProp := types.Proposal{
-		Header:               proposal.Header,
-		Payload:              proposal.Payload,
-		Metadata:             nextMD,
-		VerificationSequence: 1,
-	}
-
 	assembler := &mocks.AssemblerMock{}
-	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(nextProp, [][]byte{}).Once()
+	assembler.On("AssembleProposal", mock.Anything, [][]byte{req}).Return(proposal, [][]byte{})
 	comm := &mocks.CommMock{}
-	commWG := sync.WaitGroup{}
-	comm.On("Nodes").Return([]uint64{1, 2, 3, 4})
+	commits := make(chan uint64, 3)
 	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		commWG.Done()
+		if args.Get(1).(*protos.Message).GetCommit() != nil {
+			commits <- args.Get(0).(uint64)
+		}
 	})
 	signer := &mocks.SignerMock{}
-	signer.On("Sign", mock.Anything).Return(nil)
 	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
-		ID:    3,
+		ID:    17,
 		Value: []byte{4},
 	})
-	app := &mocks.ApplicationMock{}
-	appWG := sync.WaitGroup{}
-	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		appWG.Done()
-	}).Return(types.Reconfig{InLatestDecision: false})
+	reqPool := &mocks.RequestPool{}
+	reqPool.On("Close")
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Leader, mock.Anything, mock.Anything)
+	leaderMon.On("HeartbeatWasSent")
+	leaderMon.On("InjectArtificialHeartbeat", mock.Anything, mock.Anything)
+	leaderMon.On("Close")
+
+	testDir, err := os.MkdirTemp("", "controller-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	wal, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+	defer wal.Close()
 
 	startedWG := sync.WaitGroup{}
 	startedWG.Add(1)
 
 	controller := &bft.Controller{
-		InFlight:           &bft.InFlightData{},
-		RequestPool:        reqPool,
-		LeaderMonitor:      leaderMon,
-		WAL:                wal,
-		ID:                 3, // the second leader
-		N:                  4,
-		NodesList:          []uint64{1, 2, 3, 4},
-		Logger:             log,
-		Batcher:            batcher,
-		Verifier:           verifier,
-		Assembler:          assembler,
-		Comm:               comm,
-		Signer:             signer,
-		Application:        app,
-		Checkpoint:         &types.Checkpoint{},
-		ViewChanger:        &bft.ViewChanger{},
-		StartedWG:          &startedWG,
-		LeaderRotation:     true,
-		DecisionsPerLeader: 1,
-		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
+		InFlight:      &bft.InFlightData{},
+		RequestPool:   reqPool,
+		LeaderMonitor: leaderMon,
+		WAL:           wal,
+		ID:            17, // the leader
+		N:             4,
+		NodesList:     []uint64{11, 17, 23, 37},
+		Logger:        log,
+		Batcher:       batcher,
+		Verifier:      verifier,
+		Assembler:     assembler,
+		Comm:          comm,
+		Signer:        signer,
+		Checkpoint:    &types.Checkpoint{},
+		ViewChanger:   &bft.ViewChanger{},
+		StartedWG:     &startedWG,
+		MetricsView:   api.NewMetricsView(&disabled.Provider{}),
 	}
 	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
-	vs := configureProposerBuilder(controller)
-	controller.ViewSequences = vs
 
-	followerMonWG.Add(1) // change role
+	configureProposerBuilder(controller)
+
 	controller.Start(1, 0, 0, false)
-	followerMonWG.Wait()
+	defer controller.Stop()
 
-	commWG.Add(3)
-	controller.ProcessMessages(2, prePrepare)
-	commWG.Wait() // prepare
+	// A prepare looped back from the node itself is not counted as a vote
+	controller.ProcessMessages(17, prepare)
+	controller.ProcessMessages(23, prepare)
+	select {
+	case <-commits:
+		assert.Fail(t, "committed without a quorum of prepares")
+	case <-time.After(200 * time.Millisecond):
+	}
 
-	commWG.Add(3)
-	controller.ProcessMessages(1, prepare)
-	controller.ProcessMessages(4, prepare)
-	commWG.Wait() // commit
+	controller.ProcessMessages(37, prepare)
+	select {
+	case <-commits:
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "did not commit after a quorum of prepares")
+	}
+
+	// A view change message looped back from the node itself doesn't reach the view changer,
+	// which would count it as a vote of another node (and which is not running in this test)
+	processed := make(chan struct{})
+	go func() {
+		controller.ProcessMessages(17, &protos.Message{
+			Content: &protos.Message_ViewChange{
+				ViewChange: &protos.ViewChange{NextView: 2},
+			},
+		})
+		close(processed)
+	}()
+	select {
+	case <-processed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "own view change message was not ignored")
+	}
+}
+
+func TestControllerSyncsOnFutureViewMessages(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	pool := &mocks.RequestPool{}
+	pool.On("Close")
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything)
+	leaderMon.On("Close")
+	comm := &mocks.CommMock{}
+	comm.On("SendConsensus", mock.Anything, mock.Anything)
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
+	synced := make(chan struct{}, 10)
+	synchronizer := &mocks.SynchronizerMock{}
+	synchronizer.On("Sync").Run(func(args mock.Arguments) {
+		synced <- struct{}{}
+	}).Return(types.SyncResponse{})
 
-	controller.ProcessMessages(1, commit1)
-	time.Sleep(time.Second)
-	appWG.Add(1)       // deliver
-	leaderMonWG.Add(1) // change role
-	commWG.Add(6)      // propose + prepare
-	controller.ProcessMessages(2, commit2)
-	appWG.Wait()
-	leaderMonWG.Wait()
-	commWG.Wait()
+	collector := bft.StateCollector{
+		SelfID:         1,
+		N:              4,
+		Logger:         log,
+		CollectTimeout: 10 * time.Millisecond,
+	}
+	collector.Start()
+	defer collector.Stop()
 
-	// leader rotation (now 3 is the leader)
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
 
-	prepareNext := proto.Clone(prepare).(*protos.Message)
-	prepareNextGet := prepareNext.GetPrepare()
-	prepareNextGet.Seq = 1
-	prepareNextGet.Digest = nextProp.Digest()
-	commWG.Add(3) // sending commit
-	controller.ProcessMessages(1, prepareNext)
-	controller.ProcessMessages(4, prepareNext)
-	commWG.Wait()
+	controller := &bft.Controller{
+		InFlight:        &bft.InFlightData{},
+		Checkpoint:      &types.Checkpoint{},
+		RequestPool:     pool,
+		LeaderMonitor:   leaderMon,
+		ID:              1, // a follower of node 2, the leader of view 1
+		N:               4,
+		NodesList:       []uint64{1, 2, 3, 4},
+		Logger:          log,
+		Batcher:         batcher,
+		Comm:            comm,
+		Verifier:        verifier,
+		Synchronizer:    synchronizer,
+		Collector:       &collector,
+		ViewChanger:     &bft.ViewChanger{},
+		FutureMsgsLimit: 10,
+		StartedWG:       &startedWG,
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+	controller.ViewSequences = configureProposerBuilder(controller)
+	controller.ViewSequences.Store(bft.ViewSequence{ViewActive: true})
 
-	commit1Next := proto.Clone(commit1).(*protos.Message)
-	commit1NextGet := commit1Next.GetCommit()
-	commit1NextGet.Seq = 1
-	commit1NextGet.Digest = nextProp.Digest()
+	controller.Start(1, 0, 0, false)
+	defer controller.Stop()
 
-	commit2Next := proto.Clone(commit2).(*protos.Message)
-	commit2NextGet := commit2Next.GetCommit()
-	commit2NextGet.Seq = 1
-	commit2NextGet.Digest = nextProp.Digest()
+	futurePrepare := &protos.Message{
+		Content: &protos.Message_Prepare{
+			Prepare: &protos.Prepare{View: 3, Seq: 5, Digest: "d"},
+		},
+	}
 
-	appWG.Add(1)
-	followerMonWG.Add(1)
-	controller.ProcessMessages(1, commit1Next)
-	controller.ProcessMessages(2, commit2Next)
-	followerMonWG.Wait()
-	restartTimersWG.Wait()
-	appWG.Wait()
-	app.AssertNumberOfCalls(t, "Deliver", 2)
+	// Messages of a future view from a single node are not enough to sync
+	controller.ProcessMessages(3, futurePrepare)
+	controller.ProcessMessages(3, futurePrepare)
+	select {
+	case <-synced:
+		t.Fatalf("Synced after future view messages from a single node")
+	case <-time.After(200 * time.Millisecond):
+	}
 
-	controller.Stop()
+	// Once f+1 nodes sent messages of a future view, the node is lagging and syncs
+	controller.ProcessMessages(4, futurePrepare)
+	select {
+	case <-synced:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Did not sync after future view messages from f+1 nodes")
+	}
 }
 
-func TestDeliverTwiceOnceFromSyncAndOnceFromViewData(t *testing.T) {
+type cancellableSynchronizer struct {
+	started   chan struct{}
+	cancelled chan struct{}
+}
 
-	// In this scenario a decision is delivered twice.
-	// Once by the synchronizer and a second time by the view data received during a view change.
-	// In the beginning there is a view change timeout and there is a view still running concurrently.
-	// Afterwards the view change is successful, but it does not include a decision made during the concurrent view.
-	// Later there is a second view change timeout and the synchronizer is called and delivers the decision.
-	// The checkpoint should be updated accordingly.
-	// Then the node receives a view data message which include the decision.
-	// If the checkpoint was updated correctly then this decision should not be delivered twice.
+func (cs *cancellableSynchronizer) SyncWithContext(ctx context.Context) types.SyncResponse {
+	cs.started <- struct{}{}
+	<-ctx.Done()
+	cs.cancelled <- struct{}{}
+	return types.SyncResponse{}
+}
 
+func TestControllerCancelsSync(t *testing.T) {
 	basicLog, err := zap.NewDevelopment()
 	assert.NoError(t, err)
 	log := basicLog.Sugar()
 
-	testDir, err := os.MkdirTemp("", "controller-unittest")
-	assert.NoErrorf(t, err, "generate temporary test dir")
-	defer os.RemoveAll(testDir)
-	wal, err := wal.Create(log, testDir, nil)
-	assert.NoError(t, err)
-
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	pool := &mocks.RequestPool{}
+	pool.On("Close")
+	leaderMon := &mocks.LeaderMonitor{}
+	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything)
+	leaderMon.On("Close")
 	comm := &mocks.CommMock{}
 	comm.On("BroadcastConsensus", mock.Anything)
-	commSendWG := sync.WaitGroup{}
-	comm.On("SendConsensus", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		commSendWG.Done()
-	})
+	comm.On("SendConsensus", mock.Anything, mock.Anything)
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
+	synchronizer := &cancellableSynchronizer{
+		started:   make(chan struct{}, 1),
+		cancelled: make(chan struct{}, 1),
+	}
 
-	reqTimer := &mocks.RequestsTimer{}
-	reqTimer.On("StopTimers")
-	reqTimer.On("RestartTimers")
+	collector := bft.StateCollector{
+		SelfID:         1,
+		N:              4,
+		Logger:         log,
+		CollectTimeout: 10 * time.Millisecond,
+	}
+	collector.Start()
+	defer collector.Stop()
 
-	ticker := make(chan time.Time)
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
 
-	signer := &mocks.SignerMock{}
-	signer.On("Sign", mock.Anything).Return(nil)
-	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
-		ID:    4,
-		Value: []byte{4},
-	})
+	controller := &bft.Controller{
+		InFlight:        &bft.InFlightData{},
+		Checkpoint:      &types.Checkpoint{},
+		RequestPool:     pool,
+		LeaderMonitor:   leaderMon,
+		ID:              1,
+		N:               4,
+		NodesList:       []uint64{1, 2, 3, 4},
+		Logger:          log,
+		Batcher:         batcher,
+		Comm:            comm,
+		Verifier:        verifier,
+		CancellableSync: synchronizer,
+		Collector:       &collector,
+		ViewChanger:     &bft.ViewChanger{},
+		StartedWG:       &startedWG,
+	}
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+	controller.ViewSequences = configureProposerBuilder(controller)
+	controller.ViewSequences.Store(bft.ViewSequence{ViewActive: true})
 
-	batcher := &mocks.Batcher{}
-	batcher.On("Close")
+	controller.Start(1, 0, 0, false)
+	defer controller.Stop()
 
-	state := &mocks.State{}
-	state.On("Save", mock.Anything).Return(nil)
+	// Cancelling when there is no sync in progress does nothing
+	controller.CancelSync()
 
-	verifier := &mocks.VerifierMock{}
-	verifier.On("VerificationSequence").Return(uint64(1))
-	verifierSigWG := sync.WaitGroup{}
-	verifier.On("VerifySignature", mock.Anything).Run(func(args mock.Arguments) {
-		verifierSigWG.Done()
-	}).Return(nil)
-	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(bft.MarshalOrPanic(&protos.PreparesFrom{
-		Ids: []uint64{1},
-	}), nil)
-	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	controller.Sync()
+	select {
+	case <-synchronizer.started:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Did not start syncing")
+	}
 
-	assembler := &mocks.AssemblerMock{}
+	select {
+	case <-synchronizer.cancelled:
+		t.Fatalf("Sync was cancelled before the node learned it is caught up")
+	case <-time.After(200 * time.Millisecond):
+	}
 
-	reqPool := &mocks.RequestPool{}
-	reqPool.On("Close")
+	// The node learns it is caught up, and the sync in progress is cancelled
+	controller.CancelSync()
+	select {
+	case <-synchronizer.cancelled:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Sync was not cancelled")
+	}
+}
+
+func TestControllerIgnoresUnendorsedSyncedDecision(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
 
+	batcher := &mocks.Batcher{}
+	batcher.On("Close")
+	batcher.On("Closed").Return(false)
+	pool := &mocks.RequestPool{}
+	pool.On("Close")
+	pool.On("Prune", mock.Anything)
 	leaderMon := &mocks.LeaderMonitor{}
-	leaderMonWG := sync.WaitGroup{}
-	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		leaderMonWG.Done()
-	})
-	leaderMon.On("InjectArtificialHeartbeat", uint64(1), mock.Anything)
+	leaderMon.On("ChangeRole", bft.Follower, mock.Anything, mock.Anything)
 	leaderMon.On("Close")
+	comm := &mocks.CommMock{}
+	comm.On("BroadcastConsensus", mock.Anything)
+	comm.On("SendConsensus", mock.Anything, mock.Anything)
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(nil, nil)
 
-	collector := bft.StateCollector{
-		SelfID:         4,
-		N:              7,
-		Logger:         log,
-		CollectTimeout: 100 * time.Millisecond,
+	proposal := types.Proposal{
+		Payload:  []byte{1},
+		Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{LatestSequence: 5}),
 	}
-	collector.Start()
-
-	// The synchronizer returns on the first call a proposal with sequence 0 and on the second call a proposal with sequence 1.
-	// In both times the synchronizer is called after a view change timeout.
+	// Node 3 is byzantine, and forges a decision which only it signed
+	forged := types.Decision{
+		Proposal:   proposal,
+		Signatures: []types.Signature{{ID: 3}},
+	}
+	endorsed := types.Decision{
+		Proposal:   proposal,
+		Signatures: []types.Signature{{ID: 2}, {ID: 3}, {ID: 4}},
+	}
+	synced := make(chan struct{}, 2)
 	synchronizer := &mocks.SynchronizerMock{}
-	synchronizerWG := sync.WaitGroup{}
 	synchronizer.On("Sync").Run(func(args mock.Arguments) {
-		synchronizerWG.Done()
-	}).Return(types.SyncResponse{Latest: types.Decision{
-		Proposal: types.Proposal{
-			Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
-				LatestSequence: 0,
-				ViewId:         0,
-			}),
-			VerificationSequence: 1,
-		},
-		Signatures: []types.Signature{
-			{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5},
-		},
-	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}}).Once()
+		synced <- struct{}{}
+	}).Return(types.SyncResponse{Latest: forged}).Once()
 	synchronizer.On("Sync").Run(func(args mock.Arguments) {
-		synchronizerWG.Done()
-	}).Return(types.SyncResponse{Latest: types.Decision{
-		Proposal: types.Proposal{
-			Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
-				LatestSequence: 1,
-				ViewId:         0,
-			}),
-			VerificationSequence: 1,
-		},
-		Signatures: []types.Signature{
-			{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5},
-		},
-	}, Reconfig: types.ReconfigSync{InReplicatedDecisions: false}}).Once()
-
-	// If deliver is called with sequence 1 then the test fails.
-	// This is after the synchronizer returns with sequence 1.
-	app := &mocks.ApplicationMock{}
-	app.On("Deliver", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
-		proposal := args.Get(0).(types.Proposal)
-		md := &protos.ViewMetadata{}
-		if err := proto.Unmarshal(proposal.Metadata, md); err != nil {
-			panic("Error unmarshaling metadata")
-		}
-		if md.LatestSequence == 1 {
-			panic("Delivering sequence 1 twice")
-		}
-	}).Return(types.Reconfig{InLatestDecision: false})
+		synced <- struct{}{}
+	}).Return(types.SyncResponse{Latest: endorsed}).Once()
 
-	vc := &bft.ViewChanger{
-		SelfID:            4,
-		N:                 7,
-		NodesList:         []uint64{1, 2, 3, 4, 5, 6, 7},
-		Logger:            log,
-		Comm:              comm,
-		Application:       app,
-		Verifier:          verifier,
-		Signer:            signer,
-		RequestsTimer:     reqTimer,
-		State:             state,
-		InFlight:          &bft.InFlightData{},
-		Ticker:            ticker,
-		InMsqQSize:        100,
-		MetricsViewChange: api.NewMetricsViewChange(&disabled.Provider{}),
-		ViewChangeTimeout: 10 * time.Second,
-		ResendTimeout:     20 * time.Second,
+	collector := bft.StateCollector{
+		SelfID:         1,
+		N:              4,
+		Logger:         log,
+		CollectTimeout: 10 * time.Millisecond,
 	}
+	collector.Start()
+	defer collector.Stop()
 
-	vc.ControllerStartedWG.Add(1)
+	startedWG := sync.WaitGroup{}
+	startedWG.Add(1)
 
+	checkpoint := &types.Checkpoint{}
 	controller := &bft.Controller{
 		InFlight:      &bft.InFlightData{},
-		Signer:        signer,
-		State:         state,
-		WAL:           wal,
-		ID:            4,
-		N:             7,
-		NodesList:     []uint64{1, 2, 3, 4, 5, 6, 7},
+		Checkpoint:    checkpoint,
+		RequestPool:   pool,
+		LeaderMonitor: leaderMon,
+		ID:            1,
+		N:             4,
+		NodesList:     []uint64{1, 2, 3, 4},
 		Logger:        log,
 		Batcher:       batcher,
-		Verifier:      verifier,
-		Assembler:     assembler,
 		Comm:          comm,
-		RequestPool:   reqPool,
-		LeaderMonitor: leaderMon,
+		Verifier:      verifier,
 		Synchronizer:  synchronizer,
-		ViewChanger:   vc,
-		Checkpoint:    &types.Checkpoint{},
 		Collector:     &collector,
-		StartedWG:     &vc.ControllerStartedWG,
+		ViewChanger:   &bft.ViewChanger{},
+		VerifySynced:  true,
+		StartedWG:     &startedWG,
 	}
-	configureProposerBuilder(controller)
-
-	controller.Checkpoint.Set(proposal, []types.Signature{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}})
-
-	vc.Controller = controller
-	vc.Synchronizer = controller
-	vc.Checkpoint = controller.Checkpoint
-	vc.Start(0)
-
-	vs := configureProposerBuilder(controller)
-	controller.ViewSequences = vs
-
-	leaderMonWG.Add(1)
-	controller.Start(0, 1, 0, false)
-	leaderMonWG.Wait()
-
-	// Starting view change
-
-	startTime := time.Now()
-	vc.StartViewChange(1, true)
+	controller.Deliver = &bft.MutuallyExclusiveDeliver{C: controller}
+	controller.ViewSequences = configureProposerBuilder(controller)
+	controller.ViewSequences.Store(bft.ViewSequence{ViewActive: true})
 
-	vc.HandleMessage(1, viewChangeMsg)
-	vc.HandleMessage(2, viewChangeMsg)
-	vc.HandleMessage(3, viewChangeMsg)
-	commSendWG.Add(1) // sending view data msg and joining the view change
-	vc.HandleMessage(5, viewChangeMsg)
-	commSendWG.Wait()
+	controller.Start(1, 0, 0, false)
+	defer controller.Stop()
 
-	// View change timeout, synchronizer is called
+	checkpointSeq := func() uint64 {
+		prop, _ := checkpoint.Get()
+		if len(prop.Metadata) == 0 {
+			return 0
+		}
+		md := &protos.ViewMetadata{}
+		assert.NoError(t, proto.Unmarshal(prop.Metadata, md))
+		return md.LatestSequence
+	}
 
-	synchronizerWG.Add(1)
-	commSendWG.Add(6) // fetch and send state after sync
-	leaderMonWG.Add(1)
-	ticker <- startTime.Add(5 * time.Second)
-	ticker <- startTime.Add(10 * time.Second)
-	ticker <- startTime.Add(9 * time.Second)
-	ticker <- startTime.Add(12 * time.Second)
-	ticker <- startTime.Add(15 * time.Second) // view change timeout
-	synchronizerWG.Wait()
-	commSendWG.Wait()
-	synchronizer.AssertNumberOfCalls(t, "Sync", 1)
-	leaderMonWG.Wait()
+	// The forged decision is not backed by a quorum, hence the checkpoint is not moved to it
+	controller.Sync()
+	select {
+	case <-synced:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Did not sync")
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, uint64(0), checkpointSeq())
+
+	// The same decision signed by a quorum is accepted
+	controller.Sync()
+	select {
+	case <-synced:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Did not sync")
+	}
+	assert.Eventually(t, func() bool {
+		return checkpointSeq() == 5
+	}, 10*time.Second, 10*time.Millisecond)
+}
 
-	// Concurrent view is running and a decision is delivered (by the other nodes)
+func TestLeaderPrioritizesTimedOutRequest(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
 
-	commSendWG.Add(6) // send prepares
-	controller.ProcessMessages(1, prePrepareSeq1View0)
-	commSendWG.Wait()
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, noopTimeoutHandler, bft.PoolOptions{QueueSize: 10, ForwardTimeout: time.Hour}, make(chan struct{}, 10))
+	defer pool.Close()
 
-	commSendWG.Add(6) // send commits
-	controller.ProcessMessages(1, prepareSeq1View0)
-	controller.ProcessMessages(2, prepareSeq1View0)
-	controller.ProcessMessages(3, prepareSeq1View0)
-	controller.ProcessMessages(7, prepareSeq1View0)
-	commSendWG.Wait()
+	// The stuck request is behind other requests in the pool of the leader
+	for _, id := range []string{"1", "2", "3"} {
+		assert.NoError(t, pool.Submit(makeTestRequest("bob", id, "foo")))
+	}
+	stuck := makeTestRequest("alice", "1", "foo")
+	assert.NoError(t, pool.Submit(stuck))
+
+	leader := &bft.Controller{
+		Checkpoint:  &types.Checkpoint{},
+		RequestPool: pool,
+		ID:          1,
+		N:           4,
+		NodesList:   []uint64{1, 2, 3, 4},
+		Logger:      log,
+	}
 
-	// A view change occurs (the new view message does not include the decision made by the concurrent view)
+	// Without prioritizing, the leader does nothing when its request times out
+	leader.OnRequestTimeout(stuck, insp.RequestID(stuck))
+	batch, _ := pool.NextRequests(1, 1000, false)
+	assert.Equal(t, [][]byte{makeTestRequest("bob", "1", "foo")}, batch)
 
-	verifierSigWG.Add(5)
-	leaderMonWG.Add(1)
-	vc.HandleMessage(2, createNewViewMsgForDeliverTwiceTest(1))
-	verifierSigWG.Wait() // got the new view msg
-	leaderMonWG.Wait()   // changed view and created the view
+	// With prioritizing, the request is included in the next batch
+	leader.PrioritizeTimedOut = true
+	leader.OnRequestTimeout(stuck, insp.RequestID(stuck))
+	batch, _ = pool.NextRequests(1, 1000, false)
+	assert.Equal(t, [][]byte{stuck}, batch)
+}
 
-	// Starting another view change
+// inOrderLedger is an application that records the sequences delivered to it,
+// and syncs the decisions up to its height that were not delivered to it
+type inOrderLedger struct {
+	delivered []uint64
+	last      uint64
+	height    uint64
+}
 
-	startTime = time.Now()
-	vc.StartViewChange(2, true)
+func proposalOfSeq(seq uint64) types.Proposal {
+	return types.Proposal{
+		Payload:  []byte{byte(seq)},
+		Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{LatestSequence: seq}),
+	}
+}
 
-	viewChangeMsg2 := proto.Clone(viewChangeMsg).(*protos.Message)
-	viewChangeMsg2.GetViewChange().NextView = 2
-	vc.HandleMessage(1, viewChangeMsg2)
-	vc.HandleMessage(2, viewChangeMsg2)
-	vc.HandleMessage(3, viewChangeMsg2)
-	commSendWG.Add(1) // sending view data msg and joining the view change
-	vc.HandleMessage(5, viewChangeMsg2)
-	commSendWG.Wait()
+func (l *inOrderLedger) Deliver(proposal types.Proposal, _ []types.Signature) types.Reconfig {
+	md := &protos.ViewMetadata{}
+	if err := proto.Unmarshal(proposal.Metadata, md); err != nil {
+		panic(err)
+	}
+	l.delivered = append(l.delivered, md.LatestSequence)
+	l.last = md.LatestSequence
+	return types.Reconfig{}
+}
 
-	// Again there is a view change timeout
-	// The synchronizer returns the decision
+func (l *inOrderLedger) Sync() types.SyncResponse {
+	for seq := l.last + 1; seq <= l.height; seq++ {
+		l.Deliver(proposalOfSeq(seq), nil)
+	}
+	return types.SyncResponse{Latest: types.Decision{Proposal: proposalOfSeq(l.last)}}
+}
 
-	synchronizerWG.Add(1)
-	commSendWG.Add(6) // fetch and send state after sync
-	leaderMonWG.Add(1)
-	ticker <- startTime.Add(5 * time.Second)
-	ticker <- startTime.Add(10 * time.Second)
-	ticker <- startTime.Add(9 * time.Second)
-	ticker <- startTime.Add(12 * time.Second)
-	ticker <- startTime.Add(15 * time.Second)
-	ticker <- startTime.Add(20 * time.Second)
-	ticker <- startTime.Add(25 * time.Second) // view change timeout (with backoff)
-	synchronizerWG.Wait()
-	commSendWG.Wait()
-	leaderMonWG.Wait() // change view after sync returns
-	synchronizer.AssertNumberOfCalls(t, "Sync", 2)
+func TestDeliverInSequenceOrder(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
 
-	// The view change continues
+	ledger := &inOrderLedger{last: 5, height: 5}
+	checkpoint := &types.Checkpoint{}
+	checkpoint.Set(proposalOfSeq(5), nil)
+	controller := &bft.Controller{
+		ID:           1,
+		N:            4,
+		NodesList:    []uint64{1, 2, 3, 4},
+		Logger:       log,
+		Application:  ledger,
+		Synchronizer: ledger,
+		Checkpoint:   checkpoint,
+		InFlight:     &bft.InFlightData{},
+		MetricsView:  api.NewMetricsView(&disabled.Provider{}),
+	}
+	deliver := &bft.MutuallyExclusiveDeliver{C: controller}
+
+	deliver.Deliver(proposalOfSeq(6), nil)
+	ledger.height = 6
+
+	// A late decision of the old view is not delivered
+	deliver.Deliver(proposalOfSeq(4), nil)
+	assert.Equal(t, []uint64{6}, ledger.delivered)
+
+	// The other nodes decided on sequences 7 and 8, which are delivered before sequence 9
+	ledger.height = 8
+	deliver.Deliver(proposalOfSeq(9), nil)
+	assert.Equal(t, []uint64{6, 7, 8, 9}, ledger.delivered)
+
+	// Sequence 11 is buffered while no node has sequence 10 yet, and is delivered once a late decision of sequence 10 arrives
+	deliver.Deliver(proposalOfSeq(11), nil)
+	assert.Equal(t, []uint64{6, 7, 8, 9}, ledger.delivered)
+	prop, _ := checkpoint.Get()
+	assert.Equal(t, []byte{9}, prop.Payload)
+
+	deliver.Deliver(proposalOfSeq(10), nil)
+	assert.Equal(t, []uint64{6, 7, 8, 9, 10, 11}, ledger.delivered)
+	prop, _ = checkpoint.Get()
+	assert.Equal(t, []byte{11}, prop.Payload)
+}
 
-	viewChangeMsg3 := proto.Clone(viewChangeMsg).(*protos.Message)
-	viewChangeMsg3.GetViewChange().NextView = 3
-	vc.HandleMessage(1, viewChangeMsg3)
-	vc.HandleMessage(2, viewChangeMsg3)
-	vc.HandleMessage(3, viewChangeMsg3)
-	vc.HandleMessage(5, viewChangeMsg3)
+func TestDeliverInSequenceOrderFromGenesis(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
 
-	// The node receives a view data message which includes the decision
+	ledger := &inOrderLedger{}
+	checkpoint := &types.Checkpoint{}
+	controller := &bft.Controller{
+		ID:           1,
+		N:            4,
+		NodesList:    []uint64{1, 2, 3, 4},
+		Logger:       log,
+		Application:  ledger,
+		Synchronizer: ledger,
+		Checkpoint:   checkpoint,
+		InFlight:     &bft.InFlightData{},
+		MetricsView:  api.NewMetricsView(&disabled.Provider{}),
+	}
+	deliver := &bft.MutuallyExclusiveDeliver{C: controller}
 
-	verifierSigWG.Add(1)
-	vc.HandleMessage(1, viewDataMsgSeq1View3)
-	verifierSigWG.Wait()
+	// Nothing was delivered yet, hence sequence 2 waits for sequence 1
+	deliver.Deliver(proposalOfSeq(2), nil)
+	assert.Empty(t, ledger.delivered)
 
-	controller.Stop()
-	vc.Stop()
-	collector.Stop()
-	wal.Close()
+	deliver.Deliver(proposalOfSeq(1), nil)
+	assert.Equal(t, []uint64{1, 2}, ledger.delivered)
 }
 
-var (
-	prePrepareSeq1View0 = &protos.Message{
-		Content: &protos.Message_PrePrepare{
-			PrePrepare: &protos.PrePrepare{
-				View: 0,
-				Seq:  1,
-				Proposal: &protos.Proposal{
-					Header:  []byte{0},
-					Payload: []byte{1},
-					Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
-						LatestSequence: 1,
-						ViewId:         0,
-					}),
-					VerificationSequence: 1,
-				},
-			},
-		},
-	}
-	proposalSeq1View0 = &types.Proposal{
-		Header:  []byte{0},
-		Payload: []byte{1},
-		Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
-			LatestSequence: 1,
-			ViewId:         0,
-		}),
-		VerificationSequence: 1,
-	}
-	prepareSeq1View0 = &protos.Message{
-		Content: &protos.Message_Prepare{
-			Prepare: &protos.Prepare{
-				View:   0,
-				Seq:    1,
-				Digest: proposalSeq1View0.Digest(),
-			},
-		},
-	}
-	viewDataSeq1View3    = createViewDataForDeliverTwiceTest(3, 1)
-	viewDataMsgSeq1View3 = &protos.Message{
-		Content: &protos.Message_ViewData{
-			ViewData: &protos.SignedViewData{
-				RawViewData: bft.MarshalOrPanic(viewDataSeq1View3),
-				Signer:      1,
-				Signature:   nil,
-			},
-		},
-	}
-)
+func TestConcurrentPruningPrunesOnce(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
 
-func createViewDataForDeliverTwiceTest(nextView, lastDecisionSequence uint64) *protos.ViewData {
-	lastDecision := types.Proposal{
-		Metadata: bft.MarshalOrPanic(&protos.ViewMetadata{
-			LatestSequence: lastDecisionSequence,
-			ViewId:         0,
-		}),
-		VerificationSequence: 1,
-	}
-	lastDecisionSignaturesProtos := []*protos.Signature{{Signer: 1, Value: []byte{4}, Msg: []byte{5}}, {Signer: 2, Value: []byte{4}, Msg: []byte{5}}, {Signer: 3, Value: []byte{4}, Msg: []byte{5}}, {Signer: 4, Value: []byte{4}, Msg: []byte{5}}, {Signer: 5, Value: []byte{4}, Msg: []byte{5}}}
-	return &protos.ViewData{
-		NextView: nextView,
-		LastDecision: &protos.Proposal{
-			Header:               lastDecision.Header,
-			Payload:              lastDecision.Payload,
-			Metadata:             lastDecision.Metadata,
-			VerificationSequence: uint64(lastDecision.VerificationSequence),
-		},
-		LastDecisionSignatures: lastDecisionSignaturesProtos,
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
+	pool := &mocks.RequestPool{}
+	pool.On("Prune", mock.Anything)
+	controller := &bft.Controller{
+		Logger:      log,
+		Verifier:    verifier,
+		RequestPool: pool,
 	}
-}
 
-func createNewViewMsgForDeliverTwiceTest(nextView uint64) *protos.Message {
-	q := 5
-	vd := createViewDataForDeliverTwiceTest(nextView, 0)
-	vdBytes := bft.MarshalOrPanic(vd)
-
-	signed := make([]*protos.SignedViewData, 0)
-	for len(signed) < q { // quorum
-		msg := &protos.Message{
-			Content: &protos.Message_ViewData{
-				ViewData: &protos.SignedViewData{
-					RawViewData: vdBytes,
-					Signer:      uint64(len(signed) + 1),
-					Signature:   nil,
-				},
-			},
-		}
-		signed = append(signed, msg.GetViewData())
-	}
-	return &protos.Message{
-		Content: &protos.Message_NewView{
-			NewView: &protos.NewView{
-				SignedViewData: signed,
-			},
-		},
+	// The delivery worker and the run loop may both notice the change of the verification sequence
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			controller.MaybePruneRevokedRequests()
+		}()
 	}
+	wg.Wait()
+	pool.AssertNumberOfCalls(t, "Prune", 1)
 }
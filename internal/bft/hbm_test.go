@@ -58,6 +58,10 @@ func (h heartbeatEventHandler) Sync() {
 	panic("implement me")
 }
 
+func (h heartbeatEventHandler) CancelSync() {
+	panic("implement me")
+}
+
 func TestHeartbeatWasSent(t *testing.T) {
 	basicLog, err := zap.NewDevelopment()
 	assert.NoError(t, err)
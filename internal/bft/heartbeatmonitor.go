@@ -6,6 +6,7 @@
 package bft
 
 import (
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -29,6 +30,27 @@ type HeartbeatEventHandler interface {
 	OnHeartbeatTimeout(view uint64, leaderID uint64)
 	// Sync is called when enough heartbeat responses report that the current leader's view is outdated.
 	Sync()
+	// CancelSync is called when a heartbeat of the leader shows that a follower that synced because it was behind
+	// the leader caught up.
+	CancelSync()
+}
+
+//go:generate mockery -dir . -name QuorumEventHandler -case underscore -output ./mocks/
+
+// QuorumEventHandler is notified when the leader stops or resumes observing activity from a quorum of nodes.
+// This is implemented by the Controller.
+type QuorumEventHandler interface {
+	// OnQuorumReachabilityChange is called when a quorum of nodes becomes unreachable, or reachable again.
+	OnQuorumReachabilityChange(reachable bool)
+}
+
+//go:generate mockery -dir . -name DuplicateIDHandler -case underscore -output ./mocks/
+
+// DuplicateIDHandler is notified when another node uses the ID of this node.
+// This is implemented by the Consensus.
+type DuplicateIDHandler interface {
+	// OnDuplicateID is called when a heartbeat sent by another node using the ID of this node is received.
+	OnDuplicateID()
 }
 
 // Role indicates if this node is a follower or a leader
@@ -74,6 +96,17 @@ type HeartbeatMonitor struct {
 	behindCounter                 uint64
 	numOfTicksBehindBeforeSyncing uint64
 	followerBehind                bool
+	syncedBehind                  bool
+	quorumHandler                 QuorumEventHandler
+	peerActivity                  chan uint64
+	activityLock                  sync.RWMutex // guards lastActive, trackedSince and lastTick against InactivePeers
+	lastActive                    map[uint64]time.Time
+	trackedSince                  time.Time
+	leaderSince                   time.Time
+	quorumUnreachable             bool
+	nonce                         uint64
+	selfID                        uint64
+	duplicateIDHandler            DuplicateIDHandler
 }
 
 // NewHeartbeatMonitor creates a new HeartbeatMonitor
@@ -94,10 +127,28 @@ func NewHeartbeatMonitor(scheduler <-chan time.Time, logger api.Logger, heartbea
 		sentHeartbeat:                 make(chan struct{}, 1),
 		artificialHeartbeat:           make(chan incMsg, 1),
 		numOfTicksBehindBeforeSyncing: numOfTicksBehindBeforeSyncing,
+		peerActivity:                  make(chan uint64, numberOfNodes),
+		lastActive:                    make(map[uint64]time.Time),
+		nonce:                         rand.Uint64(),
 	}
 	return hm
 }
 
+// TrackQuorum makes the monitor track whether the leader observes activity from a quorum of nodes,
+// and notify the given handler whenever that changes. Followers then respond to every heartbeat of the leader.
+// It must be called before the monitor is started.
+func (hm *HeartbeatMonitor) TrackQuorum(handler QuorumEventHandler) {
+	hm.quorumHandler = handler
+}
+
+// DetectDuplicateID makes the monitor notify the given handler when it receives a heartbeat sent by another node
+// that uses the given ID of this node, which it tells apart from this node by the nonce each monitor attaches
+// to its heartbeats. It must be called before the monitor is started.
+func (hm *HeartbeatMonitor) DetectDuplicateID(selfID uint64, handler DuplicateIDHandler) {
+	hm.selfID = selfID
+	hm.duplicateIDHandler = handler
+}
+
 func (hm *HeartbeatMonitor) start() {
 	hm.running.Add(1)
 	go hm.run()
@@ -110,7 +161,9 @@ func (hm *HeartbeatMonitor) Close() {
 	}
 	defer func() {
 		hm.lastHeartbeat = time.Time{}
+		hm.activityLock.Lock()
 		hm.lastTick = time.Time{}
+		hm.activityLock.Unlock()
 	}()
 	defer hm.running.Wait()
 	close(hm.stopChan)
@@ -132,6 +185,8 @@ func (hm *HeartbeatMonitor) run() {
 			hm.lastHeartbeat = hm.lastTick
 		case msg := <-hm.artificialHeartbeat:
 			hm.handleArtificialHeartBeat(msg.sender, msg.GetHeartBeat())
+		case sender := <-hm.peerActivity:
+			hm.markActive(sender)
 		}
 	}
 }
@@ -159,6 +214,46 @@ func (hm *HeartbeatMonitor) InjectArtificialHeartbeat(sender uint64, msg *smartb
 	}
 }
 
+// PeerActive tells the monitor that a message from the given node was received
+func (hm *HeartbeatMonitor) PeerActive(sender uint64) {
+	if hm.quorumHandler == nil {
+		return
+	}
+	select {
+	case hm.peerActivity <- sender:
+	default:
+	}
+}
+
+func (hm *HeartbeatMonitor) markActive(sender uint64) {
+	hm.activityLock.Lock()
+	defer hm.activityLock.Unlock()
+	hm.lastActive[sender] = hm.lastTick
+}
+
+// InactivePeers returns the given nodes from which no activity was observed within the last heartbeat timeout.
+// Activity is only tracked when the monitor tracks the quorum, otherwise none of the nodes is returned.
+func (hm *HeartbeatMonitor) InactivePeers(nodes []uint64) []uint64 {
+	if hm.quorumHandler == nil {
+		return nil
+	}
+
+	hm.activityLock.RLock()
+	defer hm.activityLock.RUnlock()
+
+	var inactive []uint64
+	for _, node := range nodes {
+		since := hm.trackedSince
+		if lastActive, exists := hm.lastActive[node]; exists {
+			since = lastActive
+		}
+		if hm.lastTick.Sub(since) >= hm.hbTimeout {
+			inactive = append(inactive, node)
+		}
+	}
+	return inactive
+}
+
 func (hm *HeartbeatMonitor) StopLeaderSendMsg() {
 	hm.logger.Infof("Changing role to folower without change current view and current leader")
 	select {
@@ -197,6 +292,10 @@ func (hm *HeartbeatMonitor) ChangeRole(follower Role, view uint64, leaderID uint
 func (hm *HeartbeatMonitor) handleMsg(sender uint64, msg *smartbftprotos.Message) {
 	switch msg.GetContent().(type) {
 	case *smartbftprotos.Message_HeartBeat:
+		if hm.duplicateIDHandler != nil && sender == hm.selfID {
+			hm.handleHeartBeatFromSelfID(msg.GetHeartBeat())
+			return
+		}
 		hm.handleRealHeartBeat(sender, msg.GetHeartBeat())
 	case *smartbftprotos.Message_HeartBeatResponse:
 		hm.handleHeartBeatResponse(sender, msg.GetHeartBeatResponse())
@@ -205,6 +304,17 @@ func (hm *HeartbeatMonitor) handleMsg(sender uint64, msg *smartbftprotos.Message
 	}
 }
 
+// handleHeartBeatFromSelfID tells apart a heartbeat of this node that was looped back from a heartbeat of another
+// node that uses the ID of this node, by their nonces
+func (hm *HeartbeatMonitor) handleHeartBeatFromSelfID(hb *smartbftprotos.HeartBeat) {
+	if hb.Nonce == hm.nonce {
+		hm.logger.Debugf("Received a heartbeat of this node, ignoring")
+		return
+	}
+	hm.logger.Errorf("Received a heartbeat from another node that uses the ID %d of this node", hm.selfID)
+	hm.duplicateIDHandler.OnDuplicateID()
+}
+
 func (hm *HeartbeatMonitor) handleRealHeartBeat(sender uint64, hb *smartbftprotos.HeartBeat) {
 	hm.handleHeartBeat(sender, hb, false)
 }
@@ -236,6 +346,7 @@ func (hm *HeartbeatMonitor) handleHeartBeat(sender uint64, hb *smartbftprotos.He
 		if ourSeq+1 < hb.Seq {
 			hm.logger.Debugf("Heartbeat sequence is bigger than expected, leader's sequence is %d and ours is %d, syncing and ignoring", hb.Seq, ourSeq)
 			hm.handler.Sync()
+			hm.syncedBehind = true
 			return
 		}
 		if ourSeq+1 == hb.Seq {
@@ -247,6 +358,11 @@ func (hm *HeartbeatMonitor) handleHeartBeat(sender uint64, hb *smartbftprotos.He
 			}
 		} else {
 			hm.followerBehind = false
+			if hm.syncedBehind {
+				hm.logger.Debugf("Our sequence caught up with the heartbeat sequence %d, cancelling the sync", hb.Seq)
+				hm.handler.CancelSync()
+				hm.syncedBehind = false
+			}
 		}
 	} else {
 		hm.followerBehind = false
@@ -254,10 +370,19 @@ func (hm *HeartbeatMonitor) handleHeartBeat(sender uint64, hb *smartbftprotos.He
 
 	hm.logger.Debugf("Received heartbeat from %d, last heartbeat was %v ago", sender, hm.lastTick.Sub(hm.lastHeartbeat))
 	hm.lastHeartbeat = hm.lastTick
+
+	if hm.quorumHandler != nil && !artificial {
+		// Let the leader know we are reachable
+		hm.sendHeartBeatResponse(sender)
+	}
 }
 
 // handleHeartBeatResponse keeps track of responses, and if we get f+1 identical, force a sync
 func (hm *HeartbeatMonitor) handleHeartBeatResponse(sender uint64, hbr *smartbftprotos.HeartBeatResponse) {
+	if hm.quorumHandler != nil {
+		hm.markActive(sender)
+	}
+
 	if hm.follower {
 		hm.logger.Debugf("Monitor is not a leader, ignoring HeartBeatResponse; sender: %d, msg: %v", sender, hbr)
 		return
@@ -313,7 +438,12 @@ func (hm *HeartbeatMonitor) viewActive(hbMsg *smartbftprotos.HeartBeat) (bool, u
 }
 
 func (hm *HeartbeatMonitor) tick(now time.Time) {
+	hm.activityLock.Lock()
 	hm.lastTick = now
+	if hm.trackedSince.IsZero() {
+		hm.trackedSince = now
+	}
+	hm.activityLock.Unlock()
 	if hm.lastHeartbeat.IsZero() {
 		hm.lastHeartbeat = now
 	}
@@ -347,9 +477,53 @@ func (hm *HeartbeatMonitor) handleCommand(cmd roleChange) {
 	hm.lastHeartbeat = hm.lastTick
 	hm.hbRespCollector = make(heartbeatResponseCollector)
 	hm.syncReq = false
+	hm.syncedBehind = false
+	hm.leaderSince = hm.lastTick
+	if bool(hm.follower) && hm.quorumUnreachable {
+		// Only the leader tracks the quorum
+		hm.setQuorumUnreachable(false)
+	}
+}
+
+// checkQuorum checks whether the leader observed activity from a quorum of nodes (including itself)
+// within the last heartbeat timeout
+func (hm *HeartbeatMonitor) checkQuorum(now time.Time) {
+	if hm.leaderSince.IsZero() {
+		hm.leaderSince = now
+	}
+	if now.Sub(hm.leaderSince) < hm.hbTimeout {
+		return
+	}
+
+	reachable := 1
+	for node, lastActive := range hm.lastActive {
+		if node != hm.leaderID && now.Sub(lastActive) < hm.hbTimeout {
+			reachable++
+		}
+	}
+
+	q, _ := computeQuorum(hm.numberOfNodes)
+	hm.setQuorumUnreachable(reachable < q)
+}
+
+func (hm *HeartbeatMonitor) setQuorumUnreachable(unreachable bool) {
+	if hm.quorumUnreachable == unreachable {
+		return
+	}
+	hm.quorumUnreachable = unreachable
+	if unreachable {
+		hm.logger.Warnf("Leader %d of view %d does not observe activity from a quorum of nodes", hm.leaderID, hm.view)
+	} else {
+		hm.logger.Infof("Leader %d of view %d observes activity from a quorum of nodes", hm.leaderID, hm.view)
+	}
+	hm.quorumHandler.OnQuorumReachabilityChange(!unreachable)
 }
 
 func (hm *HeartbeatMonitor) leaderTick(now time.Time) {
+	if hm.quorumHandler != nil {
+		hm.checkQuorum(now)
+	}
+
 	if now.Sub(hm.lastHeartbeat)*time.Duration(hm.hbCount) < hm.hbTimeout {
 		return
 	}
@@ -366,8 +540,9 @@ func (hm *HeartbeatMonitor) leaderTick(now time.Time) {
 	heartbeat := &smartbftprotos.Message{
 		Content: &smartbftprotos.Message_HeartBeat{
 			HeartBeat: &smartbftprotos.HeartBeat{
-				View: hm.view,
-				Seq:  sequence,
+				View:  hm.view,
+				Seq:   sequence,
+				Nonce: hm.nonce,
 			},
 		},
 	}
@@ -400,6 +575,7 @@ func (hm *HeartbeatMonitor) followerTick(now time.Time) {
 	if hm.behindCounter >= hm.numOfTicksBehindBeforeSyncing {
 		hm.logger.Warnf("Syncing since the follower with seq %d is behind the leader for the last %d ticks", hm.behindSeq, hm.numOfTicksBehindBeforeSyncing)
 		hm.handler.Sync()
+		hm.syncedBehind = true
 		hm.behindCounter = 0
 		return
 	}
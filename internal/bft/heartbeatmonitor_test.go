@@ -294,6 +294,49 @@ func TestHeartbeatMonitorLeaderAndFollower(t *testing.T) {
 	handler1.AssertNumberOfCalls(t, "OnHeartbeatTimeout", 1)
 }
 
+func TestHeartbeatMonitorDuplicateID(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	// Two monitors of nodes that were both started with the ID 1
+	scheduler1 := make(chan time.Time)
+	comm1 := &mocks.CommMock{}
+	vs1 := &atomic.Value{}
+	vs1.Store(bft.ViewSequence{ViewActive: true})
+	hm1 := bft.NewHeartbeatMonitor(scheduler1, log, types.DefaultConfig.LeaderHeartbeatTimeout, types.DefaultConfig.LeaderHeartbeatCount, comm1, 4, &mocks.HeartbeatEventHandler{}, vs1, types.DefaultConfig.NumOfTicksBehindBeforeSyncing)
+	duplicateHandler1 := &mocks.DuplicateIDHandler{}
+	hm1.DetectDuplicateID(1, duplicateHandler1)
+
+	vs2 := &atomic.Value{}
+	vs2.Store(bft.ViewSequence{ViewActive: true})
+	hm2 := bft.NewHeartbeatMonitor(make(chan time.Time), log, types.DefaultConfig.LeaderHeartbeatTimeout, types.DefaultConfig.LeaderHeartbeatCount, &mocks.CommMock{}, 4, &mocks.HeartbeatEventHandler{}, vs2, types.DefaultConfig.NumOfTicksBehindBeforeSyncing)
+	duplicateHandler2 := &mocks.DuplicateIDHandler{}
+	duplicateHandler2.On("OnDuplicateID").Return()
+	hm2.DetectDuplicateID(1, duplicateHandler2)
+
+	heartbeats := make(chan *smartbftprotos.Message, heartbeatCount*2)
+	comm1.On("BroadcastConsensus", mock.AnythingOfType("*smartbftprotos.Message")).Run(func(args mock.Arguments) {
+		heartbeats <- args[0].(*smartbftprotos.Message)
+	})
+
+	hm1.ChangeRole(bft.Leader, 10, 1)
+	hm2.ChangeRole(bft.Follower, 10, 1)
+	clock := fakeTime{}
+	clock.advanceTime(heartbeatCount*2, scheduler1)
+	heartbeat := <-heartbeats
+
+	// A heartbeat of the first node that is looped back to it is not taken as sent by a duplicate,
+	// but the other node detects that the heartbeat was sent by another node using its ID
+	hm1.ProcessMsg(1, heartbeat)
+	hm2.ProcessMsg(1, heartbeat)
+	hm1.Close()
+	hm2.Close()
+
+	duplicateHandler1.AssertNotCalled(t, "OnDuplicateID")
+	duplicateHandler2.AssertNumberOfCalls(t, "OnDuplicateID", 1)
+}
+
 func TestHeartbeatResponseLeader(t *testing.T) {
 	basicLog, err := zap.NewDevelopment()
 	assert.NoError(t, err)
@@ -457,6 +500,56 @@ func TestFollowerBehindSync(t *testing.T) {
 	handler.AssertNumberOfCalls(t, "Sync", 1)
 }
 
+func TestFollowerCancelsSyncOnceCaughtUp(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	scheduler := make(chan time.Time)
+
+	comm := &mocks.CommMock{}
+	handler := &mocks.HeartbeatEventHandler{}
+	syncWG := &sync.WaitGroup{}
+	syncWG.Add(1)
+	handler.On("Sync").Run(func(args mock.Arguments) {
+		syncWG.Done()
+	})
+	cancelWG := &sync.WaitGroup{}
+	cancelWG.Add(1)
+	handler.On("CancelSync").Run(func(args mock.Arguments) {
+		cancelWG.Done()
+	})
+
+	vs := &atomic.Value{}
+	vs.Store(bft.ViewSequence{ViewActive: true, ProposalSeq: 8})
+	hm := bft.NewHeartbeatMonitor(scheduler, log, types.DefaultConfig.LeaderHeartbeatTimeout, types.DefaultConfig.LeaderHeartbeatCount, comm, 4, handler, vs, 3)
+
+	hm.ChangeRole(bft.Follower, 10, 12)
+
+	// The leader is too far ahead, so the follower syncs
+	hm.ProcessMsg(12, heartbeat)
+	syncWG.Wait()
+
+	// Heartbeats that show the follower is still behind do not cancel the sync
+	vs.Store(bft.ViewSequence{ViewActive: true, ProposalSeq: 9})
+	hm.ProcessMsg(12, heartbeat)
+	scheduler <- time.Now()
+	handler.AssertNotCalled(t, "CancelSync")
+
+	// Once the follower caught up with the leader the sync is cancelled
+	vs.Store(bft.ViewSequence{ViewActive: true, ProposalSeq: 10})
+	hm.ProcessMsg(12, heartbeat)
+	cancelWG.Wait()
+
+	// Later heartbeats do not cancel it again
+	hm.ProcessMsg(12, heartbeat)
+	scheduler <- time.Now()
+
+	hm.Close()
+	handler.AssertNumberOfCalls(t, "Sync", 1)
+	handler.AssertNumberOfCalls(t, "CancelSync", 1)
+}
+
 type fakeTime struct {
 	time time.Time
 }
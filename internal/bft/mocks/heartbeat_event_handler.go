@@ -9,6 +9,11 @@ type HeartbeatEventHandler struct {
 	mock.Mock
 }
 
+// CancelSync provides a mock function with given fields:
+func (_m *HeartbeatEventHandler) CancelSync() {
+	_m.Called()
+}
+
 // OnHeartbeatTimeout provides a mock function with given fields: view, leaderID
 func (_m *HeartbeatEventHandler) OnHeartbeatTimeout(view uint64, leaderID uint64) {
 	_m.Called(view, leaderID)
@@ -34,6 +34,27 @@ func (_m *LeaderMonitor) InjectArtificialHeartbeat(sender uint64, msg *smartbftp
 	_m.Called(sender, msg)
 }
 
+// InactivePeers provides a mock function with given fields: nodes
+func (_m *LeaderMonitor) InactivePeers(nodes []uint64) []uint64 {
+	ret := _m.Called(nodes)
+
+	var r0 []uint64
+	if rf, ok := ret.Get(0).(func([]uint64) []uint64); ok {
+		r0 = rf(nodes)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uint64)
+		}
+	}
+
+	return r0
+}
+
+// PeerActive provides a mock function with given fields: sender
+func (_m *LeaderMonitor) PeerActive(sender uint64) {
+	_m.Called(sender)
+}
+
 // ProcessMsg provides a mock function with given fields: sender, msg
 func (_m *LeaderMonitor) ProcessMsg(sender uint64, msg *smartbftprotos.Message) {
 	_m.Called(sender, msg)
@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	time "time"
+
 	types "github.com/hyperledger-labs/SmartBFT/pkg/types"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -17,6 +19,20 @@ func (_m *RequestPool) Close() {
 	_m.Called()
 }
 
+// Contains provides a mock function with given fields: request
+func (_m *RequestPool) Contains(request types.RequestInfo) bool {
+	ret := _m.Called(request)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(types.RequestInfo) bool); ok {
+		r0 = rf(request)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // NextRequests provides a mock function with given fields: maxCount, maxSizeBytes, check
 func (_m *RequestPool) NextRequests(maxCount int, maxSizeBytes uint64, check bool) ([][]byte, bool) {
 	ret := _m.Called(maxCount, maxSizeBytes, check)
@@ -40,6 +56,20 @@ func (_m *RequestPool) NextRequests(maxCount int, maxSizeBytes uint64, check boo
 	return r0, r1
 }
 
+// Prioritize provides a mock function with given fields: request
+func (_m *RequestPool) Prioritize(request types.RequestInfo) bool {
+	ret := _m.Called(request)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(types.RequestInfo) bool); ok {
+		r0 = rf(request)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // Prune provides a mock function with given fields: predicate
 func (_m *RequestPool) Prune(predicate func([]byte) error) {
 	_m.Called(predicate)
@@ -59,6 +89,29 @@ func (_m *RequestPool) RemoveRequest(request types.RequestInfo) error {
 	return r0
 }
 
+// Request provides a mock function with given fields: request
+func (_m *RequestPool) Request(request types.RequestInfo) ([]byte, bool) {
+	ret := _m.Called(request)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(types.RequestInfo) []byte); ok {
+		r0 = rf(request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(types.RequestInfo) bool); ok {
+		r1 = rf(request)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // RestartTimers provides a mock function with given fields:
 func (_m *RequestPool) RestartTimers() {
 	_m.Called()
@@ -96,3 +149,52 @@ func (_m *RequestPool) Submit(request []byte) error {
 
 	return r0
 }
+
+// SubmitForwarded provides a mock function with given fields: request, submitted
+func (_m *RequestPool) SubmitForwarded(request []byte, submitted time.Time) error {
+	ret := _m.Called(request, submitted)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]byte, time.Time) error); ok {
+		r0 = rf(request, submitted)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SubmissionTime provides a mock function with given fields: request
+func (_m *RequestPool) SubmissionTime(request types.RequestInfo) (time.Time, bool) {
+	ret := _m.Called(request)
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func(types.RequestInfo) time.Time); ok {
+		r0 = rf(request)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(types.RequestInfo) bool); ok {
+		r1 = rf(request)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// SubmitBatch provides a mock function with given fields: requests
+func (_m *RequestPool) SubmitBatch(requests [][]byte) error {
+	ret := _m.Called(requests)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([][]byte) error); ok {
+		r0 = rf(requests)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
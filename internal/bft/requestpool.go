@@ -9,6 +9,7 @@ import (
 	"container/list"
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -22,8 +23,9 @@ import (
 const (
 	defaultRequestTimeout    = 10 * time.Second // for unit tests only
 	defaultMaxBytes          = 100 * 1024       // default max request size would be of size 100Kb
-	defaultSizeOfDelElements = 1000             // default size slice of delete elements
+	defaultSizeOfDelElements = 1000             // default number of processed requests remembered
 	defaultEraseTimeout      = 5 * time.Second  // for cicle erase silice of delete elements
+	latencySamples           = 1000             // number of most recent request latencies retained
 )
 
 var (
@@ -31,6 +33,8 @@ var (
 	ErrReqAlreadyProcessed = fmt.Errorf("request already processed")
 	ErrRequestTooBig       = fmt.Errorf("submitted request is too big")
 	ErrSubmitTimeout       = fmt.Errorf("timeout submitting to request pool")
+	ErrLeaderNotDraining   = fmt.Errorf("request pool is full and is not being drained")
+	ErrPoolMaxBytes        = fmt.Errorf("request pool would exceed its max bytes")
 )
 
 //go:generate mockery -dir . -name RequestTimeoutHandler -case underscore -output ./mocks/
@@ -49,6 +53,9 @@ type RequestTimeoutHandler interface {
 // Pool implements requests pool, maintains pool of given size provided during
 // construction. In case there are more incoming request than given size it will
 // block during submit until there will be place to submit new ones.
+// All the methods of the pool are safe to call from multiple goroutines concurrently.
+// A request that is submitted concurrently more than once is added to the pool only once,
+// and the other submissions return ErrReqAlreadyExists, or ErrReqAlreadyProcessed if it was removed meanwhile.
 type Pool struct {
 	logger    api.Logger
 	metrics   *api.MetricsRequestPool
@@ -65,15 +72,30 @@ type Pool struct {
 	stopped        bool
 	submittedChan  chan struct{}
 	sizeBytes      uint64
-	delMap         map[types.RequestInfo]struct{}
-	delSlice       []types.RequestInfo
+	delMap         map[types.RequestInfo]time.Time
+	delSlice       []delElement
+	lastRemoval    time.Time
+	nextGroup      uint64
+	boundaries     int // the number of batch boundary requests in the pool
+	latency        *RequestLatency
 }
 
 // requestItem captures request related information
 type requestItem struct {
 	request           []byte
+	reqInfo           types.RequestInfo
 	timeout           *time.Timer
 	additionTimestamp time.Time
+	submitted         time.Time
+	group             uint64 // the group the request was submitted in, or zero if it was submitted on its own
+	boundary          bool   // whether the batch that includes the request ends with it
+	verificationSeq   uint64 // the verification sequence the request was submitted at
+}
+
+// delElement is a processed request, in the order requests were processed
+type delElement struct {
+	reqInfo   types.RequestInfo
+	timestamp time.Time
 }
 
 // PoolOptions is the pool configuration
@@ -84,7 +106,31 @@ type PoolOptions struct {
 	AutoRemoveTimeout time.Duration
 	RequestMaxBytes   uint64
 	SubmitTimeout     time.Duration
-	Metrics           *api.MetricsRequestPool
+	// MaxPoolBytes bounds the total size of the requests in the pool, and requests that would exceed it are rejected,
+	// even if the pool holds fewer than QueueSize requests. A value of zero means no limit.
+	MaxPoolBytes uint64
+	// MaxBytesPerType overrides RequestMaxBytes for the requests of the given types,
+	// as classified by the TypeInspector.
+	MaxBytesPerType map[string]uint64
+	// SortBatch orders the requests of each batch by client ID and then by request ID,
+	// instead of by their arrival order.
+	SortBatch bool
+	// ProcessedCacheSize is the number of processed requests remembered in order to reject their resubmission.
+	ProcessedCacheSize int
+	// MaxBatchCount caps the number of requests returned by NextRequests, whatever count it is asked for,
+	// and groups of more requests are rejected. A value of zero means there is no cap.
+	MaxBatchCount int
+	// ProcessedCacheMaxAge is the time a processed request is remembered for, a value of zero means it is
+	// remembered until ProcessedCacheSize requests that were processed later are remembered.
+	ProcessedCacheMaxAge time.Duration
+	Metrics              *api.MetricsRequestPool
+	// BoundaryInspector marks the requests that end the batch that includes them, if set.
+	BoundaryInspector api.BatchBoundaryInspector
+	// TypeInspector classifies the requests for MaxBytesPerType, if set.
+	TypeInspector api.RequestTypeInspector
+	// VerificationSequence returns the current verification sequence, if set, in which case a batch is cut
+	// between requests submitted at different verification sequences.
+	VerificationSequence func() uint64
 }
 
 // NewPool constructs new requests pool
@@ -104,6 +150,9 @@ func NewPool(log api.Logger, inspector api.RequestInspector, th RequestTimeoutHa
 	if options.SubmitTimeout == 0 {
 		options.SubmitTimeout = defaultRequestTimeout
 	}
+	if options.ProcessedCacheSize == 0 {
+		options.ProcessedCacheSize = defaultSizeOfDelElements
+	}
 	if options.Metrics == nil {
 		options.Metrics = api.NewMetricsRequestPool(&disabled.Provider{})
 	}
@@ -121,8 +170,9 @@ func NewPool(log api.Logger, inspector api.RequestInspector, th RequestTimeoutHa
 		existMap:       make(map[types.RequestInfo]*list.Element),
 		options:        options,
 		submittedChan:  submittedChan,
-		delMap:         make(map[types.RequestInfo]struct{}),
-		delSlice:       make([]types.RequestInfo, 0, defaultSizeOfDelElements),
+		delMap:         make(map[types.RequestInfo]time.Time),
+		delSlice:       make([]delElement, 0, options.ProcessedCacheSize),
+		latency:        &RequestLatency{Size: latencySamples, Histogram: options.Metrics.LatencyOfRequestDelivery},
 	}
 
 	go func() {
@@ -174,6 +224,16 @@ func (rp *Pool) ChangeOptions(th RequestTimeoutHandler, options PoolOptions) {
 	rp.options.AutoRemoveTimeout = options.AutoRemoveTimeout
 	rp.options.RequestMaxBytes = options.RequestMaxBytes
 	rp.options.SubmitTimeout = options.SubmitTimeout
+	rp.options.MaxBatchCount = options.MaxBatchCount
+	rp.options.MaxPoolBytes = options.MaxPoolBytes
+	rp.options.MaxBytesPerType = options.MaxBytesPerType
+	rp.options.VerificationSequence = options.VerificationSequence
+	rp.options.SortBatch = options.SortBatch
+	if options.ProcessedCacheSize != 0 {
+		rp.options.ProcessedCacheSize = options.ProcessedCacheSize
+	}
+	rp.options.ProcessedCacheMaxAge = options.ProcessedCacheMaxAge
+	rp.evictProcessed(time.Now())
 
 	rp.timeoutHandler = th
 
@@ -189,25 +249,35 @@ func (rp *Pool) isClosed() bool {
 
 // Submit a request into the pool, returns an error when request is already in the pool
 func (rp *Pool) Submit(request []byte) error {
+	return rp.submit(request, time.Time{})
+}
+
+// SubmitForwarded submits into the pool a request forwarded by another node, which was first submitted at the given
+// time, so that SubmissionTime reports when it was first submitted rather than when it was added to this pool.
+func (rp *Pool) SubmitForwarded(request []byte, submitted time.Time) error {
+	return rp.submit(request, submitted)
+}
+
+func (rp *Pool) submit(request []byte, submitted time.Time) error {
 	reqInfo := rp.inspector.RequestID(request)
 	if rp.isClosed() {
 		return errors.Errorf("pool closed, request rejected: %s", reqInfo)
 	}
 
-	if uint64(len(request)) > rp.options.RequestMaxBytes {
+	if maxBytes := rp.requestMaxBytes(request); uint64(len(request)) > maxBytes {
 		rp.metrics.CountOfFailAddRequestToPool.With(
 			rp.metrics.LabelsForWith("reason", api.ReasonRequestMaxBytes)...,
 		).Add(1)
 		return fmt.Errorf(
 			"submitted request (%d) is bigger than request max bytes (%d)",
 			len(request),
-			rp.options.RequestMaxBytes,
+			maxBytes,
 		)
 	}
 
 	rp.lock.RLock()
 	_, alreadyExists := rp.existMap[reqInfo]
-	_, alreadyDelete := rp.delMap[reqInfo]
+	alreadyDelete := rp.processed(reqInfo)
 	rp.lock.RUnlock()
 
 	if alreadyExists {
@@ -220,6 +290,7 @@ func (rp *Pool) Submit(request []byte) error {
 		return ErrReqAlreadyProcessed
 	}
 
+	waitStart := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), rp.options.SubmitTimeout)
 	defer cancel()
 	// do not wait for a semaphore with a lock, as it will prevent draining the pool.
@@ -227,6 +298,14 @@ func (rp *Pool) Submit(request []byte) error {
 		rp.metrics.CountOfFailAddRequestToPool.With(
 			rp.metrics.LabelsForWith("reason", api.ReasonSemaphoreAcquireFail)...,
 		).Add(1)
+		// If no request left the pool while waiting, the pool is not merely full but is not being drained,
+		// which clients should treat as a signal to back off.
+		rp.lock.RLock()
+		drained := rp.lastRemoval.After(waitStart)
+		rp.lock.RUnlock()
+		if !drained {
+			return errors.Wrapf(ErrLeaderNotDraining, "acquiring semaphore for request: %s", reqInfo)
+		}
 		return errors.Wrapf(err, "acquiring semaphore for request: %s", reqInfo)
 	}
 
@@ -241,27 +320,177 @@ func (rp *Pool) Submit(request []byte) error {
 		return ErrReqAlreadyExists
 	}
 
-	if _, deleteEl := rp.delMap[reqInfo]; deleteEl {
+	if rp.processed(reqInfo) {
 		rp.semaphore.Release(1)
 		rp.logger.Debugf("request %s has been already processed", reqInfo)
 		return ErrReqAlreadyProcessed
 	}
 
+	if err := rp.checkPoolBytes(uint64(len(request))); err != nil {
+		rp.semaphore.Release(1)
+		return errors.Wrapf(err, "request %s", reqInfo)
+	}
+
+	rp.add(reqCopy, reqInfo, 0, submitted)
+	rp.notifySubmitted()
+
+	return nil
+}
+
+// SubmitBatch submits the given requests into the pool as a group, which is batched as a whole,
+// even if it exceeds the batch limits. Either all the requests are submitted, or none of them.
+func (rp *Pool) SubmitBatch(requests [][]byte) error {
+	if len(requests) == 0 {
+		return errors.New("empty group of requests")
+	}
+	if int64(len(requests)) > rp.options.QueueSize {
+		return errors.Errorf("group of %d requests is larger than the pool size (%d)", len(requests), rp.options.QueueSize)
+	}
+	if rp.options.MaxBatchCount > 0 && len(requests) > rp.options.MaxBatchCount {
+		return errors.Errorf("group of %d requests is larger than the maximal batch count (%d)", len(requests), rp.options.MaxBatchCount)
+	}
+	if rp.isClosed() {
+		return errors.Errorf("pool closed, group of %d requests rejected", len(requests))
+	}
+
+	reqInfos := make([]types.RequestInfo, 0, len(requests))
+	inGroup := make(map[types.RequestInfo]struct{}, len(requests))
+	for _, request := range requests {
+		reqInfo := rp.inspector.RequestID(request)
+		if maxBytes := rp.requestMaxBytes(request); uint64(len(request)) > maxBytes {
+			rp.metrics.CountOfFailAddRequestToPool.With(
+				rp.metrics.LabelsForWith("reason", api.ReasonRequestMaxBytes)...,
+			).Add(1)
+			return errors.Errorf("submitted request %s (%d) is bigger than request max bytes (%d)",
+				reqInfo, len(request), maxBytes)
+		}
+		if _, exists := inGroup[reqInfo]; exists {
+			return errors.Wrapf(ErrReqAlreadyExists, "request %s appears twice in the group", reqInfo)
+		}
+		inGroup[reqInfo] = struct{}{}
+		reqInfos = append(reqInfos, reqInfo)
+	}
+
+	rp.lock.RLock()
+	err := rp.checkNotSubmitted(reqInfos)
+	rp.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rp.options.SubmitTimeout)
+	defer cancel()
+	// do not wait for a semaphore with a lock, as it will prevent draining the pool.
+	if err := rp.semaphore.Acquire(ctx, int64(len(requests))); err != nil {
+		rp.metrics.CountOfFailAddRequestToPool.With(
+			rp.metrics.LabelsForWith("reason", api.ReasonSemaphoreAcquireFail)...,
+		).Add(float64(len(requests)))
+		return errors.Wrapf(err, "acquiring semaphore for group of %d requests", len(requests))
+	}
+
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	if err := rp.checkNotSubmitted(reqInfos); err != nil {
+		rp.semaphore.Release(int64(len(requests)))
+		return err
+	}
+
+	var groupBytes uint64
+	for _, request := range requests {
+		groupBytes += uint64(len(request))
+	}
+	if err := rp.checkPoolBytes(groupBytes); err != nil {
+		rp.semaphore.Release(int64(len(requests)))
+		return errors.Wrapf(err, "group of %d requests", len(requests))
+	}
+
+	rp.nextGroup++
+	for i, request := range requests {
+		rp.add(append(make([]byte, 0), request...), reqInfos[i], rp.nextGroup, time.Time{})
+	}
+	rp.notifySubmitted()
+
+	return nil
+}
+
+// requestMaxBytes returns the maximal size of the given request, according to its type.
+func (rp *Pool) requestMaxBytes(request []byte) uint64 {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	if rp.options.TypeInspector == nil {
+		return rp.options.RequestMaxBytes
+	}
+	if maxBytes, exists := rp.options.MaxBytesPerType[rp.options.TypeInspector.RequestType(request)]; exists {
+		return maxBytes
+	}
+	return rp.options.RequestMaxBytes
+}
+
+// checkPoolBytes returns ErrPoolMaxBytes if adding requests of the given total size would exceed MaxPoolBytes.
+// Should be called with the lock held.
+func (rp *Pool) checkPoolBytes(size uint64) error {
+	if rp.options.MaxPoolBytes == 0 || rp.sizeBytes+size <= rp.options.MaxPoolBytes {
+		return nil
+	}
+	rp.metrics.CountOfFailAddRequestToPool.With(
+		rp.metrics.LabelsForWith("reason", api.ReasonPoolMaxBytes)...,
+	).Add(1)
+	rp.logger.Debugf("Pool holds %dB, adding %dB would exceed its max bytes (%d)", rp.sizeBytes, size, rp.options.MaxPoolBytes)
+	return errors.Wrapf(ErrPoolMaxBytes, "pool holds %dB, adding %dB would exceed %dB", rp.sizeBytes, size, rp.options.MaxPoolBytes)
+}
+
+// checkNotSubmitted returns an error if any of the given requests is in the pool or was already processed.
+// Should be called with the lock held.
+func (rp *Pool) checkNotSubmitted(reqInfos []types.RequestInfo) error {
+	for _, reqInfo := range reqInfos {
+		if _, exists := rp.existMap[reqInfo]; exists {
+			rp.logger.Debugf("request %s already exists in the pool", reqInfo)
+			return errors.Wrapf(ErrReqAlreadyExists, "request %s", reqInfo)
+		}
+		if rp.processed(reqInfo) {
+			rp.logger.Debugf("request %s already processed", reqInfo)
+			return errors.Wrapf(ErrReqAlreadyProcessed, "request %s", reqInfo)
+		}
+	}
+	return nil
+}
+
+// add adds the given request to the end of the pool, as part of the given group unless it is zero.
+// Should be called with the lock held, after the semaphore was acquired for the request.
+func (rp *Pool) add(request []byte, reqInfo types.RequestInfo, group uint64, submitted time.Time) {
 	to := time.AfterFunc(
 		rp.options.ForwardTimeout,
-		func() { rp.onRequestTO(reqCopy, reqInfo) },
+		func() { rp.onRequestTO(request, reqInfo) },
 	)
 	if rp.stopped {
 		rp.logger.Debugf("pool stopped, submitting with a stopped timer, request: %s", reqInfo)
 		to.Stop()
 	}
 	reqItem := &requestItem{
-		request:           reqCopy,
+		request:           request,
+		reqInfo:           reqInfo,
 		timeout:           to,
 		additionTimestamp: time.Now(),
+		submitted:         submitted,
+		group:             group,
+		boundary:          rp.options.BoundaryInspector != nil && rp.options.BoundaryInspector.IsBatchBoundary(request),
+	}
+	// A forwarded request was submitted to the node that forwarded it before it was added to this pool
+	if reqItem.submitted.IsZero() {
+		reqItem.submitted = reqItem.additionTimestamp
+	}
+	if reqItem.boundary {
+		rp.boundaries++
+	}
+	if rp.options.VerificationSequence != nil {
+		reqItem.verificationSeq = rp.options.VerificationSequence()
+		rp.cutOnVerificationSequence(reqItem.verificationSeq)
 	}
 
 	element := rp.fifo.PushBack(reqItem)
+	rp.latency.Submitted(reqInfo, reqItem.additionTimestamp)
 	rp.metrics.CountOfRequestPool.Set(float64(rp.fifo.Len()))
 	rp.metrics.CountOfRequestPoolAll.Add(1)
 	rp.existMap[reqInfo] = element
@@ -272,15 +501,33 @@ func (rp *Pool) Submit(request []byte) error {
 
 	rp.logger.Debugf("Request %s submitted; started a timeout: %s", reqInfo, rp.options.ForwardTimeout)
 
-	// notify that a request was submitted
+	rp.sizeBytes += uint64(len(request))
+}
+
+// cutOnVerificationSequence marks the last request in the pool as a batch boundary if it was submitted
+// at a verification sequence other than the given one, so that no batch spans a verification sequence change.
+// Should be called with the lock held.
+func (rp *Pool) cutOnVerificationSequence(verificationSeq uint64) {
+	last := rp.fifo.Back()
+	if last == nil {
+		return
+	}
+	item := last.Value.(*requestItem)
+	if item.boundary || item.verificationSeq == verificationSeq {
+		return
+	}
+	rp.logger.Debugf("Verification sequence changed from %d to %d, cutting the batch after request %s",
+		item.verificationSeq, verificationSeq, item.reqInfo)
+	item.boundary = true
+	rp.boundaries++
+}
+
+// notifySubmitted notifies that requests were submitted
+func (rp *Pool) notifySubmitted() {
 	select {
 	case rp.submittedChan <- struct{}{}:
 	default:
 	}
-
-	rp.sizeBytes += uint64(len(element.Value.(*requestItem).request))
-
-	return nil
 }
 
 // Size returns the number of requests currently residing the pool
@@ -291,38 +538,126 @@ func (rp *Pool) Size() int {
 	return len(rp.existMap)
 }
 
+// Contains returns whether the given request resides in the pool
+func (rp *Pool) Contains(requestInfo types.RequestInfo) bool {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	_, exists := rp.existMap[requestInfo]
+	return exists
+}
+
+// SubmissionTime returns the time the given request was first submitted, either to this pool or, if it was forwarded,
+// to the node that forwarded it, and whether the pool contains it.
+func (rp *Pool) SubmissionTime(requestInfo types.RequestInfo) (time.Time, bool) {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	element, exists := rp.existMap[requestInfo]
+	if !exists {
+		return time.Time{}, false
+	}
+	return element.Value.(*requestItem).submitted, true
+}
+
+// Request returns the given request, and whether the pool contains it.
+func (rp *Pool) Request(requestInfo types.RequestInfo) ([]byte, bool) {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	element, exists := rp.existMap[requestInfo]
+	if !exists {
+		return nil, false
+	}
+	return element.Value.(*requestItem).request, true
+}
+
+// Prioritize moves the given request to the front of the pool, so that it is included in the next batch,
+// and returns whether the pool contains it. A request submitted as part of a group is moved along with its group.
+func (rp *Pool) Prioritize(requestInfo types.RequestInfo) bool {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	element, exists := rp.existMap[requestInfo]
+	if !exists {
+		return false
+	}
+	group := element.Value.(*requestItem).group
+	if group == 0 {
+		rp.fifo.MoveToFront(element)
+		return true
+	}
+	// The requests of a group are adjacent, move them from the last to the first to keep their order
+	last := element
+	for next := last.Next(); next != nil && next.Value.(*requestItem).group == group; next = next.Next() {
+		last = next
+	}
+	for element = last; element != nil && element.Value.(*requestItem).group == group; {
+		prev := element.Prev()
+		rp.fifo.MoveToFront(element)
+		element = prev
+	}
+	return true
+}
+
+// MarkProposed remembers the given requests of a proposal the node accepted as processed, unless they are in the pool,
+// so that they are rejected if they are submitted later on, as if the proposal was already delivered.
+func (rp *Pool) MarkProposed(requests []types.RequestInfo) {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	for _, requestInfo := range requests {
+		if _, exists := rp.existMap[requestInfo]; exists {
+			continue
+		}
+		rp.moveToDelSlice(requestInfo)
+	}
+}
+
 // NextRequests returns the next requests to be batched.
 // It returns at most maxCount requests, and at most maxSizeBytes, in a newly allocated slice.
+// Whatever maxCount is, no more than MaxBatchCount requests are returned, if it is set.
 // Return variable full indicates that the batch cannot be increased further by calling again with the same arguments.
 func (rp *Pool) NextRequests(maxCount int, maxSizeBytes uint64, check bool) (batch [][]byte, full bool) {
 	rp.lock.Lock()
 	defer rp.lock.Unlock()
 
+	if rp.options.MaxBatchCount > 0 && maxCount > rp.options.MaxBatchCount {
+		maxCount = rp.options.MaxBatchCount
+	}
+
 	if check {
-		if (len(rp.existMap) < maxCount) && (rp.sizeBytes < maxSizeBytes) {
+		if (len(rp.existMap) < maxCount) && (rp.sizeBytes < maxSizeBytes) && rp.boundaries == 0 {
 			return nil, false
 		}
 	}
 
-	count := minInt(rp.fifo.Len(), maxCount)
 	var totalSize uint64
-	batch = make([][]byte, 0, count)
-	element := rp.fifo.Front()
-	for i := 0; i < count; i++ {
-		req := element.Value.(*requestItem).request
-		reqLen := uint64(len(req))
-		if totalSize+reqLen > maxSizeBytes {
+	var count int
+	var units [][]*requestItem
+	for element := rp.fifo.Front(); element != nil && count < maxCount; {
+		unit, unitSize, next := groupAt(element)
+		// A group of requests is batched as a whole, and may exceed the limits only if it is batched on its own
+		exceeds := count+len(unit) > maxCount || totalSize+unitSize > maxSizeBytes
+		if exceeds && (count > 0 || unit[0].group == 0) {
 			rp.logger.Debugf("Returning batch of %d requests totalling %dB as it exceeds threshold of %dB",
-				len(batch), totalSize, maxSizeBytes)
-			return batch, true
+				count, totalSize, maxSizeBytes)
+			return rp.batchOf(units), true
+		}
+		units = append(units, unit)
+		count += len(unit)
+		totalSize += unitSize
+		element = next
+		if endsBatch(unit) {
+			rp.logger.Debugf("Returning batch of %d requests totalling %dB as it ends with a batch boundary request",
+				count, totalSize)
+			return rp.batchOf(units), true
 		}
-		batch = append(batch, req)
-		totalSize += reqLen
-		element = element.Next()
 	}
+	batch = rp.batchOf(units)
 
 	fullS := totalSize >= maxSizeBytes
-	fullC := len(batch) == maxCount
+	fullC := len(batch) >= maxCount
 	full = fullS || fullC
 	if len(batch) > 0 {
 		rp.logger.Debugf("Returning batch of %d requests totalling %dB",
@@ -331,6 +666,52 @@ func (rp *Pool) NextRequests(maxCount int, maxSizeBytes uint64, check bool) (bat
 	return batch, full
 }
 
+// groupAt returns the requests of the group starting at the given element, which is only its request
+// if it was not submitted as part of a group, along with their total size and the element following them.
+func groupAt(element *list.Element) (group []*requestItem, sizeBytes uint64, next *list.Element) {
+	first := element.Value.(*requestItem)
+	for ; element != nil; element = element.Next() {
+		item := element.Value.(*requestItem)
+		if len(group) > 0 && (first.group == 0 || item.group != first.group) {
+			break
+		}
+		group = append(group, item)
+		sizeBytes += uint64(len(item.request))
+	}
+	return group, sizeBytes, element
+}
+
+// endsBatch returns whether the given group of requests includes a batch boundary request
+func endsBatch(group []*requestItem) bool {
+	for _, item := range group {
+		if item.boundary {
+			return true
+		}
+	}
+	return false
+}
+
+// batchOf returns the requests of the given groups, sorted by client ID and request ID if the pool is set to do so,
+// so that the order of a batch does not depend on the order in which its requests arrived.
+// The requests of a group are kept together and in their order, and the group is sorted by its first request.
+func (rp *Pool) batchOf(groups [][]*requestItem) [][]byte {
+	if rp.options.SortBatch {
+		sort.SliceStable(groups, func(i, j int) bool {
+			if groups[i][0].reqInfo.ClientID != groups[j][0].reqInfo.ClientID {
+				return groups[i][0].reqInfo.ClientID < groups[j][0].reqInfo.ClientID
+			}
+			return groups[i][0].reqInfo.ID < groups[j][0].reqInfo.ID
+		})
+	}
+	batch := make([][]byte, 0, len(groups))
+	for _, group := range groups {
+		for _, item := range group {
+			batch = append(batch, item.request)
+		}
+	}
+	return batch
+}
+
 // Prune removes requests for which the given predicate returns error.
 func (rp *Pool) Prune(predicate func([]byte) error) {
 	reqVec, infoVec := rp.copyRequests()
@@ -342,7 +723,7 @@ func (rp *Pool) Prune(predicate func([]byte) error) {
 			continue
 		}
 
-		if remErr := rp.RemoveRequest(infoVec[i]); remErr != nil {
+		if remErr := rp.discardRequest(infoVec[i]); remErr != nil {
 			rp.logger.Debugf("Failed to prune request: %s; predicate error: %s; remove error: %s", infoVec[i], err, remErr)
 		} else {
 			rp.logger.Debugf("Pruned request: %s; predicate error: %s", infoVec[i], err)
@@ -370,8 +751,20 @@ func (rp *Pool) copyRequests() (requestVec [][]byte, infoVec []types.RequestInfo
 	return
 }
 
-// RemoveRequest removes the given request from the pool.
+// RemoveRequest removes the given request from the pool once it is delivered, and measures its latency.
 func (rp *Pool) RemoveRequest(requestInfo types.RequestInfo) error {
+	rp.latency.Delivered(requestInfo, time.Now())
+	return rp.discardRequest(requestInfo)
+}
+
+// LatencyStats returns the statistics of the time it took to deliver the most recent requests
+// since they were submitted to the pool.
+func (rp *Pool) LatencyStats() types.LatencyStats {
+	return rp.latency.Stats()
+}
+
+// discardRequest removes the given request from the pool.
+func (rp *Pool) discardRequest(requestInfo types.RequestInfo) error {
 	rp.lock.Lock()
 	defer rp.lock.Unlock()
 
@@ -384,19 +777,24 @@ func (rp *Pool) RemoveRequest(requestInfo types.RequestInfo) error {
 	}
 
 	rp.deleteRequest(element, requestInfo)
-	rp.sizeBytes -= uint64(len(element.Value.(*requestItem).request))
 	return nil
 }
 
 func (rp *Pool) deleteRequest(element *list.Element, requestInfo types.RequestInfo) {
 	item := element.Value.(*requestItem)
 	item.timeout.Stop()
+	if item.boundary {
+		rp.boundaries--
+	}
 
 	rp.fifo.Remove(element)
+	rp.sizeBytes -= uint64(len(item.request))
 	rp.metrics.CountOfRequestPool.Set(float64(rp.fifo.Len()))
 	rp.metrics.LatencyOfRequestPool.Observe(time.Since(item.additionTimestamp).Seconds())
+	rp.latency.Forget(requestInfo)
 	delete(rp.existMap, requestInfo)
 	rp.moveToDelSlice(requestInfo)
+	rp.lastRemoval = time.Now()
 	rp.logger.Infof("Removed request %s from request pool", requestInfo)
 	rp.semaphore.Release(1)
 
@@ -405,35 +803,53 @@ func (rp *Pool) deleteRequest(element *list.Element, requestInfo types.RequestIn
 	}
 }
 
+// processed returns whether the given request was processed recently enough to be remembered.
+// Must be called with the lock held.
+func (rp *Pool) processed(requestInfo types.RequestInfo) bool {
+	timestamp, exist := rp.delMap[requestInfo]
+	if !exist {
+		return false
+	}
+	return rp.options.ProcessedCacheMaxAge == 0 || time.Since(timestamp) <= rp.options.ProcessedCacheMaxAge
+}
+
 func (rp *Pool) moveToDelSlice(requestInfo types.RequestInfo) {
-	_, exist := rp.delMap[requestInfo]
-	if exist {
+	if rp.processed(requestInfo) {
 		return
 	}
 
-	rp.delMap[requestInfo] = struct{}{}
-	rp.delSlice = append(rp.delSlice, requestInfo)
+	now := time.Now()
+	rp.delMap[requestInfo] = now
+	rp.delSlice = append(rp.delSlice, delElement{reqInfo: requestInfo, timestamp: now})
+	rp.evictProcessed(now)
 }
 
-func (rp *Pool) eraseFromDelSlice() {
-	rp.lock.RLock()
-	l := len(rp.delSlice)
-	rp.lock.RUnlock()
-
-	if l <= defaultSizeOfDelElements {
-		return
+// evictProcessed forgets the oldest processed requests beyond ProcessedCacheSize, and those older than
+// ProcessedCacheMaxAge. Must be called with the lock held.
+func (rp *Pool) evictProcessed(now time.Time) {
+	var n int
+	for n < len(rp.delSlice) {
+		oldest := rp.delSlice[n]
+		expired := rp.options.ProcessedCacheMaxAge > 0 && now.Sub(oldest.timestamp) > rp.options.ProcessedCacheMaxAge
+		if len(rp.delSlice)-n <= rp.options.ProcessedCacheSize && !expired {
+			break
+		}
+		// The request may have been remembered again since, in which case a later element refers to it
+		if rp.delMap[oldest.reqInfo] == oldest.timestamp {
+			delete(rp.delMap, oldest.reqInfo)
+		}
+		n++
 	}
 
+	rp.delSlice = rp.delSlice[n:]
+	rp.metrics.CountOfProcessedRequests.Set(float64(len(rp.delMap)))
+}
+
+func (rp *Pool) eraseFromDelSlice() {
 	rp.lock.Lock()
 	defer rp.lock.Unlock()
 
-	n := len(rp.delSlice) - defaultSizeOfDelElements
-
-	for _, r := range rp.delSlice[:n] {
-		delete(rp.delMap, r)
-	}
-
-	rp.delSlice = rp.delSlice[n:]
+	rp.evictProcessed(time.Now())
 }
 
 // Close removes all the requests, stops all the timeout timers.
@@ -558,7 +974,7 @@ func (rp *Pool) onLeaderFwdRequestTO(request []byte, reqInfo types.RequestInfo)
 // called by the goroutine spawned by time.AfterFunc
 func (rp *Pool) onAutoRemoveTO(reqInfo types.RequestInfo) {
 	rp.logger.Debugf("Request %s auto-remove timeout expired, going to remove from pool", reqInfo)
-	if err := rp.RemoveRequest(reqInfo); err != nil {
+	if err := rp.discardRequest(reqInfo); err != nil {
 		rp.logger.Errorf("Removal of request %s failed; error: %s", reqInfo, err)
 		return
 	}
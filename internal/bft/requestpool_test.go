@@ -10,12 +10,18 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/hyperledger-labs/SmartBFT/internal/bft"
 	"github.com/hyperledger-labs/SmartBFT/internal/bft/mocks"
+	"github.com/hyperledger-labs/SmartBFT/pkg/api"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics/disabled"
 	"github.com/hyperledger-labs/SmartBFT/pkg/types"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -46,7 +52,7 @@ func TestReqPoolBasic(t *testing.T) {
 		assert.Equal(t, 0, pool.Size())
 
 		err = pool.Submit(byteReq1)
-		assert.EqualError(t, err, "pool closed, request rejected: {1 1}")
+		assert.EqualError(t, err, "pool closed, request rejected: {1 1 }")
 	})
 
 	t.Run("submit remove next", func(t *testing.T) {
@@ -259,6 +265,42 @@ func TestReqPoolCapacity(t *testing.T) {
 		timeoutHandler.AssertNumberOfCalls(t, "OnLeaderFwdRequestTimeout", 0)
 		pool.Close()
 	})
+
+	t.Run("full and not draining", func(t *testing.T) {
+		timeoutHandler := &mocks.RequestTimeoutHandler{}
+		pool := bft.NewPool(log, insp, timeoutHandler, bft.PoolOptions{
+			QueueSize:      1,
+			ForwardTimeout: time.Hour,
+			SubmitTimeout:  200 * time.Millisecond,
+		}, submittedChan)
+		defer pool.Close()
+
+		assert.NoError(t, pool.Submit(makeTestRequest("1", "1", "foo")))
+
+		// Nothing leaves the pool, as if the leader is stalled
+		err := pool.Submit(makeTestRequest("2", "2", "foo"))
+		assert.ErrorIs(t, err, bft.ErrLeaderNotDraining)
+
+		// A request leaves the pool, but two submitters compete on its place
+		results := make(chan error, 2)
+		for i := 2; i <= 3; i++ {
+			go func(i string) {
+				results <- pool.Submit(makeTestRequest(i, i, "foo"))
+			}(fmt.Sprintf("%d", i))
+		}
+		time.Sleep(50 * time.Millisecond)
+		assert.NoError(t, pool.RemoveRequest(types.RequestInfo{ID: "1", ClientID: "1"}))
+
+		var errs []error
+		for i := 0; i < 2; i++ {
+			if err := <-results; err != nil {
+				errs = append(errs, err)
+			}
+		}
+		assert.Len(t, errs, 1)
+		assert.NotErrorIs(t, errs[0], bft.ErrLeaderNotDraining)
+		assert.Contains(t, errs[0].Error(), "context deadline exceeded")
+	})
 }
 
 func TestReqPoolPrune(t *testing.T) {
@@ -301,6 +343,89 @@ func TestReqPoolPrune(t *testing.T) {
 	pool.Close()
 }
 
+func TestReqPoolLatency(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	latencies := &latencyRecorder{}
+	poolMetrics := api.NewMetricsRequestPool(latencies)
+
+	insp := &testRequestInspector{}
+	timeoutHandler := &mocks.RequestTimeoutHandler{}
+	pool := bft.NewPool(log, insp, timeoutHandler, bft.PoolOptions{QueueSize: 100, ForwardTimeout: time.Hour, Metrics: poolMetrics}, nil)
+	defer pool.Close()
+
+	var reqs [][]byte
+	for i := 0; i < 10; i++ {
+		req := makeTestRequest("1", strconv.Itoa(i), "foo")
+		assert.NoError(t, pool.Submit(req))
+		reqs = append(reqs, req)
+	}
+	delay := 50 * time.Millisecond
+	time.Sleep(delay)
+
+	// The first request is pruned rather than delivered, so its latency is not measured
+	pool.Prune(func(req []byte) error {
+		if bytes.Equal(req, reqs[0]) {
+			return errors.New("revoked")
+		}
+		return nil
+	})
+	for _, req := range reqs[1:] {
+		assert.NoError(t, pool.RemoveRequest(insp.RequestID(req)))
+	}
+
+	stats := pool.LatencyStats()
+	assert.Equal(t, uint64(9), stats.Count)
+	assert.GreaterOrEqual(t, stats.P50, delay)
+	assert.GreaterOrEqual(t, stats.P99, stats.P50)
+	assert.GreaterOrEqual(t, stats.Max, stats.P99)
+	assert.Less(t, stats.Max, 10*time.Second)
+
+	observations := latencies.Observations()
+	assert.Len(t, observations, 9)
+	for _, seconds := range observations {
+		assert.GreaterOrEqual(t, seconds, delay.Seconds())
+	}
+}
+
+// latencyRecorder is a metrics provider that records the observations of the latency of delivery,
+// and discards all other metrics
+type latencyRecorder struct {
+	disabled.Provider
+	lock         sync.Mutex
+	observations []float64
+}
+
+func (r *latencyRecorder) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
+	if opts.Name != "pool_latency_of_delivery" {
+		return r.Provider.NewHistogram(opts)
+	}
+	return &recordedLatency{recorder: r}
+}
+
+// Observations returns the latencies observed so far, in seconds
+func (r *latencyRecorder) Observations() []float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]float64(nil), r.observations...)
+}
+
+type recordedLatency struct {
+	recorder *latencyRecorder
+}
+
+func (l *recordedLatency) Observe(value float64) {
+	l.recorder.lock.Lock()
+	defer l.recorder.lock.Unlock()
+	l.recorder.observations = append(l.recorder.observations, value)
+}
+
+func (l *recordedLatency) With(...string) metrics.Histogram {
+	return l
+}
+
 func TestReqPoolTimeout(t *testing.T) {
 	basicLog, err := zap.NewDevelopment()
 	assert.NoError(t, err)
@@ -573,6 +698,389 @@ func TestReqPoolTimeout(t *testing.T) {
 	})
 }
 
+func TestReqPoolProcessedCache(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	processed := &testGauge{}
+	metrics := api.NewMetricsRequestPool(&disabled.Provider{})
+	metrics.CountOfProcessedRequests = processed
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{
+		QueueSize:            10,
+		ForwardTimeout:       time.Hour,
+		ProcessedCacheSize:   2,
+		ProcessedCacheMaxAge: 500 * time.Millisecond,
+		Metrics:              metrics,
+	}, make(chan struct{}, 10))
+	defer pool.Close()
+
+	for i := 1; i <= 3; i++ {
+		request := makeTestRequest("alice", strconv.Itoa(i), "foo")
+		assert.NoError(t, pool.Submit(request))
+		assert.NoError(t, pool.RemoveRequest(insp.RequestID(request)))
+	}
+	assert.Equal(t, float64(2), processed.Value())
+
+	// The oldest request is evicted once more than 2 requests are processed
+	assert.NoError(t, pool.Submit(makeTestRequest("alice", "1", "foo")))
+	assert.NoError(t, pool.RemoveRequest(types.RequestInfo{ClientID: "alice", ID: "1"}))
+
+	// Duplicates of requests processed within the window are caught
+	assert.ErrorIs(t, pool.Submit(makeTestRequest("alice", "3", "foo")), bft.ErrReqAlreadyProcessed)
+	assert.ErrorIs(t, pool.Submit(makeTestRequest("alice", "1", "foo")), bft.ErrReqAlreadyProcessed)
+
+	// Requests processed before the window are evicted
+	time.Sleep(600 * time.Millisecond)
+	assert.NoError(t, pool.Submit(makeTestRequest("alice", "3", "foo")))
+	assert.NoError(t, pool.RemoveRequest(types.RequestInfo{ClientID: "alice", ID: "3"}))
+	assert.Equal(t, float64(1), processed.Value())
+}
+
+func TestReqPoolSortBatch(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	requests := [][]byte{
+		makeTestRequest("bob", "2", "foo"),
+		makeTestRequest("alice", "2", "foo"),
+		makeTestRequest("bob", "1", "foo"),
+		makeTestRequest("alice", "1", "foo"),
+	}
+	arrivalOrders := [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 0, 3, 1}}
+
+	batchesOf := func(sortBatch bool) [][][]byte {
+		var batches [][][]byte
+		for _, order := range arrivalOrders {
+			pool := bft.NewPool(log, insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{
+				QueueSize:      10,
+				ForwardTimeout: time.Hour,
+				SortBatch:      sortBatch,
+			}, make(chan struct{}, 1))
+			for _, i := range order {
+				assert.NoError(t, pool.Submit(requests[i]))
+			}
+			batch, full := pool.NextRequests(4, 1000, false)
+			assert.True(t, full)
+			batches = append(batches, batch)
+			pool.Close()
+		}
+		return batches
+	}
+
+	// Without sorting, batches follow the arrival order
+	batches := batchesOf(false)
+	assert.NotEqual(t, batches[0], batches[1])
+	assert.NotEqual(t, batches[0], batches[2])
+
+	// With sorting, all nodes batch the requests in the same order
+	batches = batchesOf(true)
+	expected := [][]byte{requests[3], requests[1], requests[2], requests[0]}
+	for _, batch := range batches {
+		assert.Equal(t, expected, batch)
+	}
+}
+
+func TestReqPoolSubmitBatch(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{
+		QueueSize:      5,
+		ForwardTimeout: time.Hour,
+		SubmitTimeout:  100 * time.Millisecond,
+	}, make(chan struct{}, 10))
+	defer pool.Close()
+
+	single := makeTestRequest("alice", "1", "foo")
+	group := [][]byte{
+		makeTestRequest("bob", "1", "foo"),
+		makeTestRequest("bob", "2", "foo"),
+		makeTestRequest("bob", "3", "foo"),
+	}
+	assert.NoError(t, pool.Submit(single))
+	assert.NoError(t, pool.SubmitBatch(group))
+	assert.Equal(t, 4, pool.Size())
+
+	// A group that overlaps the pool, repeats a request, or does not fit in the pool is rejected as a whole
+	assert.ErrorIs(t, pool.SubmitBatch([][]byte{makeTestRequest("carol", "1", "foo"), single}), bft.ErrReqAlreadyExists)
+	assert.ErrorIs(t, pool.SubmitBatch([][]byte{makeTestRequest("carol", "1", "foo"), makeTestRequest("carol", "1", "foo")}), bft.ErrReqAlreadyExists)
+	assert.Error(t, pool.SubmitBatch([][]byte{makeTestRequest("carol", "1", "foo"), makeTestRequest("carol", "2", "foo")}))
+	assert.Error(t, pool.SubmitBatch(nil))
+	assert.Equal(t, 4, pool.Size())
+
+	// The group is not split between batches
+	batch, full := pool.NextRequests(2, 1000, false)
+	assert.True(t, full)
+	assert.Equal(t, [][]byte{single}, batch)
+
+	// The group is batched as a whole even if it exceeds the batch limits, as long as it is batched on its own
+	assert.NoError(t, pool.RemoveRequest(insp.RequestID(single)))
+	batch, full = pool.NextRequests(2, 1000, false)
+	assert.True(t, full)
+	assert.Equal(t, group, batch)
+
+	// Once some of the requests of the group are removed, the rest are still batched together
+	assert.NoError(t, pool.RemoveRequest(insp.RequestID(group[0])))
+	another := makeTestRequest("alice", "2", "foo")
+	assert.NoError(t, pool.Submit(another))
+	batch, _ = pool.NextRequests(10, 1000, false)
+	assert.Equal(t, [][]byte{group[1], group[2], another}, batch)
+}
+
+func TestReqPoolMaxBatchCount(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{
+		QueueSize:      20,
+		ForwardTimeout: time.Hour,
+		MaxBatchCount:  5,
+	}, make(chan struct{}, 20))
+	defer pool.Close()
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, pool.Submit(makeTestRequest("alice", fmt.Sprintf("%d", i), "foo")))
+	}
+
+	// However many requests are asked for, no more than the cap are returned
+	batch, full := pool.NextRequests(math.MaxInt, math.MaxUint64, false)
+	assert.True(t, full)
+	assert.Len(t, batch, 5)
+	batch, _ = pool.NextRequests(3, math.MaxUint64, false)
+	assert.Len(t, batch, 3)
+
+	// A group of more requests than the cap is rejected
+	var group [][]byte
+	for i := 0; i < 6; i++ {
+		group = append(group, makeTestRequest("bob", fmt.Sprintf("%d", i), "foo"))
+	}
+	assert.ErrorContains(t, pool.SubmitBatch(group), "is larger than the maximal batch count (5)")
+	assert.Equal(t, 10, pool.Size())
+
+	// A reconfiguration changes the cap
+	pool.StopTimers()
+	pool.ChangeOptions(&mocks.RequestTimeoutHandler{}, bft.PoolOptions{ForwardTimeout: time.Hour, MaxBatchCount: 8})
+	pool.RestartTimers()
+	batch, _ = pool.NextRequests(math.MaxInt, math.MaxUint64, false)
+	assert.Len(t, batch, 8)
+
+	// Without a cap, the default cap applies
+	pool.StopTimers()
+	pool.ChangeOptions(&mocks.RequestTimeoutHandler{}, bft.PoolOptions{ForwardTimeout: time.Hour})
+	pool.RestartTimers()
+	batch, _ = pool.NextRequests(math.MaxInt, math.MaxUint64, false)
+	assert.Len(t, batch, 10)
+	assert.NoError(t, pool.SubmitBatch(group))
+}
+
+func TestReqPoolSubmissionTime(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{
+		QueueSize:      10,
+		ForwardTimeout: time.Hour,
+	}, make(chan struct{}, 10))
+	defer pool.Close()
+
+	// A request submitted to this node was submitted when it was added
+	before := time.Now()
+	submittedHere := makeTestRequest("alice", "1", "foo")
+	assert.NoError(t, pool.Submit(submittedHere))
+	submitted, exists := pool.SubmissionTime(insp.RequestID(submittedHere))
+	assert.True(t, exists)
+	assert.False(t, submitted.Before(before))
+
+	// A forwarded request keeps the time it was first submitted
+	firstSubmitted := time.Now().Add(-time.Minute)
+	forwarded := makeTestRequest("alice", "2", "foo")
+	assert.NoError(t, pool.SubmitForwarded(forwarded, firstSubmitted))
+	submitted, exists = pool.SubmissionTime(insp.RequestID(forwarded))
+	assert.True(t, exists)
+	assert.Equal(t, firstSubmitted, submitted)
+
+	_, exists = pool.SubmissionTime(types.RequestInfo{ClientID: "alice", ID: "3"})
+	assert.False(t, exists)
+}
+
+func TestReqPoolMaxPoolBytes(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{
+		QueueSize:      10,
+		ForwardTimeout: time.Hour,
+		MaxPoolBytes:   1000,
+	}, make(chan struct{}, 10))
+	defer pool.Close()
+
+	large := func(clientID, txID string) []byte {
+		return makeTestRequest(clientID, txID, strings.Repeat("a", 300))
+	}
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, pool.Submit(large("alice", fmt.Sprintf("%d", i))))
+	}
+
+	// The pool has room for more requests by count, but not by bytes
+	assert.Equal(t, 3, pool.Size())
+	assert.ErrorIs(t, pool.Submit(large("alice", "4")), bft.ErrPoolMaxBytes)
+	assert.ErrorIs(t, pool.SubmitBatch([][]byte{makeTestRequest("bob", "1", "foo"), large("bob", "2")}), bft.ErrPoolMaxBytes)
+	assert.Equal(t, 3, pool.Size())
+
+	// A small request still fits
+	assert.NoError(t, pool.Submit(makeTestRequest("bob", "1", "foo")))
+
+	// Once a request is removed, its bytes are freed
+	assert.NoError(t, pool.RemoveRequest(types.RequestInfo{ClientID: "alice", ID: "1"}))
+	assert.NoError(t, pool.Submit(large("alice", "4")))
+	assert.Equal(t, 4, pool.Size())
+}
+
+// clientTypeInspector classifies requests by their client.
+type clientTypeInspector struct{}
+
+func (clientTypeInspector) RequestType(req []byte) string {
+	clientID, _, _ := parseTestRequest(req)
+	return clientID
+}
+
+func TestReqPoolMaxBytesPerType(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{
+		QueueSize:       10,
+		ForwardTimeout:  time.Hour,
+		RequestMaxBytes: 100,
+		MaxBytesPerType: map[string]uint64{"config": 200, "payment": 50},
+		TypeInspector:   clientTypeInspector{},
+	}, make(chan struct{}, 10))
+	defer pool.Close()
+
+	// The requests of each type are limited by the limit of their type,
+	// and the requests of other types are limited by the limit of all requests
+	assert.NoError(t, pool.Submit(makeTestRequest("config", "1", strings.Repeat("a", 150))))
+	assert.ErrorContains(t, pool.Submit(makeTestRequest("config", "2", strings.Repeat("a", 250))), "is bigger than request max bytes (200)")
+	assert.NoError(t, pool.Submit(makeTestRequest("payment", "1", strings.Repeat("a", 20))))
+	assert.ErrorContains(t, pool.Submit(makeTestRequest("payment", "2", strings.Repeat("a", 70))), "is bigger than request max bytes (50)")
+	assert.NoError(t, pool.Submit(makeTestRequest("other", "1", strings.Repeat("a", 70))))
+	assert.ErrorContains(t, pool.Submit(makeTestRequest("other", "2", strings.Repeat("a", 150))), "is bigger than request max bytes (100)")
+	assert.Equal(t, 3, pool.Size())
+
+	// A group is rejected if any of its requests exceeds the limit of its type
+	err = pool.SubmitBatch([][]byte{
+		makeTestRequest("config", "3", strings.Repeat("a", 150)),
+		makeTestRequest("payment", "3", strings.Repeat("a", 70)),
+	})
+	assert.ErrorContains(t, err, "is bigger than request max bytes (50)")
+	assert.Equal(t, 3, pool.Size())
+}
+
+func TestReqPoolPrioritize(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(log, insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{
+		QueueSize:      10,
+		ForwardTimeout: time.Hour,
+	}, make(chan struct{}, 10))
+	defer pool.Close()
+
+	single := makeTestRequest("alice", "1", "foo")
+	group := [][]byte{
+		makeTestRequest("bob", "1", "foo"),
+		makeTestRequest("bob", "2", "foo"),
+		makeTestRequest("bob", "3", "foo"),
+	}
+	last := makeTestRequest("carol", "1", "foo")
+	assert.NoError(t, pool.Submit(single))
+	assert.NoError(t, pool.SubmitBatch(group))
+	assert.NoError(t, pool.Submit(last))
+
+	assert.False(t, pool.Prioritize(types.RequestInfo{ClientID: "dave", ID: "1"}))
+
+	assert.True(t, pool.Prioritize(insp.RequestID(last)))
+	batch, _ := pool.NextRequests(10, 1000, false)
+	assert.Equal(t, [][]byte{last, single, group[0], group[1], group[2]}, batch)
+
+	// Prioritizing a request of a group moves the whole group, in its order
+	assert.True(t, pool.Prioritize(insp.RequestID(group[1])))
+	batch, _ = pool.NextRequests(10, 1000, false)
+	assert.Equal(t, [][]byte{group[0], group[1], group[2], last, single}, batch)
+}
+
+func TestReqPoolConcurrentSubmit(t *testing.T) {
+	insp := &testRequestInspector{}
+	pool := bft.NewPool(zap.NewNop().Sugar(), insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{
+		QueueSize:          100,
+		ForwardTimeout:     time.Hour,
+		SubmitTimeout:      time.Minute,
+		ProcessedCacheSize: 10000,
+	}, make(chan struct{}, 1))
+	defer pool.Close()
+
+	// Every request is submitted by two goroutines, while the pool is drained concurrently
+	goroutines, requestsPerGoroutine := 20, 100
+	var submitted, rejected sync.Map
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < requestsPerGoroutine; i++ {
+				req := makeTestRequest("alice", fmt.Sprintf("%d", (g/2)*requestsPerGoroutine+i), "foo")
+				err := pool.Submit(req)
+				if err == nil {
+					_, loaded := submitted.LoadOrStore(string(req), struct{}{})
+					assert.False(t, loaded, "request submitted twice")
+					continue
+				}
+				assert.True(t, errors.Is(err, bft.ErrReqAlreadyExists) || errors.Is(err, bft.ErrReqAlreadyProcessed), "unexpected error %v", err)
+				rejected.Store(string(req), struct{}{})
+			}
+		}(g)
+	}
+
+	unique := goroutines / 2 * requestsPerGoroutine
+	removed := make(map[string]struct{})
+	for len(removed) < unique {
+		batch, _ := pool.NextRequests(10, 1000, false)
+		for _, req := range batch {
+			_, exists := removed[string(req)]
+			assert.False(t, exists, "request batched after it was removed")
+			assert.NoError(t, pool.RemoveRequest(insp.RequestID(req)))
+			removed[string(req)] = struct{}{}
+		}
+	}
+	wg.Wait()
+
+	// No request was lost, and every request was added exactly once
+	assert.Equal(t, 0, pool.Size())
+	for req := range removed {
+		_, ok := submitted.Load(req)
+		assert.True(t, ok)
+		_, ok = rejected.Load(req)
+		assert.True(t, ok)
+	}
+}
+
 func TestMakeRequest(t *testing.T) {
 	r := makeTestRequest("AB", "CDE", "FGHI")
 	assert.Equal(t, 21, len(r))
@@ -0,0 +1,75 @@
+// Copyright IBM Corp. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package bft
+
+import (
+	"encoding/asn1"
+
+	"github.com/golang/protobuf/proto"
+	protos "github.com/hyperledger-labs/SmartBFT/smartbftprotos"
+	"github.com/pkg/errors"
+)
+
+// Snapshot is the committed state of a node: the proposal and signatures of its checkpoint, which is its latest decision,
+// and the content of its write ahead log.
+type Snapshot struct {
+	NodeID     uint64
+	Proposal   *protos.Proposal
+	Signatures []*protos.Signature
+	WAL        [][]byte
+}
+
+type encodedSnapshot struct {
+	NodeID     int64
+	Proposal   []byte
+	Signatures [][]byte
+	WAL        [][]byte
+}
+
+// Marshal encodes the snapshot into bytes
+func (s *Snapshot) Marshal() ([]byte, error) {
+	encoded := encodedSnapshot{
+		NodeID:     int64(s.NodeID),
+		Proposal:   MarshalOrPanic(s.Proposal),
+		Signatures: make([][]byte, 0, len(s.Signatures)),
+		WAL:        s.WAL,
+	}
+	for _, sig := range s.Signatures {
+		encoded.Signatures = append(encoded.Signatures, MarshalOrPanic(sig))
+	}
+	if encoded.WAL == nil {
+		encoded.WAL = [][]byte{}
+	}
+	return asn1.Marshal(encoded)
+}
+
+// UnmarshalSnapshot decodes a snapshot encoded by Snapshot.Marshal
+func UnmarshalSnapshot(bytes []byte) (*Snapshot, error) {
+	encoded := encodedSnapshot{}
+	if _, err := asn1.Unmarshal(bytes, &encoded); err != nil {
+		return nil, errors.Wrap(err, "malformed snapshot")
+	}
+
+	proposal := &protos.Proposal{}
+	if err := proto.Unmarshal(encoded.Proposal, proposal); err != nil {
+		return nil, errors.Wrap(err, "malformed snapshot proposal")
+	}
+	signatures := make([]*protos.Signature, 0, len(encoded.Signatures))
+	for _, rawSig := range encoded.Signatures {
+		sig := &protos.Signature{}
+		if err := proto.Unmarshal(rawSig, sig); err != nil {
+			return nil, errors.Wrap(err, "malformed snapshot signature")
+		}
+		signatures = append(signatures, sig)
+	}
+
+	return &Snapshot{
+		NodeID:     uint64(encoded.NodeID),
+		Proposal:   proposal,
+		Signatures: signatures,
+		WAL:        encoded.WAL,
+	}, nil
+}
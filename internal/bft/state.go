@@ -7,6 +7,9 @@ package bft
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger-labs/SmartBFT/pkg/api"
@@ -33,6 +36,13 @@ type PersistedState struct {
 	Entries          [][]byte
 	Logger           api.Logger
 	WAL              api.WriteAheadLog
+	Metrics          *api.MetricsConsensus
+
+	lastWriteLatency int64
+
+	walLock    sync.Mutex
+	walContent [][]byte
+	walLoaded  bool
 }
 
 func (ps *PersistedState) Save(msgToSave *protos.SavedMessage) error {
@@ -55,7 +65,82 @@ func (ps *PersistedState) Save(msgToSave *protos.SavedMessage) error {
 	//    of the cluster agreeing to a new view configuration.
 	newProposal := msgToSave.GetProposedRecord() != nil
 	// TODO: handle view message here as well, and add "|| finalizedView" to truncate flag
-	return ps.WAL.Append(b, newProposal)
+	begin := time.Now()
+	err = ps.WAL.Append(b, newProposal)
+	ps.recordWriteLatency(time.Since(begin))
+	if err == nil {
+		ps.trackWALContent(b, newProposal)
+	}
+	return err
+}
+
+func (ps *PersistedState) trackWALContent(entry []byte, truncateTo bool) {
+	ps.walLock.Lock()
+	defer ps.walLock.Unlock()
+	ps.loadWALContent()
+	if truncateTo {
+		ps.walContent = nil
+	}
+	ps.walContent = append(ps.walContent, entry)
+}
+
+func (ps *PersistedState) loadWALContent() {
+	if ps.walLoaded {
+		return
+	}
+	ps.walLoaded = true
+	ps.walContent = append([][]byte(nil), ps.Entries...)
+}
+
+// WALContent returns the entries that are currently in the write ahead log
+func (ps *PersistedState) WALContent() [][]byte {
+	ps.walLock.Lock()
+	defer ps.walLock.Unlock()
+	ps.loadWALContent()
+	return append([][]byte(nil), ps.walContent...)
+}
+
+// WALSummary returns a summary of each of the entries that are currently in the write ahead log, in their order
+func (ps *PersistedState) WALSummary() []types.WALEntrySummary {
+	content := ps.WALContent()
+	summary := make([]types.WALEntrySummary, 0, len(content))
+	for _, entry := range content {
+		summary = append(summary, summarizeWALEntry(entry))
+	}
+	return summary
+}
+
+func summarizeWALEntry(entry []byte) types.WALEntrySummary {
+	msg := &protos.SavedMessage{}
+	if err := proto.Unmarshal(entry, msg); err != nil {
+		return types.WALEntrySummary{Type: types.WALEntryMalformed}
+	}
+	switch content := msg.Content.(type) {
+	case *protos.SavedMessage_ProposedRecord:
+		pp := content.ProposedRecord.GetPrePrepare()
+		return types.WALEntrySummary{Type: types.WALEntryProposal, View: pp.GetView(), Seq: pp.GetSeq()}
+	case *protos.SavedMessage_Commit:
+		commit := content.Commit.GetCommit()
+		return types.WALEntrySummary{Type: types.WALEntryCommit, View: commit.GetView(), Seq: commit.GetSeq()}
+	case *protos.SavedMessage_NewView:
+		return types.WALEntrySummary{Type: types.WALEntryNewView, View: content.NewView.GetViewId(), Seq: content.NewView.GetLatestSequence()}
+	case *protos.SavedMessage_ViewChange:
+		return types.WALEntrySummary{Type: types.WALEntryViewChange, View: content.ViewChange.GetNextView()}
+	default:
+		return types.WALEntrySummary{Type: types.WALEntryMalformed}
+	}
+}
+
+func (ps *PersistedState) recordWriteLatency(latency time.Duration) {
+	atomic.StoreInt64(&ps.lastWriteLatency, int64(latency))
+	if ps.Metrics != nil {
+		ps.Metrics.LatencyWALWrite.Observe(latency.Seconds())
+	}
+}
+
+// LastWriteLatency returns how long the most recent write to the write ahead log took
+func (ps *PersistedState) LastWriteLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&ps.lastWriteLatency))
 }
 
 func (ps *PersistedState) storeProposal(proposed *protos.ProposedRecord) {
@@ -71,7 +156,13 @@ func (ps *PersistedState) storeProposal(proposed *protos.ProposedRecord) {
 
 func (ps *PersistedState) storePrepared(commitMsg *protos.Message) {
 	cmt := commitMsg.GetCommit()
-	ps.InFlightProposal.StorePrepares(cmt.View, cmt.Seq)
+	var signatures []types.Signature
+	if sig := cmt.Signature; sig != nil {
+		signatures = append(signatures, types.Signature{ID: sig.Signer, Value: sig.Value, Msg: sig.Msg})
+	}
+	if err := ps.InFlightProposal.StorePrepares(cmt.View, cmt.Seq, signatures...); err != nil {
+		ps.Logger.Errorf("WAL record integrity check failed, the record may be corrupted: %v", err)
+	}
 }
 
 func (ps *PersistedState) LoadNewViewIfApplicable() (*types.ViewAndSeq, error) {
@@ -164,6 +255,11 @@ func (ps *PersistedState) recoverProposed(lastPersistedMessage *protos.ProposedR
 	// Reconstruct the prepare message we shall next broadcast
 	// after the recovery.
 	prp := lastPersistedMessage.GetPrePrepare()
+	v.recoveredPrePrepare = &protos.Message{
+		Content: &protos.Message_PrePrepare{
+			PrePrepare: prp,
+		},
+	}
 	v.lastBroadcastSent = &protos.Message{
 		Content: &protos.Message_Prepare{
 			Prepare: lastPersistedMessage.GetPrepare(),
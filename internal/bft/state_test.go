@@ -7,9 +7,11 @@ package bft_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/hyperledger-labs/SmartBFT/internal/bft"
 	"github.com/hyperledger-labs/SmartBFT/pkg/api"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics"
 	"github.com/hyperledger-labs/SmartBFT/pkg/metrics/disabled"
 	"github.com/hyperledger-labs/SmartBFT/pkg/types"
 	protos "github.com/hyperledger-labs/SmartBFT/smartbftprotos"
@@ -200,3 +202,126 @@ func TestStateRestore(t *testing.T) {
 		})
 	}
 }
+
+type slowWAL struct {
+	delay time.Duration
+}
+
+func (w *slowWAL) Append(_ []byte, _ bool) error {
+	time.Sleep(w.delay)
+	return nil
+}
+
+type recordingHistogram struct {
+	observations []float64
+}
+
+func (h *recordingHistogram) With(...string) metrics.Histogram {
+	return h
+}
+
+func (h *recordingHistogram) Observe(value float64) {
+	h.observations = append(h.observations, value)
+}
+
+func TestStateWriteLatency(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	histogram := &recordingHistogram{}
+	state := &bft.PersistedState{
+		Logger:           log,
+		InFlightProposal: &bft.InFlightData{},
+		WAL:              &slowWAL{delay: 100 * time.Millisecond},
+		Metrics:          &api.MetricsConsensus{LatencyWALWrite: histogram},
+	}
+	assert.Zero(t, state.LastWriteLatency())
+
+	err = state.Save(&protos.SavedMessage{
+		Content: &protos.SavedMessage_NewView{
+			NewView: &protos.ViewMetadata{ViewId: 1, LatestSequence: 2},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.GreaterOrEqual(t, state.LastWriteLatency(), 100*time.Millisecond)
+	assert.Len(t, histogram.observations, 1)
+	assert.GreaterOrEqual(t, histogram.observations[0], 0.1)
+}
+
+func TestStateWALContent(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	restored := bft.MarshalOrPanic(&protos.SavedMessage{
+		Content: &protos.SavedMessage_NewView{
+			NewView: &protos.ViewMetadata{ViewId: 1},
+		},
+	})
+	state := &bft.PersistedState{
+		Logger:           log,
+		InFlightProposal: &bft.InFlightData{},
+		WAL:              &slowWAL{},
+		Entries:          [][]byte{restored},
+	}
+	assert.Equal(t, [][]byte{restored}, state.WALContent())
+
+	newView := &protos.SavedMessage{
+		Content: &protos.SavedMessage_NewView{
+			NewView: &protos.ViewMetadata{ViewId: 2},
+		},
+	}
+	err = state.Save(newView)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{restored, bft.MarshalOrPanic(newView)}, state.WALContent())
+
+	// A new proposal truncates the write ahead log
+	proposed := &protos.SavedMessage{
+		Content: &protos.SavedMessage_ProposedRecord{
+			ProposedRecord: &protos.ProposedRecord{
+				PrePrepare: &protos.PrePrepare{
+					View:     2,
+					Seq:      3,
+					Proposal: &protos.Proposal{Payload: []byte{1}},
+				},
+			},
+		},
+	}
+	err = state.Save(proposed)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{bft.MarshalOrPanic(proposed)}, state.WALContent())
+}
+
+func TestStateWALSummary(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	state := &bft.PersistedState{
+		Logger:           log,
+		InFlightProposal: &bft.InFlightData{},
+		WAL:              &slowWAL{},
+		Entries: [][]byte{
+			bft.MarshalOrPanic(&protos.SavedMessage{
+				Content: &protos.SavedMessage_ViewChange{
+					ViewChange: &protos.ViewChange{NextView: 2},
+				},
+			}),
+			{1, 2, 3},
+		},
+	}
+	err = state.Save(&protos.SavedMessage{
+		Content: &protos.SavedMessage_NewView{
+			NewView: &protos.ViewMetadata{ViewId: 2, LatestSequence: 5},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []types.WALEntrySummary{
+		{Type: types.WALEntryViewChange, View: 2},
+		{Type: types.WALEntryMalformed},
+		{Type: types.WALEntryNewView, View: 2, Seq: 5},
+	}, state.WALSummary())
+}
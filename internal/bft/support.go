@@ -26,6 +26,20 @@ type AssemblerMock interface {
 	api.Assembler
 }
 
+// FallibleAssemblerMock mock for the FallibleAssembler interface
+//
+//go:generate mockery -dir . -name FallibleAssemblerMock -case underscore -output ./mocks/
+type FallibleAssemblerMock interface {
+	api.FallibleAssembler
+}
+
+// CandidateAssemblerMock mock for the CandidateAssembler interface
+//
+//go:generate mockery -dir . -name CandidateAssemblerMock -case underscore -output ./mocks/
+type CandidateAssemblerMock interface {
+	api.CandidateAssembler
+}
+
 // ApplicationMock mock for the Application interface
 //
 //go:generate mockery -dir . -name ApplicationMock -case underscore -output ./mocks/
@@ -62,6 +76,13 @@ type MembershipNotifierMock interface {
 	api.MembershipNotifier
 }
 
+// RequestAbandonedHandlerMock mock for the RequestAbandonedHandler interface
+//
+//go:generate mockery -dir . -name RequestAbandonedHandlerMock -case underscore -output ./mocks/
+type RequestAbandonedHandlerMock interface {
+	api.RequestAbandonedHandler
+}
+
 // Synchronizer mock for the Synchronizer interface (no return value)
 //
 //go:generate mockery -dir . -name Synchronizer -case underscore -output ./mocks/
@@ -12,14 +12,18 @@ import (
 	"encoding/base64"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger-labs/SmartBFT/pkg/api"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics"
 	"github.com/hyperledger-labs/SmartBFT/pkg/types"
 	protos "github.com/hyperledger-labs/SmartBFT/smartbftprotos"
+	"github.com/pkg/errors"
 )
 
 type proposalInfo struct {
@@ -106,6 +110,23 @@ func getLeaderID(
 	panic(fmt.Sprintf("all %d nodes are blacklisted", len(nodes)))
 }
 
+// ProposalLeader returns the leader that proposed the proposal with the given metadata, out of the given nodes.
+func ProposalLeader(md *protos.ViewMetadata, nodes []uint64, leaderRotation bool, decisionsPerLeader uint64) uint64 {
+	return getLeaderID(md.ViewId, uint64(len(nodes)), nodes, leaderRotation, md.DecisionsInView, decisionsPerLeader, md.BlackList)
+}
+
+// GenesisView returns the first view, counting from zero, that the given node leads before any decision is made,
+// and false if the given node never leads such a view.
+func GenesisView(leader uint64, nodes []uint64, leaderRotation bool, decisionsPerLeader uint64) (uint64, bool) {
+	n := uint64(len(nodes))
+	for view := uint64(0); view < n; view++ {
+		if getLeaderID(view, n, nodes, leaderRotation, 0, decisionsPerLeader, nil) == leader {
+			return view, true
+		}
+	}
+	return 0, false
+}
+
 type vote struct {
 	*protos.Message
 	sender uint64
@@ -162,6 +183,21 @@ func (nv *nextViews) sendRecv(next uint64, sender uint64) bool {
 	return next == nv.n[sender]
 }
 
+// highestOf returns the highest next view registered by at least count senders, or zero if there is none
+func (nv *nextViews) highestOf(count int) uint64 {
+	if count <= 0 || len(nv.n) < count {
+		return 0
+	}
+	views := make([]uint64, 0, len(nv.n))
+	for _, next := range nv.n {
+		views = append(views, next)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i] > views[j]
+	})
+	return views[count-1]
+}
+
 type incMsg struct {
 	*protos.Message
 	sender uint64
@@ -186,9 +222,319 @@ func computeQuorum(n uint64) (q int, f int) {
 	return
 }
 
+// ValidateQuorumOverride checks that the given quorum size, overriding the computed quorum of a cluster of size N,
+// is safe: it is not smaller than the computed quorum, so any two quorums still intersect in at least f+1 nodes,
+// and it does not exceed N.
+func ValidateQuorumOverride(n uint64, quorumSize uint64) error {
+	q, _ := computeQuorum(n)
+	if quorumSize < uint64(q) {
+		return errors.Errorf("quorum size %d is smaller than the minimal quorum %d of %d nodes", quorumSize, q, n)
+	}
+	if quorumSize > n {
+		return errors.Errorf("quorum size %d is bigger than the number of nodes %d", quorumSize, n)
+	}
+	return nil
+}
+
+// LeaderHistory records the leader each view was started with, for the most recent Size views.
+type LeaderHistory struct {
+	Size int
+
+	lock    sync.RWMutex
+	leaders map[uint64]uint64
+	views   []uint64
+}
+
+// Record records the given leader for the given view, unless a leader was already recorded for it.
+// When the history is full, the earliest recorded view is evicted.
+func (lh *LeaderHistory) Record(view uint64, leader uint64) {
+	lh.lock.Lock()
+	defer lh.lock.Unlock()
+
+	if lh.leaders == nil {
+		lh.leaders = make(map[uint64]uint64)
+	}
+	if _, exists := lh.leaders[view]; exists {
+		return
+	}
+
+	lh.leaders[view] = leader
+	lh.views = append(lh.views, view)
+	if len(lh.views) > lh.Size {
+		delete(lh.leaders, lh.views[0])
+		lh.views = lh.views[1:]
+	}
+}
+
+// LeaderForView returns the leader recorded for the given view, and false if there is none.
+func (lh *LeaderHistory) LeaderForView(view uint64) (uint64, bool) {
+	lh.lock.RLock()
+	defer lh.lock.RUnlock()
+
+	leader, exists := lh.leaders[view]
+	return leader, exists
+}
+
+// DecisionHistory retains the most recent Size decisions in a ring buffer indexed by their sequences.
+type DecisionHistory struct {
+	Size int
+
+	lock    sync.RWMutex
+	entries []decisionEntry
+}
+
+type decisionEntry struct {
+	seq      uint64
+	decision *types.Decision
+}
+
+// Record retains the given decision with the given sequence, overriding the decision it shares a slot with.
+func (dh *DecisionHistory) Record(seq uint64, decision types.Decision) {
+	if dh.Size <= 0 {
+		return
+	}
+
+	dh.lock.Lock()
+	defer dh.lock.Unlock()
+
+	if dh.entries == nil {
+		dh.entries = make([]decisionEntry, dh.Size)
+	}
+	dh.entries[seq%uint64(dh.Size)] = decisionEntry{seq: seq, decision: &decision}
+}
+
+// Get returns the decision with the given sequence, and false if it isn't retained.
+func (dh *DecisionHistory) Get(seq uint64) (*types.Decision, bool) {
+	dh.lock.RLock()
+	defer dh.lock.RUnlock()
+
+	if len(dh.entries) == 0 {
+		return nil, false
+	}
+	entry := dh.entries[seq%uint64(len(dh.entries))]
+	if entry.decision == nil || entry.seq != seq {
+		return nil, false
+	}
+	decision := *entry.decision
+	return &decision, true
+}
+
+// RequestLatency tracks the time requests are submitted at, and measures the time it takes to deliver them.
+// The latencies are observed by the Histogram, and the most recent Size latencies are retained for LatencyStats.
+type RequestLatency struct {
+	Size      int
+	Histogram metrics.Histogram
+
+	lock      sync.Mutex
+	submitted map[types.RequestInfo]time.Time
+	latencies []time.Duration
+	next      int
+	count     uint64
+}
+
+// Submitted records the time the given request was submitted at, unless it is already known to be submitted earlier.
+func (rl *RequestLatency) Submitted(info types.RequestInfo, at time.Time) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	if rl.submitted == nil {
+		rl.submitted = make(map[types.RequestInfo]time.Time)
+	}
+	if earlier, exists := rl.submitted[info]; exists && earlier.Before(at) {
+		return
+	}
+	rl.submitted[info] = at
+}
+
+// Delivered measures the latency of the given request, if its submission time is known.
+func (rl *RequestLatency) Delivered(info types.RequestInfo, at time.Time) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	submitted, exists := rl.submitted[info]
+	if !exists {
+		return
+	}
+	delete(rl.submitted, info)
+
+	latency := at.Sub(submitted)
+	if rl.Histogram != nil {
+		rl.Histogram.Observe(latency.Seconds())
+	}
+	rl.count++
+	if rl.Size <= 0 {
+		return
+	}
+	if len(rl.latencies) < rl.Size {
+		rl.latencies = append(rl.latencies, latency)
+		return
+	}
+	rl.latencies[rl.next] = latency
+	rl.next = (rl.next + 1) % rl.Size
+}
+
+// Forget stops tracking the given request, which will not be delivered.
+func (rl *RequestLatency) Forget(info types.RequestInfo) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	delete(rl.submitted, info)
+}
+
+// Stats returns the statistics of the latencies retained.
+func (rl *RequestLatency) Stats() types.LatencyStats {
+	rl.lock.Lock()
+	latencies := make([]time.Duration, len(rl.latencies))
+	copy(latencies, rl.latencies)
+	count := rl.count
+	rl.lock.Unlock()
+
+	stats := types.LatencyStats{Count: count}
+	if len(latencies) == 0 {
+		return stats
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	percentile := func(p int) time.Duration {
+		return latencies[(len(latencies)-1)*p/100]
+	}
+	stats.P50 = percentile(50)
+	stats.P99 = percentile(99)
+	stats.Max = latencies[len(latencies)-1]
+	return stats
+}
+
+// DryRun tracks the decisions a joining node verifies without voting, until it verified Decisions
+// consecutive decisions and is promoted to a voter.
+type DryRun struct {
+	Decisions uint64
+
+	lock     sync.Mutex
+	verified uint64
+	promoted bool
+}
+
+// Voting returns whether the node is a voter, that is, it is not or no longer in dry run.
+func (dr *DryRun) Voting() bool {
+	dr.lock.Lock()
+	defer dr.lock.Unlock()
+	return dr.promoted || dr.verified >= dr.Decisions
+}
+
+// Verified counts a decision that was verified, and returns true if it promoted the node to a voter.
+func (dr *DryRun) Verified() bool {
+	dr.lock.Lock()
+	defer dr.lock.Unlock()
+	if dr.promoted {
+		return false
+	}
+	dr.verified++
+	dr.promoted = dr.verified >= dr.Decisions
+	return dr.promoted
+}
+
+// Reset restarts counting the consecutive verified decisions, unless the node was already promoted.
+func (dr *DryRun) Reset() {
+	dr.lock.Lock()
+	defer dr.lock.Unlock()
+	if !dr.promoted {
+		dr.verified = 0
+	}
+}
+
+// proposalSize returns the number of bytes of the given proposal
+func proposalSize(proposal types.Proposal) uint64 {
+	return uint64(len(proposal.Header) + len(proposal.Payload) + len(proposal.Metadata))
+}
+
+// isVote returns whether the message is counted towards a quorum by its receivers
+func isVote(m *protos.Message) bool {
+	switch m.GetContent().(type) {
+	case *protos.Message_Prepare, *protos.Message_Commit, *protos.Message_ViewChange, *protos.Message_ViewData:
+		return true
+	default:
+		return false
+	}
+}
+
+// forwardedRequest is a request forwarded to the leader along with the time it was submitted
+type forwardedRequest struct {
+	Request   []byte
+	Submitted int64 // Unix time in nanoseconds
+}
+
+func wrapForwardedRequest(request []byte, submitted time.Time) []byte {
+	raw, err := asn1.Marshal(forwardedRequest{Request: request, Submitted: submitted.UnixNano()})
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+func unwrapForwardedRequest(raw []byte) ([]byte, time.Time, error) {
+	fwd := forwardedRequest{}
+	rest, err := asn1.Unmarshal(raw, &fwd)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "malformed forwarded request")
+	}
+	if len(rest) > 0 {
+		return nil, time.Time{}, errors.Errorf("malformed forwarded request: %d trailing bytes", len(rest))
+	}
+	return fwd.Request, time.Unix(0, fwd.Submitted), nil
+}
+
+// requestDigests are the infos of the requests a proposal payload consists of, which are sent instead of the payload
+type requestDigests struct {
+	Requests []requestDigest
+}
+
+type requestDigest struct {
+	ID       []byte
+	ClientID []byte
+	TraceID  []byte
+}
+
+func marshalRequestDigests(infos []types.RequestInfo) []byte {
+	digests := requestDigests{Requests: make([]requestDigest, 0, len(infos))}
+	for _, info := range infos {
+		digests.Requests = append(digests.Requests, requestDigest{
+			ID:       []byte(info.ID),
+			ClientID: []byte(info.ClientID),
+			TraceID:  []byte(info.TraceID),
+		})
+	}
+	raw, err := asn1.Marshal(digests)
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+func unmarshalRequestDigests(raw []byte) ([]types.RequestInfo, error) {
+	digests := requestDigests{}
+	if _, err := asn1.Unmarshal(raw, &digests); err != nil {
+		return nil, errors.Wrap(err, "malformed request digests")
+	}
+	infos := make([]types.RequestInfo, 0, len(digests.Requests))
+	for _, digest := range digests.Requests {
+		infos = append(infos, types.RequestInfo{
+			ID:       string(digest.ID),
+			ClientID: string(digest.ClientID),
+			TraceID:  string(digest.TraceID),
+		})
+	}
+	return infos, nil
+}
+
 // InFlightData records proposals that are in-flight,
 // as well as their corresponding prepares.
+// Storing a proposal supersedes the previous one along with its prepares,
+// and once the in-flight proposal is committed it is cleared altogether.
 type InFlightData struct {
+	// Verifier, if set, verifies the signatures attesting that the in-flight proposal is prepared before they are stored.
+	Verifier api.Verifier
+
 	lock sync.RWMutex
 	v    *inFlightProposalData
 }
@@ -196,6 +542,7 @@ type InFlightData struct {
 type inFlightProposalData struct {
 	proposal *types.Proposal
 	prepared bool
+	prepares map[uint64]types.Signature
 }
 
 // InFlightProposal returns an in-flight proposal or nil if there is no such.
@@ -223,6 +570,7 @@ func (ifp *InFlightData) IsInFlightPrepared() bool {
 }
 
 // StoreProposal stores an in-flight proposal.
+// The proposal must have been verified beforehand, as it is surfaced to other nodes during a view change.
 func (ifp *InFlightData) StoreProposal(prop types.Proposal) {
 	p := prop
 
@@ -232,18 +580,71 @@ func (ifp *InFlightData) StoreProposal(prop types.Proposal) {
 	ifp.v = &inFlightProposalData{proposal: &p}
 }
 
-// StorePrepares stores alongside the already stored in-flight proposal that it is prepared.
-func (ifp *InFlightData) StorePrepares(view, seq uint64) {
+// StorePrepares stores alongside the already stored in-flight proposal that it is prepared,
+// along with the given signatures attesting to it, keyed by their signer.
+// If a Verifier is set, signatures that fail verification are discarded and an error is returned,
+// but the proposal is considered prepared regardless, since the node already voted on it.
+func (ifp *InFlightData) StorePrepares(view, seq uint64, signatures ...types.Signature) error {
 	prop := ifp.InFlightProposal()
 	if prop == nil {
 		panic("stored prepares but proposal is not initialized")
 	}
 	p := prop
 
+	var err error
+	prepares := make(map[uint64]types.Signature, len(signatures))
+	for _, sig := range signatures {
+		if ifp.Verifier != nil {
+			if _, verr := ifp.Verifier.VerifyConsenterSig(sig, *p); verr != nil {
+				err = errors.Wrapf(verr, "prepare signature of %d for seq %d in view %d failed verification", sig.ID, seq, view)
+				continue
+			}
+		}
+		prepares[sig.ID] = sig
+	}
+
 	ifp.lock.Lock()
 	defer ifp.lock.Unlock()
 
-	ifp.v = &inFlightProposalData{proposal: p, prepared: true}
+	ifp.v = &inFlightProposalData{proposal: p, prepared: true, prepares: prepares}
+
+	return err
+}
+
+// InFlightPrepares returns the stored signatures attesting that the in-flight proposal is prepared,
+// sorted by their signer.
+func (ifp *InFlightData) InFlightPrepares() []types.Signature {
+	ifp.lock.RLock()
+	defer ifp.lock.RUnlock()
+
+	if ifp.v == nil || len(ifp.v.prepares) == 0 {
+		return nil
+	}
+
+	prepares := make([]types.Signature, 0, len(ifp.v.prepares))
+	for _, sig := range ifp.v.prepares {
+		prepares = append(prepares, sig)
+	}
+	sort.Slice(prepares, func(i, j int) bool {
+		return prepares[i].ID < prepares[j].ID
+	})
+	return prepares
+}
+
+// ClearCommitted clears the in-flight proposal along with its prepares,
+// if its sequence is not greater than the given committed sequence.
+func (ifp *InFlightData) ClearCommitted(seq uint64) {
+	ifp.lock.Lock()
+	defer ifp.lock.Unlock()
+
+	if ifp.v == nil {
+		return
+	}
+	md := &protos.ViewMetadata{}
+	if err := proto.Unmarshal(ifp.v.proposal.Metadata, md); err != nil || md.LatestSequence > seq {
+		return
+	}
+	ifp.v = nil
 }
 
 func (ifp *InFlightData) clear() {
@@ -253,6 +654,91 @@ func (ifp *InFlightData) clear() {
 	ifp.v = nil
 }
 
+// VerificationLimiter bounds the number of concurrent verifications of the verifiers it limits,
+// which share its limit. Verifications beyond the limit wait for one of the running ones to finish.
+type VerificationLimiter struct {
+	sem chan struct{}
+}
+
+// NewVerificationLimiter returns a VerificationLimiter that lets at most the given number of verifications run concurrently
+func NewVerificationLimiter(limit uint64) *VerificationLimiter {
+	return &VerificationLimiter{sem: make(chan struct{}, limit)}
+}
+
+// Verifier returns the given verifier with its verifications bounded by the limit.
+// If the verifier is a BatchVerifier, so is the returned verifier, and a batch verification counts as one.
+func (vl *VerificationLimiter) Verifier(verifier api.Verifier) api.Verifier {
+	limited := &limitedVerifier{Verifier: verifier, limiter: vl}
+	if batchVerifier, ok := verifier.(api.BatchVerifier); ok {
+		return &limitedBatchVerifier{limitedVerifier: limited, batchVerifier: batchVerifier}
+	}
+	return limited
+}
+
+// ClientSignatureVerifier returns the given client signature verifier with its verifications bounded by the limit
+func (vl *VerificationLimiter) ClientSignatureVerifier(verifier api.ClientSignatureVerifier) api.ClientSignatureVerifier {
+	return &limitedClientSignatureVerifier{verifier: verifier, limiter: vl}
+}
+
+func (vl *VerificationLimiter) acquire() {
+	vl.sem <- struct{}{}
+}
+
+func (vl *VerificationLimiter) release() {
+	<-vl.sem
+}
+
+type limitedVerifier struct {
+	api.Verifier
+	limiter *VerificationLimiter
+}
+
+func (lv *limitedVerifier) VerifyProposal(proposal types.Proposal) ([]types.RequestInfo, error) {
+	lv.limiter.acquire()
+	defer lv.limiter.release()
+	return lv.Verifier.VerifyProposal(proposal)
+}
+
+func (lv *limitedVerifier) VerifyRequest(val []byte) (types.RequestInfo, error) {
+	lv.limiter.acquire()
+	defer lv.limiter.release()
+	return lv.Verifier.VerifyRequest(val)
+}
+
+func (lv *limitedVerifier) VerifyConsenterSig(signature types.Signature, prop types.Proposal) ([]byte, error) {
+	lv.limiter.acquire()
+	defer lv.limiter.release()
+	return lv.Verifier.VerifyConsenterSig(signature, prop)
+}
+
+func (lv *limitedVerifier) VerifySignature(signature types.Signature) error {
+	lv.limiter.acquire()
+	defer lv.limiter.release()
+	return lv.Verifier.VerifySignature(signature)
+}
+
+type limitedBatchVerifier struct {
+	*limitedVerifier
+	batchVerifier api.BatchVerifier
+}
+
+func (lbv *limitedBatchVerifier) VerifyConsenterSigs(signatures []types.Signature, prop types.Proposal) []error {
+	lbv.limiter.acquire()
+	defer lbv.limiter.release()
+	return lbv.batchVerifier.VerifyConsenterSigs(signatures, prop)
+}
+
+type limitedClientSignatureVerifier struct {
+	verifier api.ClientSignatureVerifier
+	limiter  *VerificationLimiter
+}
+
+func (lcv *limitedClientSignatureVerifier) VerifyClientSignature(req []byte) error {
+	lcv.limiter.acquire()
+	defer lcv.limiter.release()
+	return lcv.verifier.VerifyClientSignature(req)
+}
+
 // ProposalMaker implements ProposerBuilder
 type ProposalMaker struct {
 	DecisionsPerLeader uint64
@@ -269,8 +755,14 @@ type ProposalMaker struct {
 	Verifier           api.Verifier
 	Signer             api.Signer
 	MembershipNotifier api.MembershipNotifier
+	BatchObserver      api.BatchObserver
+	ProposedRequests   ProposedRequestsMarker
 	State              State
+	PrepareQuorum      int
+	CommitQuorum       int
 	InMsqQSize         int
+	AggregationWindow  time.Duration
+	ResendRecovered    bool
 	ViewSequences      *atomic.Value
 	restoreOnceFromWAL sync.Once
 	Checkpoint         *types.Checkpoint
@@ -286,6 +778,8 @@ func (pm *ProposalMaker) NewProposer(leader, proposalSequence, viewNum, decision
 		LeaderID:           leader,
 		SelfID:             pm.SelfID,
 		Quorum:             quorumSize,
+		PrepareQuorum:      pm.PrepareQuorum,
+		CommitQuorum:       pm.CommitQuorum,
 		Number:             viewNum,
 		Decider:            pm.Decider,
 		FailureDetector:    pm.FailureDetector,
@@ -295,10 +789,14 @@ func (pm *ProposalMaker) NewProposer(leader, proposalSequence, viewNum, decision
 		Verifier:           pm.Verifier,
 		Signer:             pm.Signer,
 		MembershipNotifier: pm.MembershipNotifier,
+		BatchObserver:      pm.BatchObserver,
+		ProposedRequests:   pm.ProposedRequests,
 		ProposalSequence:   proposalSequence,
 		DecisionsInView:    decisionsInView,
 		State:              pm.State,
 		InMsgQSize:         pm.InMsqQSize,
+		AggregationWindow:  pm.AggregationWindow,
+		ResendRecovered:    pm.ResendRecovered,
 		ViewSequences:      pm.ViewSequences,
 		MetricsBlacklist:   pm.MetricsBlacklist,
 		MetricsView:        pm.MetricsView,
@@ -547,6 +1045,20 @@ func pruneBlacklist(prevBlacklist []uint64, preparesFrom map[uint64]*protos.Prep
 	return newBlackList
 }
 
+// sortedSignatures returns a copy of the given signatures sorted by the ID of their signers,
+// so that all nodes deliver the signatures of a decision in the same order
+func sortedSignatures(signatures []types.Signature) []types.Signature {
+	if signatures == nil {
+		return nil
+	}
+	sorted := make([]types.Signature, len(signatures))
+	copy(sorted, signatures)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
 func equalIntLists(a, b []uint64) bool {
 	if len(a) != len(b) {
 		return false
@@ -7,7 +7,10 @@ package bft
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/hyperledger-labs/SmartBFT/pkg/api"
 	"github.com/hyperledger-labs/SmartBFT/pkg/metrics/disabled"
@@ -132,6 +135,158 @@ func TestInFlightProposalLatest(t *testing.T) {
 	assert.Equal(t, prop, *ifp.InFlightProposal())
 }
 
+func TestInFlightProposalLifecycle(t *testing.T) {
+	proposalOfSeq := func(seq uint64) types.Proposal {
+		return types.Proposal{
+			Metadata: MarshalOrPanic(&protos.ViewMetadata{LatestSequence: seq}),
+			Payload:  []byte{byte(seq)},
+		}
+	}
+
+	ifp := &InFlightData{}
+	ifp.ClearCommitted(1) // nothing to clear
+	assert.Nil(t, ifp.InFlightProposal())
+
+	ifp.StoreProposal(proposalOfSeq(1))
+	ifp.StorePrepares(0, 1)
+	assert.True(t, ifp.IsInFlightPrepared())
+
+	// A new proposal supersedes the previous one along with its prepares
+	ifp.StoreProposal(proposalOfSeq(2))
+	assert.Equal(t, proposalOfSeq(2), *ifp.InFlightProposal())
+	assert.False(t, ifp.IsInFlightPrepared())
+	ifp.StorePrepares(0, 2)
+
+	// Committing an earlier sequence does not clear it
+	ifp.ClearCommitted(1)
+	assert.Equal(t, proposalOfSeq(2), *ifp.InFlightProposal())
+	assert.True(t, ifp.IsInFlightPrepared())
+
+	ifp.ClearCommitted(2)
+	assert.Nil(t, ifp.InFlightProposal())
+	assert.False(t, ifp.IsInFlightPrepared())
+}
+
+func TestInFlightVerifiedPrepares(t *testing.T) {
+	prop := types.Proposal{
+		Metadata: MarshalOrPanic(&protos.ViewMetadata{LatestSequence: 1}),
+		Payload:  []byte{1},
+	}
+
+	ifp := &InFlightData{Verifier: &consenterSigVerifier{}}
+	ifp.StoreProposal(prop)
+	err := ifp.StorePrepares(0, 1,
+		types.Signature{ID: 3, Value: []byte("valid")},
+		types.Signature{ID: 1, Value: []byte("forged")},
+		types.Signature{ID: 2, Value: []byte("valid")},
+		types.Signature{ID: 4, Value: []byte("forged")},
+	)
+	assert.Error(t, err)
+	assert.True(t, ifp.IsInFlightPrepared())
+	assert.Equal(t, []types.Signature{
+		{ID: 2, Value: []byte("valid")},
+		{ID: 3, Value: []byte("valid")},
+	}, ifp.InFlightPrepares())
+
+	// A proposal with no valid prepares is still considered prepared
+	ifp.StoreProposal(prop)
+	err = ifp.StorePrepares(0, 1, types.Signature{ID: 1, Value: []byte("forged")})
+	assert.EqualError(t, err, "prepare signature of 1 for seq 1 in view 0 failed verification: bad signature")
+	assert.True(t, ifp.IsInFlightPrepared())
+	assert.Nil(t, ifp.InFlightPrepares())
+
+	// Without a verifier, all prepares are retained
+	ifp = &InFlightData{}
+	ifp.StoreProposal(prop)
+	err = ifp.StorePrepares(0, 1, types.Signature{ID: 1, Value: []byte("forged")})
+	assert.NoError(t, err)
+	assert.True(t, ifp.IsInFlightPrepared())
+	assert.Len(t, ifp.InFlightPrepares(), 1)
+}
+
+type consenterSigVerifier struct {
+	api.Verifier
+}
+
+func (*consenterSigVerifier) VerifyConsenterSig(signature types.Signature, _ types.Proposal) ([]byte, error) {
+	if string(signature.Value) != "valid" {
+		return nil, fmt.Errorf("bad signature")
+	}
+	return nil, nil
+}
+
+func TestVerificationLimiter(t *testing.T) {
+	verifier := &concurrencyCountingVerifier{}
+	limiter := NewVerificationLimiter(3)
+	limitedVerifier := limiter.Verifier(verifier)
+	limitedClientSigVerifier := limiter.ClientSignatureVerifier(verifier)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			limitedVerifier.VerifyProposal(types.Proposal{})
+		}()
+		go func() {
+			defer wg.Done()
+			limitedVerifier.VerifyRequest(nil)
+		}()
+		go func() {
+			defer wg.Done()
+			limitedVerifier.VerifyConsenterSig(types.Signature{}, types.Proposal{})
+		}()
+		go func() {
+			defer wg.Done()
+			limitedClientSigVerifier.VerifyClientSignature(nil)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(400), verifier.invocations.Load())
+	assert.Equal(t, int32(3), verifier.maxConcurrent.Load())
+}
+
+type concurrencyCountingVerifier struct {
+	api.Verifier
+	running       atomic.Int32
+	maxConcurrent atomic.Int32
+	invocations   atomic.Int32
+}
+
+func (v *concurrencyCountingVerifier) verify() {
+	running := v.running.Add(1)
+	defer v.running.Add(-1)
+	v.invocations.Add(1)
+	for {
+		maxConcurrent := v.maxConcurrent.Load()
+		if running <= maxConcurrent || v.maxConcurrent.CompareAndSwap(maxConcurrent, running) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+}
+
+func (v *concurrencyCountingVerifier) VerifyProposal(types.Proposal) ([]types.RequestInfo, error) {
+	v.verify()
+	return nil, nil
+}
+
+func (v *concurrencyCountingVerifier) VerifyRequest([]byte) (types.RequestInfo, error) {
+	v.verify()
+	return types.RequestInfo{}, nil
+}
+
+func (v *concurrencyCountingVerifier) VerifyConsenterSig(types.Signature, types.Proposal) ([]byte, error) {
+	v.verify()
+	return nil, nil
+}
+
+func (v *concurrencyCountingVerifier) VerifyClientSignature([]byte) error {
+	v.verify()
+	return nil
+}
+
 func TestQuorum(t *testing.T) {
 	// Ensure that quorum size is as expected.
 
@@ -162,6 +317,105 @@ func TestQuorum(t *testing.T) {
 	}
 }
 
+func TestValidateQuorumOverride(t *testing.T) {
+	assert.NoError(t, ValidateQuorumOverride(4, 3))
+	assert.NoError(t, ValidateQuorumOverride(4, 4))
+	assert.NoError(t, ValidateQuorumOverride(6, 5))
+	assert.EqualError(t, ValidateQuorumOverride(4, 2), "quorum size 2 is smaller than the minimal quorum 3 of 4 nodes")
+	assert.EqualError(t, ValidateQuorumOverride(6, 3), "quorum size 3 is smaller than the minimal quorum 4 of 6 nodes")
+	assert.EqualError(t, ValidateQuorumOverride(4, 5), "quorum size 5 is bigger than the number of nodes 4")
+}
+
+func TestLeaderHistory(t *testing.T) {
+	lh := &LeaderHistory{Size: 2}
+
+	_, exists := lh.LeaderForView(0)
+	assert.False(t, exists)
+
+	lh.Record(0, 1)
+	lh.Record(0, 2) // the leader the view started with is kept
+	lh.Record(3, 4)
+	leader, exists := lh.LeaderForView(0)
+	assert.True(t, exists)
+	assert.Equal(t, uint64(1), leader)
+
+	lh.Record(5, 2) // evicts view 0
+	_, exists = lh.LeaderForView(0)
+	assert.False(t, exists)
+	leader, exists = lh.LeaderForView(3)
+	assert.True(t, exists)
+	assert.Equal(t, uint64(4), leader)
+	leader, exists = lh.LeaderForView(5)
+	assert.True(t, exists)
+	assert.Equal(t, uint64(2), leader)
+}
+
+func TestDecisionHistory(t *testing.T) {
+	dh := &DecisionHistory{Size: 2}
+
+	_, exists := dh.Get(1)
+	assert.False(t, exists)
+
+	for seq := uint64(1); seq <= 3; seq++ {
+		dh.Record(seq, types.Decision{Proposal: types.Proposal{Payload: []byte{byte(seq)}}})
+	}
+
+	// Sequence 1 was overridden by sequence 3
+	_, exists = dh.Get(1)
+	assert.False(t, exists)
+	for seq := uint64(2); seq <= 3; seq++ {
+		decision, exists := dh.Get(seq)
+		assert.True(t, exists)
+		assert.Equal(t, []byte{byte(seq)}, decision.Proposal.Payload)
+	}
+	_, exists = dh.Get(4)
+	assert.False(t, exists)
+
+	// A history without a size retains nothing
+	dh = &DecisionHistory{}
+	dh.Record(1, types.Decision{})
+	_, exists = dh.Get(1)
+	assert.False(t, exists)
+}
+
+func TestDryRun(t *testing.T) {
+	dr := &DryRun{Decisions: 2}
+	assert.False(t, dr.Voting())
+
+	assert.False(t, dr.Verified())
+	assert.False(t, dr.Voting())
+
+	// A sync restarts counting the consecutive decisions
+	dr.Reset()
+	assert.False(t, dr.Verified())
+	assert.True(t, dr.Verified())
+	assert.True(t, dr.Voting())
+
+	// Once promoted, the node keeps voting
+	dr.Reset()
+	assert.False(t, dr.Verified())
+	assert.True(t, dr.Voting())
+
+	// Without a dry run the node votes right away
+	assert.True(t, (&DryRun{}).Voting())
+}
+
+func TestGenesisView(t *testing.T) {
+	nodes := []uint64{1, 2, 3, 4}
+
+	for _, leaderRotation := range []bool{false, true} {
+		for view := uint64(0); view < uint64(len(nodes)); view++ {
+			leader := getLeaderID(view, uint64(len(nodes)), nodes, leaderRotation, 0, 1, nil)
+			genesisView, exists := GenesisView(leader, nodes, leaderRotation, 1)
+			assert.True(t, exists)
+			assert.Equal(t, view, genesisView)
+		}
+	}
+
+	_, exists := GenesisView(5, nodes, true, 1)
+	assert.False(t, exists)
+}
+
 func TestGetLeaderId(t *testing.T) {
 	nodes := []uint64{1, 2, 3, 4}
 	view := uint64(0)
@@ -65,6 +65,12 @@ type Comm interface {
 
 type CheckpointRetriever func() (*protos.Proposal, []*protos.Signature)
 
+// ProposedRequestsMarker marks the requests of the proposals the node accepted.
+// This is implemented by the Pool.
+type ProposedRequestsMarker interface {
+	MarkProposed(requests []types.RequestInfo)
+}
+
 // View is responsible for running the view protocol
 type View struct {
 	// Configuration
@@ -75,6 +81,8 @@ type View struct {
 	NodesList          []uint64
 	LeaderID           uint64
 	Quorum             int
+	PrepareQuorum      int // if zero, Quorum is used
+	CommitQuorum       int // if zero, Quorum is used
 	Number             uint64
 	Decider            Decider
 	FailureDetector    FailureDetector
@@ -84,18 +92,24 @@ type View struct {
 	Verifier           api.Verifier
 	Signer             api.Signer
 	MembershipNotifier api.MembershipNotifier
+	BatchObserver      api.BatchObserver
+	ProposedRequests   ProposedRequestsMarker // if set, marks the requests of the proposals the view accepts
 	ProposalSequence   uint64
 	DecisionsInView    uint64
 	State              State
 	Phase              Phase
 	InMsgQSize         int
+	AggregationWindow  time.Duration
+	ResendRecovered    bool
 	// Runtime
+	currentSeq            uint64
 	lastVotedProposalByID map[uint64]*protos.Commit
 	incMsgs               chan *incMsg
 	myProposalSig         *types.Signature
 	inFlightProposal      *types.Proposal
 	inFlightRequests      []types.RequestInfo
 	lastBroadcastSent     *protos.Message
+	recoveredPrePrepare   *protos.Message // the pre-prepare of the proposal restored from the WAL
 	// Current sequence sent prepare and commit
 	currPrepareSent *protos.Message
 	currCommitSent  *protos.Message
@@ -130,12 +144,17 @@ func (v *View) Start() {
 	v.abortChan = make(chan struct{})
 	v.lastVotedProposalByID = make(map[uint64]*protos.Commit)
 	v.viewEnded.Add(1)
+	atomic.StoreUint64(&v.currentSeq, v.ProposalSequence)
 
 	v.prePrepare = make(chan *protos.Message, 1)
 	v.nextPrePrepare = make(chan *protos.Message, 1)
 
 	v.setupVotes()
 
+	// The per view counters count only the decisions of this view, unlike the cumulative counters
+	v.MetricsView.CountBatchesInView.Set(0)
+	v.MetricsView.CountTxsInView.Set(0)
+
 	go func() {
 		v.run()
 	}()
@@ -282,6 +301,7 @@ func (v *View) run() {
 func (v *View) doPhase() {
 	switch v.Phase {
 	case PROPOSED:
+		v.resendRecoveredPrePrepare()
 		v.Comm.BroadcastConsensus(v.lastBroadcastSent) // broadcast here serves also recovery
 		v.Phase = v.processPrepares()
 	case PREPARED:
@@ -307,6 +327,13 @@ func (v *View) processPrePrepare(pp *protos.PrePrepare, m *protos.Message, msgFo
 		v.Logger.Warnf("%d got pre-prepare from %d but the leader is %d", v.SelfID, sender, v.LeaderID)
 		return
 	}
+	if err := verifyPrePrepareMetadata(pp); err != nil {
+		v.Logger.Warnf("%d received bad proposal from %d: %v", v.SelfID, sender, err)
+		v.FailureDetector.Complain(v.Number, false)
+		v.Sync.Sync()
+		v.stop()
+		return
+	}
 
 	prePrepareChan := v.prePrepare
 	currentOrNext := "current"
@@ -323,6 +350,22 @@ func (v *View) processPrePrepare(pp *protos.PrePrepare, m *protos.Message, msgFo
 	}
 }
 
+// verifyPrePrepareMetadata verifies that the view metadata embedded in the proposal matches the view and
+// sequence of the pre-prepare carrying it. Metadata that cannot be parsed is rejected when the proposal is verified.
+func verifyPrePrepareMetadata(pp *protos.PrePrepare) error {
+	md := &protos.ViewMetadata{}
+	if err := proto.Unmarshal(pp.Proposal.Metadata, md); err != nil {
+		return nil
+	}
+	if md.ViewId != pp.View {
+		return errors.Errorf("invalid view number: metadata view %d mismatches pre-prepare view %d", md.ViewId, pp.View)
+	}
+	if md.LatestSequence != pp.Seq {
+		return errors.Errorf("invalid proposal sequence: metadata sequence %d mismatches pre-prepare sequence %d", md.LatestSequence, pp.Seq)
+	}
+	return nil
+}
+
 func (v *View) prepared() Phase {
 	proposal := v.inFlightProposal
 	signatures, phase := v.processCommits(proposal)
@@ -336,6 +379,8 @@ func (v *View) prepared() Phase {
 
 	v.MetricsView.CountBatchAll.Add(1)
 	v.MetricsView.CountTxsAll.Add(float64(len(v.inFlightRequests)))
+	v.MetricsView.CountBatchesInView.Add(1)
+	v.MetricsView.CountTxsInView.Add(float64(len(v.inFlightRequests)))
 	size := 0
 	size += len(proposal.Metadata) + len(proposal.Header) + len(proposal.Payload)
 	for i := range signatures {
@@ -394,6 +439,9 @@ func (v *View) processProposal() Phase {
 
 	v.MetricsView.CountTxsInBatch.Set(float64(len(requests)))
 	v.beginPrePrepare = time.Now()
+	if v.BatchObserver != nil {
+		v.BatchObserver.OnBatch(proposal, requests)
+	}
 
 	seq := v.ProposalSequence
 
@@ -417,6 +465,9 @@ func (v *View) processProposal() Phase {
 	v.currPrepareSent.GetPrepare().Assist = true
 	v.inFlightProposal = &proposal
 	v.inFlightRequests = requests
+	if v.ProposedRequests != nil {
+		v.ProposedRequests.MarkProposed(requests)
+	}
 
 	if v.SelfID == v.LeaderID {
 		v.Comm.BroadcastConsensus(receivedProposal)
@@ -426,6 +477,23 @@ func (v *View) processProposal() Phase {
 	return PROPOSED
 }
 
+// resendRecoveredPrePrepare makes a leader that restarted in the middle of its proposal resend the pre-prepare
+// it restored from the WAL, since the followers might not have received it before the restart.
+// Thus, the leader completes its original proposal rather than waiting for a view change.
+func (v *View) resendRecoveredPrePrepare() {
+	pp := v.recoveredPrePrepare
+	v.recoveredPrePrepare = nil
+	if pp == nil || !v.ResendRecovered || v.SelfID != v.LeaderID {
+		return
+	}
+	prePrepare := pp.GetPrePrepare()
+	if prePrepare.View != v.Number || prePrepare.Seq != v.ProposalSequence {
+		return
+	}
+	v.Logger.Infof("Resending the recovered proposal with seq %d in view %d", prePrepare.Seq, prePrepare.View)
+	v.Comm.BroadcastConsensus(pp)
+}
+
 func (v *View) createPrepare(seq uint64, proposal types.Proposal) *protos.Message {
 	return &protos.Message{
 		Content: &protos.Message_Prepare{
@@ -438,12 +506,26 @@ func (v *View) createPrepare(seq uint64, proposal types.Proposal) *protos.Messag
 	}
 }
 
+func (v *View) prepareQuorum() int {
+	if v.PrepareQuorum != 0 {
+		return v.PrepareQuorum
+	}
+	return v.Quorum
+}
+
+func (v *View) commitQuorum() int {
+	if v.CommitQuorum != 0 {
+		return v.CommitQuorum
+	}
+	return v.Quorum
+}
+
 func (v *View) processPrepares() Phase {
 	proposal := v.inFlightProposal
 	expectedDigest := proposal.Digest()
 
 	var voterIDs []uint64
-	for len(voterIDs) < v.Quorum-1 {
+	for len(voterIDs) < v.prepareQuorum()-1 {
 		select {
 		case <-v.abortChan:
 			return ABORT
@@ -528,17 +610,34 @@ func (v *View) processCommits(proposal *types.Proposal) ([]types.Signature, Phas
 
 	var voterIDs []uint64
 
-	for len(signatures) < v.Quorum-1 {
+	// Commits are accumulated for the aggregation window and then verified together,
+	// unless they may complete the quorum, in which case they are verified right away.
+	var pending []*protos.Message
+	var dispatched int
+	var windowEnded <-chan time.Time
+	verifyPending := func() {
+		// Valid votes end up written into the 'validVotes' channel.
+		go signatureCollector.verifyVotes(pending)
+		dispatched += len(pending)
+		pending = nil
+		windowEnded = nil
+	}
+
+	for len(signatures) < v.commitQuorum()-1 {
 		select {
 		case <-v.abortChan:
 			return nil, ABORT
 		case msg := <-v.incMsgs:
 			v.processMsg(msg.sender, msg.Message)
 		case vote := <-v.commits.votes:
-			// Valid votes end up written into the 'validVotes' channel.
-			go func(vote *protos.Message) {
-				signatureCollector.verifyVote(vote)
-			}(vote.Message)
+			pending = append(pending, vote.Message)
+			if v.AggregationWindow == 0 || dispatched+len(pending) >= v.commitQuorum()-1 {
+				verifyPending()
+			} else if windowEnded == nil {
+				windowEnded = time.After(v.AggregationWindow)
+			}
+		case <-windowEnded:
+			verifyPending()
 		case signature := <-signatureCollector.validVotes:
 			signatures = append(signatures, signature)
 			voterIDs = append(voterIDs, signature.ID)
@@ -824,28 +923,54 @@ type voteVerifier struct {
 	validVotes     chan types.Signature
 }
 
-func (vv *voteVerifier) verifyVote(vote *protos.Message) {
-	commit := vote.GetCommit()
-	if commit.Digest != vv.expectedDigest {
-		vv.v.Logger.Warnf("Got wrong digest at processCommits for seq %d", commit.Seq)
-		return
+// verifyVotes verifies the given votes together if the Verifier is a BatchVerifier, and otherwise concurrently,
+// and writes the valid ones into the validVotes channel
+func (vv *voteVerifier) verifyVotes(votes []*protos.Message) {
+	signatures := make([]types.Signature, 0, len(votes))
+	for _, vote := range votes {
+		commit := vote.GetCommit()
+		if commit.Digest != vv.expectedDigest {
+			vv.v.Logger.Warnf("Got wrong digest at processCommits for seq %d", commit.Seq)
+			continue
+		}
+		signatures = append(signatures, types.Signature{
+			ID:    commit.Signature.Signer,
+			Value: commit.Signature.Value,
+			Msg:   commit.Signature.Msg,
+		})
 	}
 
-	_, err := vv.v.Verifier.VerifyConsenterSig(types.Signature{
-		ID:    commit.Signature.Signer,
-		Value: commit.Signature.Value,
-		Msg:   commit.Signature.Msg,
-	}, *vv.proposal)
-	if err != nil {
-		vv.v.Logger.Warnf("Couldn't verify %d's signature: %v", commit.Signature.Signer, err)
+	if batchVerifier, ok := vv.v.Verifier.(api.BatchVerifier); ok && len(signatures) > 1 {
+		errs := batchVerifier.VerifyConsenterSigs(signatures, *vv.proposal)
+		if len(errs) == len(signatures) {
+			for i, signature := range signatures {
+				vv.verified(signature, errs[i])
+			}
+			return
+		}
+		vv.v.Logger.Warnf("Batch verification returned %d results for %d signatures, verifying them one by one", len(errs), len(signatures))
+	}
+
+	if len(signatures) == 0 {
 		return
 	}
+	for _, signature := range signatures[1:] {
+		go vv.verifyVote(signature)
+	}
+	vv.verifyVote(signatures[0])
+}
 
-	vv.validVotes <- types.Signature{
-		ID:    commit.Signature.Signer,
-		Value: commit.Signature.Value,
-		Msg:   commit.Signature.Msg,
+func (vv *voteVerifier) verifyVote(signature types.Signature) {
+	_, err := vv.v.Verifier.VerifyConsenterSig(signature, *vv.proposal)
+	vv.verified(signature, err)
+}
+
+func (vv *voteVerifier) verified(signature types.Signature, err error) {
+	if err != nil {
+		vv.v.Logger.Warnf("Couldn't verify %d's signature: %v", signature.ID, err)
+		return
 	}
+	vv.validVotes <- signature
 }
 
 func (v *View) decide(proposal *types.Proposal, signatures []types.Signature, requests []types.RequestInfo) {
@@ -864,6 +989,7 @@ func (v *View) startNextSeq() {
 	v.DecisionsInView++
 
 	nextSeq := v.ProposalSequence
+	atomic.StoreUint64(&v.currentSeq, nextSeq)
 
 	v.MetricsView.ProposalSequence.Set(float64(v.ProposalSequence))
 	v.MetricsView.DecisionsInView.Set(float64(v.DecisionsInView))
@@ -1016,6 +1142,11 @@ func (v *View) GetLeaderID() uint64 {
 	return v.LeaderID
 }
 
+// CurrentSequence returns the sequence the view is working on, and can be called while the view runs
+func (v *View) CurrentSequence() uint64 {
+	return atomic.LoadUint64(&v.currentSeq)
+}
+
 func (v *View) updateBlacklistMetadata(metadata *protos.ViewMetadata, prevSigs []*protos.Signature, prevMetadata []byte) *protos.ViewMetadata {
 	if v.DecisionsPerLeader == 0 {
 		v.Logger.Debugf("Rotation is disabled, setting blacklist to be empty")
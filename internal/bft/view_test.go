@@ -13,6 +13,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger-labs/SmartBFT/internal/bft"
@@ -280,6 +281,24 @@ func TestBadPrePrepare(t *testing.T) {
 				fd.AssertCalled(t, "Complain", uint64(1), false)
 			},
 		},
+		{
+			description: "metadata sequence mismatches pre-prepare sequence",
+			expectedErr: "received bad proposal from 1: invalid proposal sequence: metadata sequence 0 mismatches pre-prepare sequence 1",
+			sender:      1,
+			setup: func() {
+				syncWG.Add(1)
+				fdWG.Add(1)
+			},
+			corruptProposal: func(proposal *protos.PrePrepare) {
+				proposal.Seq = 1
+			},
+			assert: func() {
+				syncWG.Wait()
+				synchronizer.AssertCalled(t, "Sync")
+				fdWG.Wait()
+				fd.AssertCalled(t, "Complain", uint64(1), false)
+			},
+		},
 		{
 			description: "corrupt metadata in proposal",
 			expectedErr: "received bad proposal from 1: proto:",
@@ -672,6 +691,419 @@ func TestNormalPath(t *testing.T) {
 	view.Abort()
 }
 
+func TestLargerCommitQuorum(t *testing.T) {
+	// A test that takes a view with a commit quorum of all nodes through all 3 phases,
+	// and checks that it decides only once commits from all nodes are collected.
+
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	comm := &mocks.CommMock{}
+	commWG := sync.WaitGroup{}
+	comm.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		commWG.Done()
+	})
+	decider := &mocks.Decider{}
+	decidedSigs := make(chan []types.Signature, 1)
+	decider.On("Decide", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sigs, _ := args.Get(1).([]types.Signature)
+		decidedSigs <- sigs
+	})
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	signer := &mocks.SignerMock{}
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
+		ID:    1,
+		Value: []byte{4},
+	})
+	state := &bft.StateRecorder{}
+	view := &bft.View{
+		RetrieveCheckpoint: (&types.Checkpoint{}).Get,
+		State:              state,
+		Logger:             log,
+		N:                  4,
+		NodesList:          []uint64{1, 2, 3, 4},
+		LeaderID:           1,
+		SelfID:             1,
+		Quorum:             3,
+		CommitQuorum:       4,
+		Number:             1,
+		ProposalSequence:   0,
+		Comm:               comm,
+		Decider:            decider,
+		Verifier:           verifier,
+		Signer:             signer,
+		ViewSequences:      &atomic.Value{},
+		InMsgQSize:         40,
+		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
+	}
+	view.Start()
+
+	commWG.Add(2)
+	view.Propose(proposal)
+	commWG.Wait()
+
+	// The prepare quorum is not affected
+	commWG.Add(1)
+	view.HandleMessage(2, prepare)
+	view.HandleMessage(3, prepare)
+	commWG.Wait()
+
+	// Commits from a regular quorum are not enough
+	view.HandleMessage(2, commit2)
+	view.HandleMessage(3, commit3)
+	select {
+	case <-decidedSigs:
+		assert.Fail(t, "decided before the commit quorum was reached")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	commit4 := proto.Clone(commit2).(*protos.Message)
+	commit4.GetCommit().Signature.Signer = 4
+	view.HandleMessage(4, commit4)
+
+	select {
+	case dSigs := <-decidedSigs:
+		assert.Len(t, dSigs, 4)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "did not decide once the commit quorum was reached")
+	}
+
+	view.Abort()
+}
+
+func TestCommitAggregationWindow(t *testing.T) {
+	// A test that checks that commits are accumulated during the aggregation window before they are verified,
+	// but that commits that complete the quorum are verified right away, without waiting for the window to end.
+
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	comm := &mocks.CommMock{}
+	commWG := sync.WaitGroup{}
+	comm.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		commWG.Done()
+	})
+	decider := &mocks.Decider{}
+	decidedSigs := make(chan []types.Signature, 1)
+	decider.On("Decide", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sigs, _ := args.Get(1).([]types.Signature)
+		decidedSigs <- sigs
+	})
+	var verifiedCommits uint32
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerificationSequence").Return(uint64(1))
+	verifier.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		atomic.AddUint32(&verifiedCommits, 1)
+	}).Return(nil, nil)
+	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	signer := &mocks.SignerMock{}
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
+		ID:    1,
+		Value: []byte{4},
+	})
+	state := &bft.StateRecorder{}
+	view := &bft.View{
+		RetrieveCheckpoint: (&types.Checkpoint{}).Get,
+		State:              state,
+		Logger:             log,
+		N:                  4,
+		NodesList:          []uint64{1, 2, 3, 4},
+		LeaderID:           1,
+		SelfID:             1,
+		Quorum:             3,
+		Number:             1,
+		ProposalSequence:   0,
+		Comm:               comm,
+		Decider:            decider,
+		Verifier:           verifier,
+		Signer:             signer,
+		ViewSequences:      &atomic.Value{},
+		InMsgQSize:         40,
+		AggregationWindow:  time.Hour,
+		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
+	}
+	view.Start()
+
+	commWG.Add(2)
+	view.Propose(proposal)
+	commWG.Wait()
+
+	commWG.Add(1)
+	view.HandleMessage(2, prepare)
+	view.HandleMessage(3, prepare)
+	commWG.Wait()
+
+	// A single commit cannot complete the quorum, so it waits for the window to end
+	view.HandleMessage(2, commit2)
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&verifiedCommits))
+
+	// The next commit may complete the quorum, so both are verified right away
+	view.HandleMessage(3, commit3)
+	select {
+	case dSigs := <-decidedSigs:
+		assert.Len(t, dSigs, 3)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "did not decide once the commit quorum was reached")
+	}
+	assert.Equal(t, uint32(2), atomic.LoadUint32(&verifiedCommits))
+
+	view.Abort()
+}
+
+type batchVerifierMock struct {
+	*mocks.VerifierMock
+	batches chan int
+}
+
+func (bvm *batchVerifierMock) VerifyConsenterSigs(signatures []types.Signature, _ types.Proposal) []error {
+	bvm.batches <- len(signatures)
+	errs := make([]error, len(signatures))
+	for i, signature := range signatures {
+		if signature.ID == 3 {
+			errs[i] = errors.New("bad signature")
+		}
+	}
+	return errs
+}
+
+func TestCommitAggregationWindowWithBatchVerifier(t *testing.T) {
+	// A test that checks that the commits accumulated during the aggregation window are verified together
+	// by a BatchVerifier, and that only the valid ones count towards the quorum.
+
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	comm := &mocks.CommMock{}
+	commWG := sync.WaitGroup{}
+	comm.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		commWG.Done()
+	})
+	decider := &mocks.Decider{}
+	decidedSigs := make(chan []types.Signature, 1)
+	decider.On("Decide", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sigs, _ := args.Get(1).([]types.Signature)
+		decidedSigs <- sigs
+	})
+	verifierMock := &mocks.VerifierMock{}
+	verifierMock.On("VerificationSequence").Return(uint64(1))
+	verifierMock.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	verifierMock.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(nil, nil)
+	verifierMock.On("VerifySignature", mock.Anything).Return(nil)
+	verifier := &batchVerifierMock{VerifierMock: verifierMock, batches: make(chan int, 10)}
+	signer := &mocks.SignerMock{}
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
+		ID:    1,
+		Value: []byte{4},
+	})
+	state := &bft.StateRecorder{}
+	view := &bft.View{
+		RetrieveCheckpoint: (&types.Checkpoint{}).Get,
+		State:              state,
+		Logger:             log,
+		N:                  4,
+		NodesList:          []uint64{1, 2, 3, 4},
+		LeaderID:           1,
+		SelfID:             1,
+		Quorum:             3,
+		Number:             1,
+		ProposalSequence:   0,
+		Comm:               comm,
+		Decider:            decider,
+		Verifier:           verifier,
+		Signer:             signer,
+		ViewSequences:      &atomic.Value{},
+		InMsgQSize:         40,
+		AggregationWindow:  time.Hour,
+		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
+	}
+	view.Start()
+
+	commWG.Add(2)
+	view.Propose(proposal)
+	commWG.Wait()
+
+	commWG.Add(1)
+	view.HandleMessage(2, prepare)
+	view.HandleMessage(3, prepare)
+	commWG.Wait()
+
+	commit4 := proto.Clone(commit2).(*protos.Message)
+	commit4.GetCommit().Signature.Signer = 4
+
+	// The two commits that may complete the quorum are verified together, but one of them is invalid
+	view.HandleMessage(2, commit2)
+	view.HandleMessage(3, commit3)
+	assert.Equal(t, 2, <-verifier.batches)
+	select {
+	case <-decidedSigs:
+		assert.Fail(t, "decided before the commit quorum was reached")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	view.HandleMessage(4, commit4)
+	select {
+	case dSigs := <-decidedSigs:
+		assert.Len(t, dSigs, 3)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "did not decide once the commit quorum was reached")
+	}
+
+	view.Abort()
+}
+
+func BenchmarkCommitAggregationWindow(b *testing.B) {
+	// Measures the cost of processing the votes of a 10 node cluster, where all the followers
+	// send their prepares and commits at once, with and without aggregating the commits,
+	// and with a verifier that verifies the aggregated commits one by one or together.
+
+	for _, bc := range []struct {
+		window time.Duration
+		batch  bool
+	}{
+		{window: 0},
+		{window: time.Millisecond},
+		{window: time.Millisecond, batch: true},
+	} {
+		b.Run(fmt.Sprintf("window=%v,batch=%v", bc.window, bc.batch), func(b *testing.B) {
+			benchmarkVotes(b, 10, bc.window, bc.batch)
+		})
+	}
+}
+
+// sigVerificationCost is the time it takes the benchmark verifiers to verify a signature,
+// and a tenth of it is the time it takes them to verify each additional signature of a batch
+const sigVerificationCost = 50 * time.Microsecond
+
+type costlyVerifier struct {
+	*mocks.VerifierMock
+	spent int64
+}
+
+// spend keeps the CPU busy for the given duration, as a signature verification would
+func (cv *costlyVerifier) spend(d time.Duration) {
+	start := time.Now()
+	for time.Since(start) < d {
+	}
+	atomic.AddInt64(&cv.spent, int64(d))
+}
+
+func (cv *costlyVerifier) VerifyConsenterSig(types.Signature, types.Proposal) ([]byte, error) {
+	cv.spend(sigVerificationCost)
+	return nil, nil
+}
+
+type costlyBatchVerifier struct {
+	*costlyVerifier
+}
+
+func (cbv *costlyBatchVerifier) VerifyConsenterSigs(signatures []types.Signature, _ types.Proposal) []error {
+	cbv.spend(sigVerificationCost + time.Duration(len(signatures)-1)*sigVerificationCost/10)
+	return make([]error, len(signatures))
+}
+
+func benchmarkVotes(b *testing.B, n uint64, window time.Duration, batch bool) {
+	comm := &mocks.CommMock{}
+	comm.On("BroadcastConsensus", mock.Anything)
+	decided := make(chan struct{}, 1)
+	decider := &mocks.Decider{}
+	decider.On("Decide", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		decided <- struct{}{}
+	})
+	verifierMock := &mocks.VerifierMock{}
+	verifierMock.On("VerificationSequence").Return(uint64(1))
+	verifierMock.On("VerifyProposal", mock.Anything, mock.Anything).Return(nil, nil)
+	costly := &costlyVerifier{VerifierMock: verifierMock}
+	var verifier api.Verifier = costly
+	if batch {
+		verifier = &costlyBatchVerifier{costlyVerifier: costly}
+	}
+	signer := &mocks.SignerMock{}
+	signer.On("SignProposal", mock.Anything, mock.Anything).Return(&types.Signature{
+		ID:    1,
+		Value: []byte{4},
+	})
+	state := &mocks.State{}
+	state.On("Save", mock.Anything).Return(nil)
+
+	nodes := make([]uint64, 0, n)
+	for id := uint64(1); id <= n; id++ {
+		nodes = append(nodes, id)
+	}
+	f := (int(n) - 1) / 3
+	view := &bft.View{
+		RetrieveCheckpoint: (&types.Checkpoint{}).Get,
+		State:              state,
+		Logger:             zap.NewNop().Sugar(),
+		N:                  n,
+		NodesList:          nodes,
+		LeaderID:           1,
+		SelfID:             1,
+		Quorum:             (int(n) + f + 2) / 2,
+		Number:             1,
+		ProposalSequence:   0,
+		Comm:               comm,
+		Decider:            decider,
+		Verifier:           verifier,
+		Signer:             signer,
+		ViewSequences:      &atomic.Value{},
+		InMsgQSize:         int(4 * n),
+		AggregationWindow:  window,
+		MetricsView:        api.NewMetricsView(&disabled.Provider{}),
+	}
+	view.Start()
+	defer view.Abort()
+
+	b.ResetTimer()
+	for seq := uint64(0); seq < uint64(b.N); seq++ {
+		prop := proposal
+		prop.Metadata = bft.MarshalOrPanic(&protos.ViewMetadata{
+			LatestSequence:  seq,
+			DecisionsInView: seq,
+			ViewId:          1,
+		})
+		view.Propose(prop)
+
+		for sender := uint64(2); sender <= n; sender++ {
+			view.HandleMessage(sender, &protos.Message{
+				Content: &protos.Message_Prepare{
+					Prepare: &protos.Prepare{
+						View:   1,
+						Seq:    seq,
+						Digest: prop.Digest(),
+					},
+				},
+			})
+		}
+		for sender := uint64(2); sender <= n; sender++ {
+			view.HandleMessage(sender, &protos.Message{
+				Content: &protos.Message_Commit{
+					Commit: &protos.Commit{
+						View:   1,
+						Seq:    seq,
+						Digest: prop.Digest(),
+						Signature: &protos.Signature{
+							Signer: sender,
+							Value:  []byte{4},
+						},
+					},
+				},
+			})
+		}
+		<-decided
+	}
+	b.StopTimer()
+
+	votes := float64(b.N) * float64(2*(n-1))
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/votes, "ns/vote")
+	b.ReportMetric(float64(atomic.LoadInt64(&costly.spent))/votes, "verify-ns/vote")
+}
+
 func TestTwoSequences(t *testing.T) {
 	// A test that takes a view through all 3 phases of two consecutive sequences,
 	// when all messages are sent in advanced for both sequences.
@@ -803,6 +1235,138 @@ func TestTwoSequences(t *testing.T) {
 	view.Abort()
 }
 
+func TestInvalidProposalNotInFlight(t *testing.T) {
+	// Ensure that a proposal of the leader that fails verification is neither persisted
+	// nor retained as in-flight, and hence it is not surfaced in a subsequent view change.
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifyProposal", mock.Anything).Return(nil, errors.New("unauthorized client"))
+	verifier.On("VerificationSequence").Return(uint64(1))
+
+	comm := &mocks.CommMock{}
+	comm.On("BroadcastConsensus", mock.Anything)
+
+	synchronizer := &mocks.Synchronizer{}
+	synchronizer.On("Sync")
+
+	var complained sync.WaitGroup
+	complained.Add(1)
+	fd := &mocks.FailureDetector{}
+	fd.On("Complain", uint64(1), false).Run(func(args mock.Arguments) {
+		complained.Done()
+	}).Once()
+
+	testDir, err := os.MkdirTemp("", "view-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	writeAheadLog, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+	defer writeAheadLog.Close()
+
+	inFlight := &bft.InFlightData{}
+	view := &bft.View{
+		RetrieveCheckpoint: (&types.Checkpoint{}).Get,
+		Comm:               comm,
+		Verifier:           verifier,
+		SelfID:             1,
+		State: &bft.PersistedState{
+			InFlightProposal: inFlight,
+			Logger:           log,
+			WAL:              writeAheadLog,
+		},
+		Logger:           log,
+		N:                4,
+		NodesList:        []uint64{1, 2, 3, 4},
+		LeaderID:         1,
+		Quorum:           3,
+		Number:           1,
+		ProposalSequence: 0,
+		Sync:             synchronizer,
+		FailureDetector:  fd,
+		ViewSequences:    &atomic.Value{},
+		InMsgQSize:       40,
+		MetricsView:      api.NewMetricsView(&disabled.Provider{}),
+	}
+	view.Start()
+	view.Propose(proposal)
+
+	complained.Wait()
+	view.Abort()
+
+	assert.Nil(t, inFlight.InFlightProposal())
+	assert.False(t, inFlight.IsInFlightPrepared())
+	comm.AssertNotCalled(t, "BroadcastConsensus", mock.Anything)
+}
+
+func TestProposedRequestsMarked(t *testing.T) {
+	// Ensure that a follower that accepts a proposal whose request it never received
+	// rejects the request if it is submitted to it later on
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	req := makeTestRequest("alice", "1", "foo")
+	pool := bft.NewPool(log, insp, &mocks.RequestTimeoutHandler{}, bft.PoolOptions{QueueSize: 10, ForwardTimeout: time.Hour}, make(chan struct{}, 1))
+	defer pool.Close()
+
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifyProposal", mock.Anything).Return([]types.RequestInfo{insp.RequestID(req)}, nil)
+	verifier.On("VerificationSequence").Return(uint64(1))
+
+	var prepared sync.WaitGroup
+	prepared.Add(1)
+	comm := &mocks.CommMock{}
+	comm.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		if args.Get(0).(*protos.Message).GetPrepare() != nil {
+			prepared.Done()
+		}
+	}).Once()
+	comm.On("BroadcastConsensus", mock.Anything)
+
+	testDir, err := os.MkdirTemp("", "view-unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+	writeAheadLog, err := wal.Create(log, testDir, nil)
+	assert.NoError(t, err)
+	defer writeAheadLog.Close()
+
+	view := &bft.View{
+		RetrieveCheckpoint: (&types.Checkpoint{}).Get,
+		Comm:               comm,
+		Verifier:           verifier,
+		SelfID:             2,
+		State: &bft.PersistedState{
+			InFlightProposal: &bft.InFlightData{},
+			Logger:           log,
+			WAL:              writeAheadLog,
+		},
+		Logger:           log,
+		N:                4,
+		NodesList:        []uint64{1, 2, 3, 4},
+		LeaderID:         1,
+		Quorum:           3,
+		Number:           1,
+		ProposalSequence: 0,
+		ProposedRequests: pool,
+		ViewSequences:    &atomic.Value{},
+		InMsgQSize:       40,
+		MetricsView:      api.NewMetricsView(&disabled.Provider{}),
+	}
+	view.Start()
+	defer view.Abort()
+
+	assert.NoError(t, pool.Submit(makeTestRequest("bob", "1", "foo")))
+	view.HandleMessage(1, prePrepare)
+	prepared.Wait()
+
+	assert.ErrorIs(t, pool.Submit(req), bft.ErrReqAlreadyProcessed)
+	assert.Equal(t, 1, pool.Size())
+}
+
 func TestViewPersisted(t *testing.T) {
 	for _, testCase := range []struct {
 		description        string
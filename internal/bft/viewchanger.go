@@ -7,6 +7,7 @@ package bft
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -56,7 +57,10 @@ type ViewChanger struct {
 	N                  uint64
 	f                  int
 	quorum             int
+	PrepareQuorum      int
+	CommitQuorum       int
 	SpeedUpViewChange  bool
+	MaxViewLag         uint64
 	LeaderRotation     bool
 	DecisionsPerLeader uint64
 
@@ -70,6 +74,9 @@ type ViewChanger struct {
 	Checkpoint *types.Checkpoint
 	InFlight   *InFlightData
 	State      State
+	// ProposalEquivalence decides whether a proposal received during a view change is the same as a proposal
+	// this node stored. If it is nil, proposals are the same if their digests are equal.
+	ProposalEquivalence func(a, b types.Proposal) bool
 
 	Controller    ViewController
 	RequestsTimer RequestsTimer
@@ -88,6 +95,7 @@ type ViewChanger struct {
 	lastResend          time.Time
 	ViewChangeTimeout   time.Duration
 	startViewChangeTime time.Time
+	viewChangeBegin     time.Time
 	checkTimeout        bool
 	backOffFactor       uint64
 
@@ -104,9 +112,14 @@ type ViewChanger struct {
 	realView                  uint64
 	currView                  uint64
 	nextView                  uint64
-	startChangeChan           chan *change
+	startChangeChan           chan struct{}
+	pendingChange             *change
+	pendingChangeLock         sync.Mutex
 	informChan                chan uint64
 	committedDuringViewChange *protos.ViewMetadata
+	lagSyncView               uint64
+	progress                  atomic.Value
+	appDeliverer              ViewRecordingDeliverer
 
 	stopOnce sync.Once
 	stopChan chan struct{}
@@ -118,7 +131,8 @@ type ViewChanger struct {
 // Start the view changer
 func (v *ViewChanger) Start(startViewNumber uint64) {
 	v.incMsgs = make(chan *incMsg, v.InMsqQSize)
-	v.startChangeChan = make(chan *change, 2)
+	v.startChangeChan = make(chan struct{}, 1)
+	v.pendingChange = nil
 	v.informChan = make(chan uint64, 1)
 
 	if v.MetricsViewChange == nil {
@@ -135,6 +149,8 @@ func (v *ViewChanger) Start(startViewNumber uint64) {
 
 	v.nvs = &nextViews{}
 	v.nvs.clear()
+	v.lagSyncView = 0
+	v.appDeliverer = inViewDeliverer(v.Application)
 	// set without locking
 	v.currView = startViewNumber
 	v.realView = v.currView
@@ -142,6 +158,7 @@ func (v *ViewChanger) Start(startViewNumber uint64) {
 	v.MetricsViewChange.CurrentView.Set(float64(v.currView))
 	v.MetricsViewChange.RealView.Set(float64(v.realView))
 	v.MetricsViewChange.NextView.Set(float64(v.nextView))
+	v.progress.Store(viewChangeProgress{})
 
 	v.lastTick = time.Now()
 	v.lastResend = v.lastTick
@@ -212,8 +229,10 @@ func (v *ViewChanger) run() {
 		select {
 		case <-v.stopChan:
 			return
-		case changeMsg := <-v.startChangeChan:
-			v.startViewChange(changeMsg)
+		case <-v.startChangeChan:
+			if change := v.takePendingChange(); change != nil {
+				v.startViewChange(change)
+			}
 		case msg := <-v.incMsgs:
 			v.processMsg(msg.sender, msg.Message)
 		case now := <-v.Ticker:
@@ -225,9 +244,52 @@ func (v *ViewChanger) run() {
 		case <-v.Restore:
 			v.processViewChangeMsg(true)
 		}
+		v.progress.Store(v.currentProgress())
 	}
 }
 
+type viewChangeProgress struct {
+	collected int
+	needed    int
+	viewData  []uint64 // the senders of the view data messages collected by the next leader
+}
+
+// ViewChangeProgress returns how many of the messages needed to complete the active view change were collected.
+// Until this node sends its view data, these are the view change messages including its own.
+// Afterwards, the next leader collects view data messages, and the other nodes have collected all the view change
+// messages they need and wait for the new view. Both are zero when there is no active view change.
+func (v *ViewChanger) ViewChangeProgress() (collected int, needed int) {
+	progress, _ := v.progress.Load().(viewChangeProgress)
+	return progress.collected, progress.needed
+}
+
+// CollectedViewData returns the sorted IDs of the nodes whose view data messages this node collected, as the leader
+// of the next view, during the active view change. Comparing it with the nodes names those whose view data is missing.
+// It is empty when there is no active view change, or when this node is not the next leader.
+func (v *ViewChanger) CollectedViewData() []uint64 {
+	progress, _ := v.progress.Load().(viewChangeProgress)
+	return progress.viewData
+}
+
+// currentProgress returns the progress of the active view change, must be called by the run goroutine
+func (v *ViewChanger) currentProgress() viewChangeProgress {
+	if v.nextView == v.currView+1 {
+		return viewChangeProgress{collected: len(v.viewChangeMsgs.voted) + 1, needed: v.quorum}
+	}
+	if v.currView == v.realView {
+		return viewChangeProgress{}
+	}
+	if v.getLeader() == v.SelfID {
+		senders := make([]uint64, 0, len(v.viewDataMsgs.voted))
+		for sender := range v.viewDataMsgs.voted {
+			senders = append(senders, sender)
+		}
+		sort.Slice(senders, func(i, j int) bool { return senders[i] < senders[j] })
+		return viewChangeProgress{collected: len(senders), needed: v.quorum, viewData: senders}
+	}
+	return viewChangeProgress{collected: v.quorum, needed: v.quorum}
+}
+
 func (v *ViewChanger) getLeader() uint64 {
 	return getLeaderID(v.currView, v.N, v.NodesList, v.LeaderRotation, 0, v.DecisionsPerLeader, v.blacklist())
 }
@@ -274,6 +336,9 @@ func (v *ViewChanger) processMsg(sender uint64, m *protos.Message) {
 	if vc := m.GetViewChange(); vc != nil {
 		v.Logger.Debugf("Node %d is processing a view change message %v from %d with next view %d", v.SelfID, m, sender, vc.NextView)
 		v.nvs.registerNext(vc.NextView, sender)
+		if v.syncIfTooFarBehind() {
+			return
+		}
 		// check view number
 		if vc.NextView == v.currView+1 { // accept view change only to immediate next view number
 			v.viewChangeMsgs.registerVote(sender, m)
@@ -323,6 +388,22 @@ func (v *ViewChanger) processMsg(sender uint64, m *protos.Message) {
 	}
 }
 
+// syncIfTooFarBehind syncs if at least f+1 nodes are in a view that is more than MaxViewLag views ahead,
+// as catching up with every intermediate view would take too long.
+func (v *ViewChanger) syncIfTooFarBehind() bool {
+	if v.MaxViewLag == 0 {
+		return false
+	}
+	observedView := v.nvs.highestOf(v.f + 1)
+	if observedView <= v.currView+v.MaxViewLag || observedView <= v.lagSyncView {
+		return false
+	}
+	v.lagSyncView = observedView
+	v.Logger.Infof("Node %d is in view %d while at least %d nodes are in view %d, calling sync", v.SelfID, v.currView, v.f+1, observedView)
+	v.Synchronizer.Sync()
+	return true
+}
+
 // InformNewView tells the view changer to advance to a new view number
 func (v *ViewChanger) InformNewView(view uint64) {
 	select {
@@ -349,17 +430,39 @@ func (v *ViewChanger) informNewView(view uint64) {
 	v.viewDataMsgs.clear(v.N)
 	v.checkTimeout = false
 	v.backOffFactor = 1 // reset
+	v.viewChangeBegin = time.Time{}
 	v.RequestsTimer.RestartTimers()
 }
 
-// StartViewChange initiates a view change
+// StartViewChange initiates a view change.
+// Triggers that arrive before a previous one is handled are coalesced into a single view change,
+// which targets the newest view and stops the current view if any of them asked to.
 func (v *ViewChanger) StartViewChange(view uint64, stopView bool) {
+	v.pendingChangeLock.Lock()
+	if v.pendingChange == nil {
+		v.pendingChange = &change{view: view, stopView: stopView}
+	} else {
+		if view > v.pendingChange.view {
+			v.pendingChange.view = view
+		}
+		v.pendingChange.stopView = v.pendingChange.stopView || stopView
+	}
+	v.pendingChangeLock.Unlock()
+
 	select {
-	case v.startChangeChan <- &change{view: view, stopView: stopView}:
+	case v.startChangeChan <- struct{}{}:
 	default:
 	}
 }
 
+func (v *ViewChanger) takePendingChange() *change {
+	v.pendingChangeLock.Lock()
+	defer v.pendingChangeLock.Unlock()
+	pending := v.pendingChange
+	v.pendingChange = nil
+	return pending
+}
+
 // StartViewChange stops current view and timeouts, and broadcasts a view change message to all
 func (v *ViewChanger) startViewChange(change *change) {
 	if change.view < v.currView { // this is about an old view
@@ -387,6 +490,9 @@ func (v *ViewChanger) startViewChange(change *change) {
 		v.Controller.AbortView(v.currView) // abort the current view when joining view change
 	}
 	v.startViewChangeTime = v.lastTick
+	if v.viewChangeBegin.IsZero() { // measure from the first attempt, regardless of timeouts
+		v.viewChangeBegin = time.Now()
+	}
 	v.checkTimeout = true
 }
 
@@ -601,7 +707,7 @@ func (v *ViewChanger) checkLastDecision(svd *protos.SignedViewData, sender uint6
 		}
 
 		// compare the last decision itself
-		if !proto.Equal(vd.LastDecision, myLastDecision) {
+		if !v.equivalentProposals(vd.LastDecision, myLastDecision) {
 			v.Logger.Warnf("Node %d got %s from %d, they are at the same sequence but the last decisions are not equal", v.SelfID, signedViewDataToString(svd), sender)
 			return false, 0
 		}
@@ -726,6 +832,40 @@ func ValidateLastDecision(vd *protos.ViewData, quorum int, n uint64, verifier ap
 	return md.LatestSequence, nil
 }
 
+// ValidateDecision validates that the given decision is signed by a quorum of the given nodes
+func ValidateDecision(proposal types.Proposal, signatures []types.Signature, nodes []uint64, verifier api.Verifier) error {
+	if proposal.Metadata == nil {
+		// This is a genesis proposal, there are no signatures to validate
+		return nil
+	}
+	md := &protos.ViewMetadata{}
+	if err := proto.Unmarshal(proposal.Metadata, md); err != nil {
+		return errors.Errorf("unable to unmarshal decision metadata, err: %v", err)
+	}
+	quorum, _ := computeQuorum(uint64(len(nodes)))
+	members := make(map[uint64]struct{}, len(nodes))
+	for _, n := range nodes {
+		members[n] = struct{}{}
+	}
+	signers := make(map[uint64]struct{}, len(signatures))
+	for _, sig := range signatures {
+		if _, exist := members[sig.ID]; !exist {
+			return errors.Errorf("decision is signed by %d which is not a member of the nodes %v", sig.ID, nodes)
+		}
+		if _, exist := signers[sig.ID]; exist {
+			continue // seen signature from this node already
+		}
+		if _, err := verifier.VerifyConsenterSig(sig, proposal); err != nil {
+			return errors.Errorf("decision signature of %d is invalid, error: %v", sig.ID, err)
+		}
+		signers[sig.ID] = struct{}{}
+	}
+	if len(signers) < quorum {
+		return errors.Errorf("there are only %d valid decision signatures out of %d required", len(signers), quorum)
+	}
+	return nil
+}
+
 // ValidateInFlight validates the given in-flight proposal
 func ValidateInFlight(inFlightProposal *protos.Proposal, lastSequence uint64) error {
 	if inFlightProposal == nil {
@@ -1025,7 +1165,7 @@ func (v *ViewChanger) validateNewViewMsg(msg *protos.NewView) (valid bool, sync
 			}
 
 			// compare the last decision itself
-			if !proto.Equal(vd.LastDecision, myLastDecision) {
+			if !v.equivalentProposals(vd.LastDecision, myLastDecision) {
 				v.Logger.Warnf("Node %d is processing newView message, but the last decision of %s is with the same sequence but is not equal", v.SelfID, signedViewDataToString(svd))
 				return false, false, false
 			}
@@ -1162,14 +1302,25 @@ func (v *ViewChanger) processNewViewMsg(msg *protos.NewView) {
 	v.nvs.clear()
 	v.Controller.ViewChanged(v.currView, mySequence+1)
 
+	v.MetricsViewChange.CountViewChange.Add(1)
+	if !v.viewChangeBegin.IsZero() {
+		v.MetricsViewChange.LatencyViewChange.Observe(time.Since(v.viewChangeBegin).Seconds())
+		v.viewChangeBegin = time.Time{}
+	}
+
 	v.RequestsTimer.RestartTimers()
 	v.checkTimeout = false
 	v.backOffFactor = 1 // reset
 }
 
+// deliver delivers the given decision to the application, as committed in the view this node changes to
+func (v *ViewChanger) deliver(proposal types.Proposal, signatures []types.Signature) types.Reconfig {
+	return v.appDeliverer.DeliverInView(proposal, signatures, v.currView)
+}
+
 func (v *ViewChanger) deliverDecision(proposal types.Proposal, signatures []types.Signature) {
 	v.Logger.Debugf("Delivering to app from deliverDecision the last decision proposal")
-	reconfig := v.Application.Deliver(proposal, signatures)
+	reconfig := v.deliver(proposal, sortedSignatures(signatures))
 	if reconfig.InLatestDecision {
 		v.close()
 	}
@@ -1184,6 +1335,27 @@ func (v *ViewChanger) deliverDecision(proposal types.Proposal, signatures []type
 	v.Pruner.MaybePruneRevokedRequests()
 }
 
+// equivalentProposals returns whether the given proposals are the same according to the ProposalEquivalence
+func (v *ViewChanger) equivalentProposals(a, b *protos.Proposal) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	propA, propB := proposalFromProto(a), proposalFromProto(b)
+	if v.ProposalEquivalence != nil {
+		return v.ProposalEquivalence(propA, propB)
+	}
+	return propA.Digest() == propB.Digest()
+}
+
+func proposalFromProto(proposal *protos.Proposal) types.Proposal {
+	return types.Proposal{
+		Header:               proposal.Header,
+		Metadata:             proposal.Metadata,
+		Payload:              proposal.Payload,
+		VerificationSequence: int64(proposal.VerificationSequence),
+	}
+}
+
 func (v *ViewChanger) commitInFlightProposal(proposal *protos.Proposal) (success bool) {
 	myLastDecision, _ := v.Checkpoint.Get()
 	if proposal == nil {
@@ -1203,7 +1375,7 @@ func (v *ViewChanger) commitInFlightProposal(proposal *protos.Proposal) (success
 		if lastDecisionMD.LatestSequence == proposalMD.LatestSequence {
 			v.Logger.Debugf("Node %d already decided on sequence %d and so it will not commit the in flight proposal with the same sequence", v.SelfID, lastDecisionMD.LatestSequence)
 			v.Logger.Debugf("Node %d is comparing its last decision with the in flight proposal with the same sequence %d", v.SelfID, lastDecisionMD.LatestSequence)
-			if !proto.Equal(myLastDecision, proposal) {
+			if !v.equivalentProposals(myLastDecision, proposal) {
 				v.Logger.Warnf("Node %d compared its last decision with the in flight proposal, which has the same sequence, but they are not equal", v.SelfID)
 				return false
 			}
@@ -1229,6 +1401,8 @@ func (v *ViewChanger) commitInFlightProposal(proposal *protos.Proposal) (success
 		Number:             inFlightViewNum,
 		LeaderID:           v.SelfID, // so that no byzantine leader will cause a complain
 		Quorum:             v.quorum,
+		PrepareQuorum:      v.PrepareQuorum,
+		CommitQuorum:       v.CommitQuorum,
 		Decider:            v,
 		FailureDetector:    v,
 		Sync:               v,
@@ -1310,7 +1484,7 @@ func (v *ViewChanger) commitInFlightProposal(proposal *protos.Proposal) (success
 func (v *ViewChanger) Decide(proposal types.Proposal, signatures []types.Signature, requests []types.RequestInfo) {
 	v.inFlightView.stop()
 	v.Logger.Debugf("Delivering to app from Decide the last decision proposal")
-	reconfig := v.Application.Deliver(proposal, signatures)
+	reconfig := v.deliver(proposal, sortedSignatures(signatures))
 	if reconfig.InLatestDecision {
 		v.close()
 	}
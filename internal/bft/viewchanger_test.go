@@ -6,6 +6,7 @@
 package bft_test
 
 import (
+	"bytes"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,6 +17,7 @@ import (
 	"github.com/hyperledger-labs/SmartBFT/internal/bft"
 	"github.com/hyperledger-labs/SmartBFT/internal/bft/mocks"
 	"github.com/hyperledger-labs/SmartBFT/pkg/api"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics"
 	"github.com/hyperledger-labs/SmartBFT/pkg/metrics/disabled"
 	"github.com/hyperledger-labs/SmartBFT/pkg/types"
 	protos "github.com/hyperledger-labs/SmartBFT/smartbftprotos"
@@ -124,6 +126,116 @@ func TestStartViewChange(t *testing.T) {
 	controller.AssertNumberOfCalls(t, "AbortView", 1)
 }
 
+func TestStartViewChangeCoalesced(t *testing.T) {
+	// Test that many concurrent calls to StartViewChange result in a single view change
+
+	comm := &mocks.CommMock{}
+	msgChan := make(chan *protos.Message, 100)
+	comm.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		msgChan <- args.Get(0).(*protos.Message)
+	})
+	reqTimer := &mocks.RequestsTimer{}
+	reqTimer.On("StopTimers")
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	controller := &mocks.ViewController{}
+	controller.On("AbortView", mock.Anything)
+
+	vc := &bft.ViewChanger{
+		N:             4,
+		NodesList:     []uint64{0, 1, 2, 3},
+		Comm:          comm,
+		RequestsTimer: reqTimer,
+		Ticker:        make(chan time.Time),
+		Logger:        log,
+		Controller:    controller,
+		InMsqQSize:    100,
+	}
+
+	vc.Start(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vc.StartViewChange(0, i%10 == 0)
+		}(i)
+	}
+	wg.Wait()
+
+	msg := <-msgChan
+	assert.Equal(t, uint64(1), msg.GetViewChange().GetNextView())
+	// Give any other view change a chance to be broadcast
+	time.Sleep(100 * time.Millisecond)
+	vc.Stop()
+
+	assert.Len(t, msgChan, 0)
+	reqTimer.AssertNumberOfCalls(t, "StopTimers", 1)
+}
+
+func TestRequestSubmittedDuringViewChangeNotForwarded(t *testing.T) {
+	// Test that the timeouts of requests submitted while a view change is in progress are paused,
+	// so that they are not forwarded to the leader of the view being changed
+
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+
+	insp := &testRequestInspector{}
+	request := makeTestRequest("1", "1", "foo")
+	forwarded := make(chan struct{}, 1)
+	timeoutHandler := &mocks.RequestTimeoutHandler{}
+	timeoutHandler.On("OnRequestTimeout", request, insp.RequestID(request)).Run(func(args mock.Arguments) {
+		forwarded <- struct{}{}
+	}).Return()
+	timeoutHandler.On("OnLeaderFwdRequestTimeout", mock.Anything, mock.Anything).Return()
+	pool := bft.NewPool(log, insp, timeoutHandler, bft.PoolOptions{
+		QueueSize:         3,
+		ForwardTimeout:    50 * time.Millisecond,
+		ComplainTimeout:   time.Hour,
+		AutoRemoveTimeout: time.Hour,
+	}, nil)
+	defer pool.Close()
+
+	comm := &mocks.CommMock{}
+	msgChan := make(chan *protos.Message, 1)
+	comm.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		msgChan <- args.Get(0).(*protos.Message)
+	})
+	controller := &mocks.ViewController{}
+	controller.On("AbortView", mock.Anything)
+
+	vc := &bft.ViewChanger{
+		N:             4,
+		NodesList:     []uint64{0, 1, 2, 3},
+		Comm:          comm,
+		RequestsTimer: pool,
+		Ticker:        make(chan time.Time),
+		Logger:        log,
+		Controller:    controller,
+		InMsqQSize:    100,
+	}
+
+	vc.Start(0)
+	defer vc.Stop()
+
+	vc.StartViewChange(0, true)
+	<-msgChan
+
+	assert.NoError(t, pool.Submit(request))
+	select {
+	case <-forwarded:
+		t.Fatalf("Request was forwarded during a view change")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// The timeouts resume once the new view is installed, which restarts the timers
+	pool.RestartTimers()
+	<-forwarded
+}
+
 func TestViewChangeProcess(t *testing.T) {
 	// Test the view change messages handling and process until sending a viewData message
 
@@ -317,6 +429,167 @@ func TestViewDataProcess(t *testing.T) {
 	state.AssertCalled(t, "Save", mock.Anything)
 }
 
+func TestViewDataWithEquivalentLastDecision(t *testing.T) {
+	// Test that view data messages whose last decision differs from the last decision of the node
+	// only in incidental bytes are accepted if the proposals are equivalent
+
+	comm := &mocks.CommMock{}
+	broadcastChan := make(chan *protos.Message)
+	comm.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		m := args.Get(0).(*protos.Message)
+		broadcastChan <- m
+	}).Once()
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	controller := &mocks.ViewController{}
+	viewNumChan := make(chan uint64)
+	controller.On("ViewChanged", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		viewNumChan <- args.Get(0).(uint64)
+	}).Return(nil).Once()
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return([]byte{1, 2, 3})
+
+	// The header of the last decision of this node holds a different timestamp
+	myLastDecision := lastDecision
+	myLastDecision.Header = []byte{9}
+	checkpoint := types.Checkpoint{}
+	checkpoint.Set(myLastDecision, lastDecisionSignatures)
+	reqTimer := &mocks.RequestsTimer{}
+	reqTimer.On("RestartTimers").Once()
+	state := &mocks.State{}
+	state.On("Save", mock.Anything).Return(nil)
+
+	var comparisons uint32
+	vc := &bft.ViewChanger{
+		SelfID:        1,
+		N:             4,
+		NodesList:     []uint64{0, 1, 2, 3},
+		Comm:          comm,
+		Logger:        log,
+		Verifier:      verifier,
+		Controller:    controller,
+		Ticker:        make(chan time.Time),
+		Checkpoint:    &checkpoint,
+		InFlight:      &bft.InFlightData{},
+		Signer:        signer,
+		RequestsTimer: reqTimer,
+		InMsqQSize:    100,
+		State:         state,
+		ProposalEquivalence: func(a, b types.Proposal) bool {
+			atomic.AddUint32(&comparisons, 1)
+			return bytes.Equal(a.Payload, b.Payload) && bytes.Equal(a.Metadata, b.Metadata) &&
+				a.VerificationSequence == b.VerificationSequence
+		},
+	}
+
+	vc.Start(1)
+
+	for i := uint64(0); i < 3; i++ {
+		msg := proto.Clone(viewDataMsg1).(*protos.Message)
+		msg.GetViewData().Signer = i
+		vc.HandleMessage(i, msg)
+	}
+	m := <-broadcastChan
+	assert.NotNil(t, m.GetNewView())
+	assert.Equal(t, uint64(1), <-viewNumChan)
+	assert.NotZero(t, atomic.LoadUint32(&comparisons))
+
+	vc.Stop()
+}
+
+func TestForgedViewDataNotCounted(t *testing.T) {
+	// Test that a view data message with a forged signature doesn't count towards the quorum
+
+	comm := &mocks.CommMock{}
+	broadcastChan := make(chan *protos.Message, 1)
+	comm.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		m := args.Get(0).(*protos.Message)
+		broadcastChan <- m
+	}).Once()
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	forgedLogged := make(chan struct{}, 1)
+	log := basicLog.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "but signature is invalid") {
+			select {
+			case forgedLogged <- struct{}{}:
+			default:
+			}
+		}
+		return nil
+	})).Sugar()
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifySignature", mock.MatchedBy(func(sig types.Signature) bool {
+		return sig.ID == 2
+	})).Return(errors.New("forged signature"))
+	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	controller := &mocks.ViewController{}
+	viewNumChan := make(chan uint64, 1)
+	controller.On("ViewChanged", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		viewNumChan <- args.Get(0).(uint64)
+	}).Return(nil).Once()
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return([]byte{1, 2, 3})
+	checkpoint := types.Checkpoint{}
+	checkpoint.Set(lastDecision, lastDecisionSignatures)
+	reqTimer := &mocks.RequestsTimer{}
+	reqTimer.On("RestartTimers").Once()
+	state := &mocks.State{}
+	state.On("Save", mock.Anything).Return(nil)
+
+	vc := &bft.ViewChanger{
+		SelfID:        1,
+		N:             4,
+		NodesList:     []uint64{0, 1, 2, 3},
+		Comm:          comm,
+		Logger:        log,
+		Verifier:      verifier,
+		Controller:    controller,
+		Ticker:        make(chan time.Time),
+		Checkpoint:    &checkpoint,
+		InFlight:      &bft.InFlightData{},
+		Signer:        signer,
+		RequestsTimer: reqTimer,
+		InMsqQSize:    100,
+		State:         state,
+	}
+
+	vc.Start(1)
+
+	vc.HandleMessage(0, viewDataMsg1)
+
+	msg1 := proto.Clone(viewDataMsg1).(*protos.Message)
+	msg1.GetViewData().Signer = 1
+	vc.HandleMessage(1, msg1)
+
+	forged := proto.Clone(viewDataMsg1).(*protos.Message)
+	forged.GetViewData().Signer = 2
+	vc.HandleMessage(2, forged)
+	<-forgedLogged
+
+	select {
+	case m := <-broadcastChan:
+		t.Fatalf("sent %v although only two of the view data messages are valid", m)
+	default:
+	}
+
+	msg3 := proto.Clone(viewDataMsg1).(*protos.Message)
+	msg3.GetViewData().Signer = 3
+	vc.HandleMessage(3, msg3)
+
+	m := <-broadcastChan
+	assert.NotNil(t, m.GetNewView())
+	for _, svd := range m.GetNewView().SignedViewData {
+		assert.NotEqual(t, uint64(2), svd.Signer)
+	}
+	assert.Equal(t, uint64(1), <-viewNumChan)
+
+	vc.Stop()
+}
+
 func TestNewViewProcess(t *testing.T) {
 	// Test the new view messages handling and process until calling controller
 
@@ -476,6 +749,107 @@ func TestNormalProcess(t *testing.T) {
 	vc.Stop()
 }
 
+func TestViewChangerMetrics(t *testing.T) {
+	comm := &mocks.CommMock{}
+	msgChan := make(chan *protos.Message)
+	comm.On("BroadcastConsensus", mock.Anything).Run(func(args mock.Arguments) {
+		m := args.Get(0).(*protos.Message)
+		msgChan <- m
+	})
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	signer := &mocks.SignerMock{}
+	signer.On("Sign", mock.Anything).Return([]byte{1, 2, 3})
+	verifier := &mocks.VerifierMock{}
+	verifier.On("VerifySignature", mock.Anything).Return(nil)
+	verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(nil, nil)
+	controller := &mocks.ViewController{}
+	viewChanged := make(chan struct{})
+	controller.On("ViewChanged", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		viewChanged <- struct{}{}
+	}).Return(nil).Once()
+	controller.On("AbortView", mock.Anything)
+	reqTimer := &mocks.RequestsTimer{}
+	reqTimer.On("StopTimers")
+	reqTimer.On("RestartTimers")
+	checkpoint := types.Checkpoint{}
+	checkpoint.Set(lastDecision, lastDecisionSignatures)
+	state := &mocks.State{}
+	state.On("Save", mock.Anything).Return(nil)
+
+	currentView, nextView, realView := &testGauge{}, &testGauge{}, &testGauge{}
+	countViewChange := &testCounter{}
+	latencyViewChange := &testHistogram{}
+
+	vc := &bft.ViewChanger{
+		SelfID:        1,
+		N:             4,
+		NodesList:     []uint64{0, 1, 2, 3},
+		Comm:          comm,
+		Logger:        log,
+		Verifier:      verifier,
+		Controller:    controller,
+		Signer:        signer,
+		RequestsTimer: reqTimer,
+		Ticker:        make(chan time.Time),
+		InFlight:      &bft.InFlightData{},
+		Checkpoint:    &checkpoint,
+		InMsqQSize:    100,
+		State:         state,
+		MetricsViewChange: &api.MetricsViewChange{
+			CurrentView:       currentView,
+			NextView:          nextView,
+			RealView:          realView,
+			CountViewChange:   countViewChange,
+			LatencyViewChange: latencyViewChange,
+		},
+	}
+
+	vc.Start(4)
+	assert.Equal(t, float64(4), currentView.Value())
+	assert.Equal(t, float64(4), nextView.Value())
+	assert.Equal(t, float64(4), realView.Value())
+
+	vc.StartViewChange(4, true)
+	m := <-msgChan
+	assert.NotNil(t, m.GetViewChange())
+	assert.Equal(t, float64(5), nextView.Value())
+	assert.Equal(t, float64(4), currentView.Value())
+
+	vcMsg := proto.Clone(viewChangeMsg).(*protos.Message)
+	vcMsg.GetViewChange().NextView = 5
+	vc.HandleMessage(2, vcMsg)
+	vc.HandleMessage(3, vcMsg)
+	assert.Eventually(t, func() bool {
+		return currentView.Value() == float64(5)
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(4), realView.Value())
+	assert.Equal(t, float64(0), countViewChange.Value())
+
+	vdMsg := proto.Clone(viewDataMsg1).(*protos.Message)
+	vd := &protos.ViewData{}
+	assert.NoError(t, proto.Unmarshal(vdMsg.GetViewData().RawViewData, vd))
+	vd.NextView = 5
+	vdMsg.GetViewData().RawViewData = bft.MarshalOrPanic(vd)
+	vc.HandleMessage(0, vdMsg)
+	vdMsg2 := proto.Clone(vdMsg).(*protos.Message)
+	vdMsg2.GetViewData().Signer = 2
+	vc.HandleMessage(2, vdMsg2)
+	m = <-msgChan
+	assert.NotNil(t, m.GetNewView())
+	<-viewChanged
+
+	assert.Eventually(t, func() bool {
+		return countViewChange.Value() == float64(1)
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(5), realView.Value())
+	assert.Equal(t, float64(5), nextView.Value())
+	assert.Equal(t, 1, latencyViewChange.Count())
+
+	vc.Stop()
+}
+
 func TestBadViewDataMessage(t *testing.T) {
 	// Test that bad view data messages don't cause a view change
 
@@ -1064,6 +1438,62 @@ func TestViewChangerTimeout(t *testing.T) {
 	comm.AssertNumberOfCalls(t, "BroadcastConsensus", 1)
 }
 
+func TestSyncWhenTooManyViewsBehind(t *testing.T) {
+	comm := &mocks.CommMock{}
+	comm.On("BroadcastConsensus", mock.Anything)
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	log := basicLog.Sugar()
+	synchronizer := &mocks.Synchronizer{}
+	synchronizerWG := sync.WaitGroup{}
+	synchronizer.On("Sync").Run(func(args mock.Arguments) {
+		synchronizerWG.Done()
+	})
+
+	vc := &bft.ViewChanger{
+		SelfID:            1,
+		N:                 4,
+		NodesList:         []uint64{1, 2, 3, 4},
+		MaxViewLag:        3,
+		Comm:              comm,
+		Ticker:            make(chan time.Time),
+		Logger:            log,
+		ViewChangeTimeout: 10 * time.Second,
+		ResendTimeout:     20 * time.Second,
+		Synchronizer:      synchronizer,
+		InMsqQSize:        100,
+	}
+
+	vc.Start(0)
+
+	viewChangeTo := func(view uint64) *protos.Message {
+		return &protos.Message{
+			Content: &protos.Message_ViewChange{
+				ViewChange: &protos.ViewChange{
+					NextView: view,
+				},
+			},
+		}
+	}
+
+	// a single node far ahead is not enough, the rest of the cluster needs to be ahead as well
+	vc.HandleMessage(2, viewChangeTo(10))
+	synchronizerWG.Add(1)
+	vc.HandleMessage(3, viewChangeTo(10))
+	synchronizerWG.Wait()
+
+	// the cluster is not further ahead than the view already synced towards
+	vc.HandleMessage(4, viewChangeTo(20))
+	synchronizerWG.Add(1)
+	vc.HandleMessage(3, viewChangeTo(21))
+	synchronizerWG.Wait()
+
+	vc.Stop()
+
+	synchronizer.AssertNumberOfCalls(t, "Sync", 2)
+	comm.AssertNotCalled(t, "BroadcastConsensus", mock.Anything)
+}
+
 func TestBackOff(t *testing.T) {
 	comm := &mocks.CommMock{}
 	comm.On("BroadcastConsensus", mock.Anything)
@@ -1521,6 +1951,76 @@ func TestValidateLastDecision(t *testing.T) {
 	}
 }
 
+func TestValidateDecision(t *testing.T) {
+	md, err := proto.Marshal(&protos.ViewMetadata{LatestSequence: 1})
+	assert.NoError(t, err)
+	proposal := types.Proposal{Metadata: md}
+	sigs := []types.Signature{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	for _, test := range []struct {
+		description  string
+		proposal     types.Proposal
+		signatures   []types.Signature
+		mutateVerify func(*mocks.VerifierMock)
+		err          string
+	}{
+		{
+			description:  "genesis decision",
+			mutateVerify: func(*mocks.VerifierMock) {},
+		},
+		{
+			description:  "unable to unmarshal decision metadata",
+			proposal:     types.Proposal{Metadata: []byte{0}},
+			mutateVerify: func(*mocks.VerifierMock) {},
+			err:          "unable to unmarshal decision metadata",
+		},
+		{
+			description:  "signer is not a member",
+			proposal:     proposal,
+			signatures:   []types.Signature{{ID: 5}, {ID: 1}, {ID: 2}},
+			mutateVerify: func(*mocks.VerifierMock) {},
+			err:          "decision is signed by 5 which is not a member of the nodes [1 2 3 4]",
+		},
+		{
+			description: "invalid signature",
+			proposal:    proposal,
+			signatures:  sigs,
+			mutateVerify: func(verifier *mocks.VerifierMock) {
+				verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(nil, errors.New("bad sig"))
+			},
+			err: "decision signature of 1 is invalid, error: bad sig",
+		},
+		{
+			description: "not enough distinct signers",
+			proposal:    proposal,
+			signatures:  []types.Signature{{ID: 1}, {ID: 2}, {ID: 2}},
+			mutateVerify: func(verifier *mocks.VerifierMock) {
+				verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(nil, nil)
+			},
+			err: "there are only 2 valid decision signatures out of 3 required",
+		},
+		{
+			description: "valid decision",
+			proposal:    proposal,
+			signatures:  sigs,
+			mutateVerify: func(verifier *mocks.VerifierMock) {
+				verifier.On("VerifyConsenterSig", mock.Anything, mock.Anything).Return(nil, nil)
+			},
+		},
+	} {
+		t.Run(test.description, func(t *testing.T) {
+			verifier := &mocks.VerifierMock{}
+			test.mutateVerify(verifier)
+			err := bft.ValidateDecision(test.proposal, test.signatures, []uint64{1, 2, 3, 4}, verifier)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Contains(t, err.Error(), test.err)
+			}
+		})
+	}
+}
+
 func TestValidateInFlight(t *testing.T) {
 	for _, test := range []struct {
 		description      string
@@ -2264,3 +2764,59 @@ func TestDontCommitInFlight(t *testing.T) {
 
 	app.AssertNotCalled(t, "Deliver")
 }
+
+type testGauge struct {
+	lock  sync.Mutex
+	value float64
+}
+
+func (g *testGauge) With(...string) metrics.Gauge {
+	return g
+}
+
+func (g *testGauge) Add(delta float64) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.value += delta
+}
+
+func (g *testGauge) Set(value float64) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.value = value
+}
+
+func (g *testGauge) Value() float64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.value
+}
+
+type testCounter struct {
+	testGauge
+}
+
+func (c *testCounter) With(...string) metrics.Counter {
+	return c
+}
+
+type testHistogram struct {
+	lock         sync.Mutex
+	observations []float64
+}
+
+func (h *testHistogram) With(...string) metrics.Histogram {
+	return h
+}
+
+func (h *testHistogram) Observe(value float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.observations = append(h.observations, value)
+}
+
+func (h *testHistogram) Count() int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return len(h.observations)
+}
@@ -6,6 +6,8 @@
 package api
 
 import (
+	"context"
+
 	bft "github.com/hyperledger-labs/SmartBFT/pkg/types"
 	protos "github.com/hyperledger-labs/SmartBFT/smartbftprotos"
 )
@@ -23,12 +25,54 @@ type Comm interface {
 	// SendConsensus sends the consensus protocol related message m to the node with id targetID.
 	SendConsensus(targetID uint64, m *protos.Message)
 	// SendTransaction sends the given client's request to the node with id targetID.
+	// If ForwardedRequestMaxAge is set, the request is wrapped in an envelope along with its submission time.
 	SendTransaction(targetID uint64, request []byte)
 	// Nodes returns a set of ids of participating nodes.
 	// In case you need to change or keep this slice, create a copy.
 	Nodes() []uint64
 }
 
+// RequestFetcher fetches requests from other nodes, and is typically implemented alongside the Comm.
+type RequestFetcher interface {
+	// FetchRequests returns the requests with the given info that the node with id targetID has, in any order.
+	// The target node is expected to look them up with Consensus.PooledRequests.
+	FetchRequests(targetID uint64, requests []bft.RequestInfo) [][]byte
+}
+
+// NodeIDMapper maps between the internal IDs by which the consensus protocol identifies the nodes,
+// and the client-facing IDs by which the nodes are known to clients and addressed by the Comm.
+type NodeIDMapper interface {
+	// ClientFacingID returns the client-facing ID of the node with the given internal ID.
+	ClientFacingID(internalID uint64) uint64
+	// InternalID returns the internal ID of the node with the given client-facing ID.
+	InternalID(clientFacingID uint64) uint64
+}
+
+// FallibleApplication delivers consensus decisions, and may fail doing so.
+type FallibleApplication interface {
+	// TryDeliver delivers the given proposal and signatures, and returns an error if the proposal
+	// could not be stored in persistent memory, in which case the delivery is retried.
+	// It returns whether this proposal was a reconfiguration and the current config.
+	TryDeliver(proposal bft.Proposal, signature []bft.Signature) (bft.Reconfig, error)
+}
+
+// ViewRecordingApplication is optionally implemented by the Application, to deliver consensus decisions along with
+// the view in which this node committed them.
+type ViewRecordingApplication interface {
+	// DeliverInView delivers the given proposal and signatures, like Application.Deliver.
+	// The commit view is the view in which this node committed the proposal, which differs from the view
+	// in the proposal metadata when the proposal is committed during a view change.
+	DeliverInView(proposal bft.Proposal, signature []bft.Signature, commitView uint64) bft.Reconfig
+}
+
+// FallibleViewRecordingApplication is optionally implemented by the FallibleApplication, to deliver consensus
+// decisions along with the view in which this node committed them.
+type FallibleViewRecordingApplication interface {
+	// TryDeliverInView delivers the given proposal and signatures, like FallibleApplication.TryDeliver,
+	// and is given the commit view like ViewRecordingApplication.DeliverInView.
+	TryDeliverInView(proposal bft.Proposal, signature []bft.Signature, commitView uint64) (bft.Reconfig, error)
+}
+
 // Assembler creates proposals.
 type Assembler interface {
 	// AssembleProposal creates a proposal which includes
@@ -36,6 +80,33 @@ type Assembler interface {
 	AssembleProposal(metadata []byte, requests [][]byte) bft.Proposal
 }
 
+// PayloadCompactor splits proposal payloads into the requests they consist of, and assembles them back,
+// so that proposals can be sent by the digests of their requests. It is typically implemented alongside the Assembler.
+type PayloadCompactor interface {
+	// PayloadRequests returns the requests the given proposal payload consists of.
+	PayloadRequests(payload []byte) [][]byte
+	// AssemblePayload returns the payload that consists of the given requests,
+	// which must be identical to the payload the requests were taken from.
+	AssemblePayload(requests [][]byte) []byte
+}
+
+// FallibleAssembler creates proposals, and may fail doing so.
+type FallibleAssembler interface {
+	// TryAssembleProposal creates a proposal which includes
+	// the given requests (when permitting) and metadata,
+	// or returns an error if the proposal cannot be assembled at the moment.
+	TryAssembleProposal(metadata []byte, requests [][]byte) (bft.Proposal, error)
+}
+
+// CandidateAssembler creates proposals out of a set of candidate requests, selecting which of them to include.
+type CandidateAssembler interface {
+	// AssembleProposalFromCandidates creates a proposal which includes a subset of the given
+	// candidate requests, in any order, and metadata.
+	// It returns the proposal along with the remainder, which are the candidate requests that were not included
+	// in the proposal. The remainder requests stay in the request pool, and are candidates for later proposals.
+	AssembleProposalFromCandidates(metadata []byte, candidates [][]byte) (proposal bft.Proposal, remainder [][]byte)
+}
+
 // WriteAheadLog is write ahead log.
 type WriteAheadLog interface {
 	// Append appends a data item to the end of the WAL
@@ -51,6 +122,15 @@ type Signer interface {
 	SignProposal(proposal bft.Proposal, auxiliaryInput []byte) *bft.Signature
 }
 
+// KeyRotator switches the signing key of the node at a verification sequence boundary.
+// Since the verification sequence changes on all nodes at the same decision, the node starts signing with its
+// new key exactly when the Verifier of its peers starts expecting it.
+type KeyRotator interface {
+	// RotateKey is called when the verification sequence changes, after the decision that changed it
+	// is delivered and before the node signs on any proposal of the new verification sequence.
+	RotateKey(oldVerificationSequence, newVerificationSequence uint64)
+}
+
 // Verifier validates data and verifies signatures.
 type Verifier interface {
 	// VerifyProposal verifies the given proposal and returns the included requests' info.
@@ -70,18 +150,140 @@ type Verifier interface {
 	AuxiliaryData([]byte) []byte
 }
 
+// BatchVerifier is optionally implemented by the Verifier, to verify the signatures of several consenters on a proposal
+// together, which is cheaper than verifying them one by one, for example by aggregating them. It is used to verify
+// the commits accumulated during VoteAggregationWindow.
+type BatchVerifier interface {
+	// VerifyConsenterSigs verifies the given signatures for the given proposal, like Verifier.VerifyConsenterSig,
+	// and returns the error of each signature at its index, which is nil if the signature is valid.
+	VerifyConsenterSigs(signatures []bft.Signature, prop bft.Proposal) []error
+}
+
+// ClientSignatureVerifier verifies the signatures of clients on their requests, and is typically implemented
+// alongside the Verifier.
+type ClientSignatureVerifier interface {
+	// VerifyClientSignature returns an error if the given request is not signed by its client,
+	// or if its signature is invalid.
+	VerifyClientSignature(req []byte) error
+}
+
 // MembershipNotifier notifies if there was a membership change in the last proposal.
 type MembershipNotifier interface {
 	// MembershipChange returns true if there was a membership change in the last proposal.
 	MembershipChange() bool
 }
 
-// RequestInspector extracts info (i.e. request id and client id) from a given request.
+// RemovalRequester creates the reconfiguration requests that remove nodes from the cluster, so that a node can be
+// decommissioned, and is typically implemented alongside the MembershipNotifier.
+type RemovalRequester interface {
+	// RemovalRequest returns a request that, once delivered, reconfigures the cluster to consist of the given nodes
+	// with the given configuration, without the given node which is removed.
+	RemovalRequest(removed uint64, nodes []uint64, config bft.Configuration) []byte
+}
+
+// RequestInspector extracts info (i.e. request id, client id, and optionally a trace id) from a given request.
 type RequestInspector interface {
 	// RequestID returns info about the given request.
 	RequestID(req []byte) bft.RequestInfo
 }
 
+// BatchBoundaryInspector marks requests that end a batch, such as epoch markers, and is typically implemented
+// alongside the RequestInspector.
+type BatchBoundaryInspector interface {
+	// IsBatchBoundary returns whether the given request is a batch boundary, in which case the leader proposes
+	// the batch that includes it right away, without adding the requests submitted after it and without waiting
+	// for the batch to fill up or for the batch interval to elapse.
+	IsBatchBoundary(req []byte) bool
+}
+
+// RequestTypeInspector classifies requests by their type, so that each type can be limited in size on its own,
+// and is typically implemented alongside the RequestInspector.
+type RequestTypeInspector interface {
+	// RequestType returns the type of the given request.
+	RequestType(req []byte) string
+}
+
+// PreOrderFilter lets an external ordering authority, such as a sequencing layer in front of the consensus,
+// decide the order of the requests the leader batches, and reject requests before they are batched.
+type PreOrderFilter interface {
+	// Order receives the candidate requests of the next batch of the leader, in the order of the pool,
+	// and returns the requests to batch out of them, in the order they should be batched in.
+	// The requests that are not returned are rejected, and are removed from the request pool of the leader.
+	// A follower whose pending request times out calls it with just that request, and removes the request
+	// from its request pool if it is not returned, rather than forwarding it to the leader and complaining.
+	// The requests of a group submitted together should either all be returned, next to each other, or all be rejected.
+	Order(requests [][]byte) [][]byte
+}
+
+// DeliveryTracer is notified about the trace IDs of the requests in delivered proposals.
+type DeliveryTracer interface {
+	// OnDeliverTraces is called right before the given proposal is delivered to the application,
+	// with the non-empty trace IDs of its requests, in their order in the proposal.
+	OnDeliverTraces(proposal bft.Proposal, traceIDs []string)
+}
+
+// DecisionDecorator post-processes decided proposals before they are delivered to the application.
+type DecisionDecorator interface {
+	// DecorateDecision returns the proposal to be delivered instead of the given decided proposal,
+	// for example with derived data such as a state root attached to its header.
+	// It must be deterministic so that all correct nodes deliver the same proposal, and it must not change the metadata.
+	DecorateDecision(proposal bft.Proposal) bft.Proposal
+}
+
+// QuorumObserver is notified when the leader stops or resumes observing activity from a quorum of nodes.
+type QuorumObserver interface {
+	// OnQuorumReachabilityChange is called when a quorum of nodes becomes unreachable, in which case
+	// the leader pauses proposing, and when it becomes reachable again.
+	OnQuorumReachabilityChange(reachable bool)
+}
+
+// SuspicionObserver is notified when the leader is suspected by an external monitor.
+type SuspicionObserver interface {
+	// OnLeaderSuspected is called when the given leader of the given view is suspected for the given reason,
+	// right before this node complains about it and starts a view change.
+	OnLeaderSuspected(view uint64, leader uint64, reason string)
+}
+
+// LeadershipObserver is notified when this node becomes or stops being the leader.
+type LeadershipObserver interface {
+	// OnLeadershipChange is called when a view is started in which this node leads and the previous one it
+	// started it did not, or vice versa, and once for the first view started. It is called synchronously
+	// by the consensus, hence it should return quickly.
+	OnLeadershipChange(isLeader bool, view uint64)
+}
+
+// BatchObserver is notified of the batches of requests proposed to this node, so that the application can emit
+// its own metrics about them.
+type BatchObserver interface {
+	// OnBatch is called with a proposal and the info of its requests once this node verified it, which is
+	// on the leader the proposal it assembled, and on the followers the proposal they received from the leader.
+	// It is called synchronously by the consensus, hence it should return quickly.
+	OnBatch(proposal bft.Proposal, requests []bft.RequestInfo)
+}
+
+// RequestAbandonedHandler is notified about requests that were dropped from the request pool.
+type RequestAbandonedHandler interface {
+	// OnRequestAbandoned is called when the given request was removed from the request pool
+	// after the auto-remove timeout expired, without being committed.
+	OnRequestAbandoned(info bft.RequestInfo)
+}
+
+// HaltObserver is notified when the node halts due to a failure it cannot recover from by itself.
+type HaltObserver interface {
+	// OnHalt is called when the node stops making progress because of the given error,
+	// after which it should be stopped and the failure should be resolved by an operator.
+	OnHalt(err error)
+}
+
+// FaultInjector injects synthetic faults into the consensus messages of this node. It is meant for testing,
+// and is consulted only if it is set.
+type FaultInjector interface {
+	// IncomingFault returns the fault to inject into the given message received from the given node.
+	IncomingFault(sender uint64, m *protos.Message) bft.Fault
+	// OutgoingFault returns the fault to inject into the given message sent to the given node.
+	OutgoingFault(target uint64, m *protos.Message) bft.Fault
+}
+
 // Synchronizer reaches the cluster nodes and fetches blocks in order to sync the replica's state.
 type Synchronizer interface {
 	// Sync blocks indefinitely until the replica's state is synchronized to the latest decision,
@@ -89,6 +291,14 @@ type Synchronizer interface {
 	Sync() bft.SyncResponse
 }
 
+// CancellableSynchronizer is a Synchronizer whose sync can be cancelled, once the node learns that it is not
+// behind after all, for example when the gap that triggered the sync was transient.
+type CancellableSynchronizer interface {
+	// SyncWithContext blocks until the replica's state is synchronized to the latest decision, or until the given
+	// context is cancelled, and returns the latest decision synchronized so far with info about reconfiguration.
+	SyncWithContext(ctx context.Context) bft.SyncResponse
+}
+
 // Logger defines the contract for logging.
 type Logger interface {
 	Debugf(template string, args ...interface{})
@@ -11,6 +11,7 @@ import (
 const (
 	ReasonRequestMaxBytes      = "MAX_BYTES"
 	ReasonSemaphoreAcquireFail = "SEMAPHORE_ACQUIRE_FAIL"
+	ReasonPoolMaxBytes         = "POOL_MAX_BYTES"
 )
 
 func NewGaugeOpts(old metrics.GaugeOpts, labelNames []string) metrics.GaugeOpts {
@@ -112,6 +113,15 @@ var countOfRequestPoolOpts = metrics.GaugeOpts{
 	StatsdFormat: "%{#fqname}",
 }
 
+var countOfProcessedRequestsOpts = metrics.GaugeOpts{
+	Namespace:    "consensus",
+	Subsystem:    "smartbft",
+	Name:         "pool_count_of_processed_requests",
+	Help:         "Number of processed requests remembered by the request pool to reject their resubmission.",
+	LabelNames:   []string{},
+	StatsdFormat: "%{#fqname}",
+}
+
 var countOfFailAddRequestToPoolOpts = metrics.CounterOpts{
 	Namespace:    "consensus",
 	Subsystem:    "smartbft",
@@ -168,15 +178,27 @@ var latencyOfRequestPoolOpts = metrics.HistogramOpts{
 	StatsdFormat: "%{#fqname}",
 }
 
+var latencyOfRequestDeliveryOpts = metrics.HistogramOpts{
+	Namespace:    "consensus",
+	Subsystem:    "smartbft",
+	Name:         "pool_latency_of_delivery",
+	Help:         "The time from the submission of a request until its delivery.",
+	Buckets:      []float64{0.005, 0.01, 0.015, 0.05, 0.1, 1, 10},
+	LabelNames:   []string{},
+	StatsdFormat: "%{#fqname}",
+}
+
 // MetricsRequestPool encapsulates request pool metrics
 type MetricsRequestPool struct {
 	CountOfRequestPool          metrics.Gauge
+	CountOfProcessedRequests    metrics.Gauge
 	CountOfFailAddRequestToPool metrics.Counter
 	CountOfLeaderForwardRequest metrics.Counter
 	CountTimeoutTwoStep         metrics.Counter
 	CountOfDeleteRequestPool    metrics.Counter
 	CountOfRequestPoolAll       metrics.Counter
 	LatencyOfRequestPool        metrics.Histogram
+	LatencyOfRequestDelivery    metrics.Histogram
 
 	labels []string
 }
@@ -184,49 +206,60 @@ type MetricsRequestPool struct {
 // NewMetricsRequestPool create new request pool metrics
 func NewMetricsRequestPool(p metrics.Provider, labelNames ...string) *MetricsRequestPool {
 	countOfRequestPoolOptsTmp := NewGaugeOpts(countOfRequestPoolOpts, labelNames)
+	countOfProcessedRequestsOptsTmp := NewGaugeOpts(countOfProcessedRequestsOpts, labelNames)
 	countOfFailAddRequestToPoolOptsTmp := NewCounterOpts(countOfFailAddRequestToPoolOpts, labelNames)
 	countOfLeaderForwardRequestOptsTmp := NewCounterOpts(countOfLeaderForwardRequestOpts, labelNames)
 	countTimeoutTwoStepOptsTmp := NewCounterOpts(countTimeoutTwoStepOpts, labelNames)
 	countOfDeleteRequestPoolOptsTmp := NewCounterOpts(countOfDeleteRequestPoolOpts, labelNames)
 	countOfRequestPoolAllOptsTmp := NewCounterOpts(countOfRequestPoolAllOpts, labelNames)
 	latencyOfRequestPoolOptsTmp := NewHistogramOpts(latencyOfRequestPoolOpts, labelNames)
+	latencyOfRequestDeliveryOptsTmp := NewHistogramOpts(latencyOfRequestDeliveryOpts, labelNames)
 	return &MetricsRequestPool{
 		CountOfRequestPool:          p.NewGauge(countOfRequestPoolOptsTmp),
+		CountOfProcessedRequests:    p.NewGauge(countOfProcessedRequestsOptsTmp),
 		CountOfFailAddRequestToPool: p.NewCounter(countOfFailAddRequestToPoolOptsTmp),
 		CountOfLeaderForwardRequest: p.NewCounter(countOfLeaderForwardRequestOptsTmp),
 		CountTimeoutTwoStep:         p.NewCounter(countTimeoutTwoStepOptsTmp),
 		CountOfDeleteRequestPool:    p.NewCounter(countOfDeleteRequestPoolOptsTmp),
 		CountOfRequestPoolAll:       p.NewCounter(countOfRequestPoolAllOptsTmp),
 		LatencyOfRequestPool:        p.NewHistogram(latencyOfRequestPoolOptsTmp),
+		LatencyOfRequestDelivery:    p.NewHistogram(latencyOfRequestDeliveryOptsTmp),
 	}
 }
 
 func (m *MetricsRequestPool) With(labelValues ...string) *MetricsRequestPool {
 	return &MetricsRequestPool{
 		CountOfRequestPool:          m.CountOfRequestPool.With(labelValues...),
+		CountOfProcessedRequests:    m.CountOfProcessedRequests.With(labelValues...),
 		CountOfFailAddRequestToPool: m.CountOfFailAddRequestToPool,
 		CountOfLeaderForwardRequest: m.CountOfLeaderForwardRequest.With(labelValues...),
 		CountTimeoutTwoStep:         m.CountTimeoutTwoStep.With(labelValues...),
 		CountOfDeleteRequestPool:    m.CountOfDeleteRequestPool.With(labelValues...),
 		CountOfRequestPoolAll:       m.CountOfRequestPoolAll.With(labelValues...),
 		LatencyOfRequestPool:        m.LatencyOfRequestPool.With(labelValues...),
+		LatencyOfRequestDelivery:    m.LatencyOfRequestDelivery.With(labelValues...),
 		labels:                      labelValues,
 	}
 }
 
 func (m *MetricsRequestPool) Initialize() {
 	m.CountOfRequestPool.Add(0)
+	m.CountOfProcessedRequests.Add(0)
 	m.CountOfFailAddRequestToPool.With(
 		m.LabelsForWith("reason", ReasonRequestMaxBytes)...,
 	).Add(0)
 	m.CountOfFailAddRequestToPool.With(
 		m.LabelsForWith("reason", ReasonSemaphoreAcquireFail)...,
 	).Add(0)
+	m.CountOfFailAddRequestToPool.With(
+		m.LabelsForWith("reason", ReasonPoolMaxBytes)...,
+	).Add(0)
 	m.CountOfLeaderForwardRequest.Add(0)
 	m.CountTimeoutTwoStep.Add(0)
 	m.CountOfDeleteRequestPool.Add(0)
 	m.CountOfRequestPoolAll.Add(0)
 	m.LatencyOfRequestPool.Observe(0)
+	m.LatencyOfRequestDelivery.Observe(0)
 }
 
 func (m *MetricsRequestPool) LabelsForWith(labelValues ...string) []string {
@@ -315,19 +348,44 @@ var latencySyncOpts = metrics.HistogramOpts{
 	StatsdFormat: "%{#fqname}",
 }
 
+var latencyWALWriteOpts = metrics.HistogramOpts{
+	Namespace:    "consensus",
+	Subsystem:    "smartbft",
+	Name:         "consensus_latency_wal_write",
+	Help:         "An average time it takes to persist a message to the write ahead log.",
+	Buckets:      []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 1},
+	LabelNames:   []string{},
+	StatsdFormat: "%{#fqname}",
+}
+
+var countPausedDecisionsOpts = metrics.GaugeOpts{
+	Namespace:    "consensus",
+	Subsystem:    "smartbft",
+	Name:         "consensus_count_paused_decisions",
+	Help:         "Number of decisions held back while delivery is paused.",
+	LabelNames:   []string{},
+	StatsdFormat: "%{#fqname}",
+}
+
 // MetricsConsensus encapsulates consensus metrics
 type MetricsConsensus struct {
 	CountConsensusReconfig metrics.Counter
 	LatencySync            metrics.Histogram
+	LatencyWALWrite        metrics.Histogram
+	CountPausedDecisions   metrics.Gauge
 }
 
 // NewMetricsConsensus create new consensus metrics
 func NewMetricsConsensus(p metrics.Provider, labelNames ...string) *MetricsConsensus {
 	consensusReconfigOptsTmp := NewCounterOpts(consensusReconfigOpts, labelNames)
 	latencySyncOptsTmp := NewHistogramOpts(latencySyncOpts, labelNames)
+	latencyWALWriteOptsTmp := NewHistogramOpts(latencyWALWriteOpts, labelNames)
+	countPausedDecisionsOptsTmp := NewGaugeOpts(countPausedDecisionsOpts, labelNames)
 	return &MetricsConsensus{
 		CountConsensusReconfig: p.NewCounter(consensusReconfigOptsTmp),
 		LatencySync:            p.NewHistogram(latencySyncOptsTmp),
+		LatencyWALWrite:        p.NewHistogram(latencyWALWriteOptsTmp),
+		CountPausedDecisions:   p.NewGauge(countPausedDecisionsOptsTmp),
 	}
 }
 
@@ -335,12 +393,16 @@ func (m *MetricsConsensus) With(labelValues ...string) *MetricsConsensus {
 	return &MetricsConsensus{
 		CountConsensusReconfig: m.CountConsensusReconfig.With(labelValues...),
 		LatencySync:            m.LatencySync.With(labelValues...),
+		LatencyWALWrite:        m.LatencyWALWrite.With(labelValues...),
+		CountPausedDecisions:   m.CountPausedDecisions.With(labelValues...),
 	}
 }
 
 func (m *MetricsConsensus) Initialize() {
 	m.CountConsensusReconfig.Add(0)
 	m.LatencySync.Observe(0)
+	m.LatencyWALWrite.Observe(0)
+	m.CountPausedDecisions.Set(0)
 }
 
 var viewNumberOpts = metrics.GaugeOpts{
@@ -415,6 +477,24 @@ var countTxsAllOpts = metrics.CounterOpts{
 	StatsdFormat: "%{#fqname}",
 }
 
+var countBatchesInViewOpts = metrics.GaugeOpts{
+	Namespace:    "consensus",
+	Subsystem:    "smartbft",
+	Name:         "view_count_batches_in_view",
+	Help:         "Amount of batches processed since the current view started.",
+	LabelNames:   []string{},
+	StatsdFormat: "%{#fqname}",
+}
+
+var countTxsInViewOpts = metrics.GaugeOpts{
+	Namespace:    "consensus",
+	Subsystem:    "smartbft",
+	Name:         "view_count_txs_in_view",
+	Help:         "Amount of transactions processed since the current view started.",
+	LabelNames:   []string{},
+	StatsdFormat: "%{#fqname}",
+}
+
 var sizeOfBatchOpts = metrics.CounterOpts{
 	Namespace:    "consensus",
 	Subsystem:    "smartbft",
@@ -454,6 +534,8 @@ type MetricsView struct {
 	CountTxsInBatch        metrics.Gauge
 	CountBatchAll          metrics.Counter
 	CountTxsAll            metrics.Counter
+	CountBatchesInView     metrics.Gauge
+	CountTxsInView         metrics.Gauge
 	SizeOfBatch            metrics.Counter
 	LatencyBatchProcessing metrics.Histogram
 	LatencyBatchSave       metrics.Histogram
@@ -469,6 +551,8 @@ func NewMetricsView(p metrics.Provider, labelNames ...string) *MetricsView {
 	countTxsInBatchOptsTmp := NewGaugeOpts(countTxsInBatchOpts, labelNames)
 	countBatchAllOptsTmp := NewCounterOpts(countBatchAllOpts, labelNames)
 	countTxsAllOptsTmp := NewCounterOpts(countTxsAllOpts, labelNames)
+	countBatchesInViewOptsTmp := NewGaugeOpts(countBatchesInViewOpts, labelNames)
+	countTxsInViewOptsTmp := NewGaugeOpts(countTxsInViewOpts, labelNames)
 	sizeOfBatchOptsTmp := NewCounterOpts(sizeOfBatchOpts, labelNames)
 	latencyBatchProcessingOptsTmp := NewHistogramOpts(latencyBatchProcessingOpts, labelNames)
 	latencyBatchSaveOptsTmp := NewHistogramOpts(latencyBatchSaveOpts, labelNames)
@@ -481,6 +565,8 @@ func NewMetricsView(p metrics.Provider, labelNames ...string) *MetricsView {
 		CountTxsInBatch:        p.NewGauge(countTxsInBatchOptsTmp),
 		CountBatchAll:          p.NewCounter(countBatchAllOptsTmp),
 		CountTxsAll:            p.NewCounter(countTxsAllOptsTmp),
+		CountBatchesInView:     p.NewGauge(countBatchesInViewOptsTmp),
+		CountTxsInView:         p.NewGauge(countTxsInViewOptsTmp),
 		SizeOfBatch:            p.NewCounter(sizeOfBatchOptsTmp),
 		LatencyBatchProcessing: p.NewHistogram(latencyBatchProcessingOptsTmp),
 		LatencyBatchSave:       p.NewHistogram(latencyBatchSaveOptsTmp),
@@ -497,6 +583,8 @@ func (m *MetricsView) With(labelValues ...string) *MetricsView {
 		CountTxsInBatch:        m.CountTxsInBatch.With(labelValues...),
 		CountBatchAll:          m.CountBatchAll.With(labelValues...),
 		CountTxsAll:            m.CountTxsAll.With(labelValues...),
+		CountBatchesInView:     m.CountBatchesInView.With(labelValues...),
+		CountTxsInView:         m.CountTxsInView.With(labelValues...),
 		SizeOfBatch:            m.SizeOfBatch.With(labelValues...),
 		LatencyBatchProcessing: m.LatencyBatchProcessing.With(labelValues...),
 		LatencyBatchSave:       m.LatencyBatchSave.With(labelValues...),
@@ -512,6 +600,8 @@ func (m *MetricsView) Initialize() {
 	m.CountTxsInBatch.Add(0)
 	m.CountBatchAll.Add(0)
 	m.CountTxsAll.Add(0)
+	m.CountBatchesInView.Add(0)
+	m.CountTxsInView.Add(0)
 	m.SizeOfBatch.Add(0)
 	m.LatencyBatchProcessing.Observe(0)
 	m.LatencyBatchSave.Observe(0)
@@ -544,11 +634,32 @@ var realViewOpts = metrics.GaugeOpts{
 	StatsdFormat: "%{#fqname}",
 }
 
+var countViewChangeOpts = metrics.CounterOpts{
+	Namespace:    "consensus",
+	Subsystem:    "smartbft",
+	Name:         "viewchange_count_of_completed",
+	Help:         "Number of completed view changes on this channel.",
+	LabelNames:   []string{},
+	StatsdFormat: "%{#fqname}",
+}
+
+var latencyViewChangeOpts = metrics.HistogramOpts{
+	Namespace:    "consensus",
+	Subsystem:    "smartbft",
+	Name:         "viewchange_latency",
+	Help:         "Amount of time it takes from starting a view change until the new view is installed.",
+	Buckets:      []float64{0.005, 0.01, 0.015, 0.05, 0.1, 1, 10},
+	LabelNames:   []string{},
+	StatsdFormat: "%{#fqname}",
+}
+
 // MetricsViewChange encapsulates view change metrics
 type MetricsViewChange struct {
-	CurrentView metrics.Gauge
-	NextView    metrics.Gauge
-	RealView    metrics.Gauge
+	CurrentView       metrics.Gauge
+	NextView          metrics.Gauge
+	RealView          metrics.Gauge
+	CountViewChange   metrics.Counter
+	LatencyViewChange metrics.Histogram
 }
 
 // NewMetricsViewChange create new view change metrics
@@ -556,18 +667,24 @@ func NewMetricsViewChange(p metrics.Provider, labelNames ...string) *MetricsView
 	currentViewOptsTmp := NewGaugeOpts(currentViewOpts, labelNames)
 	nextViewOptsTmp := NewGaugeOpts(nextViewOpts, labelNames)
 	realViewOptsTmp := NewGaugeOpts(realViewOpts, labelNames)
+	countViewChangeOptsTmp := NewCounterOpts(countViewChangeOpts, labelNames)
+	latencyViewChangeOptsTmp := NewHistogramOpts(latencyViewChangeOpts, labelNames)
 	return &MetricsViewChange{
-		CurrentView: p.NewGauge(currentViewOptsTmp),
-		NextView:    p.NewGauge(nextViewOptsTmp),
-		RealView:    p.NewGauge(realViewOptsTmp),
+		CurrentView:       p.NewGauge(currentViewOptsTmp),
+		NextView:          p.NewGauge(nextViewOptsTmp),
+		RealView:          p.NewGauge(realViewOptsTmp),
+		CountViewChange:   p.NewCounter(countViewChangeOptsTmp),
+		LatencyViewChange: p.NewHistogram(latencyViewChangeOptsTmp),
 	}
 }
 
 func (m *MetricsViewChange) With(labelValues ...string) *MetricsViewChange {
 	return &MetricsViewChange{
-		CurrentView: m.CurrentView.With(labelValues...),
-		NextView:    m.NextView.With(labelValues...),
-		RealView:    m.RealView.With(labelValues...),
+		CurrentView:       m.CurrentView.With(labelValues...),
+		NextView:          m.NextView.With(labelValues...),
+		RealView:          m.RealView.With(labelValues...),
+		CountViewChange:   m.CountViewChange.With(labelValues...),
+		LatencyViewChange: m.LatencyViewChange.With(labelValues...),
 	}
 }
 
@@ -575,4 +692,6 @@ func (m *MetricsViewChange) Initialize() {
 	m.CurrentView.Add(0)
 	m.NextView.Add(0)
 	m.RealView.Add(0)
+	m.CountViewChange.Add(0)
+	m.LatencyViewChange.Observe(0)
 }
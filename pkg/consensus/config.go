@@ -0,0 +1,137 @@
+// Copyright IBM Corp. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package consensus
+
+import (
+	"time"
+
+	bft "github.com/hyperledger-labs/SmartBFT/pkg/api"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics/disabled"
+	"github.com/hyperledger-labs/SmartBFT/pkg/types"
+	protos "github.com/hyperledger-labs/SmartBFT/smartbftprotos"
+	"github.com/pkg/errors"
+)
+
+// defaultTickInterval is the interval of the tickers New creates for a Scheduler and a ViewChangerTicker that are not set
+const defaultTickInterval = time.Second
+
+// Config holds the configuration and the dependencies of a Consensus created by New.
+// Each field has the meaning of the field of Consensus with the same name.
+type Config struct {
+	Config              types.Configuration
+	Application         bft.Application
+	FallibleApplication bft.FallibleApplication
+	Assembler           bft.Assembler
+	CandidateAssembler  bft.CandidateAssembler
+	FallibleAssembler   bft.FallibleAssembler
+	PayloadCompactor    bft.PayloadCompactor
+	WAL                 bft.WriteAheadLog
+	WALInitialContent   [][]byte
+	Comm                bft.Comm
+	NodeIDMapper        bft.NodeIDMapper
+	RequestFetcher      bft.RequestFetcher
+	Signer              bft.Signer
+	KeyRotator          bft.KeyRotator
+	Verifier            bft.Verifier
+	MembershipNotifier  bft.MembershipNotifier
+	RemovalRequester    bft.RemovalRequester
+	RequestInspector    bft.RequestInspector
+	ClientSigVerifier   bft.ClientSignatureVerifier
+	BoundaryInspector   bft.BatchBoundaryInspector
+	TypeInspector       bft.RequestTypeInspector
+	PreOrderFilter      bft.PreOrderFilter
+	RequestAbandoned    bft.RequestAbandonedHandler
+	DeliveryTracer      bft.DeliveryTracer
+	DecisionDecorator   bft.DecisionDecorator
+	ProposalEquivalence func(a, b types.Proposal) bool
+	QuorumObserver      bft.QuorumObserver
+	SuspicionObserver   bft.SuspicionObserver
+	LeadershipObserver  bft.LeadershipObserver
+	FaultInjector       bft.FaultInjector
+	BatchObserver       bft.BatchObserver
+	HaltObserver        bft.HaltObserver
+	Synchronizer        bft.Synchronizer
+	CancellableSync     bft.CancellableSynchronizer
+	Logger              bft.Logger
+	Metrics             *bft.Metrics
+	Metadata            *protos.ViewMetadata
+	LastProposal        types.Proposal
+	LastSignatures      []types.Signature
+	Scheduler           <-chan time.Time
+	ViewChangerTicker   <-chan time.Time
+}
+
+// New returns a Consensus with the given configuration and dependencies, which is yet to be started.
+// It returns an error naming the first required dependency that is missing, as ValidateDependencies does,
+// or describing why the configuration is invalid. The optional dependencies that are not set are defaulted:
+// Metrics are disabled, the Metadata is empty, and the Scheduler and the ViewChangerTicker tick every second.
+func New(cfg Config) (*Consensus, error) {
+	c := &Consensus{
+		Config:              cfg.Config,
+		Application:         cfg.Application,
+		FallibleApplication: cfg.FallibleApplication,
+		Assembler:           cfg.Assembler,
+		CandidateAssembler:  cfg.CandidateAssembler,
+		FallibleAssembler:   cfg.FallibleAssembler,
+		PayloadCompactor:    cfg.PayloadCompactor,
+		WAL:                 cfg.WAL,
+		WALInitialContent:   cfg.WALInitialContent,
+		Comm:                cfg.Comm,
+		NodeIDMapper:        cfg.NodeIDMapper,
+		RequestFetcher:      cfg.RequestFetcher,
+		Signer:              cfg.Signer,
+		KeyRotator:          cfg.KeyRotator,
+		Verifier:            cfg.Verifier,
+		MembershipNotifier:  cfg.MembershipNotifier,
+		RemovalRequester:    cfg.RemovalRequester,
+		RequestInspector:    cfg.RequestInspector,
+		ClientSigVerifier:   cfg.ClientSigVerifier,
+		BoundaryInspector:   cfg.BoundaryInspector,
+		TypeInspector:       cfg.TypeInspector,
+		PreOrderFilter:      cfg.PreOrderFilter,
+		RequestAbandoned:    cfg.RequestAbandoned,
+		DeliveryTracer:      cfg.DeliveryTracer,
+		DecisionDecorator:   cfg.DecisionDecorator,
+		ProposalEquivalence: cfg.ProposalEquivalence,
+		QuorumObserver:      cfg.QuorumObserver,
+		SuspicionObserver:   cfg.SuspicionObserver,
+		LeadershipObserver:  cfg.LeadershipObserver,
+		FaultInjector:       cfg.FaultInjector,
+		BatchObserver:       cfg.BatchObserver,
+		HaltObserver:        cfg.HaltObserver,
+		Synchronizer:        cfg.Synchronizer,
+		CancellableSync:     cfg.CancellableSync,
+		Logger:              cfg.Logger,
+		Metrics:             cfg.Metrics,
+		Metadata:            cfg.Metadata,
+		LastProposal:        cfg.LastProposal,
+		LastSignatures:      cfg.LastSignatures,
+		Scheduler:           cfg.Scheduler,
+		ViewChangerTicker:   cfg.ViewChangerTicker,
+	}
+
+	if err := c.ValidateDependencies(); err != nil {
+		return nil, errors.Wrapf(err, "dependencies are invalid")
+	}
+	if err := c.Config.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "configuration is invalid")
+	}
+
+	if c.Metrics == nil {
+		c.Metrics = bft.NewMetrics(&disabled.Provider{})
+	}
+	if c.Metadata == nil {
+		c.Metadata = &protos.ViewMetadata{}
+	}
+	if c.Scheduler == nil {
+		c.Scheduler = time.NewTicker(defaultTickInterval).C
+	}
+	if c.ViewChangerTicker == nil {
+		c.ViewChangerTicker = time.NewTicker(defaultTickInterval).C
+	}
+
+	return c, nil
+}
@@ -6,6 +6,7 @@
 package consensus
 
 import (
+	"context"
 	"sort"
 	"strings"
 	"sync"
@@ -21,49 +22,116 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ErrLeaderNotDraining is returned by SubmitRequest when the request pool is full and no request left it
+// while the submission waited, which indicates that the leader does not keep up and clients should back off.
+var ErrLeaderNotDraining = algorithm.ErrLeaderNotDraining
+
+// ErrPoolMaxBytes is returned by SubmitRequest when the request would make the pending requests exceed
+// RequestPoolMaxBytes.
+var ErrPoolMaxBytes = algorithm.ErrPoolMaxBytes
+
+// decommissionPollInterval is how often Decommission checks whether the step it waits for is done
+const decommissionPollInterval = 100 * time.Millisecond
+
+// defaultPausedDecisionsBufferSize is the number of decisions held back while delivery is paused,
+// if PausedDecisionsBufferSize is zero
+const defaultPausedDecisionsBufferSize = 100
+
+// leaderHistorySize is the number of most recent views whose leaders are remembered
+const leaderHistorySize = 1000
+
 // Consensus submits requests to be total ordered,
 // and delivers to the application proposals by invoking Deliver() on it.
 // The proposals contain batches of requests assembled together by the Assembler.
 type Consensus struct {
-	Config             types.Configuration
-	Application        bft.Application
-	Assembler          bft.Assembler
-	WAL                bft.WriteAheadLog
-	WALInitialContent  [][]byte
-	Comm               bft.Comm
-	Signer             bft.Signer
-	Verifier           bft.Verifier
-	MembershipNotifier bft.MembershipNotifier
-	RequestInspector   bft.RequestInspector
-	Synchronizer       bft.Synchronizer
-	Logger             bft.Logger
-	Metrics            *bft.Metrics
-	Metadata           *protos.ViewMetadata
-	LastProposal       types.Proposal
-	LastSignatures     []types.Signature
-	Scheduler          <-chan time.Time
-	ViewChangerTicker  <-chan time.Time
-
-	submittedChan chan struct{}
-	inFlight      *algorithm.InFlightData
-	checkpoint    *types.Checkpoint
-	Pool          *algorithm.Pool
-	viewChanger   *algorithm.ViewChanger
-	controller    *algorithm.Controller
-	collector     *algorithm.StateCollector
-	state         *algorithm.PersistedState
-	numberOfNodes uint64
-	nodes         []uint64
-	nodeMap       sync.Map
+	Config              types.Configuration
+	Application         bft.Application
+	FallibleApplication bft.FallibleApplication
+	Assembler           bft.Assembler
+	CandidateAssembler  bft.CandidateAssembler
+	FallibleAssembler   bft.FallibleAssembler
+	PayloadCompactor    bft.PayloadCompactor
+	WAL                 bft.WriteAheadLog
+	WALInitialContent   [][]byte
+	Comm                bft.Comm
+	NodeIDMapper        bft.NodeIDMapper
+	RequestFetcher      bft.RequestFetcher
+	Signer              bft.Signer
+	KeyRotator          bft.KeyRotator
+	Verifier            bft.Verifier
+	MembershipNotifier  bft.MembershipNotifier
+	RemovalRequester    bft.RemovalRequester
+	RequestInspector    bft.RequestInspector
+	ClientSigVerifier   bft.ClientSignatureVerifier
+	BoundaryInspector   bft.BatchBoundaryInspector
+	TypeInspector       bft.RequestTypeInspector
+	PreOrderFilter      bft.PreOrderFilter
+	RequestAbandoned    bft.RequestAbandonedHandler
+	DeliveryTracer      bft.DeliveryTracer
+	DecisionDecorator   bft.DecisionDecorator
+	// ProposalEquivalence optionally decides whether two proposals are the same, for applications whose proposals
+	// carry incidental data such as timestamps. Proposals are compared by their digests if it is not set.
+	ProposalEquivalence func(a, b types.Proposal) bool
+	QuorumObserver      bft.QuorumObserver
+	SuspicionObserver   bft.SuspicionObserver
+	LeadershipObserver  bft.LeadershipObserver
+	FaultInjector       bft.FaultInjector
+	BatchObserver       bft.BatchObserver
+	HaltObserver        bft.HaltObserver
+	Synchronizer        bft.Synchronizer
+	CancellableSync     bft.CancellableSynchronizer
+	Logger              bft.Logger
+	Metrics             *bft.Metrics
+	Metadata            *protos.ViewMetadata
+	LastProposal        types.Proposal
+	LastSignatures      []types.Signature
+	Scheduler           <-chan time.Time
+	ViewChangerTicker   <-chan time.Time
+
+	submittedChan     chan struct{}
+	comm              bft.Comm
+	fetcher           bft.RequestFetcher
+	verifier          bft.Verifier
+	clientSigVerifier bft.ClientSignatureVerifier
+	// verificationLimiter is shared with the other instances of the ConsensusGroup this instance is registered in
+	verificationLimiter *algorithm.VerificationLimiter
+	inFlight            *algorithm.InFlightData
+	checkpoint          *types.Checkpoint
+	leaderHistory       *algorithm.LeaderHistory
+	decisionHistory     *algorithm.DecisionHistory
+	dryRun              *algorithm.DryRun
+	Pool                *algorithm.Pool
+	viewChanger         *algorithm.ViewChanger
+	controller          *algorithm.Controller
+	collector           *algorithm.StateCollector
+	state               *algorithm.PersistedState
+	numberOfNodes       uint64
+	nodes               []uint64
+	nodeMap             sync.Map
 
 	consensusDone sync.WaitGroup
 	stopOnce      sync.Once
 	stopChan      chan struct{}
+	stoppingOnce  sync.Once
+	stoppingChan  chan struct{} // closed once Stop is called, before the components are stopped
 
 	consensusLock sync.RWMutex
 
 	reconfigChan chan types.Reconfig
+	decisions    chan types.Decision
 	running      uint64
+	evicted      uint64 // set once a reconfiguration removed this node
+
+	deliveredLock    sync.Mutex
+	deliveredSeq     uint64
+	deliveredChanged chan struct{}
+	deliverApp       applicationDeliverer
+
+	deliveryLock     sync.Mutex
+	deliveryPaused   bool
+	drainingPaused   bool             // set while the decisions held back while paused are delivered
+	pausedOverflowed bool             // set once more than PausedDecisionsBufferSize decisions were held back
+	pausedDecisions  []types.Decision // decisions acknowledged while delivery is paused, delivered once it is resumed
 }
 
 func (c *Consensus) Complain(viewNum uint64, stopView bool) {
@@ -72,8 +140,160 @@ func (c *Consensus) Complain(viewNum uint64, stopView bool) {
 	c.viewChanger.StartViewChange(viewNum, stopView)
 }
 
+// SuspectLeader lets an external monitor suspect the current leader, which makes this node complain about it
+// and start a view change. The reason is logged and reported to the SuspicionObserver.
+func (c *Consensus) SuspectLeader(reason string) {
+	c.consensusLock.RLock()
+	controller := c.controller
+	c.consensusLock.RUnlock()
+	if controller == nil {
+		return
+	}
+	controller.SuspectLeader(reason)
+}
+
 func (c *Consensus) Deliver(proposal types.Proposal, signatures []types.Signature) types.Reconfig {
-	reconfig := c.Application.Deliver(proposal, signatures)
+	return c.DeliverInView(proposal, signatures, 0)
+}
+
+// DeliverInView delivers the given decision, which this node committed in the given view,
+// and records the view in the decisions streamed and retained in the decision history.
+// While delivery is paused, the decision is acknowledged right away and held back until delivery is resumed.
+func (c *Consensus) DeliverInView(proposal types.Proposal, signatures []types.Signature, commitView uint64) types.Reconfig {
+	c.deliveryLock.Lock()
+	if c.deliveryPaused || c.drainingPaused {
+		c.holdDecision(types.Decision{Proposal: proposal, Signatures: signatures, CommitView: commitView})
+		c.deliveryLock.Unlock()
+		return types.Reconfig{}
+	}
+	c.deliveryLock.Unlock()
+	return c.deliver(proposal, signatures, commitView)
+}
+
+// holdDecision appends the given decision to the decisions held back while delivery is paused.
+// Once more than PausedDecisionsBufferSize decisions are held back, they are all discarded, and the node syncs
+// instead once delivery is resumed. It is called while holding the deliveryLock.
+func (c *Consensus) holdDecision(decision types.Decision) {
+	if c.pausedOverflowed {
+		return
+	}
+	bufferSize := c.Config.PausedDecisionsBufferSize
+	if bufferSize == 0 {
+		bufferSize = defaultPausedDecisionsBufferSize
+	}
+	if uint64(len(c.pausedDecisions)) >= bufferSize {
+		c.Logger.Warnf("More than %d decisions were ordered while delivery is paused, discarding them and syncing once delivery is resumed",
+			bufferSize)
+		c.pausedDecisions = nil
+		c.pausedOverflowed = true
+	} else {
+		c.pausedDecisions = append(c.pausedDecisions, decision)
+	}
+	c.Metrics.MetricsConsensus.CountPausedDecisions.Set(float64(len(c.pausedDecisions)))
+}
+
+// PauseDelivery pauses the delivery of decisions to the application, for example to take a consistent snapshot
+// of the application. The node keeps ordering in the meantime: the decisions are acknowledged to the ordering
+// components as they are committed, and are held back in order until ResumeDelivery is called.
+// Up to PausedDecisionsBufferSize decisions are held back, after which the node syncs once delivery is resumed.
+// Since the decisions are acknowledged, the checkpoint moves ahead of the application, and a reconfiguration
+// in a held back decision takes effect only once it is delivered.
+func (c *Consensus) PauseDelivery() {
+	c.deliveryLock.Lock()
+	defer c.deliveryLock.Unlock()
+	c.deliveryPaused = true
+}
+
+// ResumeDelivery resumes delivering decisions. The decisions held back while delivery was paused are delivered
+// in the background and in order, before any decision ordered afterwards.
+func (c *Consensus) ResumeDelivery() {
+	c.deliveryLock.Lock()
+	defer c.deliveryLock.Unlock()
+	if !c.deliveryPaused {
+		return
+	}
+	c.deliveryPaused = false
+	if c.drainingPaused || (len(c.pausedDecisions) == 0 && !c.pausedOverflowed) {
+		return
+	}
+	c.drainingPaused = true
+	go c.drainPausedDecisions()
+}
+
+// drainPausedDecisions delivers the decisions held back while delivery was paused, in order, skipping those that
+// were delivered meanwhile by a sync. If the decisions overflowed, it syncs instead. It returns once no decisions
+// are held back, or once delivery is paused again.
+func (c *Consensus) drainPausedDecisions() {
+	for {
+		c.deliveryLock.Lock()
+		if c.deliveryPaused || (len(c.pausedDecisions) == 0 && !c.pausedOverflowed) {
+			c.drainingPaused = false
+			c.deliveryLock.Unlock()
+			return
+		}
+		if c.pausedOverflowed {
+			c.pausedOverflowed = false
+			c.deliveryLock.Unlock()
+			c.Logger.Infof("Syncing the decisions ordered while delivery was paused")
+			c.Sync()
+			continue
+		}
+		decision := c.pausedDecisions[0]
+		c.pausedDecisions = c.pausedDecisions[1:]
+		c.Metrics.MetricsConsensus.CountPausedDecisions.Set(float64(len(c.pausedDecisions)))
+		c.deliveryLock.Unlock()
+
+		if c.alreadyDelivered(decision) {
+			continue
+		}
+		c.deliver(decision.Proposal, decision.Signatures, decision.CommitView)
+		select {
+		case <-c.stoppingChan:
+			return
+		default:
+		}
+	}
+}
+
+// alreadyDelivered returns whether the sequence of the given decision was already delivered
+func (c *Consensus) alreadyDelivered(decision types.Decision) bool {
+	if len(decision.Proposal.Metadata) == 0 {
+		return false
+	}
+	md := &protos.ViewMetadata{}
+	if err := proto.Unmarshal(decision.Proposal.Metadata, md); err != nil {
+		return false
+	}
+	c.deliveredLock.Lock()
+	defer c.deliveredLock.Unlock()
+	return md.LatestSequence <= c.deliveredSeq
+}
+
+// deliver delivers the given decision to the application, streams it and retains it,
+// and passes on the reconfiguration it carries.
+func (c *Consensus) deliver(proposal types.Proposal, signatures []types.Signature, commitView uint64) types.Reconfig {
+	if c.DecisionDecorator != nil {
+		proposal = c.DecisionDecorator.DecorateDecision(proposal)
+	}
+	if c.DeliveryTracer != nil {
+		c.traceDelivery(proposal)
+	}
+	reconfig, delivered := c.deliverToApplication(proposal, signatures, commitView)
+	if !delivered {
+		return reconfig
+	}
+	if reconfig.InLatestDecision && c.staleReconfig(reconfig) {
+		c.Logger.Warnf("Reconfiguration to nodes %v is based on nodes %v but the current nodes are %v, not applying it",
+			reconfig.CurrentNodes, reconfig.BasedOnNodes, c.nodes)
+		reconfig = types.Reconfig{}
+	}
+	c.decisionDelivered(types.Decision{Proposal: proposal, Signatures: signatures, CommitView: commitView})
+	if c.decisions != nil {
+		select {
+		case c.decisions <- types.Decision{Proposal: proposal, Signatures: signatures, CommitView: commitView}:
+		case <-c.stopChan:
+		}
+	}
 	if reconfig.InLatestDecision {
 		c.Logger.Debugf("Detected a reconfig in deliver")
 		c.reconfigChan <- reconfig
@@ -81,10 +301,245 @@ func (c *Consensus) Deliver(proposal types.Proposal, signatures []types.Signatur
 	return reconfig
 }
 
+// staleReconfig returns whether the given reconfiguration was made for a set of nodes other than the current one.
+// The nodes are only changed by a reconfiguration, which stops the components that deliver decisions,
+// hence they can be read here without the consensus lock.
+func (c *Consensus) staleReconfig(reconfig types.Reconfig) bool {
+	if len(reconfig.BasedOnNodes) == 0 {
+		return false
+	}
+	basedOn := sortNodes(reconfig.BasedOnNodes)
+	if len(basedOn) != len(c.nodes) {
+		return true
+	}
+	for i := range basedOn {
+		if basedOn[i] != c.nodes[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// deliverToApplication delivers the decision to the application, using the deliverer resolved on Start.
+// When a FallibleApplication is used, it retries until the application succeeds, so that the node does not
+// advance past an undelivered decision. Once DeliveryMaxAttempts attempts failed, the node halts until consensus
+// is stopped. It returns false if consensus was stopped before the decision was delivered.
+func (c *Consensus) deliverToApplication(proposal types.Proposal, signatures []types.Signature, commitView uint64) (types.Reconfig, bool) {
+	for attempt := 1; ; attempt++ {
+		reconfig, err := c.deliverApp(proposal, signatures, commitView)
+		if err == nil {
+			return reconfig, true
+		}
+		if c.Config.DeliveryMaxAttempts != 0 && uint64(attempt) >= c.Config.DeliveryMaxAttempts {
+			c.halt(errors.Wrapf(err, "failed delivering a decision %d times", attempt))
+			return types.Reconfig{}, false
+		}
+		c.Logger.Warnf("Attempt %d to deliver a decision failed, retrying in %v: %v", attempt, c.Config.DeliveryRetryInterval, err)
+		select {
+		case <-time.After(c.Config.DeliveryRetryInterval):
+		case <-c.stoppingChan:
+			return types.Reconfig{}, false
+		}
+	}
+}
+
+// applicationDeliverer delivers a decision to the application, along with the view in which it was committed.
+// It fails only if a FallibleApplication fails.
+type applicationDeliverer func(proposal types.Proposal, signatures []types.Signature, commitView uint64) (types.Reconfig, error)
+
+// newApplicationDeliverer returns the applicationDeliverer of the FallibleApplication if it is set, and otherwise
+// of the Application. The commit view is passed on only to an application that records it, by implementing
+// ViewRecordingApplication or FallibleViewRecordingApplication.
+func (c *Consensus) newApplicationDeliverer() applicationDeliverer {
+	if c.FallibleApplication != nil {
+		if app, ok := c.FallibleApplication.(bft.FallibleViewRecordingApplication); ok {
+			return app.TryDeliverInView
+		}
+		app := c.FallibleApplication
+		return func(proposal types.Proposal, signatures []types.Signature, _ uint64) (types.Reconfig, error) {
+			return app.TryDeliver(proposal, signatures)
+		}
+	}
+	if app, ok := c.Application.(bft.ViewRecordingApplication); ok {
+		return func(proposal types.Proposal, signatures []types.Signature, commitView uint64) (types.Reconfig, error) {
+			return app.DeliverInView(proposal, signatures, commitView), nil
+		}
+	}
+	app := c.Application
+	return func(proposal types.Proposal, signatures []types.Signature, _ uint64) (types.Reconfig, error) {
+		return app.Deliver(proposal, signatures), nil
+	}
+}
+
+// halt notifies the HaltObserver that the node halted due to the given error, and blocks until consensus is stopped.
+func (c *Consensus) halt(err error) {
+	c.Logger.Errorf("Halting: %v", err)
+	if c.HaltObserver != nil {
+		c.HaltObserver.OnHalt(err)
+	}
+	<-c.stoppingChan
+}
+
+// Decisions returns a channel that streams the decisions delivered to the application, in the order
+// of their sequences, right after the Deliver callback returns for each of them. Decisions that the application
+// obtains by itself when it is asked to synchronize are not streamed. The channel is buffered with
+// DecisionsBufferSize entries, and once it is full, deliveries block until the stream is consumed.
+// It returns nil if DecisionsBufferSize is zero or if Consensus wasn't started.
+func (c *Consensus) Decisions() <-chan types.Decision {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	return c.decisions
+}
+
+func (c *Consensus) traceDelivery(proposal types.Proposal) {
+	var traceIDs []string
+	for _, info := range c.Verifier.RequestsFromProposal(proposal) {
+		if info.TraceID != "" {
+			traceIDs = append(traceIDs, info.TraceID)
+		}
+	}
+	c.DeliveryTracer.OnDeliverTraces(proposal, traceIDs)
+}
+
+// WaitForSequence blocks until the node has delivered (or synced) the decision with the given sequence
+// or a later one, and returns the error of the context if it is done before that.
+func (c *Consensus) WaitForSequence(ctx context.Context, seq uint64) error {
+	for {
+		c.deliveredLock.Lock()
+		latest, changed := c.deliveredSeq, c.deliveredChangedChan()
+		c.deliveredLock.Unlock()
+		if latest >= seq {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Decommission gracefully removes this node from the cluster. If this node is the leader, it first complains
+// about itself until another node leads. It then waits until it delivered all the decisions before the one it
+// is working on, and submits the request of the RemovalRequester that removes it from the current set of nodes.
+// It returns once the removal is delivered and the node shut down, or returns the error of the context if it is
+// done before that. The remaining nodes continue with the current configuration.
+func (c *Consensus) Decommission(ctx context.Context) error {
+	if c.RemovalRequester == nil {
+		return errors.Errorf("RemovalRequester is not set")
+	}
+	if atomic.LoadUint64(&c.running) == 0 {
+		return errors.Errorf("consensus is not running")
+	}
+
+	ticker := time.NewTicker(decommissionPollInterval)
+	defer ticker.Stop()
+
+	for leader := c.GetLeaderID(); leader == c.Config.SelfID || leader == 0; leader = c.GetLeaderID() {
+		if leader == c.Config.SelfID {
+			c.Logger.Infof("Decommissioning the leader of view %d, abdicating", c.CurrentView())
+			c.Complain(c.CurrentView(), true)
+		}
+		select {
+		case <-ticker.C:
+		case <-c.stopChan:
+			return errors.Errorf("consensus stopped while decommissioning")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if seq := c.CurrentProposalSequence(); seq > 0 {
+		if err := c.WaitForSequence(ctx, seq-1); err != nil {
+			return err
+		}
+	}
+
+	nodes, config := c.Membership()
+	var remaining []uint64
+	for _, n := range nodes {
+		if n != c.Config.SelfID {
+			remaining = append(remaining, n)
+		}
+	}
+	c.Logger.Infof("Decommissioning node %d, leaving nodes %v", c.Config.SelfID, remaining)
+	if err := c.SubmitRequest(c.RemovalRequester.RemovalRequest(c.Config.SelfID, remaining, config)); err != nil {
+		return errors.Wrap(err, "submitting the removal request")
+	}
+
+	select {
+	case <-c.stopChan:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if atomic.LoadUint64(&c.evicted) == 0 {
+		return errors.Errorf("consensus stopped while decommissioning")
+	}
+	return nil
+}
+
+// decisionDelivered tracks the sequence of the given decision as the latest delivered one, if it is later,
+// and retains the decision in the decision history
+func (c *Consensus) decisionDelivered(decision types.Decision) {
+	if len(decision.Proposal.Metadata) == 0 {
+		return
+	}
+	md := &protos.ViewMetadata{}
+	if err := proto.Unmarshal(decision.Proposal.Metadata, md); err != nil {
+		c.Logger.Warnf("Failed unmarshaling the metadata of a delivered proposal: %v", err)
+		return
+	}
+	c.setDeliveredSequence(md.LatestSequence)
+	c.decisionHistory.Record(md.LatestSequence, decision)
+}
+
+// GetDecision returns the delivered decision with the given sequence, and false if it is no longer retained,
+// or if DecisionHistorySize is zero.
+func (c *Consensus) GetDecision(seq uint64) (*types.Decision, bool) {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.decisionHistory == nil {
+		return nil, false
+	}
+	return c.decisionHistory.Get(seq)
+}
+
+func (c *Consensus) setDeliveredSequence(seq uint64) {
+	c.deliveredLock.Lock()
+	defer c.deliveredLock.Unlock()
+	if seq <= c.deliveredSeq {
+		return
+	}
+	c.deliveredSeq = seq
+	close(c.deliveredChangedChan())
+	c.deliveredChanged = nil
+}
+
+// deliveredChangedChan returns a channel that is closed when the latest delivered sequence changes.
+// It is called while holding the deliveredLock.
+func (c *Consensus) deliveredChangedChan() chan struct{} {
+	if c.deliveredChanged == nil {
+		c.deliveredChanged = make(chan struct{})
+	}
+	return c.deliveredChanged
+}
+
 func (c *Consensus) Sync() types.SyncResponse {
+	return c.SyncWithContext(context.Background())
+}
+
+// SyncWithContext syncs like Sync, but using the CancellableSync if it is set, in which case
+// the sync stops once the given context is cancelled.
+func (c *Consensus) SyncWithContext(ctx context.Context) types.SyncResponse {
 	begin := time.Now()
-	syncResponse := c.Synchronizer.Sync()
+	var syncResponse types.SyncResponse
+	if c.CancellableSync != nil {
+		syncResponse = c.CancellableSync.SyncWithContext(ctx)
+	} else {
+		syncResponse = c.Synchronizer.Sync()
+	}
 	c.Metrics.MetricsConsensus.LatencySync.Observe(time.Since(begin).Seconds())
+	c.decisionDelivered(syncResponse.Latest)
 	if syncResponse.Reconfig.InReplicatedDecisions {
 		c.Logger.Debugf("Detected a reconfig in sync")
 		c.reconfigChan <- types.Reconfig{
@@ -104,53 +559,358 @@ func (c *Consensus) GetLeaderID() uint64 {
 	return c.controller.GetLeaderID()
 }
 
+// CurrentView returns the view this node is in, or zero if Consensus is not running
+func (c *Consensus) CurrentView() uint64 {
+	if atomic.LoadUint64(&c.running) == 0 {
+		return 0
+	}
+	return c.controller.CurrentView()
+}
+
+// LeaderForView returns the leader the given view was started with, and false if it isn't known.
+// Only the leaders of the most recent views this node took part in are remembered.
+func (c *Consensus) LeaderForView(view uint64) (uint64, bool) {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.controller == nil {
+		return 0, false
+	}
+	return c.controller.LeaderForView(view)
+}
+
+// ExpectedLeader returns the leader the given future view is started with, or zero if Consensus is not running.
+// It accounts for the current nodes and blacklist, hence it may change if they change before the view is started.
+func (c *Consensus) ExpectedLeader(view uint64) uint64 {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if atomic.LoadUint64(&c.running) == 0 {
+		return 0
+	}
+	return c.controller.ExpectedLeader(view)
+}
+
+// ViewChangeProgress returns how many of the messages needed to complete the active view change were collected,
+// and zero for both when there is no active view change.
+func (c *Consensus) ViewChangeProgress() (collected int, needed int) {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.viewChanger == nil {
+		return 0, 0
+	}
+	return c.viewChanger.ViewChangeProgress()
+}
+
+// HoldsLeaderToken returns whether this node currently holds the leader token, which entitles it to propose.
+// A leader holds it while it waits for requests and assembles a proposal, and acquires it again once its proposal
+// is decided. It returns false if Consensus is not running.
+func (c *Consensus) HoldsLeaderToken() bool {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.controller == nil {
+		return false
+	}
+	return c.controller.HoldsLeaderToken()
+}
+
+// CollectedViewData returns the IDs of the nodes whose view data messages this node collected during the active
+// view change, when it is the leader of the next view. Along with ViewChangeProgress, it names the nodes
+// a view change that does not complete is waiting for.
+func (c *Consensus) CollectedViewData() []uint64 {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.viewChanger == nil {
+		return nil
+	}
+	return c.viewChanger.CollectedViewData()
+}
+
+// QuorumReachable returns false if this node is the leader and it does not observe activity from a quorum of nodes,
+// in which case it does not propose until the quorum is reachable again. It requires DetectQuorumLoss to be set.
+func (c *Consensus) QuorumReachable() bool {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.controller == nil {
+		return true
+	}
+	return c.controller.QuorumReachable()
+}
+
+// UnreachablePeers returns the IDs of the nodes this node considers unreachable, which are the nodes that it did not
+// observe activity from within the heartbeat timeout, if DetectQuorumLoss is set, and the nodes that sending to timed out,
+// if BroadcastSendTimeout is set, or that messages to were dropped, until a send to them completes.
+// The nodes are identified by the IDs the Comm addresses them by.
+func (c *Consensus) UnreachablePeers() []uint64 {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.controller == nil {
+		return nil
+	}
+	unreachable := c.controller.UnreachablePeers()
+	if c.NodeIDMapper != nil {
+		for i, node := range unreachable {
+			unreachable[i] = c.NodeIDMapper.ClientFacingID(node)
+		}
+	}
+	return unreachable
+}
+
+// LatencyStats returns the statistics of the time it took the most recent requests submitted to this node,
+// or forwarded to it, to be delivered since they were first submitted to it.
+func (c *Consensus) LatencyStats() types.LatencyStats {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.Pool == nil {
+		return types.LatencyStats{}
+	}
+	return c.Pool.LatencyStats()
+}
+
+// PooledRequests returns the requests with the given info that are in the request pool of this node,
+// so that they can be fetched by the other nodes using a RequestFetcher.
+func (c *Consensus) PooledRequests(requests []types.RequestInfo) [][]byte {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.Pool == nil {
+		return nil
+	}
+	var pooled [][]byte
+	for _, info := range requests {
+		if req, exists := c.Pool.Request(info); exists {
+			pooled = append(pooled, req)
+		}
+	}
+	return pooled
+}
+
+// Voting returns whether this node votes. A node that starts with a JoinDryRunDecisions configuration
+// only follows and verifies the decisions of the other nodes, and votes once it verified enough consecutive decisions.
+func (c *Consensus) Voting() bool {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.controller == nil {
+		return false
+	}
+	return c.controller.Voting()
+}
+
+// CurrentProposalSequence returns the sequence this node is currently working on committing,
+// or zero if Consensus is not running. Unlike the latest delivered sequence, it reflects the work in progress.
+func (c *Consensus) CurrentProposalSequence() uint64 {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.controller == nil {
+		return 0
+	}
+	return c.controller.CurrentProposalSequence()
+}
+
+// WALWriteLatency returns how long the most recent write to the write ahead log took.
+// The latency of all writes is also reported by the LatencyWALWrite metric.
+func (c *Consensus) WALWriteLatency() time.Duration {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.state == nil {
+		return 0
+	}
+	return c.state.LastWriteLatency()
+}
+
+// Membership returns the current set of nodes and the configuration, as of the latest applied reconfiguration
+func (c *Consensus) Membership() ([]uint64, types.Configuration) {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	nodes := make([]uint64, len(c.nodes))
+	copy(nodes, c.nodes)
+	return nodes, c.Config
+}
+
+// DumpWAL returns a summary of the entries currently in the write ahead log of this node, in their order,
+// for diagnosing a node that does not make progress. The summaries do not include the content of proposals.
+// It returns nil if Consensus wasn't started.
+func (c *Consensus) DumpWAL() []types.WALEntrySummary {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.state == nil {
+		return nil
+	}
+	return c.state.WALSummary()
+}
+
+// ExportSnapshot returns the committed state of this node: its latest checkpoint and the content of its write ahead log.
+// The snapshot can be imported by ImportSnapshot into a fresh node, which then starts from the checkpoint.
+func (c *Consensus) ExportSnapshot() ([]byte, error) {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.checkpoint == nil || c.state == nil {
+		return nil, errors.New("consensus is not started")
+	}
+	proposal, signatures := c.checkpoint.Get()
+	snapshot := &algorithm.Snapshot{
+		NodeID:     c.Config.SelfID,
+		Proposal:   proposal,
+		Signatures: signatures,
+		WAL:        c.state.WALContent(),
+	}
+	return snapshot.Marshal()
+}
+
+// ImportSnapshot sets the last decision and metadata of this node from a snapshot exported by ExportSnapshot.
+// If the snapshot was exported by a node with the same ID, the write ahead log content is imported as well,
+// otherwise it is ignored as it holds the votes of another node.
+// It must be called before Start.
+func (c *Consensus) ImportSnapshot(bytes []byte) error {
+	if atomic.LoadUint64(&c.running) == 1 {
+		return errors.New("cannot import a snapshot into a running consensus")
+	}
+	snapshot, err := algorithm.UnmarshalSnapshot(bytes)
+	if err != nil {
+		return errors.Wrap(err, "failed importing snapshot")
+	}
+
+	md := &protos.ViewMetadata{}
+	if err := proto.Unmarshal(snapshot.Proposal.Metadata, md); err != nil {
+		return errors.Wrap(err, "failed unmarshaling snapshot metadata")
+	}
+
+	signatures := make([]types.Signature, 0, len(snapshot.Signatures))
+	for _, sig := range snapshot.Signatures {
+		signatures = append(signatures, types.Signature{ID: sig.Signer, Value: sig.Value, Msg: sig.Msg})
+	}
+
+	if snapshot.NodeID == c.Config.SelfID {
+		for i, entry := range snapshot.WAL {
+			if err := c.WAL.Append(entry, i == 0); err != nil {
+				return errors.Wrap(err, "failed importing snapshot write ahead log")
+			}
+		}
+		c.WALInitialContent = snapshot.WAL
+	}
+
+	c.LastProposal = types.Proposal{
+		Header:               snapshot.Proposal.Header,
+		Payload:              snapshot.Proposal.Payload,
+		Metadata:             snapshot.Proposal.Metadata,
+		VerificationSequence: int64(snapshot.Proposal.VerificationSequence),
+	}
+	c.LastSignatures = signatures
+	c.Metadata = md
+
+	return nil
+}
+
 func (c *Consensus) Start() error {
-	if err := c.ValidateConfiguration(c.Comm.Nodes()); err != nil {
+	if err := c.ValidateDependencies(); err != nil {
+		return errors.Wrapf(err, "dependencies are invalid")
+	}
+
+	c.comm = c.Comm
+	c.fetcher = c.RequestFetcher
+	c.deliverApp = c.newApplicationDeliverer()
+	if c.NodeIDMapper != nil {
+		c.comm = &idMappingComm{Comm: c.Comm, mapper: c.NodeIDMapper}
+		if c.RequestFetcher != nil {
+			c.fetcher = &idMappingFetcher{RequestFetcher: c.RequestFetcher, mapper: c.NodeIDMapper}
+		}
+	}
+
+	c.verifier = c.Verifier
+	c.clientSigVerifier = c.ClientSigVerifier
+	limiter := c.verificationLimiter
+	if limiter == nil && c.Config.VerificationConcurrency > 0 {
+		limiter = algorithm.NewVerificationLimiter(c.Config.VerificationConcurrency)
+	}
+	if limiter != nil {
+		c.verifier = limiter.Verifier(c.Verifier)
+		if c.ClientSigVerifier != nil {
+			c.clientSigVerifier = limiter.ClientSignatureVerifier(c.ClientSigVerifier)
+		}
+	}
+
+	if err := c.ValidateConfiguration(c.comm.Nodes()); err != nil {
 		return errors.Wrapf(err, "configuration is invalid")
 	}
 
+	if c.Config.VerifyLastDecisionOnStart {
+		if err := algorithm.ValidateDecision(c.LastProposal, c.LastSignatures, c.comm.Nodes(), c.Verifier); err != nil {
+			return errors.Wrapf(err, "last decision is invalid")
+		}
+	}
+
 	if c.Metrics == nil {
 		c.Metrics = bft.NewMetrics(&disabled.Provider{})
 	}
 
+	if c.Metadata == nil {
+		c.Metadata = &protos.ViewMetadata{}
+	}
+
 	c.consensusDone.Add(1)
 	c.stopOnce = sync.Once{}
 	c.stopChan = make(chan struct{})
+	c.stoppingOnce = sync.Once{}
+	c.stoppingChan = make(chan struct{})
 	c.reconfigChan = make(chan types.Reconfig)
+	atomic.StoreUint64(&c.evicted, 0)
 	c.consensusLock.Lock()
 	defer c.consensusLock.Unlock()
 
-	c.setNodes(c.Comm.Nodes())
+	c.setNodes(c.comm.Nodes())
 	c.Metrics.Initialize(c.nodes)
 
 	c.inFlight = &algorithm.InFlightData{}
+	if c.Config.VerifyInFlightPrepares {
+		c.inFlight.Verifier = c.verifier
+	}
 
 	c.state = &algorithm.PersistedState{
 		InFlightProposal: c.inFlight,
 		Entries:          c.WALInitialContent,
 		Logger:           c.Logger,
 		WAL:              c.WAL,
+		Metrics:          c.Metrics.MetricsConsensus,
 	}
 
 	c.checkpoint = &types.Checkpoint{}
 	c.checkpoint.Set(c.LastProposal, c.LastSignatures)
 
+	c.leaderHistory = &algorithm.LeaderHistory{Size: leaderHistorySize}
+	c.decisionHistory = &algorithm.DecisionHistory{Size: int(c.Config.DecisionHistorySize)}
+	c.dryRun = &algorithm.DryRun{Decisions: c.Config.JoinDryRunDecisions}
+
+	if c.Config.DecisionsBufferSize > 0 && c.decisions == nil {
+		c.decisions = make(chan types.Decision, c.Config.DecisionsBufferSize)
+	}
+
 	c.createComponents()
+	// Only the first proposal after the node starts is delayed, and not the one after a reconfiguration
+	c.controller.FirstProposalDelay = c.Config.FirstProposalDelay
 	opts := algorithm.PoolOptions{
-		QueueSize:         int64(c.Config.RequestPoolSize),
-		ForwardTimeout:    c.Config.RequestForwardTimeout,
-		ComplainTimeout:   c.Config.RequestComplainTimeout,
-		AutoRemoveTimeout: c.Config.RequestAutoRemoveTimeout,
-		RequestMaxBytes:   c.Config.RequestMaxBytes,
-		SubmitTimeout:     c.Config.RequestPoolSubmitTimeout,
-		Metrics:           c.Metrics.MetricsRequestPool,
+		QueueSize:            int64(c.Config.RequestPoolSize),
+		ForwardTimeout:       c.Config.RequestForwardTimeout,
+		ComplainTimeout:      c.Config.RequestComplainTimeout,
+		AutoRemoveTimeout:    c.Config.RequestAutoRemoveTimeout,
+		RequestMaxBytes:      c.Config.RequestMaxBytes,
+		MaxBytesPerType:      c.Config.RequestMaxBytesPerType,
+		SubmitTimeout:        c.Config.RequestPoolSubmitTimeout,
+		MaxPoolBytes:         c.Config.RequestPoolMaxBytes,
+		SortBatch:            c.Config.SortBatchRequests,
+		ProcessedCacheSize:   int(c.Config.ProcessedRequestsCacheSize),
+		ProcessedCacheMaxAge: c.Config.ProcessedRequestsCacheMaxAge,
+		MaxBatchCount:        int(c.Config.RequestPoolMaxBatchCount),
+		Metrics:              c.Metrics.MetricsRequestPool,
+		BoundaryInspector:    c.BoundaryInspector,
+		TypeInspector:        c.TypeInspector,
+	}
+	if c.Config.CutBatchOnVerificationChange {
+		opts.VerificationSequence = c.Verifier.VerificationSequence
 	}
 	c.submittedChan = make(chan struct{}, 1)
 	c.Pool = algorithm.NewPool(c.Logger, c.RequestInspector, c.controller, opts, c.submittedChan)
 	c.continueCreateComponents()
 
 	c.Logger.Debugf("Application started with view %d, seq %d, and decisions %d", c.Metadata.ViewId, c.Metadata.LatestSequence, c.Metadata.DecisionsInView)
-	view, seq, dec := c.setViewAndSeq(c.Metadata.ViewId, c.Metadata.LatestSequence, c.Metadata.DecisionsInView)
+	c.setDeliveredSequence(c.Metadata.LatestSequence)
+	view, seq, dec := c.setViewAndSeq(c.startViewNumber(), c.Metadata.LatestSequence, c.Metadata.DecisionsInView)
 
 	c.waitForEachOther()
 
@@ -202,13 +962,16 @@ func (c *Consensus) reconfig(reconfig types.Reconfig) {
 
 	if !exist {
 		c.Logger.Infof("Evicted in reconfiguration, shutting down")
+		atomic.StoreUint64(&c.evicted, 1)
 		c.close()
 		return
 	}
 
+	prevConfig := c.Config
 	c.Config = reconfig.CurrentConfig
 	if err := c.ValidateConfiguration(reconfig.CurrentNodes); err != nil {
 		if strings.Contains(err.Error(), "nodes does not contain the SelfID") {
+			atomic.StoreUint64(&c.evicted, 1)
 			c.close()
 			c.Logger.Infof("Closing consensus since this node is not in the current set of nodes")
 			return
@@ -222,11 +985,20 @@ func (c *Consensus) reconfig(reconfig types.Reconfig) {
 
 	c.createComponents()
 	opts := algorithm.PoolOptions{
-		ForwardTimeout:    c.Config.RequestForwardTimeout,
-		ComplainTimeout:   c.Config.RequestComplainTimeout,
-		AutoRemoveTimeout: c.Config.RequestAutoRemoveTimeout,
-		RequestMaxBytes:   c.Config.RequestMaxBytes,
-		SubmitTimeout:     c.Config.RequestPoolSubmitTimeout,
+		ForwardTimeout:       c.Config.RequestForwardTimeout,
+		ComplainTimeout:      c.Config.RequestComplainTimeout,
+		AutoRemoveTimeout:    c.Config.RequestAutoRemoveTimeout,
+		RequestMaxBytes:      c.Config.RequestMaxBytes,
+		MaxBytesPerType:      c.Config.RequestMaxBytesPerType,
+		SubmitTimeout:        c.Config.RequestPoolSubmitTimeout,
+		MaxPoolBytes:         c.Config.RequestPoolMaxBytes,
+		SortBatch:            c.Config.SortBatchRequests,
+		ProcessedCacheSize:   int(c.Config.ProcessedRequestsCacheSize),
+		ProcessedCacheMaxAge: c.Config.ProcessedRequestsCacheMaxAge,
+		MaxBatchCount:        int(c.Config.RequestPoolMaxBatchCount),
+	}
+	if c.Config.CutBatchOnVerificationChange {
+		opts.VerificationSequence = c.Verifier.VerificationSequence
 	}
 	c.Pool.ChangeOptions(c.controller, opts) // TODO handle reconfiguration of queue size in the pool
 	c.continueCreateComponents()
@@ -238,7 +1010,17 @@ func (c *Consensus) reconfig(reconfig types.Reconfig) {
 	}
 	c.Logger.Debugf("Checkpoint with view %d and seq %d", md.ViewId, md.LatestSequence)
 
+	newView := c.Config.NewViewOnLeaderRemoval && len(proposal.Metadata) > 0 &&
+		!containsNode(c.nodes, algorithm.ProposalLeader(md, old, prevConfig.LeaderRotation, prevConfig.DecisionsPerLeader))
+	if newView {
+		c.Logger.Infof("The reconfiguration removed the leader of view %d, starting view %d", md.ViewId, md.ViewId+1)
+		md.ViewId++
+	}
+
 	view, seq, dec := c.setViewAndSeq(md.ViewId, md.LatestSequence, md.DecisionsInView)
+	if newView && view == md.ViewId {
+		dec = 0
+	}
 
 	c.waitForEachOther()
 
@@ -251,6 +1033,31 @@ func (c *Consensus) reconfig(reconfig types.Reconfig) {
 	c.Logger.Debugf("Reconfig is done")
 }
 
+// duplicateIDHalter halts the consensus once another node is detected to use the ID of this node
+type duplicateIDHalter struct {
+	consensus *Consensus
+}
+
+func (h duplicateIDHalter) OnDuplicateID() {
+	c := h.consensus
+	err := errors.Errorf("another node uses the ID %d of this node", c.Config.SelfID)
+	c.Logger.Errorf("Halting: %v", err)
+	if c.HaltObserver != nil {
+		c.HaltObserver.OnHalt(err)
+	}
+	c.close()
+}
+
+// proposedRequestsMarker marks the requests of accepted proposals in the request pool,
+// which is created after the proposal maker
+type proposedRequestsMarker struct {
+	consensus *Consensus
+}
+
+func (m proposedRequestsMarker) MarkProposed(requests []types.RequestInfo) {
+	m.consensus.Pool.MarkProposed(requests)
+}
+
 func (c *Consensus) initMetricsBlacklistReconfigure(old []uint64) {
 	var newNodes []uint64
 
@@ -280,6 +1087,7 @@ func (c *Consensus) close() {
 }
 
 func (c *Consensus) Stop() {
+	c.stoppingOnce.Do(func() { close(c.stoppingChan) })
 	c.consensusLock.RLock()
 	c.viewChanger.Stop()
 	c.controller.Stop()
@@ -290,6 +1098,9 @@ func (c *Consensus) Stop() {
 }
 
 func (c *Consensus) HandleMessage(sender uint64, m *protos.Message) {
+	if c.NodeIDMapper != nil {
+		sender = c.NodeIDMapper.InternalID(sender)
+	}
 	if _, exists := c.nodeMap.Load(sender); !exists {
 		c.Logger.Warnf("Received message from unexpected node %d", sender)
 		return
@@ -300,11 +1111,16 @@ func (c *Consensus) HandleMessage(sender uint64, m *protos.Message) {
 }
 
 func (c *Consensus) HandleRequest(sender uint64, req []byte) {
+	if c.NodeIDMapper != nil {
+		sender = c.NodeIDMapper.InternalID(sender)
+	}
 	c.consensusLock.RLock()
 	defer c.consensusLock.RUnlock()
 	c.controller.HandleRequest(sender, req)
 }
 
+// SubmitRequest submits the given request to be ordered. It may be called from multiple goroutines concurrently,
+// and a request that is submitted again while it is still pending, or after it was ordered, is rejected.
 func (c *Consensus) SubmitRequest(req []byte) error {
 	c.consensusLock.RLock()
 	defer c.consensusLock.RUnlock()
@@ -315,8 +1131,21 @@ func (c *Consensus) SubmitRequest(req []byte) error {
 	return c.controller.SubmitRequest(req)
 }
 
+// SubmitBatch submits the given requests such that they are ordered contiguously in a single batch,
+// or rejects all of them. The requests are kept together only in batches this node proposes as the leader,
+// and as long as the proposal they are assembled into does not exceed MaxProposalBytes.
+func (c *Consensus) SubmitBatch(reqs [][]byte) error {
+	c.consensusLock.RLock()
+	defer c.consensusLock.RUnlock()
+	if c.GetLeaderID() == 0 {
+		return errors.Errorf("no leader")
+	}
+	c.Logger.Debugf("Submit batch of %d requests", len(reqs))
+	return c.controller.SubmitBatch(reqs)
+}
+
 func (c *Consensus) proposalMaker() *algorithm.ProposalMaker {
-	return &algorithm.ProposalMaker{
+	pm := &algorithm.ProposalMaker{
 		DecisionsPerLeader: c.Config.DecisionsPerLeader,
 		Checkpoint:         c.checkpoint,
 		State:              c.state,
@@ -327,15 +1156,52 @@ func (c *Consensus) proposalMaker() *algorithm.ProposalMaker {
 		MetricsView:        c.Metrics.MetricsView,
 		Signer:             c.Signer,
 		MembershipNotifier: c.MembershipNotifier,
+		BatchObserver:      c.BatchObserver,
 		SelfID:             c.Config.SelfID,
 		Sync:               c.controller,
 		FailureDetector:    c,
-		Verifier:           c.Verifier,
+		Verifier:           c.verifier,
 		N:                  c.numberOfNodes,
 		NodesList:          c.nodes,
 		InMsqQSize:         int(c.Config.IncomingMessageBufferSize),
+		AggregationWindow:  c.Config.VoteAggregationWindow,
+		ResendRecovered:    c.Config.ResendRecoveredProposal,
 		ViewSequences:      c.controller.ViewSequences,
+		PrepareQuorum:      int(c.Config.PrepareQuorum),
+		CommitQuorum:       int(c.Config.CommitQuorum),
+	}
+	if c.Config.RememberProposedRequests {
+		pm.ProposedRequests = proposedRequestsMarker{consensus: c}
 	}
+	return pm
+}
+
+// ValidateDependencies checks that all the dependencies the consensus cannot run without are set,
+// and returns an error naming the first one that is missing. Start calls it before anything else.
+// Either Assembler, CandidateAssembler or FallibleAssembler is required, either Application or FallibleApplication,
+// and either Synchronizer or CancellableSync.
+func (c *Consensus) ValidateDependencies() error {
+	dependencies := []struct {
+		name    string
+		missing bool
+	}{
+		{name: "Comm", missing: c.Comm == nil},
+		{name: "WAL", missing: c.WAL == nil},
+		{name: "Signer", missing: c.Signer == nil},
+		{name: "Verifier", missing: c.Verifier == nil},
+		{name: "Assembler", missing: c.Assembler == nil && c.CandidateAssembler == nil && c.FallibleAssembler == nil},
+		{name: "Application", missing: c.Application == nil && c.FallibleApplication == nil},
+		{name: "RequestInspector", missing: c.RequestInspector == nil},
+		{name: "Synchronizer", missing: c.Synchronizer == nil && c.CancellableSync == nil},
+		{name: "Logger", missing: c.Logger == nil},
+		{name: "PayloadCompactor", missing: c.Config.ProposeRequestDigests && c.PayloadCompactor == nil},
+	}
+	for _, dependency := range dependencies {
+		if dependency.missing {
+			return errors.Errorf("%s is not set", dependency.name)
+		}
+	}
+	return nil
 }
 
 func (c *Consensus) ValidateConfiguration(nodes []uint64) error {
@@ -359,6 +1225,22 @@ func (c *Consensus) ValidateConfiguration(nodes []uint64) error {
 		return errors.Errorf("nodes contains duplicate IDs, nodes: %v", nodes)
 	}
 
+	if c.Config.PrepareQuorum != 0 {
+		if err := algorithm.ValidateQuorumOverride(uint64(len(nodes)), c.Config.PrepareQuorum); err != nil {
+			return errors.Wrap(err, "invalid PrepareQuorum")
+		}
+	}
+
+	if c.Config.CommitQuorum != 0 {
+		if err := algorithm.ValidateQuorumOverride(uint64(len(nodes)), c.Config.CommitQuorum); err != nil {
+			return errors.Wrap(err, "invalid CommitQuorum")
+		}
+	}
+
+	if c.Config.GenesisLeader != 0 && !nodeSet[c.Config.GenesisLeader] {
+		return errors.Errorf("nodes does not contain the GenesisLeader: %d, nodes: %v", c.Config.GenesisLeader, nodes)
+	}
+
 	return nil
 }
 
@@ -383,20 +1265,33 @@ func sortNodes(nodes []uint64) []uint64 {
 	return sorted
 }
 
+func containsNode(nodes []uint64, node uint64) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Consensus) createComponents() {
 	c.viewChanger = &algorithm.ViewChanger{
-		SelfID:             c.Config.SelfID,
-		N:                  c.numberOfNodes,
-		NodesList:          c.nodes,
-		LeaderRotation:     c.Config.LeaderRotation,
-		DecisionsPerLeader: c.Config.DecisionsPerLeader,
-		SpeedUpViewChange:  c.Config.SpeedUpViewChange,
-		Logger:             c.Logger,
-		Signer:             c.Signer,
-		Verifier:           c.Verifier,
-		Checkpoint:         c.checkpoint,
-		InFlight:           c.inFlight,
-		State:              c.state,
+		SelfID:              c.Config.SelfID,
+		N:                   c.numberOfNodes,
+		NodesList:           c.nodes,
+		LeaderRotation:      c.Config.LeaderRotation,
+		DecisionsPerLeader:  c.Config.DecisionsPerLeader,
+		SpeedUpViewChange:   c.Config.SpeedUpViewChange,
+		MaxViewLag:          c.Config.MaxViewLag,
+		PrepareQuorum:       int(c.Config.PrepareQuorum),
+		CommitQuorum:        int(c.Config.CommitQuorum),
+		Logger:              c.Logger,
+		Signer:              c.Signer,
+		Verifier:            c.verifier,
+		Checkpoint:          c.checkpoint,
+		InFlight:            c.inFlight,
+		State:               c.state,
+		ProposalEquivalence: c.ProposalEquivalence,
 		// Controller later
 		// RequestsTimer later
 		Ticker:            c.ViewChangerTicker,
@@ -423,23 +1318,63 @@ func (c *Consensus) createComponents() {
 		NodesList:          c.nodes,
 		LeaderRotation:     c.Config.LeaderRotation,
 		DecisionsPerLeader: c.Config.DecisionsPerLeader,
-		Verifier:           c.Verifier,
+		Verifier:           c.verifier,
 		Logger:             c.Logger,
 		Assembler:          c.Assembler,
+		CandidateAssembler: c.CandidateAssembler,
+		CandidatesMaxCount: c.Config.AssemblerCandidatesMaxCount,
+		FallibleAssembler:  c.FallibleAssembler,
+		AssembleAttempts:   c.Config.AssembleProposalMaxAttempts,
+		AssembleBackoff:    c.Config.AssembleProposalRetryBackoff,
+		AssembleMaxBackoff: c.Config.AssembleProposalMaxBackoff,
+		MaxProposalBytes:   c.Config.MaxProposalBytes,
+		ProposalInterval:   c.Config.MinProposalInterval,
+		CatchUpDelay:       c.Config.CatchUpProposalDelay,
+		MaxLeaderIdle:      c.Config.MaxLeaderIdleWithoutCommit,
+		MaxViewIdle:        c.Config.MaxViewIdleTime,
+		LogLeaderToken:     c.Config.LogLeaderToken,
+		AsyncDelivery:      c.Config.AsyncDelivery,
 		Application:        c,
 		FailureDetector:    c,
 		Synchronizer:       c,
-		Comm:               c.Comm,
+		Comm:               c.comm,
+		BroadcastWorkers:   c.Config.BroadcastConcurrency,
+		ForwardQuota:       c.Config.ForwardedRequestsQuota,
+		ForwardMaxAge:      c.Config.ForwardedRequestMaxAge,
+		ProposeDigests:     c.Config.ProposeRequestDigests,
+		PayloadCompactor:   c.PayloadCompactor,
+		RequestFetcher:     c.fetcher,
+		FetchTimeout:       c.Config.RequestFetchTimeout,
+		FutureMsgsLimit:    int(c.Config.FutureViewMessagesBufferSize),
+		SendTimeout:        c.Config.BroadcastSendTimeout,
 		Signer:             c.Signer,
 		RequestInspector:   c.RequestInspector,
+		ClientSigVerifier:  c.clientSigVerifier,
+		RequestAbandoned:   c.RequestAbandoned,
+		KeyRotator:         c.KeyRotator,
 		ViewChanger:        c.viewChanger,
 		ViewSequences:      &atomic.Value{},
 		Collector:          c.collector,
 		State:              c.state,
 		InFlight:           c.inFlight,
+		LeaderHistory:      c.leaderHistory,
+		DetectQuorumLoss:   c.Config.DetectQuorumLoss,
+		DetectDuplicateID:  c.Config.DetectDuplicateID,
+		VerifySynced:       c.Config.VerifySyncedDecision,
+		PrioritizeTimedOut: c.Config.PrioritizeTimedOutRequests,
+		ForwardToNext:      c.Config.ForwardToNextLeader,
+		PreOrder:           c.PreOrderFilter,
+		QuorumObserver:     c.QuorumObserver,
+		SuspicionObserver:  c.SuspicionObserver,
+		LeadershipObserver: c.LeadershipObserver,
+		FaultInjector:      c.FaultInjector,
+		DryRun:             c.dryRun,
 		MetricsView:        c.Metrics.MetricsView,
 	}
 	c.controller.Deliver = &algorithm.MutuallyExclusiveDeliver{C: c.controller}
+	if c.CancellableSync != nil {
+		c.controller.CancellableSync = c
+	}
 
 	c.viewChanger.Application = &algorithm.MutuallyExclusiveDeliver{C: c.controller}
 	c.viewChanger.Comm = c.controller
@@ -450,10 +1385,18 @@ func (c *Consensus) createComponents() {
 
 func (c *Consensus) continueCreateComponents() {
 	batchBuilder := algorithm.NewBatchBuilder(c.Pool, c.submittedChan, c.Config.RequestBatchMaxCount, c.Config.RequestBatchMaxBytes, c.Config.RequestBatchMaxInterval)
+	batchBuilder.ResetGracePeriod = c.Config.RequestBatchResetGracePeriod
+	batchBuilder.PreOrder = c.PreOrderFilter
 	leaderMonitor := algorithm.NewHeartbeatMonitor(c.Scheduler, c.Logger, c.Config.LeaderHeartbeatTimeout, c.Config.LeaderHeartbeatCount, c.controller, c.numberOfNodes, c.controller, c.controller.ViewSequences, c.Config.NumOfTicksBehindBeforeSyncing)
 	c.controller.RequestPool = c.Pool
 	c.controller.Batcher = batchBuilder
 	c.controller.LeaderMonitor = leaderMonitor
+	if c.Config.DetectQuorumLoss {
+		leaderMonitor.TrackQuorum(c.controller)
+	}
+	if c.Config.DetectDuplicateID {
+		leaderMonitor.DetectDuplicateID(c.Config.SelfID, duplicateIDHalter{consensus: c})
+	}
 
 	c.viewChanger.Controller = c.controller
 	c.viewChanger.Pruner = c.controller
@@ -461,6 +1404,17 @@ func (c *Consensus) continueCreateComponents() {
 	c.viewChanger.ViewSequences = c.controller.ViewSequences
 }
 
+// startViewNumber returns the view of the metadata the application started with,
+// unless no decision was made yet, in which case it is the first view led by the GenesisLeader
+func (c *Consensus) startViewNumber() uint64 {
+	if c.Config.GenesisLeader == 0 || c.Metadata.ViewId != 0 || c.Metadata.LatestSequence != 0 {
+		return c.Metadata.ViewId
+	}
+	view, _ := algorithm.GenesisView(c.Config.GenesisLeader, c.nodes, c.Config.LeaderRotation, c.Config.DecisionsPerLeader)
+	c.Logger.Infof("Starting from view %d which is led by the genesis leader %d", view, c.Config.GenesisLeader)
+	return view
+}
+
 func (c *Consensus) setViewAndSeq(view, seq, dec uint64) (newView, newSeq, newDec uint64) {
 	newView = view
 	newSeq = seq
@@ -515,8 +1469,59 @@ func (c *Consensus) startComponents(view, seq, dec uint64, configSync bool) {
 	c.collector.Start()
 	c.viewChanger.Start(view)
 	if configSync {
-		c.controller.Start(view, seq+1, dec, c.Config.SyncOnStart)
+		c.controller.Start(view, seq+1, dec, c.Config.SyncOnStart || c.joiningAtNonZeroView(view))
 	} else {
 		c.controller.Start(view, seq+1, dec, false)
 	}
+	// The controller may only move past the view the view changer starts at by syncing on start,
+	// in which case it informs the view changer of the view it synced to.
+	if controllerView := c.controller.CurrentView(); controllerView < view {
+		c.Logger.Panicf("Controller started at view %d while the view changer started at view %d", controllerView, view)
+	}
+}
+
+// joiningAtNonZeroView returns true if the node should sync on start since it starts
+// with an empty WAL from the metadata of a non-zero view
+func (c *Consensus) joiningAtNonZeroView(view uint64) bool {
+	if !c.Config.SyncOnStartAtNonZeroView || view == 0 || len(c.WALInitialContent) > 0 {
+		return false
+	}
+	c.Logger.Infof("Starting at view %d with an empty WAL, syncing to the current view of the cluster", view)
+	return true
+}
+
+// idMappingComm translates the internal node IDs used by the consensus
+// to the client-facing IDs the Comm addresses the nodes by, and vice versa.
+type idMappingComm struct {
+	bft.Comm
+	mapper bft.NodeIDMapper
+}
+
+func (ic *idMappingComm) SendConsensus(targetID uint64, m *protos.Message) {
+	ic.Comm.SendConsensus(ic.mapper.ClientFacingID(targetID), m)
+}
+
+func (ic *idMappingComm) SendTransaction(targetID uint64, request []byte) {
+	ic.Comm.SendTransaction(ic.mapper.ClientFacingID(targetID), request)
+}
+
+func (ic *idMappingComm) Nodes() []uint64 {
+	clientFacingIDs := ic.Comm.Nodes()
+	nodes := make([]uint64, 0, len(clientFacingIDs))
+	for _, id := range clientFacingIDs {
+		nodes = append(nodes, ic.mapper.InternalID(id))
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+	return nodes
+}
+
+// idMappingFetcher translates the internal node IDs used by the consensus
+// to the client-facing IDs the RequestFetcher addresses the nodes by.
+type idMappingFetcher struct {
+	bft.RequestFetcher
+	mapper bft.NodeIDMapper
+}
+
+func (ifr *idMappingFetcher) FetchRequests(targetID uint64, requests []types.RequestInfo) [][]byte {
+	return ifr.RequestFetcher.FetchRequests(ifr.mapper.ClientFacingID(targetID), requests)
 }
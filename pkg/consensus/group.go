@@ -0,0 +1,140 @@
+// Copyright IBM Corp. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package consensus
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	algorithm "github.com/hyperledger-labs/SmartBFT/internal/bft"
+	"github.com/pkg/errors"
+)
+
+// ConsensusGroup runs the Consensus instances of multiple independent channels in one process.
+// The instances share a single tick source, and a bound on the number of verifications running concurrently
+// across all of them, instead of each having its own ticker and verification limit.
+type ConsensusGroup struct {
+	lock     sync.RWMutex
+	channels map[string]*groupMember
+	ticks    <-chan time.Time
+	limiter  *algorithm.VerificationLimiter
+	stopOnce sync.Once
+	stopChan chan struct{}
+	done     sync.WaitGroup
+}
+
+// groupMember is a Consensus instance registered in a ConsensusGroup, along with the tickers it is given
+type groupMember struct {
+	consensus         *Consensus
+	scheduler         chan time.Time
+	viewChangerTicker chan time.Time
+}
+
+// NewConsensusGroup returns a ConsensusGroup that passes the ticks of the given scheduler to its instances,
+// and lets them run at most verificationConcurrency verifications at the same time, where zero means no limit.
+// The group should be stopped once its instances are stopped.
+func NewConsensusGroup(scheduler <-chan time.Time, verificationConcurrency uint64) *ConsensusGroup {
+	g := &ConsensusGroup{
+		channels: make(map[string]*groupMember),
+		ticks:    scheduler,
+		stopChan: make(chan struct{}),
+	}
+	if verificationConcurrency > 0 {
+		g.limiter = algorithm.NewVerificationLimiter(verificationConcurrency)
+	}
+	g.done.Add(1)
+	go g.run()
+	return g
+}
+
+// Register adds the given Consensus instance of the given channel to the group. It must be called before
+// the instance is started, and it replaces the Scheduler and the ViewChangerTicker of the instance with the ticks
+// of the group. If the group bounds the verifications, those of the instance are bounded by the limit of the group
+// rather than by its own VerificationConcurrency.
+func (g *ConsensusGroup) Register(channel string, c *Consensus) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if _, exists := g.channels[channel]; exists {
+		return errors.Errorf("channel %s is already registered", channel)
+	}
+	member := &groupMember{
+		consensus:         c,
+		scheduler:         make(chan time.Time, 1),
+		viewChangerTicker: make(chan time.Time, 1),
+	}
+	c.Scheduler = member.scheduler
+	c.ViewChangerTicker = member.viewChangerTicker
+	c.verificationLimiter = g.limiter
+	g.channels[channel] = member
+	return nil
+}
+
+// Unregister removes the instance of the given channel from the group, after which it is no longer given ticks.
+// It should be called once the instance is stopped.
+func (g *ConsensusGroup) Unregister(channel string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.channels, channel)
+}
+
+// Get returns the instance of the given channel, and false if no instance is registered for it.
+func (g *ConsensusGroup) Get(channel string) (*Consensus, bool) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	member, exists := g.channels[channel]
+	if !exists {
+		return nil, false
+	}
+	return member.consensus, true
+}
+
+// Channels returns the channels registered in the group, sorted.
+func (g *ConsensusGroup) Channels() []string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	channels := make([]string, 0, len(g.channels))
+	for channel := range g.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Stop stops passing ticks to the instances of the group.
+func (g *ConsensusGroup) Stop() {
+	g.stopOnce.Do(func() { close(g.stopChan) })
+	g.done.Wait()
+}
+
+func (g *ConsensusGroup) run() {
+	defer g.done.Done()
+	for {
+		select {
+		case now := <-g.ticks:
+			g.lock.RLock()
+			for _, member := range g.channels {
+				member.tick(now)
+			}
+			g.lock.RUnlock()
+		case <-g.stopChan:
+			return
+		}
+	}
+}
+
+// tick passes the given tick to the instance, and drops it if the instance did not consume the previous tick,
+// like a time.Ticker does for slow receivers.
+func (m *groupMember) tick(now time.Time) {
+	select {
+	case m.scheduler <- now:
+	default:
+	}
+	select {
+	case m.viewChangerTicker <- now:
+	default:
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright IBM Corp. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package inspector
+
+import (
+	"encoding/binary"
+	"strconv"
+
+	"github.com/hyperledger-labs/SmartBFT/pkg/types"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// LengthPrefixed is a RequestInspector of requests that start with the client ID followed by the request ID,
+// and optionally by the trace ID, each preceded by its length as a 4 byte big-endian unsigned integer.
+// The rest of the request is not inspected.
+// The fields of a request that is too short to contain them all are left empty.
+type LengthPrefixed struct {
+	// TraceID indicates whether the request ID is followed by a trace ID.
+	TraceID bool
+}
+
+// RequestID returns info about the given request.
+func (lp LengthPrefixed) RequestID(req []byte) types.RequestInfo {
+	fields := 2
+	if lp.TraceID {
+		fields = 3
+	}
+	values := make([]string, 0, fields)
+	for len(values) < fields {
+		if len(req) < 4 {
+			return types.RequestInfo{}
+		}
+		size := binary.BigEndian.Uint32(req)
+		req = req[4:]
+		if uint64(len(req)) < uint64(size) {
+			return types.RequestInfo{}
+		}
+		values = append(values, string(req[:size]))
+		req = req[size:]
+	}
+	info := types.RequestInfo{ClientID: values[0], ID: values[1]}
+	if lp.TraceID {
+		info.TraceID = values[2]
+	}
+	return info
+}
+
+// Protobuf is a RequestInspector of protobuf encoded requests, which takes the info of a request from its fields
+// at the given paths. A path lists the numbers of the fields leading from the request to a string, bytes,
+// or unsigned integer field, through the fields of embedded messages. Unsigned integers are formatted in decimal.
+// If a field occurs more than once, its last occurrence is used.
+// A field that is missing or malformed is left empty.
+type Protobuf struct {
+	ClientIDPath []protowire.Number
+	IDPath       []protowire.Number
+	// TraceIDPath is optional, and the trace ID is left empty without it.
+	TraceIDPath []protowire.Number
+}
+
+// RequestID returns info about the given request.
+func (pb Protobuf) RequestID(req []byte) types.RequestInfo {
+	return types.RequestInfo{
+		ClientID: lookupField(req, pb.ClientIDPath),
+		ID:       lookupField(req, pb.IDPath),
+		TraceID:  lookupField(req, pb.TraceIDPath),
+	}
+}
+
+// lookupField returns the value of the field at the given path of the given message, or an empty string if there is none
+func lookupField(msg []byte, path []protowire.Number) string {
+	for i, num := range path {
+		value, typ, found := lastField(msg, num)
+		if !found {
+			return ""
+		}
+		last := i == len(path)-1
+		switch {
+		case typ == protowire.BytesType && last:
+			return string(value)
+		case typ == protowire.BytesType:
+			msg = value
+		case typ == protowire.VarintType && last:
+			v, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return ""
+			}
+			return strconv.FormatUint(v, 10)
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// lastField returns the value of the last occurrence of the given field in the given message along with its type,
+// and whether it occurs in the message. The value of a length-delimited field is returned without its length.
+func lastField(msg []byte, num protowire.Number) (value []byte, typ protowire.Type, found bool) {
+	for len(msg) > 0 {
+		n, t, tagLen := protowire.ConsumeTag(msg)
+		if tagLen < 0 {
+			return nil, 0, false
+		}
+		msg = msg[tagLen:]
+		valueLen := protowire.ConsumeFieldValue(n, t, msg)
+		if valueLen < 0 {
+			return nil, 0, false
+		}
+		if n == num {
+			value, typ, found = msg[:valueLen], t, true
+			if t == protowire.BytesType {
+				value, _ = protowire.ConsumeBytes(value)
+			}
+		}
+		msg = msg[valueLen:]
+	}
+	return value, typ, found
+}
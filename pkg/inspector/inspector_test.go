@@ -0,0 +1,159 @@
+// Copyright IBM Corp. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package inspector
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger-labs/SmartBFT/pkg/api"
+	"github.com/hyperledger-labs/SmartBFT/pkg/types"
+	protos "github.com/hyperledger-labs/SmartBFT/smartbftprotos"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	_ api.RequestInspector = LengthPrefixed{}
+	_ api.RequestInspector = Protobuf{}
+)
+
+func lengthPrefixed(fields ...string) []byte {
+	var req []byte
+	for _, field := range fields {
+		req = binary.BigEndian.AppendUint32(req, uint32(len(field)))
+		req = append(req, field...)
+	}
+	return req
+}
+
+func TestLengthPrefixed(t *testing.T) {
+	for _, testCase := range []struct {
+		description string
+		inspector   LengthPrefixed
+		req         []byte
+		expected    types.RequestInfo
+	}{
+		{
+			description: "client and request IDs followed by the payload",
+			req:         append(lengthPrefixed("alice", "tx1"), []byte("payload")...),
+			expected:    types.RequestInfo{ClientID: "alice", ID: "tx1"},
+		},
+		{
+			description: "empty request ID",
+			req:         lengthPrefixed("alice", ""),
+			expected:    types.RequestInfo{ClientID: "alice"},
+		},
+		{
+			description: "trace ID",
+			inspector:   LengthPrefixed{TraceID: true},
+			req:         lengthPrefixed("alice", "tx1", "trace1"),
+			expected:    types.RequestInfo{ClientID: "alice", ID: "tx1", TraceID: "trace1"},
+		},
+		{
+			description: "missing trace ID",
+			inspector:   LengthPrefixed{TraceID: true},
+			req:         lengthPrefixed("alice", "tx1"),
+		},
+		{
+			description: "truncated request ID",
+			req:         lengthPrefixed("alice", "tx1")[:14],
+		},
+		{
+			description: "truncated length",
+			req:         lengthPrefixed("alice")[:2],
+		},
+		{
+			description: "empty request",
+		},
+	} {
+		t.Run(testCase.description, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.inspector.RequestID(testCase.req))
+		})
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	// A request with a client ID, and a header that holds the request ID, a nonce, and a trace ID
+	header := protowire.AppendTag(nil, 1, protowire.BytesType)
+	header = protowire.AppendString(header, "tx1")
+	header = protowire.AppendTag(header, 2, protowire.VarintType)
+	header = protowire.AppendVarint(header, 42)
+	header = protowire.AppendTag(header, 3, protowire.BytesType)
+	header = protowire.AppendString(header, "trace1")
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendString(req, "alice")
+	req = protowire.AppendTag(req, 2, protowire.BytesType)
+	req = protowire.AppendBytes(req, header)
+	req = protowire.AppendTag(req, 4, protowire.BytesType)
+	req = protowire.AppendBytes(req, []byte("payload"))
+
+	// A commit message, whose signer serves as the client ID and whose digest serves as the request ID
+	commit, err := proto.Marshal(&protos.Commit{
+		View:      1,
+		Seq:       2,
+		Digest:    "digest",
+		Signature: &protos.Signature{Signer: 3, Value: []byte{4}},
+	})
+	assert.NoError(t, err)
+
+	for _, testCase := range []struct {
+		description string
+		inspector   Protobuf
+		req         []byte
+		expected    types.RequestInfo
+	}{
+		{
+			description: "nested request ID",
+			inspector:   Protobuf{ClientIDPath: []protowire.Number{1}, IDPath: []protowire.Number{2, 1}},
+			req:         req,
+			expected:    types.RequestInfo{ClientID: "alice", ID: "tx1"},
+		},
+		{
+			description: "unsigned integer request ID and trace ID",
+			inspector: Protobuf{
+				ClientIDPath: []protowire.Number{1},
+				IDPath:       []protowire.Number{2, 2},
+				TraceIDPath:  []protowire.Number{2, 3},
+			},
+			req:      req,
+			expected: types.RequestInfo{ClientID: "alice", ID: "42", TraceID: "trace1"},
+		},
+		{
+			description: "generated message",
+			inspector:   Protobuf{ClientIDPath: []protowire.Number{4, 1}, IDPath: []protowire.Number{3}},
+			req:         commit,
+			expected:    types.RequestInfo{ClientID: "3", ID: "digest"},
+		},
+		{
+			description: "last occurrence",
+			inspector:   Protobuf{ClientIDPath: []protowire.Number{1}, IDPath: []protowire.Number{2, 1}},
+			req:         protowire.AppendString(protowire.AppendTag(req, 1, protowire.BytesType), "bob"),
+			expected:    types.RequestInfo{ClientID: "bob", ID: "tx1"},
+		},
+		{
+			description: "missing fields",
+			inspector:   Protobuf{ClientIDPath: []protowire.Number{5}, IDPath: []protowire.Number{2, 5}},
+			req:         req,
+		},
+		{
+			description: "path through a non message field",
+			inspector:   Protobuf{ClientIDPath: []protowire.Number{1}, IDPath: []protowire.Number{2, 2, 1}},
+			req:         req,
+			expected:    types.RequestInfo{ClientID: "alice"},
+		},
+		{
+			description: "malformed request",
+			inspector:   Protobuf{ClientIDPath: []protowire.Number{1}, IDPath: []protowire.Number{2, 1}},
+			req:         req[:len(req)-1],
+		},
+	} {
+		t.Run(testCase.description, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, testCase.inspector.RequestID(testCase.req))
+		})
+	}
+}
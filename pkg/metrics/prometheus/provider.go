@@ -0,0 +1,140 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package prometheus provides the consensus metrics using the Prometheus client.
+// It is a module of its own, so that the consensus library does not depend on the Prometheus client.
+// It requires a tagged release of the consensus library, and is tagged as pkg/metrics/prometheus/vX.Y.Z
+// once that release is tagged as vX.Y.Z.
+package prometheus
+
+import (
+	"strconv"
+
+	"github.com/hyperledger-labs/SmartBFT/pkg/api"
+	bft "github.com/hyperledger-labs/SmartBFT/pkg/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// NodeIDLabel is the label which NewMetrics attaches to all the metrics of the node
+const NodeIDLabel = "node_id"
+
+// NewMetrics returns the metrics of the consensus of the given node, registered with the given registerer,
+// and labeled with its node ID.
+func NewMetrics(registerer prom.Registerer, nodeID uint64) *api.Metrics {
+	p := &Provider{Registerer: registerer}
+	return api.NewMetrics(p, NodeIDLabel).With(NodeIDLabel, strconv.FormatUint(nodeID, 10))
+}
+
+// Provider is a metrics provider which registers the metrics it provides with a Prometheus registerer.
+// Label values are provided to With as pairs of label name and label value,
+// and labels which are not provided have the value "unknown".
+type Provider struct {
+	Registerer prom.Registerer
+}
+
+func (p *Provider) NewCounter(opts bft.CounterOpts) bft.Counter {
+	cv := prom.NewCounterVec(prom.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      opts.Name,
+		Help:      opts.Help,
+	}, opts.LabelNames)
+	return &Counter{cv: p.register(cv).(*prom.CounterVec), names: opts.LabelNames}
+}
+
+func (p *Provider) NewGauge(opts bft.GaugeOpts) bft.Gauge {
+	gv := prom.NewGaugeVec(prom.GaugeOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      opts.Name,
+		Help:      opts.Help,
+	}, opts.LabelNames)
+	return &Gauge{gv: p.register(gv).(*prom.GaugeVec), names: opts.LabelNames}
+}
+
+func (p *Provider) NewHistogram(opts bft.HistogramOpts) bft.Histogram {
+	hv := prom.NewHistogramVec(prom.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: opts.Subsystem,
+		Name:      opts.Name,
+		Help:      opts.Help,
+		Buckets:   opts.Buckets,
+	}, opts.LabelNames)
+	return &Histogram{hv: p.register(hv).(*prom.HistogramVec), names: opts.LabelNames}
+}
+
+// register registers the collector, or returns the collector already registered with the same description,
+// so metrics created again, such as after a reconfiguration, keep reporting to the same time series.
+func (p *Provider) register(c prom.Collector) prom.Collector {
+	if err := p.Registerer.Register(c); err != nil {
+		if are, ok := err.(prom.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+type Counter struct {
+	cv     *prom.CounterVec
+	names  []string
+	values []string
+}
+
+func (c *Counter) Add(delta float64) {
+	c.cv.With(makeLabels(c.names, c.values)).Add(delta)
+}
+
+func (c *Counter) With(labelValues ...string) bft.Counter {
+	return &Counter{cv: c.cv, names: c.names, values: append(append([]string(nil), c.values...), labelValues...)}
+}
+
+type Gauge struct {
+	gv     *prom.GaugeVec
+	names  []string
+	values []string
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.gv.With(makeLabels(g.names, g.values)).Add(delta)
+}
+
+func (g *Gauge) Set(value float64) {
+	g.gv.With(makeLabels(g.names, g.values)).Set(value)
+}
+
+func (g *Gauge) With(labelValues ...string) bft.Gauge {
+	return &Gauge{gv: g.gv, names: g.names, values: append(append([]string(nil), g.values...), labelValues...)}
+}
+
+type Histogram struct {
+	hv     *prom.HistogramVec
+	names  []string
+	values []string
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.hv.With(makeLabels(h.names, h.values)).Observe(value)
+}
+
+func (h *Histogram) With(labelValues ...string) bft.Histogram {
+	return &Histogram{hv: h.hv, names: h.names, values: append(append([]string(nil), h.values...), labelValues...)}
+}
+
+// makeLabels maps the label names to the values given in pairs of label name and label value.
+// Pairs of labels which are not among the names are ignored.
+func makeLabels(names []string, pairs []string) prom.Labels {
+	labels := make(prom.Labels, len(names))
+	for _, name := range names {
+		labels[name] = "unknown"
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if _, exists := labels[pairs[i]]; exists {
+			labels[pairs[i]] = pairs[i+1]
+		}
+	}
+	return labels
+}
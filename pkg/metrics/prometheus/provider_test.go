@@ -0,0 +1,95 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package prometheus_test
+
+import (
+	"strings"
+	"testing"
+
+	bft "github.com/hyperledger-labs/SmartBFT/pkg/metrics"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProviderLabels(t *testing.T) {
+	registry := prom.NewRegistry()
+	p := &prometheus.Provider{Registerer: registry}
+
+	counter := p.NewCounter(bft.CounterOpts{
+		Namespace:  "consensus",
+		Subsystem:  "smartbft",
+		Name:       "fails",
+		Help:       "Fails.",
+		LabelNames: []string{"reason", "node_id"},
+	})
+	counter.With("node_id", "1").With("reason", "a").Add(2)
+	counter.With("node_id", "1").Add(1)
+
+	expected := `
+# HELP consensus_smartbft_fails Fails.
+# TYPE consensus_smartbft_fails counter
+consensus_smartbft_fails{node_id="1",reason="a"} 2
+consensus_smartbft_fails{node_id="1",reason="unknown"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "consensus_smartbft_fails"))
+
+	// Creating the same metric again reports to the already registered one
+	gauge := p.NewGauge(bft.GaugeOpts{Namespace: "consensus", Subsystem: "smartbft", Name: "size", Help: "Size."})
+	gauge.Set(3)
+	gauge = p.NewGauge(bft.GaugeOpts{Namespace: "consensus", Subsystem: "smartbft", Name: "size", Help: "Size."})
+	gauge.Add(1)
+
+	expected = `
+# HELP consensus_smartbft_size Size.
+# TYPE consensus_smartbft_size gauge
+consensus_smartbft_size 4
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "consensus_smartbft_size"))
+}
+
+func TestNewMetricsOfSeveralNodes(t *testing.T) {
+	// All nodes register their metrics with the same registry, distinguished by their node ID
+	registry := prom.NewRegistry()
+	numberOfNodes := 4
+	for i := 1; i <= numberOfNodes; i++ {
+		m := prometheus.NewMetrics(registry, uint64(i))
+		m.MetricsRequestPool.CountOfRequestPool.Set(1)
+		m.MetricsBlacklist.CountBlackList.Set(0)
+		m.MetricsConsensus.LatencySync.Observe(1)
+		m.MetricsView.ViewNumber.Set(1)
+		m.MetricsView.CountBatchAll.Add(1)
+		m.MetricsViewChange.CurrentView.Set(1)
+	}
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	nodeIDs := make(map[string]map[string]struct{})
+	for _, family := range families {
+		nodeIDs[family.GetName()] = make(map[string]struct{})
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == prometheus.NodeIDLabel {
+					nodeIDs[family.GetName()][label.GetValue()] = struct{}{}
+				}
+			}
+		}
+	}
+
+	for _, name := range []string{
+		"consensus_smartbft_pool_count_of_elements",
+		"consensus_smartbft_blacklist_count",
+		"consensus_smartbft_consensus_latency_sync",
+		"consensus_smartbft_view_number",
+		"consensus_smartbft_view_count_batch_all",
+		"consensus_smartbft_viewchange_current_view",
+	} {
+		assert.Contains(t, nodeIDs, name)
+		assert.Len(t, nodeIDs[name], numberOfNodes, "metric %s is not reported by all nodes", name)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package prometheus_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	naive "github.com/hyperledger-labs/SmartBFT/examples/naive_chain"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics/disabled"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics/prometheus"
+	"github.com/hyperledger-labs/SmartBFT/pkg/wal"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestScrapeMetricsOfRunningNodes(t *testing.T) {
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	logger := basicLog.Sugar()
+
+	numberOfNodes := 4
+	network := make(map[int]map[int]chan proto.Message)
+	for to := 1; to <= numberOfNodes; to++ {
+		network[to] = make(map[int]chan proto.Message)
+		for from := 1; from <= numberOfNodes; from++ {
+			network[to][from] = make(chan proto.Message, 128)
+		}
+	}
+
+	// All nodes register their metrics with the same registry, distinguished by their node ID
+	registry := prom.NewRegistry()
+	opts := naive.NetworkOptions{NumNodes: numberOfNodes, BatchSize: 1, BatchTimeout: 10 * time.Second}
+	chains := make([]*naive.Chain, 0, numberOfNodes)
+	for id := 1; id <= numberOfNodes; id++ {
+		ingress := make(naive.Ingress)
+		egress := make(naive.Egress)
+		for other := 1; other <= numberOfNodes; other++ {
+			ingress[other] = network[id][other]
+			egress[other] = network[other][id]
+		}
+		walMetrics := wal.NewMetrics(&disabled.Provider{}, "label1")
+		chain := naive.NewChain(uint64(id), ingress, egress, logger, walMetrics, prometheus.NewMetrics(registry, uint64(id)), opts, testDir)
+		defer chain.Stop()
+		chains = append(chains, chain)
+	}
+
+	assert.NoError(t, chains[0].Order(naive.Transaction{ClientID: "alice", ID: "tx1"}))
+	for _, chain := range chains {
+		block := chain.Listen()
+		assert.Equal(t, uint64(1), block.Sequence)
+	}
+
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	scrape := func() string {
+		resp, err := http.Get(server.URL)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return string(body)
+	}
+
+	for id := 1; id <= numberOfNodes; id++ {
+		for _, sample := range []string{
+			fmt.Sprintf(`consensus_smartbft_view_count_batch_all{node_id="%d"} 1`, id),
+			fmt.Sprintf(`consensus_smartbft_consensus_latency_sync_count{node_id="%d"}`, id),
+			fmt.Sprintf(`consensus_smartbft_pool_count_of_elements{node_id="%d"}`, id),
+			fmt.Sprintf(`consensus_smartbft_blacklist_count{node_id="%d"}`, id),
+			fmt.Sprintf(`consensus_smartbft_viewchange_current_view{node_id="%d"} 0`, id),
+		} {
+			assert.Eventually(t, func() bool {
+				return strings.Contains(scrape(), sample)
+			}, 10*time.Second, 100*time.Millisecond, "scraped metrics lack %s", sample)
+		}
+	}
+}
@@ -27,12 +27,148 @@ type Configuration struct {
 	// first created (i.e. the time the first request was added to it), or until it is of count RequestBatchMaxCount,
 	// or total size RequestBatchMaxBytes, which ever happens first.
 	RequestBatchMaxInterval time.Duration
+	// RequestBatchResetGracePeriod is the interval after the batcher of the leader is closed by a view change
+	// (or a sync), within which the next batch keeps counting RequestBatchMaxInterval from when the interrupted batch
+	// started, instead of counting it anew, so that closely spaced view changes do not keep postponing the proposal.
+	// The batcher is still closed and reset on every view change, and the pending requests remain in the request pool
+	// either way. Zero, the default, counts the interval anew after every close.
+	RequestBatchResetGracePeriod time.Duration
+	// FirstProposalDelay is the interval after the node starts during which it does not propose even if it leads,
+	// so that the application has time to get ready. Later proposals are not delayed. Zero disables this.
+	FirstProposalDelay time.Duration
+	// MinProposalInterval is the minimal interval between consecutive proposals of the leader, so that a leader
+	// with a full pool does not propose faster than the followers can verify. Requests keep accumulating
+	// in the pool meanwhile, and are proposed in larger batches. Zero disables this.
+	MinProposalInterval time.Duration
+	// CatchUpProposalDelay is the interval after the node catches up with the other nodes by synchronizing
+	// during which it does not propose even if it leads, so that a node which recovers from a partition
+	// settles before it resumes leading. Zero disables this.
+	CatchUpProposalDelay time.Duration
+	// MaxLeaderIdleWithoutCommit is the maximal time the leader waits for its proposal to be committed,
+	// before it gives up leading and complains about itself, so that another node can try to lead
+	// when the leader cannot reach a quorum. Zero disables this.
+	MaxLeaderIdleWithoutCommit time.Duration
+	// MaxViewIdleTime is the maximal time a view may go without deciding while requests are pending in the pool,
+	// after which the node complains about the leader and forces a view change. This guards against a leader
+	// that keeps the view alive with heartbeats but never proposes. Zero disables this,
+	// and otherwise it must be at least 10ms.
+	MaxViewIdleTime time.Duration
+	// LogLeaderToken makes the node log whenever it acquires or releases the leader token, which entitles
+	// the leader to propose, for debugging proposals that stall.
+	LogLeaderToken bool
+	// AssemblerCandidatesMaxCount is the maximal number of pending requests handed to a CandidateAssembler,
+	// which selects out of them the requests of the next proposal. A value of zero means that the candidates
+	// are just the requests of the next batch.
+	AssemblerCandidatesMaxCount uint64
+	// AssembleProposalMaxAttempts is the maximal number of attempts the leader makes to assemble a proposal using
+	// a FallibleAssembler, before it gives up and complains about itself. A value of zero means a single attempt.
+	AssembleProposalMaxAttempts uint64
+	// AssembleProposalRetryBackoff is the interval the leader waits after the first failed attempt to assemble
+	// a proposal, and it is doubled after every consecutive failed attempt.
+	AssembleProposalRetryBackoff time.Duration
+	// AssembleProposalMaxBackoff caps the interval the leader waits between failed attempts to assemble a proposal.
+	// A value smaller than AssembleProposalRetryBackoff, such as zero, means the interval is not doubled.
+	AssembleProposalMaxBackoff time.Duration
 
+	// PrepareQuorum overrides the number of nodes (including the node itself) whose prepares are required in order
+	// to prepare a proposal. A value of zero means the quorum computed from the number of nodes is used.
+	// An override must not be smaller than the computed quorum, in order to preserve the property that any two
+	// quorums intersect in at least f+1 nodes, and must not exceed the number of nodes.
+	PrepareQuorum uint64
+	// CommitQuorum overrides the number of nodes (including the node itself) whose commits are required in order
+	// to decide on a proposal. A value of zero means the quorum computed from the number of nodes is used.
+	// It is subject to the same constraints as PrepareQuorum. Note that a commit quorum larger than N-f means that
+	// decisions cannot be made when f nodes are faulty.
+	CommitQuorum uint64
+	// VoteAggregationWindow is the interval during which a node accumulates the commits it receives before verifying
+	// them together, so that on large clusters the burst of commits around each proposal is verified in a few batches
+	// rather than one by one. The commits of a window are verified together if the Verifier is a BatchVerifier,
+	// and otherwise concurrently. Commits that may complete the quorum are verified right away, so the decision
+	// is not delayed by the window. Nodes do not delay the votes they send, as each node sends a single prepare
+	// and a single commit per proposal, so there is nothing for it to coalesce. Zero disables this.
+	VoteAggregationWindow time.Duration
+	// ResendRecoveredProposal makes a leader that restarts in the middle of a proposal resend the proposal it restored
+	// from the WAL, so that the followers that did not receive it before the restart complete it. Otherwise,
+	// the leader only resends its vote on the proposal, and the proposal is completed after a view change.
+	// Either way, the leader never proposes a different proposal for the same sequence.
+	ResendRecoveredProposal bool
+	// VerifyInFlightPrepares is an integrity check of the prepared records in the WAL: a node verifies its own
+	// commit signature recorded alongside its in-flight proposal, both when the proposal becomes prepared
+	// and when it is restored from the WAL. A signature that fails verification is logged as an error and is not
+	// surfaced to other nodes, but the proposal is still considered prepared, since the node already voted on it.
+	VerifyInFlightPrepares bool
+	// VerificationConcurrency is the maximal number of verifications of requests, proposals, and signatures
+	// that run concurrently, across all the components of the node that verify them. Verifications beyond it wait
+	// for one of the running ones to finish, which bounds the CPU used for verification regardless of the rate
+	// of incoming messages. A value of zero means no limit.
+	VerificationConcurrency uint64
+
+	// DeliveryRetryInterval is the interval between attempts to deliver a decision using a FallibleApplication,
+	// during which the node does not advance past the decision.
+	DeliveryRetryInterval time.Duration
+	// DeliveryMaxAttempts is the number of failed attempts to deliver a decision using a FallibleApplication,
+	// after which the node halts instead of retrying, and notifies the HaltObserver.
+	// A value of zero means the delivery is retried indefinitely.
+	DeliveryMaxAttempts uint64
+	// AsyncDelivery makes a delivery worker deliver the decisions to the application in order, so that the node
+	// keeps handling view changes while the application is slow to deliver. A view still waits for its decision
+	// to be delivered before it moves to the next proposal, unless the view is aborted, and a new view is started
+	// only after the decisions of the previous views are delivered.
+	AsyncDelivery bool
 	// IncomingMessageBufferSize is the size of the buffer holding incoming messages before they are processed.
 	IncomingMessageBufferSize uint64
+	// FutureViewMessagesBufferSize is the number of consensus messages of views ahead of the current view that are
+	// retained. Once f+1 nodes sent such messages the node syncs, and the messages are passed to the view
+	// once it is reached. A value of zero disables it.
+	FutureViewMessagesBufferSize uint64
+	// DecisionsBufferSize is the size of the buffer of the channel returned by Consensus.Decisions(),
+	// which streams the delivered decisions in addition to the Deliver callback. A value of zero disables it.
+	DecisionsBufferSize uint64
+	// DecisionHistorySize is the number of most recently delivered decisions retained in memory,
+	// which can be fetched by their sequences with Consensus.GetDecision(). A value of zero disables it.
+	DecisionHistorySize uint64
+	// PausedDecisionsBufferSize is the number of decisions held back while delivery is paused by
+	// Consensus.PauseDelivery(). The node keeps ordering while delivery is paused, and once more decisions
+	// are ordered, the held back decisions are discarded and the node syncs once delivery is resumed.
+	// A value of zero means 100 decisions are held back.
+	PausedDecisionsBufferSize uint64
 	// RequestPoolSize is the number of pending requests retained by the node.
 	// The RequestPoolSize is recommended to be at least double (x2) the RequestBatchMaxCount.
 	RequestPoolSize uint64
+	// RequestPoolMaxBytes bounds the total size of the pending requests retained by the node, in bytes.
+	// A request that would exceed it is rejected, even if fewer than RequestPoolSize requests are retained.
+	// A value of zero means no limit.
+	RequestPoolMaxBytes uint64
+	// RequestPoolMaxBatchCount is a hard cap on the number of requests the request pool returns for a batch,
+	// whatever count it is asked for, and groups of more requests are rejected when they are submitted.
+	// A value of zero means there is no cap beyond RequestBatchMaxCount.
+	RequestPoolMaxBatchCount uint64
+	// ForwardedRequestsQuota is the maximal number of requests forwarded by a single node that the leader retains
+	// in its request pool at the same time. Forwarded requests beyond it are rejected. A value of zero means no limit.
+	ForwardedRequestsQuota uint64
+	// ProcessedRequestsCacheSize is the number of most recently processed requests the node remembers,
+	// in order to reject their resubmission. A value of zero means 1000 requests are remembered.
+	ProcessedRequestsCacheSize uint64
+	// ProcessedRequestsCacheMaxAge is the time a processed request is remembered for. It should cover the interval
+	// after which clients retry submitting requests. A value of zero means requests are remembered as long as
+	// they are among the ProcessedRequestsCacheSize most recently processed ones.
+	ProcessedRequestsCacheMaxAge time.Duration
+	// RememberProposedRequests makes a node remember the requests of each proposal it accepts as processed,
+	// even if they are not in its request pool, so that they are rejected rather than ordered again if they are
+	// submitted to it later on. A request of a proposal that is not committed, such as due to a view change,
+	// is then only ordered again from the request pools of the other nodes.
+	RememberProposedRequests bool
+
+	// BroadcastConcurrency is the maximal number of nodes consensus messages are concurrently sent to.
+	// If it is greater than one, or BroadcastSendTimeout is set, the messages to each node are queued and sent
+	// in order by a sender of its own, so that a slow node does not delay sending to the rest of the nodes,
+	// and messages to a node whose queue is full are dropped. A value of zero or one, without BroadcastSendTimeout,
+	// means a broadcast message is sent to the nodes one after the other.
+	BroadcastConcurrency uint64
+	// BroadcastSendTimeout is the interval after which sending a consensus message to a node gives up its turn
+	// to send, so that a stuck node does not hold back sending to the rest of the nodes, and the node is considered
+	// unreachable until the send completes. A value of zero means there is no timeout.
+	BroadcastSendTimeout time.Duration
 
 	// RequestForwardTimeout is started from the moment a request is submitted, and defines the interval after which a
 	// request is forwarded to the leader.
@@ -43,6 +179,29 @@ type Configuration struct {
 	// RequestAutoRemoveTimeout is started when RequestComplainTimeout expires, and defines the interval after which
 	// a request is removed (dropped) from the request pool.
 	RequestAutoRemoveTimeout time.Duration
+	// ForwardedRequestMaxAge is the maximal time since a forwarded request was submitted to the node that forwarded it,
+	// after which the leader drops it. When it is set, the payload a node passes to Comm.SendTransaction is not
+	// the request itself but an ASN.1 envelope of the request and its submission time, hence it must be identical
+	// on all nodes, and their clocks should be synchronized. A request submitted more than a second in the future
+	// is dropped as well. Requests forwarded without an envelope, such as by nodes that do not set it yet during
+	// a rolling upgrade, are accepted without checking their age. A value of zero disables it.
+	ForwardedRequestMaxAge time.Duration
+	// ProposeRequestDigests makes the leader send its proposals carrying the digests of their requests instead of
+	// their payload, which the followers assemble back from the requests in their request pool, fetching the requests
+	// they miss from the leader. It requires a PayloadCompactor, and it should be set on all nodes.
+	ProposeRequestDigests bool
+	// RequestFetchTimeout is the maximal time a follower waits for the requests it fetches from the leader
+	// when ProposeRequestDigests is set, after which it drops the proposal. It should be greater than zero
+	// when ProposeRequestDigests is set.
+	RequestFetchTimeout time.Duration
+	// PrioritizeTimedOutRequests is a flag indicating whether the leader moves a request to the front of its request
+	// pool when RequestForwardTimeout expires for it, so that the request is included in its next batch.
+	PrioritizeTimedOutRequests bool
+	// ForwardToNextLeader is a flag indicating whether a follower forwards a request whose RequestForwardTimeout
+	// expired not only to the leader but also to the next leader candidate, which relays it to the leader, in case
+	// the leader is unreachable from the follower. When it is set, followers accept forwarded requests,
+	// hence it should be set on all nodes.
+	ForwardToNextLeader bool
 
 	// ViewChangeResendInterval defined the interval in which the ViewChange message is resent.
 	ViewChangeResendInterval time.Duration
@@ -57,6 +216,14 @@ type Configuration struct {
 	// LeaderHeartbeatCount is the number of heartbeats per LeaderHeartbeatTimeout that the leader should emit.
 	// The heartbeat-interval is equal to: LeaderHeartbeatTimeout/LeaderHeartbeatCount.
 	LeaderHeartbeatCount uint64
+	// DetectQuorumLoss is a flag indicating whether the leader tracks if it observes activity from a quorum of
+	// nodes within the last LeaderHeartbeatTimeout, and pauses proposing when it does not. When it is set, followers
+	// respond to every heartbeat of the leader, hence it should be set on all nodes.
+	DetectQuorumLoss bool
+	// DetectDuplicateID makes a node halt once it receives a heartbeat of another node that was started with
+	// the same SelfID, rather than take part in quorums alongside it. Heartbeats are told apart by a nonce
+	// each node picks when it starts. The HaltObserver is notified once the node halts.
+	DetectDuplicateID bool
 	// NumOfTicksBehindBeforeSyncing is the number of follower ticks where the follower is behind the leader
 	// by one sequence before starting a sync
 	NumOfTicksBehindBeforeSyncing uint64
@@ -67,23 +234,66 @@ type Configuration struct {
 
 	// SyncOnStart is a flag indicating whether a sync is required on startup.
 	SyncOnStart bool
+	// SyncOnStartAtNonZeroView makes a node that starts with an empty WAL from the metadata of a non-zero view
+	// sync on startup even if SyncOnStart is not set. Such a node is typically joining a cluster that may have
+	// moved past the view of its metadata, and syncing starts it at the current view of the cluster
+	// rather than at a stale view whose leader it would complain about.
+	SyncOnStartAtNonZeroView bool
+
+	// VerifyLastDecisionOnStart is a flag indicating whether the last decision the node is started with
+	// should be verified to be signed by a quorum of the nodes on startup.
+	VerifyLastDecisionOnStart bool
+
+	// VerifySyncedDecision is a flag indicating whether the latest decision returned by the synchronizer
+	// should be verified to be signed by a quorum of the nodes, before the node moves its checkpoint to it.
+	// A decision which is not backed by a quorum, such as one forged by a byzantine node it synced from, is ignored.
+	VerifySyncedDecision bool
 
 	// SpeedUpViewChange is a flag indicating whether a node waits for only f+1 view change messages to join
 	// the view change (hence speeds up the view change process), or it waits for a quorum before joining.
 	// Waiting only for f+1 is considered less safe.
 	SpeedUpViewChange bool
+	// JoinDryRunDecisions is the number of consecutive decisions a node verifies after it starts,
+	// while it follows the other nodes without voting, before it starts voting. A value of zero disables the dry run.
+	JoinDryRunDecisions uint64
+	// MaxViewLag is the number of views a node may lag behind the view observed from f+1 other nodes
+	// before it synchronizes instead of catching up view by view. A value of zero disables it.
+	MaxViewLag uint64
 
 	// LeaderRotation is a flag indicating whether leader rotation is active.
 	LeaderRotation bool
 	// DecisionsPerLeader is the number of decisions reached by a leader before there is a leader rotation.
 	DecisionsPerLeader uint64
+	// GenesisLeader is the ID of the node that leads the first view when the nodes start without any decision made.
+	// It must be the same on all nodes. A value of zero means the node with the lowest ID leads the first view.
+	GenesisLeader uint64
+	// NewViewOnLeaderRemoval makes the remaining nodes start the next view when a reconfiguration removes the leader
+	// that proposed it, so that the leadership moves to a remaining node along with a view change. Otherwise,
+	// the nodes stay in the same view, which is led by the node the view maps to out of the remaining nodes.
+	NewViewOnLeaderRemoval bool
 
 	// RequestMaxBytes total allowed size of a single request.
 	RequestMaxBytes uint64
+	// RequestMaxBytesPerType is the total allowed size of a single request of a given type, as classified by
+	// the RequestTypeInspector, which overrides RequestMaxBytes for the requests of that type.
+	// It is ignored if there is no RequestTypeInspector.
+	RequestMaxBytesPerType map[string]uint64
+	// MaxProposalBytes is the total allowed size of the header, payload and metadata of an assembled proposal.
+	// A leader splits a batch whose proposal exceeds it, and gives up a proposal of a single request that exceeds it.
+	// A value of zero means proposals are not limited.
+	MaxProposalBytes uint64
 
 	// RequestPoolSubmitTimeout the total amount of time a client can wait for the submission of a single
 	// request into the request pool.
 	RequestPoolSubmitTimeout time.Duration
+
+	// SortBatchRequests is a flag indicating whether the requests of a batch are ordered by their client ID
+	// and then by their ID, rather than by the order in which they arrived to the leader.
+	SortBatchRequests bool
+	// CutBatchOnVerificationChange is a flag indicating whether the batch of the leader is cut between the
+	// requests submitted before the verification sequence changed and the requests submitted after it changed,
+	// so that no batch mixes requests of different configurations.
+	CutBatchOnVerificationChange bool
 }
 
 // DefaultConfig contains reasonable values for a small cluster that resides on the same geography (or "Region"), but
@@ -94,23 +304,70 @@ var DefaultConfig = Configuration{
 	RequestBatchMaxCount:          100,
 	RequestBatchMaxBytes:          10 * 1024 * 1024,
 	RequestBatchMaxInterval:       50 * time.Millisecond,
+	RequestBatchResetGracePeriod:  0,
+	FirstProposalDelay:            0,
+	MinProposalInterval:           0,
+	CatchUpProposalDelay:          0,
+	MaxLeaderIdleWithoutCommit:    0,
+	MaxViewIdleTime:               0,
+	LogLeaderToken:                false,
+	AssemblerCandidatesMaxCount:   0,
+	AssembleProposalMaxAttempts:   3,
+	AssembleProposalRetryBackoff:  50 * time.Millisecond,
+	AssembleProposalMaxBackoff:    time.Second,
+	VoteAggregationWindow:         0,
+	ResendRecoveredProposal:       true,
+	VerifyInFlightPrepares:        false,
+	VerificationConcurrency:       0,
+	DeliveryRetryInterval:         100 * time.Millisecond,
+	DeliveryMaxAttempts:           0,
+	AsyncDelivery:                 false,
 	IncomingMessageBufferSize:     200,
+	FutureViewMessagesBufferSize:  0,
+	DecisionsBufferSize:           0,
+	DecisionHistorySize:           0,
+	PausedDecisionsBufferSize:     100,
 	RequestPoolSize:               400,
+	RequestPoolMaxBytes:           0,
+	RequestPoolMaxBatchCount:      0,
+	ForwardedRequestsQuota:        0,
+	ProcessedRequestsCacheSize:    1000,
+	ProcessedRequestsCacheMaxAge:  0,
+	RememberProposedRequests:      false,
+	BroadcastConcurrency:          1,
+	BroadcastSendTimeout:          0,
 	RequestForwardTimeout:         2 * time.Second,
 	RequestComplainTimeout:        20 * time.Second,
 	RequestAutoRemoveTimeout:      3 * time.Minute,
+	ForwardedRequestMaxAge:        0,
+	ProposeRequestDigests:         false,
+	RequestFetchTimeout:           2 * time.Second,
+	PrioritizeTimedOutRequests:    false,
+	ForwardToNextLeader:           false,
 	ViewChangeResendInterval:      5 * time.Second,
 	ViewChangeTimeout:             20 * time.Second,
 	LeaderHeartbeatTimeout:        time.Minute,
 	LeaderHeartbeatCount:          10,
+	DetectQuorumLoss:              false,
+	DetectDuplicateID:             false,
 	NumOfTicksBehindBeforeSyncing: 10,
 	CollectTimeout:                time.Second,
 	SyncOnStart:                   false,
+	SyncOnStartAtNonZeroView:      false,
+	VerifyLastDecisionOnStart:     false,
+	VerifySyncedDecision:          false,
 	SpeedUpViewChange:             false,
+	JoinDryRunDecisions:           0,
+	MaxViewLag:                    0,
 	LeaderRotation:                true,
 	DecisionsPerLeader:            3,
+	GenesisLeader:                 0,
+	NewViewOnLeaderRemoval:        false,
 	RequestMaxBytes:               10 * 1024,
+	MaxProposalBytes:              0,
 	RequestPoolSubmitTimeout:      5 * time.Second,
+	SortBatchRequests:             false,
+	CutBatchOnVerificationChange:  false,
 }
 
 func (c Configuration) Validate() error {
@@ -126,12 +383,39 @@ func (c Configuration) Validate() error {
 	if c.RequestBatchMaxInterval <= 0 {
 		return errors.Errorf("RequestBatchMaxInterval should be greater than zero")
 	}
+	if c.RequestBatchResetGracePeriod < 0 {
+		return errors.Errorf("RequestBatchResetGracePeriod should not be negative")
+	}
+	if c.FirstProposalDelay < 0 {
+		return errors.Errorf("FirstProposalDelay should not be negative")
+	}
+	if c.MinProposalInterval < 0 {
+		return errors.Errorf("MinProposalInterval should not be negative")
+	}
+	if c.CatchUpProposalDelay < 0 {
+		return errors.Errorf("CatchUpProposalDelay should not be negative")
+	}
+	if c.MaxLeaderIdleWithoutCommit < 0 {
+		return errors.Errorf("MaxLeaderIdleWithoutCommit should not be negative")
+	}
+	if c.MaxViewIdleTime < 0 {
+		return errors.Errorf("MaxViewIdleTime should not be negative")
+	}
+	if c.MaxViewIdleTime != 0 && c.MaxViewIdleTime < 10*time.Millisecond {
+		return errors.Errorf("MaxViewIdleTime should be zero or at least 10ms")
+	}
+	if c.VoteAggregationWindow < 0 {
+		return errors.Errorf("VoteAggregationWindow should not be negative")
+	}
 	if c.IncomingMessageBufferSize == 0 {
 		return errors.Errorf("IncomingMessageBufferSize should be greater than zero")
 	}
 	if c.RequestPoolSize == 0 {
 		return errors.Errorf("RequestPoolSize should be greater than zero")
 	}
+	if c.RequestPoolMaxBatchCount != 0 && c.RequestPoolMaxBatchCount < c.RequestBatchMaxCount {
+		return errors.Errorf("RequestPoolMaxBatchCount is smaller than RequestBatchMaxCount")
+	}
 	if c.RequestForwardTimeout <= 0 {
 		return errors.Errorf("RequestForwardTimeout should be greater than zero")
 	}
@@ -162,12 +446,36 @@ func (c Configuration) Validate() error {
 	if c.RequestBatchMaxCount > c.RequestBatchMaxBytes {
 		return errors.Errorf("RequestBatchMaxCount is bigger than RequestBatchMaxBytes")
 	}
+	if c.AssemblerCandidatesMaxCount != 0 && c.AssemblerCandidatesMaxCount < c.RequestBatchMaxCount {
+		return errors.Errorf("AssemblerCandidatesMaxCount is smaller than RequestBatchMaxCount")
+	}
+	if c.AssembleProposalRetryBackoff < 0 {
+		return errors.Errorf("AssembleProposalRetryBackoff should not be negative")
+	}
+	if c.AssembleProposalMaxBackoff < 0 {
+		return errors.Errorf("AssembleProposalMaxBackoff should not be negative")
+	}
+	if c.DeliveryRetryInterval < 0 {
+		return errors.Errorf("DeliveryRetryInterval should not be negative")
+	}
+	if c.BroadcastSendTimeout < 0 {
+		return errors.Errorf("BroadcastSendTimeout should not be negative")
+	}
 	if c.RequestForwardTimeout > c.RequestComplainTimeout {
 		return errors.Errorf("RequestForwardTimeout is bigger than RequestComplainTimeout")
 	}
 	if c.RequestComplainTimeout > c.RequestAutoRemoveTimeout {
 		return errors.Errorf("RequestComplainTimeout is bigger than RequestAutoRemoveTimeout")
 	}
+	if c.ProcessedRequestsCacheMaxAge < 0 {
+		return errors.Errorf("ProcessedRequestsCacheMaxAge should not be negative")
+	}
+	if c.ForwardedRequestMaxAge < 0 {
+		return errors.Errorf("ForwardedRequestMaxAge should not be negative")
+	}
+	if c.ProposeRequestDigests && c.RequestFetchTimeout <= 0 {
+		return errors.Errorf("RequestFetchTimeout should be greater than zero when ProposeRequestDigests is set")
+	}
 	if c.ViewChangeResendInterval > c.ViewChangeTimeout {
 		return errors.Errorf("ViewChangeResendInterval is bigger than ViewChangeTimeout")
 	}
@@ -180,9 +488,23 @@ func (c Configuration) Validate() error {
 	if c.RequestMaxBytes == 0 {
 		return errors.Errorf("RequestMaxBytes should be greater than zero")
 	}
+	for reqType, maxBytes := range c.RequestMaxBytesPerType {
+		if maxBytes == 0 {
+			return errors.Errorf("RequestMaxBytesPerType of type %s should be greater than zero", reqType)
+		}
+		if c.MaxProposalBytes != 0 && c.MaxProposalBytes < maxBytes {
+			return errors.Errorf("MaxProposalBytes is smaller than RequestMaxBytesPerType of type %s", reqType)
+		}
+	}
 	if c.RequestPoolSubmitTimeout <= 0 {
 		return errors.Errorf("RequestPoolSubmitTimeout should be greater than zero")
 	}
+	if c.RequestPoolMaxBytes != 0 && c.RequestPoolMaxBytes < c.RequestMaxBytes {
+		return errors.Errorf("RequestPoolMaxBytes is smaller than RequestMaxBytes")
+	}
+	if c.MaxProposalBytes != 0 && c.MaxProposalBytes < c.RequestMaxBytes {
+		return errors.Errorf("MaxProposalBytes is smaller than RequestMaxBytes")
+	}
 
 	return nil
 }
@@ -11,6 +11,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/hyperledger-labs/SmartBFT/smartbftprotos"
 )
@@ -31,6 +32,49 @@ type Signature struct {
 type Decision struct {
 	Proposal   Proposal
 	Signatures []Signature
+	// CommitView is the view in which this node committed the decision,
+	// or zero if the decision was not committed by this node
+	CommitView uint64
+}
+
+// Fault is a synthetic fault injected into a consensus message, for testing.
+type Fault struct {
+	// Drop drops the message
+	Drop bool
+	// Delay defers the message by the given duration
+	Delay time.Duration
+	// Duplicate makes the message be sent or handled twice
+	Duplicate bool
+	// Replace is sent or handled instead of the message, if set, which lets a node equivocate
+	Replace *smartbftprotos.Message
+}
+
+// LatencyStats summarizes the time it took to deliver the most recent requests since they were submitted.
+type LatencyStats struct {
+	// Count is the number of requests whose latency was measured
+	Count uint64
+	P50   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// The types of the entries of the write ahead log
+const (
+	WALEntryProposal   = "proposal"
+	WALEntryCommit     = "commit"
+	WALEntryNewView    = "new view"
+	WALEntryViewChange = "view change"
+	WALEntryMalformed  = "malformed"
+)
+
+// WALEntrySummary describes an entry of the write ahead log, without the proposal or signatures it holds.
+type WALEntrySummary struct {
+	// Type is one of the WALEntry types
+	Type string
+	// View is the view of the entry, which for a view change is the view it changes to
+	View uint64
+	// Seq is the sequence of the entry, or zero for a view change
+	Seq uint64
 }
 
 type ViewAndSeq struct {
@@ -41,6 +85,9 @@ type ViewAndSeq struct {
 type RequestInfo struct {
 	ClientID string
 	ID       string
+	// TraceID optionally correlates the request with an end-to-end trace.
+	// It is a part of the request's identity, hence it must be extracted from the request consistently.
+	TraceID string
 }
 
 func (r *RequestInfo) String() string {
@@ -108,6 +155,10 @@ type Reconfig struct {
 	InLatestDecision bool
 	CurrentNodes     []uint64
 	CurrentConfig    Configuration
+	// BasedOnNodes is the set of nodes the reconfiguration was made for. If it is set and differs from the nodes
+	// at the sequence the reconfiguration is committed in, another reconfiguration was committed before it,
+	// hence it is stale and is not applied.
+	BasedOnNodes []uint64
 }
 
 type SyncResponse struct {
@@ -14,25 +14,39 @@ var countOfFilesOpts = metrics.GaugeOpts{
 	StatsdFormat: "%{#fqname}",
 }
 
+var mirrorLagOpts = metrics.GaugeOpts{
+	Namespace:    "consensus",
+	Subsystem:    "smartbft",
+	Name:         "wal_mirror_lag",
+	Help:         "Count of wal entries not yet appended to the mirror wal.",
+	LabelNames:   []string{},
+	StatsdFormat: "%{#fqname}",
+}
+
 // Metrics encapsulates wal metrics
 type Metrics struct {
 	CountOfFiles metrics.Gauge
+	MirrorLag    metrics.Gauge
 }
 
 // NewMetrics create new wal metrics
 func NewMetrics(p metrics.Provider, labelNames ...string) *Metrics {
 	countOfFilesOptsTmp := api.NewGaugeOpts(countOfFilesOpts, labelNames)
+	mirrorLagOptsTmp := api.NewGaugeOpts(mirrorLagOpts, labelNames)
 	return &Metrics{
 		CountOfFiles: p.NewGauge(countOfFilesOptsTmp),
+		MirrorLag:    p.NewGauge(mirrorLagOptsTmp),
 	}
 }
 
 func (m *Metrics) With(labelValues ...string) *Metrics {
 	return &Metrics{
 		CountOfFiles: m.CountOfFiles.With(labelValues...),
+		MirrorLag:    m.MirrorLag.With(labelValues...),
 	}
 }
 
 func (m *Metrics) Initialize() {
 	m.CountOfFiles.Add(0)
+	m.MirrorLag.Add(0)
 }
@@ -0,0 +1,115 @@
+// Copyright IBM Corp. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package wal
+
+import (
+	"sync"
+
+	"github.com/hyperledger-labs/SmartBFT/pkg/api"
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics/disabled"
+	"github.com/pkg/errors"
+)
+
+// MirrorBufferSizeDefault is the default number of entries buffered for the mirror.
+const MirrorBufferSizeDefault = 1024
+
+type mirrorEntry struct {
+	data       []byte
+	truncateTo bool
+}
+
+// MirrorWAL is a write ahead log which appends every entry to a primary WAL, and mirrors it to a secondary WAL
+// for disaster recovery.
+//
+// Entries are appended to the primary synchronously, and to the mirror asynchronously and in the same order,
+// so that a slow mirror does not delay the appends. Entries that were not yet appended to the mirror are buffered,
+// and once the buffer is full, appends wait for the mirror to catch up.
+// An entry that fails to be appended to the primary is not mirrored, and an entry that fails to be appended
+// to the mirror is logged and skipped.
+type MirrorWAL struct {
+	logger  api.Logger
+	metrics *Metrics
+	primary api.WriteAheadLog
+	mirror  api.WriteAheadLog
+
+	mutex   sync.Mutex
+	closed  bool
+	entries chan mirrorEntry
+	done    chan struct{}
+}
+
+// NewMirrorWAL creates a MirrorWAL which appends to the given primary WAL, and mirrors to the given mirror WAL.
+//
+// logger: reference to a Logger implementation.
+// bufferSize: the number of entries buffered for the mirror, or zero for the default.
+// metrics: the metrics reporting the mirror lag, or nil for disabled metrics.
+func NewMirrorWAL(logger api.Logger, primary, mirror api.WriteAheadLog, bufferSize int, metrics *Metrics) *MirrorWAL {
+	if bufferSize == 0 {
+		bufferSize = MirrorBufferSizeDefault
+	}
+	if metrics == nil {
+		metrics = NewMetrics(&disabled.Provider{})
+	}
+
+	m := &MirrorWAL{
+		logger:  logger,
+		metrics: metrics,
+		primary: primary,
+		mirror:  mirror,
+		entries: make(chan mirrorEntry, bufferSize),
+		done:    make(chan struct{}),
+	}
+
+	go m.run()
+
+	return m
+}
+
+// Append appends the data item to the primary WAL, and then queues it to be appended to the mirror WAL.
+//
+// data: the data to be appended to the log.
+// truncateTo: whether all records preceding this one, but not including it, can be truncated from the log.
+func (m *MirrorWAL) Append(data []byte, truncateTo bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return errors.New("mirror wal is closed")
+	}
+
+	if err := m.primary.Append(data, truncateTo); err != nil {
+		return err
+	}
+
+	m.metrics.MirrorLag.Add(1)
+	m.entries <- mirrorEntry{data: append(make([]byte, 0, len(data)), data...), truncateTo: truncateTo}
+
+	return nil
+}
+
+// Close waits until all the queued entries are appended to the mirror WAL.
+// It does not close the primary and mirror WALs.
+func (m *MirrorWAL) Close() {
+	m.mutex.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.entries)
+	}
+	m.mutex.Unlock()
+
+	<-m.done
+}
+
+func (m *MirrorWAL) run() {
+	defer close(m.done)
+
+	for entry := range m.entries {
+		if err := m.mirror.Append(entry.data, entry.truncateTo); err != nil {
+			m.logger.Errorf("Failed appending entry to the mirror wal: %v", err)
+		}
+		m.metrics.MirrorLag.Add(-1)
+	}
+}
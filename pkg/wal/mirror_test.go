@@ -0,0 +1,75 @@
+// Copyright IBM Corp. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package wal
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type memoryWAL struct {
+	lock    sync.Mutex
+	entries [][]byte
+	release chan struct{} // if set, each append waits for it
+}
+
+func (w *memoryWAL) Append(entry []byte, _ bool) error {
+	if w.release != nil {
+		<-w.release
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.entries = append(w.entries, entry)
+	return nil
+}
+
+func (w *memoryWAL) all() [][]byte {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([][]byte{}, w.entries...)
+}
+
+func TestMirrorWAL(t *testing.T) {
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+	logger := basicLog.Sugar()
+
+	primary := &memoryWAL{}
+	mirror := &memoryWAL{release: make(chan struct{})}
+	mirrorWAL := NewMirrorWAL(logger, primary, mirror, 100, nil)
+
+	var expected [][]byte
+	appended := make(chan struct{})
+	go func() {
+		defer close(appended)
+		for i := 0; i < 10; i++ {
+			entry := []byte(fmt.Sprintf("entry-%d", i))
+			assert.NoError(t, mirrorWAL.Append(entry, i == 5))
+			expected = append(expected, entry)
+		}
+	}()
+
+	// The primary is not blocked by the mirror, which did not append anything yet
+	select {
+	case <-appended:
+	case <-time.After(10 * time.Second):
+		t.Fatal("appends were blocked by the mirror")
+	}
+	assert.Equal(t, expected, primary.all())
+	assert.Empty(t, mirror.all())
+
+	// Once the mirror proceeds, it has all the entries in order
+	close(mirror.release)
+	mirrorWAL.Close()
+	assert.Equal(t, expected, mirror.all())
+
+	assert.Error(t, mirrorWAL.Append([]byte("entry"), false))
+}
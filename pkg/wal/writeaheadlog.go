@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger-labs/SmartBFT/pkg/api"
@@ -35,10 +36,42 @@ const (
 
 	walCRCSeed uint32 = 0xDEED0001
 
-	FileSizeBytesDefault   int64 = 64 * 1024 * 1024 // 64MB
-	BufferSizeBytesDefault int64 = 1024 * 1024      // 1MB
+	FileSizeBytesDefault   int64         = 64 * 1024 * 1024 // 64MB
+	BufferSizeBytesDefault int64         = 1024 * 1024      // 1MB
+	SyncIntervalDefault    time.Duration = 100 * time.Millisecond
 )
 
+// SyncPolicy determines when appended records are flushed (fsync) to stable storage.
+//
+// Only SyncAlways guarantees that a record is persisted once Append returns. The consensus library relies on this
+// to persist a vote before sending it, so that a node never contradicts its own vote after a crash. With SyncInterval
+// or SyncOff, a crash may lose the most recent records, and a restarted node may vote differently in a view in which
+// it already voted, so these policies should be used only when a crash of the entire host is not a concern.
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes the log file after every appended record, before Append returns.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes the log file on an append, if at least SyncInterval elapsed since the last flush.
+	// Records that were not flushed on append are flushed in the background once SyncInterval elapses.
+	SyncInterval
+	// SyncOff never flushes on append, and leaves it to the operating system.
+	SyncOff
+)
+
+func (p SyncPolicy) String() string {
+	switch p {
+	case SyncAlways:
+		return "always"
+	case SyncInterval:
+		return "interval"
+	case SyncOff:
+		return "off"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(p))
+	}
+}
+
 var (
 	ErrCRC                 = errors.New("wal: crc verification failed")
 	ErrWALUnmarshalPayload = errors.New("wal: failed to unmarshal payload")
@@ -94,11 +127,20 @@ type WriteAheadLogFile struct {
 	readMode      bool
 	truncateIndex uint64
 	activeIndexes []uint64
+	lastSync      time.Time
+	dirty         bool
+	syncTimer     *time.Timer
 }
 
+// Options for the WAL.
+//
+// Regardless of the SyncPolicy, the log file is always flushed when it is switched or closed, and the CRC-Anchor of
+// every new log file is always flushed, together with the directory, so that file switching is crash safe.
 type Options struct {
 	FileSizeBytes   int64
 	BufferSizeBytes int64
+	SyncPolicy      SyncPolicy
+	SyncInterval    time.Duration
 	Metrics         *Metrics
 }
 
@@ -107,12 +149,15 @@ func DefaultOptions() *Options {
 	return &Options{
 		FileSizeBytes:   FileSizeBytesDefault,
 		BufferSizeBytes: BufferSizeBytesDefault,
+		SyncPolicy:      SyncAlways,
+		SyncInterval:    SyncIntervalDefault,
 		Metrics:         NewMetrics(&disabled.Provider{}),
 	}
 }
 
 func (o *Options) String() string {
-	return fmt.Sprintf("{FileSizeBytes: %d, BufferSizeBytes: %d}", o.FileSizeBytes, o.BufferSizeBytes)
+	return fmt.Sprintf("{FileSizeBytes: %d, BufferSizeBytes: %d, SyncPolicy: %s, SyncInterval: %s}",
+		o.FileSizeBytes, o.BufferSizeBytes, o.SyncPolicy, o.SyncInterval)
 }
 
 // Create will create a new WAL, if it does not exist, or an error if it already exists.
@@ -142,6 +187,13 @@ func Create(logger api.Logger, dirPath string, options *Options) (*WriteAheadLog
 		if options.BufferSizeBytes != 0 {
 			opt.BufferSizeBytes = options.BufferSizeBytes
 		}
+		opt.SyncPolicy = options.SyncPolicy
+		if options.SyncInterval != 0 {
+			opt.SyncInterval = options.SyncInterval
+		}
+	}
+	if opt.SyncPolicy < SyncAlways || opt.SyncPolicy > SyncOff {
+		return nil, fmt.Errorf("wal: invalid sync policy: %s", opt.SyncPolicy)
 	}
 	opt.Metrics.Initialize()
 
@@ -189,6 +241,12 @@ func Create(logger api.Logger, dirPath string, options *Options) (*WriteAheadLog
 		return nil, err
 	}
 
+	if err = wal.dirFile.Sync(); err != nil {
+		_ = wal.Close()
+
+		return nil, fmt.Errorf("wal: could not sync directory: %s; error: %w", dirPath, err)
+	}
+
 	wal.logger.Infof("Write-Ahead-Log created successfully, mode: WRITE, dir: %s", wal.dirName)
 
 	return wal, nil
@@ -231,6 +289,13 @@ func Open(logger api.Logger, dirPath string, options *Options) (*WriteAheadLogFi
 		if options.BufferSizeBytes != 0 {
 			opt.BufferSizeBytes = options.BufferSizeBytes
 		}
+		opt.SyncPolicy = options.SyncPolicy
+		if options.SyncInterval != 0 {
+			opt.SyncInterval = options.SyncInterval
+		}
+	}
+	if opt.SyncPolicy < SyncAlways || opt.SyncPolicy > SyncOff {
+		return nil, fmt.Errorf("wal: invalid sync policy: %s", opt.SyncPolicy)
 	}
 	opt.Metrics.Initialize()
 
@@ -337,12 +402,18 @@ func Repair(logger api.Logger, dirPath string) error {
 }
 
 // Close the files and directory of the WAL, and release all resources.
+// Records that were not flushed yet are flushed before the log file is closed.
 func (w *WriteAheadLogFile) Close() error {
 	var errF, errD error
 
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
+	if w.syncTimer != nil {
+		w.syncTimer.Stop()
+		w.syncTimer = nil
+	}
+
 	if w.logFile != nil {
 		if errF = w.truncateAndCloseLogFile(); errF != nil {
 			w.logger.Errorf("failed to properly close log file %s; error: %s", w.logFile.Name(), errF)
@@ -460,7 +531,7 @@ func (w *WriteAheadLogFile) append(record *protos.LogRecord) error {
 		return fmt.Errorf("wal: failed to write payload bytes: %w", err)
 	}
 
-	err = w.logFile.Sync()
+	err = w.syncLogFile()
 	if err != nil {
 		return fmt.Errorf("wal: failed to Sync log file: %w", err)
 	}
@@ -625,6 +696,8 @@ func (w *WriteAheadLogFile) truncateAndCloseLogFile() error {
 		return err
 	}
 
+	w.dirty = false
+
 	w.logger.Debugf("Truncated, Sync'ed & Closed log file: %s", w.logFile.Name())
 
 	return nil
@@ -701,12 +774,65 @@ func (w *WriteAheadLogFile) deleteAndCreateFile() error {
 		return err
 	}
 
+	// Make sure the file creation and deletions survive a crash
+	if err = w.dirFile.Sync(); err != nil {
+		return fmt.Errorf("wal: failed to Sync directory: %w", err)
+	}
+
 	w.activeIndexes = append(w.activeIndexes, w.index)
 	w.metrics.CountOfFiles.Set(float64(len(w.activeIndexes)))
 
 	return nil
 }
 
+// syncLogFile flushes the log file according to the SyncPolicy.
+func (w *WriteAheadLogFile) syncLogFile() error {
+	switch w.options.SyncPolicy {
+	case SyncOff:
+		return nil
+	case SyncInterval:
+		if elapsed := time.Since(w.lastSync); elapsed < w.options.SyncInterval {
+			w.dirty = true
+			if w.syncTimer == nil {
+				w.syncTimer = time.AfterFunc(w.options.SyncInterval-elapsed, w.syncDirtyLogFile)
+			}
+
+			return nil
+		}
+	}
+
+	if err := w.logFile.Sync(); err != nil {
+		return err
+	}
+
+	w.lastSync = time.Now()
+	w.dirty = false
+
+	return nil
+}
+
+// syncDirtyLogFile flushes the records that were appended since the last flush,
+// so that with SyncInterval no record is left unflushed for longer than SyncInterval.
+func (w *WriteAheadLogFile) syncDirtyLogFile() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.syncTimer = nil
+
+	if !w.dirty || w.logFile == nil || w.readMode {
+		return
+	}
+
+	if err := w.logFile.Sync(); err != nil {
+		w.logger.Errorf("Failed to Sync log file: %s; error: %s", w.logFile.Name(), err)
+
+		return
+	}
+
+	w.lastSync = time.Now()
+	w.dirty = false
+}
+
 // saveCRC saves the current CRC followed by a CRC_ANCHOR record.
 func (w *WriteAheadLogFile) saveCRC() error {
 	anchorRecord := &protos.LogRecord{Type: protos.LogRecord_CRC_ANCHOR, TruncateTo: false}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/hyperledger-labs/SmartBFT/pkg/api"
 	"github.com/hyperledger-labs/SmartBFT/smartbftprotos"
@@ -678,6 +679,108 @@ func TestWriteAheadLogFile_Repair(t *testing.T) {
 	})
 }
 
+func TestWriteAheadLogFile_SyncPolicy(t *testing.T) {
+	testDir, err := os.MkdirTemp("", "unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+
+	defer os.RemoveAll(testDir)
+
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+
+	logger := basicLog.Sugar()
+
+	t.Run("invalid", func(t *testing.T) {
+		dirPath := filepath.Join(testDir, "invalid")
+
+		wal, err := Create(logger, dirPath, &Options{SyncPolicy: SyncPolicy(7)})
+		assert.EqualError(t, err, "wal: invalid sync policy: unknown(7)")
+		assert.Nil(t, wal)
+	})
+
+	for _, policy := range []SyncPolicy{SyncAlways, SyncInterval, SyncOff} {
+		t.Run(fmt.Sprintf("crash with torn record - %s", policy), func(t *testing.T) {
+			dirPath := filepath.Join(testDir, policy.String())
+
+			wal, err := Create(logger, dirPath, &Options{
+				FileSizeBytes:   10 * 1024,
+				BufferSizeBytes: 2048,
+				SyncPolicy:      policy,
+				SyncInterval:    time.Millisecond,
+			})
+			assert.NoError(t, err)
+			assert.NotNil(t, wal)
+			if wal == nil {
+				return
+			}
+
+			const NumBytes = 1024
+			const NumRec = 102
+			data1 := make([]byte, NumBytes)
+			for m := 0; m < NumRec; m++ {
+				for n := 0; n < NumBytes; n++ {
+					data1[n] = byte(m)
+				}
+				err = wal.Append(data1, false)
+				assert.NoError(t, err)
+			}
+
+			// crash while writing the next record: the files are not truncated & closed orderly
+			_, err = wal.logFile.Write([]byte{0x10, 0x04, 0, 0, 0xAB, 0xCD, 0xEF, 0x01, 1, 2, 3})
+			assert.NoError(t, err)
+			assert.NoError(t, wal.logFile.Close())
+			assert.NoError(t, wal.dirFile.Close())
+
+			assertTestRepair(t, logger, dirPath, NumRec)
+		})
+	}
+}
+
+func TestWriteAheadLogFile_SyncIntervalInBackground(t *testing.T) {
+	testDir, err := os.MkdirTemp("", "unittest")
+	assert.NoErrorf(t, err, "generate temporary test dir")
+
+	defer os.RemoveAll(testDir)
+
+	basicLog, err := zap.NewDevelopment()
+	assert.NoError(t, err)
+
+	logger := basicLog.Sugar()
+
+	dirty := func(wal *WriteAheadLogFile) bool {
+		wal.mutex.Lock()
+		defer wal.mutex.Unlock()
+
+		return wal.dirty
+	}
+
+	wal, err := Create(logger, testDir, &Options{SyncPolicy: SyncInterval, SyncInterval: 50 * time.Millisecond})
+	assert.NoError(t, err)
+
+	// The first append is flushed, and the next one within the interval is not
+	assert.NoError(t, wal.Append([]byte{1}, false))
+	assert.False(t, dirty(wal))
+	assert.NoError(t, wal.Append([]byte{2}, false))
+	assert.True(t, dirty(wal))
+
+	// Without any further append, the second record is flushed in the background
+	assert.Eventually(t, func() bool { return !dirty(wal) }, time.Second, 10*time.Millisecond)
+
+	// Records that are not flushed yet are flushed on close
+	assert.NoError(t, wal.Append([]byte{3}, false))
+	assert.True(t, dirty(wal))
+	assert.NoError(t, wal.Close())
+	assert.False(t, wal.dirty)
+	assert.Nil(t, wal.syncTimer)
+
+	wal, err = Open(logger, testDir, nil)
+	assert.NoError(t, err)
+	entries, err := wal.ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{{1}, {2}, {3}}, entries)
+	assert.NoError(t, wal.Close())
+}
+
 func TestWriteAheadLogFile_InitializeAndReadAll(t *testing.T) {
 	testDir, err := os.MkdirTemp("", t.Name())
 	assert.NoErrorf(t, err, "generate temporary test dir")
@@ -71,6 +71,22 @@ if [[ $? -ne 0 ]];then
     exit 1
 fi
 
+(cd pkg/metrics/prometheus && go mod tidy -diff && go mod verify)
+if [[ $? -ne 0 ]];then
+    err "prometheus metrics provider module is not tidy or fails verification"
+    exit 1
+fi
+
+# The provider requires a tagged release of the root module, so test it against this checkout through a workspace
+export GOWORK=$(mktemp -d)/go.work
+go work init "$PWD" "$PWD/pkg/metrics/prometheus"
+go test -count 1 -race ./pkg/metrics/prometheus/...
+if [[ $? -ne 0 ]];then
+    err "prometheus metrics provider tests failed"
+    exit 1
+fi
+unset GOWORK
+
 
 echo -e "${ANSI_GREEN} \(^o^)/ Tests passed! ${ANSI_RESET}"
 exit 0
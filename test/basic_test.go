@@ -6,10 +6,14 @@
 package test
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,6 +23,8 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger-labs/SmartBFT/internal/bft"
+	"github.com/hyperledger-labs/SmartBFT/pkg/consensus"
+	"github.com/hyperledger-labs/SmartBFT/pkg/types"
 	"github.com/hyperledger-labs/SmartBFT/smartbftprotos"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
@@ -149,6 +155,54 @@ func TestNodeViewChangeWhileInPartition(t *testing.T) {
 	viewChangeWG.Wait()
 }
 
+func TestLeaderCompletesRecoveredProposalAfterRestart(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	// The leader records its proposal in the WAL, but restarts before the followers receive it
+	proposed := make(chan struct{})
+	var proposedOnce sync.Once
+	nodes[0].Consensus.FaultInjector = &faultInjector{
+		outgoing: func(_ uint64, m *smartbftprotos.Message) types.Fault {
+			if m.GetPrePrepare() != nil {
+				proposedOnce.Do(func() { close(proposed) })
+				return types.Fault{Drop: true}
+			}
+			return types.Fault{}
+		},
+	}
+	startNodes(nodes, network)
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	<-proposed
+	nodes[0].Restart()
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+
+	// The original proposal is completed, and the new request is proposed only in the next sequence
+	for _, expected := range []string{"1", "2"} {
+		for i := 0; i < numberOfNodes; i++ {
+			select {
+			case record := <-nodes[i].Delivered:
+				assert.Len(t, record.Batch.Requests, 1)
+				assert.Equal(t, expected, requestFromBytes(record.Batch.Requests[0]).ID)
+			case <-time.After(30 * time.Second):
+				t.Fatalf("node %d did not deliver request %s", i+1, expected)
+			}
+		}
+	}
+}
+
 func TestRestartFollowers(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
@@ -249,7 +303,7 @@ func TestLeaderInPartition(t *testing.T) {
 	assert.LessOrEqual(t, uint64(2), nodes[2].Consensus.GetLeaderID())
 }
 
-func TestAfterDecisionLeaderInPartition(t *testing.T) {
+func TestDeliveryRecordsCommitView(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -262,64 +316,40 @@ func TestAfterDecisionLeaderInPartition(t *testing.T) {
 	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Application = &viewRecordingApp{App: n}
 		nodes = append(nodes, n)
 	}
+	// The first delivery of the last node fails, and is retried along with the commit view
+	nodes[3].Consensus.FallibleApplication = &viewRecordingApp{App: nodes[3]}
+	nodes[3].Consensus.Config.DeliveryRetryInterval = 10 * time.Millisecond
+	atomic.StoreInt32(&nodes[3].deliverFails, 1)
 	startNodes(nodes, network)
 
-	nodes[0].Submit(Request{ID: "1", ClientID: "alice"}) // submit to leader
-
-	data := make([]*AppRecord, 0)
-	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
-	}
-
-	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
-
-	data = make([]*AppRecord, 0)
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
 	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
+		record := <-nodes[i].Delivered
+		assert.Equal(t, uint64(0), record.CommitView)
 	}
 
 	nodes[0].Disconnect() // leader in partition
 
 	for i := 1; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "3", ClientID: "alice"}) // submit to other nodes
-	}
-
-	data = make([]*AppRecord, 0)
-	for i := 1; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-2; i++ {
-		assert.Equal(t, data[i], data[i+1])
-	}
-
-	for i := 1; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "4", ClientID: "alice"}) // submit to other nodes
+		nodes[i].Submit(Request{ID: "2", ClientID: "alice"}) // submit to other nodes
 	}
 
-	data = make([]*AppRecord, 0)
 	for i := 1; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-2; i++ {
-		assert.Equal(t, data[i], data[i+1])
+		record := <-nodes[i].Delivered
+		md := &smartbftprotos.ViewMetadata{}
+		assert.NoError(t, proto.Unmarshal(record.Metadata, md))
+		assert.Equal(t, uint64(2), md.LatestSequence)
+		assert.Equal(t, md.ViewId, record.CommitView)
+		assert.LessOrEqual(t, uint64(1), record.CommitView)
 	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&nodes[3].deliverCalls))
 }
 
-func TestLeaderInPartitionWithHealing(t *testing.T) {
+func TestLeaderForView(t *testing.T) {
 	t.Parallel()
-
 	network := NewNetwork()
 	defer network.Shutdown()
 
@@ -327,62 +357,48 @@ func TestLeaderInPartitionWithHealing(t *testing.T) {
 	assert.NoErrorf(t, err, "generate temporary test dir")
 	defer os.RemoveAll(testDir)
 
-	numberOfNodes := 4
+	numberOfNodes := 7
 	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
 		nodes = append(nodes, n)
 	}
-	startNodes(nodes, network)
-
-	nodes[0].Submit(Request{ID: "1", ClientID: "alice"}) // submit to leader
-
-	data := make([]*AppRecord, 0)
-	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
-	}
 
-	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+	_, exists := nodes[6].Consensus.LeaderForView(0)
+	assert.False(t, exists)
 
-	data = make([]*AppRecord, 0)
-	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
-	}
+	startNodes(nodes, network)
 
-	nodes[0].Disconnect() // leader in partition
-	t.Log("Disconnected n0")
+	leader, exists := nodes[6].Consensus.LeaderForView(0)
+	assert.True(t, exists)
+	assert.Equal(t, uint64(1), leader)
 
-	for i := 1; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "3", ClientID: "alice"}) // submit to other nodes
+	// Disconnect the leader and then the next leader, each followed by a request that forces a view change
+	for disconnected := 0; disconnected < 2; disconnected++ {
+		nodes[disconnected].Disconnect()
+		for i := disconnected + 1; i < numberOfNodes; i++ {
+			nodes[i].Submit(Request{ID: fmt.Sprintf("%d", disconnected), ClientID: "alice"})
+		}
+		for i := disconnected + 1; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
 	}
 
-	data = make([]*AppRecord, 0)
-	for i := 1; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-2; i++ {
-		assert.Equal(t, data[i], data[i+1])
+	// The recorded leaders are the leaders of the views the node went through
+	currentLeader := nodes[6].Consensus.GetLeaderID()
+	var views []uint64
+	for view := uint64(0); view < 10; view++ {
+		if leader, exists := nodes[6].Consensus.LeaderForView(view); exists {
+			assert.Equal(t, view%uint64(numberOfNodes)+1, leader)
+			views = append(views, view)
+		}
 	}
-
-	assert.Len(t, nodes[0].Delivered, 0) // n0 did not receive it
-
-	nodes[0].Connect() // partition heals, leader should eventually sync, become a follower, and deliver
-	t.Log("Connected n0")
-
-	data0 := <-nodes[0].Delivered
-	assert.Equal(t, data[0], data0)
+	assert.GreaterOrEqual(t, len(views), 3)
+	leader, _ = nodes[6].Consensus.LeaderForView(views[len(views)-1])
+	assert.Equal(t, currentLeader, leader)
 }
 
-func TestMultiLeadersPartition(t *testing.T) {
+func TestExpectedLeader(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -398,46 +414,49 @@ func TestMultiLeadersPartition(t *testing.T) {
 		nodes = append(nodes, n)
 	}
 
-	start := time.Now()
-	for _, n := range nodes {
-		n.viewChangeTime = make(chan time.Time, 1)
-		n.viewChangeTime <- start
-		n.Setup()
-	}
-	assert.Equal(t, uint64(0), nodes[0].Consensus.GetLeaderID())
-	startNodes(nodes, network)
-	assert.Equal(t, uint64(1), nodes[0].Consensus.GetLeaderID())
+	assert.Equal(t, uint64(0), nodes[6].Consensus.ExpectedLeader(1))
 
-	nodes[0].Disconnect() // leader in partition
-	nodes[1].Disconnect() // next leader in partition
+	startNodes(nodes, network)
 
-	for i := 2; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "1", ClientID: "alice"}) // submit to other nodes
+	expected := make(map[uint64]uint64)
+	for view := uint64(0); view < 3; view++ {
+		expected[view] = nodes[6].Consensus.ExpectedLeader(view)
+		// All nodes expect the same leader
+		for i := 0; i < numberOfNodes; i++ {
+			assert.Equal(t, expected[view], nodes[i].Consensus.ExpectedLeader(view))
+		}
 	}
 
-	done := make(chan struct{})
-	defer close(done)
-
-	var counter uint64
-	accelerateTime(nodes, done, false, true, &counter)
-
-	data := make([]*AppRecord, 0)
-	for i := 2; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-3; i++ {
-		assert.Equal(t, data[i], data[i+1])
+	// Disconnect the leader and then the next leader, each followed by a request that forces a view change
+	disconnected := make(map[int]bool)
+	for round := 0; round < 2; round++ {
+		leader := int(nodes[6].Consensus.GetLeaderID()) - 1
+		nodes[leader].Disconnect()
+		disconnected[leader] = true
+		for i := 0; i < numberOfNodes; i++ {
+			if !disconnected[i] {
+				nodes[i].Submit(Request{ID: fmt.Sprintf("%d", round), ClientID: "alice"})
+			}
+		}
+		for i := 0; i < numberOfNodes; i++ {
+			if !disconnected[i] {
+				<-nodes[i].Delivered
+			}
+		}
 	}
 
-	lID := nodes[2].Consensus.GetLeaderID()
-	assert.LessOrEqual(t, uint64(3), lID)
-	for i := 3; i < numberOfNodes; i++ {
-		assert.Equal(t, lID, nodes[i].Consensus.GetLeaderID())
+	// The views were started with the leaders that were expected before the view changes
+	var views int
+	for view, leader := range expected {
+		if actual, exists := nodes[6].Consensus.LeaderForView(view); exists {
+			assert.Equal(t, leader, actual)
+			views++
+		}
 	}
+	assert.Equal(t, 3, views)
 }
 
-func TestHeartbeatTimeoutCausesViewChange(t *testing.T) {
+func TestLeaderPausesWithoutQuorum(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -450,56 +469,73 @@ func TestHeartbeatTimeoutCausesViewChange(t *testing.T) {
 	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		if i == 1 {
+			n.heartbeatTime = make(chan time.Time, 1)
+			n.heartbeatTime <- time.Now()
+			n.Setup()
+			n.quorumEvents = make(chan bool, 10)
+			n.Consensus.QuorumObserver = n
+			// Make sure the leader keeps sending heartbeats while it holds a request
+			n.Consensus.Config.RequestComplainTimeout = time.Minute
+		}
+		n.Consensus.Config.DetectQuorumLoss = true
 		nodes = append(nodes, n)
 	}
+	var prePrepares int32
+	nodes[1].LoseMessages(func(msg *smartbftprotos.Message) bool {
+		if msg.GetPrePrepare() != nil {
+			atomic.AddInt32(&prePrepares, 1)
+		}
+		return false
+	})
+	startNodes(nodes, network)
 
-	start := time.Now()
-	for _, n := range nodes {
-		n.heartbeatTime = make(chan time.Time, 1)
-		n.heartbeatTime <- start
-		n.Setup()
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
 	}
+	assert.True(t, nodes[0].Consensus.QuorumReachable())
 
-	// wait for the new leader to finish the view change before submitting
-	done := make(chan struct{})
-	viewChangeWG := sync.WaitGroup{}
-	viewChangeWG.Add(numberOfNodes - 1)
-	for _, n := range nodes {
-		baseLogger := n.Consensus.Logger.(*zap.SugaredLogger).Desugar()
-		n.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if strings.Contains(entry.Message, "ViewChanged") {
-				viewChangeWG.Done()
+	// Advance the time of the leader until it notices a change in the reachability of the quorum
+	now := time.Now()
+	waitForQuorumEvent := func(expected bool) {
+		for {
+			now = now.Add(6 * time.Second)
+			select {
+			case reachable := <-nodes[0].quorumEvents:
+				assert.Equal(t, expected, reachable)
+				return
+			case nodes[0].heartbeatTime <- now:
+				time.Sleep(10 * time.Millisecond)
 			}
-			return nil
-		})).Sugar()
+		}
 	}
 
-	startNodes(nodes, network)
-
-	nodes[0].Disconnect() // leader in partition
-
-	// Accelerate the time until a view change because of heartbeat timeout
-	var counter uint64
-	accelerateTime(nodes, done, true, false, &counter)
-
-	viewChangeWG.Wait()
-	close(done)
+	nodes[2].Disconnect()
+	nodes[3].Disconnect()
+	waitForQuorumEvent(false)
+	assert.False(t, nodes[0].Consensus.QuorumReachable())
 
-	for i := 1; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "1", ClientID: "alice"}) // submit to other nodes
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+	select {
+	case <-nodes[0].Delivered:
+		t.Fatalf("Request was delivered while a quorum is unreachable")
+	case <-time.After(time.Second):
 	}
+	// The leader did not even propose
+	assert.Equal(t, int32(1), atomic.LoadInt32(&prePrepares))
 
-	data := make([]*AppRecord, 0)
-	for i := 1; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-2; i++ {
-		assert.Equal(t, data[i], data[i+1])
+	nodes[2].Connect()
+	nodes[3].Connect()
+	waitForQuorumEvent(true)
+	assert.True(t, nodes[0].Consensus.QuorumReachable())
+
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
 	}
 }
 
-func TestMultiViewChangeWithNoRequestsTimeout(t *testing.T) {
+func TestUnreachablePeers(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -508,63 +544,93 @@ func TestMultiViewChangeWithNoRequestsTimeout(t *testing.T) {
 	assert.NoErrorf(t, err, "generate temporary test dir")
 	defer os.RemoveAll(testDir)
 
-	numberOfNodes := 7
+	numberOfNodes := 4
 	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		if i == 1 {
+			n.heartbeatTime = make(chan time.Time, 1)
+			n.heartbeatTime <- time.Now()
+			n.Setup()
+		}
+		n.Consensus.Config.DetectQuorumLoss = true
 		nodes = append(nodes, n)
 	}
+	startNodes(nodes, network)
 
-	start := time.Now()
-	for _, n := range nodes {
-		n.heartbeatTime = make(chan time.Time, 1)
-		n.heartbeatTime <- start
-		n.viewChangeTime = make(chan time.Time, 1)
-		n.viewChangeTime <- start
-		n.Setup()
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
 	}
+	assert.Empty(t, nodes[0].Consensus.UnreachablePeers())
 
-	// wait for the new leader to finish the view change before submitting
-	done := make(chan struct{})
-	viewChangeWG := sync.WaitGroup{}
-	viewChangeWG.Add(5)
-
-	ns := network.Nodes()
-	for _, n := range ns {
-		baseLogger := n.app.Consensus.Logger.(*zap.SugaredLogger).Desugar()
-		n.app.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if strings.Contains(entry.Message, "ViewChanged") {
-				viewChangeWG.Done()
+	// Advance the time of the leader until it considers the expected nodes unreachable
+	now := time.Now()
+	waitForUnreachable := func(expected []uint64) {
+		for {
+			now = now.Add(time.Second)
+			nodes[0].heartbeatTime <- now
+			time.Sleep(10 * time.Millisecond)
+			if unreachable := nodes[0].Consensus.UnreachablePeers(); len(unreachable) == len(expected) {
+				assert.Equal(t, expected, unreachable)
+				return
 			}
-			return nil
-		})).Sugar()
+		}
 	}
 
-	startNodes(nodes, network)
+	nodes[2].Disconnect()
+	nodes[3].Disconnect()
+	waitForUnreachable([]uint64{3, 4})
 
-	nodes[0].Disconnect() // leader in partition
-	nodes[1].Disconnect() // next leader in partition
+	nodes[2].Connect()
+	nodes[3].Connect()
+	waitForUnreachable(nil)
+}
 
-	var counter uint64
-	accelerateTime(nodes, done, true, true, &counter)
-	viewChangeWG.Wait()
-	close(done)
+func TestDecisionsStream(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	for i := 2; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "1", ClientID: "alice"}) // submit to other nodes
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.DecisionsBufferSize = 10
+		nodes = append(nodes, n)
 	}
+	assert.Nil(t, nodes[0].Consensus.Decisions())
+	startNodes(nodes, network)
 
-	data := make([]*AppRecord, 0)
-	for i := 2; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
+	for i := 1; i <= 3; i++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+		for j := 0; j < numberOfNodes; j++ {
+			<-nodes[j].Delivered
+		}
 	}
-	for i := 0; i < numberOfNodes-3; i++ {
-		assert.Equal(t, data[i], data[i+1])
+
+	// Every node streams the decisions in order
+	for _, n := range nodes {
+		decisions := n.Consensus.Decisions()
+		for i := 1; i <= 3; i++ {
+			decision := <-decisions
+			md := &smartbftprotos.ViewMetadata{}
+			assert.NoError(t, proto.Unmarshal(decision.Proposal.Metadata, md))
+			assert.Equal(t, uint64(i), md.LatestSequence)
+			requests := n.RequestsFromProposal(decision.Proposal)
+			assert.Len(t, requests, 1)
+			assert.Equal(t, fmt.Sprintf("%d", i), requests[0].ID)
+			assert.NotEmpty(t, decision.Signatures)
+		}
+		assert.Len(t, decisions, 0)
 	}
 }
 
-func TestCatchingUpWithViewChange(t *testing.T) {
+func TestDeliveredSignaturesSorted(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -579,61 +645,210 @@ func TestCatchingUpWithViewChange(t *testing.T) {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
 		nodes = append(nodes, n)
 	}
+	startNodes(nodes, network)
 
-	start := time.Now()
-	for _, n := range nodes {
-		n.viewChangeTime = make(chan time.Time, 1)
-		n.viewChangeTime <- start
+	// With the last node disconnected, the rest of the nodes collect the same signatures
+	nodes[3].Disconnect()
+
+	for i := 1; i <= 3; i++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+		signers := make([][]uint64, 0)
+		for j := 0; j < numberOfNodes-1; j++ {
+			<-nodes[j].Delivered
+			nodes[j].lock.Lock()
+			var ids []uint64
+			for _, sig := range nodes[j].lastDecision.Signatures {
+				ids = append(ids, sig.ID)
+			}
+			nodes[j].lock.Unlock()
+			signers = append(signers, ids)
+		}
+		assert.Equal(t, []uint64{1, 2, 3}, signers[0])
+		for j := 1; j < numberOfNodes-1; j++ {
+			assert.Equal(t, signers[0], signers[j])
+		}
 	}
+}
 
-	done := make(chan struct{})
-	viewChangeFinishWG := sync.WaitGroup{}
-	viewChangeFinishWG.Add(1)
-	viewChangeFinishOnce := sync.Once{}
-	baseLogger := nodes[3].logger.Desugar()
-	nodes[3].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "ViewChanged") {
-			viewChangeFinishOnce.Do(func() {
-				viewChangeFinishWG.Done()
-			})
+func TestWaitForSequence(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	// Nothing was delivered yet
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, nodes[3].Consensus.WaitForSequence(ctx, 1))
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- nodes[3].Consensus.WaitForSequence(context.Background(), 3)
+	}()
+
+	for i := 1; i <= 3; i++ {
+		select {
+		case err := <-waited:
+			t.Fatalf("Waiting for sequence 3 returned %v before delivering sequence %d", err, i)
+		default:
 		}
-		return nil
-	})).Sugar()
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+		for j := 0; j < numberOfNodes; j++ {
+			<-nodes[j].Delivered
+		}
+	}
 
-	for _, n := range nodes {
-		n.Setup()
+	assert.NoError(t, <-waited)
+	// An already delivered sequence returns immediately
+	assert.NoError(t, nodes[3].Consensus.WaitForSequence(context.Background(), 2))
+}
+
+func TestGetDecision(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.DecisionHistorySize = 2
+		nodes = append(nodes, n)
 	}
+	startNodes(nodes, network)
+
+	var lastDecision types.Decision
+	for i := 1; i <= 3; i++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+		for j := 0; j < numberOfNodes; j++ {
+			<-nodes[j].Delivered
+		}
+		nodes[1].lock.Lock()
+		lastDecision = *nodes[1].lastDecision
+		nodes[1].lock.Unlock()
+	}
+
+	// Only the last two decisions are retained
+	_, exists := nodes[1].Consensus.GetDecision(1)
+	assert.False(t, exists)
+	decision, exists := nodes[1].Consensus.GetDecision(2)
+	assert.True(t, exists)
+	requests := nodes[1].RequestsFromProposal(decision.Proposal)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "2", requests[0].ID)
+	decision, exists = nodes[1].Consensus.GetDecision(3)
+	assert.True(t, exists)
+	assert.Equal(t, lastDecision, *decision)
+	_, exists = nodes[1].Consensus.GetDecision(4)
+	assert.False(t, exists)
+}
+
+func TestGenesisLeader(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.GenesisLeader = 3
+		nodes = append(nodes, n)
+	}
 	startNodes(nodes, network)
 
-	nodes[3].Disconnect() // will need to catch up
+	for _, n := range nodes {
+		assert.Equal(t, uint64(3), n.Consensus.GetLeaderID())
+	}
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for _, n := range nodes {
+		record := <-n.Delivered
+		md := &smartbftprotos.ViewMetadata{}
+		assert.NoError(t, proto.Unmarshal(record.Metadata, md))
+		assert.Equal(t, uint64(1), md.LatestSequence)
+		leader, exists := n.Consensus.LeaderForView(md.ViewId)
+		assert.True(t, exists)
+		assert.Equal(t, uint64(3), leader)
+	}
+}
+
+func TestAfterDecisionLeaderInPartition(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
 
 	nodes[0].Submit(Request{ID: "1", ClientID: "alice"}) // submit to leader
 
 	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
 	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+
+	data = make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
 		d := <-nodes[i].Delivered
 		data = append(data, d)
 	}
-	for i := 0; i < numberOfNodes-2; i++ {
+	for i := 0; i < numberOfNodes-1; i++ {
 		assert.Equal(t, data[i], data[i+1])
 	}
 
-	nodes[3].Connect()
 	nodes[0].Disconnect() // leader in partition
 
 	for i := 1; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "2", ClientID: "alice"}) // submit to other nodes
+		nodes[i].Submit(Request{ID: "3", ClientID: "alice"}) // submit to other nodes
 	}
 
-	var counter uint64
-	accelerateTime(nodes, done, false, true, &counter)
-
-	data3 := <-nodes[3].Delivered // from catch up
-	assert.Equal(t, data[0], data3)
+	data = make([]*AppRecord, 0)
+	for i := 1; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-2; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
 
-	viewChangeFinishWG.Wait()
-	close(done)
+	for i := 1; i < numberOfNodes; i++ {
+		nodes[i].Submit(Request{ID: "4", ClientID: "alice"}) // submit to other nodes
+	}
 
 	data = make([]*AppRecord, 0)
 	for i := 1; i < numberOfNodes; i++ {
@@ -645,8 +860,9 @@ func TestCatchingUpWithViewChange(t *testing.T) {
 	}
 }
 
-func TestLeaderCatchingUpAfterViewChange(t *testing.T) {
+func TestLeaderInPartitionWithHealing(t *testing.T) {
 	t.Parallel()
+
 	network := NewNetwork()
 	defer network.Shutdown()
 
@@ -673,11 +889,24 @@ func TestLeaderCatchingUpAfterViewChange(t *testing.T) {
 		assert.Equal(t, data[i], data[i+1])
 	}
 
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+
+	data = make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
 	nodes[0].Disconnect() // leader in partition
+	t.Log("Disconnected n0")
 
 	for i := 1; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "2", ClientID: "alice"}) // submit to other nodes
+		nodes[i].Submit(Request{ID: "3", ClientID: "alice"}) // submit to other nodes
 	}
+
 	data = make([]*AppRecord, 0)
 	for i := 1; i < numberOfNodes; i++ {
 		d := <-nodes[i].Delivered
@@ -687,23 +916,16 @@ func TestLeaderCatchingUpAfterViewChange(t *testing.T) {
 		assert.Equal(t, data[i], data[i+1])
 	}
 
-	nodes[0].Connect() // old leader woke up
+	assert.Len(t, nodes[0].Delivered, 0) // n0 did not receive it
 
-	// We create new batches until it catches up
-	for reqID := 3; reqID < 100; reqID++ {
-		nodes[1].Submit(Request{ID: fmt.Sprintf("%d", reqID), ClientID: "alice"})
-		nodes[2].Submit(Request{ID: fmt.Sprintf("%d", reqID), ClientID: "alice"})
-		<-nodes[1].Delivered // Wait for new leader to commit
-		<-nodes[2].Delivered // Wait for follower to commit
-		caughtUp := waitForCatchup(reqID, nodes[0].Delivered)
-		if caughtUp {
-			return
-		}
-	}
-	t.Fatalf("Didn't catch up")
+	nodes[0].Connect() // partition heals, leader should eventually sync, become a follower, and deliver
+	t.Log("Connected n0")
+
+	data0 := <-nodes[0].Delivered
+	assert.Equal(t, data[0], data0)
 }
 
-func TestRestartAfterViewChangeAndRestoreNewView(t *testing.T) {
+func TestMultiLeadersPartition(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -712,7 +934,7 @@ func TestRestartAfterViewChangeAndRestoreNewView(t *testing.T) {
 	assert.NoErrorf(t, err, "generate temporary test dir")
 	defer os.RemoveAll(testDir)
 
-	numberOfNodes := 4
+	numberOfNodes := 7
 	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
@@ -721,47 +943,95 @@ func TestRestartAfterViewChangeAndRestoreNewView(t *testing.T) {
 
 	start := time.Now()
 	for _, n := range nodes {
-		n.heartbeatTime = make(chan time.Time, 1)
-		n.heartbeatTime <- start
+		n.viewChangeTime = make(chan time.Time, 1)
+		n.viewChangeTime <- start
 		n.Setup()
 	}
-
-	// wait for a view change to occur
-	done := make(chan struct{})
-	viewChangeWG := sync.WaitGroup{}
-	viewChangeWG.Add(2)
-	baseLogger1 := nodes[1].Consensus.Logger.(*zap.SugaredLogger).Desugar()
-	nodes[1].Consensus.Logger = baseLogger1.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "ViewChanged") {
-			viewChangeWG.Done()
-		}
-		return nil
-	})).Sugar()
-	baseLogger3 := nodes[3].Consensus.Logger.(*zap.SugaredLogger).Desugar()
-	nodes[3].Consensus.Logger = baseLogger3.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "ViewChanged") {
-			viewChangeWG.Done()
-		}
-		return nil
-	})).Sugar()
-
+	assert.Equal(t, uint64(0), nodes[0].Consensus.GetLeaderID())
 	startNodes(nodes, network)
+	assert.Equal(t, uint64(1), nodes[0].Consensus.GetLeaderID())
 
-	nodes[0].Disconnect()
+	nodes[0].Disconnect() // leader in partition
+	nodes[1].Disconnect() // next leader in partition
 
-	var counter uint64
-	accelerateTime(nodes, done, true, false, &counter)
+	for i := 2; i < numberOfNodes; i++ {
+		nodes[i].Submit(Request{ID: "1", ClientID: "alice"}) // submit to other nodes
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	var counter uint64
+	accelerateTime(nodes, done, false, true, &counter)
+
+	data := make([]*AppRecord, 0)
+	for i := 2; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-3; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	lID := nodes[2].Consensus.GetLeaderID()
+	assert.LessOrEqual(t, uint64(3), lID)
+	for i := 3; i < numberOfNodes; i++ {
+		assert.Equal(t, lID, nodes[i].Consensus.GetLeaderID())
+	}
+}
+
+func TestHeartbeatTimeoutCausesViewChange(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+
+	start := time.Now()
+	for _, n := range nodes {
+		n.heartbeatTime = make(chan time.Time, 1)
+		n.heartbeatTime <- start
+		n.Setup()
+	}
+
+	// wait for the new leader to finish the view change before submitting
+	done := make(chan struct{})
+	viewChangeWG := sync.WaitGroup{}
+	viewChangeWG.Add(numberOfNodes - 1)
+	for _, n := range nodes {
+		baseLogger := n.Consensus.Logger.(*zap.SugaredLogger).Desugar()
+		n.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if strings.Contains(entry.Message, "ViewChanged") {
+				viewChangeWG.Done()
+			}
+			return nil
+		})).Sugar()
+	}
+
+	startNodes(nodes, network)
+
+	nodes[0].Disconnect() // leader in partition
+
+	// Accelerate the time until a view change because of heartbeat timeout
+	var counter uint64
+	accelerateTime(nodes, done, true, false, &counter)
 
 	viewChangeWG.Wait()
 	close(done)
 
-	// restart new leader and a follower, they will restore from new view
-	nodes[1].Restart()
-	nodes[3].Restart()
-
 	for i := 1; i < numberOfNodes; i++ {
 		nodes[i].Submit(Request{ID: "1", ClientID: "alice"}) // submit to other nodes
 	}
+
 	data := make([]*AppRecord, 0)
 	for i := 1; i < numberOfNodes; i++ {
 		d := <-nodes[i].Delivered
@@ -772,7 +1042,7 @@ func TestRestartAfterViewChangeAndRestoreNewView(t *testing.T) {
 	}
 }
 
-func TestRestoringViewChange(t *testing.T) {
+func TestMultiViewChangeWithNoRequestsTimeout(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -794,32 +1064,23 @@ func TestRestoringViewChange(t *testing.T) {
 		n.heartbeatTime <- start
 		n.viewChangeTime = make(chan time.Time, 1)
 		n.viewChangeTime <- start
+		n.Setup()
 	}
 
+	// wait for the new leader to finish the view change before submitting
 	done := make(chan struct{})
-	viewChangeFinishWG := sync.WaitGroup{}
-	viewChangeFinishWG.Add(1)
-	viewChangeFinishOnce := sync.Once{}
 	viewChangeWG := sync.WaitGroup{}
-	viewChangeWG.Add(1)
-	viewChangeOnce := sync.Once{}
-	baseLogger := nodes[6].logger.Desugar()
-	nodes[6].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "Node 7 sent view data msg") {
-			viewChangeOnce.Do(func() {
-				viewChangeWG.Done()
-			})
-		}
-		if strings.Contains(entry.Message, "ViewChanged") {
-			viewChangeFinishOnce.Do(func() {
-				viewChangeFinishWG.Done()
-			})
-		}
-		return nil
-	})).Sugar()
+	viewChangeWG.Add(5)
 
-	for _, n := range nodes {
-		n.Setup()
+	ns := network.Nodes()
+	for _, n := range ns {
+		baseLogger := n.app.Consensus.Logger.(*zap.SugaredLogger).Desugar()
+		n.app.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if strings.Contains(entry.Message, "ViewChanged") {
+				viewChangeWG.Done()
+			}
+			return nil
+		})).Sugar()
 	}
 
 	startNodes(nodes, network)
@@ -829,17 +1090,11 @@ func TestRestoringViewChange(t *testing.T) {
 
 	var counter uint64
 	accelerateTime(nodes, done, true, true, &counter)
-
 	viewChangeWG.Wait()
-	nodes[6].Disconnect()
-	nodes[6].Restart()
-	nodes[6].Connect()
-
-	viewChangeFinishWG.Wait()
 	close(done)
 
 	for i := 2; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "1", ClientID: "alice"})
+		nodes[i].Submit(Request{ID: "1", ClientID: "alice"}) // submit to other nodes
 	}
 
 	data := make([]*AppRecord, 0)
@@ -852,7 +1107,7 @@ func TestRestoringViewChange(t *testing.T) {
 	}
 }
 
-func TestLeaderForwarding(t *testing.T) {
+func TestCatchingUpWithViewChange(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -867,30 +1122,73 @@ func TestLeaderForwarding(t *testing.T) {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
 		nodes = append(nodes, n)
 	}
+
+	start := time.Now()
+	for _, n := range nodes {
+		n.viewChangeTime = make(chan time.Time, 1)
+		n.viewChangeTime <- start
+	}
+
+	done := make(chan struct{})
+	viewChangeFinishWG := sync.WaitGroup{}
+	viewChangeFinishWG.Add(1)
+	viewChangeFinishOnce := sync.Once{}
+	baseLogger := nodes[3].logger.Desugar()
+	nodes[3].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "ViewChanged") {
+			viewChangeFinishOnce.Do(func() {
+				viewChangeFinishWG.Done()
+			})
+		}
+		return nil
+	})).Sugar()
+
+	for _, n := range nodes {
+		n.Setup()
+	}
+
 	startNodes(nodes, network)
 
-	nodes[1].Submit(Request{ID: "1", ClientID: "alice"})
-	nodes[2].Submit(Request{ID: "2", ClientID: "bob"})
-	nodes[3].Submit(Request{ID: "3", ClientID: "carol"})
+	nodes[3].Disconnect() // will need to catch up
 
-	numBatchesCreated := countCommittedBatches(nodes[0])
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"}) // submit to leader
 
-	committedBatches := make([][]AppRecord, 3)
-	for nodeIndex, n := range []*App{nodes[1], nodes[2], nodes[3]} {
-		committedBatches = append(committedBatches, make([]AppRecord, numBatchesCreated))
-		for i := 0; i < numBatchesCreated; i++ {
-			record := <-n.Delivered
-			committedBatches[nodeIndex] = append(committedBatches[nodeIndex], *record)
-		}
+	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes-1; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-2; i++ {
+		assert.Equal(t, data[i], data[i+1])
 	}
 
-	assert.Equal(t, committedBatches[0], committedBatches[1])
-	assert.Equal(t, committedBatches[0], committedBatches[2])
+	nodes[3].Connect()
+	nodes[0].Disconnect() // leader in partition
+
+	for i := 1; i < numberOfNodes; i++ {
+		nodes[i].Submit(Request{ID: "2", ClientID: "alice"}) // submit to other nodes
+	}
+
+	var counter uint64
+	accelerateTime(nodes, done, false, true, &counter)
+
+	data3 := <-nodes[3].Delivered // from catch up
+	assert.Equal(t, data[0], data3)
+
+	viewChangeFinishWG.Wait()
+	close(done)
+
+	data = make([]*AppRecord, 0)
+	for i := 1; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-2; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
 }
 
-func TestLeaderExclusion(t *testing.T) {
-	// Scenario: The leader doesn't send messages to n3,
-	// but it should detect this and sync.
+func TestLeaderCatchingUpAfterViewChange(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -905,16 +1203,42 @@ func TestLeaderExclusion(t *testing.T) {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
 		nodes = append(nodes, n)
 	}
-
 	startNodes(nodes, network)
 
-	nodes[0].DisconnectFrom(3)
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"}) // submit to leader
 
-	// We create new batches until the disconnected node catches up the quorum.
-	for reqID := 1; reqID < 100; reqID++ {
+	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	nodes[0].Disconnect() // leader in partition
+
+	for i := 1; i < numberOfNodes; i++ {
+		nodes[i].Submit(Request{ID: "2", ClientID: "alice"}) // submit to other nodes
+	}
+	data = make([]*AppRecord, 0)
+	for i := 1; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-2; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	nodes[0].Connect() // old leader woke up
+
+	// We create new batches until it catches up
+	for reqID := 3; reqID < 100; reqID++ {
 		nodes[1].Submit(Request{ID: fmt.Sprintf("%d", reqID), ClientID: "alice"})
-		<-nodes[1].Delivered // Wait for follower to commit
-		caughtUp := waitForCatchup(reqID, nodes[3].Delivered)
+		nodes[2].Submit(Request{ID: fmt.Sprintf("%d", reqID), ClientID: "alice"})
+		<-nodes[1].Delivered // Wait for new leader to commit
+		<-nodes[2].Delivered // Wait for follower to commit
+		caughtUp := waitForCatchup(reqID, nodes[0].Delivered)
 		if caughtUp {
 			return
 		}
@@ -922,7 +1246,7 @@ func TestLeaderExclusion(t *testing.T) {
 	t.Fatalf("Didn't catch up")
 }
 
-func TestCatchingUpWithSyncAssisted(t *testing.T) {
+func TestRestartAfterViewChangeAndRestoreNewView(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -937,123 +1261,61 @@ func TestCatchingUpWithSyncAssisted(t *testing.T) {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
 		nodes = append(nodes, n)
 	}
-	startNodes(nodes, network)
 
-	nodes[3].Disconnect() // will need to catch up
-
-	for i := 1; i <= 10; i++ {
-		for j := 0; j <= 2; j++ {
-			nodes[j].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
-		}
-		for j := 0; j <= 2; j++ {
-			<-nodes[j].Delivered
-		}
+	start := time.Now()
+	for _, n := range nodes {
+		n.heartbeatTime = make(chan time.Time, 1)
+		n.heartbeatTime <- start
+		n.Setup()
 	}
 
-	nodes[3].Connect()
-
-	// We create new batches until it catches up the quorum.
-	for reqID := 11; reqID < 100; reqID++ {
-		for j := 0; j <= 2; j++ {
-			nodes[j].Submit(Request{ID: fmt.Sprintf("%d", reqID), ClientID: "alice"})
-		}
-		for j := 0; j <= 2; j++ {
-			<-nodes[j].Delivered
-		}
-		caughtUp := waitForCatchup(reqID, nodes[3].Delivered)
-		if caughtUp {
-			return
+	// wait for a view change to occur
+	done := make(chan struct{})
+	viewChangeWG := sync.WaitGroup{}
+	viewChangeWG.Add(2)
+	baseLogger1 := nodes[1].Consensus.Logger.(*zap.SugaredLogger).Desugar()
+	nodes[1].Consensus.Logger = baseLogger1.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "ViewChanged") {
+			viewChangeWG.Done()
 		}
-	}
-	t.Fatalf("Didn't catch up")
-}
-
-func TestCatchingUpWithSyncAutonomous(t *testing.T) {
-	t.Parallel()
-	network := NewNetwork()
-	defer network.Shutdown()
-
-	testDir, err := os.MkdirTemp("", t.Name())
-	assert.NoErrorf(t, err, "generate temporary test dir")
-	defer os.RemoveAll(testDir)
-
-	numberOfNodes := 4
-	nodes := make([]*App, 0)
-	for i := 1; i <= numberOfNodes; i++ {
-		n := newNode(uint64(i), network, t.Name(), testDir, true, 3)
-		nodes = append(nodes, n)
-	}
-
-	var detectedSequenceGap uint32
-
-	baseLogger := nodes[3].Consensus.Logger.(*zap.SugaredLogger).Desugar()
-	nodes[3].Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "Leader's sequence is 10 and ours is 1") {
-			atomic.StoreUint32(&detectedSequenceGap, 1)
+		return nil
+	})).Sugar()
+	baseLogger3 := nodes[3].Consensus.Logger.(*zap.SugaredLogger).Desugar()
+	nodes[3].Consensus.Logger = baseLogger3.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "ViewChanged") {
+			viewChangeWG.Done()
 		}
 		return nil
 	})).Sugar()
 
-	start := time.Now()
-	nodes[0].heartbeatTime = make(chan time.Time, 1)
-	nodes[0].heartbeatTime <- start
-	nodes[3].heartbeatTime = make(chan time.Time, 1)
-	nodes[3].heartbeatTime <- start
-	nodes[3].viewChangeTime = make(chan time.Time, 1)
-	nodes[3].viewChangeTime <- start
-	nodes[0].Setup()
-	nodes[3].Setup()
-
 	startNodes(nodes, network)
 
-	nodes[3].Disconnect() // will need to catch up
+	nodes[0].Disconnect()
 
-	for i := 1; i <= 5; i++ {
-		for j := 0; j <= 2; j++ {
-			nodes[j].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
-		}
-		for j := 0; j <= 2; j++ {
-			<-nodes[j].Delivered
-		}
-	}
+	var counter uint64
+	accelerateTime(nodes, done, true, false, &counter)
 
-	nodes[3].Connect()
+	viewChangeWG.Wait()
+	close(done)
 
-	done := make(chan struct{})
-	// Accelerate the time for n3 so it will suspect the leader and view change.
-	go func() {
-		var i int
-		for {
-			i++
-			select {
-			case <-done:
-				return
-			case <-time.After(time.Millisecond * 100):
-				nodes[0].heartbeatTime <- time.Now().Add(time.Second * time.Duration(10*i))
-				nodes[3].heartbeatTime <- time.Now().Add(time.Second * time.Duration(10*i))
-				nodes[3].viewChangeTime <- time.Now().Add(time.Minute * time.Duration(10*i))
-			}
-		}
-	}()
+	// restart new leader and a follower, they will restore from new view
+	nodes[1].Restart()
+	nodes[3].Restart()
 
-	for i := 1; i <= 5; i++ {
-		select {
-		case <-nodes[3].Delivered:
-		case <-time.After(time.Second * 10):
-			t.Fatalf("Didn't catch up within a timely period")
-		}
+	for i := 1; i < numberOfNodes; i++ {
+		nodes[i].Submit(Request{ID: "1", ClientID: "alice"}) // submit to other nodes
+	}
+	data := make([]*AppRecord, 0)
+	for i := 1; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-2; i++ {
+		assert.Equal(t, data[i], data[i+1])
 	}
-
-	close(done)
-	assert.Equal(t, uint32(0), atomic.LoadUint32(&detectedSequenceGap))
 }
 
-func TestFollowerStateTransfer(t *testing.T) {
-	// Scenario: the leader (n0) is disconnected and so there is a view change
-	// a follower (n6) is also disconnected and misses the view change
-	// after the follower reconnects and gets a view change timeout is calls sync
-	// where it collects state transfer requests and sees that there was a view change
-
+func TestRestoringViewChange(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -1077,22 +1339,24 @@ func TestFollowerStateTransfer(t *testing.T) {
 		n.viewChangeTime <- start
 	}
 
-	syncedWG := sync.WaitGroup{}
-	syncedWG.Add(1)
-	baseLogger6 := nodes[6].logger.Desugar()
-	nodes[6].logger = baseLogger6.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "collected state") {
-			syncedWG.Done()
-		}
-		return nil
-	})).Sugar()
-
+	done := make(chan struct{})
+	viewChangeFinishWG := sync.WaitGroup{}
+	viewChangeFinishWG.Add(1)
+	viewChangeFinishOnce := sync.Once{}
 	viewChangeWG := sync.WaitGroup{}
 	viewChangeWG.Add(1)
-	baseLogger1 := nodes[1].logger.Desugar()
-	nodes[1].logger = baseLogger1.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+	viewChangeOnce := sync.Once{}
+	baseLogger := nodes[6].logger.Desugar()
+	nodes[6].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "Node 7 sent view data msg") {
+			viewChangeOnce.Do(func() {
+				viewChangeWG.Done()
+			})
+		}
 		if strings.Contains(entry.Message, "ViewChanged") {
-			viewChangeWG.Done()
+			viewChangeFinishOnce.Do(func() {
+				viewChangeFinishWG.Done()
+			})
 		}
 		return nil
 	})).Sugar()
@@ -1104,17 +1368,17 @@ func TestFollowerStateTransfer(t *testing.T) {
 	startNodes(nodes, network)
 
 	nodes[0].Disconnect() // leader in partition
-	nodes[6].Disconnect() // follower in partition
-
-	// Accelerate the time until a view change
-	done := make(chan struct{})
+	nodes[1].Disconnect() // next leader in partition
 
 	var counter uint64
 	accelerateTime(nodes, done, true, true, &counter)
 
 	viewChangeWG.Wait()
+	nodes[6].Disconnect()
+	nodes[6].Restart()
 	nodes[6].Connect()
-	syncedWG.Wait()
+
+	viewChangeFinishWG.Wait()
 	close(done)
 
 	for i := 2; i < numberOfNodes; i++ {
@@ -1131,131 +1395,88 @@ func TestFollowerStateTransfer(t *testing.T) {
 	}
 }
 
-func TestLeaderModifiesPreprepare(t *testing.T) {
-	for _, test := range []struct {
-		description  string
-		mutatingFunc func(target uint64, m *smartbftprotos.Message)
-	}{
-		{
-			description: "wrong view",
-			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
-				if m.GetPrePrepare() == nil {
-					return
-				}
-				m.GetPrePrepare().View = 1
-			},
-		},
-		{
-			description: "conflicting proposals",
-			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
-				if m.GetPrePrepare() == nil {
-					return
-				}
-				incReq := Request{ID: fmt.Sprintf("%d", target), ClientID: "alice"}
-				incData := batch{
-					Requests: [][]byte{incReq.ToBytes()},
-				}
-				m.GetPrePrepare().Proposal.Payload = incData.toBytes()
-			},
-		},
-		{
-			description: "next sequence",
-			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
-				if m.GetPrePrepare() == nil {
-					return
-				}
-				m.GetPrePrepare().Seq = 2
-			},
-		},
-		{
-			description: "wrong verification sequence",
-			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
-				if m.GetPrePrepare() == nil {
-					return
-				}
-				m.GetPrePrepare().Proposal.VerificationSequence = 3
-			},
-		},
-		{
-			description: "wrong number of decisions",
-			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
-				if m.GetPrePrepare() == nil {
-					return
-				}
-				m.GetPrePrepare().Proposal.Metadata = bft.MarshalOrPanic(&smartbftprotos.ViewMetadata{
-					DecisionsInView: 1, // instead of 0
-					LatestSequence:  1,
-					ViewId:          0,
-				})
-			},
-		},
-	} {
-		test := test
-		t.Run(test.description, func(t *testing.T) {
-			t.Parallel()
-			network := NewNetwork()
-			defer network.Shutdown()
-
-			testDir, err := os.MkdirTemp("", test.description)
-			assert.NoErrorf(t, err, "generate temporary test dir")
-			defer os.RemoveAll(testDir)
+func TestLeaderForwarding(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-			numberOfNodes := 4
-			nodes := make([]*App, 0)
-			for i := 1; i <= numberOfNodes; i++ {
-				n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
-				nodes = append(nodes, n)
-			}
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-			once := sync.Once{}
-			viewChangeWG := sync.WaitGroup{}
-			viewChangeWG.Add(1)
-			baseLogger1 := nodes[1].logger.Desugar()
-			nodes[1].logger = baseLogger1.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-				if strings.Contains(entry.Message, "ViewChanged") {
-					once.Do(func() {
-						viewChangeWG.Done()
-					})
-				}
-				return nil
-			})).Sugar()
-			nodes[1].Setup()
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
 
-			startNodes(nodes, network)
+	nodes[1].Submit(Request{ID: "1", ClientID: "alice"})
+	nodes[2].Submit(Request{ID: "2", ClientID: "bob"})
+	nodes[3].Submit(Request{ID: "3", ClientID: "carol"})
 
-			for i := 2; i <= numberOfNodes; i++ {
-				nodes[0].MutateSend(uint64(i), test.mutatingFunc)
-			}
+	numBatchesCreated := countCommittedBatches(nodes[0])
 
-			for i := 0; i < numberOfNodes; i++ {
-				nodes[i].Submit(Request{ID: fmt.Sprintf("%d", 1), ClientID: "alice"})
-			}
+	committedBatches := make([][]AppRecord, 3)
+	for nodeIndex, n := range []*App{nodes[1], nodes[2], nodes[3]} {
+		committedBatches = append(committedBatches, make([]AppRecord, numBatchesCreated))
+		for i := 0; i < numBatchesCreated; i++ {
+			record := <-n.Delivered
+			committedBatches[nodeIndex] = append(committedBatches[nodeIndex], *record)
+		}
+	}
 
-			viewChangeWG.Wait()
+	assert.Equal(t, committedBatches[0], committedBatches[1])
+	assert.Equal(t, committedBatches[0], committedBatches[2])
+}
 
-			data := make([]*AppRecord, 0)
-			for i := 0; i < numberOfNodes; i++ {
-				d := <-nodes[i].Delivered
-				data = append(data, d)
-			}
-			for i := 0; i < numberOfNodes-1; i++ {
-				assert.Equal(t, data[i], data[i+1])
-			}
+func TestRequestLatency(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-			md := &smartbftprotos.ViewMetadata{}
-			if err := proto.Unmarshal(data[0].Metadata, md); err != nil {
-				assert.NoError(t, err)
-			}
-			assert.Equal(t, uint64(1), md.LatestSequence)
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-			for i := 2; i <= numberOfNodes; i++ {
-				nodes[0].ClearMutateSend(uint64(i))
-			}
-		})
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	for i := 1; i <= 10; i++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
 	}
+	// The request submitted to a follower reaches the leader once the follower forwards it
+	nodes[1].Submit(Request{ID: "1", ClientID: "bob"})
+
+	for i := 0; i < numberOfNodes; i++ {
+		for delivered := 0; delivered < 11; {
+			delivered += len((<-nodes[i].Delivered).Batch.Requests)
+		}
+	}
+
+	leaderStats := nodes[0].Consensus.LatencyStats()
+	assert.Equal(t, uint64(11), leaderStats.Count)
+	assert.Greater(t, leaderStats.P50, time.Duration(0))
+	assert.GreaterOrEqual(t, leaderStats.P99, leaderStats.P50)
+	assert.GreaterOrEqual(t, leaderStats.Max, leaderStats.P99)
+	assert.Less(t, leaderStats.Max, 10*time.Second)
+
+	// The follower measures the latency of the request submitted to it since it was submitted, not forwarded
+	followerStats := nodes[1].Consensus.LatencyStats()
+	assert.Equal(t, uint64(1), followerStats.Count)
+	assert.GreaterOrEqual(t, followerStats.Max, fastConfig.RequestForwardTimeout)
+	assert.GreaterOrEqual(t, followerStats.Max, leaderStats.Max)
+
+	assert.Zero(t, nodes[2].Consensus.LatencyStats().Count)
 }
 
-func TestLeaderCatchUpWithoutSync(t *testing.T) {
+func TestJoinAtNonZeroView(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -1264,68 +1485,150 @@ func TestLeaderCatchUpWithoutSync(t *testing.T) {
 	assert.NoErrorf(t, err, "generate temporary test dir")
 	defer os.RemoveAll(testDir)
 
-	numberOfNodes := 4
+	numberOfNodes := 7
 	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
-		n.Consensus.Config.SyncOnStart = false
+		// All nodes start from the metadata of view 3, which is led by node 4
+		n.latestMD = &smartbftprotos.ViewMetadata{ViewId: 3}
+		n.Setup()
 		nodes = append(nodes, n)
 	}
+	startNodes(nodes, network)
+	assert.Equal(t, uint64(4), nodes[0].Consensus.GetLeaderID())
 
-	restartWG := sync.WaitGroup{}
-	restartWG.Add(1)
+	nodes[3].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
 
-	restoredWG := sync.WaitGroup{}
-	restoredWG.Add(1)
+	// The last node goes down and loses its WAL
+	nodes[6].Consensus.Stop()
+	assert.NoError(t, os.RemoveAll(filepath.Join(testDir, "node7")))
 
-	var blockCommits uint32
-	nodes[0].LoseMessages(func(msg *smartbftprotos.Message) bool {
-		return msg.GetCommit() != nil && atomic.LoadUint32(&blockCommits) == 1
-	})
-	baseLogger := nodes[0].logger.Desugar()
-	nodes[0].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "Processed prepares for proposal with seq 1") {
-			restartWG.Done()
-		}
-		if strings.Contains(entry.Message, "Restored proposal with sequence 1") {
-			restoredWG.Done()
-		}
+	// The rest of the cluster moves to view 4 in its absence
+	nodes[3].Disconnect()
+	for _, i := range []int{0, 1, 2, 4, 5} {
+		nodes[i].Submit(Request{ID: "2", ClientID: "alice"})
+	}
+	for _, i := range []int{0, 1, 2, 4, 5} {
+		<-nodes[i].Delivered
+		assert.Equal(t, uint64(4), nodes[i].Consensus.CurrentView())
+	}
+
+	// The last node joins back from the metadata of view 3, and syncs to view 4 as it starts
+	nodes[6].Node.Lock()
+	nodes[6].Setup()
+	nodes[6].Consensus.Config.SyncOnStart = false
+	nodes[6].Consensus.Config.SyncOnStartAtNonZeroView = true
+	assert.NoError(t, nodes[6].Consensus.Start())
+	nodes[6].Node.Unlock()
+	assert.Equal(t, uint64(4), nodes[6].Consensus.CurrentView())
+	assert.Equal(t, uint64(5), nodes[6].Consensus.GetLeaderID())
+	<-nodes[6].Delivered
+
+	nodes[4].Submit(Request{ID: "3", ClientID: "alice"})
+	for _, i := range []int{0, 1, 2, 4, 5, 6} {
+		record := <-nodes[i].Delivered
+		md := &smartbftprotos.ViewMetadata{}
+		assert.NoError(t, proto.Unmarshal(record.Metadata, md))
+		assert.Equal(t, uint64(4), md.ViewId)
+		assert.Equal(t, uint64(4), nodes[i].Consensus.CurrentView())
+	}
+}
+
+// requestFetcher fetches requests from the pools of the other nodes, and records the requests it fetches.
+// Fetching from the node withholdingID blocks until withheld is closed.
+type requestFetcher struct {
+	nodes         []*App
+	lock          sync.Mutex
+	fetched       []types.RequestInfo
+	withholdingID uint64
+	withheld      chan struct{}
+}
+
+func (rf *requestFetcher) FetchRequests(targetID uint64, requests []types.RequestInfo) [][]byte {
+	rf.lock.Lock()
+	rf.fetched = append(rf.fetched, requests...)
+	rf.lock.Unlock()
+	if targetID == rf.withholdingID {
+		<-rf.withheld
 		return nil
-	})).Sugar()
-	nodes[0].Setup()
-	atomic.StoreUint32(&blockCommits, 1)
+	}
+	return rf.nodes[targetID-1].Consensus.PooledRequests(requests)
+}
 
-	startNodes(nodes, network)
+func (rf *requestFetcher) fetchedRequests() []types.RequestInfo {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	return append([]types.RequestInfo(nil), rf.fetched...)
+}
 
-	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+func TestProposeRequestDigests(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	restartWG.Wait()
-	nodes[0].RestartSync(false)
-	atomic.StoreUint32(&blockCommits, 0)
-	restoredWG.Wait()
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	data := make([]*AppRecord, 0)
-	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	fetcher := &requestFetcher{}
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.ProposeRequestDigests = true
+		n.Consensus.Config.RequestFetchTimeout = 5 * time.Second
+		n.Consensus.PayloadCompactor = n
+		n.Consensus.RequestFetcher = fetcher
+		nodes = append(nodes, n)
 	}
+	fetcher.nodes = nodes
 
-	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+	var sentPayloads [][]byte
+	var sentPayloadsLock sync.Mutex
+	nodes[0].MutateSend(2, func(_ uint64, m *smartbftprotos.Message) {
+		if pp := m.GetPrePrepare(); pp != nil {
+			sentPayloadsLock.Lock()
+			sentPayloads = append(sentPayloads, pp.Proposal.Payload)
+			sentPayloadsLock.Unlock()
+		}
+	})
+	startNodes(nodes, network)
 
-	data = make([]*AppRecord, 0)
+	// The followers have the request in their pool, and assemble the proposal from it
+	req1 := Request{ID: "1", ClientID: "alice"}
+	for i := numberOfNodes - 1; i >= 0; i-- {
+		nodes[i].Submit(req1)
+	}
 	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
+		record := <-nodes[i].Delivered
+		assert.Equal(t, [][]byte{req1.ToBytes()}, record.Batch.Requests)
 	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
+	assert.Empty(t, fetcher.fetchedRequests())
+
+	// The followers do not have the request, and fetch it from the leader
+	req2 := Request{ID: "2", ClientID: "alice"}
+	nodes[0].Submit(req2)
+	for i := 0; i < numberOfNodes; i++ {
+		record := <-nodes[i].Delivered
+		assert.Equal(t, [][]byte{req2.ToBytes()}, record.Batch.Requests)
+	}
+	assert.Contains(t, fetcher.fetchedRequests(), nodes[0].RequestID(req2.ToBytes()))
+	assert.NotContains(t, fetcher.fetchedRequests(), nodes[0].RequestID(req1.ToBytes()))
+
+	// The full payload was never sent
+	sentPayloadsLock.Lock()
+	defer sentPayloadsLock.Unlock()
+	assert.NotEmpty(t, sentPayloads)
+	for _, payload := range sentPayloads {
+		assert.NotEqual(t, batch{Requests: [][]byte{req1.ToBytes()}}.toBytes(), payload)
+		assert.NotEqual(t, batch{Requests: [][]byte{req2.ToBytes()}}.toBytes(), payload)
 	}
 }
 
-func TestLeaderProposeAfterRestartWithoutSync(t *testing.T) {
+func TestLeaderWithholdingRequestsIsReplaced(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -1336,60 +1639,63 @@ func TestLeaderProposeAfterRestartWithoutSync(t *testing.T) {
 
 	numberOfNodes := 4
 	nodes := make([]*App, 0)
+	fetcher := &requestFetcher{withholdingID: 1, withheld: make(chan struct{})}
+	defer close(fetcher.withheld)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
-		n.Consensus.Config.SyncOnStart = false
+		n.Consensus.Config.ProposeRequestDigests = true
+		n.Consensus.Config.RequestFetchTimeout = 500 * time.Millisecond
+		n.Consensus.PayloadCompactor = n
+		n.Consensus.RequestFetcher = fetcher
 		nodes = append(nodes, n)
 	}
+	fetcher.nodes = nodes
+	startNodes(nodes, network)
 
-	restartWG := sync.WaitGroup{}
-	restartWG.Add(1)
-
-	restoredWG := sync.WaitGroup{}
-	restoredWG.Add(1)
-
-	contViewWG := sync.WaitGroup{}
-	contViewWG.Add(2)
+	// The leader never hands out the request, so the followers give up fetching it
+	req := Request{ID: "1", ClientID: "alice"}
+	nodes[0].Submit(req)
+	assert.Eventually(t, func() bool {
+		return len(fetcher.fetchedRequests()) == numberOfNodes-1
+	}, 30*time.Second, 100*time.Millisecond)
 
-	var blockCommits uint32
-	nodes[0].LoseMessages(func(msg *smartbftprotos.Message) bool {
-		return msg.GetCommit() != nil && atomic.LoadUint32(&blockCommits) == 1
-	})
-	baseLogger := nodes[0].logger.Desugar()
-	nodes[0].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "Processed prepares for proposal with seq 1") {
-			restartWG.Done()
-		}
-		if strings.Contains(entry.Message, "Restored proposal with sequence 1") {
-			restoredWG.Done()
-		}
-		if strings.Contains(entry.Message, "processed commits for proposal with seq 1") {
-			contViewWG.Wait()
-		}
-		if strings.Contains(entry.Message, "GetMetadata with view 0, seq 1") {
-			contViewWG.Done()
-		}
-		if strings.Contains(entry.Message, "Not acquiring leader token when starting view with phase PREPARED") {
-			contViewWG.Done()
-		}
-		if strings.Contains(entry.Message, "Expected proposal sequence 2 but got 1") {
-			panic("Expected proposal sequence 2 but got 1")
+	// While the fetches are still pending, the followers complain about the leader and change the view
+	for i := 1; i < numberOfNodes; i++ {
+		nodes[i].Submit(req)
+	}
+	for i := 1; i < numberOfNodes; i++ {
+		select {
+		case record := <-nodes[i].Delivered:
+			assert.Equal(t, [][]byte{req.ToBytes()}, record.Batch.Requests)
+		case <-time.After(30 * time.Second):
+			t.Fatal("the request was not delivered after the view change")
 		}
-		return nil
-	})).Sugar()
-	nodes[0].Setup()
-	atomic.StoreUint32(&blockCommits, 1)
+		assert.LessOrEqual(t, uint64(1), nodes[i].Consensus.CurrentView())
+	}
+}
 
-	startNodes(nodes, network)
+func TestForwardingToNextLeader(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	restartWG.Wait()
-	nodes[0].RestartSync(false)
-	atomic.StoreUint32(&blockCommits, 0)
-	restoredWG.Wait()
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.ForwardToNextLeader = true
+		n.Consensus.Config.RequestComplainTimeout = time.Minute
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
 
-	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+	// Node 2 cannot reach the leader, so its request reaches the leader through the next leader candidate
+	nodes[1].DisconnectFrom(1)
+	nodes[1].Submit(Request{ID: "1", ClientID: "alice"})
 
 	data := make([]*AppRecord, 0)
 	for i := 0; i < numberOfNodes; i++ {
@@ -1399,23 +1705,10 @@ func TestLeaderProposeAfterRestartWithoutSync(t *testing.T) {
 	for i := 0; i < numberOfNodes-1; i++ {
 		assert.Equal(t, data[i], data[i+1])
 	}
-
-	data = make([]*AppRecord, 0)
-	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
-	}
+	assert.Equal(t, uint64(1), nodes[2].Consensus.GetLeaderID())
 }
 
-func TestGradualStart(t *testing.T) {
-	// Scenario: initially the network has only one node
-	// a transaction is submitted and committed with that node
-	// then another node is introduced, with a reset to the original node
-	// and another transaction is passed
-	// lastly a third node is added and another transaction is submitted
+func TestLeaderSelectsRequestsFromCandidates(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -1424,112 +1717,105 @@ func TestGradualStart(t *testing.T) {
 	assert.NoErrorf(t, err, "generate temporary test dir")
 	defer os.RemoveAll(testDir)
 
-	// start with only one node
-	n0 := newNode(uint64(1), network, t.Name(), testDir, true, 1)
-
-	if err := n0.Consensus.Start(); err != nil {
-		n0.logger.Panicf("Consensus returned an error : %v", err)
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
 	}
-	network.StartServe()
 
-	n0.Submit(Request{ID: fmt.Sprintf("%d", 1), ClientID: "alice"})
+	// The leader picks every second candidate, or the only candidate there is
+	leader := nodes[0]
+	leader.selectRequests = func(candidates [][]byte) (chosen, remainder [][]byte) {
+		if len(candidates) == 1 {
+			return candidates, nil
+		}
+		for i, req := range candidates {
+			if i%2 == 1 {
+				chosen = append(chosen, req)
+			} else {
+				remainder = append(remainder, req)
+			}
+		}
+		return chosen, remainder
+	}
+	leader.Consensus.CandidateAssembler = leader
+	leader.Consensus.Config.RequestBatchMaxCount = 4
+	leader.Consensus.Config.RequestBatchMaxInterval = 200 * time.Millisecond
+	leader.Consensus.Config.AssemblerCandidatesMaxCount = 10
+	startNodes(nodes, network)
 
-	d0 := <-n0.Delivered
-	md := &smartbftprotos.ViewMetadata{}
-	if err := proto.Unmarshal(d0.Metadata, md); err != nil {
-		assert.NoError(t, err)
+	for i := 1; i <= 4; i++ {
+		leader.Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
 	}
-	assert.Equal(t, uint64(0), md.ViewId)
-	assert.Equal(t, uint64(1), md.LatestSequence)
-	first := d0
 
-	network.StopServe()
+	for _, expected := range [][]string{{"2", "4"}, {"3"}, {"1"}} {
+		for i := 0; i < numberOfNodes; i++ {
+			d := <-nodes[i].Delivered
+			ids := make([]string, 0, len(d.Batch.Requests))
+			for _, req := range d.Batch.Requests {
+				ids = append(ids, requestFromBytes(req).ID)
+			}
+			assert.Equal(t, expected, ids)
+		}
+	}
+}
 
-	// add a second node
-	n1 := newNode(uint64(2), network, t.Name(), testDir, true, 1)
-	atomic.AddUint64(&n1.verificationSeq, 1)
+// reversingFilter reverses the order of the requests, and rejects the requests of mallory
+type reversingFilter struct{}
 
-	if err := n1.Consensus.Start(); err != nil {
-		n1.logger.Panicf("Consensus returned an error : %v", err)
-	}
-
-	atomic.AddUint64(&n0.verificationSeq, 1)
-	n0.Restart()
-
-	network.StartServe()
-
-	d1 := <-n1.Delivered
-	assert.Equal(t, d0, d1)
-
-	n0.Submit(Request{ID: fmt.Sprintf("%d", 2), ClientID: "alice"})
-
-	d0 = <-n0.Delivered
-	md = &smartbftprotos.ViewMetadata{}
-	if err := proto.Unmarshal(d0.Metadata, md); err != nil {
-		assert.NoError(t, err)
+func (reversingFilter) Order(requests [][]byte) [][]byte {
+	ordered := make([][]byte, 0, len(requests))
+	for i := len(requests) - 1; i >= 0; i-- {
+		if requestFromBytes(requests[i]).ClientID != "mallory" {
+			ordered = append(ordered, requests[i])
+		}
 	}
-	assert.Equal(t, uint64(0), md.ViewId)
-	assert.Equal(t, uint64(2), md.LatestSequence)
-	second := d0
-
-	d1 = <-n1.Delivered
-	assert.Equal(t, d0, d1)
+	return ordered
+}
 
-	network.StopServe()
+func TestLeaderBatchesInPreOrder(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	// add a third node
-	n2 := newNode(uint64(3), network, t.Name(), testDir, true, 1)
-	atomic.AddUint64(&n2.verificationSeq, 2)
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	if err := n2.Consensus.Start(); err != nil {
-		n2.logger.Panicf("Consensus returned an error : %v", err)
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
 	}
+	leader := nodes[0]
+	leader.Consensus.PreOrderFilter = reversingFilter{}
+	leader.Consensus.Config.RequestBatchMaxCount = 4
+	leader.Consensus.Config.RequestBatchMaxInterval = time.Minute
+	startNodes(nodes, network)
 
-	atomic.AddUint64(&n1.verificationSeq, 1)
-	atomic.AddUint64(&n0.verificationSeq, 1)
-	n0.Restart()
-	n1.Restart()
-
-	network.StartServe()
-
-	d2 := <-n2.Delivered
-	assert.Equal(t, first, d2)
-	d2 = <-n2.Delivered
-	assert.Equal(t, second, d2)
-
-	n0.Submit(Request{ID: fmt.Sprintf("%d", 3), ClientID: "alice"})
-
-	d0 = <-n0.Delivered
-	md = &smartbftprotos.ViewMetadata{}
-	if err := proto.Unmarshal(d0.Metadata, md); err != nil {
-		assert.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		leader.Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
 	}
-	assert.Equal(t, uint64(0), md.ViewId)
-	assert.Equal(t, uint64(3), md.LatestSequence)
+	leader.Submit(Request{ID: "1", ClientID: "mallory"})
 
-	d1 = <-n1.Delivered
-	assert.Equal(t, d0, d1)
-	d2 = <-n2.Delivered
-	assert.Equal(t, d0, d2)
-
-	n0.Disconnect() // disconnect the leader
-
-	n1.Submit(Request{ID: fmt.Sprintf("%d", 4), ClientID: "alice"})
-	n2.Submit(Request{ID: fmt.Sprintf("%d", 4), ClientID: "alice"})
-
-	d1 = <-n1.Delivered
-	md = &smartbftprotos.ViewMetadata{}
-	if err := proto.Unmarshal(d1.Metadata, md); err != nil {
-		assert.NoError(t, err)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		ids := make([]string, 0, len(d.Batch.Requests))
+		for _, req := range d.Batch.Requests {
+			ids = append(ids, requestFromBytes(req).ID)
+		}
+		assert.Equal(t, []string{"3", "2", "1"}, ids)
 	}
-	assert.Equal(t, uint64(1), md.ViewId) // view was changed
-	assert.Equal(t, uint64(4), md.LatestSequence)
 
-	d2 = <-n2.Delivered
-	assert.Equal(t, d1, d2)
+	// The rejected request is removed from the pool of the leader
+	assert.Eventually(t, func() bool {
+		return leader.Consensus.Pool.Size() == 0
+	}, 10*time.Second, 10*time.Millisecond)
 }
 
-func TestReconfigAndViewChange(t *testing.T) {
+func TestFollowersPruneRequestsRejectedInPreOrder(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -1540,64 +1826,42 @@ func TestReconfigAndViewChange(t *testing.T) {
 
 	numberOfNodes := 4
 	nodes := make([]*App, 0)
-	for i := 2; i <= numberOfNodes+1; i++ { // add 4 nodes with ids starting at 2
+	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
-		n.LoseMessages(func(msg *smartbftprotos.Message) bool {
-			return false
-		})
+		n.Consensus.PreOrderFilter = reversingFilter{}
 		nodes = append(nodes, n)
 	}
-
 	startNodes(nodes, network)
 
-	for i := 1; i < 5; i++ {
-		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+	for _, n := range nodes {
+		n.Submit(Request{ID: "1", ClientID: "mallory"})
+		n.Submit(Request{ID: "1", ClientID: "alice"})
 	}
-
-	data := make([]*AppRecord, 0)
 	for i := 0; i < numberOfNodes; i++ {
 		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
-	}
-
-	for i := 0; i < numberOfNodes; i++ {
-		nodes[i].Consensus.Stop() // stop all nodes
+		assert.Len(t, d.Batch.Requests, 1)
+		assert.Equal(t, "alice", requestFromBytes(d.Batch.Requests[0]).ClientID)
 	}
 
-	newNode := newNode(1, network, t.Name(), testDir, false, 0) // add node with id 1, should be the leader
-	newNode.LoseMessages(func(msg *smartbftprotos.Message) bool {
-		return false
-	})
-	nodes = append(nodes, newNode)
-	startNodes(nodes[4:], network)
-
-	for i := 0; i < numberOfNodes; i++ {
-		nodes[i].Restart() // restart all stopped nodes
+	// The followers remove the rejected request once it times out, instead of complaining about the leader
+	for _, n := range nodes {
+		n := n
+		assert.Eventually(t, func() bool {
+			return n.Consensus.Pool.Size() == 0
+		}, 10*time.Second, 10*time.Millisecond)
 	}
-
-	d := <-nodes[4].Delivered
-	assert.Equal(t, d, data[0]) // make sure the new added node (leader) is synced
-
-	nodes[4].Disconnect() // leader in partition
-
-	for i := 0; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "10", ClientID: "alice"}) // submit while leader in partition will cause a view change
+	time.Sleep(2 * fastConfig.RequestComplainTimeout)
+	for _, n := range nodes {
+		assert.Equal(t, uint64(0), n.Consensus.CurrentView())
 	}
 
-	data = make([]*AppRecord, 0)
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
 	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
+		<-nodes[i].Delivered
 	}
 }
 
-func TestRotateAndViewChange(t *testing.T) {
+func TestLeaderRetriesFailedAssembly(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -1609,53 +1873,16 @@ func TestRotateAndViewChange(t *testing.T) {
 	numberOfNodes := 4
 	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
-		n := newNode(uint64(i), network, t.Name(), testDir, true, 1)
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.FallibleAssembler = n
+		n.Consensus.Config.AssembleProposalMaxAttempts = 3
+		n.Consensus.Config.AssembleProposalRetryBackoff = 10 * time.Millisecond
 		nodes = append(nodes, n)
 	}
-
-	start := time.Now()
-	for _, n := range nodes {
-		n.heartbeatTime = make(chan time.Time, 1)
-		n.heartbeatTime <- start
-		n.viewChangeTime = make(chan time.Time, 1)
-		n.viewChangeTime <- start
-	}
-
-	var once sync.Once
-	syncedWG := sync.WaitGroup{}
-	syncedWG.Add(1)
-	viewChangeWG3 := sync.WaitGroup{}
-	viewChangeWG3.Add(1)
-	baseLogger3 := nodes[3].logger.Desugar()
-	nodes[3].logger = baseLogger3.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "Node 4 collected state with view 1 and sequence 4") {
-			syncedWG.Done()
-		}
-		if strings.Contains(entry.Message, "Starting view with number 1") {
-			once.Do(func() {
-				viewChangeWG3.Done()
-			})
-		}
-		return nil
-	})).Sugar()
-
-	viewChangeWG := sync.WaitGroup{}
-	viewChangeWG.Add(1)
-	baseLogger1 := nodes[1].logger.Desugar()
-	nodes[1].logger = baseLogger1.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		if strings.Contains(entry.Message, "ViewChanged") {
-			viewChangeWG.Done()
-		}
-		return nil
-	})).Sugar()
-
-	for _, n := range nodes {
-		n.Setup()
-	}
-
+	atomic.StoreInt32(&nodes[0].assembleFails, 2)
 	startNodes(nodes, network)
 
-	nodes[0].Submit(Request{ID: "1", ClientID: "alice"}) // submit to first leader
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
 
 	data := make([]*AppRecord, 0)
 	for i := 0; i < numberOfNodes; i++ {
@@ -1665,55 +1892,81 @@ func TestRotateAndViewChange(t *testing.T) {
 	for i := 0; i < numberOfNodes-1; i++ {
 		assert.Equal(t, data[i], data[i+1])
 	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&nodes[0].assembleCalls))
+}
 
-	nodes[1].Submit(Request{ID: "2", ClientID: "alice"}) // submit to second leader
+func TestTraceIDsSurviveForwardingAndBatching(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	data = make([]*AppRecord, 0)
-	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.deliveredTraces = make(chan []string, 10)
+		n.Consensus.DeliveryTracer = n
+		n.Consensus.Config.RequestBatchMaxCount = 3
+		n.Consensus.Config.RequestBatchMaxInterval = time.Minute
+		nodes = append(nodes, n)
 	}
+	startNodes(nodes, network)
 
-	nodes[2].Submit(Request{ID: "3", ClientID: "alice"}) // submit to third leader
+	// Requests submitted to a follower are forwarded to the leader
+	for i := 1; i <= 3; i++ {
+		nodes[1].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice", TraceID: fmt.Sprintf("trace-%d", i)})
+	}
 
-	data = make([]*AppRecord, 0)
 	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
+		<-nodes[i].Delivered
+		traces := <-nodes[i].deliveredTraces
+		assert.ElementsMatch(t, []string{"trace-1", "trace-2", "trace-3"}, traces)
 	}
+}
 
-	nodes[3].Disconnect() // fourth leader in partition
+func TestDeliveryRetriedUntilAcknowledged(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	// Accelerate the time until a view change
-	done := make(chan struct{})
-	var counter uint64
-	accelerateTime(nodes, done, true, true, &counter)
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	viewChangeWG.Wait()
-	nodes[3].Connect()
-	syncedWG.Wait()
-	viewChangeWG3.Wait()
-	close(done)
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	// The first delivery of the second node fails
+	nodes[1].Consensus.FallibleApplication = nodes[1]
+	nodes[1].Consensus.Config.DeliveryRetryInterval = 10 * time.Millisecond
+	atomic.StoreInt32(&nodes[1].deliverFails, 1)
+	startNodes(nodes, network)
 
-	nodes[1].Submit(Request{ID: "4", ClientID: "alice"}) // submit to current leader
+	for j := 1; j <= 2; j++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", j), ClientID: "alice"})
+		for i := 0; i < numberOfNodes; i++ {
+			record := <-nodes[i].Delivered
+			md := &smartbftprotos.ViewMetadata{}
+			assert.NoError(t, proto.Unmarshal(record.Metadata, md))
+			assert.Equal(t, uint64(j), md.LatestSequence)
+		}
+	}
 
-	data = make([]*AppRecord, 0)
+	// The failed delivery was retried, and every decision was delivered exactly once
+	assert.Equal(t, int32(3), atomic.LoadInt32(&nodes[1].deliverCalls))
 	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
+		assert.Empty(t, nodes[i].Delivered)
 	}
 }
 
-func TestMigrateToBlacklistAndBackAgain(t *testing.T) {
+func TestDeliveryHaltsAfterMaxAttempts(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -1723,115 +1976,323 @@ func TestMigrateToBlacklistAndBackAgain(t *testing.T) {
 	defer os.RemoveAll(testDir)
 
 	numberOfNodes := 4
-	var nodes []*App
+	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
 		nodes = append(nodes, n)
 	}
+	// Every delivery of the second node fails
+	nodes[1].Consensus.FallibleApplication = nodes[1]
+	nodes[1].Consensus.Config.DeliveryRetryInterval = 10 * time.Millisecond
+	nodes[1].Consensus.Config.DeliveryMaxAttempts = 3
+	nodes[1].halts = make(chan error, 1)
+	nodes[1].Consensus.HaltObserver = nodes[1]
+	atomic.StoreInt32(&nodes[1].deliverFails, math.MaxInt32)
+	startNodes(nodes, network)
 
-	var leaderRotationDisabled uint64
-	var boundCommitSignatures uint64
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for _, i := range []int{0, 2, 3} {
+		<-nodes[i].Delivered
+	}
 
-	setupLogger := func(node *App) {
-		node.logger = node.logger.Desugar().WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if strings.Contains(entry.Message, "Bound 3 commit signatures to proposal") {
-				atomic.AddUint64(&boundCommitSignatures, 1)
-			}
-			if strings.Contains(entry.Message, "Leader rotation is disabled, will not bind signatures to proposals") {
-				atomic.AddUint64(&leaderRotationDisabled, 1)
-			}
-			return nil
-		})).Sugar()
+	// The node halts after the last attempt, and does not attempt again
+	select {
+	case err := <-nodes[1].halts:
+		assert.ErrorContains(t, err, "delivery failed")
+	case <-time.After(10 * time.Second):
+		t.Fatal("node did not halt")
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&nodes[1].deliverCalls))
+	assert.Empty(t, nodes[1].Delivered)
+}
+
+// faultInjector injects the faults its functions return, if they are set
+type faultInjector struct {
+	incoming func(sender uint64, m *smartbftprotos.Message) types.Fault
+	outgoing func(target uint64, m *smartbftprotos.Message) types.Fault
+}
+
+func (fi *faultInjector) IncomingFault(sender uint64, m *smartbftprotos.Message) types.Fault {
+	if fi.incoming == nil {
+		return types.Fault{}
 	}
+	return fi.incoming(sender, m)
+}
 
-	for _, n := range nodes {
-		setupLogger(n)
-		n.Setup()
+func (fi *faultInjector) OutgoingFault(target uint64, m *smartbftprotos.Message) types.Fault {
+	if fi.outgoing == nil {
+		return types.Fault{}
 	}
+	return fi.outgoing(target, m)
+}
 
-	t.Run("No leader rotation", func(t *testing.T) {
-		startNodes(nodes, network)
+func TestFaultInjectionDrop(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-		nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	// The votes of the second node never reach the others, and it handles the votes of the others twice
+	var dropped uint32
+	nodes[1].Consensus.FaultInjector = &faultInjector{
+		outgoing: func(_ uint64, m *smartbftprotos.Message) types.Fault {
+			if m.GetPrepare() != nil || m.GetCommit() != nil {
+				atomic.AddUint32(&dropped, 1)
+				return types.Fault{Drop: true}
+			}
+			return types.Fault{}
+		},
+		incoming: func(_ uint64, m *smartbftprotos.Message) types.Fault {
+			return types.Fault{Duplicate: m.GetPrepare() != nil || m.GetCommit() != nil}
+		},
+	}
+	startNodes(nodes, network)
 
+	for j := 1; j <= 2; j++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", j), ClientID: "alice"})
+		data := make([]*AppRecord, 0)
 		for i := 0; i < numberOfNodes; i++ {
-			<-nodes[i].Delivered
+			data = append(data, <-nodes[i].Delivered)
+		}
+		for i := 0; i < numberOfNodes-1; i++ {
+			assert.Equal(t, data[i], data[i+1])
 		}
+	}
+	assert.NotZero(t, atomic.LoadUint32(&dropped))
+}
 
-		assert.Equal(t, uint64(1), atomic.LoadUint64(&leaderRotationDisabled))
-		assert.Equal(t, uint64(0), atomic.LoadUint64(&boundCommitSignatures))
+func TestFaultInjectionDelay(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-		nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-		for i := 0; i < numberOfNodes; i++ {
-			<-nodes[i].Delivered
-		}
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	// The proposals of the leader reach the followers only after a delay
+	delay := 500 * time.Millisecond
+	nodes[0].Consensus.FaultInjector = &faultInjector{
+		outgoing: func(_ uint64, m *smartbftprotos.Message) types.Fault {
+			if m.GetPrePrepare() != nil {
+				return types.Fault{Delay: delay}
+			}
+			return types.Fault{}
+		},
+	}
+	startNodes(nodes, network)
 
-		assert.Equal(t, uint64(2), atomic.LoadUint64(&leaderRotationDisabled))
-		assert.Equal(t, uint64(0), atomic.LoadUint64(&boundCommitSignatures))
-	})
+	start := time.Now()
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		data = append(data, <-nodes[i].Delivered)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), delay)
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+}
 
-	t.Run("Activate leader rotation", func(t *testing.T) {
-		network.StopServe()
+func TestSuspectLeader(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-		for _, n := range nodes {
-			n.Consensus.Config.DecisionsPerLeader = 1
-			n.Consensus.Config.LeaderRotation = true
-			n.Restart()
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	nodes[1].suspicions = make(chan string, 1)
+	nodes[1].Consensus.SuspicionObserver = nodes[1]
+
+	viewChanges := make(chan uint64, 10)
+	nodes[2].LoseMessages(func(msg *smartbftprotos.Message) bool {
+		if vc := msg.GetViewChange(); vc != nil {
+			select {
+			case viewChanges <- vc.NextView:
+			default:
+			}
 		}
+		return false
+	})
+	startNodes(nodes, network)
 
-		network.StartServe()
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
 
-		nodes[0].Submit(Request{ID: "3", ClientID: "alice"})
+	nodes[1].Consensus.SuspectLeader("monitoring reports node 1 is down")
+	assert.Equal(t, "monitoring reports node 1 is down", <-nodes[1].suspicions)
 
-		for i := 0; i < numberOfNodes; i++ {
-			<-nodes[i].Delivered
-		}
+	// The suspecting node starts a view change
+	select {
+	case nextView := <-viewChanges:
+		assert.Equal(t, uint64(1), nextView)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("No view change was started")
+	}
+}
 
-		assert.Equal(t, uint64(2), atomic.LoadUint64(&leaderRotationDisabled))
-		assert.Equal(t, uint64(1), atomic.LoadUint64(&boundCommitSignatures))
+func TestJoinDryRun(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-		nodes[0].Submit(Request{ID: "4", ClientID: "alice"})
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	nodes[3].Consensus.Config.JoinDryRunDecisions = 2
+
+	// Count the commits of the joining node that reach the leader
+	var joiningNodeCommits int32
+	nodes[0].LoseMessages(func(msg *smartbftprotos.Message) bool {
+		if commit := msg.GetCommit(); commit != nil && commit.Signature.Signer == 4 {
+			atomic.AddInt32(&joiningNodeCommits, 1)
+		}
+		return false
+	})
+	startNodes(nodes, network)
+	assert.False(t, nodes[3].Consensus.Voting())
 
+	// The joining node follows and verifies the decisions, but does not vote
+	for j := 1; j <= 2; j++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", j), ClientID: "alice"})
 		for i := 0; i < numberOfNodes; i++ {
 			<-nodes[i].Delivered
 		}
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&joiningNodeCommits))
+	assert.True(t, nodes[3].Consensus.Voting())
 
-		assert.Equal(t, uint64(2), atomic.LoadUint64(&leaderRotationDisabled))
-		assert.Equal(t, uint64(2), atomic.LoadUint64(&boundCommitSignatures))
-	})
-
-	t.Run("Deactivate leader rotation", func(t *testing.T) {
-		t.Skip()
-		network.StopServe()
+	// Once promoted, the joining node is a part of the quorum
+	nodes[2].Disconnect()
+	nodes[0].Submit(Request{ID: "3", ClientID: "alice"})
+	for _, i := range []int{0, 1, 3} {
+		<-nodes[i].Delivered
+	}
+	assert.NotZero(t, atomic.LoadInt32(&joiningNodeCommits))
+}
 
-		for _, n := range nodes {
-			n.Consensus.Config.DecisionsPerLeader = 0
-			n.Consensus.Config.LeaderRotation = false
-			n.Restart()
-		}
+func TestDecorateDecision(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-		network.StartServe()
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-		nodes[0].Submit(Request{ID: "5", ClientID: "alice"})
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.DecisionDecorator = n
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
 
+	for j := 1; j <= 3; j++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", j), ClientID: "alice"})
+		var headers [][]byte
 		for i := 0; i < numberOfNodes; i++ {
 			<-nodes[i].Delivered
+			nodes[i].lock.Lock()
+			proposal := nodes[i].lastDecision.Proposal
+			nodes[i].lock.Unlock()
+			digest := sha256.Sum256(proposal.Payload)
+			assert.Equal(t, digest[:], proposal.Header)
+			headers = append(headers, proposal.Header)
+		}
+		for i := 1; i < numberOfNodes; i++ {
+			assert.Equal(t, headers[0], headers[i])
 		}
+	}
+}
 
-		assert.Equal(t, uint64(2), atomic.LoadUint64(&boundCommitSignatures))
+func TestRotateKeyAtVerificationSequenceBoundary(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-		nodes[0].Submit(Request{ID: "6", ClientID: "alice"})
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.KeyRotator = n
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
 
+	// Rotate the key of the second node, starting from the next verification sequence
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice", RotateKey: 2})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+
+	// Subsequent proposals are verified using the rotated key
+	for j := 3; j <= 4; j++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", j), ClientID: "alice"})
 		for i := 0; i < numberOfNodes; i++ {
 			<-nodes[i].Delivered
 		}
+	}
 
-		assert.Equal(t, uint64(2), atomic.LoadUint64(&boundCommitSignatures))
-	})
+	for i := 0; i < numberOfNodes; i++ {
+		assert.Equal(t, uint64(1), nodes[i].VerificationSequence())
+		nodes[i].lock.Lock()
+		lastDecision := nodes[i].lastDecision
+		nodes[i].lock.Unlock()
+		for _, sig := range lastDecision.Signatures {
+			if sig.ID == 2 {
+				assert.Equal(t, []byte("key-2-1"), sig.Value)
+			}
+		}
+	}
 }
 
-func TestNodeInFlightFails(t *testing.T) {
+func TestLeaderExclusion(t *testing.T) {
+	// Scenario: The leader doesn't send messages to n3,
+	// but it should detect this and sync.
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -1842,140 +2303,159 @@ func TestNodeInFlightFails(t *testing.T) {
 
 	numberOfNodes := 4
 	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
 
-	var failedViewChange sync.WaitGroup
-	failedViewChange.Add(1)
+	startNodes(nodes, network)
 
-	var timeoutExpiredWG sync.WaitGroup
-	timeoutExpiredWG.Add(1)
+	nodes[0].DisconnectFrom(3)
 
-	var collectPreparesWG sync.WaitGroup
-	collectPreparesWG.Add(4)
+	// We create new batches until the disconnected node catches up the quorum.
+	for reqID := 1; reqID < 100; reqID++ {
+		nodes[1].Submit(Request{ID: fmt.Sprintf("%d", reqID), ClientID: "alice"})
+		<-nodes[1].Delivered // Wait for follower to commit
+		caughtUp := waitForCatchup(reqID, nodes[3].Delivered)
+		if caughtUp {
+			return
+		}
+	}
+	t.Fatalf("Didn't catch up")
+}
 
-	var inFlightCommit sync.WaitGroup
-	inFlightCommit.Add(2)
+func TestCatchingUpWithSyncAssisted(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	var blockCommits uint32
-	var blockCommitsForLastNode uint32
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
-		n.Consensus.Config.SyncOnStart = false                       // Prevent last node from syncing without a reason
-		n.Consensus.Config.ViewChangeTimeout = time.Second * 10      // Make view change faster to speedup the test
-		n.Consensus.Config.LeaderHeartbeatTimeout = time.Second * 20 // Force view change sooner to speedup test
-		n.Consensus.Config.RequestForwardTimeout = time.Second * 25  // Prevent first node to forward after view change
-		n.Consensus.Config.RequestComplainTimeout = time.Second * 30 // Must be bigger than the forward timeout
-		n.Consensus.Config.SpeedUpViewChange = false
 		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
 
-		baseLogger := n.logger.Desugar()
+	nodes[3].Disconnect() // will need to catch up
 
-		id := n.ID
-		n.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if strings.Contains(entry.Message, "collected 2 prepares") && atomic.LoadUint32(&blockCommits) == 1 {
-				collectPreparesWG.Done()
-			}
-
-			if strings.Contains(entry.Message, "In-flight view 0 with latest sequence 1 has committed a decision") && (id == 2 || id == 3) {
-				inFlightCommit.Done()
-			}
-
-			if strings.Contains(entry.Message, "Timeout expired waiting on In-flight 0 with latest sequence view to commit 1") && id == 4 {
-				timeoutExpiredWG.Done()
-			}
-
-			if strings.Contains(entry.Message, "Node 4 was unable to commit the in flight proposal, not changing the view") && id == 4 {
-				failedViewChange.Done()
-			}
+	for i := 1; i <= 10; i++ {
+		for j := 0; j <= 2; j++ {
+			nodes[j].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+		}
+		for j := 0; j <= 2; j++ {
+			<-nodes[j].Delivered
+		}
+	}
 
-			return nil
-		})).Sugar()
+	nodes[3].Connect()
 
-		// Make all nodes but the last node lose commits per the 'blockCommits' flag
-		if i == 4 {
-			continue
+	// We create new batches until it catches up the quorum.
+	for reqID := 11; reqID < 100; reqID++ {
+		for j := 0; j <= 2; j++ {
+			nodes[j].Submit(Request{ID: fmt.Sprintf("%d", reqID), ClientID: "alice"})
+		}
+		for j := 0; j <= 2; j++ {
+			<-nodes[j].Delivered
+		}
+		caughtUp := waitForCatchup(reqID, nodes[3].Delivered)
+		if caughtUp {
+			return
 		}
-		n.LoseMessages(func(msg *smartbftprotos.Message) bool {
-			if msg.GetCommit() == nil {
-				return false
-			}
-			return atomic.LoadUint32(&blockCommits) == 1
-		})
 	}
+	t.Fatalf("Didn't catch up")
+}
 
-	// However the last node never receives any commits.
-	nodes[len(nodes)-1].LoseMessages(func(msg *smartbftprotos.Message) bool {
-		return msg.GetCommit() != nil && atomic.LoadUint32(&blockCommitsForLastNode) == 1
-	})
+func TestCatchingUpWithSyncAutonomous(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	startNodes(nodes, network)
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	atomic.StoreUint32(&blockCommits, 1)
-	atomic.StoreUint32(&blockCommitsForLastNode, 1)
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, true, 3)
+		nodes = append(nodes, n)
+	}
 
-	nodes[0].Submit(Request{ID: "first request", ClientID: "alice"})
+	var detectedSequenceGap uint32
 
-	// Wait for prepares to be collected
-	collectPreparesWG.Wait()
+	baseLogger := nodes[3].Consensus.Logger.(*zap.SugaredLogger).Desugar()
+	nodes[3].Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "Leader's sequence is 10 and ours is 1") {
+			atomic.StoreUint32(&detectedSequenceGap, 1)
+		}
+		return nil
+	})).Sugar()
 
-	// Ensure no one commits a block because of not enough commits gathered
-	var doNotCommitWG sync.WaitGroup
-	doNotCommitWG.Add(numberOfNodes)
+	start := time.Now()
+	nodes[0].heartbeatTime = make(chan time.Time, 1)
+	nodes[0].heartbeatTime <- start
+	nodes[3].heartbeatTime = make(chan time.Time, 1)
+	nodes[3].heartbeatTime <- start
+	nodes[3].viewChangeTime = make(chan time.Time, 1)
+	nodes[3].viewChangeTime <- start
+	nodes[0].Setup()
+	nodes[3].Setup()
 
-	for i := 0; i < numberOfNodes; i++ {
-		go func(deliverChannel chan *AppRecord) {
-			defer doNotCommitWG.Done()
-			select {
-			case <-deliverChannel:
-				assert.Fail(t, "should not have committed because first commit message should have been lost")
-			case <-time.After(time.Second):
-			}
-		}(nodes[i].Delivered)
-	}
-	doNotCommitWG.Wait()
+	startNodes(nodes, network)
 
-	// Re-enable commit messages to flow to all nodes but the last node
-	atomic.StoreUint32(&blockCommits, 0)
+	nodes[3].Disconnect() // will need to catch up
 
-	// Disconnect leader to force view change
-	nodes[0].Disconnect()
+	for i := 1; i <= 5; i++ {
+		for j := 0; j <= 2; j++ {
+			nodes[j].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+		}
+		for j := 0; j <= 2; j++ {
+			<-nodes[j].Delivered
+		}
+	}
 
-	// Wait for view change and ensure in-flight view has committed the previous decision
-	inFlightCommit.Wait()
+	nodes[3].Connect()
 
-	// Re-connect the leader
-	nodes[0].Connect()
+	done := make(chan struct{})
+	// Accelerate the time for n3 so it will suspect the leader and view change.
+	go func() {
+		var i int
+		for {
+			i++
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond * 100):
+				nodes[0].heartbeatTime <- time.Now().Add(time.Second * time.Duration(10*i))
+				nodes[3].heartbeatTime <- time.Now().Add(time.Second * time.Duration(10*i))
+				nodes[3].viewChangeTime <- time.Now().Add(time.Minute * time.Duration(10*i))
+			}
+		}
+	}()
 
-	// Ensure that only nodes 1 to 3 have committed in-flight
-	d1 := <-nodes[0].Delivered
-	d2 := <-nodes[1].Delivered
-	d3 := <-nodes[2].Delivered
-	select {
-	case <-nodes[3].Delivered:
-		t.Fatalf("Node 4 has committed but shouldn't have")
-	case <-time.After(time.Second):
+	for i := 1; i <= 5; i++ {
+		select {
+		case <-nodes[3].Delivered:
+		case <-time.After(time.Second * 10):
+			t.Fatalf("Didn't catch up within a timely period")
+		}
 	}
 
-	// Ensure all nodes that committed, committed the same decision
-	assert.Equal(t, d1, d2)
-	assert.Equal(t, d2, d3)
-
-	// Wait for in-flight view of view changer to time out
-	timeoutExpiredWG.Wait()
-	// Re-enable commits to flow to the last node
-	atomic.StoreUint32(&blockCommitsForLastNode, 0)
-	// Ensure the last node failed changing the view
-	failedViewChange.Wait()
-	// However, it eventually syncs successfully and delivers the proposal
-	select {
-	case d4 := <-nodes[3].Delivered:
-		assert.Equal(t, d3, d4)
-	case <-time.After(time.Second * 30):
-		t.Fatalf("Didn't commit decision in a timely manner")
-	}
+	close(done)
+	assert.Equal(t, uint32(0), atomic.LoadUint32(&detectedSequenceGap))
 }
 
-func TestBlacklistAndRedemption(t *testing.T) {
+func TestFollowerStateTransfer(t *testing.T) {
+	// Scenario: the leader (n0) is disconnected and so there is a view change
+	// a follower (n6) is also disconnected and misses the view change
+	// after the follower reconnects and gets a view change timeout is calls sync
+	// where it collects state transfer requests and sees that there was a view change
+
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -1984,112 +2464,1440 @@ func TestBlacklistAndRedemption(t *testing.T) {
 	assert.NoErrorf(t, err, "generate temporary test dir")
 	defer os.RemoveAll(testDir)
 
-	numberOfNodes := 10
-	var nodes []*App
+	numberOfNodes := 7
+	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
-		n := newNode(uint64(i), network, t.Name(), testDir, true, 1)
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
 		nodes = append(nodes, n)
 	}
 
-	var viewChangedWG sync.WaitGroup
-	viewChangedWG.Add(len(nodes) - 1)
-
-	var blacklistPrunedWG sync.WaitGroup
-	blacklistPrunedWG.Add(len(nodes) + 1) // Once at proposing, n times at verification
-
-	var redemptionWG sync.WaitGroup
-	redemptionWG.Add(len(nodes))
-
 	start := time.Now()
 	for _, n := range nodes {
-		l := n.logger.Desugar()
-		n.logger = l.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if strings.Contains(entry.Message, "Starting view with number 1, sequence 1,") {
-				viewChangedWG.Done()
-			}
-			if strings.Contains(entry.Message, "Blacklist changed: [1] --> []") {
-				blacklistPrunedWG.Done()
-			}
-			if strings.Contains(entry.Message, "Rotating leader from 10 to 1") {
-				redemptionWG.Done()
-			}
-			return nil
-		})).Sugar()
 		n.heartbeatTime = make(chan time.Time, 1)
 		n.heartbeatTime <- start
 		n.viewChangeTime = make(chan time.Time, 1)
 		n.viewChangeTime <- start
+	}
+
+	syncedWG := sync.WaitGroup{}
+	syncedWG.Add(1)
+	baseLogger6 := nodes[6].logger.Desugar()
+	nodes[6].logger = baseLogger6.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "collected state") {
+			syncedWG.Done()
+		}
+		return nil
+	})).Sugar()
+
+	viewChangeWG := sync.WaitGroup{}
+	viewChangeWG.Add(1)
+	baseLogger1 := nodes[1].logger.Desugar()
+	nodes[1].logger = baseLogger1.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "ViewChanged") {
+			viewChangeWG.Done()
+		}
+		return nil
+	})).Sugar()
+
+	for _, n := range nodes {
 		n.Setup()
 	}
 
 	startNodes(nodes, network)
 
-	nodes[0].Disconnect() // Leader is in partition
+	nodes[0].Disconnect() // leader in partition
+	nodes[6].Disconnect() // follower in partition
 
 	// Accelerate the time until a view change
 	done := make(chan struct{})
+
 	var counter uint64
-	accelerateTime(nodes[1:], done, true, true, &counter)
+	accelerateTime(nodes, done, true, true, &counter)
 
-	// Wait for view change
-	viewChangedWG.Wait()
+	viewChangeWG.Wait()
+	nodes[6].Connect()
+	syncedWG.Wait()
 	close(done)
 
-	// Rotate the leader and ensure the view doesn't change, which proves
-	// node 1 never became the leader again
-	for j := 0; j < numberOfNodes; j++ {
-		nodes[1].Submit(Request{ID: fmt.Sprintf("%d", j), ClientID: "alice"})
-		for i := 1; i < numberOfNodes; i++ {
-			<-nodes[i].Delivered
-		}
+	for i := 2; i < numberOfNodes; i++ {
+		nodes[i].Submit(Request{ID: "1", ClientID: "alice"})
 	}
 
-	// Ensure we remain on view 1, and that node 1 is in the blacklist
-	md := &smartbftprotos.ViewMetadata{}
-	err = proto.Unmarshal(nodes[1].lastDecision.Proposal.Metadata, md)
-	assert.NoError(t, err)
-	assert.Equal(t, uint64(1), md.ViewId)
-	assert.Equal(t, []uint64{1}, md.BlackList)
-
-	// Next, re-connect node 1
-	nodes[0].Connect()
+	data := make([]*AppRecord, 0)
+	for i := 2; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-3; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+}
+
+func TestLeaderModifiesPreprepare(t *testing.T) {
+	for _, test := range []struct {
+		description  string
+		mutatingFunc func(target uint64, m *smartbftprotos.Message)
+	}{
+		{
+			description: "wrong view",
+			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
+				if m.GetPrePrepare() == nil {
+					return
+				}
+				m.GetPrePrepare().View = 1
+			},
+		},
+		{
+			description: "conflicting proposals",
+			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
+				if m.GetPrePrepare() == nil {
+					return
+				}
+				incReq := Request{ID: fmt.Sprintf("%d", target), ClientID: "alice"}
+				incData := batch{
+					Requests: [][]byte{incReq.ToBytes()},
+				}
+				m.GetPrePrepare().Proposal.Payload = incData.toBytes()
+			},
+		},
+		{
+			description: "next sequence",
+			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
+				if m.GetPrePrepare() == nil {
+					return
+				}
+				m.GetPrePrepare().Seq = 2
+			},
+		},
+		{
+			description: "wrong verification sequence",
+			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
+				if m.GetPrePrepare() == nil {
+					return
+				}
+				m.GetPrePrepare().Proposal.VerificationSequence = 3
+			},
+		},
+		{
+			description: "wrong number of decisions",
+			mutatingFunc: func(target uint64, m *smartbftprotos.Message) {
+				if m.GetPrePrepare() == nil {
+					return
+				}
+				m.GetPrePrepare().Proposal.Metadata = bft.MarshalOrPanic(&smartbftprotos.ViewMetadata{
+					DecisionsInView: 1, // instead of 0
+					LatestSequence:  1,
+					ViewId:          0,
+				})
+			},
+		},
+	} {
+		test := test
+		t.Run(test.description, func(t *testing.T) {
+			t.Parallel()
+			network := NewNetwork()
+			defer network.Shutdown()
+
+			testDir, err := os.MkdirTemp("", test.description)
+			assert.NoErrorf(t, err, "generate temporary test dir")
+			defer os.RemoveAll(testDir)
+
+			numberOfNodes := 4
+			nodes := make([]*App, 0)
+			for i := 1; i <= numberOfNodes; i++ {
+				n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+				nodes = append(nodes, n)
+			}
+
+			once := sync.Once{}
+			viewChangeWG := sync.WaitGroup{}
+			viewChangeWG.Add(1)
+			baseLogger1 := nodes[1].logger.Desugar()
+			nodes[1].logger = baseLogger1.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+				if strings.Contains(entry.Message, "ViewChanged") {
+					once.Do(func() {
+						viewChangeWG.Done()
+					})
+				}
+				return nil
+			})).Sugar()
+			nodes[1].Setup()
+
+			startNodes(nodes, network)
+
+			for i := 2; i <= numberOfNodes; i++ {
+				nodes[0].MutateSend(uint64(i), test.mutatingFunc)
+			}
+
+			for i := 0; i < numberOfNodes; i++ {
+				nodes[i].Submit(Request{ID: fmt.Sprintf("%d", 1), ClientID: "alice"})
+			}
+
+			viewChangeWG.Wait()
+
+			data := make([]*AppRecord, 0)
+			for i := 0; i < numberOfNodes; i++ {
+				d := <-nodes[i].Delivered
+				data = append(data, d)
+			}
+			for i := 0; i < numberOfNodes-1; i++ {
+				assert.Equal(t, data[i], data[i+1])
+			}
+
+			md := &smartbftprotos.ViewMetadata{}
+			if err := proto.Unmarshal(data[0].Metadata, md); err != nil {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, uint64(1), md.LatestSequence)
+
+			for i := 2; i <= numberOfNodes; i++ {
+				nodes[0].ClearMutateSend(uint64(i))
+			}
+		})
+	}
+}
+
+func TestLeaderCatchUpWithoutSync(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.SyncOnStart = false
+		nodes = append(nodes, n)
+	}
+
+	restartWG := sync.WaitGroup{}
+	restartWG.Add(1)
+
+	restoredWG := sync.WaitGroup{}
+	restoredWG.Add(1)
+
+	var blockCommits uint32
+	nodes[0].LoseMessages(func(msg *smartbftprotos.Message) bool {
+		return msg.GetCommit() != nil && atomic.LoadUint32(&blockCommits) == 1
+	})
+	baseLogger := nodes[0].logger.Desugar()
+	nodes[0].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "Processed prepares for proposal with seq 1") {
+			restartWG.Done()
+		}
+		if strings.Contains(entry.Message, "Restored proposal with sequence 1") {
+			restoredWG.Done()
+		}
+		return nil
+	})).Sugar()
+	nodes[0].Setup()
+	atomic.StoreUint32(&blockCommits, 1)
+
+	startNodes(nodes, network)
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+
+	restartWG.Wait()
+	nodes[0].RestartSync(false)
+	atomic.StoreUint32(&blockCommits, 0)
+	restoredWG.Wait()
+
+	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+
+	data = make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+}
+
+func TestLeaderProposeAfterRestartWithoutSync(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.SyncOnStart = false
+		nodes = append(nodes, n)
+	}
+
+	restartWG := sync.WaitGroup{}
+	restartWG.Add(1)
+
+	restoredWG := sync.WaitGroup{}
+	restoredWG.Add(1)
+
+	contViewWG := sync.WaitGroup{}
+	contViewWG.Add(2)
+
+	var blockCommits uint32
+	nodes[0].LoseMessages(func(msg *smartbftprotos.Message) bool {
+		return msg.GetCommit() != nil && atomic.LoadUint32(&blockCommits) == 1
+	})
+	baseLogger := nodes[0].logger.Desugar()
+	nodes[0].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "Processed prepares for proposal with seq 1") {
+			restartWG.Done()
+		}
+		if strings.Contains(entry.Message, "Restored proposal with sequence 1") {
+			restoredWG.Done()
+		}
+		if strings.Contains(entry.Message, "processed commits for proposal with seq 1") {
+			contViewWG.Wait()
+		}
+		if strings.Contains(entry.Message, "GetMetadata with view 0, seq 1") {
+			contViewWG.Done()
+		}
+		if strings.Contains(entry.Message, "Not acquiring leader token when starting view with phase PREPARED") {
+			contViewWG.Done()
+		}
+		if strings.Contains(entry.Message, "Expected proposal sequence 2 but got 1") {
+			panic("Expected proposal sequence 2 but got 1")
+		}
+		return nil
+	})).Sugar()
+	nodes[0].Setup()
+	atomic.StoreUint32(&blockCommits, 1)
+
+	startNodes(nodes, network)
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+
+	restartWG.Wait()
+	nodes[0].RestartSync(false)
+	atomic.StoreUint32(&blockCommits, 0)
+	restoredWG.Wait()
+
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+
+	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	data = make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+}
+
+func TestGradualStart(t *testing.T) {
+	// Scenario: initially the network has only one node
+	// a transaction is submitted and committed with that node
+	// then another node is introduced, with a reset to the original node
+	// and another transaction is passed
+	// lastly a third node is added and another transaction is submitted
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	// start with only one node
+	n0 := newNode(uint64(1), network, t.Name(), testDir, true, 1)
+
+	if err := n0.Consensus.Start(); err != nil {
+		n0.logger.Panicf("Consensus returned an error : %v", err)
+	}
+	network.StartServe()
+
+	n0.Submit(Request{ID: fmt.Sprintf("%d", 1), ClientID: "alice"})
+
+	d0 := <-n0.Delivered
+	md := &smartbftprotos.ViewMetadata{}
+	if err := proto.Unmarshal(d0.Metadata, md); err != nil {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, uint64(0), md.ViewId)
+	assert.Equal(t, uint64(1), md.LatestSequence)
+	first := d0
+
+	network.StopServe()
+
+	// add a second node
+	n1 := newNode(uint64(2), network, t.Name(), testDir, true, 1)
+	atomic.AddUint64(&n1.verificationSeq, 1)
+
+	if err := n1.Consensus.Start(); err != nil {
+		n1.logger.Panicf("Consensus returned an error : %v", err)
+	}
+
+	atomic.AddUint64(&n0.verificationSeq, 1)
+	n0.Restart()
+
+	network.StartServe()
+
+	d1 := <-n1.Delivered
+	assert.Equal(t, d0, d1)
+
+	n0.Submit(Request{ID: fmt.Sprintf("%d", 2), ClientID: "alice"})
+
+	d0 = <-n0.Delivered
+	md = &smartbftprotos.ViewMetadata{}
+	if err := proto.Unmarshal(d0.Metadata, md); err != nil {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, uint64(0), md.ViewId)
+	assert.Equal(t, uint64(2), md.LatestSequence)
+	second := d0
+
+	d1 = <-n1.Delivered
+	assert.Equal(t, d0, d1)
+
+	network.StopServe()
+
+	// add a third node
+	n2 := newNode(uint64(3), network, t.Name(), testDir, true, 1)
+	atomic.AddUint64(&n2.verificationSeq, 2)
+
+	if err := n2.Consensus.Start(); err != nil {
+		n2.logger.Panicf("Consensus returned an error : %v", err)
+	}
+
+	atomic.AddUint64(&n1.verificationSeq, 1)
+	atomic.AddUint64(&n0.verificationSeq, 1)
+	n0.Restart()
+	n1.Restart()
+
+	network.StartServe()
+
+	d2 := <-n2.Delivered
+	assert.Equal(t, first, d2)
+	d2 = <-n2.Delivered
+	assert.Equal(t, second, d2)
+
+	n0.Submit(Request{ID: fmt.Sprintf("%d", 3), ClientID: "alice"})
+
+	d0 = <-n0.Delivered
+	md = &smartbftprotos.ViewMetadata{}
+	if err := proto.Unmarshal(d0.Metadata, md); err != nil {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, uint64(0), md.ViewId)
+	assert.Equal(t, uint64(3), md.LatestSequence)
+
+	d1 = <-n1.Delivered
+	assert.Equal(t, d0, d1)
+	d2 = <-n2.Delivered
+	assert.Equal(t, d0, d2)
+
+	n0.Disconnect() // disconnect the leader
+
+	n1.Submit(Request{ID: fmt.Sprintf("%d", 4), ClientID: "alice"})
+	n2.Submit(Request{ID: fmt.Sprintf("%d", 4), ClientID: "alice"})
+
+	d1 = <-n1.Delivered
+	md = &smartbftprotos.ViewMetadata{}
+	if err := proto.Unmarshal(d1.Metadata, md); err != nil {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, uint64(1), md.ViewId) // view was changed
+	assert.Equal(t, uint64(4), md.LatestSequence)
+
+	d2 = <-n2.Delivered
+	assert.Equal(t, d1, d2)
+}
+
+func TestReconfigAndViewChange(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 2; i <= numberOfNodes+1; i++ { // add 4 nodes with ids starting at 2
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.LoseMessages(func(msg *smartbftprotos.Message) bool {
+			return false
+		})
+		nodes = append(nodes, n)
+	}
+
+	startNodes(nodes, network)
+
+	for i := 1; i < 5; i++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+	}
+
+	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	for i := 0; i < numberOfNodes; i++ {
+		nodes[i].Consensus.Stop() // stop all nodes
+	}
+
+	newNode := newNode(1, network, t.Name(), testDir, false, 0) // add node with id 1, should be the leader
+	newNode.LoseMessages(func(msg *smartbftprotos.Message) bool {
+		return false
+	})
+	nodes = append(nodes, newNode)
+	startNodes(nodes[4:], network)
+
+	for i := 0; i < numberOfNodes; i++ {
+		nodes[i].Restart() // restart all stopped nodes
+	}
+
+	d := <-nodes[4].Delivered
+	assert.Equal(t, d, data[0]) // make sure the new added node (leader) is synced
+
+	nodes[4].Disconnect() // leader in partition
+
+	for i := 0; i < numberOfNodes; i++ {
+		nodes[i].Submit(Request{ID: "10", ClientID: "alice"}) // submit while leader in partition will cause a view change
+	}
+
+	data = make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+}
+
+func TestRotateAndViewChange(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, true, 1)
+		nodes = append(nodes, n)
+	}
+
+	start := time.Now()
+	for _, n := range nodes {
+		n.heartbeatTime = make(chan time.Time, 1)
+		n.heartbeatTime <- start
+		n.viewChangeTime = make(chan time.Time, 1)
+		n.viewChangeTime <- start
+	}
+
+	var once sync.Once
+	syncedWG := sync.WaitGroup{}
+	syncedWG.Add(1)
+	viewChangeWG3 := sync.WaitGroup{}
+	viewChangeWG3.Add(1)
+	baseLogger3 := nodes[3].logger.Desugar()
+	nodes[3].logger = baseLogger3.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "Node 4 collected state with view 1 and sequence 4") {
+			syncedWG.Done()
+		}
+		if strings.Contains(entry.Message, "Starting view with number 1") {
+			once.Do(func() {
+				viewChangeWG3.Done()
+			})
+		}
+		return nil
+	})).Sugar()
+
+	viewChangeWG := sync.WaitGroup{}
+	viewChangeWG.Add(1)
+	baseLogger1 := nodes[1].logger.Desugar()
+	nodes[1].logger = baseLogger1.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "ViewChanged") {
+			viewChangeWG.Done()
+		}
+		return nil
+	})).Sugar()
+
+	for _, n := range nodes {
+		n.Setup()
+	}
+
+	startNodes(nodes, network)
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"}) // submit to first leader
+
+	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	nodes[1].Submit(Request{ID: "2", ClientID: "alice"}) // submit to second leader
+
+	data = make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	nodes[2].Submit(Request{ID: "3", ClientID: "alice"}) // submit to third leader
+
+	data = make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	nodes[3].Disconnect() // fourth leader in partition
+
+	// Accelerate the time until a view change
+	done := make(chan struct{})
+	var counter uint64
+	accelerateTime(nodes, done, true, true, &counter)
+
+	viewChangeWG.Wait()
+	nodes[3].Connect()
+	syncedWG.Wait()
+	viewChangeWG3.Wait()
+	close(done)
+
+	nodes[1].Submit(Request{ID: "4", ClientID: "alice"}) // submit to current leader
+
+	data = make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+}
+
+func TestMigrateToBlacklistAndBackAgain(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	var nodes []*App
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+
+	var leaderRotationDisabled uint64
+	var boundCommitSignatures uint64
+
+	setupLogger := func(node *App) {
+		node.logger = node.logger.Desugar().WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if strings.Contains(entry.Message, "Bound 3 commit signatures to proposal") {
+				atomic.AddUint64(&boundCommitSignatures, 1)
+			}
+			if strings.Contains(entry.Message, "Leader rotation is disabled, will not bind signatures to proposals") {
+				atomic.AddUint64(&leaderRotationDisabled, 1)
+			}
+			return nil
+		})).Sugar()
+	}
+
+	for _, n := range nodes {
+		setupLogger(n)
+		n.Setup()
+	}
+
+	t.Run("No leader rotation", func(t *testing.T) {
+		startNodes(nodes, network)
+
+		nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+
+		for i := 0; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+
+		assert.Equal(t, uint64(1), atomic.LoadUint64(&leaderRotationDisabled))
+		assert.Equal(t, uint64(0), atomic.LoadUint64(&boundCommitSignatures))
+
+		nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+
+		for i := 0; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+
+		assert.Equal(t, uint64(2), atomic.LoadUint64(&leaderRotationDisabled))
+		assert.Equal(t, uint64(0), atomic.LoadUint64(&boundCommitSignatures))
+	})
+
+	t.Run("Activate leader rotation", func(t *testing.T) {
+		network.StopServe()
+
+		for _, n := range nodes {
+			n.Consensus.Config.DecisionsPerLeader = 1
+			n.Consensus.Config.LeaderRotation = true
+			n.Restart()
+		}
+
+		network.StartServe()
+
+		nodes[0].Submit(Request{ID: "3", ClientID: "alice"})
+
+		for i := 0; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+
+		assert.Equal(t, uint64(2), atomic.LoadUint64(&leaderRotationDisabled))
+		assert.Equal(t, uint64(1), atomic.LoadUint64(&boundCommitSignatures))
+
+		nodes[0].Submit(Request{ID: "4", ClientID: "alice"})
+
+		for i := 0; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+
+		assert.Equal(t, uint64(2), atomic.LoadUint64(&leaderRotationDisabled))
+		assert.Equal(t, uint64(2), atomic.LoadUint64(&boundCommitSignatures))
+	})
+
+	t.Run("Deactivate leader rotation", func(t *testing.T) {
+		t.Skip()
+		network.StopServe()
+
+		for _, n := range nodes {
+			n.Consensus.Config.DecisionsPerLeader = 0
+			n.Consensus.Config.LeaderRotation = false
+			n.Restart()
+		}
+
+		network.StartServe()
+
+		nodes[0].Submit(Request{ID: "5", ClientID: "alice"})
+
+		for i := 0; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+
+		assert.Equal(t, uint64(2), atomic.LoadUint64(&boundCommitSignatures))
+
+		nodes[0].Submit(Request{ID: "6", ClientID: "alice"})
+
+		for i := 0; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+
+		assert.Equal(t, uint64(2), atomic.LoadUint64(&boundCommitSignatures))
+	})
+}
+
+func TestNodeInFlightFails(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+
+	var failedViewChange sync.WaitGroup
+	failedViewChange.Add(1)
+
+	var timeoutExpiredWG sync.WaitGroup
+	timeoutExpiredWG.Add(1)
+
+	var collectPreparesWG sync.WaitGroup
+	collectPreparesWG.Add(4)
+
+	var inFlightCommit sync.WaitGroup
+	inFlightCommit.Add(2)
+
+	var blockCommits uint32
+	var blockCommitsForLastNode uint32
+
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.SyncOnStart = false                       // Prevent last node from syncing without a reason
+		n.Consensus.Config.ViewChangeTimeout = time.Second * 10      // Make view change faster to speedup the test
+		n.Consensus.Config.LeaderHeartbeatTimeout = time.Second * 20 // Force view change sooner to speedup test
+		n.Consensus.Config.RequestForwardTimeout = time.Second * 25  // Prevent first node to forward after view change
+		n.Consensus.Config.RequestComplainTimeout = time.Second * 30 // Must be bigger than the forward timeout
+		n.Consensus.Config.SpeedUpViewChange = false
+		nodes = append(nodes, n)
+
+		baseLogger := n.logger.Desugar()
+
+		id := n.ID
+		n.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if strings.Contains(entry.Message, "collected 2 prepares") && atomic.LoadUint32(&blockCommits) == 1 {
+				collectPreparesWG.Done()
+			}
+
+			if strings.Contains(entry.Message, "In-flight view 0 with latest sequence 1 has committed a decision") && (id == 2 || id == 3) {
+				inFlightCommit.Done()
+			}
+
+			if strings.Contains(entry.Message, "Timeout expired waiting on In-flight 0 with latest sequence view to commit 1") && id == 4 {
+				timeoutExpiredWG.Done()
+			}
+
+			if strings.Contains(entry.Message, "Node 4 was unable to commit the in flight proposal, not changing the view") && id == 4 {
+				failedViewChange.Done()
+			}
+
+			return nil
+		})).Sugar()
+
+		// Make all nodes but the last node lose commits per the 'blockCommits' flag
+		if i == 4 {
+			continue
+		}
+		n.LoseMessages(func(msg *smartbftprotos.Message) bool {
+			if msg.GetCommit() == nil {
+				return false
+			}
+			return atomic.LoadUint32(&blockCommits) == 1
+		})
+	}
+
+	// However the last node never receives any commits.
+	nodes[len(nodes)-1].LoseMessages(func(msg *smartbftprotos.Message) bool {
+		return msg.GetCommit() != nil && atomic.LoadUint32(&blockCommitsForLastNode) == 1
+	})
+
+	startNodes(nodes, network)
+
+	atomic.StoreUint32(&blockCommits, 1)
+	atomic.StoreUint32(&blockCommitsForLastNode, 1)
+
+	nodes[0].Submit(Request{ID: "first request", ClientID: "alice"})
+
+	// Wait for prepares to be collected
+	collectPreparesWG.Wait()
+
+	// Ensure no one commits a block because of not enough commits gathered
+	var doNotCommitWG sync.WaitGroup
+	doNotCommitWG.Add(numberOfNodes)
+
+	for i := 0; i < numberOfNodes; i++ {
+		go func(deliverChannel chan *AppRecord) {
+			defer doNotCommitWG.Done()
+			select {
+			case <-deliverChannel:
+				assert.Fail(t, "should not have committed because first commit message should have been lost")
+			case <-time.After(time.Second):
+			}
+		}(nodes[i].Delivered)
+	}
+	doNotCommitWG.Wait()
+
+	// Re-enable commit messages to flow to all nodes but the last node
+	atomic.StoreUint32(&blockCommits, 0)
+
+	// Disconnect leader to force view change
+	nodes[0].Disconnect()
+
+	// Wait for view change and ensure in-flight view has committed the previous decision
+	inFlightCommit.Wait()
+
+	// Re-connect the leader
+	nodes[0].Connect()
+
+	// Ensure that only nodes 1 to 3 have committed in-flight
+	d1 := <-nodes[0].Delivered
+	d2 := <-nodes[1].Delivered
+	d3 := <-nodes[2].Delivered
+	select {
+	case <-nodes[3].Delivered:
+		t.Fatalf("Node 4 has committed but shouldn't have")
+	case <-time.After(time.Second):
+	}
+
+	// Ensure all nodes that committed, committed the same decision
+	assert.Equal(t, d1, d2)
+	assert.Equal(t, d2, d3)
+
+	// Wait for in-flight view of view changer to time out
+	timeoutExpiredWG.Wait()
+	// Re-enable commits to flow to the last node
+	atomic.StoreUint32(&blockCommitsForLastNode, 0)
+	// Ensure the last node failed changing the view
+	failedViewChange.Wait()
+	// However, it eventually syncs successfully and delivers the proposal
+	select {
+	case d4 := <-nodes[3].Delivered:
+		assert.Equal(t, d3, d4)
+	case <-time.After(time.Second * 30):
+		t.Fatalf("Didn't commit decision in a timely manner")
+	}
+}
+
+func TestBlacklistAndRedemption(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 10
+	var nodes []*App
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, true, 1)
+		nodes = append(nodes, n)
+	}
+
+	var viewChangedWG sync.WaitGroup
+	viewChangedWG.Add(len(nodes) - 1)
+
+	var blacklistPrunedWG sync.WaitGroup
+	blacklistPrunedWG.Add(len(nodes) + 1) // Once at proposing, n times at verification
+
+	var redemptionWG sync.WaitGroup
+	redemptionWG.Add(len(nodes))
+
+	start := time.Now()
+	for _, n := range nodes {
+		l := n.logger.Desugar()
+		n.logger = l.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if strings.Contains(entry.Message, "Starting view with number 1, sequence 1,") {
+				viewChangedWG.Done()
+			}
+			if strings.Contains(entry.Message, "Blacklist changed: [1] --> []") {
+				blacklistPrunedWG.Done()
+			}
+			if strings.Contains(entry.Message, "Rotating leader from 10 to 1") {
+				redemptionWG.Done()
+			}
+			return nil
+		})).Sugar()
+		n.heartbeatTime = make(chan time.Time, 1)
+		n.heartbeatTime <- start
+		n.viewChangeTime = make(chan time.Time, 1)
+		n.viewChangeTime <- start
+		n.Setup()
+	}
+
+	startNodes(nodes, network)
+
+	nodes[0].Disconnect() // Leader is in partition
+
+	// Accelerate the time until a view change
+	done := make(chan struct{})
+	var counter uint64
+	accelerateTime(nodes[1:], done, true, true, &counter)
+
+	// Wait for view change
+	viewChangedWG.Wait()
+	close(done)
+
+	// Rotate the leader and ensure the view doesn't change, which proves
+	// node 1 never became the leader again
+	for j := 0; j < numberOfNodes; j++ {
+		nodes[1].Submit(Request{ID: fmt.Sprintf("%d", j), ClientID: "alice"})
+		for i := 1; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+	}
+
+	// Ensure we remain on view 1, and that node 1 is in the blacklist
+	md := &smartbftprotos.ViewMetadata{}
+	err = proto.Unmarshal(nodes[1].lastDecision.Proposal.Metadata, md)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), md.ViewId)
+	assert.Equal(t, []uint64{1}, md.BlackList)
+
+	// Next, re-connect node 1
+	nodes[0].Connect()
+
+	// Accelerate time to wait for it to synchronize
+	done = make(chan struct{})
+	accelerateTime(nodes, done, true, true, &counter)
+	for j := 0; j < numberOfNodes; j++ {
+		<-nodes[0].Delivered
+	}
+	close(done)
+
+	// Rotate the leader and ensure the view doesn't change,
+	// but this time we want to ensure that node 1 became the leader
+	// again at some point, and that it has been deleted from the blacklist.
+	stop := make(chan struct{})
+	f := func() {
+		txID := make([]byte, 15)
+		rand.Read(txID)
+		nodes[1].Submit(Request{ID: hex.EncodeToString(txID), ClientID: "alice"})
+		for i := 0; i < len(nodes); i++ {
+			select {
+			case <-nodes[i].Delivered:
+			case <-stop:
+				return
+			}
+		}
+		md := &smartbftprotos.ViewMetadata{}
+		err = proto.Unmarshal(nodes[1].lastDecision.Proposal.Metadata, md)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), md.ViewId)
+	}
+	go doInBackground(f, stop)
+	blacklistPrunedWG.Wait()
+	redemptionWG.Wait()
+	close(stop)
+}
+
+func TestBlacklistMultipleViewChanges(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 10
+	var nodes []*App
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, true, 1)
+		nodes = append(nodes, n)
+	}
+
+	var viewChangedWG sync.WaitGroup
+	viewChangedWG.Add(len(nodes) - 2)
+
+	var node2RemovedFromBlacklist sync.WaitGroup
+	node2RemovedFromBlacklist.Add(len(nodes) + 1)
+
+	var node3RemovedFromBlacklist sync.WaitGroup
+	node3RemovedFromBlacklist.Add(len(nodes) + 1)
+
+	start := time.Now()
+	for _, n := range nodes {
+		l := n.logger.Desugar()
+		n.logger = l.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if strings.Contains(entry.Message, "Starting view with number 3, sequence 2,") {
+				viewChangedWG.Done()
+			}
+			if strings.Contains(entry.Message, "Node 2 was observed sending a prepare") && strings.Contains(entry.Message, "removing it from blacklist") {
+				node2RemovedFromBlacklist.Done()
+			}
+			if strings.Contains(entry.Message, "Node 3 was observed sending a prepare") && strings.Contains(entry.Message, "removing it from blacklist") {
+				node3RemovedFromBlacklist.Done()
+			}
+			return nil
+		})).Sugar()
+		n.heartbeatTime = make(chan time.Time, 1)
+		n.heartbeatTime <- start
+		n.viewChangeTime = make(chan time.Time, 1)
+		n.viewChangeTime <- start
+		n.Setup()
+	}
+
+	startNodes(nodes, network)
+
+	// Put a single decision
+	nodes[0].Submit(Request{ID: "genesis", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+
+	// Put the next and the next nodes in partition
+	nodes[1].Disconnect()
+	nodes[2].Disconnect()
+
+	// Accelerate the time until a view change
+	done := make(chan struct{})
+	var acceleratedNodes []*App
+	acceleratedNodes = append(acceleratedNodes, nodes[0])
+	acceleratedNodes = append(acceleratedNodes, nodes[2:]...)
+	var counter uint64
+	accelerateTime(acceleratedNodes, done, true, true, &counter)
+
+	// Wait for two view changes
+	viewChangedWG.Wait()
+	close(done)
+
+	// Rotate the leader and ensure the view doesn't change, which proves
+	// nodes 2 and 3 never became the leader again
+	for j := 0; j < numberOfNodes; j++ {
+		nodes[5].Submit(Request{ID: fmt.Sprintf("%d", j), ClientID: "alice"})
+		for i := 3; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+	}
+
+	// Ensure we remain on view 2, and that nodes 2,3 are in the blacklist
+	md := &smartbftprotos.ViewMetadata{}
+	err = proto.Unmarshal(nodes[5].lastDecision.Proposal.Metadata, md)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), md.ViewId)
+	assert.Equal(t, []uint64{2, 3}, md.BlackList)
+
+	// Next, re-connect nodes 2,3
+	nodes[1].Connect()
+	nodes[2].Connect()
+
+	// Accelerate time to wait for them to synchronize
+	done = make(chan struct{})
+	accelerateTime(nodes, done, true, true, &counter)
+	for j := 0; j < numberOfNodes; j++ {
+		<-nodes[1].Delivered
+		<-nodes[2].Delivered
+	}
+	close(done)
+
+	// Rotate the leader and ensure the view doesn't change,
+	stop := make(chan struct{})
+	f := func() {
+		txID := make([]byte, 15)
+		rand.Read(txID)
+		nodes[3].Submit(Request{ID: hex.EncodeToString(txID), ClientID: "alice"})
+		for i := 0; i < len(nodes); i++ {
+			select {
+			case <-nodes[i].Delivered:
+			case <-stop:
+				return
+			}
+		}
+		md := &smartbftprotos.ViewMetadata{}
+		err = proto.Unmarshal(nodes[3].lastDecision.Proposal.Metadata, md)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(3), md.ViewId)
+	}
+	go doInBackground(f, stop)
+	node2RemovedFromBlacklist.Wait()
+	node3RemovedFromBlacklist.Wait()
+	close(stop)
+}
+
+func TestNodeInFlightThenViewChange(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+
+	var blockCommits uint32
+
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.LoseMessages(func(msg *smartbftprotos.Message) bool {
+			if msg.GetCommit() == nil {
+				return false
+			}
+			return atomic.LoadUint32(&blockCommits) == 1
+		})
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	atomic.StoreUint32(&blockCommits, 1)
+
+	nodes[0].Submit(Request{ID: "first request", ClientID: "alice"})
+
+	// Ensure no one commits a block because of not enough commits gathered
+	var wg sync.WaitGroup
+	wg.Add(numberOfNodes)
+
+	for i := 0; i < numberOfNodes; i++ {
+		go func(deliverChannel chan *AppRecord) {
+			defer wg.Done()
+			select {
+			case <-deliverChannel:
+				assert.Fail(t, "should not have committed because first commit message should have been lost")
+			case <-time.After(time.Second):
+			}
+		}(nodes[i].Delivered)
+	}
+
+	wg.Wait()
+	atomic.StoreUint32(&blockCommits, 0)
+
+	nodes[len(nodes)-1].Disconnect() // Last node in partition
+
+	// Submit second request to all nodes to trigger view change
+	nodes[0].Submit(Request{ID: "second request", ClientID: "alice"})
+	nodes[1].Submit(Request{ID: "second request", ClientID: "alice"})
+	nodes[2].Submit(Request{ID: "second request", ClientID: "alice"})
+
+	for i := 0; i < numberOfNodes-1; i++ {
+		<-nodes[i].Delivered // first request
+		<-nodes[i].Delivered // second request
+	}
+
+	// Submit a third request to one of the nodes
+	nodes[0].Submit(Request{ID: "third request", ClientID: "alice"})
+
+	for i := 0; i < numberOfNodes-1; i++ {
+		<-nodes[i].Delivered
+	}
+
+	// Reconnect the offline node and wait for it to sync
+	nodes[len(nodes)-1].Connect()
+	<-nodes[len(nodes)-1].Delivered
+	<-nodes[len(nodes)-1].Delivered
+	<-nodes[len(nodes)-1].Delivered
+
+	// Disconnect leader
+	nodes[1].Disconnect()
+
+	// Submit fourth request to all nodes to trigger view change
+	nodes[0].Submit(Request{ID: "fourth request", ClientID: "alice"})
+	nodes[2].Submit(Request{ID: "fourth request", ClientID: "alice"})
+	nodes[3].Submit(Request{ID: "fourth request", ClientID: "alice"})
+
+	// Wait for a view change and a commit
+	<-nodes[0].Delivered
+	<-nodes[2].Delivered
+	<-nodes[3].Delivered
+}
+
+func TestNodeCommitTheRestPrepareAndCommittedNodeCrashesThenRecovers(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+
+	var blockCommits uint32
+
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		// All but the leader don't receive any commits
+		if i != 1 {
+			n.LoseMessages(func(msg *smartbftprotos.Message) bool {
+				if msg.GetCommit() == nil {
+					return false
+				}
+				return atomic.LoadUint32(&blockCommits) == 1
+			})
+		}
+
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	atomic.StoreUint32(&blockCommits, 1)
+
+	nodes[0].Submit(Request{ID: "first request", ClientID: "alice"})
+
+	firstReqs := make(map[string]struct{})
+
+	// Ensure the leader commits
+	cmt := <-nodes[0].Delivered
+	firstReqs[string(cmt.Batch.toBytes())] = struct{}{}
+
+	// Disconnect the leader
+	nodes[0].Disconnect()
+
+	// Unblock commits
+	atomic.StoreUint32(&blockCommits, 0)
+
+	// Submit second request to all nodes to trigger view change
+	nodes[1].Submit(Request{ID: "second request", ClientID: "alice"})
+	nodes[2].Submit(Request{ID: "second request", ClientID: "alice"})
+	nodes[3].Submit(Request{ID: "second request", ClientID: "alice"})
+
+	secondReqs := make(map[string]struct{})
+	// Nodes should commit both decisions
+	for i := 1; i < numberOfNodes; i++ {
+		first := <-nodes[i].Delivered  // first request
+		second := <-nodes[i].Delivered // second request
+		firstReqs[string(first.Batch.toBytes())] = struct{}{}
+		secondReqs[string(second.Batch.toBytes())] = struct{}{}
+	}
+
+	// Reconnect the old leader
+	nodes[0].Connect()
+
+	// Wait for the old leader to sync
+	cmt = <-nodes[0].Delivered // second request
+	secondReqs[string(cmt.Batch.toBytes())] = struct{}{}
+
+	// Disconnect the new leader
+	nodes[1].Disconnect()
 
-	// Accelerate time to wait for it to synchronize
-	done = make(chan struct{})
-	accelerateTime(nodes, done, true, true, &counter)
-	for j := 0; j < numberOfNodes; j++ {
-		<-nodes[0].Delivered
+	// Submit a third request to force view change
+	nodes[0].Submit(Request{ID: "third request", ClientID: "alice"})
+	nodes[2].Submit(Request{ID: "third request", ClientID: "alice"})
+	nodes[3].Submit(Request{ID: "third request", ClientID: "alice"})
+
+	// Wait for remaining nodes to commit
+	<-nodes[0].Delivered
+	<-nodes[2].Delivered
+	<-nodes[3].Delivered
+
+	assert.Len(t, firstReqs, 1)
+	assert.Len(t, secondReqs, 1)
+}
+
+func TestNodePreparesTheRestInPartitionThenPartitionHeals(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+
+	var blockPrepares uint32
+
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		// All but the leader don't receive any prepares
+		if i != 1 {
+			n.LoseMessages(func(msg *smartbftprotos.Message) bool {
+				if msg.GetPrepare() == nil {
+					return false
+				}
+				return atomic.LoadUint32(&blockPrepares) == 1
+			})
+		}
+
+		nodes = append(nodes, n)
 	}
-	close(done)
 
-	// Rotate the leader and ensure the view doesn't change,
-	// but this time we want to ensure that node 1 became the leader
-	// again at some point, and that it has been deleted from the blacklist.
-	stop := make(chan struct{})
-	f := func() {
-		txID := make([]byte, 15)
-		rand.Read(txID)
-		nodes[1].Submit(Request{ID: hex.EncodeToString(txID), ClientID: "alice"})
-		for i := 0; i < len(nodes); i++ {
-			select {
-			case <-nodes[i].Delivered:
-			case <-stop:
-				return
-			}
+	// Notice node 0's WAL append when receiving enough prepares
+	var wg sync.WaitGroup
+	wg.Add(2) // One for pre-prepare reception, one for reception of quorum of prepares
+
+	baseLogger := nodes[0].logger.Desugar()
+	nodes[0].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		// Only make not of log appends if we are interested in blocking prepares
+		if atomic.LoadUint32(&blockPrepares) == 0 {
+			return nil
 		}
-		md := &smartbftprotos.ViewMetadata{}
-		err = proto.Unmarshal(nodes[1].lastDecision.Proposal.Metadata, md)
-		assert.NoError(t, err)
-		assert.Equal(t, uint64(1), md.ViewId)
+		if strings.Contains(entry.Message, "LogRecord appended successfully") {
+			wg.Done()
+		}
+		return nil
+	})).Sugar()
+	nodes[0].Setup()
+
+	startNodes(nodes, network)
+
+	atomic.StoreUint32(&blockPrepares, 1)
+
+	nodes[0].Submit(Request{ID: "first request", ClientID: "alice"})
+
+	// Wait for node 0 to receive both pre-prepare and a quorum of prepares
+	wg.Wait()
+
+	// Disconnect node 0
+	nodes[0].Disconnect()
+
+	atomic.StoreUint32(&blockPrepares, 0)
+
+	// Submit second request to all nodes to trigger view change
+	nodes[1].Submit(Request{ID: "second request", ClientID: "alice"})
+	nodes[2].Submit(Request{ID: "second request", ClientID: "alice"})
+	nodes[3].Submit(Request{ID: "second request", ClientID: "alice"})
+
+	secondReqs := make(map[string]struct{})
+	// Nodes should commit the second request only
+	for i := 1; i < numberOfNodes; i++ {
+		second := <-nodes[i].Delivered // second request
+		secondReqs[string(second.Batch.toBytes())] = struct{}{}
 	}
-	go doInBackground(f, stop)
-	blacklistPrunedWG.Wait()
-	redemptionWG.Wait()
-	close(stop)
+
+	// Reconnect the old leader
+	nodes[0].Connect()
+
+	// Wait for the old leader to sync
+	cmt := <-nodes[0].Delivered // second request
+	secondReqs[string(cmt.Batch.toBytes())] = struct{}{}
+
+	// Disconnect the new leader
+	nodes[1].Disconnect()
+
+	// Submit a third request to force view change
+	nodes[0].Submit(Request{ID: "third request", ClientID: "alice"})
+	nodes[2].Submit(Request{ID: "third request", ClientID: "alice"})
+	nodes[3].Submit(Request{ID: "third request", ClientID: "alice"})
+
+	// Wait for remaining nodes to commit
+	<-nodes[0].Delivered
+	<-nodes[2].Delivered
+	<-nodes[3].Delivered
+
+	assert.Len(t, secondReqs, 1)
 }
 
-func TestBlacklistMultipleViewChanges(t *testing.T) {
+// TestViewChangeAfterTryingToFork - there is some redundancy in the test,
+// but it is a reminder of the conditions under which the error was detected.
+// This is the first time the 7th node is disconnected and it is connected back in.
+// It turned out that at startup one node self-deleted from the consensus.
+// This situation can be fixed by adding and configuring backoff between nodes
+// (for example, this should be added to fabric,
+// the recommended parameters ORDERER_GENERAL_BACKOFF_MAXDELAY: 20s)
+// In this test, we disable 3 nodes and destroy the quorum.
+// Throw the transaction. And after unsuccessful change of leader (no quorum),
+// reconnect 3 nodes. Quorum must be assembled, leader must be successfully
+// changed and transaction must be accepted.
+func TestViewChangeAfterTryingToFork(t *testing.T) {
 	t.Parallel()
+
+	// wait for the new leader to finish the view change before submitting
+	done := make(chan struct{})
+	defer close(done)
+
 	network := NewNetwork()
 	defer network.Shutdown()
 
@@ -2097,122 +3905,247 @@ func TestBlacklistMultipleViewChanges(t *testing.T) {
 	assert.NoErrorf(t, err, "generate temporary test dir")
 	defer os.RemoveAll(testDir)
 
-	numberOfNodes := 10
-	var nodes []*App
+	numberOfNodes := 7
+	nodes := make([]*App, 0, 7)
+
 	for i := 1; i <= numberOfNodes; i++ {
-		n := newNode(uint64(i), network, t.Name(), testDir, true, 1)
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
 		nodes = append(nodes, n)
 	}
 
-	var viewChangedWG sync.WaitGroup
-	viewChangedWG.Add(len(nodes) - 2)
+	start := time.Now()
 
-	var node2RemovedFromBlacklist sync.WaitGroup
-	node2RemovedFromBlacklist.Add(len(nodes) + 1)
+	for _, n := range nodes {
+		n.heartbeatTime = make(chan time.Time, 1)
+		n.heartbeatTime <- start
+		n.viewChangeTime = make(chan time.Time, 1)
+		n.viewChangeTime <- start
+		n.LoseMessages(func(msg *smartbftprotos.Message) bool {
+			return false
+		})
+		n.Setup()
+	}
 
-	var node3RemovedFromBlacklist sync.WaitGroup
-	node3RemovedFromBlacklist.Add(len(nodes) + 1)
+	var once sync.Once
+
+	addFirstViewCh := make(chan struct{}, 6)
+	addFirstViewChMap := make(map[uint64]struct{}, 6)
+	addFirstSync := sync.RWMutex{}
+
+	viewChange1Ch := make(chan struct{}, 4)
+	viewChange1Map := make(map[uint64]struct{}, 4)
+	viewChange1Sync := sync.RWMutex{}
+
+	viewChange2Ch := make(chan struct{}, 4)
+	viewChange2Map := make(map[uint64]struct{}, 4)
+	viewChange2Sync := sync.RWMutex{}
+
+	realViewChangeCh := make(chan struct{}, 7)
+	realViewChangeMap := make(map[uint64]struct{}, 7)
+	realViewChangeSync := sync.RWMutex{}
+
+	for i := 0; i < numberOfNodes; i++ {
+		id := nodes[i].ID
+		baseLogger := nodes[i].Node.app.Consensus.Logger.(*zap.SugaredLogger).Desugar()
+		nodes[i].Node.app.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if strings.Contains(entry.Message, "Starting view with number") &&
+				!strings.Contains(entry.Message, "Starting view with number 0") {
+				// After returning all the nodes,
+				// we fix that a new view has started, but not the very first one
+				realViewChangeSync.RLock()
+				_, hasVoted := realViewChangeMap[id]
+				realViewChangeSync.RUnlock()
+
+				if !hasVoted {
+					realViewChangeSync.Lock()
+					_, hasVoted = realViewChangeMap[id]
+					if !hasVoted {
+						realViewChangeMap[id] = struct{}{}
+						realViewChangeCh <- struct{}{}
+					}
+					realViewChangeSync.Unlock()
+				}
+			}
+
+			if id != 7 {
+				// We wait for the node, after no heartbeat, to throw its vote for the leader change.
+				// Then we will turn this node off anyway, so that we can't use it to complete the leader change.
+				if strings.Contains(entry.Message, "is processing a view change message") &&
+					strings.Contains(entry.Message, "from 7") {
+					addFirstSync.RLock()
+					_, hasVoted := addFirstViewChMap[id]
+					addFirstSync.RUnlock()
+
+					if !hasVoted {
+						addFirstSync.Lock()
+						_, hasVoted = addFirstViewChMap[id]
+						if !hasVoted {
+							addFirstViewChMap[id] = struct{}{}
+							addFirstViewCh <- struct{}{}
+						}
+						addFirstSync.Unlock()
+					}
+				}
+			}
+
+			if id >= 1 && id <= 4 {
+				// All live nodes want to change the leader
+				if strings.Contains(entry.Message, "sent view data msg, with next view 1") {
+					viewChange1Sync.RLock()
+					_, hasVoted := viewChange1Map[id]
+					viewChange1Sync.RUnlock()
+
+					if !hasVoted {
+						viewChange1Sync.Lock()
+						_, hasVoted = viewChange1Map[id]
+						if !hasVoted {
+							viewChange1Map[id] = struct{}{}
+							viewChange1Ch <- struct{}{}
+						}
+						viewChange1Sync.Unlock()
+					}
+				}
+				// All live nodes have begun the leader change procedure
+				if strings.Contains(entry.Message, "started view change, last view is 1") {
+					viewChange2Sync.RLock()
+					_, hasVoted := viewChange2Map[id]
+					viewChange2Sync.RUnlock()
 
-	start := time.Now()
-	for _, n := range nodes {
-		l := n.logger.Desugar()
-		n.logger = l.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if strings.Contains(entry.Message, "Starting view with number 3, sequence 2,") {
-				viewChangedWG.Done()
-			}
-			if strings.Contains(entry.Message, "Node 2 was observed sending a prepare") && strings.Contains(entry.Message, "removing it from blacklist") {
-				node2RemovedFromBlacklist.Done()
+					if !hasVoted {
+						viewChange2Sync.Lock()
+						_, hasVoted = viewChange2Map[id]
+						if !hasVoted {
+							viewChange2Map[id] = struct{}{}
+							viewChange2Ch <- struct{}{}
+						}
+						viewChange2Sync.Unlock()
+					}
+				}
 			}
-			if strings.Contains(entry.Message, "Node 3 was observed sending a prepare") && strings.Contains(entry.Message, "removing it from blacklist") {
-				node3RemovedFromBlacklist.Done()
+
+			if id == 7 {
+				// Emulation of node removal from the consensus in the absence of heartbeat
+				if strings.Contains(entry.Message, "Heartbeat timeout expired") {
+					once.Do(func() {
+						nodes[6].Connect()
+					})
+				}
 			}
 			return nil
 		})).Sugar()
-		n.heartbeatTime = make(chan time.Time, 1)
-		n.heartbeatTime <- start
-		n.viewChangeTime = make(chan time.Time, 1)
-		n.viewChangeTime <- start
-		n.Setup()
 	}
 
 	startNodes(nodes, network)
 
-	// Put a single decision
-	nodes[0].Submit(Request{ID: "genesis", ClientID: "alice"})
-	for i := 0; i < numberOfNodes; i++ {
-		<-nodes[i].Delivered
+	var counter uint64
+	accelerateTime(nodes, done, true, true, &counter)
+
+	// Emulation of node removal from the consensus in the absence of heartbeat
+	nodes[6].Disconnect()
+
+	// Expect all nodes to see the 7th node's proposal to change the leader
+	for i := 0; i < 6; i++ {
+		<-addFirstViewCh
 	}
 
-	// Put the next and the next nodes in partition
-	nodes[1].Disconnect()
-	nodes[2].Disconnect()
+	// Breaking consensus for all operations: performing a transaction and changing a view
+	nodes[6].Disconnect()
+	nodes[5].Disconnect()
+	nodes[4].Disconnect()
 
-	// Accelerate the time until a view change
-	done := make(chan struct{})
-	var acceleratedNodes []*App
-	acceleratedNodes = append(acceleratedNodes, nodes[0])
-	acceleratedNodes = append(acceleratedNodes, nodes[2:]...)
-	var counter uint64
-	accelerateTime(acceleratedNodes, done, true, true, &counter)
+	for i := 0; i < numberOfNodes-3; i++ {
+		nodes[i].Submit(Request{ID: "1", ClientID: "alice"}) // submit to other nodes
+	}
 
-	// Wait for two view changes
-	viewChangedWG.Wait()
-	close(done)
+	// Expect the desire of all living nodes to change the leader
+	fail := time.After(1 * time.Minute)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-viewChange1Ch:
+		case <-fail:
+			t.Fatal("Didn't change view 1")
+		}
+	}
 
-	// Rotate the leader and ensure the view doesn't change, which proves
-	// nodes 2 and 3 never became the leader again
-	for j := 0; j < numberOfNodes; j++ {
-		nodes[5].Submit(Request{ID: fmt.Sprintf("%d", j), ClientID: "alice"})
-		for i := 3; i < numberOfNodes; i++ {
-			<-nodes[i].Delivered
+	// Expect leader changes to begin from all live nodes
+	fail = time.After(1 * time.Minute)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-viewChange2Ch:
+		case <-fail:
+			t.Fatal("Didn't change view 2")
 		}
 	}
 
-	// Ensure we remain on view 2, and that nodes 2,3 are in the blacklist
-	md := &smartbftprotos.ViewMetadata{}
-	err = proto.Unmarshal(nodes[5].lastDecision.Proposal.Metadata, md)
-	assert.NoError(t, err)
-	assert.Equal(t, uint64(3), md.ViewId)
-	assert.Equal(t, []uint64{2, 3}, md.BlackList)
+	// Return the nodes to the consensus
+	nodes[6].Connect()
+	nodes[5].Connect()
+	nodes[4].Connect()
 
-	// Next, re-connect nodes 2,3
-	nodes[1].Connect()
-	nodes[2].Connect()
+	// Waiting for a real change of leader and view
+	fail = time.After(realViewChangeTimeout)
+	for i := 0; i < 7; i++ {
+		select {
+		case <-realViewChangeCh:
+		case <-fail:
+			t.Fatal("Didn't change real view")
+		}
+	}
 
-	// Accelerate time to wait for them to synchronize
-	done = make(chan struct{})
-	accelerateTime(nodes, done, true, true, &counter)
-	for j := 0; j < numberOfNodes; j++ {
-		<-nodes[1].Delivered
-		<-nodes[2].Delivered
+	data := make([]*AppRecord, 0, 7)
+	storeI := -1
+	fail = time.After(1 * time.Minute)
+ExternalLoop:
+	for i := 0; i < numberOfNodes; i++ {
+		select {
+		case d := <-nodes[i].Delivered:
+			data = append(data, d)
+		case <-fail:
+			storeI = i
+			break ExternalLoop
+		}
 	}
-	close(done)
 
-	// Rotate the leader and ensure the view doesn't change,
-	stop := make(chan struct{})
-	f := func() {
-		txID := make([]byte, 15)
-		rand.Read(txID)
-		nodes[3].Submit(Request{ID: hex.EncodeToString(txID), ClientID: "alice"})
-		for i := 0; i < len(nodes); i++ {
-			select {
-			case <-nodes[i].Delivered:
-			case <-stop:
-				return
-			}
+	if storeI != -1 {
+		for i := 0; i < numberOfNodes; i++ {
+			nodes[i].Submit(Request{ID: "2", ClientID: "bob"})
+		}
+
+		for i := storeI; i < numberOfNodes; i++ {
+			d := <-nodes[i].Delivered
+			data = append(data, d)
 		}
-		md := &smartbftprotos.ViewMetadata{}
-		err = proto.Unmarshal(nodes[3].lastDecision.Proposal.Metadata, md)
-		assert.NoError(t, err)
-		assert.Equal(t, uint64(3), md.ViewId)
 	}
-	go doInBackground(f, stop)
-	node2RemovedFromBlacklist.Wait()
-	node3RemovedFromBlacklist.Wait()
-	close(stop)
+
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+
+	if storeI == -1 {
+		return
+	}
+
+	data1 := make([]*AppRecord, 0, 7)
+	fail = time.After(1 * time.Minute)
+	for i := 0; i < numberOfNodes; i++ {
+		select {
+		case d := <-nodes[i].Delivered:
+			data1 = append(data1, d)
+		case <-fail:
+			t.Fatal("Didn't deliver two msg")
+		}
+	}
+
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data1[i], data1[i+1])
+	}
 }
 
-func TestNodeInFlightThenViewChange(t *testing.T) {
+func TestFetchStateWhenSyncReturnsPrevView(t *testing.T) {
+	// Scenario: A node (node 2) becomes the leader but disconnects inducing a second view change.
+	// When it reconnects, with no transactions available, it syncs after receiving multiple heartbeat responses.
+	// However, the Synchronizer returns with an old view, and so for the node to catch up it needs to fetch the state.
+
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -2223,168 +4156,133 @@ func TestNodeInFlightThenViewChange(t *testing.T) {
 
 	numberOfNodes := 4
 	nodes := make([]*App, 0)
-
-	var blockCommits uint32
-
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
-		n.LoseMessages(func(msg *smartbftprotos.Message) bool {
-			if msg.GetCommit() == nil {
-				return false
-			}
-			return atomic.LoadUint32(&blockCommits) == 1
-		})
 		nodes = append(nodes, n)
 	}
-	startNodes(nodes, network)
-
-	atomic.StoreUint32(&blockCommits, 1)
-
-	nodes[0].Submit(Request{ID: "first request", ClientID: "alice"})
-
-	// Ensure no one commits a block because of not enough commits gathered
-	var wg sync.WaitGroup
-	wg.Add(numberOfNodes)
-
-	for i := 0; i < numberOfNodes; i++ {
-		go func(deliverChannel chan *AppRecord) {
-			defer wg.Done()
-			select {
-			case <-deliverChannel:
-				assert.Fail(t, "should not have committed because first commit message should have been lost")
-			case <-time.After(time.Second):
-			}
-		}(nodes[i].Delivered)
-	}
-
-	wg.Wait()
-	atomic.StoreUint32(&blockCommits, 0)
-
-	nodes[len(nodes)-1].Disconnect() // Last node in partition
-
-	// Submit second request to all nodes to trigger view change
-	nodes[0].Submit(Request{ID: "second request", ClientID: "alice"})
-	nodes[1].Submit(Request{ID: "second request", ClientID: "alice"})
-	nodes[2].Submit(Request{ID: "second request", ClientID: "alice"})
-
-	for i := 0; i < numberOfNodes-1; i++ {
-		<-nodes[i].Delivered // first request
-		<-nodes[i].Delivered // second request
-	}
 
-	// Submit a third request to one of the nodes
-	nodes[0].Submit(Request{ID: "third request", ClientID: "alice"})
-
-	for i := 0; i < numberOfNodes-1; i++ {
-		<-nodes[i].Delivered
+	start := time.Now()
+	for _, n := range nodes {
+		n.heartbeatTime = make(chan time.Time, 1)
+		n.heartbeatTime <- start
+		n.Setup()
 	}
 
-	// Reconnect the offline node and wait for it to sync
-	nodes[len(nodes)-1].Connect()
-	<-nodes[len(nodes)-1].Delivered
-	<-nodes[len(nodes)-1].Delivered
-	<-nodes[len(nodes)-1].Delivered
-
-	// Disconnect leader
-	nodes[1].Disconnect()
-
-	// Submit fourth request to all nodes to trigger view change
-	nodes[0].Submit(Request{ID: "fourth request", ClientID: "alice"})
-	nodes[2].Submit(Request{ID: "fourth request", ClientID: "alice"})
-	nodes[3].Submit(Request{ID: "fourth request", ClientID: "alice"})
+	// wait for the new leader to finish the view change before submitting
+	done := make(chan struct{})
+	viewChangeWG := sync.WaitGroup{}
+	viewChangeWG.Add(numberOfNodes - 1)
 
-	// Wait for a view change and a commit
-	<-nodes[0].Delivered
-	<-nodes[2].Delivered
-	<-nodes[3].Delivered
-}
+	syncWG := sync.WaitGroup{}
+	syncWG.Add(1)
 
-func TestNodeCommitTheRestPrepareAndCommittedNodeCrashesThenRecovers(t *testing.T) {
-	t.Parallel()
-	network := NewNetwork()
-	defer network.Shutdown()
+	ignoreWG := sync.WaitGroup{}
+	ignoreWG.Add(1)
+	ignoreOnce := sync.Once{}
 
-	testDir, err := os.MkdirTemp("", t.Name())
-	assert.NoErrorf(t, err, "generate temporary test dir")
-	defer os.RemoveAll(testDir)
+	stateWG := sync.WaitGroup{}
+	stateWG.Add(1)
 
-	numberOfNodes := 4
-	nodes := make([]*App, 0)
+	for _, n := range nodes {
+		id := n.ID
+		baseLogger := n.Consensus.Logger.(*zap.SugaredLogger).Desugar()
+		n.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if strings.Contains(entry.Message, "ViewChanged") {
+				viewChangeWG.Done()
+			}
 
-	var blockCommits uint32
+			if strings.Contains(entry.Message, "Received HeartBeatResponse triggered a call to HeartBeatEventHandler Sync") {
+				syncWG.Done()
+			}
 
-	for i := 1; i <= numberOfNodes; i++ {
-		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
-		// All but the leader don't receive any commits
-		if i != 1 {
-			n.LoseMessages(func(msg *smartbftprotos.Message) bool {
-				if msg.GetCommit() == nil {
-					return false
+			if strings.Contains(entry.Message, "Monitor already called Sync, ignoring HeartBeatResponse") {
+				if id == 2 {
+					ignoreOnce.Do(func() {
+						ignoreWG.Done()
+					})
 				}
-				return atomic.LoadUint32(&blockCommits) == 1
-			})
-		}
+			}
 
-		nodes = append(nodes, n)
+			if strings.Contains(entry.Message, "collected state with view 2 and sequence 2") {
+				stateWG.Done()
+			}
+
+			if strings.Contains(entry.Message, "but I am in view 1 and seq 2") {
+				panic("Didn't fetch state")
+			}
+
+			return nil
+		})).Sugar()
 	}
+
 	startNodes(nodes, network)
 
-	atomic.StoreUint32(&blockCommits, 1)
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
 
-	nodes[0].Submit(Request{ID: "first request", ClientID: "alice"})
+	data := make([]*AppRecord, 0)
+	for i := 1; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-2; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
 
-	firstReqs := make(map[string]struct{})
+	nodes[0].Disconnect() // leader in partition
 
-	// Ensure the leader commits
-	cmt := <-nodes[0].Delivered
-	firstReqs[string(cmt.Batch.toBytes())] = struct{}{}
+	// Accelerate the time until a view change because of heartbeat timeout
+	var counter uint64
+	accelerateTime(nodes, done, true, false, &counter)
 
-	// Disconnect the leader
-	nodes[0].Disconnect()
+	viewChangeWG.Wait()
+	close(done)
 
-	// Unblock commits
-	atomic.StoreUint32(&blockCommits, 0)
+	nodes[0].Connect() // connect after view changed
 
-	// Submit second request to all nodes to trigger view change
-	nodes[1].Submit(Request{ID: "second request", ClientID: "alice"})
-	nodes[2].Submit(Request{ID: "second request", ClientID: "alice"})
-	nodes[3].Submit(Request{ID: "second request", ClientID: "alice"})
+	done = make(chan struct{})
+	accelerateTime(nodes, done, true, false, &counter)
 
-	secondReqs := make(map[string]struct{})
-	// Nodes should commit both decisions
-	for i := 1; i < numberOfNodes; i++ {
-		first := <-nodes[i].Delivered  // first request
-		second := <-nodes[i].Delivered // second request
-		firstReqs[string(first.Batch.toBytes())] = struct{}{}
-		secondReqs[string(second.Batch.toBytes())] = struct{}{}
-	}
+	syncWG.Wait()
+	close(done)
 
-	// Reconnect the old leader
-	nodes[0].Connect()
+	syncWG.Add(1)
+	viewChangeWG.Add(3)
+	nodes[1].Disconnect() // leader in partition
 
-	// Wait for the old leader to sync
-	cmt = <-nodes[0].Delivered // second request
-	secondReqs[string(cmt.Batch.toBytes())] = struct{}{}
+	done = make(chan struct{})
+	accelerateTime(nodes, done, true, false, &counter)
 
-	// Disconnect the new leader
-	nodes[1].Disconnect()
+	viewChangeWG.Wait()
+	close(done)
 
-	// Submit a third request to force view change
-	nodes[0].Submit(Request{ID: "third request", ClientID: "alice"})
-	nodes[2].Submit(Request{ID: "third request", ClientID: "alice"})
-	nodes[3].Submit(Request{ID: "third request", ClientID: "alice"})
+	nodes[1].Connect()
 
-	// Wait for remaining nodes to commit
-	<-nodes[0].Delivered
-	<-nodes[2].Delivered
-	<-nodes[3].Delivered
+	done = make(chan struct{})
+	accelerateTime(nodes, done, true, false, &counter)
 
-	assert.Len(t, firstReqs, 1)
-	assert.Len(t, secondReqs, 1)
+	syncWG.Wait()
+	ignoreWG.Wait()
+	close(done)
+
+	stateWG.Wait()
+
+	for i := 0; i < numberOfNodes; i++ {
+		nodes[i].Submit(Request{ID: "2", ClientID: "alice"}) // submit to all nodes
+	}
+
+	data = make([]*AppRecord, 0)
+	for i := 1; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
+	for i := 0; i < numberOfNodes-2; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
 }
 
-func TestNodePreparesTheRestInPartitionThenPartitionHeals(t *testing.T) {
+func TestLeaderStopSendHeartbeat(t *testing.T) {
 	t.Parallel()
+
 	network := NewNetwork()
 	defer network.Shutdown()
 
@@ -2393,103 +4291,130 @@ func TestNodePreparesTheRestInPartitionThenPartitionHeals(t *testing.T) {
 	defer os.RemoveAll(testDir)
 
 	numberOfNodes := 4
-	nodes := make([]*App, 0)
-
-	var blockPrepares uint32
+	nodes := make([]*App, 0, 4)
 
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
-		// All but the leader don't receive any prepares
-		if i != 1 {
-			n.LoseMessages(func(msg *smartbftprotos.Message) bool {
-				if msg.GetPrepare() == nil {
-					return false
-				}
-				return atomic.LoadUint32(&blockPrepares) == 1
-			})
-		}
-
 		nodes = append(nodes, n)
 	}
 
-	// Notice node 0's WAL append when receiving enough prepares
-	var wg sync.WaitGroup
-	wg.Add(2) // One for pre-prepare reception, one for reception of quorum of prepares
+	start := time.Now()
 
-	baseLogger := nodes[0].logger.Desugar()
-	nodes[0].logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-		// Only make not of log appends if we are interested in blocking prepares
-		if atomic.LoadUint32(&blockPrepares) == 0 {
-			return nil
-		}
-		if strings.Contains(entry.Message, "LogRecord appended successfully") {
-			wg.Done()
-		}
-		return nil
-	})).Sugar()
-	nodes[0].Setup()
+	for _, n := range nodes {
+		n.heartbeatTime = make(chan time.Time, 1)
+		n.heartbeatTime <- start
+		n.viewChangeTime = make(chan time.Time, 1)
+		n.viewChangeTime <- start
+		n.Setup()
+	}
 
-	startNodes(nodes, network)
+	// wait for the new leader to finish the view change before submitting
+	done := make(chan struct{})
 
-	atomic.StoreUint32(&blockPrepares, 1)
+	prepareFirstCh := make(chan struct{}, 2)
+	prepareFirstMap := make(map[uint64]struct{}, 2)
+	prepareFirstSync := sync.RWMutex{}
 
-	nodes[0].Submit(Request{ID: "first request", ClientID: "alice"})
+	realViewChangeCh := make(chan struct{}, 4)
+	realViewChangeMap := make(map[uint64]struct{}, 4)
+	realViewChangeSync := sync.RWMutex{}
 
-	// Wait for node 0 to receive both pre-prepare and a quorum of prepares
-	wg.Wait()
+	for i := 0; i < numberOfNodes; i++ {
+		id := nodes[i].ID
+		baseLogger := nodes[i].Node.app.Consensus.Logger.(*zap.SugaredLogger).Desugar()
+		nodes[i].Node.app.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if strings.Contains(entry.Message, "Starting view with number") &&
+				!strings.Contains(entry.Message, "Starting view with number 0") {
+				realViewChangeSync.RLock()
+				_, hasVoted := realViewChangeMap[id]
+				realViewChangeSync.RUnlock()
 
-	// Disconnect node 0
-	nodes[0].Disconnect()
+				if !hasVoted {
+					realViewChangeSync.Lock()
+					_, hasVoted = realViewChangeMap[id]
+					if !hasVoted {
+						realViewChangeMap[id] = struct{}{}
+						realViewChangeCh <- struct{}{}
+					}
+					realViewChangeSync.Unlock()
+				}
+			}
 
-	atomic.StoreUint32(&blockPrepares, 0)
+			if (id == 1 || id == 2) &&
+				strings.Contains(entry.Message, "prepare") {
+				prepareFirstSync.RLock()
+				_, hasVoted := prepareFirstMap[id]
+				prepareFirstSync.RUnlock()
 
-	// Submit second request to all nodes to trigger view change
-	nodes[1].Submit(Request{ID: "second request", ClientID: "alice"})
-	nodes[2].Submit(Request{ID: "second request", ClientID: "alice"})
-	nodes[3].Submit(Request{ID: "second request", ClientID: "alice"})
+				if !hasVoted {
+					prepareFirstSync.Lock()
+					_, hasVoted = prepareFirstMap[id]
+					if !hasVoted {
+						prepareFirstMap[id] = struct{}{}
+						prepareFirstCh <- struct{}{}
+					}
+					prepareFirstSync.Unlock()
+				}
+			}
+
+			return nil
+		})).Sugar()
+	}
+
+	startNodes(nodes, network)
+
+	var counter uint64
+	accelerateTime(nodes, done, true, true, &counter)
+
+	nodes[2].Disconnect()
+	nodes[3].Disconnect()
 
-	secondReqs := make(map[string]struct{})
-	// Nodes should commit the second request only
-	for i := 1; i < numberOfNodes; i++ {
-		second := <-nodes[i].Delivered // second request
-		secondReqs[string(second.Batch.toBytes())] = struct{}{}
-	}
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
 
-	// Reconnect the old leader
-	nodes[0].Connect()
+	fail := time.After(1 * time.Minute)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-prepareFirstCh:
+		case <-fail:
+			t.Fatal("Didn't change view 1")
+		}
+	}
 
-	// Wait for the old leader to sync
-	cmt := <-nodes[0].Delivered // second request
-	secondReqs[string(cmt.Batch.toBytes())] = struct{}{}
+	nodes[2].Connect()
+	nodes[3].Connect()
 
-	// Disconnect the new leader
-	nodes[1].Disconnect()
+	fail = time.After(realViewChangeTimeout)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-realViewChangeCh:
+		case <-fail:
+			t.Fatal("Didn't change real view")
+		}
+	}
 
-	// Submit a third request to force view change
-	nodes[0].Submit(Request{ID: "third request", ClientID: "alice"})
-	nodes[2].Submit(Request{ID: "third request", ClientID: "alice"})
-	nodes[3].Submit(Request{ID: "third request", ClientID: "alice"})
+	close(done)
 
-	// Wait for remaining nodes to commit
-	<-nodes[0].Delivered
-	<-nodes[2].Delivered
-	<-nodes[3].Delivered
+	data := make([]*AppRecord, 0, 4)
+	for i := 0; i < numberOfNodes; i++ {
+		d := <-nodes[i].Delivered
+		data = append(data, d)
+	}
 
-	assert.Len(t, secondReqs, 1)
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
 }
 
-// TestViewChangeAfterTryingToFork - there is some redundancy in the test,
-// but it is a reminder of the conditions under which the error was detected.
-// This is the first time the 7th node is disconnected and it is connected back in.
-// It turned out that at startup one node self-deleted from the consensus.
-// This situation can be fixed by adding and configuring backoff between nodes
-// (for example, this should be added to fabric,
-// the recommended parameters ORDERER_GENERAL_BACKOFF_MAXDELAY: 20s)
-// In this test, we disable 3 nodes and destroy the quorum.
-// Throw the transaction. And after unsuccessful change of leader (no quorum),
-// reconnect 3 nodes. Quorum must be assembled, leader must be successfully
-// changed and transaction must be accepted.
-func TestViewChangeAfterTryingToFork(t *testing.T) {
+// TestTryCommittedSequenceTwice - is a modified copy of TestViewChangeAfterTryingToFork,
+// because it periodically recorded an error.
+// The difference from the parent is that there is work with new channels:
+// twiceDeliverBeginCh, deliverControlCh and deliverViewChangerCh,
+// After the quorum is restored, two "change of viewpoint" processes take place in parallel,
+// during which a transaction delivery and an attempt to deliver a transaction by the new leader can take place.
+// With the help of channels twiceDeliverBeginCh, deliverControlCh and deliverViewChangerCh
+// at the right moments of time, suspended or triggered the corresponding goroutines,
+// so that double delivery can take place
+func TestTryCommittedSequenceTwice(t *testing.T) {
 	t.Parallel()
 
 	// wait for the new leader to finish the view change before submitting
@@ -2501,13 +4426,16 @@ func TestViewChangeAfterTryingToFork(t *testing.T) {
 
 	testDir, err := os.MkdirTemp("", t.Name())
 	assert.NoErrorf(t, err, "generate temporary test dir")
-	defer os.RemoveAll(testDir)
+	defer func() {
+		_ = os.RemoveAll(testDir)
+	}()
 
 	numberOfNodes := 7
 	nodes := make([]*App, 0, 7)
 
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		// n.Consensus.Application
 		nodes = append(nodes, n)
 	}
 
@@ -2542,6 +4470,17 @@ func TestViewChangeAfterTryingToFork(t *testing.T) {
 	realViewChangeMap := make(map[uint64]struct{}, 7)
 	realViewChangeSync := sync.RWMutex{}
 
+	twiceDeliverBeginCh := make(chan struct{}, 2)
+	deliverControlCh := make(chan struct{})
+	deliverViewChangerCh := make(chan struct{})
+	go func() {
+		// 2. [twice] Waiting for the moment when both goroutines are ready to deliver the transaction
+		<-twiceDeliverBeginCh
+		<-twiceDeliverBeginCh
+		// 3. [twice] Started delivery in goroutine viewchanger
+		close(deliverViewChangerCh)
+	}()
+
 	for i := 0; i < numberOfNodes; i++ {
 		id := nodes[i].ID
 		baseLogger := nodes[i].Node.app.Consensus.Logger.(*zap.SugaredLogger).Desugar()
@@ -2549,7 +4488,7 @@ func TestViewChangeAfterTryingToFork(t *testing.T) {
 			if strings.Contains(entry.Message, "Starting view with number") &&
 				!strings.Contains(entry.Message, "Starting view with number 0") {
 				// After returning all the nodes,
-				// we fix that a new view has started, but not the very first one
+				// we fix that a new view has started, but not the very first one.
 				realViewChangeSync.RLock()
 				_, hasVoted := realViewChangeMap[id]
 				realViewChangeSync.RUnlock()
@@ -2629,6 +4568,36 @@ func TestViewChangeAfterTryingToFork(t *testing.T) {
 					})
 				}
 			}
+
+			if id == 4 {
+				// Suspension of gorutins and their launch to try to implement twice-delivery
+				if strings.Contains(entry.Message, "Delivering to app from deliverDecision the last decision proposal") {
+					// 1. [twice] Waited by the time the transaction was delivered
+					twiceDeliverBeginCh <- struct{}{}
+					// 4. [twice] Started delivery in goroutine "viewchanger"
+					<-deliverViewChangerCh
+				}
+				if strings.Contains(entry.Message, "Delivering to app from Decide the last decision proposal") {
+					// 1. [twice] Waited by the time the transaction was delivered
+					twiceDeliverBeginCh <- struct{}{}
+					// 4. [twice] Started delivery in goroutine "viewchanger"
+					<-deliverViewChangerCh
+				}
+				if strings.Contains(entry.Message, "Delivering end to app from deliverDecision the last decision proposal") {
+					// 5. [twice] "Viewchanger" delivered the transaction, let's pass the baton on
+					close(deliverControlCh)
+				}
+				if strings.Contains(entry.Message, "Delivering end to app from Decide the last decision proposal") {
+					// 5. [twice] "Viewchanger" delivered the transaction, let's pass the baton on
+					close(deliverControlCh)
+				}
+				if strings.Contains(entry.Message, "Delivering to app from Controller decide the last decision proposal") {
+					// 1. [twice] Waited by the time the transaction was delivered
+					twiceDeliverBeginCh <- struct{}{}
+					// 6. [twice] Started delivery in goroutine "control"
+					<-deliverControlCh
+				}
+			}
 			return nil
 		})).Sugar()
 	}
@@ -2739,11 +4708,175 @@ ExternalLoop:
 	}
 }
 
-func TestFetchStateWhenSyncReturnsPrevView(t *testing.T) {
-	// Scenario: A node (node 2) becomes the leader but disconnects inducing a second view change.
-	// When it reconnects, with no transactions available, it syncs after receiving multiple heartbeat responses.
-	// However, the Synchronizer returns with an old view, and so for the node to catch up it needs to fetch the state.
+func TestStartWithInvalidLastDecision(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.VerifyLastDecisionOnStart = true
+		nodes = append(nodes, n)
+	}
+
+	md, err := proto.Marshal(&smartbftprotos.ViewMetadata{LatestSequence: 1})
+	assert.NoError(t, err)
+	nodes[0].Consensus.LastProposal = types.Proposal{Metadata: md}
+	nodes[0].Consensus.LastSignatures = []types.Signature{{ID: 1}, {ID: 2}, {ID: 2}}
+	err = nodes[0].Consensus.Start()
+	assert.EqualError(t, err, "last decision is invalid: there are only 2 valid decision signatures out of 3 required")
+
+	// Starting from the genesis decision requires no signatures
+	nodes[0].Consensus.LastProposal = types.Proposal{}
+	nodes[0].Consensus.LastSignatures = nil
+	startNodes(nodes, network)
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+}
+
+func doInBackground(f func(), stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			f()
+		}
+	}
+}
+
+func countCommittedBatches(n *App) int {
+	var numBatchesCreated int
+	for {
+		select {
+		case <-n.Delivered:
+			numBatchesCreated++
+		case <-time.After(time.Millisecond * 500):
+			return numBatchesCreated
+		}
+	}
+}
+
+func requestIDFromBatch(record *AppRecord) int {
+	n, _ := strconv.ParseInt(requestFromBytes(record.Batch.Requests[0]).ID, 10, 32)
+	return int(n)
+}
+
+func waitForCatchup(targetReqID int, out chan *AppRecord) bool {
+	for {
+		select {
+		case record := <-out:
+			if requestIDFromBatch(record) == targetReqID {
+				return true
+			}
+		case <-time.After(time.Millisecond * 100):
+			return false
+		}
+	}
+}
+
+func accelerateTime(nodes []*App, done chan struct{}, heartbeatTime, viewChangeTime bool, counter *uint64) {
+	go func() {
+		for {
+			atomic.AddUint64(counter, 1)
+			newTime := time.Now().Add(time.Second * time.Duration(2*atomic.LoadUint64(counter)))
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond * 100):
+				for _, n := range nodes {
+					if heartbeatTime {
+						select {
+						case n.heartbeatTime <- newTime:
+						default:
+						}
+					}
+					if viewChangeTime {
+						select {
+						case n.viewChangeTime <- newTime:
+						default:
+						}
+					}
+				}
+			}
+		}
+	}()
+}
+
+func startNodes(nodes []*App, network *Network) {
+	for _, n := range nodes {
+		if err := n.Consensus.Start(); err != nil {
+			n.logger.Panicf("Consensus returned an error : %v", err)
+		}
+	}
+	network.StartServe()
+}
+
+func TestImportSnapshot(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	for i := 1; i <= 3; i++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+		for j := 0; j < numberOfNodes; j++ {
+			<-nodes[j].Delivered
+		}
+	}
+
+	snapshot, err := nodes[3].Consensus.ExportSnapshot()
+	assert.NoError(t, err)
+	nodes[3].Consensus.Stop()
+
+	// Bootstrap node 4 again on a new machine, from the snapshot alone
+	freshDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(freshDir)
+
+	fresh := newNode(4, network, t.Name(), freshDir, false, 0)
+	fresh.Consensus.Config.SyncOnStart = false
+	assert.NoError(t, fresh.Consensus.ImportSnapshot(snapshot))
+	assert.Equal(t, uint64(3), fresh.Consensus.Metadata.LatestSequence)
+	assert.NoError(t, fresh.Consensus.Start())
+	defer fresh.Consensus.Stop()
+	nodes[3] = fresh
+
+	assert.Error(t, fresh.Consensus.ImportSnapshot(snapshot))
+
+	// Without node 3, a quorum cannot be formed unless the fresh node participates
+	nodes[2].Disconnect()
+
+	nodes[0].Submit(Request{ID: "4", ClientID: "alice"})
+	for _, n := range []*App{nodes[0], nodes[1], nodes[3]} {
+		record := <-n.Delivered
+		assert.Equal(t, 4, requestIDFromBatch(record))
+	}
+	// The fresh node did not replay the earlier decisions through a full sync
+	assert.Len(t, fresh.Delivered, 0)
+}
 
+func TestCurrentProposalSequence(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
 	defer network.Shutdown()
@@ -2758,129 +4891,151 @@ func TestFetchStateWhenSyncReturnsPrevView(t *testing.T) {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
 		nodes = append(nodes, n)
 	}
+	assert.Zero(t, nodes[0].Consensus.CurrentProposalSequence())
+	startNodes(nodes, network)
 
-	start := time.Now()
-	for _, n := range nodes {
-		n.heartbeatTime = make(chan time.Time, 1)
-		n.heartbeatTime <- start
-		n.Setup()
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
 	}
 
-	// wait for the new leader to finish the view change before submitting
-	done := make(chan struct{})
-	viewChangeWG := sync.WaitGroup{}
-	viewChangeWG.Add(numberOfNodes - 1)
-
-	syncWG := sync.WaitGroup{}
-	syncWG.Add(1)
-
-	ignoreWG := sync.WaitGroup{}
-	ignoreWG.Add(1)
-	ignoreOnce := sync.Once{}
-
-	stateWG := sync.WaitGroup{}
-	stateWG.Add(1)
-
+	// Block all commits, so that the second proposal is never committed
+	var prepares uint32
 	for _, n := range nodes {
-		id := n.ID
-		baseLogger := n.Consensus.Logger.(*zap.SugaredLogger).Desugar()
-		n.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if strings.Contains(entry.Message, "ViewChanged") {
-				viewChangeWG.Done()
+		n.LoseMessages(func(msg *smartbftprotos.Message) bool {
+			if msg.GetPrepare() != nil {
+				atomic.AddUint32(&prepares, 1)
 			}
+			return msg.GetCommit() != nil
+		})
+	}
 
-			if strings.Contains(entry.Message, "Received HeartBeatResponse triggered a call to HeartBeatEventHandler Sync") {
-				syncWG.Done()
-			}
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&prepares) >= uint32(numberOfNodes*(numberOfNodes-1))
+	}, time.Minute, 10*time.Millisecond)
 
-			if strings.Contains(entry.Message, "Monitor already called Sync, ignoring HeartBeatResponse") {
-				if id == 2 {
-					ignoreOnce.Do(func() {
-						ignoreWG.Done()
-					})
-				}
-			}
+	for _, n := range nodes {
+		assert.Equal(t, uint64(2), n.Consensus.CurrentProposalSequence())
+		n.lock.Lock()
+		assert.Equal(t, uint64(1), n.latestMD.LatestSequence)
+		n.lock.Unlock()
+	}
+}
 
-			if strings.Contains(entry.Message, "collected state with view 2 and sequence 2") {
-				stateWG.Done()
-			}
+func TestLeadershipChange(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-			if strings.Contains(entry.Message, "but I am in view 1 and seq 2") {
-				panic("Didn't fetch state")
-			}
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-			return nil
-		})).Sugar()
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.leadership = make(chan leadershipChange, 10)
+		n.Consensus.LeadershipObserver = n
+		nodes = append(nodes, n)
 	}
-
 	startNodes(nodes, network)
 
-	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	assert.Equal(t, leadershipChange{isLeader: true, view: 0}, <-nodes[0].leadership)
+	assert.Equal(t, leadershipChange{isLeader: false, view: 0}, <-nodes[1].leadership)
+
+	nodes[0].Disconnect() // leader in partition
 
-	data := make([]*AppRecord, 0)
 	for i := 1; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
+		nodes[i].Submit(Request{ID: "1", ClientID: "alice"})
 	}
-	for i := 0; i < numberOfNodes-2; i++ {
-		assert.Equal(t, data[i], data[i+1])
+	for i := 1; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
 	}
+	assert.Equal(t, leadershipChange{isLeader: true, view: 1}, <-nodes[1].leadership)
+	assert.Len(t, nodes[0].leadership, 0)
 
-	nodes[0].Disconnect() // leader in partition
-
-	// Accelerate the time until a view change because of heartbeat timeout
-	var counter uint64
-	accelerateTime(nodes, done, true, false, &counter)
-
-	viewChangeWG.Wait()
-	close(done)
-
-	nodes[0].Connect() // connect after view changed
+	nodes[0].Connect() // the old leader syncs and becomes a follower
+	<-nodes[0].Delivered
+	select {
+	case change := <-nodes[0].leadership:
+		assert.Equal(t, leadershipChange{isLeader: false, view: 1}, change)
+	case <-time.After(30 * time.Second):
+		t.Fatalf("The old leader was not notified that it stopped leading")
+	}
+}
 
-	done = make(chan struct{})
-	accelerateTime(nodes, done, true, false, &counter)
+func TestFirstProposalDelay(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	syncWG.Wait()
-	close(done)
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	syncWG.Add(1)
-	viewChangeWG.Add(3)
-	nodes[1].Disconnect() // leader in partition
+	delay := 2 * time.Second
 
-	done = make(chan struct{})
-	accelerateTime(nodes, done, true, false, &counter)
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.FirstProposalDelay = delay
+		nodes = append(nodes, n)
+	}
+	start := time.Now()
+	startNodes(nodes, network)
 
-	viewChangeWG.Wait()
-	close(done)
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+	assert.GreaterOrEqual(t, time.Since(start), delay)
 
-	nodes[1].Connect()
+	// Subsequent proposals are not delayed
+	start = time.Now()
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+	assert.Less(t, time.Since(start), delay/2)
+}
 
-	done = make(chan struct{})
-	accelerateTime(nodes, done, true, false, &counter)
+func TestClientFacingNodeIDs(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	syncWG.Wait()
-	ignoreWG.Wait()
-	close(done)
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	stateWG.Wait()
+	// The nodes are known in the network by client-facing IDs 101 to 104, and by internal IDs 1 to 4 to the consensus
+	mapper := offsetIDMapper(100)
 
-	for i := 0; i < numberOfNodes; i++ {
-		nodes[i].Submit(Request{ID: "2", ClientID: "alice"}) // submit to all nodes
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(mapper.ClientFacingID(uint64(i)), network, t.Name(), testDir, false, 0)
+		n.ID = uint64(i)
+		n.Consensus.Config.SelfID = uint64(i)
+		n.Consensus.NodeIDMapper = mapper
+		nodes = append(nodes, n)
 	}
+	startNodes(nodes, network)
 
-	data = make([]*AppRecord, 0)
-	for i := 1; i < numberOfNodes; i++ {
+	// The request is submitted to a follower, which forwards it to the leader by its client-facing ID
+	nodes[2].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
 		d := <-nodes[i].Delivered
-		data = append(data, d)
-	}
-	for i := 0; i < numberOfNodes-2; i++ {
-		assert.Equal(t, data[i], data[i+1])
+		assert.Equal(t, "1", requestFromBytes(d.Batch.Requests[0]).ID)
 	}
+	assert.Equal(t, uint64(1), nodes[2].Consensus.GetLeaderID())
 }
 
-func TestLeaderStopSendHeartbeat(t *testing.T) {
+func TestSubmitBatch(t *testing.T) {
 	t.Parallel()
-
 	network := NewNetwork()
 	defer network.Shutdown()
 
@@ -2889,497 +5044,638 @@ func TestLeaderStopSendHeartbeat(t *testing.T) {
 	defer os.RemoveAll(testDir)
 
 	numberOfNodes := 4
-	nodes := make([]*App, 0, 4)
-
+	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.RequestBatchMaxCount = 2
 		nodes = append(nodes, n)
 	}
+	startNodes(nodes, network)
 
-	start := time.Now()
-
-	for _, n := range nodes {
-		n.heartbeatTime = make(chan time.Time, 1)
-		n.heartbeatTime <- start
-		n.viewChangeTime = make(chan time.Time, 1)
-		n.viewChangeTime <- start
-		n.Setup()
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	group := [][]byte{
+		Request{ID: "2", ClientID: "alice"}.ToBytes(),
+		Request{ID: "3", ClientID: "alice"}.ToBytes(),
+		Request{ID: "4", ClientID: "alice"}.ToBytes(),
 	}
+	assert.NoError(t, nodes[0].Consensus.SubmitBatch(group))
 
-	// wait for the new leader to finish the view change before submitting
-	done := make(chan struct{})
-
-	prepareFirstCh := make(chan struct{}, 2)
-	prepareFirstMap := make(map[uint64]struct{}, 2)
-	prepareFirstSync := sync.RWMutex{}
-
-	realViewChangeCh := make(chan struct{}, 4)
-	realViewChangeMap := make(map[uint64]struct{}, 4)
-	realViewChangeSync := sync.RWMutex{}
-
-	for i := 0; i < numberOfNodes; i++ {
-		id := nodes[i].ID
-		baseLogger := nodes[i].Node.app.Consensus.Logger.(*zap.SugaredLogger).Desugar()
-		nodes[i].Node.app.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if strings.Contains(entry.Message, "Starting view with number") &&
-				!strings.Contains(entry.Message, "Starting view with number 0") {
-				realViewChangeSync.RLock()
-				_, hasVoted := realViewChangeMap[id]
-				realViewChangeSync.RUnlock()
-
-				if !hasVoted {
-					realViewChangeSync.Lock()
-					_, hasVoted = realViewChangeMap[id]
-					if !hasVoted {
-						realViewChangeMap[id] = struct{}{}
-						realViewChangeCh <- struct{}{}
-					}
-					realViewChangeSync.Unlock()
-				}
-			}
-
-			if (id == 1 || id == 2) &&
-				strings.Contains(entry.Message, "prepare") {
-				prepareFirstSync.RLock()
-				_, hasVoted := prepareFirstMap[id]
-				prepareFirstSync.RUnlock()
-
-				if !hasVoted {
-					prepareFirstSync.Lock()
-					_, hasVoted = prepareFirstMap[id]
-					if !hasVoted {
-						prepareFirstMap[id] = struct{}{}
-						prepareFirstCh <- struct{}{}
-					}
-					prepareFirstSync.Unlock()
-				}
+	// The group is larger than the batch limit, yet it is delivered as a whole in its own batch
+	for _, expected := range [][]string{{"1"}, {"2", "3", "4"}} {
+		for i := 0; i < numberOfNodes; i++ {
+			d := <-nodes[i].Delivered
+			ids := make([]string, 0, len(d.Batch.Requests))
+			for _, req := range d.Batch.Requests {
+				ids = append(ids, requestFromBytes(req).ID)
 			}
-
-			return nil
-		})).Sugar()
+			assert.Equal(t, expected, ids)
+		}
 	}
+}
 
-	startNodes(nodes, network)
-
-	var counter uint64
-	accelerateTime(nodes, done, true, true, &counter)
-
-	nodes[2].Disconnect()
-	nodes[3].Disconnect()
-
-	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+func TestMinProposalInterval(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	fail := time.After(1 * time.Minute)
-	for i := 0; i < 2; i++ {
-		select {
-		case <-prepareFirstCh:
-		case <-fail:
-			t.Fatal("Didn't change view 1")
-		}
-	}
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	nodes[2].Connect()
-	nodes[3].Connect()
+	interval := 200 * time.Millisecond
 
-	fail = time.After(realViewChangeTimeout)
-	for i := 0; i < 4; i++ {
-		select {
-		case <-realViewChangeCh:
-		case <-fail:
-			t.Fatal("Didn't change real view")
-		}
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.MinProposalInterval = interval
+		nodes = append(nodes, n)
 	}
 
-	close(done)
+	var lock sync.Mutex
+	var proposalTimes []time.Time
+	baseLogger := nodes[0].Consensus.Logger.(*zap.SugaredLogger).Desugar()
+	nodes[0].Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+		if strings.Contains(entry.Message, "Proposing proposal sequence") {
+			lock.Lock()
+			proposalTimes = append(proposalTimes, entry.Time)
+			lock.Unlock()
+		}
+		return nil
+	})).Sugar()
+	startNodes(nodes, network)
+
+	// The pool of the leader is kept full
+	requestCount := 40
+	start := time.Now()
+	for i := 1; i <= requestCount; i++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+	}
 
-	data := make([]*AppRecord, 0, 4)
 	for i := 0; i < numberOfNodes; i++ {
-		d := <-nodes[i].Delivered
-		data = append(data, d)
+		delivered := 0
+		for delivered < requestCount {
+			d := <-nodes[i].Delivered
+			delivered += len(d.Batch.Requests)
+		}
 	}
 
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
+	// The requests accumulate in full batches between proposals, so throughput is not hurt much
+	assert.Less(t, time.Since(start), time.Duration(requestCount/int(fastConfig.RequestBatchMaxCount)+1)*interval+time.Second)
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.GreaterOrEqual(t, len(proposalTimes), 2)
+	for i := 1; i < len(proposalTimes); i++ {
+		assert.GreaterOrEqual(t, proposalTimes[i].Sub(proposalTimes[i-1]), interval)
 	}
 }
 
-// TestTryCommittedSequenceTwice - is a modified copy of TestViewChangeAfterTryingToFork,
-// because it periodically recorded an error.
-// The difference from the parent is that there is work with new channels:
-// twiceDeliverBeginCh, deliverControlCh and deliverViewChangerCh,
-// After the quorum is restored, two "change of viewpoint" processes take place in parallel,
-// during which a transaction delivery and an attempt to deliver a transaction by the new leader can take place.
-// With the help of channels twiceDeliverBeginCh, deliverControlCh and deliverViewChangerCh
-// at the right moments of time, suspended or triggered the corresponding goroutines,
-// so that double delivery can take place
-func TestTryCommittedSequenceTwice(t *testing.T) {
+func TestBatchObserver(t *testing.T) {
 	t.Parallel()
-
-	// wait for the new leader to finish the view change before submitting
-	done := make(chan struct{})
-	defer close(done)
-
 	network := NewNetwork()
 	defer network.Shutdown()
 
 	testDir, err := os.MkdirTemp("", t.Name())
 	assert.NoErrorf(t, err, "generate temporary test dir")
-	defer func() {
-		_ = os.RemoveAll(testDir)
-	}()
-
-	numberOfNodes := 7
-	nodes := make([]*App, 0, 7)
+	defer os.RemoveAll(testDir)
 
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
 	for i := 1; i <= numberOfNodes; i++ {
 		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
-		// n.Consensus.Application
+		n.batches = make(chan observedBatch, 10)
+		n.Consensus.BatchObserver = n
 		nodes = append(nodes, n)
 	}
+	startNodes(nodes, network)
 
-	start := time.Now()
+	for i := 1; i <= 3; i++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", i), ClientID: "alice"})
+	}
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
 
-	for _, n := range nodes {
-		n.heartbeatTime = make(chan time.Time, 1)
-		n.heartbeatTime <- start
-		n.viewChangeTime = make(chan time.Time, 1)
-		n.viewChangeTime <- start
-		n.LoseMessages(func(msg *smartbftprotos.Message) bool {
-			return false
+	// The leader observes the batch it assembled, and the followers observe the same batch after verifying it
+	leaderBatch := <-nodes[0].batches
+	assert.NotEmpty(t, leaderBatch.requests)
+	for i := 1; i < numberOfNodes; i++ {
+		assert.Equal(t, leaderBatch, <-nodes[i].batches)
+	}
+}
+
+func TestStartWithMissingDependency(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	n := newNode(1, network, t.Name(), testDir, false, 0)
+	assert.NoError(t, n.Consensus.ValidateDependencies())
+	startNodes([]*App{n}, network)
+
+	for _, testCase := range []struct {
+		dependency string
+		unset      func(c *consensus.Consensus)
+	}{
+		{dependency: "Comm", unset: func(c *consensus.Consensus) { c.Comm = nil }},
+		{dependency: "WAL", unset: func(c *consensus.Consensus) { c.WAL = nil }},
+		{dependency: "Signer", unset: func(c *consensus.Consensus) { c.Signer = nil }},
+		{dependency: "Verifier", unset: func(c *consensus.Consensus) { c.Verifier = nil }},
+		{dependency: "Assembler", unset: func(c *consensus.Consensus) { c.Assembler = nil }},
+		{dependency: "Application", unset: func(c *consensus.Consensus) { c.Application = nil }},
+		{dependency: "RequestInspector", unset: func(c *consensus.Consensus) { c.RequestInspector = nil }},
+		{dependency: "Synchronizer", unset: func(c *consensus.Consensus) { c.Synchronizer = nil }},
+		{dependency: "Logger", unset: func(c *consensus.Consensus) { c.Logger = nil }},
+		{dependency: "PayloadCompactor", unset: func(c *consensus.Consensus) { c.Config.ProposeRequestDigests = true }},
+	} {
+		t.Run(testCase.dependency, func(t *testing.T) {
+			c := &consensus.Consensus{
+				Config:           n.Consensus.Config,
+				Logger:           n.Consensus.Logger,
+				WAL:              n.Consensus.WAL,
+				Comm:             n.Consensus.Comm,
+				Verifier:         n.Consensus.Verifier,
+				Signer:           n.Consensus.Signer,
+				RequestInspector: n.Consensus.RequestInspector,
+				Assembler:        n.Consensus.Assembler,
+				Synchronizer:     n.Consensus.Synchronizer,
+				Application:      n.Consensus.Application,
+			}
+			testCase.unset(c)
+			assert.EqualError(t, c.Start(), fmt.Sprintf("dependencies are invalid: %s is not set", testCase.dependency))
 		})
-		n.Setup()
 	}
 
-	var once sync.Once
+	// The fallible variants of the assembler and the application can replace them
+	c := &consensus.Consensus{
+		Logger:              n.Consensus.Logger,
+		WAL:                 n.Consensus.WAL,
+		Comm:                n.Consensus.Comm,
+		Verifier:            n.Consensus.Verifier,
+		Signer:              n.Consensus.Signer,
+		RequestInspector:    n.Consensus.RequestInspector,
+		FallibleAssembler:   n,
+		Synchronizer:        n.Consensus.Synchronizer,
+		FallibleApplication: n,
+	}
+	assert.NoError(t, c.ValidateDependencies())
+}
 
-	addFirstViewCh := make(chan struct{}, 6)
-	addFirstViewChMap := make(map[uint64]struct{}, 6)
-	addFirstSync := sync.RWMutex{}
+func TestNewWithMissingDependency(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	viewChange1Ch := make(chan struct{}, 4)
-	viewChange1Map := make(map[uint64]struct{}, 4)
-	viewChange1Sync := sync.RWMutex{}
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	viewChange2Ch := make(chan struct{}, 4)
-	viewChange2Map := make(map[uint64]struct{}, 4)
-	viewChange2Sync := sync.RWMutex{}
+	n := newNode(1, network, t.Name(), testDir, false, 0)
+	startNodes([]*App{n}, network)
+	config := func() consensus.Config {
+		return consensus.Config{
+			Config:           n.Consensus.Config,
+			Logger:           n.Consensus.Logger,
+			WAL:              n.Consensus.WAL,
+			Comm:             n.Consensus.Comm,
+			Verifier:         n.Consensus.Verifier,
+			Signer:           n.Consensus.Signer,
+			RequestInspector: n.Consensus.RequestInspector,
+			Assembler:        n.Consensus.Assembler,
+			Synchronizer:     n.Consensus.Synchronizer,
+			Application:      n.Consensus.Application,
+		}
+	}
 
-	realViewChangeCh := make(chan struct{}, 7)
-	realViewChangeMap := make(map[uint64]struct{}, 7)
-	realViewChangeSync := sync.RWMutex{}
+	for _, testCase := range []struct {
+		dependency string
+		unset      func(cfg *consensus.Config)
+	}{
+		{dependency: "Comm", unset: func(cfg *consensus.Config) { cfg.Comm = nil }},
+		{dependency: "WAL", unset: func(cfg *consensus.Config) { cfg.WAL = nil }},
+		{dependency: "Signer", unset: func(cfg *consensus.Config) { cfg.Signer = nil }},
+		{dependency: "Verifier", unset: func(cfg *consensus.Config) { cfg.Verifier = nil }},
+		{dependency: "Assembler", unset: func(cfg *consensus.Config) { cfg.Assembler = nil }},
+		{dependency: "Application", unset: func(cfg *consensus.Config) { cfg.Application = nil }},
+		{dependency: "RequestInspector", unset: func(cfg *consensus.Config) { cfg.RequestInspector = nil }},
+		{dependency: "Synchronizer", unset: func(cfg *consensus.Config) { cfg.Synchronizer = nil }},
+		{dependency: "Logger", unset: func(cfg *consensus.Config) { cfg.Logger = nil }},
+		{dependency: "PayloadCompactor", unset: func(cfg *consensus.Config) { cfg.Config.ProposeRequestDigests = true }},
+	} {
+		t.Run(testCase.dependency, func(t *testing.T) {
+			cfg := config()
+			testCase.unset(&cfg)
+			c, err := consensus.New(cfg)
+			assert.EqualError(t, err, fmt.Sprintf("dependencies are invalid: %s is not set", testCase.dependency))
+			assert.Nil(t, c)
+		})
+	}
 
-	twiceDeliverBeginCh := make(chan struct{}, 2)
-	deliverControlCh := make(chan struct{})
-	deliverViewChangerCh := make(chan struct{})
-	go func() {
-		// 2. [twice] Waiting for the moment when both goroutines are ready to deliver the transaction
-		<-twiceDeliverBeginCh
-		<-twiceDeliverBeginCh
-		// 3. [twice] Started delivery in goroutine viewchanger
-		close(deliverViewChangerCh)
-	}()
+	// An invalid configuration is rejected
+	cfg := config()
+	cfg.Config.SelfID = 0
+	_, err = consensus.New(cfg)
+	assert.EqualError(t, err, "configuration is invalid: SelfID should be greater than zero")
 
-	for i := 0; i < numberOfNodes; i++ {
-		id := nodes[i].ID
-		baseLogger := nodes[i].Node.app.Consensus.Logger.(*zap.SugaredLogger).Desugar()
-		nodes[i].Node.app.Consensus.Logger = baseLogger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if strings.Contains(entry.Message, "Starting view with number") &&
-				!strings.Contains(entry.Message, "Starting view with number 0") {
-				// After returning all the nodes,
-				// we fix that a new view has started, but not the very first one.
-				realViewChangeSync.RLock()
-				_, hasVoted := realViewChangeMap[id]
-				realViewChangeSync.RUnlock()
+	// A RequestPoolMaxBatchCount is checked against the batch size only if it is set
+	cfg = config()
+	cfg.Config.RequestPoolMaxBatchCount = 0
+	cfg.Config.RequestBatchMaxCount = 20000
+	cfg.Config.RequestPoolSize = 40000
+	_, err = consensus.New(cfg)
+	assert.NoError(t, err)
+	cfg.Config.RequestPoolMaxBatchCount = 10000
+	_, err = consensus.New(cfg)
+	assert.EqualError(t, err, "configuration is invalid: RequestPoolMaxBatchCount is smaller than RequestBatchMaxCount")
+
+	// A MaxViewIdleTime too small to be checked periodically is rejected
+	cfg = config()
+	cfg.Config.MaxViewIdleTime = 5 * time.Nanosecond
+	_, err = consensus.New(cfg)
+	assert.EqualError(t, err, "configuration is invalid: MaxViewIdleTime should be zero or at least 10ms")
+
+	// The optional dependencies that are not set are defaulted
+	c, err := consensus.New(config())
+	assert.NoError(t, err)
+	assert.NotNil(t, c.Metrics)
+	assert.NotNil(t, c.Metadata)
+	assert.NotNil(t, c.Scheduler)
+	assert.NotNil(t, c.ViewChangerTicker)
+	assert.Equal(t, n.Consensus.Comm, c.Comm)
+	assert.Equal(t, n.Consensus.Config, c.Config)
+}
 
-				if !hasVoted {
-					realViewChangeSync.Lock()
-					_, hasVoted = realViewChangeMap[id]
-					if !hasVoted {
-						realViewChangeMap[id] = struct{}{}
-						realViewChangeCh <- struct{}{}
-					}
-					realViewChangeSync.Unlock()
-				}
-			}
+func TestViewChangeProgress(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-			if id != 7 {
-				// We wait for the node, after no heartbeat, to throw its vote for the leader change.
-				// Then we will turn this node off anyway, so that we can't use it to complete the leader change.
-				if strings.Contains(entry.Message, "is processing a view change message") &&
-					strings.Contains(entry.Message, "from 7") {
-					addFirstSync.RLock()
-					_, hasVoted := addFirstViewChMap[id]
-					addFirstSync.RUnlock()
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-					if !hasVoted {
-						addFirstSync.Lock()
-						_, hasVoted = addFirstViewChMap[id]
-						if !hasVoted {
-							addFirstViewChMap[id] = struct{}{}
-							addFirstViewCh <- struct{}{}
-						}
-						addFirstSync.Unlock()
-					}
-				}
-			}
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
 
-			if id >= 1 && id <= 4 {
-				// All live nodes want to change the leader
-				if strings.Contains(entry.Message, "sent view data msg, with next view 1") {
-					viewChange1Sync.RLock()
-					_, hasVoted := viewChange1Map[id]
-					viewChange1Sync.RUnlock()
+	collected, needed := nodes[2].Consensus.ViewChangeProgress()
+	assert.Equal(t, 0, collected)
+	assert.Equal(t, 0, needed)
 
-					if !hasVoted {
-						viewChange1Sync.Lock()
-						_, hasVoted = viewChange1Map[id]
-						if !hasVoted {
-							viewChange1Map[id] = struct{}{}
-							viewChange1Ch <- struct{}{}
-						}
-						viewChange1Sync.Unlock()
-					}
-				}
-				// All live nodes have begun the leader change procedure
-				if strings.Contains(entry.Message, "started view change, last view is 1") {
-					viewChange2Sync.RLock()
-					_, hasVoted := viewChange2Map[id]
-					viewChange2Sync.RUnlock()
+	// With the leader and another node partitioned away, the view change cannot complete
+	nodes[0].Disconnect()
+	nodes[3].Disconnect()
+	nodes[1].Submit(Request{ID: "1", ClientID: "alice"})
+	nodes[2].Submit(Request{ID: "1", ClientID: "alice"})
 
-					if !hasVoted {
-						viewChange2Sync.Lock()
-						_, hasVoted = viewChange2Map[id]
-						if !hasVoted {
-							viewChange2Map[id] = struct{}{}
-							viewChange2Ch <- struct{}{}
-						}
-						viewChange2Sync.Unlock()
-					}
-				}
-			}
+	// The collected count increases toward the quorum, but cannot reach it
+	var progress []int
+	assert.Eventually(t, func() bool {
+		collected, needed := nodes[2].Consensus.ViewChangeProgress()
+		if needed == 0 {
+			return false
+		}
+		assert.Equal(t, 3, needed)
+		if len(progress) == 0 || progress[len(progress)-1] != collected {
+			progress = append(progress, collected)
+		}
+		return collected == 2
+	}, time.Minute, 10*time.Millisecond)
+	assert.IsIncreasing(t, progress)
+	time.Sleep(time.Second)
+	collected, needed = nodes[2].Consensus.ViewChangeProgress()
+	assert.Equal(t, 2, collected)
+	assert.Equal(t, 3, needed)
+
+	// Once a quorum is reachable again the view change completes
+	nodes[3].Connect()
+	<-nodes[1].Delivered
+	<-nodes[2].Delivered
+	assert.Eventually(t, func() bool {
+		collected, needed := nodes[2].Consensus.ViewChangeProgress()
+		return collected == 0 && needed == 0
+	}, time.Minute, 10*time.Millisecond)
+}
 
-			if id == 7 {
-				// Emulation of node removal from the consensus in the absence of heartbeat
-				if strings.Contains(entry.Message, "Heartbeat timeout expired") {
-					once.Do(func() {
-						nodes[6].Connect()
-					})
-				}
-			}
+func TestDumpWAL(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-			if id == 4 {
-				// Suspension of gorutins and their launch to try to implement twice-delivery
-				if strings.Contains(entry.Message, "Delivering to app from deliverDecision the last decision proposal") {
-					// 1. [twice] Waited by the time the transaction was delivered
-					twiceDeliverBeginCh <- struct{}{}
-					// 4. [twice] Started delivery in goroutine "viewchanger"
-					<-deliverViewChangerCh
-				}
-				if strings.Contains(entry.Message, "Delivering to app from Decide the last decision proposal") {
-					// 1. [twice] Waited by the time the transaction was delivered
-					twiceDeliverBeginCh <- struct{}{}
-					// 4. [twice] Started delivery in goroutine "viewchanger"
-					<-deliverViewChangerCh
-				}
-				if strings.Contains(entry.Message, "Delivering end to app from deliverDecision the last decision proposal") {
-					// 5. [twice] "Viewchanger" delivered the transaction, let's pass the baton on
-					close(deliverControlCh)
-				}
-				if strings.Contains(entry.Message, "Delivering end to app from Decide the last decision proposal") {
-					// 5. [twice] "Viewchanger" delivered the transaction, let's pass the baton on
-					close(deliverControlCh)
-				}
-				if strings.Contains(entry.Message, "Delivering to app from Controller decide the last decision proposal") {
-					// 1. [twice] Waited by the time the transaction was delivered
-					twiceDeliverBeginCh <- struct{}{}
-					// 6. [twice] Started delivery in goroutine "control"
-					<-deliverControlCh
-				}
-			}
-			return nil
-		})).Sugar()
-	}
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
 	startNodes(nodes, network)
 
-	var counter uint64
-	accelerateTime(nodes, done, true, true, &counter)
+	for seq := uint64(1); seq <= 3; seq++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", seq), ClientID: "alice"})
+		for i := 0; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+		// Each proposal truncates the write ahead log, which then holds the proposal and the commit on it
+		for i := 0; i < numberOfNodes; i++ {
+			assert.Equal(t, []types.WALEntrySummary{
+				{Type: types.WALEntryProposal, View: 0, Seq: seq},
+				{Type: types.WALEntryCommit, View: 0, Seq: seq},
+			}, nodes[i].Consensus.DumpWAL())
+		}
+	}
+}
 
-	// Emulation of node removal from the consensus in the absence of heartbeat
-	nodes[6].Disconnect()
+func TestDuplicateIDDetected(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-	// Expect all nodes to see the 7th node's proposal to change the leader
-	for i := 0; i < 6; i++ {
-		<-addFirstViewCh
-	}
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	// Breaking consensus for all operations: performing a transaction and changing a view
-	nodes[6].Disconnect()
-	nodes[5].Disconnect()
-	nodes[4].Disconnect()
+	numberOfNodes := 5
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	// The fifth node is misconfigured to use the ID of the first node
+	nodes[4].Consensus.Config.SelfID = 1
+	nodes[4].Consensus.Config.DetectDuplicateID = true
+	nodes[4].halts = make(chan error, 1)
+	nodes[4].Consensus.HaltObserver = nodes[4]
+	startNodes(nodes, network)
 
-	for i := 0; i < numberOfNodes-3; i++ {
-		nodes[i].Submit(Request{ID: "1", ClientID: "alice"}) // submit to other nodes
+	// It detects the heartbeats of the first node, which leads the view, and halts
+	select {
+	case err := <-nodes[4].halts:
+		assert.ErrorContains(t, err, "another node uses the ID 1 of this node")
+	case <-time.After(30 * time.Second):
+		t.Fatal("the duplicate node did not halt")
 	}
 
-	// Expect the desire of all living nodes to change the leader
-	fail := time.After(1 * time.Minute)
-	for i := 0; i < 4; i++ {
-		select {
-		case <-viewChange1Ch:
-		case <-fail:
-			t.Fatal("Didn't change view 1")
-		}
+	// The rest of the nodes keep delivering without it
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes-1; i++ {
+		data = append(data, <-nodes[i].Delivered)
 	}
+	for i := 0; i < len(data)-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+	assert.Empty(t, nodes[4].Delivered)
+}
 
-	// Expect leader changes to begin from all live nodes
-	fail = time.After(1 * time.Minute)
-	for i := 0; i < 4; i++ {
-		select {
-		case <-viewChange2Ch:
-		case <-fail:
-			t.Fatal("Didn't change view 2")
-		}
+func TestPauseAndResumeDelivery(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
 	}
+	recorder := newMetricsRecorder()
+	nodes[3].UseMetrics(recorder)
+	startNodes(nodes, network)
 
-	// Return the nodes to the consensus
-	nodes[6].Connect()
-	nodes[5].Connect()
-	nodes[4].Connect()
+	nodes[3].Consensus.PauseDelivery()
 
-	// Waiting for a real change of leader and view
-	fail = time.After(realViewChangeTimeout)
-	for i := 0; i < 7; i++ {
+	var decisions []*AppRecord
+	for seq := 1; seq <= 5; seq++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", seq), ClientID: "alice"})
+		decision := <-nodes[0].Delivered
+		for i := 1; i < numberOfNodes-1; i++ {
+			assert.Equal(t, decision, <-nodes[i].Delivered)
+		}
+		decisions = append(decisions, decision)
+	}
+	// The paused node keeps ordering, and holds back all the decisions it ordered
+	assert.Eventually(t, func() bool {
+		return nodes[3].Consensus.CurrentProposalSequence() == 6
+	}, 30*time.Second, 100*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return recorder.Value("consensus_count_paused_decisions") == float64(5)
+	}, 30*time.Second, 100*time.Millisecond)
+	assert.Len(t, nodes[3].Delivered, 0)
+
+	nodes[3].Consensus.ResumeDelivery()
+	for _, decision := range decisions {
 		select {
-		case <-realViewChangeCh:
-		case <-fail:
-			t.Fatal("Didn't change real view")
+		case delivered := <-nodes[3].Delivered:
+			assert.Equal(t, decision, delivered)
+		case <-time.After(30 * time.Second):
+			t.Fatal("the decisions were not delivered once delivery was resumed")
 		}
 	}
+	assert.Eventually(t, func() bool {
+		return recorder.Value("consensus_count_paused_decisions") == float64(0)
+	}, 30*time.Second, 100*time.Millisecond)
 
-	data := make([]*AppRecord, 0, 7)
-	storeI := -1
-	fail = time.After(1 * time.Minute)
-ExternalLoop:
+	// Once resumed, decisions are delivered as they are ordered
+	nodes[0].Submit(Request{ID: "6", ClientID: "alice"})
 	for i := 0; i < numberOfNodes; i++ {
-		select {
-		case d := <-nodes[i].Delivered:
-			data = append(data, d)
-		case <-fail:
-			storeI = i
-			break ExternalLoop
-		}
+		<-nodes[i].Delivered
 	}
+}
 
-	if storeI != -1 {
-		for i := 0; i < numberOfNodes; i++ {
-			nodes[i].Submit(Request{ID: "2", ClientID: "bob"})
-		}
+func TestPauseDeliveryOverflowSyncs(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-		for i := storeI; i < numberOfNodes; i++ {
-			d := <-nodes[i].Delivered
-			data = append(data, d)
-		}
-	}
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
 
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data[i], data[i+1])
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.PausedDecisionsBufferSize = 2
+		nodes = append(nodes, n)
 	}
+	startNodes(nodes, network)
 
-	if storeI == -1 {
-		return
+	nodes[3].Consensus.PauseDelivery()
+
+	var decisions []*AppRecord
+	for seq := 1; seq <= 5; seq++ {
+		nodes[0].Submit(Request{ID: fmt.Sprintf("%d", seq), ClientID: "alice"})
+		decision := <-nodes[0].Delivered
+		for i := 1; i < numberOfNodes-1; i++ {
+			assert.Equal(t, decision, <-nodes[i].Delivered)
+		}
+		decisions = append(decisions, decision)
 	}
+	assert.Eventually(t, func() bool {
+		return nodes[3].Consensus.CurrentProposalSequence() == 6
+	}, 30*time.Second, 100*time.Millisecond)
+	assert.Len(t, nodes[3].Delivered, 0)
 
-	data1 := make([]*AppRecord, 0, 7)
-	fail = time.After(1 * time.Minute)
-	for i := 0; i < numberOfNodes; i++ {
+	// More decisions were ordered than are held back, hence the node syncs them once delivery is resumed
+	nodes[3].Consensus.ResumeDelivery()
+	for _, decision := range decisions {
 		select {
-		case d := <-nodes[i].Delivered:
-			data1 = append(data1, d)
-		case <-fail:
-			t.Fatal("Didn't deliver two msg")
+		case delivered := <-nodes[3].Delivered:
+			assert.Equal(t, decision, delivered)
+		case <-time.After(30 * time.Second):
+			t.Fatal("the decisions were not synced once delivery was resumed")
 		}
 	}
 
-	for i := 0; i < numberOfNodes-1; i++ {
-		assert.Equal(t, data1[i], data1[i+1])
+	nodes[0].Submit(Request{ID: "6", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
 	}
 }
 
-func doInBackground(f func(), stop <-chan struct{}) {
-	for {
-		select {
-		case <-stop:
-			return
-		default:
-			f()
+func TestConsensusGroup(t *testing.T) {
+	t.Parallel()
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	channels := []string{"channel1", "channel2", "channel3"}
+
+	// Each node runs an instance of every channel, and its instances share a single ticker and verification limit
+	groups := make([]*consensus.ConsensusGroup, 0, numberOfNodes)
+	for i := 0; i < numberOfNodes; i++ {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		group := consensus.NewConsensusGroup(ticker.C, 2)
+		defer group.Stop()
+		groups = append(groups, group)
+	}
+
+	nodesByChannel := make(map[string][]*App)
+	for _, channel := range channels {
+		network := NewNetwork()
+		defer network.Shutdown()
+		nodes := make([]*App, 0)
+		for i := 1; i <= numberOfNodes; i++ {
+			n := newNode(uint64(i), network, t.Name()+channel, filepath.Join(testDir, channel), false, 0)
+			assert.NoError(t, groups[i-1].Register(channel, n.Consensus))
+			nodes = append(nodes, n)
 		}
+		startNodes(nodes, network)
+		nodesByChannel[channel] = nodes
+	}
+	for _, group := range groups {
+		assert.Equal(t, channels, group.Channels())
 	}
-}
 
-func countCommittedBatches(n *App) int {
-	var numBatchesCreated int
-	for {
-		select {
-		case <-n.Delivered:
-			numBatchesCreated++
-		case <-time.After(time.Millisecond * 500):
-			return numBatchesCreated
+	// Each channel orders its own requests independently of the others
+	for _, channel := range channels {
+		nodes := nodesByChannel[channel]
+		nodes[0].Submit(Request{ID: "1", ClientID: channel})
+		decision := <-nodes[0].Delivered
+		assert.Equal(t, channel, requestFromBytes(decision.Batch.Requests[0]).ClientID)
+		for i := 1; i < numberOfNodes; i++ {
+			assert.Equal(t, decision, <-nodes[i].Delivered)
+		}
+	}
+	for _, channel := range channels {
+		for _, n := range nodesByChannel[channel] {
+			assert.Len(t, n.Delivered, 0)
 		}
 	}
 }
 
-func requestIDFromBatch(record *AppRecord) int {
-	n, _ := strconv.ParseInt(requestFromBytes(record.Batch.Requests[0]).ID, 10, 32)
-	return int(n)
-}
+func TestCollectedViewData(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
 
-func waitForCatchup(targetReqID int, out chan *AppRecord) bool {
-	for {
-		select {
-		case record := <-out:
-			if requestIDFromBatch(record) == targetReqID {
-				return true
-			}
-		case <-time.After(time.Millisecond * 100):
-			return false
-		}
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
 	}
-}
+	// The view data of the fourth node never reaches the second node, which leads the next view
+	nodes[1].LoseMessages(func(msg *smartbftprotos.Message) bool {
+		return msg.GetViewData() != nil && msg.GetViewData().Signer == 4
+	})
+	startNodes(nodes, network)
 
-func accelerateTime(nodes []*App, done chan struct{}, heartbeatTime, viewChangeTime bool, counter *uint64) {
-	go func() {
-		for {
-			atomic.AddUint64(counter, 1)
-			newTime := time.Now().Add(time.Second * time.Duration(2*atomic.LoadUint64(counter)))
-			select {
-			case <-done:
-				return
-			case <-time.After(time.Millisecond * 100):
-				for _, n := range nodes {
-					if heartbeatTime {
-						select {
-						case n.heartbeatTime <- newTime:
-						default:
-						}
-					}
-					if viewChangeTime {
-						select {
-						case n.viewChangeTime <- newTime:
-						default:
-						}
-					}
-				}
-			}
-		}
-	}()
+	assert.Empty(t, nodes[1].Consensus.CollectedViewData())
+
+	// With the leader partitioned away, the view change collects the view data of the second and third nodes only
+	nodes[0].Disconnect()
+	nodes[1].Submit(Request{ID: "1", ClientID: "alice"})
+	nodes[2].Submit(Request{ID: "1", ClientID: "alice"})
+	nodes[3].Submit(Request{ID: "1", ClientID: "alice"})
+
+	assert.Eventually(t, func() bool {
+		return len(nodes[1].Consensus.CollectedViewData()) == 2
+	}, time.Minute, 10*time.Millisecond)
+	time.Sleep(time.Second)
+	assert.Equal(t, []uint64{2, 3}, nodes[1].Consensus.CollectedViewData())
+	collected, needed := nodes[1].Consensus.ViewChangeProgress()
+	assert.Equal(t, 2, collected)
+	assert.Equal(t, 3, needed)
+
+	// Nodes that do not lead the next view collect no view data
+	assert.Empty(t, nodes[2].Consensus.CollectedViewData())
 }
 
-func startNodes(nodes []*App, network *Network) {
-	for _, n := range nodes {
-		if err := n.Consensus.Start(); err != nil {
-			n.logger.Panicf("Consensus returned an error : %v", err)
-		}
+func TestHoldsLeaderToken(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.LogLeaderToken = true
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	// The leader holds the token while it waits for requests, and the followers never hold it
+	assert.Eventually(t, nodes[0].Consensus.HoldsLeaderToken, time.Minute, 10*time.Millisecond)
+	for i := 1; i < numberOfNodes; i++ {
+		assert.False(t, nodes[i].Consensus.HoldsLeaderToken())
+	}
+
+	// The leader acquires the token again once its proposal is decided
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+	assert.Eventually(t, nodes[0].Consensus.HoldsLeaderToken, time.Minute, 10*time.Millisecond)
+	for i := 1; i < numberOfNodes; i++ {
+		assert.False(t, nodes[i].Consensus.HoldsLeaderToken())
 	}
-	network.StartServe()
 }
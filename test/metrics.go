@@ -0,0 +1,100 @@
+// Copyright IBM Corp. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package test
+
+import (
+	"sync"
+
+	"github.com/hyperledger-labs/SmartBFT/pkg/metrics"
+)
+
+// metricsRecorder is a metrics provider that records the values of all metrics it provides, by their name,
+// regardless of the label values.
+type metricsRecorder struct {
+	lock       sync.Mutex
+	values     map[string]float64
+	histograms map[string][]float64
+}
+
+func newMetricsRecorder() *metricsRecorder {
+	return &metricsRecorder{
+		values:     make(map[string]float64),
+		histograms: make(map[string][]float64),
+	}
+}
+
+func (r *metricsRecorder) NewCounter(opts metrics.CounterOpts) metrics.Counter {
+	return &recordedCounter{recordedMetric{name: opts.Name, recorder: r}}
+}
+
+func (r *metricsRecorder) NewGauge(opts metrics.GaugeOpts) metrics.Gauge {
+	return &recordedGauge{recordedMetric{name: opts.Name, recorder: r}}
+}
+
+func (r *metricsRecorder) NewHistogram(opts metrics.HistogramOpts) metrics.Histogram {
+	return &recordedHistogram{recordedMetric{name: opts.Name, recorder: r}}
+}
+
+// Value returns the current value of the counter or gauge with the given name
+func (r *metricsRecorder) Value(name string) float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.values[name]
+}
+
+// Observations returns the values observed by the histogram with the given name
+func (r *metricsRecorder) Observations(name string) []float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]float64(nil), r.histograms[name]...)
+}
+
+type recordedMetric struct {
+	name     string
+	recorder *metricsRecorder
+}
+
+func (m *recordedMetric) Add(delta float64) {
+	m.recorder.lock.Lock()
+	defer m.recorder.lock.Unlock()
+	m.recorder.values[m.name] += delta
+}
+
+func (m *recordedMetric) Set(value float64) {
+	m.recorder.lock.Lock()
+	defer m.recorder.lock.Unlock()
+	m.recorder.values[m.name] = value
+}
+
+func (m *recordedMetric) Observe(value float64) {
+	m.recorder.lock.Lock()
+	defer m.recorder.lock.Unlock()
+	m.recorder.histograms[m.name] = append(m.recorder.histograms[m.name], value)
+}
+
+type recordedCounter struct {
+	recordedMetric
+}
+
+func (c *recordedCounter) With(...string) metrics.Counter {
+	return c
+}
+
+type recordedGauge struct {
+	recordedMetric
+}
+
+func (g *recordedGauge) With(...string) metrics.Gauge {
+	return g
+}
+
+type recordedHistogram struct {
+	recordedMetric
+}
+
+func (h *recordedHistogram) With(...string) metrics.Histogram {
+	return h
+}
@@ -28,18 +28,21 @@ type Configuration struct {
 	DecisionsPerLeader            int64
 	RequestMaxBytes               int64
 	RequestPoolSubmitTimeout      time.Duration
+	NewViewOnLeaderRemoval        bool
 }
 
 type Reconfig struct {
 	InLatestDecision bool
 	CurrentNodes     []int64
 	CurrentConfig    Configuration
+	BasedOnNodes     []int64
 }
 
 func (r Reconfig) recconfigToUint(id uint64) types.Reconfig {
 	return types.Reconfig{
 		InLatestDecision: r.InLatestDecision,
 		CurrentNodes:     nodesToUint(r.CurrentNodes),
+		BasedOnNodes:     nodesToUint(r.BasedOnNodes),
 		CurrentConfig: types.Configuration{
 			SelfID:                        id,
 			RequestBatchMaxCount:          uint64(r.CurrentConfig.RequestBatchMaxCount),
@@ -62,6 +65,7 @@ func (r Reconfig) recconfigToUint(id uint64) types.Reconfig {
 			DecisionsPerLeader:            uint64(r.CurrentConfig.DecisionsPerLeader),
 			RequestMaxBytes:               uint64(r.CurrentConfig.RequestBatchMaxBytes),
 			RequestPoolSubmitTimeout:      r.CurrentConfig.RequestPoolSubmitTimeout,
+			NewViewOnLeaderRemoval:        r.CurrentConfig.NewViewOnLeaderRemoval,
 		},
 	}
 }
@@ -70,6 +74,7 @@ func recconfigToInt(reconfig types.Reconfig) Reconfig {
 	return Reconfig{
 		InLatestDecision: reconfig.InLatestDecision,
 		CurrentNodes:     nodesToInt(reconfig.CurrentNodes),
+		BasedOnNodes:     nodesToInt(reconfig.BasedOnNodes),
 		CurrentConfig: Configuration{
 			RequestBatchMaxCount:          int64(reconfig.CurrentConfig.RequestBatchMaxCount),
 			RequestBatchMaxBytes:          int64(reconfig.CurrentConfig.RequestBatchMaxBytes),
@@ -91,6 +96,7 @@ func recconfigToInt(reconfig types.Reconfig) Reconfig {
 			DecisionsPerLeader:            int64(reconfig.CurrentConfig.DecisionsPerLeader),
 			RequestMaxBytes:               int64(reconfig.CurrentConfig.RequestBatchMaxBytes),
 			RequestPoolSubmitTimeout:      reconfig.CurrentConfig.RequestPoolSubmitTimeout,
+			NewViewOnLeaderRemoval:        reconfig.CurrentConfig.NewViewOnLeaderRemoval,
 		},
 	}
 }
@@ -1,12 +1,15 @@
 package test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger-labs/SmartBFT/pkg/types"
+	"github.com/hyperledger-labs/SmartBFT/smartbftprotos"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -75,6 +78,113 @@ func TestBasicReconfig(t *testing.T) {
 	}
 }
 
+func TestReconfigAndSyncMetrics(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	recorders := make([]*metricsRecorder, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		r := newMetricsRecorder()
+		n.UseMetrics(r)
+		nodes = append(nodes, n)
+		recorders = append(recorders, r)
+	}
+	startNodes(nodes, network)
+
+	// All nodes sync on start
+	for _, r := range recorders {
+		assert.Eventually(t, func() bool {
+			return len(r.Observations("consensus_latency_sync")) > 0
+		}, 30*time.Second, 100*time.Millisecond)
+		assert.Equal(t, float64(0), r.Value("consensus_reconfig"))
+	}
+
+	newConfig := fastConfig
+	newConfig.CollectTimeout = fastConfig.CollectTimeout * 2
+
+	nodes[0].Submit(Request{
+		ClientID: "reconfig",
+		ID:       "10",
+		Reconfig: Reconfig{
+			InLatestDecision: true,
+			CurrentNodes:     nodesToInt(nodes[0].Node.Nodes()),
+			CurrentConfig:    recconfigToInt(types.Reconfig{CurrentConfig: newConfig}).CurrentConfig,
+		},
+	})
+
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+
+	for _, r := range recorders {
+		assert.Eventually(t, func() bool {
+			return r.Value("consensus_reconfig") == float64(1)
+		}, 30*time.Second, 100*time.Millisecond)
+	}
+}
+
+func TestPerViewMetrics(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	recorders := make([]*metricsRecorder, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		r := newMetricsRecorder()
+		n.UseMetrics(r)
+		nodes = append(nodes, n)
+		recorders = append(recorders, r)
+	}
+	startNodes(nodes, network)
+
+	// Two decisions in the first view
+	for _, id := range []string{"1", "2"} {
+		nodes[0].Submit(Request{ID: id, ClientID: "alice"})
+		for i := 0; i < numberOfNodes; i++ {
+			<-nodes[i].Delivered
+		}
+	}
+	for _, r := range recorders[1:] {
+		assert.Eventually(t, func() bool {
+			return r.Value("view_count_batches_in_view") == 2 && r.Value("view_count_batch_all") == 2
+		}, 30*time.Second, 100*time.Millisecond)
+		assert.Equal(t, float64(2), r.Value("view_count_txs_in_view"))
+	}
+
+	// The leader is partitioned and a decision is made in the next view
+	nodes[0].Disconnect()
+	for i := 1; i < numberOfNodes; i++ {
+		nodes[i].Submit(Request{ID: "3", ClientID: "alice"})
+	}
+	for i := 1; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+
+	// The per view counters were reset at the view change, while the cumulative counters kept counting
+	for _, r := range recorders[1:] {
+		assert.Eventually(t, func() bool {
+			return r.Value("view_count_batches_in_view") == 1 && r.Value("view_count_batch_all") == 3
+		}, 30*time.Second, 100*time.Millisecond)
+		assert.Equal(t, float64(1), r.Value("view_count_txs_in_view"))
+		assert.Equal(t, float64(3), r.Value("view_count_txs_all"))
+	}
+}
+
 func TestBasicAddNodes(t *testing.T) {
 	t.Parallel()
 	network := NewNetwork()
@@ -94,6 +204,9 @@ func TestBasicAddNodes(t *testing.T) {
 	}
 	startNodes(nodes, network)
 
+	members, _ := nodes[0].Consensus.Membership()
+	assert.Equal(t, []uint64{1, 2, 3, 4}, members)
+
 	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
 
 	data1 := make([]*AppRecord, 0)
@@ -131,6 +244,13 @@ func TestBasicAddNodes(t *testing.T) {
 		assert.Equal(t, data2[i], data2[i+1])
 	}
 
+	for i := 0; i < numberOfNodes; i++ {
+		assert.Eventually(t, func() bool {
+			members, config := nodes[i].Consensus.Membership()
+			return assert.ObjectsAreEqual([]uint64{1, 2, 3, 4, 5, 6}, members) && config.LeaderRotation
+		}, 30*time.Second, 100*time.Millisecond)
+	}
+
 	nodes = append(nodes, newNode1, newNode2)
 	startNodes(nodes[4:], network)
 
@@ -639,3 +759,333 @@ func TestAddNodeAfterManyRotations(t *testing.T) {
 		assert.Equal(t, data[i], data[i+1])
 	}
 }
+
+func TestConcurrentReconfigs(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 7
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		n.Consensus.Config.RequestBatchMaxCount = 1
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+
+	newConfig := fastConfig
+	newConfig.RequestBatchMaxCount = 1
+
+	// Both reconfigurations are made for the initial nodes, hence only the first one committed is applied
+	basedOn := nodesToInt(nodes[0].Node.Nodes())
+	nodes[0].Submit(Request{
+		ClientID: "reconfig",
+		ID:       "10",
+		Reconfig: Reconfig{
+			InLatestDecision: true,
+			CurrentNodes:     []int64{1, 2, 3, 4, 5, 6},
+			CurrentConfig:    recconfigToInt(types.Reconfig{CurrentConfig: newConfig}).CurrentConfig,
+			BasedOnNodes:     basedOn,
+		},
+	})
+	nodes[0].Submit(Request{
+		ClientID: "reconfig",
+		ID:       "11",
+		Reconfig: Reconfig{
+			InLatestDecision: true,
+			CurrentNodes:     []int64{1, 2, 3, 4, 5, 7},
+			CurrentConfig:    recconfigToInt(types.Reconfig{CurrentConfig: newConfig}).CurrentConfig,
+			BasedOnNodes:     basedOn,
+		},
+	})
+
+	// Nodes 1 to 5 remain either way
+	for i := 0; i < 5; i++ {
+		<-nodes[i].Delivered
+		<-nodes[i].Delivered
+	}
+
+	members, _ := nodes[0].Consensus.Membership()
+	assert.Contains(t, [][]uint64{{1, 2, 3, 4, 5, 6}, {1, 2, 3, 4, 5, 7}}, members)
+	remaining := nodes[:5]
+	if members[5] == 6 {
+		remaining = append(remaining, nodes[5])
+	} else {
+		remaining = append(remaining, nodes[6])
+	}
+
+	nodes[0].Submit(Request{ID: "12", ClientID: "alice"})
+	for _, n := range remaining {
+		record := <-n.Delivered
+		if n.ID > 5 {
+			// The remaining node among 6 and 7 also delivered both reconfigurations
+			record = <-n.Delivered
+			record = <-n.Delivered
+		}
+		assert.Equal(t, 12, requestIDFromBatch(record))
+		nodesOfN, _ := n.Consensus.Membership()
+		assert.Equal(t, members, nodesOfN)
+	}
+}
+
+func TestQuorumRecomputedOnReconfig(t *testing.T) {
+	// The decisions of 7 nodes are signed by a quorum of 5,
+	// once the membership shrinks to 4 nodes they should be signed by a quorum of 3,
+	// without restarting any of the remaining nodes.
+
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 7
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	signaturesOfLastDecision := func(n *App) int {
+		n.lock.Lock()
+		defer n.lock.Unlock()
+		return len(n.lastRecord.signatures)
+	}
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+		assert.Equal(t, 5, signaturesOfLastDecision(nodes[i]))
+	}
+
+	nodes[0].Submit(Request{
+		ClientID: "reconfig",
+		ID:       "10",
+		Reconfig: Reconfig{
+			InLatestDecision: true,
+			CurrentNodes:     []int64{1, 2, 3, 4},
+			CurrentConfig:    recconfigToInt(types.Reconfig{CurrentConfig: fastConfig}).CurrentConfig,
+		},
+	})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+
+	nodes[4].Disconnect()
+	nodes[5].Disconnect()
+	nodes[6].Disconnect()
+
+	numberOfNodes = 4
+	nodes[0].Submit(Request{ID: "11", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+		assert.Equal(t, 3, signaturesOfLastDecision(nodes[i]))
+		nodesOfN, _ := nodes[i].Consensus.Membership()
+		assert.Equal(t, []uint64{1, 2, 3, 4}, nodesOfN)
+	}
+}
+
+func TestReconfigRemovesLeader(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 5
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+	assert.Equal(t, uint64(1), nodes[1].Consensus.GetLeaderID())
+
+	newConfig := fastConfig
+	newConfig.NewViewOnLeaderRemoval = true
+
+	// The leader submits a reconfiguration that removes itself
+	nodes[0].Submit(Request{
+		ClientID: "reconfig",
+		ID:       "1",
+		Reconfig: Reconfig{
+			InLatestDecision: true,
+			CurrentNodes:     []int64{2, 3, 4, 5},
+			CurrentConfig:    recconfigToInt(types.Reconfig{CurrentConfig: newConfig}).CurrentConfig,
+		},
+	})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+
+	// The remaining nodes move to the next view, which is led by the second of them, and keep delivering
+	nodes = nodes[1:]
+	for _, n := range nodes {
+		assert.Eventually(t, func() bool {
+			return n.Consensus.GetLeaderID() == 3
+		}, 30*time.Second, 10*time.Millisecond)
+	}
+	for i := range nodes {
+		nodes[i].Submit(Request{ID: "2", ClientID: "alice"})
+	}
+	data := make([]*AppRecord, 0)
+	for i := range nodes {
+		select {
+		case d := <-nodes[i].Delivered:
+			data = append(data, d)
+		case <-time.After(30 * time.Second):
+			t.Fatalf("node %d did not deliver after the reconfiguration", i+2)
+		}
+	}
+	for i := 0; i < len(data)-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+	md := &smartbftprotos.ViewMetadata{}
+	assert.NoError(t, proto.Unmarshal(data[0].Metadata, md))
+	assert.Equal(t, uint64(1), md.ViewId)
+	assert.Equal(t, uint64(0), md.DecisionsInView)
+}
+
+func TestDecommissionFollower(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 5
+	nodes := make([]*App, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		nodes = append(nodes, n)
+	}
+	startNodes(nodes, network)
+
+	nodes[0].Submit(Request{ID: "1", ClientID: "alice"})
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+
+	// The last node decommissions itself, and all nodes deliver its removal
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	assert.NoError(t, nodes[4].Consensus.Decommission(ctx))
+	for i := 0; i < numberOfNodes; i++ {
+		<-nodes[i].Delivered
+	}
+
+	// The remaining nodes keep delivering without it
+	nodes = nodes[:4]
+	for _, n := range nodes {
+		assert.Eventually(t, func() bool {
+			membership, _ := n.Consensus.Membership()
+			return len(membership) == 4
+		}, 30*time.Second, 10*time.Millisecond)
+	}
+	nodes[0].Submit(Request{ID: "2", ClientID: "alice"})
+	data := make([]*AppRecord, 0)
+	for i := range nodes {
+		select {
+		case d := <-nodes[i].Delivered:
+			data = append(data, d)
+		case <-time.After(30 * time.Second):
+			t.Fatalf("node %d did not deliver after the decommission", i+1)
+		}
+	}
+	for i := 0; i < len(data)-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+	membership, _ := nodes[0].Consensus.Membership()
+	assert.Equal(t, []uint64{1, 2, 3, 4}, membership)
+}
+
+func TestPauseDeliveryAcrossReconfig(t *testing.T) {
+	t.Parallel()
+	network := NewNetwork()
+	defer network.Shutdown()
+
+	testDir, err := os.MkdirTemp("", t.Name())
+	assert.NoErrorf(t, err, "generate temporary test dir")
+	defer os.RemoveAll(testDir)
+
+	numberOfNodes := 4
+	nodes := make([]*App, 0)
+	recorders := make([]*metricsRecorder, 0)
+	for i := 1; i <= numberOfNodes; i++ {
+		n := newNode(uint64(i), network, t.Name(), testDir, false, 0)
+		r := newMetricsRecorder()
+		n.UseMetrics(r)
+		nodes = append(nodes, n)
+		recorders = append(recorders, r)
+	}
+	startNodes(nodes, network)
+
+	nodes[3].Consensus.PauseDelivery()
+
+	newConfig := fastConfig
+	newConfig.CollectTimeout = fastConfig.CollectTimeout * 2
+
+	nodes[0].Submit(Request{
+		ClientID: "reconfig",
+		ID:       "10",
+		Reconfig: Reconfig{
+			InLatestDecision: true,
+			CurrentNodes:     nodesToInt(nodes[0].Node.Nodes()),
+			CurrentConfig:    recconfigToInt(types.Reconfig{CurrentConfig: newConfig}).CurrentConfig,
+		},
+	})
+
+	reconfigDecision := <-nodes[0].Delivered
+	for i := 1; i < numberOfNodes-1; i++ {
+		assert.Equal(t, reconfigDecision, <-nodes[i].Delivered)
+	}
+
+	// The paused node orders the reconfiguration, but holds it back and does not apply it
+	assert.Eventually(t, func() bool {
+		return recorders[3].Value("consensus_count_paused_decisions") == float64(1)
+	}, 30*time.Second, 100*time.Millisecond)
+	assert.Len(t, nodes[3].Delivered, 0)
+	_, config := nodes[3].Consensus.Membership()
+	assert.Equal(t, fastConfig.CollectTimeout, config.CollectTimeout)
+	assert.Equal(t, float64(0), recorders[3].Value("consensus_reconfig"))
+
+	nodes[3].Consensus.ResumeDelivery()
+	select {
+	case delivered := <-nodes[3].Delivered:
+		assert.Equal(t, reconfigDecision, delivered)
+	case <-time.After(30 * time.Second):
+		t.Fatal("the reconfiguration was not delivered once delivery was resumed")
+	}
+	assert.Eventually(t, func() bool {
+		_, config := nodes[3].Consensus.Membership()
+		return config.CollectTimeout == newConfig.CollectTimeout
+	}, 30*time.Second, 100*time.Millisecond)
+	assert.Equal(t, float64(0), recorders[3].Value("consensus_count_paused_decisions"))
+
+	// The node keeps ordering under the new configuration
+	nodes[0].Submit(Request{ID: "11", ClientID: "alice"})
+	data := make([]*AppRecord, 0)
+	for i := 0; i < numberOfNodes; i++ {
+		data = append(data, <-nodes[i].Delivered)
+	}
+	for i := 0; i < numberOfNodes-1; i++ {
+		assert.Equal(t, data[i], data[i+1])
+	}
+}
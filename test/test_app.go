@@ -6,6 +6,8 @@
 package test
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/asn1"
 	"fmt"
 	"path/filepath"
@@ -21,6 +23,7 @@ import (
 	"github.com/hyperledger-labs/SmartBFT/pkg/types"
 	"github.com/hyperledger-labs/SmartBFT/pkg/wal"
 	"github.com/hyperledger-labs/SmartBFT/smartbftprotos"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -43,6 +46,7 @@ var fastConfig = types.Configuration{
 	LeaderRotation:                false,
 	RequestMaxBytes:               10 * 1024,
 	RequestPoolSubmitTimeout:      5 * time.Second,
+	ResendRecoveredProposal:       true,
 }
 
 // App implements all interfaces required by an application using this library
@@ -64,9 +68,43 @@ type App struct {
 	lastRecord      lastRecord
 	verificationSeq uint64
 	messageLost     func(*smartbftprotos.Message) bool
+	selectRequests  func(candidates [][]byte) (chosen, remainder [][]byte)
+	assembleFails   int32
+	assembleCalls   int32
+	deliverFails    int32
+	deliverCalls    int32
+	deliveredTraces chan []string
+	quorumEvents    chan bool
+	suspicions      chan string
+	halts           chan error
+	leadership      chan leadershipChange
+	batches         chan observedBatch
+	keyRotations    sync.Map // node ID -> the verification sequence from which its rotated key is used
+	signingKey      atomic.Value
 	lock            sync.Mutex
 }
 
+type leadershipChange struct {
+	isLeader bool
+	view     uint64
+}
+
+type observedBatch struct {
+	proposal types.Proposal
+	requests []types.RequestInfo
+}
+
+// offsetIDMapper maps the internal ID of a node to a client-facing ID which is larger by a fixed offset
+type offsetIDMapper uint64
+
+func (m offsetIDMapper) ClientFacingID(internalID uint64) uint64 {
+	return internalID + uint64(m)
+}
+
+func (m offsetIDMapper) InternalID(clientFacingID uint64) uint64 {
+	return clientFacingID - uint64(m)
+}
+
 type lastRecord struct {
 	proposal   types.Proposal
 	signatures []types.Signature
@@ -82,6 +120,12 @@ func (a *App) UnMute() {
 	a.logLevel.SetLevel(zapcore.DebugLevel)
 }
 
+// UseMetrics makes the node report its metrics to the given provider
+func (a *App) UseMetrics(p metrics.Provider) {
+	a.metricsProvider = p
+	a.Consensus.Metrics = api.NewMetrics(p)
+}
+
 // Submit submits the client request
 func (a *App) Submit(req Request) {
 	a.Consensus.SubmitRequest(req.ToBytes())
@@ -200,6 +244,7 @@ func (a *App) RequestID(req []byte) types.RequestInfo {
 	return types.RequestInfo{
 		ClientID: txn.ClientID,
 		ID:       txn.ID,
+		TraceID:  txn.TraceID,
 	}
 }
 
@@ -209,7 +254,7 @@ func (a *App) VerifyProposal(proposal types.Proposal) ([]types.RequestInfo, erro
 	requests := make([]types.RequestInfo, 0)
 	for _, t := range blockData.Requests {
 		req := requestFromBytes(t)
-		reqInfo := types.RequestInfo{ID: req.ID, ClientID: req.ClientID}
+		reqInfo := types.RequestInfo{ID: req.ID, ClientID: req.ClientID, TraceID: req.TraceID}
 		requests = append(requests, reqInfo)
 	}
 	return requests, nil
@@ -221,7 +266,7 @@ func (a *App) RequestsFromProposal(proposal types.Proposal) []types.RequestInfo
 	requests := make([]types.RequestInfo, 0)
 	for _, t := range blockData.Requests {
 		req := requestFromBytes(t)
-		reqInfo := types.RequestInfo{ID: req.ID, ClientID: req.ClientID}
+		reqInfo := types.RequestInfo{ID: req.ID, ClientID: req.ClientID, TraceID: req.TraceID}
 		requests = append(requests, reqInfo)
 	}
 	return requests
@@ -230,14 +275,31 @@ func (a *App) RequestsFromProposal(proposal types.Proposal) []types.RequestInfo
 // VerifyRequest verifies the given request and returns its info
 func (a *App) VerifyRequest(val []byte) (types.RequestInfo, error) {
 	req := requestFromBytes(val)
-	return types.RequestInfo{ID: req.ID, ClientID: req.ClientID}, nil
+	return types.RequestInfo{ID: req.ID, ClientID: req.ClientID, TraceID: req.TraceID}, nil
 }
 
 // VerifyConsenterSig verifies a nodes signature on the given proposal
-func (a *App) VerifyConsenterSig(signature types.Signature, _ types.Proposal) ([]byte, error) {
+func (a *App) VerifyConsenterSig(signature types.Signature, proposal types.Proposal) ([]byte, error) {
+	if expected := a.keyOf(signature.ID, uint64(proposal.VerificationSequence)); !bytes.Equal(expected, signature.Value) {
+		return nil, errors.Errorf("signature of %d is not signed by its key %s", signature.ID, expected)
+	}
 	return signature.Msg, nil
 }
 
+// keyOf returns the signing key of the given node at the given verification sequence
+func (a *App) keyOf(id uint64, verificationSeq uint64) []byte {
+	rotatedAt, exists := a.keyRotations.Load(id)
+	if !exists || verificationSeq < rotatedAt.(uint64) {
+		return nil
+	}
+	return []byte(fmt.Sprintf("key-%d-%d", id, rotatedAt.(uint64)))
+}
+
+// RotateKey switches the signing key to the one used at the new verification sequence
+func (a *App) RotateKey(_, newVerificationSequence uint64) {
+	a.signingKey.Store(a.keyOf(a.ID, newVerificationSequence))
+}
+
 func (a *App) AuxiliaryData(msg []byte) []byte {
 	return msg
 }
@@ -263,7 +325,8 @@ func (a *App) SignProposal(_ types.Proposal, aux []byte) *types.Signature {
 	if len(aux) == 0 && cnt > 1 && a.messageLost == nil {
 		a.logger.Panicf("didn't receive prepares from anyone, n=%d", cnt)
 	}
-	return &types.Signature{ID: a.ID, Msg: aux}
+	key, _ := a.signingKey.Load().([]byte)
+	return &types.Signature{ID: a.ID, Value: key, Msg: aux}
 }
 
 // AssembleProposal assembles a new proposal from the given requests
@@ -275,12 +338,120 @@ func (a *App) AssembleProposal(metadata []byte, requests [][]byte) types.Proposa
 	}
 }
 
+// PayloadRequests returns the requests of the batch in the given payload
+func (a *App) PayloadRequests(payload []byte) [][]byte {
+	return batchFromBytes(payload).Requests
+}
+
+// AssemblePayload returns the payload of the batch of the given requests
+func (a *App) AssemblePayload(requests [][]byte) []byte {
+	return batch{Requests: requests}.toBytes()
+}
+
+// AssembleProposalFromCandidates assembles a new proposal from the requests selected out of the given candidates
+func (a *App) AssembleProposalFromCandidates(metadata []byte, candidates [][]byte) (types.Proposal, [][]byte) {
+	chosen, remainder := a.selectRequests(candidates)
+	return a.AssembleProposal(metadata, chosen), remainder
+}
+
+// TryAssembleProposal assembles a new proposal from the given requests, unless it is set to fail
+func (a *App) TryAssembleProposal(metadata []byte, requests [][]byte) (types.Proposal, error) {
+	atomic.AddInt32(&a.assembleCalls, 1)
+	if atomic.AddInt32(&a.assembleFails, -1) >= 0 {
+		return types.Proposal{}, errors.New("assembly failed")
+	}
+	return a.AssembleProposal(metadata, requests), nil
+}
+
+// OnDeliverTraces records the trace IDs of the requests in the delivered proposal
+func (a *App) OnDeliverTraces(_ types.Proposal, traceIDs []string) {
+	a.deliveredTraces <- traceIDs
+}
+
+// TryDeliver delivers the given proposal, unless it is set to fail
+func (a *App) TryDeliver(proposal types.Proposal, signatures []types.Signature) (types.Reconfig, error) {
+	atomic.AddInt32(&a.deliverCalls, 1)
+	if atomic.AddInt32(&a.deliverFails, -1) >= 0 {
+		return types.Reconfig{}, errors.New("delivery failed")
+	}
+	return a.Deliver(proposal, signatures), nil
+}
+
+// viewRecordingApp delivers the decisions to the App along with the views they were committed in
+type viewRecordingApp struct {
+	*App
+}
+
+// DeliverInView delivers the given proposal, and records the view it was committed in
+func (a *viewRecordingApp) DeliverInView(proposal types.Proposal, signatures []types.Signature, commitView uint64) types.Reconfig {
+	return a.deliverInView(proposal, signatures, commitView)
+}
+
+// TryDeliverInView delivers the given proposal and records the view it was committed in, unless it is set to fail
+func (a *viewRecordingApp) TryDeliverInView(proposal types.Proposal, signatures []types.Signature, commitView uint64) (types.Reconfig, error) {
+	atomic.AddInt32(&a.deliverCalls, 1)
+	if atomic.AddInt32(&a.deliverFails, -1) >= 0 {
+		return types.Reconfig{}, errors.New("delivery failed")
+	}
+	return a.deliverInView(proposal, signatures, commitView), nil
+}
+
+// OnLeaderSuspected records the reason the leader was suspected for
+func (a *App) OnLeaderSuspected(_ uint64, _ uint64, reason string) {
+	a.suspicions <- reason
+}
+
+// OnHalt records the error the node halted due to
+func (a *App) OnHalt(err error) {
+	a.halts <- err
+}
+
+// OnLeadershipChange records whether the node leads the given view
+func (a *App) OnLeadershipChange(isLeader bool, view uint64) {
+	a.leadership <- leadershipChange{isLeader: isLeader, view: view}
+}
+
+// OnBatch records the proposal verified by the node along with its requests
+func (a *App) OnBatch(proposal types.Proposal, requests []types.RequestInfo) {
+	a.batches <- observedBatch{proposal: proposal, requests: requests}
+}
+
+// DecorateDecision sets the header of the proposal to the digest of its payload, as a state root would be
+func (a *App) DecorateDecision(proposal types.Proposal) types.Proposal {
+	digest := sha256.Sum256(proposal.Payload)
+	proposal.Header = digest[:]
+	return proposal
+}
+
+// OnQuorumReachabilityChange records whether the quorum is reachable
+func (a *App) OnQuorumReachabilityChange(reachable bool) {
+	a.quorumEvents <- reachable
+}
+
 func (a *App) MembershipChange() bool {
 	return false
 }
 
+// RemovalRequest returns a reconfig request that removes the given node
+func (a *App) RemovalRequest(removed uint64, nodes []uint64, config types.Configuration) []byte {
+	return Request{
+		ClientID: "reconfig",
+		ID:       fmt.Sprintf("remove-%d", removed),
+		Reconfig: Reconfig{
+			InLatestDecision: true,
+			CurrentNodes:     nodesToInt(nodes),
+			CurrentConfig:    recconfigToInt(types.Reconfig{CurrentConfig: config}).CurrentConfig,
+		},
+	}.ToBytes()
+}
+
 // Deliver delivers the given proposal
 func (a *App) Deliver(proposal types.Proposal, signatures []types.Signature) types.Reconfig {
+	return a.deliverInView(proposal, signatures, 0)
+}
+
+// deliverInView delivers the given proposal, and records the view it was committed in
+func (a *App) deliverInView(proposal types.Proposal, signatures []types.Signature, commitView uint64) types.Reconfig {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	defer func() {
@@ -290,8 +461,9 @@ func (a *App) Deliver(proposal types.Proposal, signatures []types.Signature) typ
 		}
 	}()
 	record := &AppRecord{
-		Metadata: proposal.Metadata,
-		Batch:    batchFromBytes(proposal.Payload),
+		Metadata:   proposal.Metadata,
+		Batch:      batchFromBytes(proposal.Payload),
+		CommitView: commitView,
 	}
 	a.Node.cb.add(record)
 	a.lastDecision = &types.Decision{
@@ -311,13 +483,20 @@ func (a *App) Deliver(proposal types.Proposal, signatures []types.Signature) typ
 		a.logger.Panicf("Committed sequence %d twice", prevSeq)
 	}
 
+	for _, req := range record.Batch.Requests {
+		if request := requestFromBytes(req); request.RotateKey != 0 {
+			a.keyRotations.Store(uint64(request.RotateKey), atomic.LoadUint64(&a.verificationSeq)+1)
+			atomic.AddUint64(&a.verificationSeq, 1)
+		}
+	}
+
 	a.Delivered <- record
 
 	for _, req := range record.Batch.Requests {
 		request := requestFromBytes(req)
 		if request.Reconfig.InLatestDecision {
 			reconfig := request.Reconfig.recconfigToUint(a.ID)
-			return types.Reconfig{InLatestDecision: true, CurrentNodes: reconfig.CurrentNodes, CurrentConfig: reconfig.CurrentConfig}
+			return types.Reconfig{InLatestDecision: true, CurrentNodes: reconfig.CurrentNodes, CurrentConfig: reconfig.CurrentConfig, BasedOnNodes: reconfig.BasedOnNodes}
 		}
 	}
 
@@ -375,6 +554,9 @@ type Request struct {
 	ClientID string
 	ID       string
 	Reconfig Reconfig
+	TraceID  string
+	// RotateKey is the ID of a node whose signing key is rotated once the request is delivered
+	RotateKey int64
 }
 
 // ToBytes returns a byte array representation of the request
@@ -412,8 +594,9 @@ func batchFromBytes(rawBlock []byte) *batch {
 
 // AppRecord represents a committed batch and metadata
 type AppRecord struct {
-	Batch    *batch
-	Metadata []byte
+	Batch      *batch
+	Metadata   []byte
+	CommitView uint64
 }
 
 func newNode(id uint64, network *Network, testName string, testDir string, rotateLeader bool, decisionsPerLeader uint64) *App {
@@ -468,6 +651,7 @@ func newNode(id uint64, network *Network, testName string, testDir string, rotat
 			Verifier:           app,
 			Signer:             app,
 			MembershipNotifier: app,
+			RemovalRequester:   app,
 			RequestInspector:   app,
 			Assembler:          app,
 			Synchronizer:       app,